@@ -143,6 +143,95 @@ const (
 	// Note: The instruction list is too long to enumerate in godoc.
 	// See https://github.com/WebAssembly/spec/blob/wg-2.0.draft1/proposals/simd/SIMD.md
 	CoreFeatureSIMD
+
+	// CoreFeatureThreads enables shared memories and atomic instructions
+	// ("threads"). This is not yet included in any WebAssembly Core
+	// Specification version.
+	//
+	// Here are the notable effects:
+	//   - Memories can be declared `shared`, which requires a maximum size.
+	//     A shared memory can be imported by multiple modules, possibly
+	//     executing on different goroutines.
+	//   - Adds `memory.atomic.notify`, `memory.atomic.wait32`,
+	//     `memory.atomic.wait64` and `atomic.fence` instructions.
+	//   - Adds atomic load, store, read-modify-write and compare-exchange
+	//     instructions, e.g. `i32.atomic.load` or `i64.atomic.rmw.cmpxchg`.
+	//
+	// Note: Atomic instructions trap when their effective address is not
+	// naturally aligned, and `memory.atomic.wait32` or `memory.atomic.wait64`
+	// trap when the memory is not shared.
+	//
+	// See https://github.com/WebAssembly/threads/blob/main/proposals/threads/Overview.md
+	CoreFeatureThreads
+
+	// CoreFeatureTailCall enables the `return_call` and `return_call_indirect`
+	// instructions ("tail-call"). This is not yet included in any WebAssembly
+	// Core Specification version.
+	//
+	// Here are the notable effects:
+	//   - `return_call` and `return_call_indirect` call a function and return
+	//     its results from the current function. The callee reuses the frame
+	//     of the caller, so deep tail recursion doesn't exhaust the call stack.
+	//   - The results of the callee must match those of the current function.
+	//
+	// Note: The optimizing compiler (experimental/opt) executes tail calls as
+	// regular calls followed by a return, so it doesn't guarantee a constant
+	// stack: deep tail recursion fails with "stack overflow" as with calls.
+	//
+	// See https://github.com/WebAssembly/tail-call/blob/main/proposals/tail-call/Overview.md
+	CoreFeatureTailCall
+
+	// CoreFeatureMultiMemory enables a module to use more than one memory
+	// ("multi-memory"). This is not yet included in any WebAssembly Core
+	// Specification version.
+	//
+	// Here are the notable effects:
+	//   - A module can import and define any number of memories.
+	//   - Memory instructions, such as `i32.load` or `memory.grow`, encode the
+	//     index of the memory they operate on.
+	//   - `memory.copy` can copy between two different memories.
+	//   - Active data segments can initialize any memory.
+	//
+	// See https://github.com/WebAssembly/multi-memory/blob/main/proposals/multi-memory/Overview.md
+	CoreFeatureMultiMemory
+
+	// CoreFeatureMemory64 enables memories addressed with 64-bit integers
+	// ("memory64"). This is not yet included in any WebAssembly Core
+	// Specification version.
+	//
+	// Here are the notable effects:
+	//   - A memory can be declared with the i64 index type, in which case its
+	//     limits aren't capped to 65536 pages (4GiB).
+	//   - Memory instructions on such a memory take i64 addresses, and the
+	//     offset of their memory argument is 64-bit.
+	//   - `memory.size` and `memory.grow` on such a memory return an i64.
+	//
+	// Note: The maximum pages of a 64-bit memory whose maximum isn't encoded
+	// is controlled by wazero.RuntimeConfig WithMemoryLimitPages. Use the
+	// 64-bit variants of the api.Memory accessors, such as Read64, to access
+	// the memory beyond 4GiB from host functions.
+	//
+	// See https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md
+	CoreFeatureMemory64
+
+	// CoreFeatureExceptionHandling enables throwing and catching exceptions
+	// ("exception-handling"). This is not yet included in any WebAssembly
+	// Core Specification version.
+	//
+	// Here are the notable effects:
+	//   - Adds the tag section, whose tags can be imported and exported, and
+	//     identify exceptions and the types of values they carry.
+	//   - Adds the `exnref` value type, which references a caught exception.
+	//   - Adds `throw`, `throw_ref` and `try_table` instructions. The latter
+	//     is a block whose catch clauses branch to a label when an exception
+	//     with a matching tag is thrown from within.
+	//
+	// Note: An exception not caught by the guest is returned as an error by
+	// api.Function Call, which wraps *api.Exception. A host function can
+	// throw an exception to the guest by panicking with NewException.
+	//
+	// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md
+	CoreFeatureExceptionHandling
 )
 
 // SetEnabled enables or disables the feature or group of features.
@@ -207,6 +296,21 @@ func featureName(f CoreFeatures) string {
 	case CoreFeatureSIMD:
 		// match https://github.com/WebAssembly/spec/blob/wg-2.0.draft1/proposals/simd/SIMD.md
 		return "simd"
+	case CoreFeatureThreads:
+		// match https://github.com/WebAssembly/threads/blob/main/proposals/threads/Overview.md
+		return "threads"
+	case CoreFeatureTailCall:
+		// match https://github.com/WebAssembly/tail-call/blob/main/proposals/tail-call/Overview.md
+		return "tail-call"
+	case CoreFeatureMultiMemory:
+		// match https://github.com/WebAssembly/multi-memory/blob/main/proposals/multi-memory/Overview.md
+		return "multi-memory"
+	case CoreFeatureMemory64:
+		// match https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md
+		return "memory64"
+	case CoreFeatureExceptionHandling:
+		// match https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md
+		return "exception-handling"
 	}
 	return ""
 }
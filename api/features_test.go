@@ -61,6 +61,11 @@ func TestCoreFeatures_String(t *testing.T) {
 		{name: "sign-extension-ops", feature: CoreFeatureSignExtensionOps, expected: "sign-extension-ops"},
 		{name: "multi-value", feature: CoreFeatureMultiValue, expected: "multi-value"},
 		{name: "simd", feature: CoreFeatureSIMD, expected: "simd"},
+		{name: "threads", feature: CoreFeatureThreads, expected: "threads"},
+		{name: "tail-call", feature: CoreFeatureTailCall, expected: "tail-call"},
+		{name: "multi-memory", feature: CoreFeatureMultiMemory, expected: "multi-memory"},
+		{name: "memory64", feature: CoreFeatureMemory64, expected: "memory64"},
+		{name: "exception-handling", feature: CoreFeatureExceptionHandling, expected: "exception-handling"},
 		{name: "features", feature: CoreFeatureMutableGlobal | CoreFeatureMultiValue, expected: "multi-value|mutable-global"},
 		{name: "undefined", feature: 1 << 63, expected: ""},
 		{
@@ -19,6 +19,8 @@ const (
 	ExternTypeTable  ExternType = 0x01
 	ExternTypeMemory ExternType = 0x02
 	ExternTypeGlobal ExternType = 0x03
+	// ExternTypeTag is an exception tag, which requires CoreFeatureExceptionHandling.
+	ExternTypeTag ExternType = 0x04
 )
 
 // The below are exported to consolidate parsing behavior for external types.
@@ -31,6 +33,8 @@ const (
 	ExternTypeMemoryName = "memory"
 	// ExternTypeGlobalName is the name of the WebAssembly 1.0 (20191205) Text Format field for ExternTypeGlobal.
 	ExternTypeGlobalName = "global"
+	// ExternTypeTagName is the name of the exception-handling proposal Text Format field for ExternTypeTag.
+	ExternTypeTagName = "tag"
 )
 
 // ExternTypeName returns the name of the WebAssembly 1.0 (20191205) Text Format field of the given type.
@@ -46,6 +50,8 @@ func ExternTypeName(et ExternType) string {
 		return ExternTypeMemoryName
 	case ExternTypeGlobal:
 		return ExternTypeGlobalName
+	case ExternTypeTag:
+		return ExternTypeTagName
 	}
 	return fmt.Sprintf("%#x", et)
 }
@@ -60,7 +66,8 @@ func ExternTypeName(et ExternType) string {
 //   - ValueTypeF32 - EncodeF32 DecodeF32 from float32
 //   - ValueTypeF64 - EncodeF64 DecodeF64 from float64
 //   - ValueTypeExternref - unintptr(unsafe.Pointer(p)) where p is any pointer
-//     type in Go (e.g. *string)
+//     type in Go (e.g. *string), or ExternrefTable Ref for any Go value
+//   - ValueTypeFuncref - opaque reference, see Table
 //
 // e.g. Given a Text Format type use (param i64) (result i64), no conversion is
 // necessary.
@@ -105,8 +112,18 @@ const (
 	//		WithFunc(func(context.Context, _ uintptr) (_ uintptr) { return }).
 	//		Export("f")
 	//
+	// Alternatively, an interface{} parameter or result passes any Go value, via the ExternrefTable of the runtime:
+	//		WithFunc(func(context.Context, conn interface{}) (_ interface{}) { return }).
+	//
 	// Note: The usage of this type is toggled with api.CoreFeatureBulkMemoryOperations.
 	ValueTypeExternref ValueType = 0x6f
+
+	// ValueTypeFuncref is a funcref type.
+	//
+	// Note: in wazero, funcref type values are opaque references to functions
+	// of the same Runtime, which can only be obtained from wazero, e.g. via
+	// Table Get. Zero is the null reference.
+	ValueTypeFuncref ValueType = 0x70
 )
 
 // ValueTypeName returns the type name of the given ValueType as a string.
@@ -125,6 +142,8 @@ func ValueTypeName(t ValueType) string {
 		return "f64"
 	case ValueTypeExternref:
 		return "externref"
+	case ValueTypeFuncref:
+		return "funcref"
 	}
 	return "unknown"
 }
@@ -147,6 +166,9 @@ type Module interface {
 	Name() string
 
 	// Memory returns a memory defined in this module or nil if there are none wasn't.
+	//
+	// Note: When the module has multiple memories (CoreFeatureMultiMemory), this returns the one at index zero. Use
+	// ExportedMemory to access the others.
 	Memory() Memory
 
 	// ExportedFunction returns a function exported from this module or nil if it wasn't.
@@ -160,7 +182,8 @@ type Module interface {
 	// definitions in this module, keyed on export name.
 	ExportedFunctionDefinitions() map[string]FunctionDefinition
 
-	// TODO: Table
+	// ExportedTable returns a table exported from this module or nil if it wasn't.
+	ExportedTable(name string) Table
 
 	// ExportedMemory returns a memory exported from this module or nil if it wasn't.
 	//
@@ -180,6 +203,43 @@ type Module interface {
 	// ExportedGlobal a global exported from this module or nil if it wasn't.
 	ExportedGlobal(name string) Global
 
+	// ExportedTag returns an exception tag exported from this module or nil if it wasn't.
+	//
+	// Note: Tags require CoreFeatureExceptionHandling. Use one to throw an exception from a host function with
+	// NewException, or to identify an Exception returned by Function.Call.
+	ExportedTag(name string) Tag
+
+	// SetFuel sets the fuel remaining for the functions defined in this module to execute.
+	//
+	// Fuel is only consumed when wazero.RuntimeConfig WithFuelMetering is enabled: one unit on each entry to a
+	// function and on each iteration of a loop. Once it runs out, the call fails with a sys.ExitError whose
+	// ExitCode is sys.ExitCodeFuelExhausted.
+	//
+	// Note: This is safe to call inside a host function, but not concurrently with a function call on another
+	// goroutine.
+	SetFuel(fuel uint64)
+
+	// AddFuel adds the given amount to the fuel remaining. See SetFuel for details.
+	AddFuel(fuel uint64)
+
+	// RemainingFuel returns the fuel remaining. See SetFuel for details.
+	RemainingFuel() uint64
+
+	// SetEpochDeadline sets the deadline of the functions defined in this module to the given number of ticks
+	// after the current epoch of the wazero.Runtime, which is advanced by its IncrementEpoch method.
+	//
+	// The deadline is only checked when wazero.RuntimeConfig WithEpochInterruption is enabled: on each entry to a
+	// function and on each iteration of a loop. Once the epoch reaches the deadline, the call fails with a
+	// sys.ExitError whose ExitCode is sys.ExitCodeEpochDeadlineExceeded. Until this is called, there's no deadline.
+	//
+	// The deadline is kept by the module across calls, including the calls in progress, so this is typically called
+	// before each call to bound its duration. To bound a single call instead, use experimental.WithEpochDeadline,
+	// which applies in addition to this deadline.
+	//
+	// Note: This is safe to call inside a host function, but not concurrently with a function call on another
+	// goroutine.
+	SetEpochDeadline(ticks uint64)
+
 	// CloseWithExitCode releases resources allocated for this Module. Use a non-zero exitCode parameter to indicate a
 	// failure to ExportedFunction callers.
 	//
@@ -285,6 +345,32 @@ type MemoryDefinition interface {
 	internalapi.WazeroOnly
 }
 
+// TableDefinition is a WebAssembly table exported in a module
+// (wazero.CompiledModule). Units are in elements.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#exports%E2%91%A0
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type TableDefinition interface {
+	ExportDefinition
+
+	// RefType returns the type of the elements: ValueTypeFuncref or
+	// ValueTypeExternref.
+	RefType() ValueType
+
+	// Min returns the possibly zero initial count of elements.
+	Min() uint32
+
+	// Max returns the possibly zero max count of elements, or false if
+	// unbounded.
+	Max() (uint32, bool)
+
+	internalapi.WazeroOnly
+}
+
 // FunctionDefinition is a WebAssembly function exported in a module
 // (wazero.CompiledModule).
 //
@@ -546,6 +632,137 @@ type MutableGlobal interface {
 	internalapi.WazeroOnly
 }
 
+// Tag is an exception tag (CoreFeatureExceptionHandling), which identifies exceptions and the types of the values they
+// carry. Two tags are the same if they are equal, e.g. when one module imports the tag exported by another.
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type Tag interface {
+	// ParamTypes are the types of the values carried by exceptions with this tag.
+	ParamTypes() []ValueType
+
+	internalapi.WazeroOnly
+}
+
+// Exception is a WebAssembly exception (CoreFeatureExceptionHandling) thrown with a Tag.
+//
+// When the guest throws an exception that it doesn't catch, Function.Call returns an error which wraps the
+// *Exception, so it can be inspected with errors.As. Conversely, a host function can throw an exception to the guest
+// by panicking with the result of NewException, which the guest can catch with the try_table instruction.
+type Exception struct {
+	tag    Tag
+	params []uint64
+}
+
+// NewException returns an Exception with the given tag, which carries the params encoded as described by the
+// Tag.ParamTypes.
+//
+// For example, this throws an exception with a tag exported by the calling module from a host function:
+//
+//	panic(api.NewException(mod.ExportedTag("error"), api.EncodeI32(errno)))
+func NewException(tag Tag, params ...uint64) *Exception {
+	return &Exception{tag: tag, params: params}
+}
+
+// Tag returns the tag this exception was thrown with.
+func (e *Exception) Tag() Tag {
+	return e.tag
+}
+
+// Params returns the values carried by this exception. See Tag.ParamTypes for how to decode them.
+func (e *Exception) Params() []uint64 {
+	return e.params
+}
+
+// Error implements error.
+func (e *Exception) Error() string {
+	return fmt.Sprintf("uncaught exception with params %v", e.params)
+}
+
+// Table allows access to a module's table from the host, e.g. to register a callback by writing a function reference
+// into a table used by call_indirect.
+//
+// The elements are opaque references encoded the same way as ValueTypeFuncref and ValueTypeExternref values, where
+// zero is the null reference.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#table-instances%E2%91%A0
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+//   - A table must not be modified while a function which accesses it is executing.
+type Table interface {
+	// Definition is metadata about this table from its defining module.
+	Definition() TableDefinition
+
+	// Size returns the count of elements in this table.
+	Size() uint32
+
+	// Get returns the reference at the offset or false if out of range.
+	Get(offset uint32) (ref uint64, ok bool)
+
+	// Set writes the reference at the offset or returns false if out of range.
+	//
+	// Note: For a table of ValueTypeFuncref, only the null reference (zero) can be written, and false is returned
+	// otherwise. Use SetFunction to write a reference to an api.Function.
+	Set(offset uint32, ref uint64) bool
+
+	// SetFunction writes a reference to the function at the offset, or returns false if out of range or this isn't a
+	// table of ValueTypeFuncref. The function can be defined by any module of the same wazero.Runtime, including host
+	// modules, and must not be used after that module is closed.
+	SetFunction(offset uint32, fn Function) bool
+
+	// Grow increases the table by the delta in elements, which are initialized to the reference. The return val is
+	// the previous size, or false if the delta was ignored as it exceeds TableDefinition.Max.
+	//
+	// For a table of ValueTypeFuncref, the reference must be zero, like in Set, otherwise false is returned. Use
+	// SetFunction to write functions into the new elements.
+	//
+	// Note: This is the same as the "table.grow" instruction defined in the WebAssembly Core Specification, except
+	// returns false instead of -1.
+	Grow(delta uint32, initialRef uint64) (previousSize uint32, ok bool)
+
+	internalapi.WazeroOnly
+}
+
+// ExternrefTable maps ValueTypeExternref values to Go values, which allows guests to hold opaque references to host
+// objects, such as database connections. The zero externref is the null reference, which maps to nil.
+//
+// Host functions defined with interface{} parameters or results convert automatically. For example:
+//
+//	r.NewHostModuleBuilder("env").
+//		NewFunctionBuilder().WithFunc(func() interface{} { return db }).Export("open").
+//		NewFunctionBuilder().WithFunc(func(conn interface{}, id uint32) {
+//			conn.(*sql.DB).Exec("DELETE FROM users WHERE id = ?", id)
+//		}).Export("delete_user")
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+//   - The Go values are kept alive until released, or the wazero.Runtime is closed.
+//   - References of this table must not be mixed with references encoded by EncodeExternref.
+type ExternrefTable interface {
+	// Ref returns the externref for the Go value, or zero if the value is nil. Values which can be map keys, such as
+	// pointers, are always mapped to the same externref until it is released.
+	Ref(v interface{}) uint64
+
+	// Value returns the Go value of the externref, or nil if it is null, unknown or released.
+	Value(ref uint64) interface{}
+
+	// Release removes the externref from this table, so that the Go value can be garbage collected. The externref
+	// maps to nil afterwards, even if the same Go value is passed to Ref again.
+	Release(ref uint64)
+
+	// Len returns the count of externrefs in this table.
+	Len() int
+
+	internalapi.WazeroOnly
+}
+
 // Memory allows restricted access to a module's memory. Notably, this does not allow growing.
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#storage%E2%91%A0
@@ -562,6 +779,9 @@ type Memory interface {
 	// Size returns the size in bytes available. e.g. If the underlying memory
 	// has 1 page: 65536
 	//
+	// Note: The result is truncated when the memory is 4GiB or larger. Use
+	// Size64 for memories declared with CoreFeatureMemory64.
+	//
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#-hrefsyntax-instr-memorymathsfmemorysize%E2%91%A0
 	Size() uint32
 
@@ -670,6 +890,55 @@ type Memory interface {
 	// WriteString writes the string to the underlying buffer at the offset or returns false if out of range.
 	WriteString(offset uint32, v string) bool
 
+	// Size64 is like Size, except the size is 64-bit. Use this for memories
+	// larger than 4GiB, which require CoreFeatureMemory64.
+	Size64() uint64
+
+	// ReadByte64 is like ReadByte, except the offset is 64-bit.
+	ReadByte64(offset uint64) (byte, bool)
+
+	// ReadUint16Le64 is like ReadUint16Le, except the offset is 64-bit.
+	ReadUint16Le64(offset uint64) (uint16, bool)
+
+	// ReadUint32Le64 is like ReadUint32Le, except the offset is 64-bit.
+	ReadUint32Le64(offset uint64) (uint32, bool)
+
+	// ReadFloat32Le64 is like ReadFloat32Le, except the offset is 64-bit.
+	ReadFloat32Le64(offset uint64) (float32, bool)
+
+	// ReadUint64Le64 is like ReadUint64Le, except the offset is 64-bit.
+	ReadUint64Le64(offset uint64) (uint64, bool)
+
+	// ReadFloat64Le64 is like ReadFloat64Le, except the offset is 64-bit.
+	ReadFloat64Le64(offset uint64) (float64, bool)
+
+	// Read64 is like Read, except the offset and byteCount are 64-bit.
+	Read64(offset, byteCount uint64) ([]byte, bool)
+
+	// WriteByte64 is like WriteByte, except the offset is 64-bit.
+	WriteByte64(offset uint64, v byte) bool
+
+	// WriteUint16Le64 is like WriteUint16Le, except the offset is 64-bit.
+	WriteUint16Le64(offset uint64, v uint16) bool
+
+	// WriteUint32Le64 is like WriteUint32Le, except the offset is 64-bit.
+	WriteUint32Le64(offset uint64, v uint32) bool
+
+	// WriteFloat32Le64 is like WriteFloat32Le, except the offset is 64-bit.
+	WriteFloat32Le64(offset uint64, v float32) bool
+
+	// WriteUint64Le64 is like WriteUint64Le, except the offset is 64-bit.
+	WriteUint64Le64(offset uint64, v uint64) bool
+
+	// WriteFloat64Le64 is like WriteFloat64Le, except the offset is 64-bit.
+	WriteFloat64Le64(offset uint64, v float64) bool
+
+	// Write64 is like Write, except the offset is 64-bit.
+	Write64(offset uint64, v []byte) bool
+
+	// WriteString64 is like WriteString, except the offset is 64-bit.
+	WriteString64(offset uint64, v string) bool
+
 	internalapi.WazeroOnly
 }
 
@@ -19,6 +19,7 @@ func TestExternTypeName(t *testing.T) {
 		{"table", ExternTypeTable, "table"},
 		{"mem", ExternTypeMemory, "memory"},
 		{"global", ExternTypeGlobal, "global"},
+		{"tag", ExternTypeTag, "tag"},
 		{"unknown", 100, "0x64"},
 	}
 
@@ -42,6 +43,7 @@ func TestValueTypeName(t *testing.T) {
 		{"f32", ValueTypeF32, "f32"},
 		{"f64", ValueTypeF64, "f64"},
 		{"externref", ValueTypeExternref, "externref"},
+		{"funcref", ValueTypeFuncref, "funcref"},
 		{"unknown", 100, "unknown"},
 	}
 
@@ -112,6 +112,16 @@ type HostFunctionBuilder interface {
 	//		fn = m.ExportedFunction("__read")
 	//		results, err := fn(ctx, offset, byteCount)
 	//	--snip--
+	//
+	// # Passing Go values
+	//
+	// An interface{} parameter or result maps to api.ValueTypeExternref, and
+	// is converted with Runtime.Externrefs. This allows guests to hold opaque
+	// references to host objects:
+	//
+	//	builder.WithFunc(func(ctx context.Context, conn interface{}, id uint32) {
+	//		conn.(*sql.DB).ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	//	})
 	WithFunc(interface{}) HostFunctionBuilder
 
 	// WithName defines the optional module-local name of this function, e.g.
@@ -185,6 +195,58 @@ type HostModuleBuilder interface {
 	// NewFunctionBuilder begins the definition of a host function.
 	NewFunctionBuilder() HostFunctionBuilder
 
+	// ExportTable adds a table exported under the name, which holds references of the type: api.ValueTypeFuncref or
+	// api.ValueTypeExternref. The table initially has `min` null elements, and can grow without bounds.
+	//
+	// Here's an example of a table which guests import to register callbacks:
+	//
+	//	env, _ := r.NewHostModuleBuilder("env").
+	//		ExportTable("callbacks", api.ValueTypeFuncref, 10).
+	//		Instantiate(ctx)
+	//
+	// The guest functions written into the table can be looked up by the host via env.ExportedTable("callbacks").
+	//
+	// Note: Exporting a table of api.ValueTypeExternref or more than one table requires
+	// api.CoreFeatureReferenceTypes, which is enabled by default.
+	ExportTable(name string, refType api.ValueType, min uint32) HostModuleBuilder
+
+	// ExportTableWithMax is like ExportTable, except the table can grow up to `max` elements.
+	ExportTableWithMax(name string, refType api.ValueType, min, max uint32) HostModuleBuilder
+
+	// ExportMemory adds a memory exported under the name, which initially has `minPages` pages and can grow up to
+	// the limit of the runtime. The same memory is shared by all modules which import it.
+	//
+	// Here's an example of a memory shared by guests:
+	//
+	//	env, _ := r.NewHostModuleBuilder("env").
+	//		ExportMemory("memory", 1).
+	//		Instantiate(ctx)
+	//
+	// The memory can be read or written by the host via env.ExportedMemory("memory").
+	//
+	// Note: Exporting more than one memory requires api.CoreFeatureMultiMemory, otherwise Compile returns an error.
+	// See RuntimeConfig.WithMemoryLimitPages
+	ExportMemory(name string, minPages uint32) HostModuleBuilder
+
+	// ExportMemoryWithMax is like ExportMemory, except the memory can grow up to `maxPages` pages.
+	ExportMemoryWithMax(name string, minPages, maxPages uint32) HostModuleBuilder
+
+	// ExportGlobal adds an immutable global exported under the name, which has a numeric type: api.ValueTypeI32,
+	// api.ValueTypeI64, api.ValueTypeF32 or api.ValueTypeF64. The value is encoded as documented on api.Global Get.
+	//
+	// Here's an example of a feature flag which guests import:
+	//
+	//	env, _ := r.NewHostModuleBuilder("env").
+	//		ExportGlobal("debug", api.ValueTypeI32, api.EncodeI32(1)).
+	//		Instantiate(ctx)
+	ExportGlobal(name string, valueType api.ValueType, value uint64) HostModuleBuilder
+
+	// ExportMutableGlobal is like ExportGlobal, except the global is mutable, such as `env.__stack_pointer`.
+	// Changes made by the guest or via api.MutableGlobal are visible to all modules which import it.
+	//
+	// Note: Importing a mutable global requires api.CoreFeatureMutableGlobal, which is enabled by default.
+	ExportMutableGlobal(name string, valueType api.ValueType, value uint64) HostModuleBuilder
+
 	// Compile returns a CompiledModule that can be instantiated by Runtime.
 	Compile(context.Context) (CompiledModule, error)
 
@@ -218,6 +280,9 @@ type hostModuleBuilder struct {
 	moduleName     string
 	exportNames    []string
 	nameToHostFunc map[string]*wasm.HostFunc
+	tables         []wasm.HostTable
+	memories       []wasm.HostMemory
+	globals        []wasm.HostGlobal
 }
 
 // NewHostModuleBuilder implements Runtime.NewHostModuleBuilder
@@ -312,14 +377,89 @@ func (b *hostModuleBuilder) NewFunctionBuilder() HostFunctionBuilder {
 	return &hostFunctionBuilder{b: b}
 }
 
+// ExportTable implements HostModuleBuilder.ExportTable
+func (b *hostModuleBuilder) ExportTable(name string, refType api.ValueType, min uint32) HostModuleBuilder {
+	return b.exportTable(name, wasm.Table{Min: min, Type: refType})
+}
+
+// ExportTableWithMax implements HostModuleBuilder.ExportTableWithMax
+func (b *hostModuleBuilder) ExportTableWithMax(name string, refType api.ValueType, min, max uint32) HostModuleBuilder {
+	return b.exportTable(name, wasm.Table{Min: min, Max: &max, Type: refType})
+}
+
+func (b *hostModuleBuilder) exportTable(name string, table wasm.Table) HostModuleBuilder {
+	for i := range b.tables {
+		if b.tables[i].ExportName == name { // overwrite the table of the same name
+			b.tables[i].Table = table
+			return b
+		}
+	}
+	b.tables = append(b.tables, wasm.HostTable{ExportName: name, Table: table})
+	return b
+}
+
+// ExportMemory implements HostModuleBuilder.ExportMemory
+func (b *hostModuleBuilder) ExportMemory(name string, minPages uint32) HostModuleBuilder {
+	return b.exportMemory(name, wasm.Memory{Min: minPages, Max: b.r.memoryLimitPages})
+}
+
+// ExportMemoryWithMax implements HostModuleBuilder.ExportMemoryWithMax
+func (b *hostModuleBuilder) ExportMemoryWithMax(name string, minPages, maxPages uint32) HostModuleBuilder {
+	return b.exportMemory(name, wasm.Memory{Min: minPages, Max: maxPages, IsMaxEncoded: true})
+}
+
+func (b *hostModuleBuilder) exportMemory(name string, memory wasm.Memory) HostModuleBuilder {
+	// Size the memory the same way as the decoder does.
+	if memory.Cap = memory.Min; b.r.memoryCapacityFromMax {
+		memory.Cap = memory.Max
+	}
+	for i := range b.memories {
+		if b.memories[i].ExportName == name { // overwrite the memory of the same name
+			b.memories[i].Memory = memory
+			return b
+		}
+	}
+	b.memories = append(b.memories, wasm.HostMemory{ExportName: name, Memory: memory})
+	return b
+}
+
+// ExportGlobal implements HostModuleBuilder.ExportGlobal
+func (b *hostModuleBuilder) ExportGlobal(name string, valueType api.ValueType, value uint64) HostModuleBuilder {
+	return b.exportGlobal(name, wasm.GlobalType{ValType: valueType}, value)
+}
+
+// ExportMutableGlobal implements HostModuleBuilder.ExportMutableGlobal
+func (b *hostModuleBuilder) ExportMutableGlobal(name string, valueType api.ValueType, value uint64) HostModuleBuilder {
+	return b.exportGlobal(name, wasm.GlobalType{ValType: valueType, Mutable: true}, value)
+}
+
+func (b *hostModuleBuilder) exportGlobal(name string, globalType wasm.GlobalType, value uint64) HostModuleBuilder {
+	for i := range b.globals {
+		if b.globals[i].ExportName == name { // overwrite the global of the same name
+			b.globals[i].Type, b.globals[i].Value = globalType, value
+			return b
+		}
+	}
+	b.globals = append(b.globals, wasm.HostGlobal{ExportName: name, Type: globalType, Value: value})
+	return b
+}
+
 // Compile implements HostModuleBuilder.Compile
 func (b *hostModuleBuilder) Compile(ctx context.Context) (CompiledModule, error) {
 	module, err := wasm.NewHostModule(b.moduleName, b.exportNames, b.nameToHostFunc, b.r.enabledFeatures)
 	if err != nil {
 		return nil, err
+	} else if err = module.AddHostTables(b.tables, b.r.enabledFeatures); err != nil {
+		return nil, err
+	} else if err = module.AddHostMemories(b.memories, b.r.memoryLimitPages); err != nil {
+		return nil, err
+	} else if err = module.AddHostGlobals(b.globals); err != nil {
+		return nil, err
 	} else if err = module.Validate(b.r.enabledFeatures); err != nil {
 		return nil, err
 	}
+	module.BuildTableDefinitions()
+	module.BuildMemoryDefinitions()
 
 	c := &compiledModule{module: module, compiledEngine: b.r.store.Engine}
 	listeners, err := buildFunctionListeners(ctx, module)
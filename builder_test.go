@@ -20,6 +20,8 @@ func TestNewHostModuleBuilder_Compile(t *testing.T) {
 		return 0
 	}
 
+	three := uint32(3)
+
 	gofunc1 := api.GoFunc(func(ctx context.Context, stack []uint64) {
 		stack[0] = 0
 	})
@@ -302,6 +304,81 @@ func TestNewHostModuleBuilder_Compile(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "ExportTable",
+			input: func(r Runtime) HostModuleBuilder {
+				return r.NewHostModuleBuilder("host").
+					NewFunctionBuilder().WithFunc(uint32_uint32).Export("1").
+					ExportTable("funcs", api.ValueTypeFuncref, 1).
+					ExportTable("externs", api.ValueTypeExternref, 2).
+					ExportTableWithMax("funcs", api.ValueTypeFuncref, 1, 3) // overwrites
+			},
+			expected: &wasm.Module{
+				TypeSection: []wasm.FunctionType{
+					{Params: []api.ValueType{i32}, Results: []api.ValueType{i32}},
+				},
+				FunctionSection: []wasm.Index{0},
+				CodeSection:     []wasm.Code{wasm.MustParseGoReflectFuncCode(uint32_uint32)},
+				TableSection: []wasm.Table{
+					{Min: 1, Max: &three, Type: wasm.RefTypeFuncref},
+					{Min: 2, Type: wasm.RefTypeExternref},
+				},
+				ExportSection: []wasm.Export{
+					{Name: "1", Type: wasm.ExternTypeFunc, Index: 0},
+					{Name: "funcs", Type: wasm.ExternTypeTable, Index: 0},
+					{Name: "externs", Type: wasm.ExternTypeTable, Index: 1},
+				},
+				Exports: map[string]*wasm.Export{
+					"1":       {Name: "1", Type: wasm.ExternTypeFunc, Index: 0},
+					"funcs":   {Name: "funcs", Type: wasm.ExternTypeTable, Index: 0},
+					"externs": {Name: "externs", Type: wasm.ExternTypeTable, Index: 1},
+				},
+				NameSection: &wasm.NameSection{
+					FunctionNames: wasm.NameMap{{Index: 0, Name: "1"}},
+					ModuleName:    "host",
+				},
+			},
+		},
+		{
+			name: "ExportMemory",
+			input: func(r Runtime) HostModuleBuilder {
+				return r.NewHostModuleBuilder("host").
+					ExportMemory("memory", 1).
+					ExportMemoryWithMax("memory", 1, 3) // overwrites
+			},
+			expected: &wasm.Module{
+				MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: 3, IsMaxEncoded: true}},
+				ExportSection: []wasm.Export{{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0}},
+				Exports: map[string]*wasm.Export{
+					"memory": {Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+				},
+				NameSection: &wasm.NameSection{ModuleName: "host"},
+			},
+		},
+		{
+			name: "ExportGlobal",
+			input: func(r Runtime) HostModuleBuilder {
+				return r.NewHostModuleBuilder("host").
+					ExportGlobal("flag", i32, api.EncodeI32(1)).
+					ExportMutableGlobal("sp", i64, 1024).
+					ExportGlobal("flag", i32, api.EncodeI32(-1)) // overwrites
+			},
+			expected: &wasm.Module{
+				GlobalSection: []wasm.Global{
+					{Type: wasm.GlobalType{ValType: i32}, Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0x7f}}},
+					{Type: wasm.GlobalType{ValType: i64, Mutable: true}, Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: []byte{0x80, 0x08}}},
+				},
+				ExportSection: []wasm.Export{
+					{Name: "flag", Type: wasm.ExternTypeGlobal, Index: 0},
+					{Name: "sp", Type: wasm.ExternTypeGlobal, Index: 1},
+				},
+				Exports: map[string]*wasm.Export{
+					"flag": {Name: "flag", Type: wasm.ExternTypeGlobal, Index: 0},
+					"sp":   {Name: "sp", Type: wasm.ExternTypeGlobal, Index: 1},
+				},
+				NameSection: &wasm.NameSection{ModuleName: "host"},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -333,6 +410,16 @@ func TestNewHostModuleBuilder_Compile(t *testing.T) {
 	}
 }
 
+func TestNewHostModuleBuilder_Compile_MultiMemory(t *testing.T) {
+	r := NewRuntimeWithConfig(testCtx, NewRuntimeConfig().WithCoreFeatures(api.CoreFeaturesV2|api.CoreFeatureMultiMemory))
+	defer r.Close(testCtx)
+
+	mod, err := r.NewHostModuleBuilder("host").ExportMemory("a", 1).ExportMemory("b", 2).Instantiate(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint32(wasm.MemoryPageSize), mod.ExportedMemory("a").Size())
+	require.Equal(t, uint32(2*wasm.MemoryPageSize), mod.ExportedMemory("b").Size())
+}
+
 // TestNewHostModuleBuilder_Compile_Errors only covers a few scenarios to avoid
 // duplicating tests in internal/wasm/host_test.go
 func TestNewHostModuleBuilder_Compile_Errors(t *testing.T) {
@@ -350,6 +437,36 @@ func TestNewHostModuleBuilder_Compile_Errors(t *testing.T) {
 			},
 			expectedErr: `func[host.fn] param[0] is unsupported: string`,
 		},
+		{
+			name: "table conflicts with function",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").
+					NewFunctionBuilder().WithFunc(func() {}).Export("fn").
+					ExportTable("fn", api.ValueTypeFuncref, 1)
+			},
+			expectedErr: `table[host.fn] conflicts with another export of the same name`,
+		},
+		{
+			name: "memory over limit",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").ExportMemoryWithMax("memory", 1, wasm.MemoryLimitPages+1)
+			},
+			expectedErr: `memory[host.memory] max 65537 pages (4 Gi) over limit of 65536 pages (4 Gi)`,
+		},
+		{
+			name: "memories without multi-memory",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").ExportMemory("a", 1).ExportMemory("b", 1)
+			},
+			expectedErr: `at most one memory allowed in module as feature "multi-memory" is disabled`,
+		},
+		{
+			name: "global of unsupported type",
+			input: func(rt Runtime) HostModuleBuilder {
+				return rt.NewHostModuleBuilder("host").ExportGlobal("g", api.ValueTypeExternref, 0)
+			},
+			expectedErr: `global[host.g] has unsupported type externref`,
+		},
 	}
 
 	for _, tt := range tests {
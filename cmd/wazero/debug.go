@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/AR1011/wazero/experimental/dap"
+	"github.com/AR1011/wazero/experimental/logging"
+)
+
+func doDebug(args []string, stdOut io.Writer, stdErr logging.Writer) int {
+	return runCommand("debug", args, stdOut, stdErr)
+}
+
+// newDebugger returns a debugger for the source read from the given path.
+func newDebugger(path string, source []byte) (dap.Debugger, error) {
+	bin, err := sourceBinary(path, source)
+	if err != nil {
+		return nil, err
+	}
+	return dap.NewDebugger(bin)
+}
+
+// acceptDebugger waits for a client of the debugger to connect on the address, and to set its breakpoints. The
+// client is served until the returned connection is closed.
+func acceptDebugger(addr string, debugger dap.Debugger, stdErr io.Writer) (net.Conn, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+
+	fmt.Fprintf(stdErr, "waiting for a debugger on %s\n", ln.Addr())
+	conn, err := ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := debugger.Serve(conn); err != nil {
+			fmt.Fprintf(stdErr, "error serving debugger: %v\n", err)
+		}
+	}()
+	debugger.WaitConfigured()
+	return conn, nil
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binary"
+)
+
+// moduleInfo is the structure of a module printed by the inspect command. Field names are part of the -json output.
+type moduleInfo struct {
+	Name           string              `json:"name,omitempty"`
+	Types          []string            `json:"types"`
+	Imports        []importInfo        `json:"imports"`
+	Exports        []exportInfo        `json:"exports"`
+	Memories       []memoryInfo        `json:"memories"`
+	Tables         []tableInfo         `json:"tables"`
+	Globals        []globalInfo        `json:"globals"`
+	FunctionNames  []functionNameInfo  `json:"functionNames"`
+	CustomSections []customSectionInfo `json:"customSections"`
+	// DWARF is true when the module has DWARF debug sections, such as ".debug_info".
+	DWARF bool `json:"dwarf"`
+}
+
+type importInfo struct {
+	Module string `json:"module"`
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	// Type is the signature of a function, or the type of a memory, table or global.
+	Type string `json:"type"`
+}
+
+type exportInfo struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Index uint32 `json:"index"`
+}
+
+type memoryInfo struct {
+	Index    uint32  `json:"index"`
+	Min      uint32  `json:"min"`
+	Max      *uint32 `json:"max,omitempty"`
+	Shared   bool    `json:"shared,omitempty"`
+	Memory64 bool    `json:"memory64,omitempty"`
+}
+
+type tableInfo struct {
+	Index uint32  `json:"index"`
+	Type  string  `json:"type"`
+	Min   uint32  `json:"min"`
+	Max   *uint32 `json:"max,omitempty"`
+}
+
+type globalInfo struct {
+	Index   uint32 `json:"index"`
+	Type    string `json:"type"`
+	Mutable bool   `json:"mutable,omitempty"`
+}
+
+type functionNameInfo struct {
+	Index uint32 `json:"index"`
+	Name  string `json:"name"`
+}
+
+type customSectionInfo struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+func doInspect(args []string, stdOut, stdErr io.Writer) int {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "Prints usage.")
+
+	var jsonOutput bool
+	flags.BoolVar(&jsonOutput, "json", false, "Prints the module structure as JSON.")
+
+	_ = flags.Parse(args)
+
+	if help {
+		printInspectUsage(stdErr, flags)
+		return 0
+	}
+
+	if flags.NArg() < 1 {
+		fmt.Fprintln(stdErr, "missing path to wasm file")
+		printInspectUsage(stdErr, flags)
+		return 1
+	}
+
+	wasmPath := flags.Arg(0)
+	bin, err := os.ReadFile(wasmPath)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error reading wasm binary: %v\n", err)
+		return 1
+	}
+
+	m, err := binary.DecodeModule(bin, wasm.CoreFeaturesAll, wasm.MemoryLimitPages, false, false, true)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error decoding wasm binary: %v\n", err)
+		return 1
+	}
+
+	info := inspectModule(m, bin)
+	if jsonOutput {
+		enc := json.NewEncoder(stdOut)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(info); err != nil {
+			fmt.Fprintf(stdErr, "error writing json: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	printModuleInfo(stdOut, info)
+	return 0
+}
+
+// inspectModule returns the structure of the module decoded from the binary. Slices are empty instead of nil, so that they are written as []
+// in JSON.
+func inspectModule(m *wasm.Module, bin []byte) *moduleInfo {
+	info := &moduleInfo{
+		Types:          []string{},
+		Imports:        []importInfo{},
+		Exports:        []exportInfo{},
+		Memories:       []memoryInfo{},
+		Tables:         []tableInfo{},
+		Globals:        []globalInfo{},
+		FunctionNames:  []functionNameInfo{},
+		CustomSections: []customSectionInfo{},
+	}
+
+	for i := range m.TypeSection {
+		info.Types = append(info.Types, signature(&m.TypeSection[i]))
+	}
+
+	var memoryIndex, tableIndex, globalIndex uint32
+	for i := range m.ImportSection {
+		imp := &m.ImportSection[i]
+		ii := importInfo{Module: imp.Module, Name: imp.Name, Kind: api.ExternTypeName(imp.Type)}
+		switch imp.Type {
+		case wasm.ExternTypeFunc:
+			ii.Type = typeAt(m, imp.DescFunc)
+		case wasm.ExternTypeMemory:
+			mem := inspectMemory(memoryIndex, imp.DescMem)
+			ii.Type = mem.String()
+			memoryIndex++
+		case wasm.ExternTypeTable:
+			table := inspectTable(tableIndex, &imp.DescTable)
+			ii.Type = table.String()
+			tableIndex++
+		case wasm.ExternTypeGlobal:
+			global := globalInfo{Index: globalIndex, Type: wasm.ValueTypeName(imp.DescGlobal.ValType), Mutable: imp.DescGlobal.Mutable}
+			ii.Type = global.String()
+			globalIndex++
+		case wasm.ExternTypeTag:
+			ii.Type = typeAt(m, imp.DescTag)
+		}
+		info.Imports = append(info.Imports, ii)
+	}
+
+	for i := range m.ExportSection {
+		exp := &m.ExportSection[i]
+		info.Exports = append(info.Exports, exportInfo{Name: exp.Name, Kind: api.ExternTypeName(exp.Type), Index: exp.Index})
+	}
+
+	for i := range m.MemorySection {
+		info.Memories = append(info.Memories, inspectMemory(memoryIndex, &m.MemorySection[i]))
+		memoryIndex++
+	}
+
+	for i := range m.TableSection {
+		info.Tables = append(info.Tables, inspectTable(tableIndex, &m.TableSection[i]))
+		tableIndex++
+	}
+
+	for i := range m.GlobalSection {
+		g := &m.GlobalSection[i]
+		info.Globals = append(info.Globals, globalInfo{Index: globalIndex, Type: wasm.ValueTypeName(g.Type.ValType), Mutable: g.Type.Mutable})
+		globalIndex++
+	}
+
+	if m.NameSection != nil {
+		info.Name = m.NameSection.ModuleName
+		for _, n := range m.NameSection.FunctionNames {
+			info.FunctionNames = append(info.FunctionNames, functionNameInfo{Index: n.Index, Name: n.Name})
+		}
+		// The decoder doesn't keep the "name" section in the custom sections, as it is decoded.
+		info.CustomSections = append(info.CustomSections, customSectionInfo{Name: "name", Size: nameSectionSize(bin)})
+	}
+
+	for _, c := range m.CustomSections {
+		info.CustomSections = append(info.CustomSections, customSectionInfo{Name: c.Name, Size: len(c.Data)})
+		if strings.HasPrefix(c.Name, ".debug_") {
+			info.DWARF = true
+		}
+	}
+	return info
+}
+
+// nameSectionSize returns the size of the data of the "name" custom section, excluding its name like the size of
+// other custom sections, or zero when the binary has none.
+func nameSectionSize(bin []byte) int {
+	bin = bin[8:] // Skip the magic number and version, which were already decoded.
+	for len(bin) > 0 {
+		id := bin[0]
+		size, n, err := leb128.LoadUint32(bin[1:])
+		end := 1 + n + uint64(size)
+		if err != nil || uint64(len(bin)) < end {
+			return 0
+		}
+		data := bin[1+n : end]
+		bin = bin[end:]
+		if id != wasm.SectionIDCustom {
+			continue
+		}
+		nameLen, n, err := leb128.LoadUint32(data)
+		if err != nil || uint64(len(data)) < n+uint64(nameLen) {
+			return 0
+		}
+		if string(data[n:n+uint64(nameLen)]) == "name" {
+			return len(data) - int(n) - int(nameLen)
+		}
+	}
+	return 0
+}
+
+func inspectMemory(index uint32, m *wasm.Memory) memoryInfo {
+	mem := memoryInfo{Index: index, Min: m.Min, Shared: m.IsShared, Memory64: m.IsMemory64}
+	if m.IsMaxEncoded {
+		max := m.Max
+		mem.Max = &max
+	}
+	return mem
+}
+
+func inspectTable(index uint32, t *wasm.Table) tableInfo {
+	return tableInfo{Index: index, Type: wasm.RefTypeName(t.Type), Min: t.Min, Max: t.Max}
+}
+
+// typeAt returns the signature of the type at the given index, or a placeholder when it is out of range, as the module
+// isn't validated.
+func typeAt(m *wasm.Module, index wasm.Index) string {
+	if int(index) >= len(m.TypeSection) {
+		return fmt.Sprintf("type[%d]", index)
+	}
+	return signature(&m.TypeSection[index])
+}
+
+// signature returns the function type in the text format, e.g. "(param i32 i32) (result i32)".
+func signature(t *wasm.FunctionType) string {
+	var parts []string
+	if len(t.Params) > 0 {
+		parts = append(parts, "(param "+valueTypeNames(t.Params)+")")
+	}
+	if len(t.Results) > 0 {
+		parts = append(parts, "(result "+valueTypeNames(t.Results)+")")
+	}
+	if len(parts) == 0 {
+		return "()"
+	}
+	return strings.Join(parts, " ")
+}
+
+func valueTypeNames(types []wasm.ValueType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = wasm.ValueTypeName(t)
+	}
+	return strings.Join(names, " ")
+}
+
+// String returns the limits of the memory in pages, e.g. "pages 1..16 shared".
+func (m memoryInfo) String() string {
+	s := fmt.Sprintf("pages %d..", m.Min)
+	if m.Max != nil {
+		s += fmt.Sprint(*m.Max)
+	}
+	if m.Shared {
+		s += " shared"
+	}
+	if m.Memory64 {
+		s += " i64"
+	}
+	return s
+}
+
+// String returns the type and limits of the table, e.g. "funcref 1..".
+func (t tableInfo) String() string {
+	s := fmt.Sprintf("%s %d..", t.Type, t.Min)
+	if t.Max != nil {
+		s += fmt.Sprint(*t.Max)
+	}
+	return s
+}
+
+// String returns the type of the global, e.g. "mut i32".
+func (g globalInfo) String() string {
+	if g.Mutable {
+		return "mut " + g.Type
+	}
+	return g.Type
+}
+
+// printModuleInfo prints the module structure in a human-readable format, omitting empty parts.
+func printModuleInfo(w io.Writer, info *moduleInfo) {
+	if info.Name != "" {
+		fmt.Fprintf(w, "module: %s\n", info.Name)
+	}
+	if len(info.Types) > 0 {
+		fmt.Fprintln(w, "types:")
+		for i, t := range info.Types {
+			fmt.Fprintf(w, "  [%d] %s\n", i, t)
+		}
+	}
+	if len(info.Imports) > 0 {
+		fmt.Fprintln(w, "imports:")
+		for _, imp := range info.Imports {
+			fmt.Fprintf(w, "  %s.%s: %s %s\n", imp.Module, imp.Name, imp.Kind, imp.Type)
+		}
+	}
+	if len(info.Exports) > 0 {
+		fmt.Fprintln(w, "exports:")
+		for _, exp := range info.Exports {
+			fmt.Fprintf(w, "  %s: %s[%d]\n", exp.Name, exp.Kind, exp.Index)
+		}
+	}
+	if len(info.Memories) > 0 {
+		fmt.Fprintln(w, "memories:")
+		for _, mem := range info.Memories {
+			fmt.Fprintf(w, "  [%d] %s\n", mem.Index, mem)
+		}
+	}
+	if len(info.Tables) > 0 {
+		fmt.Fprintln(w, "tables:")
+		for _, t := range info.Tables {
+			fmt.Fprintf(w, "  [%d] %s\n", t.Index, t)
+		}
+	}
+	if len(info.Globals) > 0 {
+		fmt.Fprintln(w, "globals:")
+		for _, g := range info.Globals {
+			fmt.Fprintf(w, "  [%d] %s\n", g.Index, g)
+		}
+	}
+	if len(info.FunctionNames) > 0 {
+		fmt.Fprintln(w, "function names:")
+		for _, n := range info.FunctionNames {
+			fmt.Fprintf(w, "  [%d] %s\n", n.Index, n.Name)
+		}
+	}
+	if len(info.CustomSections) > 0 {
+		fmt.Fprintln(w, "custom sections:")
+		for _, c := range info.CustomSections {
+			fmt.Fprintf(w, "  %s: %d bytes\n", c.Name, c.Size)
+		}
+	}
+	fmt.Fprintf(w, "dwarf: %t\n", info.DWARF)
+}
+
+func printInspectUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero inspect <options> <path to wasm file>")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
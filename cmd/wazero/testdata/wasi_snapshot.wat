@@ -0,0 +1,41 @@
+;; $wasi_snapshot is a WASI command which reads environ on pre-initialization, and copies it to stdout on start.
+(module $wasi_snapshot
+    (import "wasi_snapshot_preview1" "environ_get"
+        (func $wasi.environ_get (param $environ i32) (param $environ_buf i32) (result (;errno;) i32)))
+
+    (import "wasi_snapshot_preview1" "environ_sizes_get"
+        (func $wasi.environ_sizes_get (param $result.environc i32) (param $result.environ_buf_size i32) (result (;errno;) i32)))
+
+    (import "wasi_snapshot_preview1" "fd_write"
+        (func $wasi.fd_write (param $fd i32) (param $iovs i32) (param $iovs_len i32) (param $result.size i32) (result (;errno;) i32)))
+
+    (memory (export "memory") 1)
+
+    ;; $iovs are offset/length pairs in memory fd_write copies to the file descriptor.
+    (global $iovs i32 i32.const 1024) ;; 1024 is an arbitrary offset larger than the environ.
+
+    ;; WASI parameters are usually memory offsets, you can ignore values by writing them to an unread offset.
+    (global $ignored i32 i32.const 32768)
+
+    ;; $init loads null-terminated environ to memory offset zero, and its size as the length of $iovs.
+    (func $init (export "wizer.initialize")
+        (call $wasi.environ_sizes_get
+            (global.get $ignored)
+            (i32.add (global.get $iovs) (i32.const 4)))
+        drop
+        (call $wasi.environ_get
+            (global.get $ignored)
+            (i32.const 0))
+        drop
+    )
+
+    ;; $main writes the environ loaded by $init to stdout.
+    (func $main (export "_start")
+        (call $wasi.fd_write
+            (i32.const 1)
+            (global.get $iovs)
+            (i32.const 1)
+            (global.get $ignored))
+        drop
+    )
+)
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binary"
+)
+
+func doValidate(args []string, stdErr io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "Prints usage.")
+
+	var featureList string
+	flags.StringVar(&featureList, "features", "v2",
+		"Comma-separated list of the WebAssembly features the module may use. "+
+			"This is v1, v2 or the name of a proposal, e.g. v2,threads,tail-call.")
+
+	_ = flags.Parse(args)
+
+	if help {
+		printValidateUsage(stdErr, flags)
+		return 0
+	}
+
+	if flags.NArg() < 1 {
+		fmt.Fprintln(stdErr, "missing path to wasm file")
+		printValidateUsage(stdErr, flags)
+		return 1
+	}
+
+	features, err := parseFeatures(featureList)
+	if err != nil {
+		fmt.Fprintf(stdErr, "invalid features: %v\n", err)
+		return 1
+	}
+
+	wasmPath := flags.Arg(0)
+	bin, err := os.ReadFile(wasmPath)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error reading wasm binary: %v\n", err)
+		return 1
+	}
+
+	if err = validateModule(bin, features); err != nil {
+		fmt.Fprintf(stdErr, "invalid wasm binary: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// parseFeatures returns the features named in the comma-separated list, where "v1" and "v2" are the versions of the
+// core specification, and the others are the names of proposals, as returned by api.CoreFeatures String.
+func parseFeatures(list string) (api.CoreFeatures, error) {
+	var features api.CoreFeatures
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+		case "v1":
+			features |= api.CoreFeaturesV1
+		case "v2":
+			features |= api.CoreFeaturesV2
+		default:
+			f, ok := featureByName(name)
+			if !ok {
+				return 0, fmt.Errorf("unknown feature %q", name)
+			}
+			features |= f
+		}
+	}
+	return features, nil
+}
+
+func featureByName(name string) (api.CoreFeatures, bool) {
+	for i := 0; i < 64; i++ {
+		if f := api.CoreFeatures(1 << i); f.String() == name {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// validateModule decodes and validates the module with the given features, without compiling or instantiating it.
+// When a function is invalid, the error is prefixed with the location of the offending instruction.
+func validateModule(bin []byte, features api.CoreFeatures) error {
+	m, err := binary.DecodeModule(bin, features, wasm.MemoryLimitPages, false, false, false)
+	if err != nil {
+		return err
+	}
+
+	err = m.Validate(features)
+	var fe *wasm.FunctionError
+	if err == nil || !errors.As(err, &fe) {
+		return err
+	}
+
+	funcIdx := m.ImportFunctionCount + fe.Index
+	location := fmt.Sprintf("section code, function %d", funcIdx)
+	if m.NameSection != nil {
+		for _, n := range m.NameSection.FunctionNames {
+			if n.Index == funcIdx {
+				location += fmt.Sprintf(" (%s)", n.Name)
+				break
+			}
+		}
+	}
+	if start, ok := codeSectionStart(bin); ok {
+		location += fmt.Sprintf(", offset %#x", start+fe.Offset)
+	}
+	return fmt.Errorf("%s: %w", location, err)
+}
+
+// codeSectionStart returns the offset of the contents of the code section in the binary, which is where offsets in
+// wasm.FunctionError are relative to. The binary must have already been decoded.
+func codeSectionStart(bin []byte) (uint64, bool) {
+	pos := uint64(8) // magic and version
+	for pos < uint64(len(bin)) {
+		sectionID := bin[pos]
+		size, read, err := leb128.LoadUint32(bin[pos+1:])
+		if err != nil {
+			return 0, false
+		}
+		pos += 1 + read
+		if sectionID == wasm.SectionIDCode {
+			return pos, true
+		}
+		pos += uint64(size)
+	}
+	return 0, false
+}
+
+func printValidateUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero validate <options> <path to wasm file>")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
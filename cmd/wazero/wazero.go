@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"errors"
@@ -18,15 +19,21 @@ import (
 	"github.com/AR1011/wazero"
 	"github.com/AR1011/wazero/api"
 	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/experimental/coverage"
+	"github.com/AR1011/wazero/experimental/dap"
 	"github.com/AR1011/wazero/experimental/gojs"
 	"github.com/AR1011/wazero/experimental/logging"
 	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/experimental/profiling"
+	"github.com/AR1011/wazero/experimental/snapshot"
 	"github.com/AR1011/wazero/experimental/sock"
 	"github.com/AR1011/wazero/experimental/sysfs"
 	"github.com/AR1011/wazero/imports/wasi_snapshot_preview1"
 	"github.com/AR1011/wazero/internal/platform"
 	internalsys "github.com/AR1011/wazero/internal/sys"
 	"github.com/AR1011/wazero/internal/version"
+	internalwasm "github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wat"
 	"github.com/AR1011/wazero/sys"
 )
 
@@ -58,8 +65,16 @@ func doMain(stdOut io.Writer, stdErr logging.Writer) int {
 	switch subCmd {
 	case "compile":
 		return doCompile(flag.Args()[1:], stdErr)
+	case "debug":
+		return doDebug(flag.Args()[1:], stdOut, stdErr)
+	case "inspect":
+		return doInspect(flag.Args()[1:], stdOut, stdErr)
 	case "run":
 		return doRun(flag.Args()[1:], stdOut, stdErr)
+	case "snapshot":
+		return doSnapshot(flag.Args()[1:], stdOut, stdErr)
+	case "validate":
+		return doValidate(flag.Args()[1:], stdErr)
 	case "version":
 		fmt.Fprintln(stdOut, version.GetWazeroVersion())
 		return 0
@@ -93,6 +108,12 @@ func doCompile(args []string, stdErr io.Writer) int {
 			"Enables memory profiling and writes the profile at the given path.")
 	}
 
+	var outPath string
+	flags.StringVar(&outPath, "o", "",
+		"Writes the compiled module to the given path, e.g. app.cwasm, which can be passed to the run command "+
+			"instead of the wasm file. The output can only be run by the same version of wazero on the same platform, "+
+			"without run flags which change how the module is compiled, e.g. -timeout.")
+
 	cacheDir := cacheDirFlag(flags)
 
 	_ = flags.Parse(args)
@@ -125,7 +146,7 @@ func doCompile(args []string, stdErr io.Writer) int {
 		return 1
 	}
 
-	c := wazero.NewRuntimeConfig()
+	c := wazero.NewRuntimeConfig().WithSerialization(outPath != "")
 	if rc, cache := maybeUseCacheDir(cacheDir, stdErr); rc != 0 {
 		return rc
 	} else if cache != nil {
@@ -137,11 +158,17 @@ func doCompile(args []string, stdErr io.Writer) int {
 	defer rt.Close(ctx)
 
 	for count > 0 {
-		compiledModule, err := rt.CompileModule(ctx, wasm)
+		compiledModule, err := compileModule(ctx, rt, wasmPath, wasm)
 		if err != nil {
 			fmt.Fprintf(stdErr, "error compiling wasm binary: %v\n", err)
 			return 1
 		}
+		if outPath != "" && count == 1 {
+			if err := writeCompiledModule(outPath, compiledModule); err != nil {
+				fmt.Fprintf(stdErr, "error writing compiled module: %v\n", err)
+				return 1
+			}
+		}
 		if err := compiledModule.Close(ctx); err != nil {
 			fmt.Fprintf(stdErr, "error releasing compiled module: %v\n", err)
 			return 1
@@ -151,8 +178,30 @@ func doCompile(args []string, stdErr io.Writer) int {
 	return 0
 }
 
+// writeCompiledModule writes the compiled module to the path, removing the partially written file on failure.
+func writeCompiledModule(path string, compiled wazero.CompiledModule) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = compiled.Serialize(f); err == nil {
+		err = f.Close()
+	} else {
+		_ = f.Close()
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
+
 func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
-	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	return runCommand("run", args, stdOut, stdErr)
+}
+
+// runCommand implements the run command, and the debug command which runs the wasm binary in a debugger.
+func runCommand(cmd string, args []string, stdOut io.Writer, stdErr logging.Writer) (exitCode int) {
+	flags := flag.NewFlagSet(cmd, flag.ExitOnError)
 	flags.SetOutput(stdErr)
 
 	var help bool
@@ -201,6 +250,22 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 		"A comma-separated list of host function scopes to log to stderr. "+
 			"This may be specified multiple times. Supported values: all,clock,filesystem,memory,proc,poll,random,sock")
 
+	var coverProfile string
+	flags.StringVar(&coverProfile, "coverprofile", "",
+		"Writes the coverage of the source code of the wasm binary at the given path, which requires DWARF sections. "+
+			"The format is LCOV when the path ends with .info or .lcov, and Go coverprofile otherwise. "+
+			"This implies -interpreter.")
+
+	var guestCPUProfile string
+	flags.StringVar(&guestCPUProfile, "guest-cpuprofile", "",
+		"Samples the call stack of the wasm binary and writes the profile at the given path, in the format of "+
+			"go tool pprof. Functions are named with the name and DWARF sections of the binary.")
+
+	var coreDump string
+	flags.StringVar(&coreDump, "coredump", "",
+		"Writes a WebAssembly coredump at the given path when the wasm binary traps, for analysis with tools "+
+			"such as wasmgdb. Locals of the frames are only recorded with -interpreter.")
+
 	var cpuProfile string
 	var memProfile string
 	if version.GetWazeroVersion() == version.Default {
@@ -211,6 +276,13 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 			"Enables memory profiling and writes the profile at the given path.")
 	}
 
+	var dapAddr string
+	if cmd == "debug" {
+		flags.StringVar(&dapAddr, "dap", "127.0.0.1:4711",
+			"Address to accept a client of the Debug Adapter Protocol on, of the form <host:port>. "+
+				"The wasm binary runs once the client is done setting breakpoints.")
+	}
+
 	cacheDir := cacheDirFlag(flags)
 
 	_ = flags.Parse(args)
@@ -244,18 +316,12 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 		}
 	}
 
-	// Don't use map to preserve order
-	var env []string
 	if envInherit {
 		envs = append(os.Environ(), envs...)
 	}
-	for _, e := range envs {
-		fields := strings.SplitN(e, "=", 2)
-		if len(fields) != 2 {
-			fmt.Fprintf(stdErr, "invalid environment variable: %s\n", e)
-			return 1
-		}
-		env = append(env, fields[0], fields[1])
+	rc, env := validateEnvs(envs, stdErr)
+	if rc != 0 {
+		return rc
 	}
 
 	rc, rootPath, fsConfig := validateMounts(mounts, stdErr)
@@ -271,6 +337,65 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 
 	wasmExe := filepath.Base(wasmPath)
 
+	// The compile command uses the default configuration, so fail with the reason a compiled module can't be run,
+	// rather than the ID mismatch when deserializing it.
+	if bytes.HasPrefix(wasm, internalwasm.SerializedModuleMagic) {
+		var conflict string
+		switch {
+		case cmd == "debug":
+			conflict = "the debug command"
+		case useInterpreter:
+			conflict = "-interpreter"
+		case useOptimizingCompiler:
+			conflict = "-optimizing-compiler"
+		case coverProfile != "":
+			conflict = "-coverprofile"
+		case guestCPUProfile != "":
+			conflict = "-guest-cpuprofile"
+		case hostlogging != 0:
+			conflict = "-hostlogging"
+		case timeout != 0:
+			conflict = "-timeout"
+		case coreDump != "":
+			conflict = "-coredump"
+		}
+		if conflict != "" {
+			fmt.Fprintf(stdErr, "error loading compiled module: %s changes how the module is compiled, "+
+				"so use the wasm binary instead\n", conflict)
+			return 1
+		}
+	}
+
+	var collector coverage.Collector
+	if coverProfile != "" {
+		if collector, err = newCoverageCollector(wasmPath, wasm); err != nil {
+			fmt.Fprintf(stdErr, "error collecting coverage: %v\n", err)
+			return 1
+		}
+		// Only the interpreter notifies the blocks which ran.
+		useInterpreter = true
+		defer writeCoverProfile(stdErr, coverProfile, collector)
+	}
+
+	var profiler profiling.CPUProfiler
+	if guestCPUProfile != "" {
+		if profiler, err = newGuestCPUProfiler(wasmPath, wasm); err != nil {
+			fmt.Fprintf(stdErr, "error profiling guest: %v\n", err)
+			return 1
+		}
+		defer writeGuestCPUProfile(stdErr, guestCPUProfile, profiler)
+	}
+
+	var debugger dap.Debugger
+	if cmd == "debug" {
+		if debugger, err = newDebugger(wasmPath, wasm); err != nil {
+			fmt.Fprintf(stdErr, "error debugging guest: %v\n", err)
+			return 1
+		}
+		// Only the interpreter stops at instructions.
+		useInterpreter = true
+	}
+
 	var rtc wazero.RuntimeConfig
 	if useInterpreter {
 		rtc = wazero.NewRuntimeConfigInterpreter()
@@ -279,8 +404,20 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 	} else {
 		rtc = wazero.NewRuntimeConfig()
 	}
+	if coreDump != "" {
+		rtc = rtc.WithCoreDump(true)
+	}
 
 	ctx := maybeHostLogging(context.Background(), logging.LogScopes(hostlogging), stdErr)
+	if collector != nil {
+		ctx = withFunctionListenerFactory(ctx, collector)
+	}
+	if profiler != nil {
+		ctx = withFunctionListenerFactory(ctx, profiler)
+	}
+	if debugger != nil {
+		ctx = withFunctionListenerFactory(ctx, debugger)
+	}
 
 	if rc, cache := maybeUseCacheDir(cacheDir, stdErr); rc != 0 {
 		return rc
@@ -324,10 +461,31 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 		conf = conf.WithEnv(env[i], env[i+1])
 	}
 
-	guest, err := rt.CompileModule(ctx, wasm)
-	if err != nil {
-		fmt.Fprintf(stdErr, "error compiling wasm binary: %v\n", err)
-		return 1
+	var guest wazero.CompiledModule
+	if bytes.HasPrefix(wasm, internalwasm.SerializedModuleMagic) {
+		guest, err = rt.DeserializeModule(ctx, bytes.NewReader(wasm))
+		if err != nil {
+			fmt.Fprintf(stdErr, "error loading compiled module: %v\n", err)
+			return 1
+		}
+	} else {
+		guest, err = compileModule(ctx, rt, wasmPath, wasm)
+		if err != nil {
+			fmt.Fprintf(stdErr, "error compiling wasm binary: %v\n", err)
+			return 1
+		}
+	}
+
+	if debugger != nil {
+		conn, err := acceptDebugger(dapAddr, debugger, stdErr)
+		if err != nil {
+			fmt.Fprintf(stdErr, "error accepting debugger: %v\n", err)
+			return 1
+		}
+		defer func() {
+			debugger.Exited(uint32(exitCode))
+			_ = conn.Close()
+		}()
 	}
 
 	switch detectImports(guest.ImportedFunctions()) {
@@ -381,6 +539,9 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 			return int(exitCode)
 		}
 		fmt.Fprintf(stdErr, "error instantiating wasm binary: %v\n", err)
+		if dump := experimental.CoreDump(err); dump != nil {
+			writeCoreDump(stdErr, coreDump, dump)
+		}
 		return 1
 	}
 
@@ -388,6 +549,154 @@ func doRun(args []string, stdOut io.Writer, stdErr logging.Writer) int {
 	return 0
 }
 
+func doSnapshot(args []string, stdOut io.Writer, stdErr logging.Writer) int {
+	flags := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	flags.SetOutput(stdErr)
+
+	var help bool
+	flags.BoolVar(&help, "h", false, "Prints usage.")
+
+	var initFunction string
+	flags.StringVar(&initFunction, "init", snapshot.DefaultInitFunction,
+		"Name of the function exported by the binary to call for pre-initialization.")
+
+	var outPath string
+	flags.StringVar(&outPath, "o", "",
+		"Writes the pre-initialized wasm binary to the given path. This flag is required.")
+
+	var envs sliceFlag
+	flags.Var(&envs, "env", "key=value pair of environment variable to expose to the binary during "+
+		"pre-initialization. Can be specified multiple times.")
+
+	var mounts sliceFlag
+	flags.Var(&mounts, "mount",
+		"Filesystem path to expose to the binary during pre-initialization in the form of <path>[:<wasm path>][:ro]. "+
+			"This may be specified multiple times. When <wasm path> is unset, <path> is used.")
+
+	_ = flags.Parse(args)
+
+	if help {
+		printSnapshotUsage(stdErr, flags)
+		return 0
+	}
+
+	if flags.NArg() < 1 {
+		fmt.Fprintln(stdErr, "missing path to wasm file")
+		printSnapshotUsage(stdErr, flags)
+		return 1
+	}
+
+	if outPath == "" {
+		fmt.Fprintln(stdErr, "missing output path")
+		printSnapshotUsage(stdErr, flags)
+		return 1
+	}
+
+	wasmPath := flags.Arg(0)
+	wasmArgs := flags.Args()[1:]
+	if len(wasmArgs) > 0 && wasmArgs[0] == "--" {
+		wasmArgs = wasmArgs[1:]
+	}
+
+	rc, env := validateEnvs(envs, stdErr)
+	if rc != 0 {
+		return rc
+	}
+
+	rc, _, fsConfig := validateMounts(mounts, stdErr)
+	if rc != 0 {
+		return rc
+	}
+
+	wasm, err := os.ReadFile(wasmPath)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error reading wasm binary: %v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	rt := wazero.NewRuntime(ctx)
+	defer rt.Close(ctx)
+
+	guest, err := rt.CompileModule(ctx, wasm)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error compiling wasm binary: %v\n", err)
+		return 1
+	}
+
+	switch detectImports(guest.ImportedFunctions()) {
+	case modeWasi:
+		wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+	case modeWasiUnstable:
+		wasiBuilder := rt.NewHostModuleBuilder("wasi_unstable")
+		wasi_snapshot_preview1.NewFunctionExporter().ExportFunctions(wasiBuilder)
+		_, err = wasiBuilder.Instantiate(ctx)
+	case modeGo:
+		err = errors.New("GOOS=js is not supported")
+	}
+	if err != nil {
+		fmt.Fprintf(stdErr, "error instantiating wasm binary: %v\n", err)
+		return 1
+	}
+
+	// The start functions, e.g. "_start", are not called, as they would run the program instead of initializing it.
+	conf := wazero.NewModuleConfig().
+		WithStartFunctions().
+		WithStdout(stdOut).
+		WithStderr(stdErr).
+		WithRandSource(rand.Reader).
+		WithFSConfig(fsConfig).
+		WithSysNanosleep().
+		WithSysNanotime().
+		WithSysWalltime().
+		WithArgs(append([]string{filepath.Base(wasmPath)}, wasmArgs...)...)
+	for i := 0; i < len(env); i += 2 {
+		conf = conf.WithEnv(env[i], env[i+1])
+	}
+
+	mod, err := rt.InstantiateModule(ctx, guest, conf)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error instantiating wasm binary: %v\n", err)
+		return 1
+	}
+
+	snapshotted, err := snapshot.Snapshot(ctx, mod, wasm, initFunction)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error pre-initializing wasm binary: %v\n", err)
+		return 1
+	}
+
+	if err = os.WriteFile(outPath, snapshotted, 0o644); err != nil {
+		fmt.Fprintf(stdErr, "error writing wasm binary: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// validateEnvs returns the environment variables as a flattened list of key, value pairs.
+func validateEnvs(envs sliceFlag, stdErr logging.Writer) (rc int, env []string) {
+	// Don't use map to preserve order
+	for _, e := range envs {
+		fields := strings.SplitN(e, "=", 2)
+		if len(fields) != 2 {
+			fmt.Fprintf(stdErr, "invalid environment variable: %s\n", e)
+			return 1, nil
+		}
+		env = append(env, fields[0], fields[1])
+	}
+	return 0, env
+}
+
+// compileModule compiles the source read from the given path, which is in the text format when the path ends with
+// %.wat or %.wast.
+func compileModule(ctx context.Context, rt wazero.Runtime, path string, source []byte) (wazero.CompiledModule, error) {
+	switch filepath.Ext(path) {
+	case ".wat", ".wast":
+		return rt.CompileModuleText(ctx, source)
+	}
+	return rt.CompileModule(ctx, source)
+}
+
 func validateMounts(mounts sliceFlag, stdErr logging.Writer) (rc int, rootPath string, config wazero.FSConfig) {
 	config = wazero.NewFSConfig()
 	for _, mount := range mounts {
@@ -492,6 +801,82 @@ func maybeHostLogging(ctx context.Context, scopes logging.LogScopes, stdErr logg
 	return ctx
 }
 
+// withFunctionListenerFactory adds the factory to the context, along with any factory already in it.
+func withFunctionListenerFactory(ctx context.Context, factory experimental.FunctionListenerFactory) context.Context {
+	if existing, ok := ctx.Value(experimental.FunctionListenerFactoryKey{}).(experimental.FunctionListenerFactory); ok {
+		factory = experimental.MultiFunctionListenerFactory(existing, factory)
+	}
+	return context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, factory)
+}
+
+// sourceBinary returns the WebAssembly binary of the source read from the given path, which is compiled first when
+// in the text format, like compileModule does.
+func sourceBinary(path string, source []byte) ([]byte, error) {
+	switch filepath.Ext(path) {
+	case ".wat", ".wast":
+		return wat.Compile(source)
+	}
+	return source, nil
+}
+
+// newCoverageCollector returns a collector for the source read from the given path.
+func newCoverageCollector(path string, source []byte) (coverage.Collector, error) {
+	bin, err := sourceBinary(path, source)
+	if err != nil {
+		return nil, err
+	}
+	return coverage.NewCollector(bin)
+}
+
+// writeCoverProfile writes the coverage in the LCOV format when the path ends with .info or .lcov, and in the Go
+// coverprofile format otherwise.
+func writeCoverProfile(stdErr io.Writer, path string, collector coverage.Collector) {
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error creating coverage profile output: %v\n", err)
+		return
+	}
+	defer f.Close()
+	switch filepath.Ext(path) {
+	case ".info", ".lcov":
+		err = collector.WriteLCOV(f)
+	default:
+		err = collector.WriteCoverprofile(f)
+	}
+	if err != nil {
+		fmt.Fprintf(stdErr, "error writing coverage profile: %v\n", err)
+	}
+}
+
+// newGuestCPUProfiler returns a profiler sampling the source read from the given path.
+func newGuestCPUProfiler(path string, source []byte) (profiling.CPUProfiler, error) {
+	bin, err := sourceBinary(path, source)
+	if err != nil {
+		return nil, err
+	}
+	return profiling.NewCPUProfiler(bin, profiling.DefaultCPUProfileRate)
+}
+
+// writeGuestCPUProfile stops the profiler and writes its samples.
+func writeGuestCPUProfile(stdErr io.Writer, path string, profiler profiling.CPUProfiler) {
+	profiler.Stop()
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(stdErr, "error creating guest cpu profile output: %v\n", err)
+		return
+	}
+	defer f.Close()
+	if err = profiler.WriteProfile(f); err != nil {
+		fmt.Fprintf(stdErr, "error writing guest cpu profile: %v\n", err)
+	}
+}
+
+func writeCoreDump(stdErr io.Writer, path string, dump []byte) {
+	if err := os.WriteFile(path, dump, 0o600); err != nil {
+		fmt.Fprintf(stdErr, "error writing coredump: %v\n", err)
+	}
+}
+
 func cacheDirFlag(flags *flag.FlagSet) *string {
 	return flags.String("cachedir", "", "Writeable directory for native code compiled from wasm. "+
 		"Contents are re-used for the same version of wazero.")
@@ -516,14 +901,18 @@ func printUsage(stdErr io.Writer) {
 	fmt.Fprintln(stdErr)
 	fmt.Fprintln(stdErr, "Commands:")
 	fmt.Fprintln(stdErr, "  compile\tPre-compiles a WebAssembly binary")
+	fmt.Fprintln(stdErr, "  debug\t\tRuns a WebAssembly binary in a debugger")
+	fmt.Fprintln(stdErr, "  inspect\tPrints the structure of a WebAssembly binary")
 	fmt.Fprintln(stdErr, "  run\t\tRuns a WebAssembly binary")
+	fmt.Fprintln(stdErr, "  snapshot\tPre-initializes a WebAssembly binary")
+	fmt.Fprintln(stdErr, "  validate\tChecks a WebAssembly binary only uses the given features")
 	fmt.Fprintln(stdErr, "  version\tDisplays the version of wazero CLI")
 }
 
 func printCompileUsage(stdErr io.Writer, flags *flag.FlagSet) {
 	fmt.Fprintln(stdErr, "wazero CLI")
 	fmt.Fprintln(stdErr)
-	fmt.Fprintln(stdErr, "Usage:\n  wazero compile <options> <path to wasm file>")
+	fmt.Fprintln(stdErr, "Usage:\n  wazero compile <options> <path to wasm or wat file>")
 	fmt.Fprintln(stdErr)
 	fmt.Fprintln(stdErr, "Options:")
 	flags.PrintDefaults()
@@ -532,7 +921,16 @@ func printCompileUsage(stdErr io.Writer, flags *flag.FlagSet) {
 func printRunUsage(stdErr io.Writer, flags *flag.FlagSet) {
 	fmt.Fprintln(stdErr, "wazero CLI")
 	fmt.Fprintln(stdErr)
-	fmt.Fprintln(stdErr, "Usage:\n  wazero run <options> <path to wasm file> [--] <wasm args>")
+	fmt.Fprintf(stdErr, "Usage:\n  wazero %s <options> <path to wasm, wat or cwasm file> [--] <wasm args>\n", flags.Name())
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Options:")
+	flags.PrintDefaults()
+}
+
+func printSnapshotUsage(stdErr io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(stdErr, "wazero CLI")
+	fmt.Fprintln(stdErr)
+	fmt.Fprintln(stdErr, "Usage:\n  wazero snapshot <options> <path to wasm file> [--] <wasm args>")
 	fmt.Fprintln(stdErr)
 	fmt.Fprintln(stdErr, "Options:")
 	flags.PrintDefaults()
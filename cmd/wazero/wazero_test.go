@@ -1,26 +1,37 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	_ "embed"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/textproto"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/AR1011/wazero/api"
 	"github.com/AR1011/wazero/experimental/logging"
 	"github.com/AR1011/wazero/imports/wasi_snapshot_preview1"
 	"github.com/AR1011/wazero/internal/internalapi"
 	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/dwarftestdata"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/version"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 	"github.com/AR1011/wazero/sys"
 )
 
@@ -30,12 +41,18 @@ var wasmInfiniteLoop []byte
 //go:embed testdata/wasi_arg.wasm
 var wasmWasiArg []byte
 
+//go:embed testdata/wasi_arg.wat
+var watWasiArg []byte
+
 //go:embed testdata/wasi_env.wasm
 var wasmWasiEnv []byte
 
 //go:embed testdata/wasi_fd.wasm
 var wasmWasiFd []byte
 
+//go:embed testdata/wasi_snapshot.wasm
+var wasmWasiSnapshot []byte
+
 //go:embed testdata/wasi_random_get.wasm
 var wasmWasiRandomGet []byte
 
@@ -154,6 +171,47 @@ func TestCompile(t *testing.T) {
 	}
 }
 
+func TestCompile_output(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+
+	tmpDir, oldwd := requireChdirToTemp(t)
+	defer os.Chdir(oldwd) //nolint
+
+	wasmPath := filepath.Join(tmpDir, "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o600))
+
+	exitCode, stdout, stderr := runMain(t, "", []string{"compile", "-o=test.cwasm", wasmPath})
+	require.Equal(t, 0, exitCode, stderr)
+	require.Zero(t, stdout)
+
+	cwasm, err := os.ReadFile("test.cwasm")
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(cwasm, wasm.SerializedModuleMagic))
+
+	// The compiled module can be run instead of the wasm binary.
+	exitCode, stdout, stderr = runMain(t, "", []string{"run", "test.cwasm", "hello world"})
+	require.Equal(t, 0, exitCode, stderr)
+	require.Equal(t, "test.cwasm\x00hello world\x00", stdout)
+
+	// But not with flags which change how the module is compiled.
+	for _, args := range [][]string{
+		{"run", "-interpreter"},
+		{"run", "-hostlogging=all"},
+		{"run", "-timeout=10s"},
+		{"run", "-coverprofile=test.lcov"},
+		{"run", "-guest-cpuprofile=test.pprof"},
+		{"run", "-coredump=test.core"},
+	} {
+		flag := strings.SplitN(args[1], "=", 2)[0]
+		exitCode, _, stderr = runMain(t, "", append(args, "test.cwasm"))
+		require.Equal(t, 1, exitCode)
+		require.Equal(t, "error loading compiled module: "+flag+
+			" changes how the module is compiled, so use the wasm binary instead\n", stderr)
+	}
+}
+
 func requireChdirToTemp(t *testing.T) (string, string) {
 	tmpDir := t.TempDir()
 	oldwd, err := os.Getwd()
@@ -191,6 +249,10 @@ func TestCompile_Errors(t *testing.T) {
 			message: "invalid cachedir",
 			args:    []string{"--cachedir", notWasmPath, wasmPath},
 		},
+		{
+			message: "error writing compiled module",
+			args:    []string{"-o", filepath.Join(tmpDir, "non-existent", "test.cwasm"), wasmPath},
+		},
 	}
 
 	for _, tc := range tests {
@@ -572,6 +634,469 @@ Consider switching to GOOS=wasip1.
 	}
 }
 
+func TestRun_text(t *testing.T) {
+	tmpDir, oldwd := requireChdirToTemp(t)
+	defer os.Chdir(oldwd) //nolint
+
+	watPath := filepath.Join(tmpDir, "test.wat")
+	require.NoError(t, os.WriteFile(watPath, watWasiArg, 0o600))
+
+	exitCode, stdout, stderr := runMain(t, "", []string{"run", watPath, "hello world"})
+	require.Equal(t, 0, exitCode, stderr)
+	require.Equal(t, "test.wat\x00hello world\x00", stdout)
+
+	// Syntax errors include the position in the source.
+	require.NoError(t, os.WriteFile(watPath, []byte("(module\n  (func i32.frob))"), 0o600))
+	exitCode, _, stderr = runMain(t, "", []string{"run", watPath})
+	require.Equal(t, 1, exitCode)
+	require.Equal(t, "error compiling wasm binary: 2:9: unknown instruction i32.frob\n", stderr)
+}
+
+func TestRun_coverprofile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	wasmPath := filepath.Join(tmpDir, "main.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, dwarftestdata.ZigWasm, 0o600))
+
+	// The program panics, but the coverage up to the panic is written.
+	lcovPath := filepath.Join(tmpDir, "coverage.lcov")
+	exitCode, _, stderr := runMain(t, "", []string{"run", "-coverprofile", lcovPath, wasmPath})
+	require.Equal(t, 1, exitCode, stderr)
+	lcov, err := os.ReadFile(lcovPath)
+	require.NoError(t, err)
+	require.Contains(t, string(lcov), "FN:10,main.main\nFNDA:1,main.main\n")
+
+	profilePath := filepath.Join(tmpDir, "coverage.out")
+	exitCode, _, stderr = runMain(t, "", []string{"run", "-coverprofile", profilePath, wasmPath})
+	require.Equal(t, 1, exitCode, stderr)
+	profile, err := os.ReadFile(profilePath)
+	require.NoError(t, err)
+	require.Contains(t, string(profile), "zig/main.zig:10.1,11.1 1 1\n")
+}
+
+func TestRun_guestCPUProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	wasmPath := filepath.Join(tmpDir, "main.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, dwarftestdata.ZigWasm, 0o600))
+
+	// The program panics, but the samples taken up to the panic are written.
+	profilePath := filepath.Join(tmpDir, "cpu.pb.gz")
+	exitCode, _, stderr := runMain(t, "", []string{"run", "-guest-cpuprofile", profilePath, wasmPath})
+	require.Equal(t, 1, exitCode, stderr)
+
+	f, err := os.Open(profilePath)
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	profile, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Contains(t, string(profile), "nanoseconds")
+}
+
+func TestRun_coreDump(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	wasmPath := filepath.Join(tmpDir, "main.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, dwarftestdata.ZigWasm, 0o600))
+
+	// The program panics, which traps with unreachable.
+	coreDumpPath := filepath.Join(tmpDir, "core.wasm")
+	exitCode, _, stderr := runMain(t, "", []string{"run", "-coredump", coreDumpPath, wasmPath})
+	require.Equal(t, 1, exitCode, stderr)
+	require.Contains(t, stderr, "wasm error: unreachable")
+
+	dump, err := os.ReadFile(coreDumpPath)
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(dump, binaryencoding.Magic))
+	require.Contains(t, string(dump), "corestack")
+}
+
+func TestDebug(t *testing.T) {
+	watPath := filepath.Join(t.TempDir(), "exit.wat")
+	require.NoError(t, os.WriteFile(watPath, []byte(`(module
+	(import "wasi_snapshot_preview1" "proc_exit" (func $exit (param i32)))
+	(func (export "_start") (call $exit (i32.const 3))))`), 0o600))
+
+	// Find a free port for the debugger.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	type result struct {
+		exitCode int
+		stderr   string
+	}
+	done := make(chan result)
+	go func() {
+		exitCode, _, stderr := runMain(t, "", []string{"debug", "-dap", addr, watPath})
+		done <- result{exitCode, stderr}
+	}()
+
+	var conn net.Conn
+	for i := 0; i < 100; i++ {
+		if conn, err = net.Dial("tcp", addr); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Run to the end without breakpoints, reading the messages until the session ends.
+	for seq, command := range []string{"initialize", "configurationDone"} {
+		_, err = fmt.Fprintf(conn, "Content-Length: %d\r\n\r\n", len(command)+39)
+		require.NoError(t, err)
+		_, err = fmt.Fprintf(conn, `{"seq":%d,"type":"request","command":"%s"}`, seq+1, command)
+		require.NoError(t, err)
+	}
+	r := textproto.NewReader(bufio.NewReader(conn))
+	var events []string
+	for {
+		header, err := r.ReadMIMEHeader()
+		require.NoError(t, err)
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		require.NoError(t, err)
+		var msg struct{ Type, Event, Command string }
+		b := make([]byte, length)
+		_, err = io.ReadFull(r.R, b)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(b, &msg))
+		events = append(events, msg.Type+" "+msg.Event+msg.Command)
+		if msg.Event == "terminated" {
+			break
+		}
+	}
+	require.Equal(t, []string{
+		"response initialize", "event initialized", "response configurationDone", "event exited", "event terminated",
+	}, events)
+
+	res := <-done
+	require.Equal(t, 3, res.exitCode, res.stderr)
+	require.Equal(t, fmt.Sprintf("waiting for a debugger on %s\n", addr), res.stderr)
+}
+
+func TestSnapshot(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	wasmPath := filepath.Join(tmpDir, "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiSnapshot, 0o600))
+	outPath := filepath.Join(tmpDir, "snapshot.wasm")
+
+	exitCode, stdout, stderr := runMain(t, "", []string{"snapshot", "-env=ANIMAL=bear", "-o", outPath, wasmPath})
+	require.Equal(t, 0, exitCode, stderr)
+	require.Zero(t, stdout) // _start isn't called.
+
+	// The environment read by the initialization function is in the snapshot.
+	exitCode, stdout, stderr = runMain(t, "", []string{"run", "-env=ANIMAL=cat", outPath})
+	require.Equal(t, 0, exitCode, stderr)
+	require.Equal(t, "ANIMAL=bear\x00", stdout)
+}
+
+func TestSnapshot_Errors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	wasmPath := filepath.Join(tmpDir, "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiSnapshot, 0o600))
+	outPath := filepath.Join(tmpDir, "snapshot.wasm")
+
+	notWasmPath := filepath.Join(tmpDir, "bears.wasm")
+	require.NoError(t, os.WriteFile(notWasmPath, []byte("pooh"), 0o600))
+
+	tests := []struct {
+		message string
+		args    []string
+	}{
+		{
+			message: "missing path to wasm file",
+			args:    []string{"-o", outPath},
+		},
+		{
+			message: "missing output path",
+			args:    []string{wasmPath},
+		},
+		{
+			message: "invalid environment variable",
+			args:    []string{"-env=ANIMAL", "-o", outPath, wasmPath},
+		},
+		{
+			message: "error reading wasm binary",
+			args:    []string{"-o", outPath, "non-existent.wasm"},
+		},
+		{
+			message: "error compiling wasm binary",
+			args:    []string{"-o", outPath, notWasmPath},
+		},
+		{
+			message: "error pre-initializing wasm binary: _initialize is not exported",
+			args:    []string{"-init=_initialize", "-o", outPath, wasmPath},
+		},
+		{
+			message: "error writing wasm binary",
+			args:    []string{"-o", filepath.Join(tmpDir, "non-existent", "snapshot.wasm"), wasmPath},
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.message, func(t *testing.T) {
+			exitCode, _, stderr := runMain(t, "", append([]string{"snapshot"}, tt.args...))
+
+			require.Equal(t, 1, exitCode)
+			require.Contains(t, stderr, tt.message)
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	wasmPath := filepath.Join(tmpDir, "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o600))
+
+	exitCode, stdout, stderr := runMain(t, "", []string{"inspect", wasmPath})
+	require.Equal(t, 0, exitCode, stderr)
+	require.Equal(t, `types:
+  [0] (param i32 i32) (result i32)
+  [1] (param i32 i32 i32 i32) (result i32)
+  [2] ()
+imports:
+  wasi_snapshot_preview1.args_get: func (param i32 i32) (result i32)
+  wasi_snapshot_preview1.args_sizes_get: func (param i32 i32) (result i32)
+  wasi_snapshot_preview1.fd_write: func (param i32 i32 i32 i32) (result i32)
+exports:
+  memory: memory[0]
+  _start: func[3]
+memories:
+  [0] pages 1..
+globals:
+  [0] i32
+  [1] i32
+dwarf: false
+`, stdout)
+}
+
+func TestInspect_json(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	max := uint32(4)
+	wasmPath := filepath.Join(tmpDir, "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection: []wasm.FunctionType{{Params: []wasm.ValueType{wasm.ValueTypeI64}}},
+		ImportSection: []wasm.Import{
+			{Type: wasm.ExternTypeFunc, Module: "env", Name: "log", DescFunc: 0},
+			{Type: wasm.ExternTypeMemory, Module: "env", Name: "memory", DescMem: &wasm.Memory{Min: 1, Max: 2, IsMaxEncoded: true, IsShared: true}},
+		},
+		TableSection: []wasm.Table{{Type: wasm.RefTypeExternref, Min: 1, Max: &max}},
+		GlobalSection: []wasm.Global{{
+			Type: wasm.GlobalType{ValType: wasm.ValueTypeI32, Mutable: true},
+			Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+		}},
+		ExportSection:  []wasm.Export{{Type: wasm.ExternTypeGlobal, Name: "counter", Index: 0}},
+		NameSection:    &wasm.NameSection{ModuleName: "plugin", FunctionNames: wasm.NameMap{{Index: 0, Name: "log"}}},
+		CustomSections: []*wasm.CustomSection{{Name: ".debug_info", Data: []byte{1, 2, 3}}},
+	}), 0o600))
+
+	exitCode, stdout, stderr := runMain(t, "", []string{"inspect", "-json", wasmPath})
+	require.Equal(t, 0, exitCode, stderr)
+	require.Equal(t, `{
+  "name": "plugin",
+  "types": [
+    "(param i64)"
+  ],
+  "imports": [
+    {
+      "module": "env",
+      "name": "log",
+      "kind": "func",
+      "type": "(param i64)"
+    },
+    {
+      "module": "env",
+      "name": "memory",
+      "kind": "memory",
+      "type": "pages 1..2 shared"
+    }
+  ],
+  "exports": [
+    {
+      "name": "counter",
+      "kind": "global",
+      "index": 0
+    }
+  ],
+  "memories": [],
+  "tables": [
+    {
+      "index": 0,
+      "type": "externref",
+      "min": 1,
+      "max": 4
+    }
+  ],
+  "globals": [
+    {
+      "index": 0,
+      "type": "i32",
+      "mutable": true
+    }
+  ],
+  "functionNames": [
+    {
+      "index": 0,
+      "name": "log"
+    }
+  ],
+  "customSections": [
+    {
+      "name": "name",
+      "size": 17
+    },
+    {
+      "name": ".debug_info",
+      "size": 3
+    }
+  ],
+  "dwarf": true
+}
+`, stdout)
+}
+
+func TestInspect_Errors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	notWasmPath := filepath.Join(tmpDir, "bears.wasm")
+	require.NoError(t, os.WriteFile(notWasmPath, []byte("pooh"), 0o600))
+
+	tests := []struct {
+		message string
+		args    []string
+	}{
+		{
+			message: "missing path to wasm file",
+			args:    []string{},
+		},
+		{
+			message: "error reading wasm binary",
+			args:    []string{"non-existent.wasm"},
+		},
+		{
+			message: "error decoding wasm binary: invalid magic number",
+			args:    []string{notWasmPath},
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.message, func(t *testing.T) {
+			exitCode, _, stderr := runMain(t, "", append([]string{"inspect"}, tt.args...))
+
+			require.Equal(t, 1, exitCode)
+			require.Contains(t, stderr, tt.message)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	wasmPath := filepath.Join(tmpDir, "test.wasm")
+	require.NoError(t, os.WriteFile(wasmPath, wasmWasiArg, 0o600))
+
+	// tailCallPath is valid only when the tail-call proposal is enabled.
+	tailCallPath := filepath.Join(tmpDir, "tail_call.wasm")
+	require.NoError(t, os.WriteFile(tailCallPath, binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeReturnCall, 0, wasm.OpcodeEnd}}},
+	}), 0o600))
+
+	invalidPath := filepath.Join(tmpDir, "invalid.wasm")
+	require.NoError(t, os.WriteFile(invalidPath, binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:         []wasm.FunctionType{{}},
+		ImportSection:       []wasm.Import{{Type: wasm.ExternTypeFunc, Module: "env", Name: "f", DescFunc: 0}},
+		ImportFunctionCount: 1,
+		FunctionSection:     []wasm.Index{0, 0},
+		CodeSection: []wasm.Code{
+			{Body: []byte{wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeNop, wasm.OpcodeI32Add, wasm.OpcodeEnd}},
+		},
+		NameSection: &wasm.NameSection{FunctionNames: wasm.NameMap{{Index: 2, Name: "add"}}},
+	}), 0o600))
+
+	tests := []struct {
+		name             string
+		args             []string
+		expectedExitCode int
+		expectedStderr   string
+	}{
+		{
+			name: "valid",
+			args: []string{wasmPath},
+		},
+		{
+			name:             "proposal not enabled",
+			args:             []string{tailCallPath},
+			expectedExitCode: 1,
+			expectedStderr: "invalid wasm binary: section code, function 0, offset 0x17: " +
+				"invalid function[0]: return_call invalid as feature \"tail-call\" is disabled\n",
+		},
+		{
+			name: "proposal enabled",
+			args: []string{"-features", "v2,tail-call", tailCallPath},
+		},
+		{
+			name:             "invalid instruction",
+			args:             []string{invalidPath},
+			expectedExitCode: 1,
+			expectedStderr: "invalid wasm binary: section code, function 2 (add), offset 0x27: " +
+				"invalid function[1]: cannot pop the 1st operand for i32.add: i32 missing\n",
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.name, func(t *testing.T) {
+			exitCode, stdout, stderr := runMain(t, "", append([]string{"validate"}, tt.args...))
+			require.Equal(t, tt.expectedExitCode, exitCode)
+			require.Equal(t, "", stdout)
+			require.Equal(t, tt.expectedStderr, stderr)
+		})
+	}
+}
+
+func TestValidate_Errors(t *testing.T) {
+	tests := []struct {
+		message string
+		args    []string
+	}{
+		{
+			message: "missing path to wasm file",
+			args:    []string{},
+		},
+		{
+			message: "error reading wasm binary",
+			args:    []string{"non-existent.wasm"},
+		},
+		{
+			message: `invalid features: unknown feature "gc"`,
+			args:    []string{"-features", "v2,gc", "test.wasm"},
+		},
+	}
+
+	for _, tc := range tests {
+		tt := tc
+		t.Run(tt.message, func(t *testing.T) {
+			exitCode, _, stderr := runMain(t, "", append([]string{"validate"}, tt.args...))
+
+			require.Equal(t, 1, exitCode)
+			require.Contains(t, stderr, tt.message)
+		})
+	}
+}
+
 func TestVersion(t *testing.T) {
 	exitCode, stdout, stderr := runMain(t, "", []string{"version"})
 	require.Equal(t, 0, exitCode)
@@ -586,6 +1111,9 @@ func TestRun_Errors(t *testing.T) {
 	notWasmPath := filepath.Join(t.TempDir(), "bears.wasm")
 	require.NoError(t, os.WriteFile(notWasmPath, []byte("pooh"), 0o700))
 
+	notCwasmPath := filepath.Join(t.TempDir(), "bears.cwasm")
+	require.NoError(t, os.WriteFile(notCwasmPath, append(wasm.SerializedModuleMagic, "pooh"...), 0o700))
+
 	tests := []struct {
 		message string
 		args    []string
@@ -602,6 +1130,10 @@ func TestRun_Errors(t *testing.T) {
 			message: "error compiling wasm binary",
 			args:    []string{notWasmPath},
 		},
+		{
+			message: "error loading compiled module",
+			args:    []string{notCwasmPath},
+		},
 		{
 			message: "invalid environment variable",
 			args:    []string{"--env=ANIMAL", "testdata/wasi_env.wasm"},
@@ -618,6 +1150,14 @@ func TestRun_Errors(t *testing.T) {
 			message: "timeout duration may not be negative",
 			args:    []string{"-timeout=-10s", wasmPath},
 		},
+		{
+			message: "error loading compiled module: -coverprofile changes how the module is compiled",
+			args:    []string{"-coverprofile", filepath.Join(t.TempDir(), "coverage.out"), notCwasmPath},
+		},
+		{
+			message: "error loading compiled module: -guest-cpuprofile changes how the module is compiled",
+			args:    []string{"-guest-cpuprofile", filepath.Join(t.TempDir(), "cpu.pb.gz"), notCwasmPath},
+		},
 	}
 
 	for _, tc := range tests {
@@ -784,7 +1324,11 @@ Usage:
 
 Commands:
   compile	Pre-compiles a WebAssembly binary
+  debug		Runs a WebAssembly binary in a debugger
+  inspect	Prints the structure of a WebAssembly binary
   run		Runs a WebAssembly binary
+  snapshot	Pre-initializes a WebAssembly binary
+  validate	Checks a WebAssembly binary only uses the given features
   version	Displays the version of wazero CLI
 `, stderr)
 }
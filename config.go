@@ -3,7 +3,6 @@ package wazero
 import (
 	"context"
 	"errors"
-	"fmt"
 	"io"
 	"io/fs"
 	"math"
@@ -57,7 +56,7 @@ type RuntimeConfig interface {
 
 	// WithMemoryLimitPages overrides the maximum pages allowed per memory. The
 	// default is 65536, allowing 4GB total memory per instance if the maximum is
-	// not encoded in a Wasm binary. Setting a value larger than default will panic.
+	// not encoded in a Wasm binary.
 	//
 	// This example reduces the largest possible memory size from 4GB to 128KB:
 	//	rConfig = wazero.NewRuntimeConfig().WithMemoryLimitPages(2)
@@ -65,6 +64,13 @@ type RuntimeConfig interface {
 	// Note: Wasm has 32-bit memory and each page is 65536 (2^16) bytes. This
 	// implies a max of 65536 (2^16) addressable pages.
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#grow-mem
+	//
+	// Note: Values larger than 65536 only apply to 64-bit memories, defined
+	// when api.CoreFeatureMemory64 is enabled. 32-bit memories remain limited
+	// to 65536 pages. For example, this allows 64-bit memories up to 16GB:
+	//	rConfig = wazero.NewRuntimeConfig().
+	//		WithCoreFeatures(api.CoreFeaturesV2 | api.CoreFeatureMemory64).
+	//		WithMemoryLimitPages(262144)
 	WithMemoryLimitPages(memoryLimitPages uint32) RuntimeConfig
 
 	// WithMemoryCapacityFromMax eagerly allocates max memory, unless max is
@@ -169,6 +175,76 @@ type RuntimeConfig interface {
 	// When the invocations of api.Function are closed due to this, sys.ExitError is raised to the callers and
 	// the api.Module from which the functions are derived is made closed.
 	WithCloseOnContextDone(bool) RuntimeConfig
+
+	// WithFuelMetering enables the deterministic execution budget of functions defined in Wasm modules.
+	// Defaults to false.
+	//
+	// When enabled, each api.Module holds the fuel remaining, which is set initially via ModuleConfig.WithFuel, and
+	// can be updated via SetFuel and AddFuel of api.Module. One unit of fuel is consumed on each entry to a function
+	// defined in the module and on each iteration of a loop. The amount consumed is identical regardless of the
+	// engine, so it can be used for reproducible accounting.
+	//
+	// When the fuel runs out, the function call fails with sys.ExitError whose ExitCode is sys.ExitCodeFuelExhausted.
+	// Unlike WithCloseOnContextDone, the api.Module is not closed, so the call can be retried after adding fuel.
+	//
+	// Note that this comes with a bit of extra cost when enabled, as the fuel is checked periodically. Host
+	// functions are not metered.
+	WithFuelMetering(bool) RuntimeConfig
+
+	// WithEpochInterruption enables the interruption of functions defined in Wasm modules based on the epoch of
+	// the Runtime. Defaults to false.
+	//
+	// When enabled, the host advances the epoch with Runtime.IncrementEpoch, e.g. from a time.Ticker, and bounds
+	// the calls with api.Module SetEpochDeadline, or each call with experimental.WithEpochDeadline. The deadline is
+	// checked on each entry to a function defined in the module and on each iteration of a loop. Once the epoch
+	// reaches the deadline, the function call fails with sys.ExitError whose ExitCode is
+	// sys.ExitCodeEpochDeadlineExceeded.
+	//
+	// Unlike WithCloseOnContextDone, this requires neither a goroutine nor a context.Context per call, and the
+	// api.Module is not closed, so it remains usable for the subsequent calls.
+	//
+	// Note: The compiled functions are not preempted by the Go scheduler, so the goroutine calling IncrementEpoch
+	// needs another OS thread to run on while they are executing, i.e. GOMAXPROCS must be greater than one.
+	WithEpochInterruption(bool) RuntimeConfig
+
+	// WithMaxCallStackDepth limits the depth of nested calls to functions defined in Wasm modules. Defaults to
+	// zero, which uses the built-in limit of the engine: 2000 frames for the interpreter, and the size of the stack
+	// for the compilers.
+	//
+	// When the limit is exceeded, the function call fails with the "stack overflow" error including the stack trace.
+	// For example, this allows a recursive-descent parser to recurse deeper than the interpreter's default, or a
+	// module to be clamped far lower to bound the memory used by its stack:
+	//
+	//	rConfig = wazero.NewRuntimeConfig().WithMaxCallStackDepth(100)
+	//
+	// The limit can be overridden per call via experimental.WithMaxCallStackDepth.
+	//
+	// Note that this comes with a bit of extra cost in the compilers when enabled, as the depth is tracked on
+	// each function call. Host functions are not counted.
+	WithMaxCallStackDepth(depth uint32) RuntimeConfig
+
+	// WithCoreDump enables capturing a WebAssembly coredump when a function defined in a Wasm module traps.
+	// Defaults to false.
+	//
+	// When enabled, the error returned by the function call carries a coredump of the guest at the time of the
+	// trap, which is read with experimental.CoreDump. It contains the call stack, with the function index, code
+	// offset and locals of each frame, and the memories and globals of the modules in it. The format follows the
+	// WebAssembly tool conventions, so it can be analyzed offline, e.g. by wasmgdb.
+	//
+	// Locals are only recorded by the interpreter, as the compilers keep them in registers. Frames of the
+	// optimizing compiler are only recorded for the module whose function was called.
+	//
+	// Note that this comes with a bit of extra cost in the interpreter when enabled, as the offsets of the
+	// instructions are retained. Nothing is captured when the guest exits, e.g. with sys.ExitError.
+	// See https://github.com/WebAssembly/tool-conventions/blob/main/Coredump.md
+	WithCoreDump(bool) RuntimeConfig
+
+	// WithSerialization retains the source binary of each module compiled or deserialized by the Runtime, which is
+	// required by CompiledModule.Serialize. Defaults to false.
+	//
+	// Note that this comes with the extra cost of keeping the whole binary in memory for the lifetime of each
+	// CompiledModule, so it should only be enabled when modules are serialized.
+	WithSerialization(bool) RuntimeConfig
 }
 
 // NewRuntimeConfig returns a RuntimeConfig using the compiler if it is supported in this environment,
@@ -189,6 +265,11 @@ type runtimeConfig struct {
 	cache                 CompilationCache
 	storeCustomSections   bool
 	ensureTermination     bool
+	fuelMetering          bool
+	epochInterruption     bool
+	maxCallStackDepth     uint32
+	coreDump              bool
+	serialization         bool
 }
 
 // EnableOptimizingCompiler implements experimental/opt/enabler.EnableOptimizingCompiler.
@@ -261,13 +342,46 @@ func (c *runtimeConfig) WithCloseOnContextDone(ensure bool) RuntimeConfig {
 	return ret
 }
 
+// WithFuelMetering implements RuntimeConfig.WithFuelMetering
+func (c *runtimeConfig) WithFuelMetering(enabled bool) RuntimeConfig {
+	ret := c.clone()
+	ret.fuelMetering = enabled
+	return ret
+}
+
+// WithEpochInterruption implements RuntimeConfig.WithEpochInterruption
+func (c *runtimeConfig) WithEpochInterruption(enabled bool) RuntimeConfig {
+	ret := c.clone()
+	ret.epochInterruption = enabled
+	return ret
+}
+
+// WithMaxCallStackDepth implements RuntimeConfig.WithMaxCallStackDepth
+func (c *runtimeConfig) WithMaxCallStackDepth(depth uint32) RuntimeConfig {
+	ret := c.clone()
+	ret.maxCallStackDepth = depth
+	return ret
+}
+
+// WithCoreDump implements RuntimeConfig.WithCoreDump
+func (c *runtimeConfig) WithCoreDump(enabled bool) RuntimeConfig {
+	ret := c.clone()
+	ret.coreDump = enabled
+	return ret
+}
+
+// WithSerialization implements RuntimeConfig.WithSerialization
+func (c *runtimeConfig) WithSerialization(enabled bool) RuntimeConfig {
+	ret := c.clone()
+	ret.serialization = enabled
+	return ret
+}
+
 // WithMemoryLimitPages implements RuntimeConfig.WithMemoryLimitPages
 func (c *runtimeConfig) WithMemoryLimitPages(memoryLimitPages uint32) RuntimeConfig {
 	ret := c.clone()
-	// This panics instead of returning an error as it is unlikely.
-	if memoryLimitPages > wasm.MemoryLimitPages {
-		panic(fmt.Errorf("memoryLimitPages invalid: %d > %d", memoryLimitPages, wasm.MemoryLimitPages))
-	}
+	// Any value is valid, as 32-bit memories are capped to wasm.MemoryLimitPages
+	// and wasm.Memory64LimitPages is the largest uint32.
 	ret.memoryLimitPages = memoryLimitPages
 	return ret
 }
@@ -331,7 +445,7 @@ type CompiledModule interface {
 	//
 	// ## Notes
 	//   - As of WebAssembly Core Specification 2.0, there can be at most one
-	//     memory.
+	//     memory, unless api.CoreFeatureMultiMemory is enabled.
 	//   - Unlike ExportedMemories, there is no unique constraint on imports.
 	ImportedMemories() []api.MemoryDefinition
 
@@ -339,13 +453,37 @@ type CompiledModule interface {
 	// (api.MemoryDefinition) in this module keyed on export name.
 	//
 	// Note: As of WebAssembly Core Specification 2.0, there can be at most one
-	// memory.
+	// memory, unless api.CoreFeatureMultiMemory is enabled.
 	ExportedMemories() map[string]api.MemoryDefinition
 
+	// ImportedTables returns all the imported tables
+	// (api.TableDefinition) in this module or nil if there are none.
+	//
+	// Note: Unlike ExportedTables, there is no unique constraint on imports.
+	ImportedTables() []api.TableDefinition
+
+	// ExportedTables returns all the exported tables
+	// (api.TableDefinition) in this module keyed on export name.
+	ExportedTables() map[string]api.TableDefinition
+
 	// CustomSections returns all the custom sections
 	// (api.CustomSection) in this module keyed on the section name.
 	CustomSections() []api.CustomSection
 
+	// Serialize writes the native code compiled for this module into `w`, so that it can be loaded with
+	// Runtime.DeserializeModule without compiling it again. The output is conventionally saved with the
+	// ".cwasm" extension.
+	//
+	// # Notes
+	//
+	//   - This returns an error unless the module was compiled by a compiler, e.g. NewRuntimeConfigCompiler.
+	//     Host modules cannot be serialized either.
+	//   - This returns an error unless RuntimeConfig.WithSerialization is enabled, as the source binary is not
+	//     retained otherwise.
+	//   - The output embeds the source binary, and is specific to the version of wazero, the platform and the
+	//     CPU features of the host which compiled it.
+	Serialize(w io.Writer) error
+
 	// Close releases all the allocated resources for this CompiledModule.
 	//
 	// Note: It is safe to call Close while having outstanding calls from an
@@ -363,6 +501,9 @@ type compiledModule struct {
 	// closeWithModule prevents leaking compiled code when a module is compiled implicitly.
 	closeWithModule bool
 	typeIDs         []wasm.FunctionTypeID
+	// binary is the source of `module`, retained for Serialize when RuntimeConfig.WithSerialization is enabled.
+	// This is nil otherwise, and for host modules.
+	binary []byte
 }
 
 // Name implements CompiledModule.Name
@@ -400,6 +541,16 @@ func (c *compiledModule) ExportedMemories() map[string]api.MemoryDefinition {
 	return c.module.ExportedMemories()
 }
 
+// ImportedTables implements CompiledModule.ImportedTables
+func (c *compiledModule) ImportedTables() []api.TableDefinition {
+	return c.module.ImportedTables()
+}
+
+// ExportedTables implements CompiledModule.ExportedTables
+func (c *compiledModule) ExportedTables() map[string]api.TableDefinition {
+	return c.module.ExportedTables()
+}
+
 // CustomSections implements CompiledModule.CustomSections
 func (c *compiledModule) CustomSections() []api.CustomSection {
 	ret := make([]api.CustomSection, len(c.module.CustomSections))
@@ -409,6 +560,22 @@ func (c *compiledModule) CustomSections() []api.CustomSection {
 	return ret
 }
 
+// Serialize implements CompiledModule.Serialize
+func (c *compiledModule) Serialize(w io.Writer) error {
+	es, ok := c.compiledEngine.(wasm.EngineSerializer)
+	if !ok {
+		return errors.New("compiled modules can only be serialized by the compiler")
+	} else if c.module.IsHostModule {
+		return errors.New("host modules cannot be serialized")
+	} else if c.binary == nil {
+		return errors.New("compiled modules can only be serialized when RuntimeConfig.WithSerialization is enabled")
+	}
+	if err := writeSerializedModuleHeader(w, c.module.ID, c.binary); err != nil {
+		return err
+	}
+	return es.SerializeCompiledModule(c.module, w)
+}
+
 // customSection implements wasm.CustomSection
 type customSection struct {
 	internalapi.WazeroOnlyType
@@ -426,6 +593,39 @@ func (c *customSection) Data() []byte {
 	return c.data
 }
 
+// ResourceLimiter is consulted whenever a memory or a table defined by a module grows, after the growth is checked
+// against the declared maximum. This allows enforcing quotas shared by multiple modules, such as per-tenant memory
+// budgets, or recording metrics of the growth.
+//
+// Here's an example which caps the memory of a module to 16MiB:
+//
+//	type limiter struct{}
+//
+//	func (limiter) MemoryGrowing(current, desired uint32) (bool, error) {
+//		return desired <= 256, nil
+//	}
+//
+//	func (limiter) TableGrowing(current, desired uint32) (bool, error) {
+//		return true, nil
+//	}
+//
+//	mod, _ := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithResourceLimiter(limiter{}))
+//
+// # Notes
+//
+//   - The methods return true to allow the growth, or false to deny it, in which case `memory.grow` and
+//     `table.grow` return -1. A non-nil error traps instead, failing the function call with that error.
+//   - When a host function grows the memory via api.Memory Grow, a non-nil error panics.
+//   - Memories and tables imported from other modules are limited by the ResourceLimiter of the module defining them.
+//   - The methods must be safe for concurrent use when the limiter is shared by multiple modules.
+type ResourceLimiter interface {
+	// MemoryGrowing is called before a memory grows from `current` to `desired` pages, where a page is 64KiB.
+	MemoryGrowing(current, desired uint32) (bool, error)
+
+	// TableGrowing is called before a table grows from `current` to `desired` elements.
+	TableGrowing(current, desired uint32) (bool, error)
+}
+
 // ModuleConfig configures resources needed by functions that have low-level interactions with the host operating
 // system. Using this, resources such as STDIN can be isolated, so that the same module can be safely instantiated
 // multiple times.
@@ -489,10 +689,23 @@ type ModuleConfig interface {
 	// (e.g. syscall.ENOSYS).
 	WithFSConfig(FSConfig) ModuleConfig
 
+	// WithFuel configures the initial fuel of the module, which is available to its start functions. Defaults to
+	// zero. This has no effect unless RuntimeConfig.WithFuelMetering is enabled.
+	//
+	// See RuntimeConfig.WithFuelMetering for details.
+	WithFuel(uint64) ModuleConfig
+
 	// WithName configures the module name. Defaults to what was decoded from
 	// the name section. Empty string ("") clears any name.
 	WithName(string) ModuleConfig
 
+	// WithResourceLimiter configures the ResourceLimiter consulted whenever a memory or a table defined by the module
+	// grows, e.g. on `memory.grow` or api.Memory Grow. Defaults to none, so growth is only bounded by the declared
+	// maximum and RuntimeConfig.WithMemoryLimitPages.
+	//
+	// See ResourceLimiter for details.
+	WithResourceLimiter(ResourceLimiter) ModuleConfig
+
 	// WithStartFunctions configures the functions to call after the module is
 	// instantiated. Defaults to "_start".
 	//
@@ -666,6 +879,10 @@ type moduleConfig struct {
 	fsConfig FSConfig
 	// sockConfig is the network listener configuration for ABI like WASI.
 	sockConfig *internalsock.Config
+	// fuel is the initial fuel of the module when fuel metering is enabled.
+	fuel uint64
+	// resourceLimiter is consulted when the memories and tables of the module grow.
+	resourceLimiter ResourceLimiter
 }
 
 // NewModuleConfig returns a ModuleConfig that can be used for configuring module instantiation.
@@ -733,6 +950,20 @@ func (c *moduleConfig) WithFSConfig(config FSConfig) ModuleConfig {
 	return ret
 }
 
+// WithFuel implements ModuleConfig.WithFuel
+func (c *moduleConfig) WithFuel(fuel uint64) ModuleConfig {
+	ret := c.clone()
+	ret.fuel = fuel
+	return ret
+}
+
+// WithResourceLimiter implements ModuleConfig.WithResourceLimiter
+func (c *moduleConfig) WithResourceLimiter(limiter ResourceLimiter) ModuleConfig {
+	ret := c.clone()
+	ret.resourceLimiter = limiter
+	return ret
+}
+
 // WithName implements ModuleConfig.WithName
 func (c *moduleConfig) WithName(name string) ModuleConfig {
 	ret := c.clone()
@@ -43,6 +43,15 @@ func TestRuntimeConfig(t *testing.T) {
 				memoryLimitPages: 10,
 			},
 		},
+		{
+			name: "memoryLimitPages over 32-bit limit",
+			with: func(c RuntimeConfig) RuntimeConfig {
+				return c.WithMemoryLimitPages(wasm.MemoryLimitPages * 2)
+			},
+			expected: &runtimeConfig{
+				memoryLimitPages: wasm.MemoryLimitPages * 2,
+			},
+		},
 		{
 			name: "memoryCapacityFromMax",
 			with: func(c RuntimeConfig) RuntimeConfig {
@@ -75,6 +84,26 @@ func TestRuntimeConfig(t *testing.T) {
 			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithCloseOnContextDone(true) },
 			expected: &runtimeConfig{ensureTermination: true},
 		},
+		{
+			name:     "WithFuelMetering",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithFuelMetering(true) },
+			expected: &runtimeConfig{fuelMetering: true},
+		},
+		{
+			name:     "WithEpochInterruption",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithEpochInterruption(true) },
+			expected: &runtimeConfig{epochInterruption: true},
+		},
+		{
+			name:     "WithMaxCallStackDepth",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithMaxCallStackDepth(100) },
+			expected: &runtimeConfig{maxCallStackDepth: 100},
+		},
+		{
+			name:     "WithSerialization",
+			with:     func(c RuntimeConfig) RuntimeConfig { return c.WithSerialization(true) },
+			expected: &runtimeConfig{serialization: true},
+		},
 	}
 
 	for _, tt := range tests {
@@ -88,14 +117,6 @@ func TestRuntimeConfig(t *testing.T) {
 			require.Equal(t, &runtimeConfig{}, input)
 		})
 	}
-
-	t.Run("memoryLimitPages invalid panics", func(t *testing.T) {
-		err := require.CapturePanic(func() {
-			input := &runtimeConfig{}
-			input.WithMemoryLimitPages(wasm.MemoryLimitPages + 1)
-		})
-		require.EqualError(t, err, "memoryLimitPages invalid: 65537 > 65536")
-	})
 }
 
 func TestModuleConfig(t *testing.T) {
@@ -529,6 +550,29 @@ func TestModuleConfig_toSysContext_WithNanosleep(t *testing.T) {
 	sysCtx.Nanosleep(2)
 }
 
+func TestModuleConfig_WithFuel(t *testing.T) {
+	require.Equal(t, uint64(0), NewModuleConfig().(*moduleConfig).fuel)
+	require.Equal(t, uint64(100), NewModuleConfig().WithFuel(100).(*moduleConfig).fuel)
+	require.Equal(t, uint64(1), NewModuleConfig().WithFuel(100).WithFuel(1).(*moduleConfig).fuel)
+}
+
+type testResourceLimiter struct{ max uint32 }
+
+func (l testResourceLimiter) MemoryGrowing(_, desired uint32) (bool, error) {
+	return desired <= l.max, nil
+}
+
+func (l testResourceLimiter) TableGrowing(_, desired uint32) (bool, error) {
+	return desired <= l.max, nil
+}
+
+func TestModuleConfig_WithResourceLimiter(t *testing.T) {
+	require.Nil(t, NewModuleConfig().(*moduleConfig).resourceLimiter)
+	limiter := testResourceLimiter{max: 1}
+	require.Equal(t, ResourceLimiter(limiter), NewModuleConfig().WithResourceLimiter(limiter).(*moduleConfig).resourceLimiter)
+	require.Nil(t, NewModuleConfig().WithResourceLimiter(limiter).WithResourceLimiter(nil).(*moduleConfig).resourceLimiter)
+}
+
 // TestModuleConfig_toSysContext_WithOsyield has to test differently because
 // we can't compare function pointers when functions are passed by value.
 func TestModuleConfig_toSysContext_WithOsyield(t *testing.T) {
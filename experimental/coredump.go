@@ -0,0 +1,24 @@
+package experimental
+
+import "errors"
+
+// CoreDump returns the WebAssembly coredump carried by the error of a function call which trapped, when the runtime
+// was configured with wazero.RuntimeConfig WithCoreDump, or nil otherwise.
+//
+// The coredump is a WebAssembly binary, which is usually written to a file ending with ".wasm" to be analyzed
+// offline, e.g. by wasmgdb. For example:
+//
+//	_, err := mod.ExportedFunction("handle").Call(ctx)
+//	if dump := experimental.CoreDump(err); dump != nil {
+//		_ = os.WriteFile("core.wasm", dump, 0o600)
+//	}
+//
+// See https://github.com/WebAssembly/tool-conventions/blob/main/Coredump.md
+func CoreDump(err error) []byte {
+	// The error is matched by its method, as the type of the error is internal.
+	var coreDumpErr interface{ CoreDump() []byte }
+	if errors.As(err, &coreDumpErr) {
+		return coreDumpErr.CoreDump()
+	}
+	return nil
+}
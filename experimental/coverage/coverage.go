@@ -0,0 +1,298 @@
+// Package coverage collects the functions and instructions run by a guest, to report the coverage of its source code,
+// e.g. by the tests of a TinyGo or Rust program.
+package coverage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binary"
+)
+
+// Collector is an experimental.FunctionListenerFactory recording the functions called by a module, and the blocks of
+// instructions it ran. Add it to the context used to compile the module, then write the report once the module is done:
+//
+//	collector, err := coverage.NewCollector(wasm)
+//	if err != nil {
+//		log.Panicln(err)
+//	}
+//	ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, collector)
+//	// Compile, instantiate and run the module with ctx...
+//	err = collector.WriteLCOV(f)
+//
+// # Notes
+//
+//   - Blocks are only recorded by the interpreter, i.e. wazero.NewRuntimeConfigInterpreter. With other engines, only
+//     function calls are, so reports have no covered lines.
+//   - Source lines are read from the DWARF custom sections of the module. Modules without them have empty reports.
+//   - Offsets are in the code section of the module, in the same way as experimental.InternalFunction
+//     SourceOffsetForPC. A collector is for a single module: other guest modules compiled with it are mixed up.
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type Collector interface {
+	experimental.FunctionListenerFactory
+
+	// FunctionCalls returns the number of calls of each function which ran, by its index in the function index
+	// namespace, which begins with imported functions.
+	FunctionCalls() map[uint32]uint64
+
+	// Blocks returns the blocks which ran, sorted by their start offset.
+	Blocks() []Block
+
+	// WriteLCOV writes the line and function coverage in the LCOV tracefile format, as read by genhtml.
+	//
+	// See https://manpages.debian.org/unstable/lcov/geninfo.1.en.html#FILES
+	WriteLCOV(w io.Writer) error
+
+	// WriteCoverprofile writes the line coverage in the Go coverprofile format, as read by "go tool cover". There is
+	// one block per source line.
+	WriteCoverprofile(w io.Writer) error
+}
+
+// Block is a range of instructions which ran, as notified to experimental.BlockListener.
+type Block struct {
+	// Start is the offset of the first instruction in the code section.
+	Start uint64
+	// End is the offset after the last instruction in the code section.
+	End uint64
+	// Count is the number of times the block started running.
+	Count uint64
+}
+
+// NewCollector returns a Collector for the module in the WebAssembly binary format, whose DWARF custom sections map
+// instructions to source lines.
+func NewCollector(wasmBinary []byte) (Collector, error) {
+	m, err := binary.DecodeModule(wasmBinary, wasm.CoreFeaturesAll, wasm.MemoryLimitPages, false, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding wasm binary: %w", err)
+	}
+	c := &collector{module: m, calls: map[uint32]uint64{}, blocks: map[blockRange]uint64{}}
+	c.listener = &coverageListener{c}
+	return c, nil
+}
+
+type blockRange struct{ start, end uint64 }
+
+type collector struct {
+	module   *wasm.Module
+	listener *coverageListener
+
+	mux    sync.Mutex
+	calls  map[uint32]uint64
+	blocks map[blockRange]uint64
+}
+
+// NewFunctionListener implements the same method as documented on experimental.FunctionListenerFactory.
+func (c *collector) NewFunctionListener(def api.FunctionDefinition) experimental.FunctionListener {
+	if def.GoFunction() != nil {
+		return nil // host functions have no source in the module.
+	}
+	return c.listener
+}
+
+// FunctionCalls implements the same method as documented on Collector.
+func (c *collector) FunctionCalls() map[uint32]uint64 {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	ret := make(map[uint32]uint64, len(c.calls))
+	for idx, n := range c.calls {
+		ret[idx] = n
+	}
+	return ret
+}
+
+// Blocks implements the same method as documented on Collector.
+func (c *collector) Blocks() []Block {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	ret := make([]Block, 0, len(c.blocks))
+	for b, n := range c.blocks {
+		ret = append(ret, Block{Start: b.start, End: b.end, Count: n})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Start != ret[j].Start {
+			return ret[i].Start < ret[j].Start
+		}
+		return ret[i].End < ret[j].End
+	})
+	return ret
+}
+
+// coverageListener implements experimental.BlockListener for all functions of the collector.
+type coverageListener struct{ c *collector }
+
+// Before implements the same method as documented on experimental.FunctionListener.
+func (l *coverageListener) Before(_ context.Context, _ api.Module, def api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) {
+	l.c.mux.Lock()
+	l.c.calls[def.Index()]++
+	l.c.mux.Unlock()
+}
+
+// After implements the same method as documented on experimental.FunctionListener.
+func (l *coverageListener) After(context.Context, api.Module, api.FunctionDefinition, []uint64) {}
+
+// Abort implements the same method as documented on experimental.FunctionListener.
+func (l *coverageListener) Abort(context.Context, api.Module, api.FunctionDefinition, error) {}
+
+// Block implements the same method as documented on experimental.BlockListener.
+func (l *coverageListener) Block(_ context.Context, _ api.Module, _ api.FunctionDefinition, start, end uint64) {
+	l.c.mux.Lock()
+	l.c.blocks[blockRange{start, end}]++
+	l.c.mux.Unlock()
+}
+
+// sourceLine is a line of a source file.
+type sourceLine struct {
+	file string
+	line int
+}
+
+// functionCoverage is the coverage of a function defined by the module.
+type functionCoverage struct {
+	name  string
+	start sourceLine
+	calls uint64
+}
+
+// fileCoverage is the coverage of a source file.
+type fileCoverage struct {
+	name      string
+	functions []functionCoverage
+	// lines maps each line with instructions to the number of times the most run of them ran.
+	lines map[int]uint64
+}
+
+// report maps the functions and blocks which ran to the source files, sorted by name.
+func (c *collector) report() []*fileCoverage {
+	entries := c.module.DWARFLines.LineEntries()
+	blocks := c.Blocks()
+	calls := c.FunctionCalls()
+
+	files := map[string]*fileCoverage{}
+	file := func(name string) *fileCoverage {
+		f, ok := files[name]
+		if !ok {
+			f = &fileCoverage{name: name, lines: map[int]uint64{}}
+			files[name] = f
+		}
+		return f
+	}
+
+	// Blocks don't overlap, so the ones containing each entry are found with a single pass over both.
+	var b int
+	for _, e := range entries {
+		for b < len(blocks) && blocks[b].End <= e.Address {
+			b++
+		}
+		var count uint64
+		for i := b; i < len(blocks) && blocks[i].Start <= e.Address; i++ {
+			if e.Address < blocks[i].End {
+				count += blocks[i].Count
+			}
+		}
+		f := file(e.File)
+		if prev, ok := f.lines[e.Line]; !ok || count > prev {
+			f.lines[e.Line] = count
+		}
+	}
+
+	// Functions are located by the first entry in their body.
+	for i := range c.module.CodeSection {
+		code := &c.module.CodeSection[i]
+		start, end := code.BodyOffsetInCodeSection, code.BodyOffsetInCodeSection+uint64(len(code.Body))
+		e := sort.Search(len(entries), func(j int) bool { return entries[j].Address >= start })
+		if e == len(entries) || entries[e].Address >= end {
+			continue
+		}
+		idx := c.module.ImportFunctionCount + wasm.Index(i)
+		fn := functionCoverage{
+			name:  c.functionName(idx),
+			start: sourceLine{entries[e].File, entries[e].Line},
+			calls: calls[idx],
+		}
+		f := file(fn.start.file)
+		f.functions = append(f.functions, fn)
+	}
+
+	ret := make([]*fileCoverage, 0, len(files))
+	for _, f := range files {
+		ret = append(ret, f)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].name < ret[j].name })
+	return ret
+}
+
+// functionName returns the name of the function in the name section, or its index otherwise.
+func (c *collector) functionName(idx wasm.Index) string {
+	if ns := c.module.NameSection; ns != nil {
+		for _, n := range ns.FunctionNames {
+			if n.Index == idx {
+				return n.Name
+			}
+		}
+	}
+	return fmt.Sprintf("$%d", idx)
+}
+
+// sortedLines returns the lines of the file in increasing order.
+func (f *fileCoverage) sortedLines() []int {
+	lines := make([]int, 0, len(f.lines))
+	for l := range f.lines {
+		lines = append(lines, l)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// WriteLCOV implements the same method as documented on Collector.
+func (c *collector) WriteLCOV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range c.report() {
+		fmt.Fprintf(bw, "SF:%s\n", f.name)
+
+		var functionsHit int
+		for _, fn := range f.functions {
+			fmt.Fprintf(bw, "FN:%d,%s\n", fn.start.line, fn.name)
+		}
+		for _, fn := range f.functions {
+			fmt.Fprintf(bw, "FNDA:%d,%s\n", fn.calls, fn.name)
+			if fn.calls > 0 {
+				functionsHit++
+			}
+		}
+		fmt.Fprintf(bw, "FNF:%d\nFNH:%d\n", len(f.functions), functionsHit)
+
+		var linesHit int
+		for _, l := range f.sortedLines() {
+			count := f.lines[l]
+			fmt.Fprintf(bw, "DA:%d,%d\n", l, count)
+			if count > 0 {
+				linesHit++
+			}
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", len(f.lines), linesHit)
+	}
+	return bw.Flush()
+}
+
+// WriteCoverprofile implements the same method as documented on Collector.
+func (c *collector) WriteCoverprofile(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "mode: count")
+	for _, f := range c.report() {
+		for _, l := range f.sortedLines() {
+			// Columns aren't reliable in DWARF, so each block is the whole line.
+			fmt.Fprintf(bw, "%s:%d.1,%d.1 1 %d\n", f.name, l, l+1, f.lines[l])
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,104 @@
+package coverage_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/experimental/coverage"
+	"github.com/AR1011/wazero/internal/testing/dwarftestdata"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wat"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// absText exports "abs", which calls "neg" for negative numbers.
+const absText = `(module
+	(func $neg (param i32) (result i32)
+		(i32.sub (i32.const 0) (local.get 0)))
+	(func (export "abs") (param i32) (result i32)
+		(if (result i32) (i32.lt_s (local.get 0) (i32.const 0))
+			(then (call $neg (local.get 0)))
+			(else (local.get 0))))
+)`
+
+func TestCollector(t *testing.T) {
+	bin, err := wat.Compile([]byte(absText))
+	require.NoError(t, err)
+
+	collector, err := coverage.NewCollector(bin)
+	require.NoError(t, err)
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, collector)
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+
+	mod, err := r.Instantiate(ctx, bin)
+	require.NoError(t, err)
+	abs := mod.ExportedFunction("abs")
+
+	_, err = abs.Call(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, map[uint32]uint64{1: 1}, collector.FunctionCalls())
+	positiveBlocks := collector.Blocks()
+
+	_, err = abs.Call(ctx, api32(-1))
+	require.NoError(t, err)
+	require.Equal(t, map[uint32]uint64{0: 1, 1: 2}, collector.FunctionCalls())
+	blocks := collector.Blocks()
+	require.True(t, len(blocks) > len(positiveBlocks))
+
+	// Blocks are ranges of the code section, which don't overlap.
+	for i, b := range blocks {
+		require.True(t, b.Start <= b.End)
+		require.True(t, b.Count > 0)
+		if i > 0 {
+			require.True(t, blocks[i-1].End <= b.Start)
+		}
+	}
+
+	// Without DWARF, there are no source lines.
+	var buf bytes.Buffer
+	require.NoError(t, collector.WriteLCOV(&buf))
+	require.Equal(t, "", buf.String())
+	buf.Reset()
+	require.NoError(t, collector.WriteCoverprofile(&buf))
+	require.Equal(t, "mode: count\n", buf.String())
+}
+
+func api32(v int32) uint64 {
+	return uint64(uint32(v))
+}
+
+func TestCollector_DWARF(t *testing.T) {
+	collector, err := coverage.NewCollector(dwarftestdata.ZigWasm)
+	require.NoError(t, err)
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, collector)
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+
+	// The program panics, but lines up to the panic are covered.
+	_, err = r.Instantiate(ctx, dwarftestdata.ZigWasm)
+	require.Error(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, collector.WriteLCOV(&buf))
+	lcov := buf.String()
+	require.Contains(t, lcov, "zig/main.zig\n")
+	// main inlines the function panicking at line 10.
+	require.Contains(t, lcov, "FN:10,main.main\nFNDA:1,main.main\n")
+	require.Contains(t, lcov, "DA:10,1\n")
+	require.Equal(t, strings.Count(lcov, "SF:"), strings.Count(lcov, "end_of_record\n"))
+
+	buf.Reset()
+	require.NoError(t, collector.WriteCoverprofile(&buf))
+	profile := buf.String()
+	require.True(t, strings.HasPrefix(profile, "mode: count\n"))
+	require.Contains(t, profile, "zig/main.zig:10.1,11.1 1 1\n")
+}
@@ -0,0 +1,280 @@
+package dap_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/experimental/dap"
+	"github.com/AR1011/wazero/imports/wasi_snapshot_preview1"
+	"github.com/AR1011/wazero/internal/testing/dwarftestdata"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wat"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// sumText exports "run", which stores the sum of 1 to 3 in memory with "add".
+const sumText = `(module $sum
+	(memory 1)
+	(global $total (mut i32) (i32.const 0))
+	(func $add (param $n i32)
+		(global.set $total (i32.add (global.get $total) (local.get $n))))
+	(func (export "run")
+		(local $i i32)
+		(loop $l
+			(local.set $i (i32.add (local.get $i) (i32.const 1)))
+			(call $add (local.get $i))
+			(br_if $l (i32.lt_u (local.get $i) (i32.const 3))))
+		(i32.store (i32.const 16) (global.get $total)))
+)`
+
+// message is a response or an event, with only the fields used by the tests.
+type message struct {
+	Type    string          `json:"type"`
+	Event   string          `json:"event"`
+	Command string          `json:"command"`
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// client is a client of the debugger, which reads the messages it sends.
+type client struct {
+	t   *testing.T
+	c   net.Conn
+	r   *textproto.Reader
+	seq int
+}
+
+func (c *client) send(command string, args interface{}) {
+	c.seq++
+	b, err := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	require.NoError(c.t, err)
+	_, err = fmt.Fprintf(c.c, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	require.NoError(c.t, err)
+}
+
+func (c *client) read() *message {
+	header, err := c.r.ReadMIMEHeader()
+	require.NoError(c.t, err)
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	require.NoError(c.t, err)
+	b := make([]byte, length)
+	_, err = io.ReadFull(c.r.R, b)
+	require.NoError(c.t, err)
+	var m message
+	require.NoError(c.t, json.Unmarshal(b, &m))
+	return &m
+}
+
+// request sends the request and returns the body of its response, which must be successful.
+func (c *client) request(command string, args interface{}, body interface{}) {
+	c.send(command, args)
+	m := c.read()
+	require.Equal(c.t, "response", m.Type)
+	require.Equal(c.t, command, m.Command)
+	require.True(c.t, m.Success, m.Message)
+	if body != nil {
+		require.NoError(c.t, json.Unmarshal(m.Body, body))
+	}
+}
+
+// expectEvent reads the next message, which must be the event, and returns its body.
+func (c *client) expectEvent(name string) json.RawMessage {
+	m := c.read()
+	require.Equal(c.t, "event", m.Type)
+	require.Equal(c.t, name, m.Event)
+	return m.Body
+}
+
+type variable struct {
+	Name, Value, Type string
+}
+
+type stackTrace struct {
+	StackFrames []struct {
+		Name   string
+		Source struct{ Name string }
+		Line   int
+	}
+	TotalFrames int
+}
+
+// connect starts serving a client connected to the debugger with a pipe, and initializes it.
+func connect(t *testing.T, debugger dap.Debugger) (*client, chan error) {
+	server, conn := net.Pipe()
+	t.Cleanup(func() { conn.Close() })
+	served := make(chan error)
+	go func() { served <- debugger.Serve(server) }()
+	c := &client{t: t, c: conn, r: textproto.NewReader(bufio.NewReader(conn))}
+
+	var capabilities map[string]bool
+	c.request("initialize", map[string]string{"adapterID": "wazero"}, &capabilities)
+	require.True(t, capabilities["supportsFunctionBreakpoints"])
+	c.expectEvent("initialized")
+	return c, served
+}
+
+func TestDebugger(t *testing.T) {
+	bin, err := wat.Compile([]byte(sumText))
+	require.NoError(t, err)
+
+	debugger, err := dap.NewDebugger(bin)
+	require.NoError(t, err)
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, debugger)
+
+	c, served := connect(t, debugger)
+
+	var breakpoints struct{ Breakpoints []struct{ Verified bool } }
+	c.request("setFunctionBreakpoints", map[string]interface{}{
+		"breakpoints": []map[string]string{{"name": "add"}, {"name": "missing"}},
+	}, &breakpoints)
+	require.Equal(t, 2, len(breakpoints.Breakpoints))
+	require.True(t, breakpoints.Breakpoints[0].Verified)
+	require.False(t, breakpoints.Breakpoints[1].Verified)
+	c.request("configurationDone", nil, nil)
+	debugger.WaitConfigured()
+
+	// Run the guest, which stops at the breakpoint until the client resumes it.
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	mod, err := r.Instantiate(ctx, bin)
+	require.NoError(t, err)
+	ran := make(chan error)
+	go func() {
+		_, err := mod.ExportedFunction("run").Call(ctx)
+		ran <- err
+	}()
+
+	var stopped struct{ Reason string }
+	require.NoError(t, json.Unmarshal(c.expectEvent("stopped"), &stopped))
+	require.Equal(t, "function breakpoint", stopped.Reason)
+
+	var trace stackTrace
+	c.request("stackTrace", map[string]int{"threadId": 1}, &trace)
+	require.Equal(t, 2, trace.TotalFrames)
+	require.Equal(t, "add", trace.StackFrames[0].Name)
+	require.Equal(t, "sum.$1", trace.StackFrames[1].Name)
+
+	// Frames out of range are ignored.
+	c.request("stackTrace", map[string]int{"threadId": 1, "startFrame": -1, "levels": 1}, &trace)
+	require.Equal(t, 1, len(trace.StackFrames))
+	require.Equal(t, "add", trace.StackFrames[0].Name)
+	c.request("stackTrace", map[string]int{"threadId": 1, "startFrame": 5}, &trace)
+	require.Equal(t, 0, len(trace.StackFrames))
+
+	var variables struct{ Variables []variable }
+	c.request("variables", map[string]int{"variablesReference": 1}, &variables)
+	require.Equal(t, []variable{{Name: "n", Value: "1", Type: "i32"}}, variables.Variables)
+
+	// Step out of add, to the caller.
+	c.request("stepOut", map[string]int{"threadId": 1}, nil)
+	require.NoError(t, json.Unmarshal(c.expectEvent("stopped"), &stopped))
+	require.Equal(t, "step", stopped.Reason)
+	c.request("stackTrace", map[string]int{"threadId": 1}, &trace)
+	require.Equal(t, 1, trace.TotalFrames)
+	c.request("variables", map[string]int{"variablesReference": 1}, &variables)
+	require.Equal(t, []variable{{Name: "i", Value: "1", Type: "i32"}}, variables.Variables)
+	c.request("variables", map[string]int{"variablesReference": 2}, &variables)
+	require.Equal(t, []variable{{Name: "$0", Value: "1", Type: "i32"}}, variables.Variables)
+
+	// Continue to the next call of add.
+	c.request("continue", map[string]int{"threadId": 1}, nil)
+	require.NoError(t, json.Unmarshal(c.expectEvent("stopped"), &stopped))
+	c.request("variables", map[string]int{"variablesReference": 1}, &variables)
+	require.Equal(t, []variable{{Name: "n", Value: "2", Type: "i32"}}, variables.Variables)
+
+	// Run to the end, once the breakpoints are cleared.
+	c.request("setFunctionBreakpoints", map[string]interface{}{"breakpoints": []interface{}{}}, nil)
+	c.request("continue", map[string]int{"threadId": 1}, nil)
+	require.NoError(t, <-ran)
+	b, ok := mod.Memory().Read(16, 1)
+	require.True(t, ok)
+	require.Equal(t, []byte{6}, b)
+
+	// The pipe is synchronous, so the events are read while sent.
+	go debugger.Exited(0)
+	c.expectEvent("exited")
+	c.expectEvent("terminated")
+	c.request("disconnect", nil, nil)
+	require.NoError(t, <-served)
+}
+
+func TestDebugger_sourceBreakpoint(t *testing.T) {
+	debugger, err := dap.NewDebugger(dwarftestdata.ZigWasm)
+	require.NoError(t, err)
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, debugger)
+	c, served := connect(t, debugger)
+
+	// Line 9 has no code, so the breakpoint moves to the next line.
+	var breakpoints struct {
+		Breakpoints []struct {
+			Verified bool
+			Line     int
+		}
+	}
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": "main.zig"},
+		"breakpoints": []map[string]int{{"line": 9}},
+	}, &breakpoints)
+	require.Equal(t, 1, len(breakpoints.Breakpoints))
+	require.True(t, breakpoints.Breakpoints[0].Verified)
+	require.Equal(t, 10, breakpoints.Breakpoints[0].Line)
+	c.request("configurationDone", nil, nil)
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+	ran := make(chan error)
+	go func() {
+		_, err := r.Instantiate(ctx, dwarftestdata.ZigWasm)
+		ran <- err
+	}()
+
+	var stopped struct{ Reason string }
+	require.NoError(t, json.Unmarshal(c.expectEvent("stopped"), &stopped))
+	require.Equal(t, "breakpoint", stopped.Reason)
+	var trace stackTrace
+	c.request("stackTrace", map[string]int{"threadId": 1}, &trace)
+	require.Equal(t, "main.zig", trace.StackFrames[0].Source.Name)
+	require.Equal(t, 10, trace.StackFrames[0].Line)
+
+	// Stepping in leaves the line.
+	c.request("stepIn", map[string]int{"threadId": 1}, nil)
+	require.NoError(t, json.Unmarshal(c.expectEvent("stopped"), &stopped))
+	require.Equal(t, "step", stopped.Reason)
+	c.request("stackTrace", map[string]int{"threadId": 1}, &trace)
+	require.NotEqual(t, 10, trace.StackFrames[0].Line)
+
+	// Disconnecting resumes the guest, which panics.
+	c.request("disconnect", nil, nil)
+	require.NoError(t, <-served)
+	require.Error(t, <-ran)
+}
+
+func TestDebugger_invalidContentLength(t *testing.T) {
+	bin, err := wat.Compile([]byte(sumText))
+	require.NoError(t, err)
+	debugger, err := dap.NewDebugger(bin)
+	require.NoError(t, err)
+
+	for _, length := range []string{"-1", "16777217"} {
+		server, conn := net.Pipe()
+		served := make(chan error)
+		go func() { served <- debugger.Serve(server) }()
+		_, err = fmt.Fprintf(conn, "Content-Length: %s\r\n\r\n", length)
+		require.NoError(t, err)
+		require.EqualError(t, <-served, "invalid Content-Length: "+length)
+		conn.Close()
+	}
+}
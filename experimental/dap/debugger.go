@@ -0,0 +1,400 @@
+// Package dap debugs guests run by the interpreter, serving the Debug Adapter Protocol (DAP) used by editors such as
+// VS Code to set breakpoints, step through code and inspect variables.
+//
+// See https://microsoft.github.io/debug-adapter-protocol/specification
+package dap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binary"
+	"github.com/AR1011/wazero/internal/wasmdebug"
+)
+
+// threadID is the only thread reported to the client, as calls into the guest are debugged as one.
+const threadID = 1
+
+// Debugger is an experimental.FunctionListenerFactory which stops the guest at breakpoints, and serves a client of
+// the Debug Adapter Protocol to control it. Add it to the context used to compile the module with the interpreter,
+// and run the module once the client configured the debugger:
+//
+//	debugger, err := dap.NewDebugger(wasm)
+//	if err != nil {
+//		log.Panicln(err)
+//	}
+//	ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, debugger)
+//	go debugger.Serve(conn) // e.g. a net.Conn accepted from the client
+//	debugger.WaitConfigured()
+//	// Compile, instantiate and run the module with ctx...
+//	debugger.Exited(exitCode)
+//
+// # Notes
+//
+//   - Only the interpreter stops at instructions, see experimental.InstructionListener.
+//   - Breakpoints are set on source lines, which requires DWARF sections, or on function names.
+//   - Locals are only available in the innermost frame.
+//   - Calls into the guest are debugged as a single thread, so debug a single goroutine.
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type Debugger interface {
+	experimental.FunctionListenerFactory
+
+	// Serve handles the requests of a client read from conn, until it disconnects or conn is closed, returning nil
+	// in both cases. The guest only stops at breakpoints while a client is connected.
+	Serve(conn io.ReadWriter) error
+
+	// WaitConfigured blocks until the client is done setting breakpoints, or disconnects.
+	WaitConfigured()
+
+	// Exited notifies the client that the guest exited with the code, which ends the debugging session.
+	Exited(exitCode uint32)
+}
+
+// NewDebugger returns a Debugger for the module in the WebAssembly binary format.
+func NewDebugger(wasmBinary []byte) (Debugger, error) {
+	m, err := binary.DecodeModule(wasmBinary, wasm.CoreFeaturesAll, wasm.MemoryLimitPages, false, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding wasm binary: %w", err)
+	}
+	return &debugger{
+		module:              m,
+		lines:               m.DWARFLines.LineEntries(),
+		configured:          make(chan struct{}),
+		sourceBreakpoints:   map[string][]uint64{},
+		breakpoints:         map[uint64]struct{}{},
+		functionBreakpoints: map[string]struct{}{},
+	}, nil
+}
+
+// stepMode is how the guest resumes after it stopped.
+type stepMode byte
+
+const (
+	// stepContinue runs until a breakpoint.
+	stepContinue stepMode = iota
+	// stepIn stops at the next line, including in a called function.
+	stepIn
+	// stepOver stops at the next line of the function, or when it returns.
+	stepOver
+	// stepOut stops when the function returns.
+	stepOut
+)
+
+// position is a source line, or the offset of an instruction when it has no line.
+type position struct {
+	file   string
+	line   int
+	offset uint64
+}
+
+type debugger struct {
+	module     *wasm.Module
+	lines      []wasmdebug.LineEntry
+	configured chan struct{}
+	configure  sync.Once
+
+	mux sync.Mutex
+	// conn is the connected client, or nil.
+	conn *conn
+	// sourceBreakpoints are the offsets of the line breakpoints, by source path.
+	sourceBreakpoints map[string][]uint64
+	// breakpoints are the offsets of all line breakpoints.
+	breakpoints         map[uint64]struct{}
+	functionBreakpoints map[string]struct{}
+
+	// depth is the number of guest functions on the call stack.
+	depth int
+	// pending is the reason to stop at the next instruction, e.g. "pause", or empty.
+	pending string
+	// step is how the guest resumed, from the position at the depth where it stopped.
+	step      stepMode
+	stepDepth int
+	stepFrom  position
+	// leaving is true until the guest runs an instruction which isn't at stepFrom.
+	leaving bool
+	// stopped is the state of the guest while it is stopped, or nil.
+	stopped *stoppedState
+	// resuming is closed to resume the guest by resumeNow.
+	resuming chan struct{}
+}
+
+// stoppedState is a copy of the state of the guest when it stopped, as the arguments of the listener are only valid
+// during the call.
+type stoppedState struct {
+	mod    api.Module
+	frames []frame
+	// locals are the locals of the innermost frame.
+	locals []uint64
+	// resume is closed to resume the guest.
+	resume chan struct{}
+}
+
+// frame is a frame of the call stack.
+type frame struct {
+	def    api.FunctionDefinition
+	offset uint64
+}
+
+// NewFunctionListener implements the same method as documented on experimental.FunctionListenerFactory.
+func (d *debugger) NewFunctionListener(def api.FunctionDefinition) experimental.FunctionListener {
+	if def.GoFunction() != nil {
+		return nil
+	}
+	return &listener{d: d}
+}
+
+// listener notifies the debugger of the calls and instructions of a guest function.
+type listener struct {
+	d *debugger
+}
+
+// Before implements the same method as documented on experimental.FunctionListener.
+func (l *listener) Before(_ context.Context, _ api.Module, def api.FunctionDefinition, _ []uint64, _ experimental.StackIterator) {
+	d := l.d
+	d.mux.Lock()
+	d.depth++
+	if d.conn != nil && d.isFunctionBreakpoint(def) {
+		d.pending = "function breakpoint"
+	}
+	d.mux.Unlock()
+}
+
+// After implements the same method as documented on experimental.FunctionListener.
+func (l *listener) After(context.Context, api.Module, api.FunctionDefinition, []uint64) {
+	l.d.mux.Lock()
+	l.d.depth--
+	l.d.mux.Unlock()
+}
+
+// Abort implements the same method as documented on experimental.FunctionListener.
+func (l *listener) Abort(context.Context, api.Module, api.FunctionDefinition, error) {
+	l.d.mux.Lock()
+	l.d.depth--
+	l.d.mux.Unlock()
+}
+
+// Instruction implements the same method as documented on experimental.InstructionListener.
+func (l *listener) Instruction(_ context.Context, mod api.Module, _ api.FunctionDefinition, offset uint64, locals []uint64, si experimental.StackIterator) {
+	d := l.d
+	d.mux.Lock()
+	reason := d.stopReason(offset)
+	if reason == "" {
+		d.mux.Unlock()
+		return
+	}
+
+	stopped := &stoppedState{mod: mod, locals: append([]uint64(nil), locals...), resume: make(chan struct{})}
+	for si.Next() {
+		fn := si.Function()
+		f := frame{def: fn.Definition()}
+		if f.def.GoFunction() == nil {
+			f.offset = fn.SourceOffsetForPC(si.ProgramCounter())
+		}
+		stopped.frames = append(stopped.frames, f)
+	}
+	d.stopped, d.pending = stopped, ""
+	c := d.conn
+	d.mux.Unlock()
+
+	// Wait until the client resumes the guest, or disconnects.
+	_ = c.event("stopped", &stoppedBody{Reason: reason, ThreadID: threadID, AllThreadsStopped: true})
+	<-stopped.resume
+}
+
+// stopReason returns why the guest stops at the instruction, or empty to keep running.
+func (d *debugger) stopReason(offset uint64) string {
+	if d.conn == nil {
+		return ""
+	}
+	if d.pending != "" {
+		return d.pending
+	}
+
+	// The guest is leaving the position it resumed from until it runs an instruction elsewhere.
+	onResumePosition := d.leaving && d.depth == d.stepDepth && d.position(offset) == d.stepFrom
+	if !onResumePosition {
+		d.leaving = false
+	}
+
+	switch d.step {
+	case stepIn:
+		if !onResumePosition {
+			return "step"
+		}
+	case stepOver:
+		if d.depth < d.stepDepth || (d.depth == d.stepDepth && !onResumePosition) {
+			return "step"
+		}
+	case stepOut:
+		if d.depth < d.stepDepth {
+			return "step"
+		}
+	}
+
+	// A line may have several breakpoint addresses, so don't stop again on the line the guest resumed from.
+	if _, ok := d.breakpoints[offset]; ok && !onResumePosition {
+		return "breakpoint"
+	}
+	return ""
+}
+
+// resume prepares resuming the stopped guest with the given step mode. It resumes once the response is sent, see
+// resumeNow, so that the response comes before a later stopped event.
+func (d *debugger) resume(step stepMode) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	stopped := d.stopped
+	if stopped == nil {
+		return errNotStopped
+	}
+	d.step, d.stepDepth = step, d.depth
+	d.stepFrom, d.leaving = d.position(stopped.frames[0].offset), true
+	d.stopped, d.resuming = nil, stopped.resume
+	return nil
+}
+
+// resumeNow resumes the guest if prepared by resume.
+func (d *debugger) resumeNow() {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.resuming != nil {
+		close(d.resuming)
+		d.resuming = nil
+	}
+}
+
+// position returns the position of the instruction at the offset.
+func (d *debugger) position(offset uint64) position {
+	if l, ok := d.line(offset); ok {
+		return position{file: l.File, line: l.Line}
+	}
+	return position{offset: offset}
+}
+
+// line returns the row of the line table containing the instruction at the offset in the code section, which is
+// the last one starting before it.
+func (d *debugger) line(offset uint64) (wasmdebug.LineEntry, bool) {
+	i := sort.Search(len(d.lines), func(i int) bool { return d.lines[i].Address > offset })
+	if i == 0 {
+		return wasmdebug.LineEntry{}, false
+	}
+	return d.lines[i-1], true
+}
+
+// isFunctionBreakpoint returns true if any name of the function has a breakpoint.
+func (d *debugger) isFunctionBreakpoint(def api.FunctionDefinition) bool {
+	if len(d.functionBreakpoints) == 0 {
+		return false
+	}
+	for _, name := range functionNames(def.Name(), def.ExportNames()) {
+		if _, ok := d.functionBreakpoints[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// functionNames returns the names a function breakpoint can use: the name in the name section and the exports.
+func functionNames(name string, exports []string) []string {
+	if name == "" {
+		return exports
+	}
+	return append([]string{name}, exports...)
+}
+
+// setSourceBreakpoints replaces the breakpoints of the source file, returning them resolved to the lines with code.
+func (d *debugger) setSourceBreakpoints(path string, lines []int) []breakpoint {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	ret := make([]breakpoint, len(lines))
+	var offsets []uint64
+	for i, line := range lines {
+		resolved, lineOffsets := d.resolveLine(path, line)
+		if resolved == 0 {
+			ret[i] = breakpoint{Line: line, Message: "no code at this line"}
+			continue
+		}
+		ret[i] = breakpoint{Verified: true, Line: resolved}
+		offsets = append(offsets, lineOffsets...)
+	}
+	d.sourceBreakpoints[path] = offsets
+
+	d.breakpoints = map[uint64]struct{}{}
+	for _, offsets := range d.sourceBreakpoints {
+		for _, offset := range offsets {
+			d.breakpoints[offset] = struct{}{}
+		}
+	}
+	return ret
+}
+
+// resolveLine returns the first line at or after the given one which has code in the file, and the offsets where it
+// starts, or zero if there is none.
+func (d *debugger) resolveLine(file string, line int) (int, []uint64) {
+	resolved := 0
+	for _, l := range d.lines {
+		if l.Line >= line && (resolved == 0 || l.Line < resolved) && sameFile(l.File, file) {
+			resolved = l.Line
+		}
+	}
+	if resolved == 0 {
+		return 0, nil
+	}
+	var offsets []uint64
+	for _, l := range d.lines {
+		if l.Line == resolved && sameFile(l.File, file) {
+			offsets = append(offsets, l.Address)
+		}
+	}
+	return resolved, offsets
+}
+
+// sameFile returns true if the paths are the same, or one is relative and a suffix of the other, as DWARF paths may
+// be relative to the directory of the compilation.
+func sameFile(a, b string) bool {
+	a, b = path.Clean(toSlash(a)), path.Clean(toSlash(b))
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	return a == b || strings.HasSuffix(a, "/"+b)
+}
+
+// toSlash is like filepath.ToSlash, but for paths of any platform, as the guest may have been built on another.
+func toSlash(p string) string {
+	return strings.ReplaceAll(p, "\\", "/")
+}
+
+// setFunctionBreakpoints replaces the function breakpoints, returning whether the module has each function.
+func (d *debugger) setFunctionBreakpoints(names []string) []breakpoint {
+	known := map[string]struct{}{}
+	for i := range d.module.FunctionSection {
+		def := d.module.FunctionDefinition(d.module.ImportFunctionCount + wasm.Index(i))
+		for _, name := range functionNames(def.Name(), def.ExportNames()) {
+			known[name] = struct{}{}
+		}
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.functionBreakpoints = map[string]struct{}{}
+	ret := make([]breakpoint, len(names))
+	for i, name := range names {
+		d.functionBreakpoints[name] = struct{}{}
+		if _, ok := known[name]; ok {
+			ret[i] = breakpoint{Verified: true}
+		} else {
+			ret[i] = breakpoint{Message: "no function named " + name}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,241 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// request is a message sent by the client, whose arguments depend on its command.
+type request struct {
+	Seq       int             `json:"seq"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// response is the reply to a request, whose body depends on its command.
+type response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+// event is a message sent to the client without a request, e.g. when the guest stops.
+type event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// maxContentLength limits the size of a request, so that a bad Content-Length doesn't allocate unbounded memory.
+const maxContentLength = 16 << 20
+
+// conn reads and writes messages, each preceded by a Content-Length header.
+type conn struct {
+	r *textproto.Reader
+
+	mux sync.Mutex // guards the fields below, as events are sent by the guest.
+	w   io.Writer
+	seq int
+}
+
+func newConn(rw io.ReadWriter) *conn {
+	return &conn{r: textproto.NewReader(bufio.NewReader(rw)), w: rw}
+}
+
+// readRequest reads the next request, or returns io.EOF when the client disconnected.
+func (c *conn) readRequest() (*request, error) {
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %w", err)
+	} else if length < 0 || length > maxContentLength {
+		return nil, fmt.Errorf("invalid Content-Length: %d", length)
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(c.r.R, body); err != nil {
+		return nil, err
+	}
+	var req request
+	if err = json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	return &req, nil
+}
+
+func (c *conn) respond(req *request, body interface{}, err error) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	resp := &response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	c.seq++
+	resp.Seq = c.seq
+	return c.write(resp)
+}
+
+func (c *conn) event(name string, body interface{}) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.seq++
+	return c.write(&event{Seq: c.seq, Type: "event", Event: name, Body: body})
+}
+
+func (c *conn) write(msg interface{}) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(b)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(b)
+	return err
+}
+
+// The following are the types of the arguments and bodies used, with only the fields used.
+//
+// See https://microsoft.github.io/debug-adapter-protocol/specification
+
+type capabilities struct {
+	SupportsConfigurationDoneRequest bool `json:"supportsConfigurationDoneRequest"`
+	SupportsFunctionBreakpoints      bool `json:"supportsFunctionBreakpoints"`
+	SupportsReadMemoryRequest        bool `json:"supportsReadMemoryRequest"`
+}
+
+type launchArguments struct {
+	StopOnEntry bool `json:"stopOnEntry"`
+}
+
+type source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+type sourceBreakpoint struct {
+	Line int `json:"line"`
+}
+
+type setBreakpointsArguments struct {
+	Source      source             `json:"source"`
+	Breakpoints []sourceBreakpoint `json:"breakpoints"`
+}
+
+type functionBreakpoint struct {
+	Name string `json:"name"`
+}
+
+type setFunctionBreakpointsArguments struct {
+	Breakpoints []functionBreakpoint `json:"breakpoints"`
+}
+
+type breakpoint struct {
+	Verified bool   `json:"verified"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+type breakpointsBody struct {
+	Breakpoints []breakpoint `json:"breakpoints"`
+}
+
+type thread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type threadsBody struct {
+	Threads []thread `json:"threads"`
+}
+
+type stackTraceArguments struct {
+	StartFrame int `json:"startFrame"`
+	Levels     int `json:"levels"`
+}
+
+type stackFrame struct {
+	ID                          int     `json:"id"`
+	Name                        string  `json:"name"`
+	Source                      *source `json:"source,omitempty"`
+	Line                        int     `json:"line"`
+	Column                      int     `json:"column"`
+	InstructionPointerReference string  `json:"instructionPointerReference,omitempty"`
+}
+
+type stackTraceBody struct {
+	StackFrames []stackFrame `json:"stackFrames"`
+	TotalFrames int          `json:"totalFrames"`
+}
+
+type scopesArguments struct {
+	FrameID int `json:"frameId"`
+}
+
+type scope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type scopesBody struct {
+	Scopes []scope `json:"scopes"`
+}
+
+type variablesArguments struct {
+	VariablesReference int `json:"variablesReference"`
+}
+
+type variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type"`
+	VariablesReference int    `json:"variablesReference"`
+	MemoryReference    string `json:"memoryReference,omitempty"`
+}
+
+type variablesBody struct {
+	Variables []variable `json:"variables"`
+}
+
+type readMemoryArguments struct {
+	MemoryReference string `json:"memoryReference"`
+	Offset          int64  `json:"offset"`
+	Count           int    `json:"count"`
+}
+
+type readMemoryBody struct {
+	Address         string `json:"address"`
+	Data            []byte `json:"data,omitempty"` // base64 encoded by encoding/json
+	UnreadableBytes int    `json:"unreadableBytes,omitempty"`
+}
+
+type stoppedBody struct {
+	Reason            string `json:"reason"`
+	Description       string `json:"description,omitempty"`
+	ThreadID          int    `json:"threadId"`
+	AllThreadsStopped bool   `json:"allThreadsStopped"`
+}
+
+type continueBody struct {
+	AllThreadsContinued bool `json:"allThreadsContinued"`
+}
+
+type exitedBody struct {
+	ExitCode uint32 `json:"exitCode"`
+}
@@ -0,0 +1,369 @@
+package dap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"path"
+	"strconv"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/wasm"
+)
+
+var errNotStopped = errors.New("not stopped")
+
+// References of the variables of the scopes. The locals are those of the innermost frame.
+const (
+	localsReference = iota + 1
+	globalsReference
+)
+
+// Serve implements the same method as documented on Debugger.
+func (d *debugger) Serve(rw io.ReadWriter) error {
+	c := newConn(rw)
+	d.mux.Lock()
+	if d.conn != nil {
+		d.mux.Unlock()
+		return errors.New("already serving a client")
+	}
+	d.conn = c
+	d.mux.Unlock()
+	defer d.disconnect()
+
+	for {
+		req, err := c.readRequest()
+		if err == io.EOF || errors.Is(err, net.ErrClosed) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		body, err := d.handle(req)
+		if err = c.respond(req, body, err); err != nil {
+			return err
+		}
+		d.resumeNow()
+		switch req.Command {
+		case "initialize":
+			// Breakpoints are set after this, until the configurationDone request.
+			if err = c.event("initialized", nil); err != nil {
+				return err
+			}
+		case "disconnect":
+			return nil
+		}
+	}
+}
+
+// handle handles the request, returning the body of the response.
+func (d *debugger) handle(req *request) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		return &capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsFunctionBreakpoints:      true,
+			SupportsReadMemoryRequest:        true,
+		}, nil
+	case "launch", "attach":
+		// The guest is already launched, and waits for the configurationDone request.
+		var args launchArguments
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+		if args.StopOnEntry {
+			d.mux.Lock()
+			d.pending = "entry"
+			d.mux.Unlock()
+		}
+		return nil, nil
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+		lines := make([]int, len(args.Breakpoints))
+		for i, b := range args.Breakpoints {
+			lines[i] = b.Line
+		}
+		return &breakpointsBody{Breakpoints: d.setSourceBreakpoints(args.Source.Path, lines)}, nil
+	case "setFunctionBreakpoints":
+		var args setFunctionBreakpointsArguments
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+		names := make([]string, len(args.Breakpoints))
+		for i, b := range args.Breakpoints {
+			names[i] = b.Name
+		}
+		return &breakpointsBody{Breakpoints: d.setFunctionBreakpoints(names)}, nil
+	case "setExceptionBreakpoints":
+		return &breakpointsBody{}, nil
+	case "configurationDone":
+		d.configure.Do(func() { close(d.configured) })
+		return nil, nil
+	case "threads":
+		return &threadsBody{Threads: []thread{{ID: threadID, Name: "main"}}}, nil
+	case "stackTrace":
+		var args stackTraceArguments
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+		return d.stackTrace(args.StartFrame, args.Levels)
+	case "scopes":
+		var args scopesArguments
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+		scopes := []scope{{Name: "Globals", VariablesReference: globalsReference}}
+		if args.FrameID == 0 {
+			scopes = append([]scope{{Name: "Locals", VariablesReference: localsReference}}, scopes...)
+		}
+		return &scopesBody{Scopes: scopes}, nil
+	case "variables":
+		var args variablesArguments
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+		return d.variables(args.VariablesReference)
+	case "readMemory":
+		var args readMemoryArguments
+		if err := unmarshalArguments(req, &args); err != nil {
+			return nil, err
+		}
+		return d.readMemory(args.MemoryReference, args.Offset, args.Count)
+	case "continue":
+		return &continueBody{AllThreadsContinued: true}, d.resume(stepContinue)
+	case "next":
+		return nil, d.resume(stepOver)
+	case "stepIn":
+		return nil, d.resume(stepIn)
+	case "stepOut":
+		return nil, d.resume(stepOut)
+	case "pause":
+		d.mux.Lock()
+		d.pending = "pause"
+		d.mux.Unlock()
+		return nil, nil
+	case "disconnect":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported command %q", req.Command)
+	}
+}
+
+func unmarshalArguments(req *request, args interface{}) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(req.Arguments, args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// disconnect forgets the client, and resumes the guest without breakpoints.
+func (d *debugger) disconnect() {
+	d.configure.Do(func() { close(d.configured) })
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	d.conn = nil
+	d.sourceBreakpoints = map[string][]uint64{}
+	d.breakpoints = map[uint64]struct{}{}
+	d.functionBreakpoints = map[string]struct{}{}
+	d.pending, d.step = "", stepContinue
+	if d.stopped != nil {
+		close(d.stopped.resume)
+		d.stopped = nil
+	}
+	if d.resuming != nil {
+		close(d.resuming)
+		d.resuming = nil
+	}
+}
+
+// WaitConfigured implements the same method as documented on Debugger.
+func (d *debugger) WaitConfigured() {
+	<-d.configured
+}
+
+// Exited implements the same method as documented on Debugger.
+func (d *debugger) Exited(exitCode uint32) {
+	d.mux.Lock()
+	c := d.conn
+	d.mux.Unlock()
+	if c != nil {
+		_ = c.event("exited", &exitedBody{ExitCode: exitCode})
+		_ = c.event("terminated", nil)
+	}
+}
+
+func (d *debugger) stackTrace(start, levels int) (*stackTraceBody, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	stopped := d.stopped
+	if stopped == nil {
+		return nil, errNotStopped
+	}
+
+	frames := stopped.frames
+	body := &stackTraceBody{StackFrames: []stackFrame{}, TotalFrames: len(frames)}
+	end := len(frames)
+	if start < 0 {
+		start = 0
+	} else if start > end {
+		start = end
+	}
+	if levels > 0 && start+levels < end {
+		end = start + levels
+	}
+	for i := start; i < end; i++ {
+		f := frames[i]
+		sf := stackFrame{ID: i, Name: f.def.Name()}
+		if sf.Name == "" {
+			sf.Name = f.def.DebugName()
+		}
+		if f.def.GoFunction() == nil {
+			sf.InstructionPointerReference = fmt.Sprintf("%#x", f.offset)
+			if l, ok := d.line(f.offset); ok {
+				sf.Source = &source{Name: path.Base(toSlash(l.File)), Path: l.File}
+				sf.Line, sf.Column = l.Line, l.Column
+			}
+		}
+		body.StackFrames = append(body.StackFrames, sf)
+	}
+	return body, nil
+}
+
+func (d *debugger) variables(reference int) (*variablesBody, error) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	stopped := d.stopped
+	if stopped == nil {
+		return nil, errNotStopped
+	}
+
+	body := &variablesBody{Variables: []variable{}}
+	switch reference {
+	case localsReference:
+		def := stopped.frames[0].def
+		types := append(append([]api.ValueType(nil), def.ParamTypes()...), d.localTypes(def.Index())...)
+		names := d.localNames(def.Index())
+		values := stopped.locals
+		for i, t := range types {
+			var v variable
+			v, values = newVariable(names[wasm.Index(i)], i, t, values)
+			body.Variables = append(body.Variables, v)
+		}
+	case globalsReference:
+		m, ok := stopped.mod.(*wasm.ModuleInstance)
+		if !ok {
+			break
+		}
+		names := map[wasm.Index]string{}
+		for _, e := range d.module.ExportSection {
+			if e.Type == wasm.ExternTypeGlobal {
+				names[e.Index] = e.Name
+			}
+		}
+		for i, g := range m.Globals {
+			lo, hi := g.Value()
+			v, _ := newVariable(names[wasm.Index(i)], i, g.Type.ValType, []uint64{lo, hi})
+			body.Variables = append(body.Variables, v)
+		}
+	default:
+		return nil, fmt.Errorf("unknown variables reference %d", reference)
+	}
+	return body, nil
+}
+
+// localTypes returns the types of the non-param locals of the function.
+func (d *debugger) localTypes(index wasm.Index) []api.ValueType {
+	if index < d.module.ImportFunctionCount {
+		return nil
+	}
+	return d.module.CodeSection[index-d.module.ImportFunctionCount].LocalTypes
+}
+
+// localNames returns the names of the locals of the function in the name section, by local index.
+func (d *debugger) localNames(index wasm.Index) map[wasm.Index]string {
+	names := map[wasm.Index]string{}
+	if d.module.NameSection == nil {
+		return names
+	}
+	for _, f := range d.module.NameSection.LocalNames {
+		if f.Index == index {
+			for _, n := range f.NameMap {
+				names[n.Index] = n.Name
+			}
+		}
+	}
+	return names
+}
+
+// newVariable returns the variable of the given type taken from the beginning of values, and the remaining values.
+// Variables without a name are named by their index, like in the text format.
+func newVariable(name string, index int, t api.ValueType, values []uint64) (variable, []uint64) {
+	if name == "" {
+		name = "$" + strconv.Itoa(index)
+	}
+	v := variable{Name: name, Type: wasm.ValueTypeName(t)}
+	if len(values) == 0 {
+		return v, values
+	}
+	switch t {
+	case api.ValueTypeI32:
+		v.Value = strconv.FormatInt(int64(int32(values[0])), 10)
+		// An i32 is often an address in memory, so let it be viewed.
+		v.MemoryReference = fmt.Sprintf("%#x", uint32(values[0]))
+	case api.ValueTypeI64:
+		v.Value = strconv.FormatInt(int64(values[0]), 10)
+	case api.ValueTypeF32:
+		v.Value = strconv.FormatFloat(float64(math.Float32frombits(uint32(values[0]))), 'g', -1, 32)
+	case api.ValueTypeF64:
+		v.Value = strconv.FormatFloat(math.Float64frombits(values[0]), 'g', -1, 64)
+	case wasm.ValueTypeV128:
+		if len(values) > 1 {
+			v.Value = fmt.Sprintf("%#016x%016x", values[1], values[0])
+			return v, values[2:]
+		}
+	default: // references
+		v.Value = fmt.Sprintf("%#x", values[0])
+		if values[0] == 0 {
+			v.Value = "null"
+		}
+	}
+	return v, values[1:]
+}
+
+// readMemory reads count bytes of the memory from the address of the reference plus the offset.
+func (d *debugger) readMemory(reference string, offset int64, count int) (*readMemoryBody, error) {
+	address, err := strconv.ParseUint(reference, 0, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid memory reference %q", reference)
+	}
+	address += uint64(offset)
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	stopped := d.stopped
+	if stopped == nil {
+		return nil, errNotStopped
+	}
+	body := &readMemoryBody{Address: fmt.Sprintf("%#x", address), UnreadableBytes: count}
+	mem := stopped.mod.Memory()
+	if mem == nil || address >= uint64(mem.Size()) || count <= 0 {
+		return body, nil
+	}
+	n := count
+	if available := uint64(mem.Size()) - address; uint64(n) > available {
+		n = int(available)
+	}
+	data, _ := mem.Read(uint32(address), uint32(n))
+	body.Data = append([]byte(nil), data...)
+	body.UnreadableBytes = count - n
+	return body, nil
+}
@@ -0,0 +1,22 @@
+package experimental
+
+import "context"
+
+// EpochDeadlineKey is a context.Context Value key. Its associated value should be an uint64.
+type EpochDeadlineKey struct{}
+
+// WithEpochDeadline sets the deadline for the calls of api.Function with the returned context.Context to the given
+// number of ticks after the epoch of the wazero.Runtime at the start of each call. The deadline belongs to the call
+// only: the deadline set by api.Module SetEpochDeadline is unchanged and still applies, so the call traps at whichever
+// is reached first.
+//
+// For example, this bounds each call without a call to SetEpochDeadline before it:
+//
+//	ctx = experimental.WithEpochDeadline(ctx, 10)
+//	_, err := mod.ExportedFunction("handle").Call(ctx)
+//
+// Note: This has no effect unless the wazero.RuntimeConfig has been configured with WithEpochInterruption. A call
+// made from a host function during the call has its own deadline, which doesn't change the one of the outer call.
+func WithEpochDeadline(ctx context.Context, ticks uint64) context.Context {
+	return context.WithValue(ctx, EpochDeadlineKey{}, ticks)
+}
@@ -94,6 +94,61 @@ type FunctionListener interface {
 	Abort(ctx context.Context, mod api.Module, def api.FunctionDefinition, err error)
 }
 
+// BlockListener is a FunctionListener which is also notified when a block of
+// instructions of the function starts running, e.g. to collect code coverage.
+// A block is a range of instructions which is entered at the beginning, such
+// as the start of the function, a branch target or the instruction after a
+// conditional branch.
+//
+// Note: Only the interpreter notifies blocks. Other engines treat this as a
+// FunctionListener.
+type BlockListener interface {
+	FunctionListener
+
+	// Block is invoked before the instructions of a block run.
+	//
+	// # Params
+	//
+	//   - ctx: the context of the function.
+	//   - mod: the module of the function.
+	//   - def: the function definition.
+	//   - start: the offset of the first instruction in the Code section.
+	//   - end: the offset after the last instruction in the Code section.
+	//
+	// Note: Instructions may not run to the end of the block, e.g. when one
+	// of them traps.
+	Block(ctx context.Context, mod api.Module, def api.FunctionDefinition, start, end uint64)
+}
+
+// InstructionListener is a FunctionListener which is also notified before
+// each instruction of the function runs, e.g. to implement a debugger which
+// stops at breakpoints.
+//
+// Note: Only the interpreter notifies instructions. Other engines treat this
+// as a FunctionListener.
+type InstructionListener interface {
+	FunctionListener
+
+	// Instruction is invoked before an instruction runs.
+	//
+	// # Params
+	//
+	//   - ctx: the context of the function.
+	//   - mod: the module of the function.
+	//   - def: the function definition.
+	//   - offset: the offset of the instruction in the Code section.
+	//   - locals: the params of the function followed by its other locals,
+	//     in the same encoding as the params of Before. A v128 local takes
+	//     two values, the lower 64 bits first.
+	//   - stackIterator: iterator on the call stack, which starts with this
+	//     function at the instruction.
+	//
+	// Note: locals and stackIterator are only valid during the call, so
+	// they must be copied to be retained. locals may be modified, which
+	// modifies the locals of the function.
+	Instruction(ctx context.Context, mod api.Module, def api.FunctionDefinition, offset uint64, locals []uint64, stackIterator StackIterator)
+}
+
 // FunctionListenerFunc is a function type implementing the FunctionListener
 // interface, making it possible to use regular functions and methods as
 // listeners of function invocation.
@@ -161,7 +216,24 @@ func (multi multiFunctionListenerFactory) NewFunctionListener(def api.FunctionDe
 	case 1:
 		return lstns[0]
 	default:
-		return &multiFunctionListener{lstns: lstns}
+		multi := &multiFunctionListener{lstns: lstns}
+		// Only expose the interfaces implemented by a listener, as engines do more work to notify them.
+		var blocks, instructions bool
+		for _, lstn := range lstns {
+			_, ok := lstn.(BlockListener)
+			blocks = blocks || ok
+			_, ok = lstn.(InstructionListener)
+			instructions = instructions || ok
+		}
+		switch {
+		case blocks && instructions:
+			return &multiBlockInstructionListener{multiInstructionListener{multi}}
+		case instructions:
+			return &multiInstructionListener{multi}
+		case blocks:
+			return &multiBlockListener{multi}
+		}
+		return multi
 	}
 }
 
@@ -190,6 +262,46 @@ func (multi *multiFunctionListener) Abort(ctx context.Context, mod api.Module, d
 	}
 }
 
+// multiBlockListener is a multiFunctionListener which notifies blocks to the
+// listeners implementing BlockListener.
+type multiBlockListener struct {
+	*multiFunctionListener
+}
+
+func (multi multiBlockListener) Block(ctx context.Context, mod api.Module, def api.FunctionDefinition, start, end uint64) {
+	for _, lstn := range multi.lstns {
+		if bl, ok := lstn.(BlockListener); ok {
+			bl.Block(ctx, mod, def, start, end)
+		}
+	}
+}
+
+// multiInstructionListener is a multiFunctionListener which notifies
+// instructions to the listeners implementing InstructionListener.
+type multiInstructionListener struct {
+	*multiFunctionListener
+}
+
+func (multi *multiInstructionListener) Instruction(ctx context.Context, mod api.Module, def api.FunctionDefinition, offset uint64, locals []uint64, si StackIterator) {
+	multi.stack.base = si
+	for _, lstn := range multi.lstns {
+		if il, ok := lstn.(InstructionListener); ok {
+			multi.stack.index = -1
+			il.Instruction(ctx, mod, def, offset, locals, &multi.stack)
+		}
+	}
+}
+
+// multiBlockInstructionListener is a multiInstructionListener which also
+// notifies blocks, when listeners implement either interface.
+type multiBlockInstructionListener struct {
+	multiInstructionListener
+}
+
+func (multi *multiBlockInstructionListener) Block(ctx context.Context, mod api.Module, def api.FunctionDefinition, start, end uint64) {
+	multiBlockListener{multi.multiFunctionListener}.Block(ctx, mod, def, start, end)
+}
+
 type stackIterator struct {
 	base  StackIterator
 	index int
@@ -9,9 +9,9 @@ import (
 	"github.com/AR1011/wazero/api"
 	"github.com/AR1011/wazero/experimental"
 	"github.com/AR1011/wazero/experimental/wazerotest"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 // compile-time check to ensure recorder implements FunctionListenerFactory
@@ -150,6 +150,138 @@ func TestMultiFunctionListenerFactory(t *testing.T) {
 	}
 }
 
+// blockListener records the blocks it is notified of.
+type blockListener struct {
+	experimental.FunctionListenerFunc
+	blocks [][2]uint64
+}
+
+func (l *blockListener) Block(_ context.Context, _ api.Module, _ api.FunctionDefinition, start, end uint64) {
+	l.blocks = append(l.blocks, [2]uint64{start, end})
+}
+
+func TestMultiFunctionListenerFactory_BlockListener(t *testing.T) {
+	module := wazerotest.NewModule(nil,
+		wazerotest.NewFunction(func(ctx context.Context, mod api.Module, value int32) {}),
+	)
+	function := module.Function(0).Definition()
+	noop := experimental.FunctionListenerFunc(func(context.Context, api.Module, api.FunctionDefinition, []uint64, experimental.StackIterator) {})
+
+	t.Run("without block listeners", func(t *testing.T) {
+		factory := experimental.MultiFunctionListenerFactory(
+			experimental.FunctionListenerFactoryFunc(func(api.FunctionDefinition) experimental.FunctionListener { return noop }),
+			experimental.FunctionListenerFactoryFunc(func(api.FunctionDefinition) experimental.FunctionListener { return noop }),
+		)
+		_, ok := factory.NewFunctionListener(function).(experimental.BlockListener)
+		require.False(t, ok)
+	})
+
+	t.Run("with a block listener", func(t *testing.T) {
+		bl := &blockListener{FunctionListenerFunc: noop}
+		factory := experimental.MultiFunctionListenerFactory(
+			experimental.FunctionListenerFactoryFunc(func(api.FunctionDefinition) experimental.FunctionListener { return noop }),
+			experimental.FunctionListenerFactoryFunc(func(api.FunctionDefinition) experimental.FunctionListener { return bl }),
+		)
+		listener, ok := factory.NewFunctionListener(function).(experimental.BlockListener)
+		require.True(t, ok)
+		listener.Block(context.Background(), module, function, 1, 5)
+		require.Equal(t, [][2]uint64{{1, 5}}, bl.blocks)
+	})
+}
+
+// instructionListener records the offsets of the instructions it is notified of.
+type instructionListener struct {
+	experimental.FunctionListenerFunc
+	offsets []uint64
+}
+
+func (l *instructionListener) Instruction(_ context.Context, _ api.Module, _ api.FunctionDefinition, offset uint64, _ []uint64, _ experimental.StackIterator) {
+	l.offsets = append(l.offsets, offset)
+}
+
+func TestMultiFunctionListenerFactory_InstructionListener(t *testing.T) {
+	module := wazerotest.NewModule(nil,
+		wazerotest.NewFunction(func(ctx context.Context, mod api.Module, value int32) {}),
+	)
+	function := module.Function(0).Definition()
+	noop := experimental.FunctionListenerFunc(func(context.Context, api.Module, api.FunctionDefinition, []uint64, experimental.StackIterator) {})
+
+	il := &instructionListener{FunctionListenerFunc: noop}
+	bl := &blockListener{FunctionListenerFunc: noop}
+	factory := experimental.MultiFunctionListenerFactory(
+		experimental.FunctionListenerFactoryFunc(func(api.FunctionDefinition) experimental.FunctionListener { return il }),
+		experimental.FunctionListenerFactoryFunc(func(api.FunctionDefinition) experimental.FunctionListener { return bl }),
+	)
+	listener := factory.NewFunctionListener(function)
+
+	// Both instructions and blocks are forwarded to the listeners implementing them.
+	listener.(experimental.InstructionListener).Instruction(context.Background(), module, function, 3, nil, nil)
+	listener.(experimental.BlockListener).Block(context.Background(), module, function, 1, 5)
+	require.Equal(t, []uint64{3}, il.offsets)
+	require.Equal(t, [][2]uint64{{1, 5}}, bl.blocks)
+
+	// Blocks aren't exposed when no listener is notified of them.
+	factory = experimental.MultiFunctionListenerFactory(
+		experimental.FunctionListenerFactoryFunc(func(api.FunctionDefinition) experimental.FunctionListener { return il }),
+		experimental.FunctionListenerFactoryFunc(func(api.FunctionDefinition) experimental.FunctionListener { return noop }),
+	)
+	listener = factory.NewFunctionListener(function)
+	_, ok := listener.(experimental.BlockListener)
+	require.False(t, ok)
+	listener.(experimental.InstructionListener).Instruction(context.Background(), module, function, 4, nil, nil)
+	require.Equal(t, []uint64{3, 4}, il.offsets)
+}
+
+// localsRecorder records the offsets of instructions with the locals before them, and the offsets of the top of the
+// stack iterator.
+type localsRecorder struct {
+	experimental.FunctionListenerFunc
+	offsets, stackOffsets []uint64
+	locals                [][]uint64
+}
+
+func (r *localsRecorder) Instruction(_ context.Context, _ api.Module, _ api.FunctionDefinition, offset uint64, locals []uint64, si experimental.StackIterator) {
+	r.offsets = append(r.offsets, offset)
+	r.locals = append(r.locals, append([]uint64(nil), locals...))
+	if si.Next() {
+		r.stackOffsets = append(r.stackOffsets, si.Function().SourceOffsetForPC(si.ProgramCounter()))
+	}
+}
+
+func TestInstructionListener(t *testing.T) {
+	recorder := &localsRecorder{FunctionListenerFunc: func(context.Context, api.Module, api.FunctionDefinition, []uint64, experimental.StackIterator) {}}
+	ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, experimental.FunctionListenerFactoryFunc(
+		func(api.FunctionDefinition) experimental.FunctionListener { return recorder },
+	))
+
+	bin := binaryencoding.EncodeModule(&wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{wasm.ValueTypeI32}}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []wasm.Code{{
+			LocalTypes: []wasm.ValueType{wasm.ValueTypeI64},
+			Body: []byte{
+				wasm.OpcodeLocalGet, 0, // offset 0x5
+				wasm.OpcodeI64ExtendI32U,
+				wasm.OpcodeLocalSet, 1,
+				wasm.OpcodeEnd,
+			},
+		}},
+		ExportSection: []wasm.Export{{Name: "f", Type: wasm.ExternTypeFunc, Index: 0}},
+	})
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+
+	m, err := r.Instantiate(ctx, bin)
+	require.NoError(t, err)
+	_, err = m.ExportedFunction("f").Call(ctx, 7)
+	require.NoError(t, err)
+
+	require.Equal(t, []uint64{0x5, 0x7, 0x8, 0xa}, recorder.offsets)
+	require.Equal(t, recorder.offsets, recorder.stackOffsets)
+	require.Equal(t, [][]uint64{{7, 0}, {7, 0}, {7, 0}, {7, 7}}, recorder.locals)
+}
+
 func BenchmarkMultiFunctionListener(b *testing.B) {
 	module := wazerotest.NewModule(nil,
 		wazerotest.NewFunction(func(ctx context.Context, mod api.Module, value int32) {}),
@@ -14,8 +14,8 @@ type enabler interface {
 
 // NewRuntimeConfigOptimizingCompiler returns a new RuntimeConfig with the optimizing compiler enabled.
 func NewRuntimeConfigOptimizingCompiler() wazero.RuntimeConfig {
-	if runtime.GOARCH != "arm64" {
-		panic("UseOptimizingCompiler is only supported on arm64")
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		panic("UseOptimizingCompiler is only supported on arm64 and amd64")
 	}
 	c := wazero.NewRuntimeConfig()
 	c.(enabler).EnableOptimizingCompiler()
@@ -11,7 +11,7 @@ import (
 )
 
 func TestUseOptimizingCompiler(t *testing.T) {
-	if runtime.GOARCH != "arm64" {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
 		return
 	}
 	c := opt.NewRuntimeConfigOptimizingCompiler()
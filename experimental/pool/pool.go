@@ -0,0 +1,171 @@
+// Package pool keeps warm instances of a module, to amortize the cost of instantiation over many short-lived uses,
+// such as sandboxing each request of a server.
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/wasm"
+)
+
+// InstancePool keeps warm instances of a wazero.CompiledModule. An instance returned to the pool is reset to the
+// state right after its instantiation, so that the next user can't observe what the previous one did.
+//
+// On Linux, the memories are backed by copy-on-write mappings, so that resetting takes time proportional to the pages
+// written instead of the size of the memories.
+//
+// # Notes
+//
+//   - The memories, globals and tables defined by the module are reset. Imported ones are not, as they belong to
+//     other modules, nor is the state outside the module such as files opened via WASI.
+//   - An instance closed while in use, e.g. by proc_exit, is discarded instead of being reset.
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type InstancePool interface {
+	// Get returns an idle instance, or instantiates a new one if there is none.
+	Get(ctx context.Context) (api.Module, error)
+
+	// Put resets the instance returned by Get and makes it idle. The instance must not be used after this.
+	//
+	// The instance is closed instead if the pool already has as many idle instances as its size.
+	Put(ctx context.Context, mod api.Module) error
+
+	// Close closes all the instances of the pool, including the ones returned by Get and not yet Put. The memories of
+	// the latter are released when Put.
+	Close(ctx context.Context) error
+}
+
+// NewInstancePool returns an InstancePool which keeps up to `size` idle instances of `compiled`, all instantiated
+// with `config`. `size` instances are instantiated before this returns.
+//
+// The instances are anonymous, so the name in `config` is ignored.
+func NewInstancePool(ctx context.Context, r wazero.Runtime, compiled wazero.CompiledModule, config wazero.ModuleConfig, size int) (InstancePool, error) {
+	if size <= 0 {
+		return nil, errors.New("size must be positive")
+	}
+	p := &instancePool{
+		r:        r,
+		compiled: compiled,
+		config:   config.WithName(""),
+		size:     size,
+		states:   map[*wasm.ModuleInstance]*wasm.InstanceState{},
+	}
+	for i := 0; i < size; i++ {
+		m, err := p.instantiate(ctx)
+		if err != nil {
+			_ = p.Close(ctx)
+			return nil, err
+		}
+		p.idle = append(p.idle, m)
+	}
+	return p, nil
+}
+
+type instancePool struct {
+	r        wazero.Runtime
+	compiled wazero.CompiledModule
+	config   wazero.ModuleConfig
+	size     int
+
+	// mux guards the fields below.
+	mux sync.Mutex
+	// idle holds the instances ready to be returned by Get.
+	idle []*wasm.ModuleInstance
+	// states holds the state captured after instantiation of all the instances which are not closed.
+	states map[*wasm.ModuleInstance]*wasm.InstanceState
+	closed bool
+}
+
+// instantiate instantiates a new instance and captures its state.
+func (p *instancePool) instantiate(ctx context.Context) (*wasm.ModuleInstance, error) {
+	mod, err := p.r.InstantiateModule(ctx, p.compiled, p.config)
+	if err != nil {
+		return nil, err
+	}
+	m := mod.(*wasm.ModuleInstance)
+	state := m.CaptureState(true)
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.closed {
+		_ = closeInstance(ctx, m, state)
+		return nil, errors.New("pool closed")
+	}
+	p.states[m] = state
+	return m, nil
+}
+
+// Get implements the same method as documented on InstancePool.
+func (p *instancePool) Get(ctx context.Context) (api.Module, error) {
+	p.mux.Lock()
+	if p.closed {
+		p.mux.Unlock()
+		return nil, errors.New("pool closed")
+	}
+	if n := len(p.idle); n > 0 {
+		m := p.idle[n-1]
+		p.idle[n-1] = nil
+		p.idle = p.idle[:n-1]
+		p.mux.Unlock()
+		return m, nil
+	}
+	p.mux.Unlock()
+	return p.instantiate(ctx)
+}
+
+// Put implements the same method as documented on InstancePool.
+func (p *instancePool) Put(ctx context.Context, mod api.Module) error {
+	m, _ := mod.(*wasm.ModuleInstance)
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	state, ok := p.states[m]
+	if !ok {
+		return errors.New("module is not in this pool")
+	}
+
+	if p.closed || len(p.idle) >= p.size || m.ResetState(state) != nil {
+		// The module was closed while in use, or it isn't needed anymore.
+		delete(p.states, m)
+		return closeInstance(ctx, m, state)
+	}
+	p.idle = append(p.idle, m)
+	return nil
+}
+
+// Close implements the same method as documented on InstancePool.
+func (p *instancePool) Close(ctx context.Context) (err error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	for _, m := range p.idle {
+		if e := closeInstance(ctx, m, p.states[m]); e != nil && err == nil {
+			err = e
+		}
+		delete(p.states, m)
+	}
+	p.idle = nil
+
+	// The memories of the instances in use can't be released yet, so they are released when Put.
+	for m := range p.states {
+		if e := m.Close(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// closeInstance closes the module and then releases its memories.
+func closeInstance(ctx context.Context, m *wasm.ModuleInstance, state *wasm.InstanceState) error {
+	err := m.Close(ctx)
+	if e := state.Release(); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,165 @@
+package pool_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/experimental/pool"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+const i32 = wasm.ValueTypeI32
+
+// statefulWasm has a memory initialized to "hello" and a mutable global initialized to zero, and exports:
+//
+//   - "mutate" which writes 1 to the memory offset zero, grows the memory by a page and sets the global to 42.
+//   - "load" which returns the byte at the memory offset zero.
+//   - "size" which returns the memory size in pages.
+//   - "global" which returns the global.
+var statefulWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection:     []wasm.FunctionType{{}, {Results: []wasm.ValueType{i32}}},
+	FunctionSection: []wasm.Index{0, 1, 1, 1},
+	CodeSection: []wasm.Code{
+		{Body: []byte{
+			wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Store8, 0, 0,
+			wasm.OpcodeI32Const, 1, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeDrop,
+			wasm.OpcodeI32Const, 42, wasm.OpcodeGlobalSet, 0,
+			wasm.OpcodeEnd,
+		}},
+		{Body: []byte{wasm.OpcodeI32Const, 0, wasm.OpcodeI32Load8U, 0, 0, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeMemorySize, 0, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeGlobalGet, 0, wasm.OpcodeEnd}},
+	},
+	MemorySection: []wasm.Memory{{Min: 1, Max: 4, IsMaxEncoded: true}},
+	GlobalSection: []wasm.Global{
+		{Type: wasm.GlobalType{ValType: i32, Mutable: true}, Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}}},
+	},
+	ExportSection: []wasm.Export{
+		{Name: "mutate", Type: wasm.ExternTypeFunc, Index: 0},
+		{Name: "load", Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "size", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "global", Type: wasm.ExternTypeFunc, Index: 3},
+	},
+	DataSection: []wasm.DataSegment{
+		{OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}}, Init: []byte("hello")},
+	},
+})
+
+func runtimeConfigs() map[string]wazero.RuntimeConfig {
+	ret := map[string]wazero.RuntimeConfig{"interpreter": wazero.NewRuntimeConfigInterpreter()}
+	if platform.CompilerSupported() {
+		ret["compiler"] = wazero.NewRuntimeConfigCompiler()
+	}
+	if runtime.GOARCH == "amd64" || runtime.GOARCH == "arm64" {
+		ret["optimizing compiler"] = opt.NewRuntimeConfigOptimizingCompiler()
+	}
+	return ret
+}
+
+func TestInstancePool(t *testing.T) {
+	for name, config := range runtimeConfigs() {
+		config := config
+		t.Run(name, func(t *testing.T) {
+			r := wazero.NewRuntimeWithConfig(testCtx, config)
+			defer r.Close(testCtx)
+
+			compiled, err := r.CompileModule(testCtx, statefulWasm)
+			require.NoError(t, err)
+
+			p, err := pool.NewInstancePool(testCtx, r, compiled, wazero.NewModuleConfig().WithName("ignored"), 1)
+			require.NoError(t, err)
+			defer p.Close(testCtx)
+
+			mod, err := p.Get(testCtx)
+			require.NoError(t, err)
+			for i := 0; i < 3; i++ {
+				requireInitialState(t, mod)
+				_, err = mod.ExportedFunction("mutate").Call(testCtx)
+				require.NoError(t, err)
+				require.Equal(t, []uint64{1}, call(t, mod, "load"))
+				require.Equal(t, []uint64{2}, call(t, mod, "size"))
+				require.Equal(t, []uint64{42}, call(t, mod, "global"))
+
+				require.NoError(t, p.Put(testCtx, mod))
+
+				// The same instance is reused.
+				reused, err := p.Get(testCtx)
+				require.NoError(t, err)
+				require.Equal(t, mod, reused)
+			}
+
+			// Another instance is instantiated while the only one is in use.
+			other, err := p.Get(testCtx)
+			require.NoError(t, err)
+			require.NotEqual(t, mod, other)
+			requireInitialState(t, other)
+
+			// The pool only keeps one idle instance.
+			require.NoError(t, p.Put(testCtx, mod))
+			require.NoError(t, p.Put(testCtx, other))
+			require.True(t, other.IsClosed())
+			require.False(t, mod.IsClosed())
+			require.EqualError(t, p.Put(testCtx, other), "module is not in this pool")
+
+			// A module closed while in use is discarded.
+			mod, err = p.Get(testCtx)
+			require.NoError(t, err)
+			require.NoError(t, mod.Close(testCtx))
+			require.NoError(t, p.Put(testCtx, mod))
+			mod, err = p.Get(testCtx)
+			require.NoError(t, err)
+			requireInitialState(t, mod)
+
+			require.NoError(t, p.Close(testCtx))
+			require.True(t, mod.IsClosed())
+			_, err = p.Get(testCtx)
+			require.EqualError(t, err, "pool closed")
+			require.NoError(t, p.Put(testCtx, mod))
+		})
+	}
+}
+
+func TestNewInstancePool_Errors(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	compiled, err := r.CompileModule(testCtx, statefulWasm)
+	require.NoError(t, err)
+
+	_, err = pool.NewInstancePool(testCtx, r, compiled, wazero.NewModuleConfig(), 0)
+	require.EqualError(t, err, "size must be positive")
+
+	compiled, err = r.CompileModule(testCtx, binaryencoding.EncodeModule(&wasm.Module{
+		ImportSection: []wasm.Import{{Module: "env", Name: "f", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+		TypeSection:   []wasm.FunctionType{{}},
+	}))
+	require.NoError(t, err)
+	_, err = pool.NewInstancePool(testCtx, r, compiled, wazero.NewModuleConfig(), 1)
+	require.EqualError(t, err, "module[env] not instantiated")
+}
+
+func requireInitialState(t *testing.T, mod api.Module) {
+	require.Equal(t, []uint64{'h'}, call(t, mod, "load"))
+	require.Equal(t, []uint64{1}, call(t, mod, "size"))
+	require.Equal(t, []uint64{0}, call(t, mod, "global"))
+	b, ok := mod.Memory().Read(0, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(b))
+	require.Equal(t, uint32(65536), mod.Memory().Size())
+}
+
+func call(t *testing.T, mod api.Module, name string) []uint64 {
+	ret, err := mod.ExportedFunction(name).Call(testCtx)
+	require.NoError(t, err)
+	return ret
+}
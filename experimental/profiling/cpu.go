@@ -0,0 +1,238 @@
+package profiling
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/internal/pprof"
+)
+
+// DefaultCPUProfileRate is the number of samples per second taken by a CPU profiler, the same as runtime/pprof.
+const DefaultCPUProfileRate = 100
+
+// CPUProfiler is an experimental.FunctionListenerFactory which samples the call stacks of a guest at a fixed rate,
+// to find where it spends its time. Add it to the context used to compile the module, then write the profile once
+// the module is done:
+//
+//	profiler, err := profiling.NewCPUProfiler(wasm, profiling.DefaultCPUProfileRate)
+//	if err != nil {
+//		log.Panicln(err)
+//	}
+//	ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, profiler)
+//	// Compile, instantiate and run the module with ctx...
+//	profiler.Stop()
+//	err = profiler.WriteProfile(f)
+//
+// # Notes
+//
+//   - Samples are of the wall-clock time spent in calls, not the CPU time: a call blocked in a host function, e.g.
+//     waiting for I/O, is sampled as much as one computing. The sample types are still named "cpu" like the profiles
+//     of runtime/pprof, so that the usual views of "go tool pprof" apply.
+//   - Sampling starts with the first function call, so creating a profiler which is never used costs nothing.
+//   - The stack is tracked per module instance, and each is sampled. Module instances can be called concurrently,
+//     but calls into the same module instance must not be, as is already the case for api.Module.
+//   - Every function call is intercepted to track the stack, which slows down the guest.
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type CPUProfiler interface {
+	experimental.FunctionListenerFactory
+
+	// Stop stops sampling, which must be called once the guest is done if it was called at all. Calls after this
+	// are not sampled.
+	Stop()
+
+	// WriteProfile writes the samples taken so far as a gzipped pprof protocol buffer, as read by "go tool pprof".
+	// Samples have the types "samples" in "count" and "cpu" in "nanoseconds".
+	WriteProfile(w io.Writer) error
+}
+
+// NewCPUProfiler returns a CPUProfiler which takes `hz` samples per second from the first function call until
+// stopped. Frames of the module in the WebAssembly binary format are symbolized with its name and DWARF custom
+// sections.
+func NewCPUProfiler(wasmBinary []byte, hz int) (CPUProfiler, error) {
+	s, err := newSymbolizer(wasmBinary)
+	if err != nil {
+		return nil, err
+	}
+	if hz <= 0 {
+		hz = DefaultCPUProfileRate
+	}
+	p := &cpuProfiler{
+		symbolizer: s,
+		period:     time.Second / time.Duration(hz),
+		stacks:     map[api.Module][]frame{},
+		counts:     map[string]*cpuSample{},
+		done:       make(chan struct{}),
+	}
+	p.listener = &stackListener{p: p}
+	return p, nil
+}
+
+type cpuProfiler struct {
+	*symbolizer
+	listener  *stackListener
+	period    time.Duration
+	done      chan struct{}
+	startOnce sync.Once
+
+	mux sync.Mutex
+	// start is when sampling started, or zero until the first function call.
+	start   time.Time
+	stopped bool
+	// stacks are the call stacks of the calls in progress by module instance, the last frame being the innermost.
+	// They are tracked per module instance, as each has its own call stack when modules are called concurrently.
+	stacks   map[api.Module][]frame
+	counts   map[string]*cpuSample
+	duration time.Duration
+}
+
+// cpuSample is the count of samples of a call stack.
+type cpuSample struct {
+	stack []pprof.Frame
+	count int64
+}
+
+// NewFunctionListener implements the same method as documented on experimental.FunctionListenerFactory.
+func (p *cpuProfiler) NewFunctionListener(api.FunctionDefinition) experimental.FunctionListener {
+	return p.listener
+}
+
+// startSampling starts the goroutine sampling the stacks, unless the profiler was already stopped.
+func (p *cpuProfiler) startSampling() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if !p.stopped {
+		p.start = time.Now()
+		go p.sample()
+	}
+}
+
+func (p *cpuProfiler) sample() {
+	ticker := time.NewTicker(p.period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.mux.Lock()
+			for _, stack := range p.stacks {
+				p.count(stack)
+			}
+			p.mux.Unlock()
+		}
+	}
+}
+
+// count adds a sample of the given call stack.
+func (p *cpuProfiler) count(frames []frame) {
+	stack := p.symbolize(frames)
+	key := stackKey(stack)
+	s, ok := p.counts[key]
+	if !ok {
+		s = &cpuSample{stack: stack}
+		p.counts[key] = s
+	}
+	s.count++
+}
+
+// Stop implements the same method as documented on CPUProfiler.
+func (p *cpuProfiler) Stop() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.stopped {
+		return
+	}
+	p.stopped = true
+	if !p.start.IsZero() {
+		close(p.done)
+		p.duration = time.Since(p.start)
+	}
+}
+
+// WriteProfile implements the same method as documented on CPUProfiler.
+func (p *cpuProfiler) WriteProfile(w io.Writer) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	period := int64(p.period)
+	prof := pprof.New([]pprof.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+		pprof.ValueType{Type: "cpu", Unit: "nanoseconds"}, period, p.moduleName)
+	start, duration := p.start, p.duration
+	if start.IsZero() { // never called
+		start = time.Now()
+	} else if !p.stopped {
+		duration = time.Since(start)
+	}
+	prof.SetTime(start.UnixNano(), int64(duration))
+	for _, key := range sortedKeys(p.counts) {
+		s := p.counts[key]
+		prof.AddSample(s.stack, s.count, s.count*period)
+	}
+	return prof.Write(w)
+}
+
+// frame is an entry of the call stack tracked by stackListener.
+type frame struct {
+	def api.FunctionDefinition
+	fn  experimental.InternalFunction
+	// pc is the position in the function: its entry when it is the innermost frame, or the call otherwise.
+	pc experimental.ProgramCounter
+}
+
+// stackListener tracks the call stacks of the guest for the cpuProfiler sampling them.
+type stackListener struct {
+	p *cpuProfiler
+}
+
+// Before implements the same method as documented on experimental.FunctionListener.
+func (l *stackListener) Before(_ context.Context, mod api.Module, def api.FunctionDefinition, _ []uint64, si experimental.StackIterator) {
+	f := frame{def: def}
+	if si.Next() {
+		f.fn, f.pc = si.Function(), si.ProgramCounter()
+	}
+	var callerPC experimental.ProgramCounter
+	hasCaller := si.Next()
+	if hasCaller {
+		callerPC = si.ProgramCounter()
+	}
+
+	p := l.p
+	p.startOnce.Do(p.startSampling)
+	p.mux.Lock()
+	stack := p.stacks[mod]
+	if n := len(stack); n > 0 && hasCaller {
+		stack[n-1].pc = callerPC
+	}
+	p.stacks[mod] = append(stack, f)
+	p.mux.Unlock()
+}
+
+// After implements the same method as documented on experimental.FunctionListener.
+func (l *stackListener) After(_ context.Context, mod api.Module, _ api.FunctionDefinition, _ []uint64) {
+	l.pop(mod)
+}
+
+// Abort implements the same method as documented on experimental.FunctionListener.
+func (l *stackListener) Abort(_ context.Context, mod api.Module, _ api.FunctionDefinition, _ error) {
+	l.pop(mod)
+}
+
+// pop removes the innermost frame of the call stack of the module instance.
+func (l *stackListener) pop(mod api.Module) {
+	p := l.p
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	switch stack := p.stacks[mod]; len(stack) {
+	case 0:
+	case 1:
+		// Module instances may be short-lived, so they aren't kept once their calls return.
+		delete(p.stacks, mod)
+	default:
+		p.stacks[mod] = stack[:len(stack)-1]
+	}
+}
@@ -0,0 +1,83 @@
+package profiling_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/experimental/profiling"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wat"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+// spinText exports "run", which spins calling "inner" from "spin".
+const spinText = `(module $busy
+	(func $inner (param i32) (result i32)
+		(i32.add (local.get 0) (i32.const 1)))
+	(func $spin (param $n i32)
+		(local $i i32)
+		(loop $l
+			(local.set $i (call $inner (local.get $i)))
+			(br_if $l (i32.lt_u (local.get $i) (local.get $n)))))
+	(func (export "run")
+		(call $spin (i32.const 1000000)))
+)`
+
+func TestCPUProfiler(t *testing.T) {
+	bin, err := wat.Compile([]byte(spinText))
+	require.NoError(t, err)
+
+	profiler, err := profiling.NewCPUProfiler(bin, 1000)
+	require.NoError(t, err)
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, profiler)
+
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+
+	mod, err := r.Instantiate(ctx, bin)
+	require.NoError(t, err)
+	_, err = mod.ExportedFunction("run").Call(ctx)
+	require.NoError(t, err)
+	profiler.Stop()
+
+	var buf bytes.Buffer
+	require.NoError(t, profiler.WriteProfile(&buf))
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	profile, err := io.ReadAll(zr)
+	require.NoError(t, err)
+
+	// Functions are named with the name section, and the mapping with the module name.
+	require.Contains(t, string(profile), "spin")
+	require.Contains(t, string(profile), "inner")
+	require.Contains(t, string(profile), "busy")
+	require.Contains(t, string(profile), "nanoseconds")
+}
+
+func TestCPUProfiler_NotCalled(t *testing.T) {
+	bin, err := wat.Compile([]byte(spinText))
+	require.NoError(t, err)
+
+	// Sampling only starts with the first function call, so there's nothing to leak without one.
+	goroutines := runtime.NumGoroutine()
+	profiler, err := profiling.NewCPUProfiler(bin, 1000)
+	require.NoError(t, err)
+	require.Equal(t, goroutines, runtime.NumGoroutine())
+
+	profiler.Stop()
+	var buf bytes.Buffer
+	require.NoError(t, profiler.WriteProfile(&buf))
+}
+
+func TestNewCPUProfiler_Errors(t *testing.T) {
+	_, err := profiling.NewCPUProfiler([]byte{0, 'a', 's', 'm'}, profiling.DefaultCPUProfileRate)
+	require.Error(t, err)
+}
@@ -0,0 +1,368 @@
+package profiling
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/internal/pprof"
+)
+
+// Allocator names the functions of the guest which allocate and free memory, matched against the names of
+// functions in the name section and their export names.
+type Allocator struct {
+	// Malloc allocates the size in its first parameter, returning the address, e.g. "malloc" or "__rust_alloc".
+	Malloc []string
+	// Calloc allocates the product of its first two parameters, returning the address, e.g. "calloc".
+	Calloc []string
+	// Realloc moves the allocation at the address in its first parameter to one of the size in its last parameter,
+	// returning the new address, e.g. "realloc" or "__rust_realloc".
+	Realloc []string
+	// Free frees the allocation at the address in its first parameter, e.g. "free" or "__rust_dealloc".
+	Free []string
+}
+
+// DefaultAllocator are the functions of the C standard library, used by guests compiled with wasi-libc or
+// Emscripten.
+var DefaultAllocator = Allocator{
+	Malloc:  []string{"malloc"},
+	Calloc:  []string{"calloc"},
+	Realloc: []string{"realloc"},
+	Free:    []string{"free"},
+}
+
+// RustAllocator are the functions called by Rust for the global allocator.
+var RustAllocator = Allocator{
+	Malloc:  []string{"__rust_alloc", "__rust_alloc_zeroed"},
+	Realloc: []string{"__rust_realloc"},
+	Free:    []string{"__rust_dealloc"},
+}
+
+// heapProfileRate is the sampling period reported in heap profiles. Every allocation is recorded, but "go tool pprof"
+// expects the period of the Go runtime, which is 512KiB.
+const heapProfileRate = 512 * 1024
+
+// HeapProfiler is an experimental.FunctionListenerFactory which records the allocations made by a guest through its
+// Allocator, with their call stacks, to find which code owns its memory. Add it to the context used to compile the
+// module, then write the profile while it runs or once it is done:
+//
+//	profiler, err := profiling.NewHeapProfiler(wasm, profiling.DefaultAllocator)
+//	if err != nil {
+//		log.Panicln(err)
+//	}
+//	ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, profiler)
+//	// Compile, instantiate and run the module with ctx...
+//	err = profiler.WriteProfile(f)
+//
+// # Notes
+//
+//   - Calls to the allocator made by the allocator itself, such as calloc calling malloc, are not recorded.
+//   - Module instances can be called concurrently, but calls into the same module instance must not be, as is
+//     already the case for allocators which aren't thread-safe.
+//   - The allocator functions must be in the name section or exported, so their names are known.
+//   - This is an interface for decoupling, not third-party implementations.
+//     All implementations are in wazero.
+type HeapProfiler interface {
+	experimental.FunctionListenerFactory
+
+	// WriteProfile writes the allocations recorded so far as a gzipped pprof protocol buffer, as read by
+	// "go tool pprof". Samples have the types "alloc_objects" and "inuse_objects" in "count", and "alloc_space"
+	// and "inuse_space" in "bytes", like the heap profiles of Go.
+	WriteProfile(w io.Writer) error
+}
+
+// NewHeapProfiler returns a HeapProfiler which records the calls to the functions of the allocator. Frames of the
+// module in the WebAssembly binary format are symbolized with its name and DWARF custom sections.
+func NewHeapProfiler(wasmBinary []byte, allocator Allocator) (HeapProfiler, error) {
+	s, err := newSymbolizer(wasmBinary)
+	if err != nil {
+		return nil, err
+	}
+	p := &heapProfiler{
+		symbolizer: s,
+		start:      time.Now(),
+		kinds:      map[string]allocatorKind{},
+		calls:      map[api.Module][]allocatorCall{},
+		sites:      map[string]*heapSite{},
+		live:       map[allocationKey]*allocation{},
+	}
+	for kind, names := range [...][]string{
+		kindMalloc:  allocator.Malloc,
+		kindCalloc:  allocator.Calloc,
+		kindRealloc: allocator.Realloc,
+		kindFree:    allocator.Free,
+	} {
+		for _, name := range names {
+			p.kinds[name] = allocatorKind(kind)
+		}
+	}
+	return p, nil
+}
+
+// allocatorKind is the role of a function in the Allocator.
+type allocatorKind byte
+
+const (
+	kindMalloc allocatorKind = iota
+	kindCalloc
+	kindRealloc
+	kindFree
+)
+
+// accepts returns true if a function of the given signature can be the allocator function of this kind: the
+// parameters read and the address returned must be integers. Otherwise, the function is not recorded, as it is
+// only named like one.
+func (k allocatorKind) accepts(params, results []api.ValueType) bool {
+	var minParams int
+	switch k {
+	case kindMalloc, kindFree:
+		minParams = 1
+	case kindCalloc, kindRealloc:
+		minParams = 2
+	}
+	if len(params) < minParams {
+		return false
+	}
+	// The first parameters and the last one are those read.
+	for _, t := range append(params[:minParams:minParams], params[len(params)-1]) {
+		if !isInteger(t) {
+			return false
+		}
+	}
+	if k == kindFree {
+		return true
+	}
+	return len(results) == 1 && isInteger(results[0])
+}
+
+func isInteger(t api.ValueType) bool {
+	return t == api.ValueTypeI32 || t == api.ValueTypeI64
+}
+
+type heapProfiler struct {
+	*symbolizer
+	start time.Time
+	kinds map[string]allocatorKind
+
+	mux sync.Mutex
+	// calls are the calls to the allocator in progress by module instance, the last being the innermost. They are
+	// tracked per module instance, as each has its own call stack when modules are called concurrently.
+	calls map[api.Module][]allocatorCall
+	// sites are the call stacks which allocated, by the key of their unsymbolized stack.
+	sites map[string]*heapSite
+	// live are the allocations not yet freed.
+	live map[allocationKey]*allocation
+}
+
+// allocatorCall is a call to the allocator, which is recorded once it returns the address.
+type allocatorCall struct {
+	kind allocatorKind
+	// nested is true when the call is made by the allocator itself, so it is ignored.
+	nested bool
+	size   uint64
+	// ptr is the address freed or reallocated.
+	ptr  uint64
+	site *heapSite
+}
+
+// heapSite is a call stack which allocated, with the totals of its allocations.
+type heapSite struct {
+	stack                    []pprof.Frame
+	allocObjects, allocSpace int64
+	inuseObjects, inuseSpace int64
+}
+
+// allocationKey identifies an allocation, as addresses are only unique within a module instance.
+type allocationKey struct {
+	mod api.Module
+	ptr uint64
+}
+
+type allocation struct {
+	size uint64
+	site *heapSite
+}
+
+// NewFunctionListener implements the same method as documented on experimental.FunctionListenerFactory.
+func (p *heapProfiler) NewFunctionListener(def api.FunctionDefinition) experimental.FunctionListener {
+	if def.GoFunction() != nil {
+		return nil
+	}
+	kind, ok := p.kinds[def.Name()]
+	for _, name := range def.ExportNames() {
+		if ok {
+			break
+		}
+		kind, ok = p.kinds[name]
+	}
+	if !ok || !kind.accepts(def.ParamTypes(), def.ResultTypes()) {
+		return nil
+	}
+	return &allocatorListener{p: p, kind: kind, paramTypes: def.ParamTypes(), resultTypes: def.ResultTypes()}
+}
+
+// allocatorListener records the calls to a function of the allocator.
+type allocatorListener struct {
+	p                       *heapProfiler
+	kind                    allocatorKind
+	paramTypes, resultTypes []api.ValueType
+}
+
+// value returns the parameter or result of the given type, whose upper bits are undefined when it is an i32.
+func value(t api.ValueType, v uint64) uint64 {
+	if t == api.ValueTypeI32 {
+		return uint64(uint32(v))
+	}
+	return v
+}
+
+// Before implements the same method as documented on experimental.FunctionListener.
+func (l *allocatorListener) Before(_ context.Context, mod api.Module, _ api.FunctionDefinition, params []uint64, si experimental.StackIterator) {
+	p := l.p
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	calls := p.calls[mod]
+	call := allocatorCall{kind: l.kind, nested: len(calls) > 0}
+	if !call.nested {
+		param := func(i int) uint64 { return value(l.paramTypes[i], params[i]) }
+		switch l.kind {
+		case kindMalloc:
+			call.size = param(0)
+		case kindCalloc:
+			call.size = param(0) * param(1)
+		case kindRealloc:
+			call.ptr, call.size = param(0), param(len(params)-1)
+		case kindFree:
+			call.ptr = param(0)
+		}
+		if l.kind != kindFree {
+			call.site = p.site(si)
+		}
+	}
+	p.calls[mod] = append(calls, call)
+}
+
+// After implements the same method as documented on experimental.FunctionListener.
+func (l *allocatorListener) After(_ context.Context, mod api.Module, _ api.FunctionDefinition, results []uint64) {
+	p := l.p
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	call, ok := p.pop(mod)
+	if !ok || call.nested {
+		return
+	}
+	var ptr uint64
+	if len(results) > 0 {
+		ptr = value(l.resultTypes[0], results[0])
+	}
+	switch call.kind {
+	case kindMalloc, kindCalloc:
+		p.allocate(allocationKey{mod, ptr}, call.size, call.site)
+	case kindRealloc:
+		// The allocation is only moved when it succeeds, returning a non-zero address.
+		if ptr != 0 {
+			p.free(allocationKey{mod, call.ptr})
+			p.allocate(allocationKey{mod, ptr}, call.size, call.site)
+		}
+	case kindFree:
+		p.free(allocationKey{mod, call.ptr})
+	}
+}
+
+// Abort implements the same method as documented on experimental.FunctionListener.
+func (l *allocatorListener) Abort(_ context.Context, mod api.Module, _ api.FunctionDefinition, _ error) {
+	l.p.mux.Lock()
+	l.p.pop(mod)
+	l.p.mux.Unlock()
+}
+
+// pop removes the innermost call to the allocator in progress in the module instance.
+func (p *heapProfiler) pop(mod api.Module) (allocatorCall, bool) {
+	calls := p.calls[mod]
+	n := len(calls)
+	if n == 0 {
+		return allocatorCall{}, false
+	}
+	call := calls[n-1]
+	if n == 1 {
+		// Module instances may be short-lived, so they aren't kept once their calls return.
+		delete(p.calls, mod)
+	} else {
+		p.calls[mod] = calls[:n-1]
+	}
+	return call, true
+}
+
+// site returns the call stack of the allocator, excluding the allocator itself, symbolizing it the first time.
+func (p *heapProfiler) site(si experimental.StackIterator) *heapSite {
+	var stack []frame
+	var key strings.Builder
+	if si.Next() { // skip the allocator
+		for si.Next() {
+			fn, pc := si.Function(), si.ProgramCounter()
+			stack = append(stack, frame{def: fn.Definition(), fn: fn, pc: pc})
+			key.WriteString(fn.Definition().DebugName())
+			key.WriteByte('@')
+			key.WriteString(strconv.FormatUint(uint64(pc), 10))
+			key.WriteByte(';')
+		}
+	}
+	if s, ok := p.sites[key.String()]; ok {
+		return s
+	}
+	// The stack iterator starts with the innermost frame, which is the order of pprof.
+	frames := make([]pprof.Frame, len(stack))
+	for i := range stack {
+		frames[i] = p.frame(&stack[i])
+	}
+	s := &heapSite{stack: frames}
+	p.sites[key.String()] = s
+	return s
+}
+
+func (p *heapProfiler) allocate(key allocationKey, size uint64, site *heapSite) {
+	if key.ptr == 0 { // out of memory
+		return
+	}
+	site.allocObjects++
+	site.allocSpace += int64(size)
+	site.inuseObjects++
+	site.inuseSpace += int64(size)
+	p.live[key] = &allocation{size: size, site: site}
+}
+
+func (p *heapProfiler) free(key allocationKey) {
+	a, ok := p.live[key]
+	if !ok { // null, or allocated before profiling
+		return
+	}
+	a.site.inuseObjects--
+	a.site.inuseSpace -= int64(a.size)
+	delete(p.live, key)
+}
+
+// WriteProfile implements the same method as documented on HeapProfiler.
+func (p *heapProfiler) WriteProfile(w io.Writer) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	prof := pprof.New([]pprof.ValueType{
+		{Type: "alloc_objects", Unit: "count"},
+		{Type: "alloc_space", Unit: "bytes"},
+		{Type: "inuse_objects", Unit: "count"},
+		{Type: "inuse_space", Unit: "bytes"},
+	}, pprof.ValueType{Type: "space", Unit: "bytes"}, heapProfileRate, p.moduleName)
+	prof.SetTime(p.start.UnixNano(), int64(time.Since(p.start)))
+	for _, key := range sortedKeys(p.sites) {
+		s := p.sites[key]
+		prof.AddSample(s.stack, s.allocObjects, s.allocSpace, s.inuseObjects, s.inuseSpace)
+	}
+	return prof.Write(w)
+}
@@ -0,0 +1,176 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wat"
+)
+
+// allocText has a bump allocator which never reuses memory, and calls "env.pause" when allocating. "run" leaks the
+// allocations of "leak" and "grow", and frees the allocation of "temp".
+const allocText = `(module $alloc
+	(import "env" "pause" (func $pause))
+	(memory 1)
+	(global $next (mut i32) (i32.const 8))
+	(func $malloc (export "malloc") (param $size i32) (result i32)
+		(call $pause)
+		(global.get $next)
+		(global.set $next (i32.add (global.get $next) (local.get $size))))
+	(func $calloc (export "calloc") (param $n i32) (param $size i32) (result i32)
+		(call $malloc (i32.mul (local.get $n) (local.get $size))))
+	(func $realloc (export "realloc") (param $ptr i32) (param $size i32) (result i32)
+		(call $malloc (local.get $size)))
+	(func $free (export "free") (param $ptr i32))
+	(func $leak (result i32)
+		(call $calloc (i32.const 4) (i32.const 25)))
+	(func $grow (result i32)
+		(call $realloc (call $malloc (i32.const 10)) (i32.const 20)))
+	(func $temp
+		(call $free (call $malloc (i32.const 1000))))
+	(func (export "run")
+		(drop (call $leak))
+		(drop (call $grow))
+		(call $temp))
+)`
+
+// heapTotals are the values of the samples of a function, in the order of WriteProfile.
+type heapTotals struct {
+	allocObjects, allocSpace, inuseObjects, inuseSpace int64
+}
+
+// totalsByCaller sums the samples by the function which called the allocator.
+func totalsByCaller(p *heapProfiler) map[string]heapTotals {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	ret := map[string]heapTotals{}
+	for _, s := range p.sites {
+		t := ret[s.stack[0].Function]
+		t.allocObjects += s.allocObjects
+		t.allocSpace += s.allocSpace
+		t.inuseObjects += s.inuseObjects
+		t.inuseSpace += s.inuseSpace
+		ret[s.stack[0].Function] = t
+	}
+	return ret
+}
+
+// runAlloc instantiates allocText the given number of times, and calls "run" of each instance concurrently. When there
+// are two instances, each pauses in malloc until the other does, so that their calls to the allocator overlap.
+func runAlloc(t *testing.T, profiler HeapProfiler, bin []byte, instances int) {
+	ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, profiler)
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	paused := make(chan struct{})
+	_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().WithFunc(func(_ context.Context, mod api.Module) {
+		switch {
+		case instances != 2:
+		case mod.Name() == "0":
+			paused <- struct{}{}
+		default:
+			<-paused
+		}
+	}).Export("pause").Instantiate(ctx)
+	require.NoError(t, err)
+
+	compiled, err := r.CompileModule(ctx, bin)
+	require.NoError(t, err)
+	mods := make([]api.Module, instances)
+	for i := range mods {
+		mods[i], err = r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName(fmt.Sprint(i)))
+		require.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, instances)
+	for i := range mods {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = mods[i].ExportedFunction("run").Call(ctx)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+func TestHeapProfiler(t *testing.T) {
+	bin, err := wat.Compile([]byte(allocText))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		instances int64
+	}{
+		{name: "one instance", instances: 1},
+		// Calls of the allocator in another module instance are not mistaken for nested calls.
+		{name: "concurrent instances", instances: 2},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			profiler, err := NewHeapProfiler(bin, DefaultAllocator)
+			require.NoError(t, err)
+			runAlloc(t, profiler, bin, int(tc.instances))
+
+			// Allocations are attributed to the callers of the allocator, but not to the allocator itself.
+			n := tc.instances
+			require.Equal(t, map[string]heapTotals{
+				"leak": {allocObjects: n, allocSpace: 100 * n, inuseObjects: n, inuseSpace: 100 * n},
+				// The allocation of 10 bytes is moved to one of 20.
+				"grow": {allocObjects: 2 * n, allocSpace: 30 * n, inuseObjects: n, inuseSpace: 20 * n},
+				"temp": {allocObjects: n, allocSpace: 1000 * n},
+			}, totalsByCaller(profiler.(*heapProfiler)))
+			require.Equal(t, 0, len(profiler.(*heapProfiler).calls))
+
+			var buf bytes.Buffer
+			require.NoError(t, profiler.WriteProfile(&buf))
+			require.NotEqual(t, 0, buf.Len())
+		})
+	}
+}
+
+// TestHeapProfiler_signature ensures functions named like the allocator are not recorded when their signature
+// doesn't match, instead of failing their calls.
+func TestHeapProfiler_signature(t *testing.T) {
+	bin, err := wat.Compile([]byte(`(module
+	(func (export "malloc") (result i32) (i32.const 8))
+	(func (export "calloc") (param i32) (result i32) (i32.const 8))
+	(func (export "realloc") (param f32 f32) (result i32) (i32.const 8))
+	(func (export "free"))
+	(func (export "run")
+		(drop (call 0))
+		(drop (call 1 (i32.const 1)))
+		(drop (call 2 (f32.const 1) (f32.const 1)))
+		(call 3))
+)`))
+	require.NoError(t, err)
+
+	profiler, err := NewHeapProfiler(bin, DefaultAllocator)
+	require.NoError(t, err)
+	ctx := context.WithValue(context.Background(), experimental.FunctionListenerFactoryKey{}, profiler)
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	mod, err := r.Instantiate(ctx, bin)
+	require.NoError(t, err)
+	_, err = mod.ExportedFunction("run").Call(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(profiler.(*heapProfiler).sites))
+}
+
+func TestNewHeapProfiler_Errors(t *testing.T) {
+	_, err := NewHeapProfiler([]byte{0, 'a', 's', 'm'}, DefaultAllocator)
+	require.Error(t, err)
+}
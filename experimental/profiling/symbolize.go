@@ -0,0 +1,95 @@
+// Package profiling samples guests to find where they spend their time or allocate memory, writing profiles in the
+// format read by "go tool pprof".
+package profiling
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AR1011/wazero/internal/pprof"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binary"
+	"github.com/AR1011/wazero/internal/wasmdebug"
+)
+
+// symbolizer maps frames of the call stack to functions and source lines.
+type symbolizer struct {
+	// moduleName is the name of the module in the name section, or "wasm" when it has none.
+	moduleName string
+	// lines are the rows of the DWARF line table of the module, sorted by their address.
+	lines []wasmdebug.LineEntry
+}
+
+func newSymbolizer(wasmBinary []byte) (*symbolizer, error) {
+	m, err := binary.DecodeModule(wasmBinary, wasm.CoreFeaturesAll, wasm.MemoryLimitPages, false, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding wasm binary: %w", err)
+	}
+	s := &symbolizer{moduleName: "wasm", lines: m.DWARFLines.LineEntries()}
+	if m.NameSection != nil && m.NameSection.ModuleName != "" {
+		s.moduleName = m.NameSection.ModuleName
+	}
+	return s, nil
+}
+
+// symbolize returns the frames of the stack, whose last frame is the innermost, in the order of pprof: innermost
+// first.
+func (s *symbolizer) symbolize(stack []frame) []pprof.Frame {
+	ret := make([]pprof.Frame, len(stack))
+	for i := range stack {
+		ret[len(stack)-1-i] = s.frame(&stack[i])
+	}
+	return ret
+}
+
+func (s *symbolizer) frame(f *frame) pprof.Frame {
+	def := f.def
+	if def.GoFunction() != nil {
+		// Host functions have no source in the module, and are qualified by their module, e.g. "env.log".
+		return pprof.Frame{Function: def.DebugName()}
+	}
+
+	ret := pprof.Frame{Function: def.Name()}
+	if ret.Function == "" {
+		ret.Function = def.DebugName()
+	}
+	if f.fn != nil {
+		ret.Address = f.fn.SourceOffsetForPC(f.pc)
+	}
+	if ret.Address != 0 {
+		if l, ok := s.line(ret.Address); ok {
+			ret.File, ret.Line = l.File, int64(l.Line)
+		}
+	}
+	return ret
+}
+
+// line returns the row of the line table containing the instruction at the offset in the code section, which is
+// the last one starting before it.
+func (s *symbolizer) line(offset uint64) (wasmdebug.LineEntry, bool) {
+	i := sort.Search(len(s.lines), func(i int) bool { return s.lines[i].Address > offset })
+	if i == 0 {
+		return wasmdebug.LineEntry{}, false
+	}
+	return s.lines[i-1], true
+}
+
+// stackKey returns a string identifying the symbolized stack, to count its samples.
+func stackKey(stack []pprof.Frame) string {
+	var b strings.Builder
+	for _, f := range stack {
+		fmt.Fprintf(&b, "%s@%#x;", f.Function, f.Address)
+	}
+	return b.String()
+}
+
+// sortedKeys returns the keys of the map in increasing order, so that profiles are deterministic.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,316 @@
+// Package snapshot pre-initializes WebAssembly modules, similar to Wizer.
+//
+// A module is instantiated, an initialization function is called, then the
+// resulting state is written back into a new WebAssembly binary. Instantiating
+// the new binary skips the work done by the initialization function.
+//
+// See https://github.com/bytecodealliance/wizer
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/wasm"
+)
+
+// DefaultInitFunction is the conventional name of the function exported for
+// pre-initialization, which is the same as Wizer.
+const DefaultInitFunction = "wizer.initialize"
+
+// maxZeroGap is the largest run of zero bytes which is kept inside a data
+// segment instead of splitting it in two. This is about the size of the
+// overhead of a data segment in the binary format.
+const maxZeroGap = 16
+
+// Snapshot calls the function exported as `initFunction` by `mod`, then
+// returns a new WebAssembly binary whose linear memories and mutable globals
+// are initialized to the state of `mod` after the call.
+//
+//   - `mod` must be instantiated from `source`, and must not be closed.
+//   - `source` is the WebAssembly binary `mod` was compiled from. Sections which
+//     don't hold state are copied as-is.
+//
+// The returned binary doesn't export `initFunction`, nor has a start function,
+// as both were already called. Other exports, including "_start", are kept.
+//
+// # Limitations
+//
+// Only the state of memories and globals defined by `mod` is captured. This
+// returns an error if `mod` imports a memory or a mutable global, or if a
+// global holds a non-null reference. The contents of tables are not captured,
+// so they are initialized from the element segments of `source` again.
+func Snapshot(ctx context.Context, mod api.Module, source []byte, initFunction string) ([]byte, error) {
+	m, ok := mod.(*wasm.ModuleInstance)
+	if !ok {
+		return nil, fmt.Errorf("unsupported module: %T", mod)
+	}
+	module := m.Source
+
+	if module.ImportMemoryCount > 0 {
+		return nil, errors.New("imported memories cannot be captured")
+	}
+	for i := range module.ImportSection {
+		if imp := &module.ImportSection[i]; imp.Type == wasm.ExternTypeGlobal && imp.DescGlobal.Mutable {
+			return nil, fmt.Errorf("imported mutable global %s.%s cannot be captured", imp.Module, imp.Name)
+		}
+	}
+
+	fn := mod.ExportedFunction(initFunction)
+	if fn == nil {
+		return nil, fmt.Errorf("%s is not exported", initFunction)
+	}
+	if _, err := fn.Call(ctx); err != nil {
+		return nil, fmt.Errorf("error calling %s: %w", initFunction, err)
+	}
+
+	s := &snapshotter{m: m, module: module, initFunction: initFunction}
+	return s.rewrite(source)
+}
+
+type snapshotter struct {
+	m            *wasm.ModuleInstance
+	module       *wasm.Module
+	initFunction string
+}
+
+// rewrite copies the sections of `source`, replacing those which hold the
+// state of the module instance.
+func (s *snapshotter) rewrite(source []byte) ([]byte, error) {
+	if len(source) < 8 || !bytes.Equal(source[:4], []byte("\x00asm")) {
+		return nil, errors.New("invalid source: invalid magic number")
+	}
+	ret := append([]byte{}, source[:8]...)
+
+	// dataAt is the offset in ret to insert the data section when source doesn't have one. As the data section is
+	// the last non-custom section, this is right after the last non-custom section.
+	dataAt, hasData, hasDataCount := len(ret), false, s.module.DataCountSection != nil
+	for rest := source[8:]; len(rest) > 0; {
+		id := rest[0]
+		size, n, err := leb128.LoadUint32(rest[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid source: section size: %w", err)
+		}
+		start := 1 + int(n)
+		end := start + int(size)
+		if end > len(rest) {
+			return nil, errors.New("invalid source: section size out of range")
+		}
+		section := rest[:end]
+		rest = rest[end:]
+
+		var payload []byte
+		switch id {
+		case wasm.SectionIDMemory:
+			payload = s.encodeMemorySection()
+		case wasm.SectionIDGlobal:
+			if payload, err = s.encodeGlobalSection(); err != nil {
+				return nil, err
+			}
+		case wasm.SectionIDExport:
+			payload = s.encodeExportSection()
+		case wasm.SectionIDStart:
+			// The start function was called on instantiation already.
+			continue
+		case wasm.SectionIDDataCount:
+			payload = leb128.EncodeUint32(uint32(len(s.dataSegments(true))))
+		case wasm.SectionIDData:
+			hasData = true
+			payload = encodeDataSection(s.dataSegments(hasDataCount))
+		default:
+			ret = append(ret, section...)
+			if id != wasm.SectionIDCustom {
+				dataAt = len(ret)
+			}
+			continue
+		}
+		ret = appendSection(ret, id, payload)
+		dataAt = len(ret)
+	}
+
+	if !hasData {
+		if segments := s.dataSegments(hasDataCount); len(segments) > 0 {
+			data := appendSection(nil, wasm.SectionIDData, encodeDataSection(segments))
+			ret = append(ret[:dataAt], append(data, ret[dataAt:]...)...)
+		}
+	}
+	return ret, nil
+}
+
+// dataSegments returns the data segments which initialize the memories to their current contents.
+//
+// When `keepIndices` is true, e.g. the source has the data count section, instructions like memory.init may refer
+// to the original data segments by index. In this case, the original data segments are kept at the same indices,
+// except that active ones are replaced by empty passive ones not to overwrite the current contents.
+func (s *snapshotter) dataSegments(keepIndices bool) (ret []wasm.DataSegment) {
+	if keepIndices {
+		for i := range s.module.DataSection {
+			if d := &s.module.DataSection[i]; d.Passive {
+				ret = append(ret, *d)
+			} else {
+				ret = append(ret, wasm.DataSegment{Passive: true})
+			}
+		}
+	}
+	for memIdx, mem := range s.m.Memories {
+		ret = append(ret, memorySegments(wasm.Index(memIdx), mem)...)
+	}
+	return
+}
+
+// memorySegments returns active data segments for the non-zero contents of the memory.
+func memorySegments(memIdx wasm.Index, mem *wasm.MemoryInstance) (ret []wasm.DataSegment) {
+	buf := mem.Buffer
+	for i := 0; i < len(buf); {
+		if buf[i] == 0 {
+			i++
+			continue
+		}
+		start, end := i, i+1
+		for i = end; i < len(buf) && i-end <= maxZeroGap; i++ {
+			if buf[i] != 0 {
+				end = i + 1
+			}
+		}
+		i = end
+
+		offset := wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(int32(start))}
+		if mem.Memory64 {
+			offset = wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: leb128.EncodeInt64(int64(start))}
+		}
+		ret = append(ret, wasm.DataSegment{OffsetExpression: offset, Init: buf[start:end], MemoryIndex: memIdx})
+	}
+	return
+}
+
+// encodeMemorySection encodes the memories defined by the module, with the minimum size raised to the current size.
+func (s *snapshotter) encodeMemorySection() []byte {
+	ret := leb128.EncodeUint32(uint32(len(s.module.MemorySection)))
+	for i := range s.module.MemorySection {
+		m := &s.module.MemorySection[i]
+		inst := s.m.Memories[int(s.module.ImportMemoryCount)+i]
+		min := uint32(uint64(len(inst.Buffer)) / uint64(wasm.MemoryPageSize))
+
+		var flag byte
+		if m.IsMaxEncoded {
+			flag |= 0x01
+		}
+		if m.IsShared {
+			flag |= 0x02
+		}
+		if m.IsMemory64 {
+			flag |= 0x04
+		}
+		ret = append(ret, flag)
+		ret = append(ret, leb128.EncodeUint32(min)...)
+		if m.IsMaxEncoded {
+			ret = append(ret, leb128.EncodeUint32(m.Max)...)
+		}
+	}
+	return ret
+}
+
+// encodeGlobalSection encodes the globals defined by the module, with mutable ones initialized to their current value.
+func (s *snapshotter) encodeGlobalSection() ([]byte, error) {
+	ret := leb128.EncodeUint32(uint32(len(s.module.GlobalSection)))
+	for i := range s.module.GlobalSection {
+		g := &s.module.GlobalSection[i]
+		ret = append(ret, g.Type.ValType)
+		if !g.Type.Mutable {
+			ret = append(ret, 0x00)
+			ret = appendConstantExpression(ret, &g.Init)
+			continue
+		}
+		ret = append(ret, 0x01)
+
+		lo, hi := s.m.Globals[int(s.module.ImportGlobalCount)+i].Value()
+		switch g.Type.ValType {
+		case wasm.ValueTypeI32:
+			ret = append(ret, wasm.OpcodeI32Const)
+			ret = append(ret, leb128.EncodeInt32(int32(lo))...)
+		case wasm.ValueTypeI64:
+			ret = append(ret, wasm.OpcodeI64Const)
+			ret = append(ret, leb128.EncodeInt64(int64(lo))...)
+		case wasm.ValueTypeF32:
+			ret = append(ret, wasm.OpcodeF32Const)
+			ret = binary.LittleEndian.AppendUint32(ret, uint32(lo))
+		case wasm.ValueTypeF64:
+			ret = append(ret, wasm.OpcodeF64Const)
+			ret = binary.LittleEndian.AppendUint64(ret, lo)
+		case wasm.ValueTypeV128:
+			ret = append(ret, wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Const)
+			ret = binary.LittleEndian.AppendUint64(ret, lo)
+			ret = binary.LittleEndian.AppendUint64(ret, hi)
+		case wasm.ValueTypeFuncref, wasm.ValueTypeExternref:
+			if lo != 0 {
+				return nil, fmt.Errorf("global[%d] holds a reference which cannot be captured", int(s.module.ImportGlobalCount)+i)
+			}
+			ret = append(ret, wasm.OpcodeRefNull, g.Type.ValType)
+		default:
+			return nil, fmt.Errorf("global[%d] has unsupported type %s", int(s.module.ImportGlobalCount)+i,
+				wasm.ValueTypeName(g.Type.ValType))
+		}
+		ret = append(ret, wasm.OpcodeEnd)
+	}
+	return ret, nil
+}
+
+// encodeExportSection encodes the exports of the module except the initialization function.
+func (s *snapshotter) encodeExportSection() []byte {
+	var ret []byte
+	var count uint32
+	for i := range s.module.ExportSection {
+		e := &s.module.ExportSection[i]
+		if e.Type == wasm.ExternTypeFunc && e.Name == s.initFunction {
+			continue
+		}
+		count++
+		ret = append(ret, leb128.EncodeUint32(uint32(len(e.Name)))...)
+		ret = append(ret, e.Name...)
+		ret = append(ret, e.Type)
+		ret = append(ret, leb128.EncodeUint32(e.Index)...)
+	}
+	return append(leb128.EncodeUint32(count), ret...)
+}
+
+func encodeDataSection(segments []wasm.DataSegment) []byte {
+	ret := leb128.EncodeUint32(uint32(len(segments)))
+	for i := range segments {
+		d := &segments[i]
+		switch {
+		case d.Passive:
+			ret = append(ret, 0x01)
+		case d.MemoryIndex == 0:
+			ret = append(ret, 0x00)
+			ret = appendConstantExpression(ret, &d.OffsetExpression)
+		default:
+			ret = append(ret, 0x02)
+			ret = append(ret, leb128.EncodeUint32(d.MemoryIndex)...)
+			ret = appendConstantExpression(ret, &d.OffsetExpression)
+		}
+		ret = append(ret, leb128.EncodeUint32(uint32(len(d.Init)))...)
+		ret = append(ret, d.Init...)
+	}
+	return ret
+}
+
+func appendConstantExpression(buf []byte, expr *wasm.ConstantExpression) []byte {
+	if expr.Opcode == wasm.OpcodeVecV128Const {
+		// The opcode of a vector constant is recorded without the prefix.
+		buf = append(buf, wasm.OpcodeVecPrefix)
+	}
+	buf = append(buf, expr.Opcode)
+	buf = append(buf, expr.Data...)
+	return append(buf, wasm.OpcodeEnd)
+}
+
+func appendSection(buf []byte, id wasm.SectionID, payload []byte) []byte {
+	buf = append(buf, id)
+	buf = append(buf, leb128.EncodeUint32(uint32(len(payload)))...)
+	return append(buf, payload...)
+}
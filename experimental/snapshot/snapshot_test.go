@@ -0,0 +1,191 @@
+package snapshot
+
+import (
+	"context"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+const (
+	i32 = wasm.ValueTypeI32
+	i64 = wasm.ValueTypeI64
+)
+
+// concat joins the given byte sequences, to build function bodies with leb128 encoded immediates.
+func concat(bs ...[]byte) (ret []byte) {
+	for _, b := range bs {
+		ret = append(ret, b...)
+	}
+	return
+}
+
+// initWasm has a start function which increments the global "g0", and exports the following functions:
+//
+//   - DefaultInitFunction which adds 10 to "g0", sets "g1" to -1, grows the memory by a page, then writes to
+//     the memory with stores and with memory.init from the passive data segment.
+//   - "g0" and "g1" which return the value of the globals.
+//   - "init_passive" which copies the passive data segment to the offset 2000.
+var initWasm = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection: []wasm.FunctionType{
+		{},
+		{Results: []wasm.ValueType{i32}},
+		{Results: []wasm.ValueType{i64}},
+	},
+	FunctionSection: []wasm.Index{0, 0, 1, 2, 0},
+	CodeSection: []wasm.Code{
+		{Body: []byte{
+			wasm.OpcodeGlobalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add, wasm.OpcodeGlobalSet, 0,
+			wasm.OpcodeEnd,
+		}},
+		{Body: concat(
+			[]byte{wasm.OpcodeGlobalGet, 0, wasm.OpcodeI32Const, 10, wasm.OpcodeI32Add, wasm.OpcodeGlobalSet, 0},
+			[]byte{wasm.OpcodeI64Const, 0x7f, wasm.OpcodeGlobalSet, 2},
+			[]byte{wasm.OpcodeI32Const, 1, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeDrop},
+			[]byte{wasm.OpcodeI32Const, 16, wasm.OpcodeI32Const}, leb128.EncodeInt32('H'),
+			[]byte{wasm.OpcodeI32Store8, 0, 0},
+			[]byte{wasm.OpcodeI32Const}, leb128.EncodeInt32(65636),
+			[]byte{wasm.OpcodeI32Const}, leb128.EncodeInt32(0x01020304),
+			[]byte{wasm.OpcodeI32Store, 2, 0},
+			[]byte{wasm.OpcodeI32Const}, leb128.EncodeInt32(1000),
+			[]byte{wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 5, wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryInit, 1, 0},
+			[]byte{wasm.OpcodeEnd},
+		)},
+		{Body: []byte{wasm.OpcodeGlobalGet, 0, wasm.OpcodeEnd}},
+		{Body: []byte{wasm.OpcodeGlobalGet, 2, wasm.OpcodeEnd}},
+		{Body: concat(
+			[]byte{wasm.OpcodeI32Const}, leb128.EncodeInt32(2000),
+			[]byte{wasm.OpcodeI32Const, 0, wasm.OpcodeI32Const, 5, wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryInit, 1, 0},
+			[]byte{wasm.OpcodeEnd},
+		)},
+	},
+	MemorySection: []wasm.Memory{{Min: 1, Max: 3, IsMaxEncoded: true}},
+	GlobalSection: []wasm.Global{
+		{Type: wasm.GlobalType{ValType: i32, Mutable: true}, Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}}},
+		{Type: wasm.GlobalType{ValType: i32}, Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{5}}},
+		{Type: wasm.GlobalType{ValType: i64, Mutable: true}, Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: []byte{0}}},
+		{
+			Type: wasm.GlobalType{ValType: wasm.ValueTypeFuncref, Mutable: true},
+			Init: wasm.ConstantExpression{Opcode: wasm.OpcodeRefNull, Data: []byte{wasm.RefTypeFuncref}},
+		},
+	},
+	ExportSection: []wasm.Export{
+		{Name: DefaultInitFunction, Type: wasm.ExternTypeFunc, Index: 1},
+		{Name: "g0", Type: wasm.ExternTypeFunc, Index: 2},
+		{Name: "g1", Type: wasm.ExternTypeFunc, Index: 3},
+		{Name: "init_passive", Type: wasm.ExternTypeFunc, Index: 4},
+		{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0},
+	},
+	StartSection: &[]wasm.Index{0}[0],
+	DataSection: []wasm.DataSegment{
+		{OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{16}}, Init: []byte("hello")},
+		{Passive: true, Init: []byte("world")},
+	},
+	DataCountSection: &[]uint32{2}[0],
+})
+
+func TestSnapshot(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	mod, err := r.Instantiate(testCtx, initWasm)
+	require.NoError(t, err)
+
+	snapshot, err := Snapshot(testCtx, mod, initWasm, DefaultInitFunction)
+	require.NoError(t, err)
+
+	// Instantiate the snapshot under a different name, as the original module is still open.
+	snapshotted, err := r.InstantiateWithConfig(testCtx, snapshot, wazero.NewModuleConfig().WithName("snapshot"))
+	require.NoError(t, err)
+
+	// The initialization function was called already.
+	require.Nil(t, snapshotted.ExportedFunction(DefaultInitFunction))
+
+	// The start function isn't called again, so "g0" isn't 12.
+	res, err := snapshotted.ExportedFunction("g0").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), res[0])
+	res, err = snapshotted.ExportedFunction("g1").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0xffffffffffffffff), res[0])
+
+	mem := snapshotted.Memory()
+	require.Equal(t, uint32(2*65536), mem.Size())
+	_, ok := mem.Grow(1)
+	require.True(t, ok) // The maximum is kept.
+	_, ok = mem.Grow(1)
+	require.False(t, ok)
+
+	// The original active data segment doesn't overwrite the memory.
+	b, _ := mem.Read(16, 5)
+	require.Equal(t, "Hello", string(b))
+	v, _ := mem.ReadUint32Le(65636)
+	require.Equal(t, uint32(0x01020304), v)
+	b, _ = mem.Read(1000, 5)
+	require.Equal(t, "world", string(b))
+
+	// The passive data segment can still be referred to by its index.
+	_, err = snapshotted.ExportedFunction("init_passive").Call(testCtx)
+	require.NoError(t, err)
+	b, _ = mem.Read(2000, 5)
+	require.Equal(t, "world", string(b))
+}
+
+func TestSnapshot_Errors(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	t.Run("not exported", func(t *testing.T) {
+		mod, err := r.InstantiateWithConfig(testCtx, initWasm, wazero.NewModuleConfig().WithName("not exported"))
+		require.NoError(t, err)
+
+		_, err = Snapshot(testCtx, mod, initWasm, "_initialize")
+		require.EqualError(t, err, "_initialize is not exported")
+	})
+
+	t.Run("imported memory", func(t *testing.T) {
+		_, err := r.Instantiate(testCtx, binaryencoding.EncodeModule(&wasm.Module{
+			MemorySection: []wasm.Memory{{Min: 1}},
+			ExportSection: []wasm.Export{{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0}},
+			NameSection:   &wasm.NameSection{ModuleName: "env"},
+		}))
+		require.NoError(t, err)
+
+		source := binaryencoding.EncodeModule(&wasm.Module{
+			ImportSection: []wasm.Import{{
+				Module: "env", Name: "memory",
+				Type: wasm.ExternTypeMemory, DescMem: &wasm.Memory{Min: 1},
+			}},
+		})
+		mod, err := r.Instantiate(testCtx, source)
+		require.NoError(t, err)
+
+		_, err = Snapshot(testCtx, mod, source, DefaultInitFunction)
+		require.EqualError(t, err, "imported memories cannot be captured")
+	})
+}
+
+func Test_memorySegments(t *testing.T) {
+	buf := make([]byte, 100)
+	buf[1] = 1
+	buf[2+maxZeroGap] = 2 // maxZeroGap zeros apart, so merged with the previous one.
+	buf[20+maxZeroGap] = 3
+	buf[99] = 4
+
+	segments := memorySegments(0, &wasm.MemoryInstance{Buffer: buf})
+	require.Equal(t, 3, len(segments))
+	require.Equal(t, []byte{1}, segments[0].Init[:1])
+	require.Equal(t, 2+maxZeroGap, len(segments[0].Init))
+	require.Equal(t, []byte{3}, segments[1].Init)
+	require.Equal(t, []byte{4}, segments[2].Init)
+	require.Equal(t, leb128.EncodeInt32(99), segments[2].OffsetExpression.Data)
+
+	require.Equal(t, 0, len(memorySegments(0, &wasm.MemoryInstance{Buffer: make([]byte, 10)})))
+}
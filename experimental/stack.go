@@ -0,0 +1,22 @@
+package experimental
+
+import "context"
+
+// MaxCallStackDepthKey is a context.Context Value key. Its associated value should be an uint32.
+type MaxCallStackDepthKey struct{}
+
+// WithMaxCallStackDepth overrides the maximum call stack depth for the calls of api.Function with the returned
+// context.Context. Zero returns the input as is.
+//
+// For example, this allows a specific call to recurse deeper than the other calls, or to clamp it further:
+//
+//	ctx = experimental.WithMaxCallStackDepth(ctx, 100000)
+//	_, err := mod.ExportedFunction("parse").Call(ctx)
+//
+// Note: This has no effect unless the wazero.RuntimeConfig has been configured with WithMaxCallStackDepth.
+func WithMaxCallStackDepth(ctx context.Context, depth uint32) context.Context {
+	if depth > 0 {
+		return context.WithValue(ctx, MaxCallStackDepthKey{}, depth)
+	}
+	return ctx
+}
@@ -0,0 +1,15 @@
+package experimental_test
+
+import (
+	"testing"
+
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/internal/testing/require"
+)
+
+func TestWithMaxCallStackDepth(t *testing.T) {
+	require.Same(t, testCtx, experimental.WithMaxCallStackDepth(testCtx, 0))
+
+	decorated := experimental.WithMaxCallStackDepth(testCtx, 100)
+	require.Equal(t, uint32(100), decorated.Value(experimental.MaxCallStackDepthKey{}))
+}
@@ -7,9 +7,9 @@ import (
 	"github.com/AR1011/wazero"
 	"github.com/AR1011/wazero/api"
 	"github.com/AR1011/wazero/experimental/table"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 func TestLookupFunction(t *testing.T) {
@@ -0,0 +1,290 @@
+// Package wasmbuilder builds WebAssembly modules in Go, and encodes them in the WebAssembly Binary Format (%.wasm).
+//
+// This is useful to generate small modules at runtime, such as shims which adapt the imports of another module. Here's
+// an example of a module which forwards its "log" export to an imported function, with the arguments swapped:
+//
+//	i32 := api.ValueTypeI32
+//	b := wasmbuilder.NewModuleBuilder("shim")
+//	log := b.ImportFunction("env", "log", []api.ValueType{i32, i32}, nil)
+//
+//	var body wasmbuilder.Code
+//	body.LocalGet(1).LocalGet(0).Call(log).End()
+//	b.ExportFunction("log", b.AddFunction([]api.ValueType{i32, i32}, nil, nil, &body))
+//
+//	bin, err := b.Encode()
+//
+// The result can be compiled with wazero.Runtime CompileModule, or written to a file.
+package wasmbuilder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/wasm"
+	binaryformat "github.com/AR1011/wazero/internal/wasm/binary"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+)
+
+// ModuleBuilder declares the types, imports, functions, memories, globals and exports of a module, then encodes it
+// with Encode.
+//
+// Methods which add a definition return its index, which is how instructions and exports refer to it. Imports are
+// numbered before the definitions of the same kind, so they must be added first, e.g. ImportFunction before
+// AddFunction.
+//
+// # Notes
+//
+//   - This is an interface for decoupling, not third-party implementations. All implementations are in wazero.
+//   - Errors, such as an unknown instruction in a Code, are returned by Encode.
+type ModuleBuilder interface {
+	// FunctionType returns the index of the function type with the given signature, which is added unless the module
+	// has one already. This is needed by Code.CallIndirect.
+	FunctionType(params, results []api.ValueType) uint32
+
+	// ImportFunction imports a function of the given signature, and returns its index.
+	ImportFunction(moduleName, name string, params, results []api.ValueType) uint32
+
+	// ImportMemory imports a memory, which has at least `minPages` pages, and returns its index.
+	ImportMemory(moduleName, name string, minPages uint32) uint32
+
+	// ImportMemoryWithMax is like ImportMemory, except the memory can have at most `maxPages` pages.
+	ImportMemoryWithMax(moduleName, name string, minPages, maxPages uint32) uint32
+
+	// ImportGlobal imports a global of the given type, and returns its index.
+	ImportGlobal(moduleName, name string, valueType api.ValueType, mutable bool) uint32
+
+	// AddFunction defines a function of the given signature, and returns its index.
+	//
+	//   - `locals` are the types of the local variables after the parameters, which are initially zero.
+	//   - `body` are the instructions of the function, which must end with Code.End. Encode fails if it is nil.
+	AddFunction(params, results, locals []api.ValueType, body *Code) uint32
+
+	// AddMemory defines a memory, which initially has `minPages` pages, and returns its index.
+	AddMemory(minPages uint32) uint32
+
+	// AddMemoryWithMax is like AddMemory, except the memory can grow up to `maxPages` pages.
+	AddMemoryWithMax(minPages, maxPages uint32) uint32
+
+	// AddGlobal defines a global of a numeric type: api.ValueTypeI32, api.ValueTypeI64, api.ValueTypeF32 or
+	// api.ValueTypeF64, and returns its index. The value is encoded as documented on api.Global Get.
+	AddGlobal(valueType api.ValueType, mutable bool, value uint64) uint32
+
+	// AddData initializes the memory at the given index with the data, starting at `offset`, when the module is
+	// instantiated.
+	AddData(memoryIndex, offset uint32, data []byte)
+
+	// NameFunction names the function at the given index in the "name" custom section, which is used in stack
+	// traces.
+	NameFunction(funcIndex uint32, name string)
+
+	// ExportFunction exports the function at the given index under the name.
+	ExportFunction(name string, funcIndex uint32)
+
+	// ExportMemory exports the memory at the given index under the name.
+	ExportMemory(name string, memoryIndex uint32)
+
+	// ExportGlobal exports the global at the given index under the name.
+	ExportGlobal(name string, globalIndex uint32)
+
+	// Encode returns the module in the WebAssembly Binary Format, or an error if it is invalid, such as when an
+	// instruction refers to an undefined function. Validation accepts any feature wazero supports, so compiling the
+	// result can still fail if the runtime has a feature disabled. See wazero.RuntimeConfig WithCoreFeatures.
+	Encode() ([]byte, error)
+}
+
+// NewModuleBuilder returns a ModuleBuilder for a module, which is named `moduleName` unless it is empty.
+func NewModuleBuilder(moduleName string) ModuleBuilder {
+	return &moduleBuilder{moduleName: moduleName}
+}
+
+// moduleBuilder implements ModuleBuilder
+type moduleBuilder struct {
+	m             wasm.Module
+	moduleName    string
+	functionNames wasm.NameMap
+	// err is the first error, which is returned by Encode.
+	err error
+}
+
+// FunctionType implements ModuleBuilder.FunctionType
+func (b *moduleBuilder) FunctionType(params, results []api.ValueType) uint32 {
+	for i := range b.m.TypeSection {
+		if b.m.TypeSection[i].EqualsSignature(params, results) {
+			return uint32(i)
+		}
+	}
+	b.m.TypeSection = append(b.m.TypeSection, wasm.FunctionType{Params: params, Results: results})
+	return uint32(len(b.m.TypeSection) - 1)
+}
+
+// ImportFunction implements ModuleBuilder.ImportFunction
+func (b *moduleBuilder) ImportFunction(moduleName, name string, params, results []api.ValueType) uint32 {
+	if len(b.m.FunctionSection) > 0 {
+		b.fail(fmt.Errorf("function %s.%s must be imported before functions are added", moduleName, name))
+	}
+	b.addImport(wasm.Import{Type: wasm.ExternTypeFunc, Module: moduleName, Name: name, DescFunc: b.FunctionType(params, results)})
+	b.m.ImportFunctionCount++
+	return b.m.ImportFunctionCount - 1
+}
+
+// ImportMemory implements ModuleBuilder.ImportMemory
+func (b *moduleBuilder) ImportMemory(moduleName, name string, minPages uint32) uint32 {
+	return b.importMemory(moduleName, name, &wasm.Memory{Min: minPages})
+}
+
+// ImportMemoryWithMax implements ModuleBuilder.ImportMemoryWithMax
+func (b *moduleBuilder) ImportMemoryWithMax(moduleName, name string, minPages, maxPages uint32) uint32 {
+	return b.importMemory(moduleName, name, &wasm.Memory{Min: minPages, Max: maxPages, IsMaxEncoded: true})
+}
+
+func (b *moduleBuilder) importMemory(moduleName, name string, memory *wasm.Memory) uint32 {
+	if len(b.m.MemorySection) > 0 {
+		b.fail(fmt.Errorf("memory %s.%s must be imported before memories are added", moduleName, name))
+	}
+	b.addImport(wasm.Import{Type: wasm.ExternTypeMemory, Module: moduleName, Name: name, DescMem: memory})
+	b.m.ImportMemoryCount++
+	return b.m.ImportMemoryCount - 1
+}
+
+// ImportGlobal implements ModuleBuilder.ImportGlobal
+func (b *moduleBuilder) ImportGlobal(moduleName, name string, valueType api.ValueType, mutable bool) uint32 {
+	if len(b.m.GlobalSection) > 0 {
+		b.fail(fmt.Errorf("global %s.%s must be imported before globals are added", moduleName, name))
+	}
+	b.addImport(wasm.Import{
+		Type: wasm.ExternTypeGlobal, Module: moduleName, Name: name,
+		DescGlobal: wasm.GlobalType{ValType: valueType, Mutable: mutable},
+	})
+	b.m.ImportGlobalCount++
+	return b.m.ImportGlobalCount - 1
+}
+
+func (b *moduleBuilder) addImport(i wasm.Import) {
+	b.m.ImportSection = append(b.m.ImportSection, i)
+}
+
+// AddFunction implements ModuleBuilder.AddFunction
+func (b *moduleBuilder) AddFunction(params, results, locals []api.ValueType, body *Code) uint32 {
+	code := wasm.Code{LocalTypes: locals}
+	if body == nil {
+		b.fail(fmt.Errorf("function[%d]: missing body", b.m.ImportFunctionCount+uint32(len(b.m.FunctionSection))))
+	} else if body.err != nil {
+		b.fail(fmt.Errorf("function[%d]: %w", b.m.ImportFunctionCount+uint32(len(b.m.FunctionSection)), body.err))
+	} else {
+		code.Body = body.body
+	}
+	b.m.FunctionSection = append(b.m.FunctionSection, b.FunctionType(params, results))
+	b.m.CodeSection = append(b.m.CodeSection, code)
+	return b.m.ImportFunctionCount + uint32(len(b.m.FunctionSection)) - 1
+}
+
+// AddMemory implements ModuleBuilder.AddMemory
+func (b *moduleBuilder) AddMemory(minPages uint32) uint32 {
+	return b.addMemory(wasm.Memory{Min: minPages})
+}
+
+// AddMemoryWithMax implements ModuleBuilder.AddMemoryWithMax
+func (b *moduleBuilder) AddMemoryWithMax(minPages, maxPages uint32) uint32 {
+	return b.addMemory(wasm.Memory{Min: minPages, Max: maxPages, IsMaxEncoded: true})
+}
+
+func (b *moduleBuilder) addMemory(memory wasm.Memory) uint32 {
+	b.m.MemorySection = append(b.m.MemorySection, memory)
+	return b.m.ImportMemoryCount + uint32(len(b.m.MemorySection)) - 1
+}
+
+// AddGlobal implements ModuleBuilder.AddGlobal
+func (b *moduleBuilder) AddGlobal(valueType api.ValueType, mutable bool, value uint64) uint32 {
+	var init wasm.ConstantExpression
+	switch valueType {
+	case api.ValueTypeI32:
+		init = wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(int32(value))}
+	case api.ValueTypeI64:
+		init = wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: leb128.EncodeInt64(int64(value))}
+	case api.ValueTypeF32:
+		data := binary.LittleEndian.AppendUint32(nil, uint32(value))
+		init = wasm.ConstantExpression{Opcode: wasm.OpcodeF32Const, Data: data}
+	case api.ValueTypeF64:
+		data := binary.LittleEndian.AppendUint64(nil, value)
+		init = wasm.ConstantExpression{Opcode: wasm.OpcodeF64Const, Data: data}
+	default:
+		b.fail(fmt.Errorf("global[%d]: unsupported type %s", b.m.ImportGlobalCount+uint32(len(b.m.GlobalSection)),
+			api.ValueTypeName(valueType)))
+	}
+	b.m.GlobalSection = append(b.m.GlobalSection, wasm.Global{
+		Type: wasm.GlobalType{ValType: valueType, Mutable: mutable},
+		Init: init,
+	})
+	return b.m.ImportGlobalCount + uint32(len(b.m.GlobalSection)) - 1
+}
+
+// AddData implements ModuleBuilder.AddData
+func (b *moduleBuilder) AddData(memoryIndex, offset uint32, data []byte) {
+	b.m.DataSection = append(b.m.DataSection, wasm.DataSegment{
+		OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(int32(offset))},
+		Init:             data,
+		MemoryIndex:      memoryIndex,
+	})
+}
+
+// NameFunction implements ModuleBuilder.NameFunction
+func (b *moduleBuilder) NameFunction(funcIndex uint32, name string) {
+	for i := range b.functionNames {
+		if b.functionNames[i].Index == funcIndex {
+			b.functionNames[i].Name = name
+			return
+		}
+	}
+	b.functionNames = append(b.functionNames, wasm.NameAssoc{Index: funcIndex, Name: name})
+}
+
+// ExportFunction implements ModuleBuilder.ExportFunction
+func (b *moduleBuilder) ExportFunction(name string, funcIndex uint32) {
+	b.addExport(wasm.ExternTypeFunc, name, funcIndex)
+}
+
+// ExportMemory implements ModuleBuilder.ExportMemory
+func (b *moduleBuilder) ExportMemory(name string, memoryIndex uint32) {
+	b.addExport(wasm.ExternTypeMemory, name, memoryIndex)
+}
+
+// ExportGlobal implements ModuleBuilder.ExportGlobal
+func (b *moduleBuilder) ExportGlobal(name string, globalIndex uint32) {
+	b.addExport(wasm.ExternTypeGlobal, name, globalIndex)
+}
+
+func (b *moduleBuilder) addExport(typ wasm.ExternType, name string, index uint32) {
+	b.m.ExportSection = append(b.m.ExportSection, wasm.Export{Type: typ, Name: name, Index: index})
+}
+
+// Encode implements ModuleBuilder.Encode
+func (b *moduleBuilder) Encode() ([]byte, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	if b.moduleName != "" || len(b.functionNames) > 0 {
+		// The binary format requires names to be sorted by index.
+		sort.Slice(b.functionNames, func(i, j int) bool { return b.functionNames[i].Index < b.functionNames[j].Index })
+		b.m.NameSection = &wasm.NameSection{ModuleName: b.moduleName, FunctionNames: b.functionNames}
+	}
+	bin := binaryencoding.EncodeModule(&b.m)
+
+	// Decode the result, so that errors are reported now instead of when the module is compiled.
+	decoded, err := binaryformat.DecodeModule(bin, wasm.CoreFeaturesAll, wasm.MemoryLimitPages, false, false, false)
+	if err != nil {
+		return nil, err
+	} else if err = decoded.Validate(wasm.CoreFeaturesAll); err != nil {
+		return nil, err
+	}
+	return bin, nil
+}
+
+func (b *moduleBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
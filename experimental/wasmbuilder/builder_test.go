@@ -0,0 +1,196 @@
+package wasmbuilder_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental/wasmbuilder"
+	"github.com/AR1011/wazero/internal/testing/require"
+)
+
+// testCtx is an arbitrary, non-default context. Non-nil also prevents linter errors.
+var testCtx = context.WithValue(context.Background(), struct{}{}, "arbitrary")
+
+const i32, i64, f64 = api.ValueTypeI32, api.ValueTypeI64, api.ValueTypeF64
+
+func TestModuleBuilder_forwarder(t *testing.T) {
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	var logged []uint32
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(a, b uint32) { logged = append(logged, a, b) }).Export("log").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	b := wasmbuilder.NewModuleBuilder("shim")
+	log := b.ImportFunction("env", "log", []api.ValueType{i32, i32}, nil)
+
+	var body wasmbuilder.Code
+	body.LocalGet(1).LocalGet(0).Call(log).End()
+	fn := b.AddFunction([]api.ValueType{i32, i32}, nil, nil, &body)
+	require.Equal(t, uint32(1), fn)
+	b.NameFunction(fn, "swap")
+	b.ExportFunction("log", fn)
+
+	bin, err := b.Encode()
+	require.NoError(t, err)
+
+	compiled, err := r.CompileModule(testCtx, bin)
+	require.NoError(t, err)
+	require.Equal(t, "shim", compiled.Name())
+	require.Equal(t, "swap", compiled.ExportedFunctions()["log"].Name())
+
+	mod, err := r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+	_, err = mod.ExportedFunction("log").Call(testCtx, 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, []uint32{2, 1}, logged)
+}
+
+func TestModuleBuilder_memoryAndGlobals(t *testing.T) {
+	b := wasmbuilder.NewModuleBuilder("")
+	mem := b.AddMemoryWithMax(1, 2)
+	b.AddData(mem, 8, []byte("hello"))
+	b.ExportMemory("memory", mem)
+	counter := b.AddGlobal(i64, true, api.EncodeI64(-1))
+	b.ExportGlobal("counter", counter)
+	b.ExportGlobal("pi", b.AddGlobal(f64, false, api.EncodeF64(3.14)))
+
+	// next increments the counter, and stores it in memory after the data.
+	var body wasmbuilder.Code
+	body.GlobalGet(counter).I64Const(1).Op("i64.add").GlobalSet(counter).
+		I32Const(0).GlobalGet(counter).Memory("i64.store", 16).
+		GlobalGet(counter).End()
+	b.ExportFunction("next", b.AddFunction(nil, []api.ValueType{i64}, nil, &body))
+
+	bin, err := b.Encode()
+	require.NoError(t, err)
+
+	r := wazero.NewRuntime(testCtx)
+	defer r.Close(testCtx)
+	mod, err := r.Instantiate(testCtx, bin)
+	require.NoError(t, err)
+
+	results, err := mod.ExportedFunction("next").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0}, results)
+	results, err = mod.ExportedFunction("next").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1}, results)
+
+	data, ok := mod.Memory().Read(8, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(data))
+	v, ok := mod.Memory().ReadUint64Le(16)
+	require.True(t, ok)
+	require.Equal(t, uint64(1), v)
+	max, ok := mod.Memory().Definition().Max()
+	require.True(t, ok)
+	require.Equal(t, uint32(2), max)
+
+	require.Equal(t, 3.14, api.DecodeF64(mod.ExportedGlobal("pi").Get()))
+}
+
+func TestModuleBuilder_FunctionType(t *testing.T) {
+	b := wasmbuilder.NewModuleBuilder("")
+	require.Equal(t, uint32(0), b.FunctionType(nil, nil))
+	require.Equal(t, uint32(1), b.FunctionType([]api.ValueType{i32}, nil))
+	require.Equal(t, uint32(0), b.FunctionType(nil, nil))
+
+	// Functions share the types.
+	var body wasmbuilder.Code
+	body.End()
+	b.AddFunction([]api.ValueType{i32}, nil, nil, &body)
+	require.Equal(t, uint32(2), b.FunctionType([]api.ValueType{i64}, nil))
+}
+
+func TestModuleBuilder_Encode_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		build       func(b wasmbuilder.ModuleBuilder)
+		expectedErr string
+	}{
+		{
+			name: "unknown instruction",
+			build: func(b wasmbuilder.ModuleBuilder) {
+				var body wasmbuilder.Code
+				body.I32Const(1).Op("i32.frob").End()
+				b.AddFunction(nil, []api.ValueType{i32}, nil, &body)
+			},
+			expectedErr: "function[0]: unknown instruction i32.frob",
+		},
+		{
+			name: "unknown memory instruction",
+			build: func(b wasmbuilder.ModuleBuilder) {
+				var body wasmbuilder.Code
+				body.I32Const(1).Memory("i32.add", 0).End()
+				b.AddFunction(nil, nil, nil, &body)
+			},
+			expectedErr: "function[0]: unknown memory instruction i32.add",
+		},
+		{
+			name: "block with two results",
+			build: func(b wasmbuilder.ModuleBuilder) {
+				var body wasmbuilder.Code
+				body.Block(i32, i32).End().End()
+				b.AddFunction(nil, nil, nil, &body)
+			},
+			expectedErr: "function[0]: block has 2 results, but can have at most one",
+		},
+		{
+			name: "nil body",
+			build: func(b wasmbuilder.ModuleBuilder) {
+				b.AddFunction(nil, nil, nil, nil)
+			},
+			expectedErr: "function[0]: missing body",
+		},
+		{
+			name: "import after function",
+			build: func(b wasmbuilder.ModuleBuilder) {
+				var body wasmbuilder.Code
+				body.End()
+				b.AddFunction(nil, nil, nil, &body)
+				b.ImportFunction("env", "f", nil, nil)
+			},
+			expectedErr: "function env.f must be imported before functions are added",
+		},
+		{
+			name: "unsupported global type",
+			build: func(b wasmbuilder.ModuleBuilder) {
+				b.AddGlobal(api.ValueTypeExternref, false, 0)
+			},
+			expectedErr: "global[0]: unsupported type externref",
+		},
+		{
+			name: "call to undefined function",
+			build: func(b wasmbuilder.ModuleBuilder) {
+				var body wasmbuilder.Code
+				body.Call(1).End()
+				b.AddFunction(nil, nil, nil, &body)
+			},
+			expectedErr: "invalid function[0]: invalid function index",
+		},
+		{
+			name: "duplicate export",
+			build: func(b wasmbuilder.ModuleBuilder) {
+				mem := b.AddMemory(1)
+				b.ExportMemory("memory", mem)
+				b.ExportMemory("memory", mem)
+			},
+			expectedErr: `section export: export[1] duplicates name "memory"`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			b := wasmbuilder.NewModuleBuilder("")
+			tc.build(b)
+			_, err := b.Encode()
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package wasmbuilder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wat"
+)
+
+// Code appends instructions to the body of a function, which is passed to ModuleBuilder.AddFunction. The zero value
+// is an empty body ready to use.
+//
+// Here's an example of a function which adds one to its parameter:
+//
+//	var body wasmbuilder.Code
+//	body.LocalGet(0).I32Const(1).Op("i32.add").End()
+//
+// Instructions without immediates are appended by their name in the text format with Op, while the others have a
+// method of their own. Nested blocks, and the function itself, must be terminated with End.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#instructions%E2%91%A6
+type Code struct {
+	body []byte
+	// err is the first invalid instruction, which is returned by ModuleBuilder.Encode.
+	err error
+}
+
+// Bytes returns the encoded instructions.
+func (c *Code) Bytes() []byte {
+	return c.body
+}
+
+// Op appends the instruction with the given name in the text format, such as "i32.add" or "drop". The instruction
+// must not have immediates, as those are appended by the other methods, e.g. LocalGet for "local.get".
+func (c *Code) Op(name string) *Code {
+	encoded, ok := wat.Instruction(name)
+	if !ok {
+		c.fail(fmt.Errorf("unknown instruction %s", name))
+		return c
+	}
+	c.body = append(c.body, encoded...)
+	return c
+}
+
+// Unreachable appends the unreachable instruction, which traps.
+func (c *Code) Unreachable() *Code {
+	c.body = append(c.body, wasm.OpcodeUnreachable)
+	return c
+}
+
+// Block appends the start of a block, whose branch target is its end. It has at most one result.
+func (c *Code) Block(results ...api.ValueType) *Code {
+	return c.block(wasm.OpcodeBlock, results)
+}
+
+// Loop appends the start of a loop, whose branch target is its start. It has at most one result.
+func (c *Code) Loop(results ...api.ValueType) *Code {
+	return c.block(wasm.OpcodeLoop, results)
+}
+
+// If appends the start of a block executed when the i32 on the stack is non-zero. It has at most one result.
+func (c *Code) If(results ...api.ValueType) *Code {
+	return c.block(wasm.OpcodeIf, results)
+}
+
+func (c *Code) block(opcode wasm.Opcode, results []api.ValueType) *Code {
+	switch len(results) {
+	case 0:
+		c.body = append(c.body, opcode, 0x40) // empty block type
+	case 1:
+		c.body = append(c.body, opcode, results[0])
+	default:
+		c.fail(fmt.Errorf("%s has %d results, but can have at most one", wasm.InstructionName(opcode), len(results)))
+	}
+	return c
+}
+
+// Else appends the start of the instructions executed when the condition of the current If is zero.
+func (c *Code) Else() *Code {
+	c.body = append(c.body, wasm.OpcodeElse)
+	return c
+}
+
+// End appends the end of the current block, or of the function when there are none.
+func (c *Code) End() *Code {
+	c.body = append(c.body, wasm.OpcodeEnd)
+	return c
+}
+
+// Br appends a branch to the block at the given depth, where zero is the innermost block.
+func (c *Code) Br(depth uint32) *Code {
+	return c.appendIndex(wasm.OpcodeBr, depth)
+}
+
+// BrIf is like Br, except it only branches when the i32 on the stack is non-zero.
+func (c *Code) BrIf(depth uint32) *Code {
+	return c.appendIndex(wasm.OpcodeBrIf, depth)
+}
+
+// BrTable appends a branch to the block at depths[i], where i is the i32 on the stack, or to the block at
+// defaultDepth when i is out of range.
+func (c *Code) BrTable(depths []uint32, defaultDepth uint32) *Code {
+	c.body = append(c.body, wasm.OpcodeBrTable)
+	c.body = append(c.body, leb128.EncodeUint32(uint32(len(depths)))...)
+	for _, d := range depths {
+		c.body = append(c.body, leb128.EncodeUint32(d)...)
+	}
+	c.body = append(c.body, leb128.EncodeUint32(defaultDepth)...)
+	return c
+}
+
+// Return appends a return from the function.
+func (c *Code) Return() *Code {
+	c.body = append(c.body, wasm.OpcodeReturn)
+	return c
+}
+
+// Call appends a call to the function at the given index, which includes imported functions.
+func (c *Code) Call(funcIndex uint32) *Code {
+	return c.appendIndex(wasm.OpcodeCall, funcIndex)
+}
+
+// ReturnCall is like Call, except it is a tail call, which replaces the current function.
+//
+// Note: This requires api.CoreFeatureTailCall.
+func (c *Code) ReturnCall(funcIndex uint32) *Code {
+	return c.appendIndex(wasm.OpcodeReturnCall, funcIndex)
+}
+
+// CallIndirect appends a call to the function in the table at the index on the stack, which must be of the given
+// type. See ModuleBuilder.FunctionType.
+func (c *Code) CallIndirect(typeIndex, tableIndex uint32) *Code {
+	c.appendIndex(wasm.OpcodeCallIndirect, typeIndex)
+	c.body = append(c.body, leb128.EncodeUint32(tableIndex)...)
+	return c
+}
+
+// LocalGet appends local.get, where parameters are the first locals.
+func (c *Code) LocalGet(index uint32) *Code {
+	return c.appendIndex(wasm.OpcodeLocalGet, index)
+}
+
+// LocalSet appends local.set, where parameters are the first locals.
+func (c *Code) LocalSet(index uint32) *Code {
+	return c.appendIndex(wasm.OpcodeLocalSet, index)
+}
+
+// LocalTee appends local.tee, where parameters are the first locals.
+func (c *Code) LocalTee(index uint32) *Code {
+	return c.appendIndex(wasm.OpcodeLocalTee, index)
+}
+
+// GlobalGet appends global.get, where imported globals are the first globals.
+func (c *Code) GlobalGet(index uint32) *Code {
+	return c.appendIndex(wasm.OpcodeGlobalGet, index)
+}
+
+// GlobalSet appends global.set, where imported globals are the first globals.
+func (c *Code) GlobalSet(index uint32) *Code {
+	return c.appendIndex(wasm.OpcodeGlobalSet, index)
+}
+
+// I32Const appends i32.const.
+func (c *Code) I32Const(v int32) *Code {
+	c.body = append(c.body, wasm.OpcodeI32Const)
+	c.body = append(c.body, leb128.EncodeInt32(v)...)
+	return c
+}
+
+// I64Const appends i64.const.
+func (c *Code) I64Const(v int64) *Code {
+	c.body = append(c.body, wasm.OpcodeI64Const)
+	c.body = append(c.body, leb128.EncodeInt64(v)...)
+	return c
+}
+
+// F32Const appends f32.const.
+func (c *Code) F32Const(v float32) *Code {
+	c.body = append(c.body, wasm.OpcodeF32Const)
+	c.body = binary.LittleEndian.AppendUint32(c.body, math.Float32bits(v))
+	return c
+}
+
+// F64Const appends f64.const.
+func (c *Code) F64Const(v float64) *Code {
+	c.body = append(c.body, wasm.OpcodeF64Const)
+	c.body = binary.LittleEndian.AppendUint64(c.body, math.Float64bits(v))
+	return c
+}
+
+// Memory appends the load or store instruction with the given name in the text format, such as "i32.load" or
+// "i64.store8", which accesses the first memory at the address on the stack plus the offset. The alignment is the
+// natural one, i.e. the size of the access.
+func (c *Code) Memory(name string, offset uint32) *Code {
+	encoded, align, ok := wat.MemoryInstruction(name)
+	if !ok {
+		c.fail(fmt.Errorf("unknown memory instruction %s", name))
+		return c
+	}
+	c.body = append(c.body, encoded...)
+	c.body = append(c.body, leb128.EncodeUint32(align)...)
+	c.body = append(c.body, leb128.EncodeUint32(offset)...)
+	return c
+}
+
+// MemorySize appends memory.size, which returns the size of the first memory in pages.
+func (c *Code) MemorySize() *Code {
+	return c.appendIndex(wasm.OpcodeMemorySize, 0)
+}
+
+// MemoryGrow appends memory.grow, which grows the first memory by the number of pages on the stack.
+func (c *Code) MemoryGrow() *Code {
+	return c.appendIndex(wasm.OpcodeMemoryGrow, 0)
+}
+
+// MemoryCopy appends memory.copy, which copies a range within the first memory.
+func (c *Code) MemoryCopy() *Code {
+	c.body = append(c.body, wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 0, 0)
+	return c
+}
+
+// MemoryFill appends memory.fill, which sets a range of the first memory to a byte value.
+func (c *Code) MemoryFill() *Code {
+	c.body = append(c.body, wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryFill, 0)
+	return c
+}
+
+func (c *Code) appendIndex(opcode wasm.Opcode, index uint32) *Code {
+	c.body = append(c.body, opcode)
+	c.body = append(c.body, leb128.EncodeUint32(index)...)
+	return c
+}
+
+func (c *Code) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
@@ -0,0 +1,81 @@
+package wasmbuilder
+
+import (
+	"testing"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+)
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     func(c *Code)
+		expected []byte
+	}{
+		{
+			name:     "empty",
+			code:     func(c *Code) {},
+			expected: nil,
+		},
+		{
+			name:     "op",
+			code:     func(c *Code) { c.Op("i32.add").Op("i32.trunc_sat_f32_s").Op("i32x4.add") },
+			expected: []byte{wasm.OpcodeI32Add, wasm.OpcodeMiscPrefix, 0x00, wasm.OpcodeVecPrefix, 0xae, 0x01},
+		},
+		{
+			name: "blocks",
+			code: func(c *Code) { c.Block().Loop(api.ValueTypeI32).Br(1).End().If().Else().End().End() },
+			expected: []byte{
+				wasm.OpcodeBlock, 0x40, wasm.OpcodeLoop, wasm.ValueTypeI32, wasm.OpcodeBr, 1, wasm.OpcodeEnd,
+				wasm.OpcodeIf, 0x40, wasm.OpcodeElse, wasm.OpcodeEnd, wasm.OpcodeEnd,
+			},
+		},
+		{
+			name:     "br_table",
+			code:     func(c *Code) { c.BrTable([]uint32{0, 1}, 2) },
+			expected: []byte{wasm.OpcodeBrTable, 2, 0, 1, 2},
+		},
+		{
+			name:     "calls",
+			code:     func(c *Code) { c.Call(200).ReturnCall(1).CallIndirect(3, 0) },
+			expected: []byte{wasm.OpcodeCall, 0xc8, 0x01, wasm.OpcodeReturnCall, 1, wasm.OpcodeCallIndirect, 3, 0},
+		},
+		{
+			name: "constants",
+			code: func(c *Code) { c.I32Const(-1).I64Const(64).F32Const(1).F64Const(-2) },
+			expected: []byte{
+				wasm.OpcodeI32Const, 0x7f,
+				wasm.OpcodeI64Const, 0xc0, 0x00,
+				wasm.OpcodeF32Const, 0x00, 0x00, 0x80, 0x3f,
+				wasm.OpcodeF64Const, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xc0,
+			},
+		},
+		{
+			name: "memory",
+			code: func(c *Code) {
+				c.Memory("i32.load", 4).Memory("i64.store8", 0).Memory("v128.load", 0).
+					MemorySize().MemoryGrow().MemoryCopy().MemoryFill()
+			},
+			expected: []byte{
+				wasm.OpcodeI32Load, 2, 4,
+				wasm.OpcodeI64Store8, 0, 0,
+				wasm.OpcodeVecPrefix, wasm.OpcodeVecV128Load, 4, 0,
+				wasm.OpcodeMemorySize, 0, wasm.OpcodeMemoryGrow, 0,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 0, 0,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryFill, 0,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			var c Code
+			tc.code(&c)
+			require.NoError(t, c.err)
+			require.Equal(t, tc.expected, c.Bytes())
+		})
+	}
+}
@@ -40,6 +40,8 @@ type Module struct {
 	ExportMemory *Memory
 
 	exitStatus atomic.Uint64
+	fuel       uint64
+	deadline   uint64
 
 	once                        sync.Once
 	exportedFunctions           map[string]api.Function
@@ -105,6 +107,38 @@ func (m *Module) ExportedGlobal(name string) api.Global {
 	return m.exportedGlobals[name]
 }
 
+// ExportedTable implements the same method as documented on api.Module.
+func (m *Module) ExportedTable(string) api.Table {
+	return nil
+}
+
+// ExportedTag implements the same method as documented on api.Module.
+func (m *Module) ExportedTag(string) api.Tag {
+	return nil
+}
+
+// SetFuel implements the same method as documented on api.Module.
+func (m *Module) SetFuel(fuel uint64) {
+	m.fuel = fuel
+}
+
+// AddFuel implements the same method as documented on api.Module.
+func (m *Module) AddFuel(fuel uint64) {
+	if m.fuel += fuel; m.fuel < fuel {
+		m.fuel = math.MaxUint64
+	}
+}
+
+// RemainingFuel implements the same method as documented on api.Module.
+func (m *Module) RemainingFuel() uint64 {
+	return m.fuel
+}
+
+// SetEpochDeadline implements the same method as documented on api.Module.
+func (m *Module) SetEpochDeadline(ticks uint64) {
+	m.deadline = ticks
+}
+
 // Close implements the same method as documented on api.Closer.
 func (m *Module) Close(ctx context.Context) error {
 	return m.CloseWithExitCode(ctx, 0)
@@ -624,11 +658,121 @@ func (m *Memory) WriteString(offset uint32, value string) bool {
 	return true
 }
 
+func (m *Memory) Size64() uint64 {
+	return uint64(len(m.Bytes))
+}
+
+func (m *Memory) ReadByte64(offset uint64) (byte, bool) {
+	if m.isOutOfRange64(offset, 1) {
+		return 0, false
+	}
+	return m.Bytes[offset], true
+}
+
+func (m *Memory) ReadUint16Le64(offset uint64) (uint16, bool) {
+	if m.isOutOfRange64(offset, 2) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint16(m.Bytes[offset:]), true
+}
+
+func (m *Memory) ReadUint32Le64(offset uint64) (uint32, bool) {
+	if m.isOutOfRange64(offset, 4) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(m.Bytes[offset:]), true
+}
+
+func (m *Memory) ReadUint64Le64(offset uint64) (uint64, bool) {
+	if m.isOutOfRange64(offset, 8) {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(m.Bytes[offset:]), true
+}
+
+func (m *Memory) ReadFloat32Le64(offset uint64) (float32, bool) {
+	v, ok := m.ReadUint32Le64(offset)
+	return math.Float32frombits(v), ok
+}
+
+func (m *Memory) ReadFloat64Le64(offset uint64) (float64, bool) {
+	v, ok := m.ReadUint64Le64(offset)
+	return math.Float64frombits(v), ok
+}
+
+func (m *Memory) Read64(offset, length uint64) ([]byte, bool) {
+	if m.isOutOfRange64(offset, length) {
+		return nil, false
+	}
+	return m.Bytes[offset : offset+length : offset+length], true
+}
+
+func (m *Memory) WriteByte64(offset uint64, value byte) bool {
+	if m.isOutOfRange64(offset, 1) {
+		return false
+	}
+	m.Bytes[offset] = value
+	return true
+}
+
+func (m *Memory) WriteUint16Le64(offset uint64, value uint16) bool {
+	if m.isOutOfRange64(offset, 2) {
+		return false
+	}
+	binary.LittleEndian.PutUint16(m.Bytes[offset:], value)
+	return true
+}
+
+func (m *Memory) WriteUint32Le64(offset uint64, value uint32) bool {
+	if m.isOutOfRange64(offset, 4) {
+		return false
+	}
+	binary.LittleEndian.PutUint32(m.Bytes[offset:], value)
+	return true
+}
+
+func (m *Memory) WriteUint64Le64(offset uint64, value uint64) bool {
+	if m.isOutOfRange64(offset, 8) {
+		return false
+	}
+	binary.LittleEndian.PutUint64(m.Bytes[offset:], value)
+	return true
+}
+
+func (m *Memory) WriteFloat32Le64(offset uint64, value float32) bool {
+	return m.WriteUint32Le64(offset, math.Float32bits(value))
+}
+
+func (m *Memory) WriteFloat64Le64(offset uint64, value float64) bool {
+	return m.WriteUint64Le64(offset, math.Float64bits(value))
+}
+
+func (m *Memory) Write64(offset uint64, value []byte) bool {
+	if m.isOutOfRange64(offset, uint64(len(value))) {
+		return false
+	}
+	copy(m.Bytes[offset:], value)
+	return true
+}
+
+func (m *Memory) WriteString64(offset uint64, value string) bool {
+	if m.isOutOfRange64(offset, uint64(len(value))) {
+		return false
+	}
+	copy(m.Bytes[offset:], value)
+	return true
+}
+
 func (m *Memory) isOutOfRange(offset, length uint32) bool {
 	size := m.Size()
 	return offset >= size || length > size || offset > (size-length)
 }
 
+func (m *Memory) isOutOfRange64(offset, length uint64) bool {
+	size := m.Size64()
+	return offset >= size || length > size || offset > (size-length)
+}
+
 type memoryDefinition struct {
 	internalapi.WazeroOnlyType
 	memory *Memory
@@ -12,9 +12,9 @@ import (
 	"github.com/AR1011/wazero/experimental/logging"
 	"github.com/AR1011/wazero/imports/wasi_snapshot_preview1"
 	internal "github.com/AR1011/wazero/internal/emscripten"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 const (
@@ -0,0 +1,287 @@
+// Package coredump builds WebAssembly coredumps, which capture the call stack, memories and globals of a guest when it
+// traps, so that it can be inspected offline by debuggers such as wasmgdb.
+//
+// See https://github.com/WebAssembly/tool-conventions/blob/main/Coredump.md
+package coredump
+
+import (
+	"encoding/binary"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+	"github.com/AR1011/wazero/sys"
+)
+
+// Error is an error of a call which trapped, with the coredump of the guest at the time of the trap.
+type Error struct {
+	err  error
+	dump []byte
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error of the call, so that this is transparent to errors.Is and errors.As.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// CoreDump returns the coredump, which is a WebAssembly binary with the custom sections of the coredump format.
+// This is read by experimental.CoreDump.
+func (e *Error) CoreDump() []byte {
+	return e.dump
+}
+
+// Builder collects the frames of the call stack while the engine unwinds it after a trap.
+//
+// AddFrame should be called beginning at the frame that trapped until no more frames exist. Once done, call Wrap.
+type Builder struct {
+	frames []frame
+}
+
+type frame struct {
+	m                   *wasm.ModuleInstance
+	index               wasm.Index
+	offsetInCodeSection uint64
+	locals              []uint64
+}
+
+// AddFrame adds the next frame.
+//
+//   - m is the module instance of the function.
+//   - index is the index of the function in the module, including the imported functions.
+//   - offsetInCodeSection is the offset of the current instruction in the code section, as for DWARF.
+//   - locals are the params followed by the other locals of the function, one uint64 per value except for v128 values
+//     which take two, or nil when the engine doesn't know them.
+//
+// Frames of host functions aren't recorded, as they have no code.
+func (b *Builder) AddFrame(m *wasm.ModuleInstance, index wasm.Index, offsetInCodeSection uint64, locals []uint64) {
+	if m == nil || index < m.Source.ImportFunctionCount {
+		return
+	}
+	code := &m.Source.CodeSection[index-m.Source.ImportFunctionCount]
+	if code.GoFunc != nil {
+		return
+	}
+	b.frames = append(b.frames, frame{m: m, index: index, offsetInCodeSection: offsetInCodeSection, locals: locals})
+}
+
+// Wrap returns err with the coredump of the frames added so far, unless err is not a trap, e.g. sys.ExitError, or
+// no frames of guest functions were added.
+func (b *Builder) Wrap(err error) error {
+	if _, ok := err.(*sys.ExitError); ok || len(b.frames) == 0 {
+		return err
+	}
+	return &Error{err: err, dump: b.encode()}
+}
+
+// Coredump sections, which are identified by a 0x0 byte before their content to allow future versions.
+const (
+	sectionCore          = "core"
+	sectionCoreModules   = "coremodules"
+	sectionCoreInstances = "coreinstances"
+	sectionCoreStack     = "corestack"
+	version              = 0x0
+	// valueMissing is a value which isn't known, e.g. when the engine keeps locals in registers.
+	valueMissing = 0x01
+)
+
+// encode returns the coredump, in which the memories and globals of all module instances in the call stack are the
+// memories and globals of the coredump itself.
+func (b *Builder) encode() []byte {
+	dump := &wasm.Module{}
+	var instances []*wasm.ModuleInstance
+	instanceIndex := map[*wasm.ModuleInstance]uint32{}
+	var modules []*wasm.Module
+	moduleIndex := map[*wasm.Module]uint32{}
+	for _, f := range b.frames {
+		if _, ok := instanceIndex[f.m]; ok {
+			continue
+		}
+		instanceIndex[f.m] = uint32(len(instances))
+		instances = append(instances, f.m)
+		if _, ok := moduleIndex[f.m.Source]; !ok {
+			moduleIndex[f.m.Source] = uint32(len(modules))
+			modules = append(modules, f.m.Source)
+		}
+	}
+
+	// The executable is the module instance of the outermost frame, as it was called first.
+	core := []byte{version}
+	core = appendName(core, b.frames[len(b.frames)-1].m.ModuleName)
+
+	coreModules := leb128.EncodeUint32(uint32(len(modules)))
+	for _, m := range modules {
+		name := ""
+		if m.NameSection != nil {
+			name = m.NameSection.ModuleName
+		}
+		if name == "" {
+			name = instances[0].ModuleName
+			for _, i := range instances {
+				if i.Source == m {
+					name = i.ModuleName
+					break
+				}
+			}
+		}
+		coreModules = append(append(coreModules, version), appendName(nil, name)...)
+	}
+
+	// Memories and globals imported by an instance are the same as those exported by another, so they are only
+	// dumped once.
+	memoryIndex := map[*wasm.MemoryInstance]uint32{}
+	globalIndex := map[*wasm.GlobalInstance]uint32{}
+	coreInstances := leb128.EncodeUint32(uint32(len(instances)))
+	for _, i := range instances {
+		coreInstances = append(coreInstances, version)
+		coreInstances = append(coreInstances, leb128.EncodeUint32(moduleIndex[i.Source])...)
+		coreInstances = append(coreInstances, leb128.EncodeUint32(uint32(len(i.Memories)))...)
+		for _, mem := range i.Memories {
+			idx, ok := memoryIndex[mem]
+			if !ok {
+				idx = uint32(len(dump.MemorySection))
+				memoryIndex[mem] = idx
+				addMemory(dump, idx, mem)
+			}
+			coreInstances = append(coreInstances, leb128.EncodeUint32(idx)...)
+		}
+		coreInstances = append(coreInstances, leb128.EncodeUint32(uint32(len(i.Globals)))...)
+		for _, g := range i.Globals {
+			idx, ok := globalIndex[g]
+			if !ok {
+				idx = uint32(len(dump.GlobalSection))
+				globalIndex[g] = idx
+				dump.GlobalSection = append(dump.GlobalSection, global(g))
+			}
+			coreInstances = append(coreInstances, leb128.EncodeUint32(idx)...)
+		}
+	}
+
+	coreStack := []byte{version}
+	coreStack = appendName(coreStack, "main")
+	coreStack = append(coreStack, leb128.EncodeUint32(uint32(len(b.frames)))...)
+	for _, f := range b.frames {
+		coreStack = appendFrame(coreStack, instanceIndex[f.m], &f)
+	}
+
+	// The custom sections precede the others, so that the binary is recognized as a coredump first.
+	ret := binaryencoding.EncodeModule(&wasm.Module{CustomSections: []*wasm.CustomSection{
+		{Name: sectionCore, Data: core},
+		{Name: sectionCoreModules, Data: coreModules},
+		{Name: sectionCoreInstances, Data: coreInstances},
+		{Name: sectionCoreStack, Data: coreStack},
+	}})
+	// Skip the preamble of the other sections, which is the magic and the version.
+	return append(ret, binaryencoding.EncodeModule(dump)[len(binaryencoding.EncodeModule(&wasm.Module{})):]...)
+}
+
+func appendName(buf []byte, name string) []byte {
+	buf = append(buf, leb128.EncodeUint32(uint32(len(name)))...)
+	return append(buf, name...)
+}
+
+// appendFrame appends the frame, whose code offset is relative to the start of the function.
+func appendFrame(buf []byte, instance uint32, f *frame) []byte {
+	source := f.m.Source
+	code := &source.CodeSection[f.index-source.ImportFunctionCount]
+	var offset uint32
+	if f.offsetInCodeSection > code.OffsetInCodeSection {
+		offset = uint32(f.offsetInCodeSection - code.OffsetInCodeSection)
+	}
+	buf = append(buf, version)
+	buf = append(buf, leb128.EncodeUint32(instance)...)
+	buf = append(buf, leb128.EncodeUint32(f.index)...)
+	buf = append(buf, leb128.EncodeUint32(offset)...)
+
+	types := append(append([]api.ValueType(nil), source.FunctionDefinition(f.index).ParamTypes()...), code.LocalTypes...)
+	buf = append(buf, leb128.EncodeUint32(uint32(len(types)))...)
+	locals := f.locals
+	for _, t := range types {
+		buf, locals = appendValue(buf, t, locals)
+	}
+	// The values on the operand stack are not known, as their types are only known at compile time.
+	return append(buf, leb128.EncodeUint32(0)...)
+}
+
+// appendValue appends the value of the given type at the beginning of values, and returns the remaining values.
+func appendValue(buf []byte, t api.ValueType, values []uint64) ([]byte, []uint64) {
+	if len(values) == 0 {
+		return append(buf, valueMissing), nil
+	}
+	v := values[0]
+	switch t {
+	case api.ValueTypeI32:
+		buf = append(append(buf, t), leb128.EncodeInt32(int32(v))...)
+	case api.ValueTypeI64:
+		buf = append(append(buf, t), leb128.EncodeInt64(int64(v))...)
+	case api.ValueTypeF32:
+		buf = binary.LittleEndian.AppendUint32(append(buf, t), uint32(v))
+	case api.ValueTypeF64:
+		buf = binary.LittleEndian.AppendUint64(append(buf, t), v)
+	case wasm.ValueTypeV128:
+		// The format has no vector values.
+		buf = append(buf, valueMissing)
+		if len(values) > 1 {
+			return buf, values[2:]
+		}
+		return buf, nil
+	default: // references aren't values of the format either.
+		buf = append(buf, valueMissing)
+	}
+	return buf, values[1:]
+}
+
+// addMemory adds the memory at the given index to the dump, with a data segment of its content.
+func addMemory(dump *wasm.Module, idx uint32, mem *wasm.MemoryInstance) {
+	size := mem.Size64()
+	max, isMaxEncoded := mem.Definition().Max()
+	dump.MemorySection = append(dump.MemorySection, wasm.Memory{
+		Min:          uint32(size >> wasm.MemoryPageSizeInBits),
+		Max:          max,
+		IsMaxEncoded: isMaxEncoded,
+		IsShared:     mem.Shared,
+		IsMemory64:   mem.Memory64,
+	})
+	// Memory is zeroed when instantiated, so trailing zeros need not be written.
+	data := mem.Buffer[:size]
+	for len(data) > 0 && data[len(data)-1] == 0 {
+		data = data[:len(data)-1]
+	}
+	offset := wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(0)}
+	if mem.Memory64 {
+		offset = wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: leb128.EncodeInt64(0)}
+	}
+	dump.DataSection = append(dump.DataSection, wasm.DataSegment{
+		OffsetExpression: offset,
+		Init:             append([]byte(nil), data...),
+		MemoryIndex:      idx,
+	})
+}
+
+// global returns the global initialized with the current value of g.
+func global(g *wasm.GlobalInstance) wasm.Global {
+	lo, hi := g.Value()
+	var init wasm.ConstantExpression
+	switch t := g.Type.ValType; t {
+	case api.ValueTypeI32:
+		init = wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(int32(lo))}
+	case api.ValueTypeI64:
+		init = wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: leb128.EncodeInt64(int64(lo))}
+	case api.ValueTypeF32:
+		init = wasm.ConstantExpression{Opcode: wasm.OpcodeF32Const, Data: binary.LittleEndian.AppendUint32(nil, uint32(lo))}
+	case api.ValueTypeF64:
+		init = wasm.ConstantExpression{Opcode: wasm.OpcodeF64Const, Data: binary.LittleEndian.AppendUint64(nil, lo)}
+	case wasm.ValueTypeV128:
+		data := binary.LittleEndian.AppendUint64(nil, lo)
+		init = wasm.ConstantExpression{Opcode: wasm.OpcodeVecV128Const, Data: binary.LittleEndian.AppendUint64(data, hi)}
+	default:
+		// References are addresses of the host, so they are dumped as null.
+		init = wasm.ConstantExpression{Opcode: wasm.OpcodeRefNull, Data: []byte{t}}
+	}
+	return wasm.Global{Type: g.Type, Init: init}
+}
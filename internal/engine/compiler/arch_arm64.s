@@ -8,8 +8,8 @@ TEXT ·nativecall(SB), NOSPLIT|NOFRAME, $0-24
 
 	// In arm64, return address is stored in R30 after jumping into the code.
 	// We save the return address value into archContext.compilerReturnAddress in Engine.
-	// Note that the const 144 drifts after editting Engine or archContext struct. See TestArchContextOffsetInEngine.
-	MOVD R30, 144(R0)
+	// Note that the const 160 drifts after editting Engine or archContext struct. See TestArchContextOffsetInEngine.
+	MOVD R30, 160(R0)
 
 	// Load the address of *wasm.ModuleInstance into arm64CallingConventionModuleInstanceAddressRegister.
 	MOVD moduleInstanceAddress+16(FP), R29
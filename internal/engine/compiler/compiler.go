@@ -44,6 +44,10 @@ type compiler interface {
 	compileCall(o *wazeroir.UnionOperation) error
 	// compileCallIndirect adds instructions to perform wazeroir.OperationCallIndirect.
 	compileCallIndirect(o *wazeroir.UnionOperation) error
+	// compileTailCall adds instructions to perform wazeroir.OperationTailCall.
+	compileTailCall(o *wazeroir.UnionOperation) error
+	// compileTailCallIndirect adds instructions to perform wazeroir.OperationTailCallIndirect.
+	compileTailCallIndirect(o *wazeroir.UnionOperation) error
 	// compileDrop adds instructions to perform wazeroir.NewOperationDrop.
 	compileDrop(o *wazeroir.UnionOperation) error
 	// compileSelect adds instructions to perform wazeroir.OperationSelect.
@@ -153,7 +157,7 @@ type compiler interface {
 	// compileMemorySize adds instruction to perform wazeroir.OperationMemoryGrow.
 	compileMemoryGrow() error
 	// compileMemorySize adds instruction to perform wazeroir.OperationMemorySize.
-	compileMemorySize() error
+	compileMemorySize(o *wazeroir.UnionOperation) error
 	// compileConstI32 adds instruction to perform wazeroir.NewOperationConstI32.
 	compileConstI32(o *wazeroir.UnionOperation) error
 	// compileConstI64 adds instruction to perform wazeroir.NewOperationConstI64.
@@ -180,6 +184,12 @@ type compiler interface {
 	compileMemoryCopy() error
 	// compileMemoryFill adds instructions to perform wazeroir.OperationMemoryFill.
 	compileMemoryFill() error
+	// compileMemoryBulkBuiltin adds instructions to perform the bulk memory operation o with the builtin function
+	// builtin. This is used for memory.copy between distinct memories and for bulk operations on 64-bit memories.
+	compileMemoryBulkBuiltin(o *wazeroir.UnionOperation, builtin wasm.Index) error
+	// compileSelectMemory adds instructions to make the subsequent memory instructions access the memory at the index
+	// instead of the first one. This is only used when the multi-memory feature is enabled.
+	compileSelectMemory(index uint32) error
 	// compileTableInit adds instructions to perform wazeroir.NewOperationTableInit.
 	compileTableInit(*wazeroir.UnionOperation) error
 	// compileTableCopy adds instructions to perform wazeroir.NewOperationTableCopy.
@@ -301,8 +311,27 @@ type compiler interface {
 	// compileV128ITruncSatFromF adds instructions to perform wazeroir.NewOperationV128ITruncSatFromF.
 	compileV128ITruncSatFromF(o *wazeroir.UnionOperation) error
 
+	// compileAtomic adds instructions to perform the atomic operations of the threads proposal, such as
+	// wazeroir.NewOperationAtomicLoad or wazeroir.NewOperationAtomicMemoryWait.
+	compileAtomic(o *wazeroir.UnionOperation) error
+
 	// compileBuiltinFunctionCheckExitCode adds instructions to perform wazeroir.OperationBuiltinFunctionCheckExitCode.
 	compileBuiltinFunctionCheckExitCode() error
+	// compileBuiltinFunctionConsumeFuel adds instructions to perform wazeroir.OperationBuiltinFunctionConsumeFuel.
+	compileBuiltinFunctionConsumeFuel() error
+	// compileBuiltinFunctionCheckEpochDeadline adds instructions to perform wazeroir.OperationBuiltinFunctionCheckEpochDeadline.
+	compileBuiltinFunctionCheckEpochDeadline() error
+
+	// compileThrow adds instructions to perform wazeroir.NewOperationThrow.
+	compileThrow(o *wazeroir.UnionOperation) error
+	// compileThrowRef adds instructions to perform wazeroir.NewOperationThrowRef.
+	compileThrowRef() error
+	// compileTryTable adds instructions to perform wazeroir.NewOperationTryTable, and prepares the landing pads of
+	// the catch clauses of wazeroir.CompilationResult ExceptionHandlers at o.U1.
+	compileTryTable(o *wazeroir.UnionOperation) error
+	// compileLandingPad adds instructions at the beginning of a landing pad of wazeroir.ExceptionHandlerCatch,
+	// where the execution resumes from Go after an exception is caught.
+	compileLandingPad() error
 
 	// compileReleaseRegisterToStack adds instructions to write the value on a register back to memory stack region.
 	compileReleaseRegisterToStack(loc *runtimeValueLocation)
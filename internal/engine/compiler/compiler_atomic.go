@@ -0,0 +1,257 @@
+package compiler
+
+import (
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasmruntime"
+	"github.com/AR1011/wazero/internal/wazeroir"
+)
+
+// Atomic instructions introduced by the threads proposal are rarely on the hot path, so rather than emitting
+// architecture specific atomic sequences, the compiled code pushes a descriptor of the operation onto the stack and
+// exits to Go via builtinFunctionIndexAtomic. The Go side then executes the operation with the same semantics as the
+// interpreter, serialized by wasm.MemoryInstance Mux.
+
+// atomicOperationDescriptor encodes the atomic operation o into a single uint64 which is pushed onto the stack
+// before calling builtinFunctionIndexAtomic.
+func atomicOperationDescriptor(o *wazeroir.UnionOperation) uint64 {
+	desc := uint64(o.Kind) | uint64(o.B1)<<16 | uint64(o.B2)<<24
+	if o.B3 {
+		// The static offset of a 64-bit memory can exceed 32 bits, so it is pushed separately before the descriptor.
+		return desc | atomicDescriptorMemory64
+	}
+	return desc | uint64(uint32(o.U2))<<32
+}
+
+// atomicDescriptorMemory64 is set in the descriptor when the atomic operation accesses a 64-bit memory
+// (api.CoreFeatureMemory64). It doesn't collide with the kind as there are much fewer than 1<<15 kinds.
+const atomicDescriptorMemory64 = 1 << 15
+
+// atomicOperationStackEffect returns the number of values consumed by the atomic operation o (excluding the
+// descriptor), and the type of the pushed result, or runtimeValueTypeNone if there's no result.
+func atomicOperationStackEffect(o *wazeroir.UnionOperation) (paramNum int, result runtimeValueType) {
+	resultType := runtimeValueTypeI32
+	if wazeroir.UnsignedType(o.B1) == wazeroir.UnsignedTypeI64 {
+		resultType = runtimeValueTypeI64
+	}
+	switch o.Kind {
+	case wazeroir.OperationKindAtomicMemoryWait:
+		return 3, runtimeValueTypeI32
+	case wazeroir.OperationKindAtomicMemoryNotify:
+		return 2, runtimeValueTypeI32
+	case wazeroir.OperationKindAtomicFence:
+		return 0, runtimeValueTypeNone
+	case wazeroir.OperationKindAtomicLoad, wazeroir.OperationKindAtomicLoad8, wazeroir.OperationKindAtomicLoad16:
+		return 1, resultType
+	case wazeroir.OperationKindAtomicStore, wazeroir.OperationKindAtomicStore8, wazeroir.OperationKindAtomicStore16:
+		return 2, runtimeValueTypeNone
+	case wazeroir.OperationKindAtomicRMW, wazeroir.OperationKindAtomicRMW8, wazeroir.OperationKindAtomicRMW16:
+		return 2, resultType
+	default: // Cmpxchg variants.
+		return 3, resultType
+	}
+}
+
+// builtinFunctionAtomic executes the atomic operation whose descriptor is on top of the stack.
+func (ce *callEngine) builtinFunctionAtomic(mem *wasm.MemoryInstance) {
+	desc := ce.popValue()
+	kind := wazeroir.OperationKind(uint16(desc) &^ atomicDescriptorMemory64)
+	is64 := wazeroir.UnsignedType(byte(desc>>16)) == wazeroir.UnsignedTypeI64
+	op := wazeroir.AtomicArithmeticOp(byte(desc >> 24))
+	memory64 := desc&atomicDescriptorMemory64 != 0
+	staticOffset := desc >> 32
+	if memory64 {
+		staticOffset = ce.popValue()
+	}
+
+	switch kind {
+	case wazeroir.OperationKindAtomicMemoryWait:
+		timeout := int64(ce.popValue())
+		exp := ce.popValue()
+		offset := ce.popAtomicMemoryOffset(staticOffset, memory64)
+		// Runtime instead of validation error because the spec intends to allow binaries to include
+		// such instructions as long as they are not executed.
+		if !mem.Shared {
+			panic(wasmruntime.ErrRuntimeExpectedSharedMemory)
+		}
+		if is64 {
+			checkAtomicAccess(mem, offset, 8)
+			ce.pushValue(mem.Wait64(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint64) uint64 {
+				mem.Mux.Lock()
+				defer mem.Mux.Unlock()
+				value, _ := mem.ReadUint64Le64(offset)
+				return value
+			}))
+		} else {
+			checkAtomicAccess(mem, offset, 4)
+			ce.pushValue(mem.Wait32(offset, uint32(exp), timeout, func(mem *wasm.MemoryInstance, offset uint64) uint32 {
+				mem.Mux.Lock()
+				defer mem.Mux.Unlock()
+				value, _ := mem.ReadUint32Le64(offset)
+				return value
+			}))
+		}
+	case wazeroir.OperationKindAtomicMemoryNotify:
+		count := ce.popValue()
+		offset := ce.popAtomicMemoryOffset(staticOffset, memory64)
+		checkAtomicAccess(mem, offset, 4)
+		// Just a no-op for unshared memory.
+		if mem.Shared {
+			ce.pushValue(uint64(mem.Notify(offset, uint32(count))))
+		} else {
+			ce.pushValue(0)
+		}
+	case wazeroir.OperationKindAtomicFence:
+		// Memory not required for fence only.
+		if mem != nil {
+			mem.Mux.Lock()
+			mem.Mux.Unlock() //nolint:staticcheck
+		}
+	case wazeroir.OperationKindAtomicLoad, wazeroir.OperationKindAtomicLoad8, wazeroir.OperationKindAtomicLoad16:
+		offset := ce.popAtomicMemoryOffset(staticOffset, memory64)
+		size := atomicAccessSize(kind, is64)
+		checkAtomicAccess(mem, offset, size)
+		mem.Mux.Lock()
+		val := readAtomic(mem, offset, size)
+		mem.Mux.Unlock()
+		ce.pushValue(val)
+	case wazeroir.OperationKindAtomicStore, wazeroir.OperationKindAtomicStore8, wazeroir.OperationKindAtomicStore16:
+		val := ce.popValue()
+		offset := ce.popAtomicMemoryOffset(staticOffset, memory64)
+		size := atomicAccessSize(kind, is64)
+		checkAtomicAccess(mem, offset, size)
+		mem.Mux.Lock()
+		writeAtomic(mem, offset, size, val)
+		mem.Mux.Unlock()
+	case wazeroir.OperationKindAtomicRMW, wazeroir.OperationKindAtomicRMW8, wazeroir.OperationKindAtomicRMW16:
+		val := ce.popValue()
+		offset := ce.popAtomicMemoryOffset(staticOffset, memory64)
+		size := atomicAccessSize(kind, is64)
+		checkAtomicAccess(mem, offset, size)
+		mem.Mux.Lock()
+		old := readAtomic(mem, offset, size)
+		writeAtomic(mem, offset, size, atomicArithmetic(op, old, val))
+		mem.Mux.Unlock()
+		ce.pushValue(old)
+	case wazeroir.OperationKindAtomicRMWCmpxchg, wazeroir.OperationKindAtomicRMW8Cmpxchg, wazeroir.OperationKindAtomicRMW16Cmpxchg:
+		rep := ce.popValue()
+		exp := ce.popValue()
+		offset := ce.popAtomicMemoryOffset(staticOffset, memory64)
+		size := atomicAccessSize(kind, is64)
+		checkAtomicAccess(mem, offset, size)
+		mem.Mux.Lock()
+		old := readAtomic(mem, offset, size)
+		if old == truncateAtomic(exp, size) {
+			writeAtomic(mem, offset, size, rep)
+		}
+		mem.Mux.Unlock()
+		ce.pushValue(old)
+	}
+
+	if mem != nil && mem.Shared {
+		// Shared memory may have been grown by another agent, so refresh the length seen by the compiled code.
+		// The buffer itself is never relocated as its capacity is reserved up to the maximum.
+		mem.Mux.Lock()
+		ce.moduleContext.memorySliceLen = uint64(len(mem.Buffer))
+		mem.Mux.Unlock()
+	}
+}
+
+// popAtomicMemoryOffset takes the dynamic address off the stack and adds the static offset of the instruction.
+func (ce *callEngine) popAtomicMemoryOffset(staticOffset uint64, memory64 bool) uint64 {
+	base := ce.popValue()
+	if !memory64 {
+		// The dynamic address is i32, so the upper 32-bits of the stack value must be ignored.
+		base = uint64(uint32(base))
+	}
+	offset := staticOffset + base
+	if offset < base {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	return offset
+}
+
+// atomicAccessSize returns the size in bytes of the memory accessed by the atomic operation of the kind.
+func atomicAccessSize(kind wazeroir.OperationKind, is64 bool) uint32 {
+	switch kind {
+	case wazeroir.OperationKindAtomicLoad8, wazeroir.OperationKindAtomicStore8,
+		wazeroir.OperationKindAtomicRMW8, wazeroir.OperationKindAtomicRMW8Cmpxchg:
+		return 1
+	case wazeroir.OperationKindAtomicLoad16, wazeroir.OperationKindAtomicStore16,
+		wazeroir.OperationKindAtomicRMW16, wazeroir.OperationKindAtomicRMW16Cmpxchg:
+		return 2
+	}
+	if is64 {
+		return 8
+	}
+	return 4
+}
+
+// checkAtomicAccess panics unless the access of the given size at the offset is within the memory and aligned to
+// the size, as required for atomic instructions.
+func checkAtomicAccess(mem *wasm.MemoryInstance, offset uint64, size uint32) {
+	if uint64(size) > uint64(len(mem.Buffer)) || offset > uint64(len(mem.Buffer))-uint64(size) {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	if offset%uint64(size) != 0 {
+		panic(wasmruntime.ErrRuntimeUnalignedAtomic)
+	}
+}
+
+// readAtomic reads the zero-extended value of the size at the offset. The caller must hold mem.Mux.
+func readAtomic(mem *wasm.MemoryInstance, offset uint64, size uint32) uint64 {
+	switch size {
+	case 1:
+		v, _ := mem.ReadByte64(offset)
+		return uint64(v)
+	case 2:
+		v, _ := mem.ReadUint16Le64(offset)
+		return uint64(v)
+	case 4:
+		v, _ := mem.ReadUint32Le64(offset)
+		return uint64(v)
+	default:
+		v, _ := mem.ReadUint64Le64(offset)
+		return v
+	}
+}
+
+// writeAtomic writes the value truncated to the size at the offset. The caller must hold mem.Mux.
+func writeAtomic(mem *wasm.MemoryInstance, offset uint64, size uint32, v uint64) {
+	switch size {
+	case 1:
+		mem.WriteByte64(offset, byte(v))
+	case 2:
+		mem.WriteUint16Le64(offset, uint16(v))
+	case 4:
+		mem.WriteUint32Le64(offset, uint32(v))
+	default:
+		mem.WriteUint64Le64(offset, v)
+	}
+}
+
+// truncateAtomic truncates v to the size in bytes.
+func truncateAtomic(v uint64, size uint32) uint64 {
+	if size == 8 {
+		return v
+	}
+	return v & (1<<(size*8) - 1)
+}
+
+// atomicArithmetic returns the result of applying op to the old value and the operand of a read-modify-write
+// instruction. The result is truncated to the width of the access on write.
+func atomicArithmetic(op wazeroir.AtomicArithmeticOp, old, val uint64) uint64 {
+	switch op {
+	case wazeroir.AtomicArithmeticOpAdd:
+		return old + val
+	case wazeroir.AtomicArithmeticOpSub:
+		return old - val
+	case wazeroir.AtomicArithmeticOpAnd:
+		return old & val
+	case wazeroir.AtomicArithmeticOpOr:
+		return old | val
+	case wazeroir.AtomicArithmeticOpXor:
+		return old ^ val
+	default: // wazeroir.AtomicArithmeticOpNop
+		return val
+	}
+}
@@ -2,6 +2,7 @@ package compiler
 
 import (
 	"github.com/AR1011/wazero/internal/asm"
+	"github.com/AR1011/wazero/internal/wasm"
 	"github.com/AR1011/wazero/internal/wazeroir"
 )
 
@@ -55,6 +56,45 @@ func compileDropRange(c compiler, raw uint64) (err error) {
 	return
 }
 
+// compileDropFrameForTailCall adds instructions to discard all the values of the current function frame, including the
+// parameters and locals, except for the arguments of the tail call to a function of calleeType on top of the stack.
+// The arguments are moved to the beginning of the frame, and the callFrame of the current function is moved right
+// after them, so that the stack looks as if the caller of the current function called the callee directly:
+//
+//	           reserved slots for results (if len(results) > len(args))
+//	                  |     |
+//	,arg0, ..., argN, ..., _, .returnAddress, .returnStackBasePointerInBytes, .function
+//
+// All the live values must be released to the stack before calling this.
+func compileDropFrameForTailCall(c compiler, currentType, calleeType *wasm.FunctionType) error {
+	locationStack := c.runtimeValueLocationStack()
+
+	// The callFrame might be overwritten by the arguments, so load it into registers beforehand.
+	returnAddress, callerStackBasePointerInBytes, callerFunction := locationStack.getCallFrameLocations(currentType)
+	callFrame := [3]runtimeValueLocation{*returnAddress, *callerStackBasePointerInBytes, *callerFunction}
+	for i := range callFrame {
+		reg, err := c.allocateRegister(registerTypeGeneralPurpose)
+		if err != nil {
+			return err
+		}
+		locationStack.markRegisterUsed(reg)
+		callFrame[i].setRegister(reg)
+		c.compileLoadValueOnStackToRegister(&callFrame[i])
+	}
+
+	r := wazeroir.InclusiveRange{Start: int32(calleeType.ParamNumInUint64), End: int32(locationStack.sp) - 1}
+	if err := compileDropRange(c, r.AsU64()); err != nil {
+		return err
+	}
+
+	returnAddress, callerStackBasePointerInBytes, callerFunction = locationStack.pushCallFrame(calleeType)
+	for i, loc := range [3]*runtimeValueLocation{returnAddress, callerStackBasePointerInBytes, callerFunction} {
+		loc.setRegister(callFrame[i].register)
+		c.compileReleaseRegisterToStack(loc)
+	}
+	return nil
+}
+
 // migrateLiveValue migrates the live value `live` into the top of the stack. It might be located on the stack
 // and in that case, we have to load it into either `generalPurposeTmpReg` or `vectorTmpReg` temporarily, and
 // write it back into the *new* stack location.
@@ -61,7 +61,7 @@ func TestCompiler_compileMemorySize(t *testing.T) {
 	require.NoError(t, err)
 
 	// Emit memory.size instructions.
-	err = compiler.compileMemorySize()
+	err = compiler.compileMemorySize(operationPtr(wazeroir.NewOperationMemorySize(0, false)))
 	require.NoError(t, err)
 	// At this point, the size of memory should be pushed onto the stack.
 	requireRuntimeLocationStackPointerEqual(t, uint64(1), compiler)
@@ -86,7 +86,7 @@ func TestCompiler_compileLoad(t *testing.T) {
 	loadTargetValue := uint64(0x12_34_56_78_9a_bc_ef_fe)
 	baseOffset := uint32(100)
 	arg := wazeroir.MemoryArg{Offset: 361}
-	offset := baseOffset + arg.Offset
+	offset := uint64(baseOffset) + arg.Offset
 
 	tests := []struct {
 		name                string
@@ -288,7 +288,7 @@ func TestCompiler_compileStore(t *testing.T) {
 	storeTargetValue := uint64(math.MaxUint64)
 	baseOffset := uint32(100)
 	arg := wazeroir.MemoryArg{Offset: 361}
-	offset := arg.Offset + baseOffset
+	offset := arg.Offset + uint64(baseOffset)
 
 	tests := []struct {
 		name                string
@@ -414,7 +414,7 @@ func TestCompiler_compileStore(t *testing.T) {
 
 			// Set the value on the left and right neighboring memoryregion,
 			// so that we can verify the operation doesn't affect there.
-			ceil := offset + tc.targetSizeInBytes
+			ceil := offset + uint64(tc.targetSizeInBytes)
 			mem := env.memory()
 			expectedNeighbor8Bytes := uint64(0x12_34_56_78_9a_bc_ef_fe)
 			binary.LittleEndian.PutUint64(mem[offset-8:offset], expectedNeighbor8Bytes)
@@ -458,7 +458,7 @@ func TestCompiler_MemoryOutOfBounds(t *testing.T) {
 					err = compiler.compileConstI32(operationPtr(wazeroir.NewOperationConstI32(base)))
 					require.NoError(t, err)
 
-					arg := wazeroir.MemoryArg{Offset: offset}
+					arg := wazeroir.MemoryArg{Offset: uint64(offset)}
 
 					switch targetSizeInByte {
 					case 1:
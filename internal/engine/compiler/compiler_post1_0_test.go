@@ -446,7 +446,7 @@ func TestCompiler_compileMemoryInit(t *testing.T) {
 			err = compiler.compileConstI32(operationPtr(wazeroir.NewOperationConstI32(tc.copySize)))
 			require.NoError(t, err)
 
-			err = compiler.compileMemoryInit(operationPtr(wazeroir.NewOperationMemoryInit(tc.dataIndex)))
+			err = compiler.compileMemoryInit(operationPtr(wazeroir.NewOperationMemoryInit(tc.dataIndex, 0, false)))
 			require.NoError(t, err)
 
 			code := asm.CodeSegment{}
@@ -54,6 +54,9 @@ func init() {
 	requireEqual(int(unsafe.Offsetof(ce.stackBasePointerInBytes)), callEngineStackContextStackBasePointerInBytesOffset, "callEngineStackContextStackBasePointerInBytesOffset")
 	requireEqual(int(unsafe.Offsetof(ce.stackElement0Address)), callEngineStackContextStackElement0AddressOffset, "callEngineStackContextStackElement0AddressOffset")
 	requireEqual(int(unsafe.Offsetof(ce.stackLenInBytes)), callEngineStackContextStackLenInBytesOffset, "callEngineStackContextStackLenInBytesOffset")
+	requireEqual(int(unsafe.Offsetof(ce.callStackDepth)), callEngineStackContextCallStackDepthOffset, "callEngineStackContextCallStackDepthOffset")
+	requireEqual(int(unsafe.Offsetof(ce.callStackDepthCeiling)), callEngineStackContextCallStackDepthCeilingOffset, "callEngineStackContextCallStackDepthCeilingOffset")
+	requireEqual(int(unsafe.Offsetof(ce.epochDeadline)), callEngineStackContextEpochDeadlineOffset, "callEngineStackContextEpochDeadlineOffset")
 
 	// Offsets for callEngine.exitContext.
 	requireEqual(int(unsafe.Offsetof(ce.statusCode)), callEngineExitContextNativeCallStatusCodeOffset, "callEngineExitContextNativeCallStatusCodeOffset")
@@ -70,8 +73,13 @@ func init() {
 	requireEqual(int(unsafe.Offsetof(f.codeInitialAddress)), functionCodeInitialAddressOffset, "functionCodeInitialAddressOffset")
 	requireEqual(int(unsafe.Offsetof(f.moduleInstance)), functionModuleInstanceOffset, "functionModuleInstanceOffset")
 	requireEqual(int(unsafe.Offsetof(f.typeID)), functionTypeIDOffset, "functionTypeIDOffset")
+	requireEqual(int(unsafe.Offsetof(f.parent)), functionParentOffset, "functionParentOffset")
 	requireEqual(int(unsafe.Sizeof(f)), functionSize, "functionModuleInstanceOffset")
 
+	// Offsets for compiledFunction.
+	var cf compiledFunction
+	requireEqual(int(unsafe.Offsetof(cf.goFunc)), compiledFunctionGoFuncOffset, "compiledFunctionGoFuncOffset")
+
 	// Offsets for wasm.ModuleInstance.
 	var moduleInstance wasm.ModuleInstance
 	requireEqual(int(unsafe.Offsetof(moduleInstance.Globals)), moduleInstanceGlobalsOffset, "moduleInstanceGlobalsOffset")
@@ -81,6 +89,10 @@ func init() {
 	requireEqual(int(unsafe.Offsetof(moduleInstance.TypeIDs)), moduleInstanceTypeIDsOffset, "moduleInstanceTypeIDsOffset")
 	requireEqual(int(unsafe.Offsetof(moduleInstance.DataInstances)), moduleInstanceDataInstancesOffset, "moduleInstanceDataInstancesOffset")
 	requireEqual(int(unsafe.Offsetof(moduleInstance.ElementInstances)), moduleInstanceElementInstancesOffset, "moduleInstanceElementInstancesOffset")
+	requireEqual(int(unsafe.Offsetof(moduleInstance.Memories)), moduleInstanceMemoriesOffset, "moduleInstanceMemoriesOffset")
+	requireEqual(int(unsafe.Offsetof(moduleInstance.Fuel)), moduleInstanceFuelOffset, "moduleInstanceFuelOffset")
+	requireEqual(int(unsafe.Offsetof(moduleInstance.EpochDeadline)), moduleInstanceEpochDeadlineOffset, "moduleInstanceEpochDeadlineOffset")
+	requireEqual(int(unsafe.Offsetof(moduleInstance.Epoch)), moduleInstanceEpochOffset, "moduleInstanceEpochOffset")
 
 	// Offsets for wasm.Table.
 	var tableInstance wasm.TableInstance
@@ -6,6 +6,7 @@ import (
 
 	"github.com/AR1011/wazero/internal/asm"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wazeroir"
 )
 
 var (
@@ -323,23 +324,7 @@ func (v *runtimeValueLocationStack) takeStealTargetFromUsedRegister(tp registerT
 // See the diagram in callEngine.stack.
 func (v *runtimeValueLocationStack) init(sig *wasm.FunctionType) {
 	for _, t := range sig.Params {
-		loc := v.pushRuntimeValueLocationOnStack()
-		switch t {
-		case wasm.ValueTypeI32:
-			loc.valueType = runtimeValueTypeI32
-		case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref:
-			loc.valueType = runtimeValueTypeI64
-		case wasm.ValueTypeF32:
-			loc.valueType = runtimeValueTypeF32
-		case wasm.ValueTypeF64:
-			loc.valueType = runtimeValueTypeF64
-		case wasm.ValueTypeV128:
-			loc.valueType = runtimeValueTypeV128Lo
-			hi := v.pushRuntimeValueLocationOnStack()
-			hi.valueType = runtimeValueTypeV128Hi
-		default:
-			panic("BUG")
-		}
+		v.pushWasmValueLocationOnStack(t)
 	}
 
 	// If the len(results) > len(args), the slots for all results are reserved after
@@ -355,6 +340,42 @@ func (v *runtimeValueLocationStack) init(sig *wasm.FunctionType) {
 	}
 }
 
+// pushWasmValueLocationOnStack pushes the runtimeValueLocation(s) of a value of the wasm.ValueType t which is
+// located on the stack.
+func (v *runtimeValueLocationStack) pushWasmValueLocationOnStack(t wasm.ValueType) {
+	loc := v.pushRuntimeValueLocationOnStack()
+	switch t {
+	case wasm.ValueTypeI32:
+		loc.valueType = runtimeValueTypeI32
+	case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref, wasm.ValueTypeExnref:
+		loc.valueType = runtimeValueTypeI64
+	case wasm.ValueTypeF32:
+		loc.valueType = runtimeValueTypeF32
+	case wasm.ValueTypeF64:
+		loc.valueType = runtimeValueTypeF64
+	case wasm.ValueTypeV128:
+		loc.valueType = runtimeValueTypeV128Lo
+		hi := v.pushRuntimeValueLocationOnStack()
+		hi.valueType = runtimeValueTypeV128Hi
+	default:
+		panic("BUG")
+	}
+}
+
+// pushExceptionPayload pushes the runtimeValueLocation(s) of the payload of the exception caught by the catch
+// clause, which is written onto the stack by callEngine.throw.
+func (v *runtimeValueLocationStack) pushExceptionPayload(catch *wazeroir.ExceptionHandlerCatch) {
+	if catch.TagType != nil {
+		for _, t := range catch.TagType.Params {
+			v.pushWasmValueLocationOnStack(t)
+		}
+	}
+	if catch.Kind == wasm.TryTableCatchKindCatchRef || catch.Kind == wasm.TryTableCatchKindCatchAllRef {
+		loc := v.pushRuntimeValueLocationOnStack()
+		loc.valueType = runtimeValueTypeI64
+	}
+}
+
 // getCallFrameLocations returns each field of callFrame's runtime location.
 //
 // See the diagram in callEngine.stack.
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"runtime"
 	"sort"
@@ -14,6 +15,7 @@ import (
 	"github.com/AR1011/wazero/experimental"
 	"github.com/AR1011/wazero/internal/asm"
 	"github.com/AR1011/wazero/internal/bitpack"
+	"github.com/AR1011/wazero/internal/coredump"
 	"github.com/AR1011/wazero/internal/filecache"
 	"github.com/AR1011/wazero/internal/internalapi"
 	"github.com/AR1011/wazero/internal/platform"
@@ -22,6 +24,7 @@ import (
 	"github.com/AR1011/wazero/internal/wasmdebug"
 	"github.com/AR1011/wazero/internal/wasmruntime"
 	"github.com/AR1011/wazero/internal/wazeroir"
+	"github.com/AR1011/wazero/sys"
 )
 
 // NOTE: The offset of many of the struct fields defined here are referenced from
@@ -141,6 +144,10 @@ type (
 		// stackIterator provides a way to iterate over the stack for Listeners.
 		// It is setup and valid only during a call to a Listener hook.
 		stackIterator stackIterator
+
+		// exceptions holds the exceptions caught with catch_ref or catch_all_ref during the current call. An
+		// exnref value on the stack is the index of this plus one, so that zero represents the null exnref.
+		exceptions []*api.Exception
 	}
 
 	// moduleContext holds the per-function call specific module information.
@@ -166,7 +173,8 @@ type (
 		memoryElement0Address uintptr
 		// memorySliceLen is the length of the memory buffer, i.e. len(ModuleInstance.Memory.Buffer).
 		memorySliceLen uint64
-		// memoryInstance holds the memory instance for this module instance.
+		// memoryInstance holds the memory instance for this module instance. This is the memory at index zero
+		// except while executing an instruction on another memory, which is selected by compiler.compileSelectMemory.
 		memoryInstance *wasm.MemoryInstance
 		// tableElement0Address is the address of the first item in the tables slice,
 		// i.e. &ModuleInstance.Tables[0] as uintptr.
@@ -212,6 +220,16 @@ type (
 		// stackLenInBytes is len(engine.stack[0]) * 8 (bytes).
 		// Note: this is updated when growing the stack in builtinFunctionGrowStack.
 		stackLenInBytes uint64
+
+		// callStackDepth is the number of the frames of the functions which limit the call stack depth.
+		// Note: this is only updated by the functions compiled with wazeroir.CompilationResult LimitsCallStackDepth.
+		callStackDepth uint64
+
+		// callStackDepthCeiling is the maximum of callStackDepth during the current call.
+		callStackDepthCeiling uint64
+
+		// epochDeadline is the epoch at which the current call traps in addition to wasm.ModuleInstance EpochDeadline.
+		epochDeadline uint64
 	}
 
 	// exitContext will be manipulated whenever compiled native code returns into the Go function.
@@ -305,6 +323,26 @@ type (
 		listener        experimental.FunctionListener
 		parent          *compiledCode
 		sourceOffsetMap sourceOffsetMap
+		// exceptionHandlers are the compiled wazeroir.ExceptionHandler of this function.
+		exceptionHandlers []exceptionHandler
+	}
+
+	// exceptionHandler is the compiled form of wazeroir.ExceptionHandler.
+	exceptionHandler struct {
+		// begin and end are the offsets of the range in the native code, where the exceptions are caught by
+		// this handler. Since the program counters of the frames are return addresses, the range is (begin, end].
+		begin, end uint64
+		// stackPointer is the stack pointer relative to the stack base pointer, where the payload of the
+		// exception is written when landing.
+		stackPointer uint64
+		catches      []exceptionHandlerCatch
+	}
+
+	exceptionHandlerCatch struct {
+		kind wasm.TryTableCatchKind
+		tag  wasm.Index
+		// padOffset is the offset of the landing pad in the native code.
+		padOffset uint64
 	}
 
 	// sourceOffsetMap holds the information to retrieve the original offset in
@@ -362,19 +400,26 @@ const (
 	callEngineStackContextStackBasePointerInBytesOffset = 96
 	callEngineStackContextStackElement0AddressOffset    = 104
 	callEngineStackContextStackLenInBytesOffset         = 112
+	callEngineStackContextCallStackDepthOffset          = 120
+	callEngineStackContextCallStackDepthCeilingOffset   = 128
+	callEngineStackContextEpochDeadlineOffset           = 136
 
 	// Offsets for callEngine exitContext.
-	callEngineExitContextNativeCallStatusCodeOffset     = 120
-	callEngineExitContextBuiltinFunctionCallIndexOffset = 124
-	callEngineExitContextReturnAddressOffset            = 128
-	callEngineExitContextCallerModuleInstanceOffset     = 136
+	callEngineExitContextNativeCallStatusCodeOffset     = 144
+	callEngineExitContextBuiltinFunctionCallIndexOffset = 148
+	callEngineExitContextReturnAddressOffset            = 152
+	callEngineExitContextCallerModuleInstanceOffset     = 160
 
 	// Offsets for function.
 	functionCodeInitialAddressOffset = 0
 	functionModuleInstanceOffset     = 8
 	functionTypeIDOffset             = 16
+	functionParentOffset             = 32
 	functionSize                     = 40
 
+	// Offsets for compiledFunction.
+	compiledFunctionGoFuncOffset = 24
+
 	// Offsets for wasm.ModuleInstance.
 	moduleInstanceGlobalsOffset          = 24
 	moduleInstanceMemoryOffset           = 48
@@ -383,6 +428,10 @@ const (
 	moduleInstanceTypeIDsOffset          = 96
 	moduleInstanceDataInstancesOffset    = 120
 	moduleInstanceElementInstancesOffset = 144
+	moduleInstanceMemoriesOffset         = 248
+	moduleInstanceFuelOffset             = 296
+	moduleInstanceEpochDeadlineOffset    = 304
+	moduleInstanceEpochOffset            = 312
 
 	// Offsets for wasm.TableInstance.
 	tableInstanceTableOffset    = 0
@@ -437,6 +486,13 @@ const (
 	nativeCallStatusCodeTypeMismatchOnIndirectCall
 	nativeCallStatusIntegerOverflow
 	nativeCallStatusIntegerDivisionByZero
+	// nativeCallStatusFuelExhausted means the fuel of the module instance ran out.
+	nativeCallStatusFuelExhausted
+	// nativeCallStatusEpochDeadlineExceeded means the epoch of the store reached the deadline of the module instance or
+	// of the current call.
+	nativeCallStatusEpochDeadlineExceeded
+	// nativeCallStatusCallStackOverflow means the depth of the call stack exceeded callStackDepthCeiling.
+	nativeCallStatusCallStackOverflow
 	nativeCallStatusModuleClosed
 )
 
@@ -458,6 +514,12 @@ func (s nativeCallStatusCode) causePanic() {
 		err = wasmruntime.ErrRuntimeInvalidTableAccess
 	case nativeCallStatusCodeTypeMismatchOnIndirectCall:
 		err = wasmruntime.ErrRuntimeIndirectCallTypeMismatch
+	case nativeCallStatusFuelExhausted:
+		err = sys.NewExitError(sys.ExitCodeFuelExhausted)
+	case nativeCallStatusEpochDeadlineExceeded:
+		err = sys.NewExitError(sys.ExitCodeEpochDeadlineExceeded)
+	case nativeCallStatusCallStackOverflow:
+		err = wasmruntime.ErrRuntimeStackOverflow
 	}
 	panic(err)
 }
@@ -484,6 +546,12 @@ func (s nativeCallStatusCode) String() (ret string) {
 		ret = "integer overflow"
 	case nativeCallStatusIntegerDivisionByZero:
 		ret = "integer division by zero"
+	case nativeCallStatusFuelExhausted:
+		ret = "fuel exhausted"
+	case nativeCallStatusEpochDeadlineExceeded:
+		ret = "epoch deadline exceeded"
+	case nativeCallStatusCallStackOverflow:
+		ret = "call stack overflow"
 	case nativeCallStatusModuleClosed:
 		ret = "module closed"
 	default:
@@ -596,7 +664,7 @@ func (e *engine) CompileModule(_ context.Context, module *wasm.Module, listeners
 			}
 			cmp.Init(typ, ir, compiledFn.listener != nil)
 
-			compiledFn.stackPointerCeil, compiledFn.sourceOffsetMap, err = compileWasmFunction(buf, cmp, ir, asmNodes, offsets)
+			compiledFn.stackPointerCeil, compiledFn.sourceOffsetMap, compiledFn.exceptionHandlers, err = compileWasmFunction(buf, cmp, ir, asmNodes, offsets)
 			if err != nil {
 				def := module.FunctionDefinition(compiledFn.index)
 				return fmt.Errorf("error compiling wasm func[%s]: %w", def.DebugName(), err)
@@ -661,6 +729,16 @@ func (e *moduleEngine) GetGlobalValue(wasm.Index) (lo, hi uint64) {
 	panic("BUG: GetGlobalValue should never be called on compiler mode")
 }
 
+// SetGlobalValue implements the same method as documented on wasm.ModuleEngine.
+func (e *moduleEngine) SetGlobalValue(wasm.Index, uint64, uint64) {
+	panic("BUG: SetGlobalValue should never be called on compiler mode")
+}
+
+// MemoryChanged implements the same method as documented on wasm.ModuleEngine.
+func (e *moduleEngine) MemoryChanged() {
+	// The memory is loaded from wasm.ModuleInstance on each function call, so nothing to do.
+}
+
 // OwnsGlobals implements the same method as documented on wasm.ModuleEngine.
 func (e *moduleEngine) OwnsGlobals() bool { return false }
 
@@ -722,6 +800,16 @@ func (ce *callEngine) Definition() api.FunctionDefinition {
 	return ce.initialFn.definition()
 }
 
+// FunctionReference returns the reference to this function as it's stored in a table, for api.Table SetFunction.
+func (ce *callEngine) FunctionReference() wasm.Reference {
+	return uintptr(unsafe.Pointer(ce.initialFn))
+}
+
+// limitsCallStackDepth returns true if the compiled code of f counts in callEngine.callStackDepth.
+func (f *function) limitsCallStackDepth() bool {
+	return f.parent.goFunc == nil && f.moduleInstance.Source.MaxCallStackDepth != 0
+}
+
 func (f *function) definition() api.FunctionDefinition {
 	compiled := f.parent
 	return compiled.parent.source.FunctionDefinition(compiled.index)
@@ -775,12 +863,18 @@ func (ce *callEngine) call(ctx context.Context, params, results []uint64) (_ []u
 
 	ft := ce.initialFn.funcType
 	ce.initializeStack(ft, params)
+	ce.initializeCallStackDepth(ctx)
 
 	if ce.module.ensureTermination {
 		done := m.CloseModuleOnCanceledOrTimeout(ctx)
 		defer done()
 	}
 
+	ce.epochDeadline = math.MaxUint64
+	if ticks, ok := ce.initialFn.parent.parent.source.CallEpochDeadline(ctx); ok {
+		ce.epochDeadline = m.EpochAfter(ticks)
+	}
+
 	ce.execWasmFunction(ctx, m)
 
 	// This returns a safe copy of the results, instead of a slice view. If we
@@ -790,6 +884,7 @@ func (ce *callEngine) call(ctx context.Context, params, results []uint64) (_ []u
 		results = make([]uint64, ft.ResultNumInUint64)
 	}
 	copy(results, ce.stack)
+	ce.resetExceptions()
 	return results, nil
 }
 
@@ -830,6 +925,17 @@ func (ce *callEngine) initializeStack(tp *wasm.FunctionType, args []uint64) {
 	}
 }
 
+// initializeCallStackDepth initializes callEngine.stackContext for the functions which limit the call stack depth.
+// See wazeroir.CompilationResult LimitsCallStackDepth.
+func (ce *callEngine) initializeCallStackDepth(ctx context.Context) {
+	ce.callStackDepth, ce.callStackDepthCeiling = 0, math.MaxUint64
+	// The ceiling is read from the module instance, as the compiled source is shared with the runtimes which have a
+	// different ceiling but use the same CompilationCache.
+	if ceiling := ce.initialFn.moduleInstance.Source.CallStackDepthCeiling(ctx); ceiling > 0 {
+		ce.callStackDepthCeiling = uint64(ceiling)
+	}
+}
+
 // callFrameOffset returns the offset of the call frame from the stack base pointer.
 //
 // See the diagram in callEngine.stack.
@@ -853,6 +959,10 @@ func (ce *callEngine) deferredOnCall(ctx context.Context, m *wasm.ModuleInstance
 		// Unwinds call frames from the values stack, starting from the
 		// current function `ce.fn`, and the current stack base pointer `ce.stackBasePointerInBytes`.
 		fn := ce.fn
+		var coreDump *coredump.Builder
+		if fn.parent.parent.source.CoreDump {
+			coreDump = &coredump.Builder{}
+		}
 		pc := uint64(ce.returnAddress)
 		stackBasePointer := int(ce.stackBasePointerInBytes >> 3)
 		functionListeners := make([]functionListenerInvocation, 0, 16)
@@ -867,6 +977,10 @@ func (ce *callEngine) deferredOnCall(ctx context.Context, m *wasm.ModuleInstance
 				if fn.parent.sourceOffsetMap.irOperationSourceOffsetsInWasmBinary != nil {
 					offset := fn.getSourceOffsetInWasmBinary(pc)
 					sources = p.parent.source.DWARFLines.Line(offset)
+					if coreDump != nil {
+						// Locals are not recorded, as they might be in registers.
+						coreDump.AddFrame(fn.moduleInstance, p.index, offset, nil)
+					}
 				}
 			}
 			builder.AddFrame(def.DebugName(), def.ParamTypes(), def.ResultTypes(), sources)
@@ -890,6 +1004,9 @@ func (ce *callEngine) deferredOnCall(ctx context.Context, m *wasm.ModuleInstance
 		}
 
 		err = builder.FromRecovered(recovered)
+		if coreDump != nil {
+			err = coreDump.Wrap(err)
+		}
 		for i := range functionListeners {
 			functionListeners[i].Abort(ctx, m, functionListeners[i].def, err)
 		}
@@ -898,6 +1015,93 @@ func (ce *callEngine) deferredOnCall(ctx context.Context, m *wasm.ModuleInstance
 	// Allows the reuse of CallEngine.
 	ce.stackBasePointerInBytes, ce.stackPointer, ce.moduleInstance = 0, 0, nil
 	ce.moduleContext.fn = ce.initialFn
+	ce.resetExceptions()
+	return
+}
+
+// resetExceptions releases the exceptions referenced by exnref values after the call.
+func (ce *callEngine) resetExceptions() {
+	for i := range ce.exceptions {
+		ce.exceptions[i] = nil
+	}
+	ce.exceptions = ce.exceptions[:0]
+}
+
+// throw unwinds the call frames to find the handler which catches the exception, starting from the current
+// function ce.fn at ce.returnAddress. This returns the address of the landing pad and the module instance of the
+// function to resume the execution with, or panics with the exception if it is not caught.
+func (ce *callEngine) throw(exception *api.Exception) (codeAddr uintptr, modAddr *wasm.ModuleInstance) {
+	fn := ce.fn
+	pc := uint64(ce.returnAddress)
+	stackBasePointer := ce.stackBasePointerInBytes >> 3
+	for {
+		handlers := fn.parent.exceptionHandlers
+		offset := pc - uint64(fn.codeInitialAddress)
+		for i := len(handlers) - 1; i >= 0; i-- {
+			h := &handlers[i]
+			if offset <= h.begin || offset > h.end {
+				continue
+			}
+			for j := range h.catches {
+				c := &h.catches[j]
+				var tag *wasm.TagInstance
+				if c.kind == wasm.TryTableCatchKindCatch || c.kind == wasm.TryTableCatchKindCatchRef {
+					tag = fn.moduleInstance.Tags[c.tag]
+					if api.Tag(tag) != exception.Tag() {
+						continue
+					}
+				}
+
+				// Unwind the stack to the frame of fn, and write the payload onto the stack.
+				ce.stackBasePointerInBytes = stackBasePointer << 3
+				ce.moduleContext.fn = fn
+				sp := stackBasePointer + h.stackPointer
+				if tag != nil {
+					params := exception.Params()
+					if len(params) != tag.Type.ParamNumInUint64 {
+						panic(fmt.Errorf("exception has %d params, but the tag expects %d", len(params), tag.Type.ParamNumInUint64))
+					}
+					sp += uint64(copy(ce.stack[sp:], params))
+				}
+				if c.kind == wasm.TryTableCatchKindCatchRef || c.kind == wasm.TryTableCatchKindCatchAllRef {
+					ce.exceptions = append(ce.exceptions, exception)
+					ce.stack[sp] = uint64(len(ce.exceptions))
+				}
+				return fn.codeInitialAddress + uintptr(c.padOffset), fn.moduleInstance
+			}
+		}
+
+		if stackBasePointer == 0 { // base == 0 means that this was the last call frame stacked.
+			panic(exception)
+		}
+		frame := *(*callFrame)(unsafe.Pointer(&ce.stack[stackBasePointer+uint64(callFrameOffset(fn.funcType))]))
+		if fn.limitsCallStackDepth() {
+			// The frame of fn is unwound without returning.
+			ce.callStackDepth--
+		}
+		fn = frame.function
+		pc = uint64(frame.returnAddress)
+		stackBasePointer = frame.returnStackBasePointerInBytes >> 3
+	}
+}
+
+// callGoFunc calls the host function fn with the stack, and returns the exception if fn panics with *api.Exception.
+// Other panics are propagated as is.
+func callGoFunc(ctx context.Context, fn interface{}, m *wasm.ModuleInstance, stack []uint64) (exception *api.Exception) {
+	defer func() {
+		if v := recover(); v != nil {
+			var ok bool
+			if exception, ok = v.(*api.Exception); !ok {
+				panic(v)
+			}
+		}
+	}()
+	switch fn := fn.(type) {
+	case api.GoModuleFunction:
+		fn.Call(ctx, m, stack)
+	case api.GoFunction:
+		fn.Call(ctx, stack)
+	}
 	return
 }
 
@@ -1026,6 +1230,12 @@ const (
 	builtinFunctionIndexFunctionListenerBefore
 	builtinFunctionIndexFunctionListenerAfter
 	builtinFunctionIndexCheckExitCode
+	builtinFunctionIndexAtomic
+	builtinFunctionIndexMemoryCopy
+	builtinFunctionIndexMemoryFill
+	builtinFunctionIndexMemoryInit
+	builtinFunctionIndexThrow
+	builtinFunctionIndexThrowRef
 	// builtinFunctionIndexBreakPoint is internal (only for wazero developers). Disabled by default.
 	builtinFunctionIndexBreakPoint
 )
@@ -1055,21 +1265,17 @@ entry:
 			}
 			stack := ce.stack[base : base+stackLen]
 
-			fn := calleeHostFunction.parent.goFunc
-			switch fn := fn.(type) {
-			case api.GoModuleFunction:
-				fn.Call(ctx, ce.callerModuleInstance, stack)
-			case api.GoFunction:
-				fn.Call(ctx, stack)
+			if exception := callGoFunc(ctx, calleeHostFunction.parent.goFunc, ce.callerModuleInstance, stack); exception != nil {
+				codeAddr, modAddr = ce.throw(exception)
+			} else {
+				codeAddr, modAddr = ce.returnAddress, ce.moduleInstance
 			}
-
-			codeAddr, modAddr = ce.returnAddress, ce.moduleInstance
 			goto entry
 		case nativeCallStatusCodeCallBuiltInFunction:
 			caller := ce.moduleContext.fn
 			switch ce.exitContext.builtinFunctionCallIndex {
 			case builtinFunctionIndexMemoryGrow:
-				ce.builtinFunctionMemoryGrow(caller.moduleInstance.MemoryInstance)
+				ce.builtinFunctionMemoryGrow(ce.moduleContext.memoryInstance)
 			case builtinFunctionIndexGrowStack:
 				ce.builtinFunctionGrowStack(caller.parent.stackPointerCeil)
 			case builtinFunctionIndexTableGrow:
@@ -1085,6 +1291,25 @@ entry:
 				if err := m.FailIfClosed(); err != nil {
 					panic(err)
 				}
+			case builtinFunctionIndexAtomic:
+				mem := caller.moduleInstance.MemoryInstance
+				if mem != nil {
+					// The operation might target a memory other than the first one.
+					mem = ce.moduleContext.memoryInstance
+				}
+				ce.builtinFunctionAtomic(mem)
+			case builtinFunctionIndexMemoryCopy:
+				ce.builtinFunctionMemoryCopy(caller.moduleInstance.Memories)
+			case builtinFunctionIndexMemoryFill:
+				ce.builtinFunctionMemoryFill(caller.moduleInstance.Memories)
+			case builtinFunctionIndexMemoryInit:
+				ce.builtinFunctionMemoryInit(caller.moduleInstance.Memories, caller.moduleInstance.DataInstances)
+			case builtinFunctionIndexThrow:
+				codeAddr, modAddr = ce.throw(ce.builtinFunctionThrow(caller.moduleInstance.Tags))
+				goto entry
+			case builtinFunctionIndexThrowRef:
+				codeAddr, modAddr = ce.throw(ce.builtinFunctionThrowRef())
+				goto entry
 			}
 			if false {
 				if ce.exitContext.builtinFunctionCallIndex == builtinFunctionIndexBreakPoint {
@@ -1125,10 +1350,38 @@ func (ce *callEngine) builtinFunctionGrowStack(stackPointerCeil uint64) {
 	ce.stackContext.stackLenInBytes = newLen << 3
 }
 
+// builtinFunctionThrow pops the tag index pushed by wazeroir.OperationKindThrow and the params of the tag, and
+// returns the exception to throw.
+func (ce *callEngine) builtinFunctionThrow(tags []*wasm.TagInstance) *api.Exception {
+	tag := tags[ce.popValue()]
+	params := make([]uint64, tag.Type.ParamNumInUint64)
+	for i := len(params) - 1; i >= 0; i-- {
+		params[i] = ce.popValue()
+	}
+	return api.NewException(tag, params...)
+}
+
+// builtinFunctionThrowRef pops the exnref, and returns the exception it refers to.
+func (ce *callEngine) builtinFunctionThrowRef() *api.Exception {
+	ref := ce.popValue()
+	if ref == 0 {
+		panic(wasmruntime.ErrRuntimeNullExceptionReference)
+	}
+	return ce.exceptions[ref-1]
+}
+
 func (ce *callEngine) builtinFunctionMemoryGrow(mem *wasm.MemoryInstance) {
 	newPages := ce.popValue()
 
-	if res, ok := mem.Grow(uint32(newPages)); !ok {
+	if mem.Memory64 {
+		if newPages > math.MaxUint32 {
+			ce.pushValue(math.MaxUint64) // = -1 in signed 64-bit integer.
+		} else if res, ok := mem.Grow(uint32(newPages)); !ok {
+			ce.pushValue(math.MaxUint64)
+		} else {
+			ce.pushValue(uint64(res))
+		}
+	} else if res, ok := mem.Grow(uint32(newPages)); !ok {
 		ce.pushValue(uint64(0xffffffff)) // = -1 in signed 32-bit integer.
 	} else {
 		ce.pushValue(uint64(res))
@@ -1140,6 +1393,78 @@ func (ce *callEngine) builtinFunctionMemoryGrow(mem *wasm.MemoryInstance) {
 	ce.moduleContext.memoryElement0Address = bufSliceHeader.Data
 }
 
+// memoryBulkDescriptor encodes the immediates of the bulk memory operation o into a single uint64 which is pushed
+// onto the stack before calling builtinFunctionIndexMemoryCopy, builtinFunctionIndexMemoryFill or
+// builtinFunctionIndexMemoryInit. These are the destination and source memory indexes for memory.copy, the memory
+// index for memory.fill, and the data and memory indexes for memory.init.
+func memoryBulkDescriptor(o *wazeroir.UnionOperation) uint64 {
+	return o.U1 | o.U2<<32
+}
+
+// popMemoryAddress pops the address operand for mem, which is i64 if mem is 64-bit, i32 otherwise.
+func (ce *callEngine) popMemoryAddress(mem *wasm.MemoryInstance) uint64 {
+	if mem.Memory64 {
+		return ce.popValue()
+	}
+	return uint64(uint32(ce.popValue()))
+}
+
+// rangeOutOfBounds returns true if [offset, offset+size) is not within the length without overflowing.
+func rangeOutOfBounds(offset, size, length uint64) bool {
+	return offset > length || size > length-offset
+}
+
+// builtinFunctionMemoryCopy executes memory.copy whose destination and source memory indexes are encoded in the
+// descriptor on top of the stack.
+func (ce *callEngine) builtinFunctionMemoryCopy(memories []*wasm.MemoryInstance) {
+	desc := ce.popValue()
+	dst, src := memories[uint32(desc)], memories[uint32(desc>>32)]
+	copySize := ce.popValue()
+	if !dst.Memory64 || !src.Memory64 {
+		// The size is i32 unless both memories are 64-bit.
+		copySize = uint64(uint32(copySize))
+	}
+	sourceOffset := ce.popMemoryAddress(src)
+	destinationOffset := ce.popMemoryAddress(dst)
+	if rangeOutOfBounds(sourceOffset, copySize, uint64(len(src.Buffer))) ||
+		rangeOutOfBounds(destinationOffset, copySize, uint64(len(dst.Buffer))) {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	} else if copySize != 0 {
+		copy(dst.Buffer[destinationOffset:], src.Buffer[sourceOffset:sourceOffset+copySize])
+	}
+}
+
+// builtinFunctionMemoryFill executes memory.fill whose memory index is encoded in the descriptor on top of the stack.
+func (ce *callEngine) builtinFunctionMemoryFill(memories []*wasm.MemoryInstance) {
+	mem := memories[uint32(ce.popValue())]
+	size := ce.popMemoryAddress(mem)
+	value := byte(ce.popValue())
+	offset := ce.popMemoryAddress(mem)
+	if rangeOutOfBounds(offset, size, uint64(len(mem.Buffer))) {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	buf := mem.Buffer[offset : offset+size]
+	for i := range buf {
+		buf[i] = value
+	}
+}
+
+// builtinFunctionMemoryInit executes memory.init whose data and memory indexes are encoded in the descriptor on top
+// of the stack.
+func (ce *callEngine) builtinFunctionMemoryInit(memories []*wasm.MemoryInstance, dataInstances []wasm.DataInstance) {
+	desc := ce.popValue()
+	data, mem := dataInstances[uint32(desc)], memories[uint32(desc>>32)]
+	copySize := uint64(uint32(ce.popValue()))
+	inDataOffset := uint64(uint32(ce.popValue()))
+	offset := ce.popMemoryAddress(mem)
+	if rangeOutOfBounds(inDataOffset, copySize, uint64(len(data))) ||
+		rangeOutOfBounds(offset, copySize, uint64(len(mem.Buffer))) {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	} else if copySize != 0 {
+		copy(mem.Buffer[offset:], data[inDataOffset:inDataOffset+copySize])
+	}
+}
+
 func (ce *callEngine) builtinFunctionTableGrow(tables []*wasm.TableInstance) {
 	tableIndex := uint32(ce.popValue())
 	table := tables[tableIndex] // verified not to be out of range by the func validation at compilation phase.
@@ -1252,23 +1577,37 @@ type offsets struct {
 	values []uint64
 }
 
-func compileWasmFunction(buf asm.Buffer, cmp compiler, ir *wazeroir.CompilationResult, asmNodes *asmNodes, offsets *offsets) (spCeil uint64, sm sourceOffsetMap, err error) {
+func compileWasmFunction(buf asm.Buffer, cmp compiler, ir *wazeroir.CompilationResult, asmNodes *asmNodes, offsets *offsets) (spCeil uint64, sm sourceOffsetMap, handlers []exceptionHandler, err error) {
 	if err = cmp.compilePreamble(); err != nil {
 		err = fmt.Errorf("failed to emit preamble: %w", err)
 		return
 	}
 
 	needSourceOffsets := len(ir.IROperationSourceOffsetsInWasmBinary) > 0
+	// The offsets of IR operations are also necessary to resolve the ranges and the landing pads of exception handlers.
+	hasExceptionHandlers := len(ir.ExceptionHandlers) > 0
 	var irOpBegins []asm.Node
-	if needSourceOffsets {
-		irOpBegins = append(asmNodes.nodes[:0], make([]asm.Node, len(ir.Operations))...)
+	// landingPads maps the labels of the landing pads to the index of the operations.
+	var landingPads map[wazeroir.Label]int
+	if needSourceOffsets || hasExceptionHandlers {
+		// One more node for the end of the last operation.
+		irOpBegins = append(asmNodes.nodes[:0], make([]asm.Node, len(ir.Operations)+1)...)
 		defer func() { asmNodes.nodes = irOpBegins }()
 	}
+	if hasExceptionHandlers {
+		handlers = make([]exceptionHandler, len(ir.ExceptionHandlers))
+		landingPads = make(map[wazeroir.Label]int)
+		for i := range ir.ExceptionHandlers {
+			for _, c := range ir.ExceptionHandlers[i].Catches {
+				landingPads[c.Label] = -1
+			}
+		}
+	}
 
 	var skip bool
 	for i := range ir.Operations {
 		op := &ir.Operations[i]
-		if needSourceOffsets {
+		if irOpBegins != nil {
 			// If this compilation requires source offsets for DWARF based back trace,
 			// we emit a NOP node at the beginning of each IR operation to get the
 			// binary offset of the beginning of the corresponding compiled native code.
@@ -1280,6 +1619,13 @@ func compileWasmFunction(buf asm.Buffer, cmp compiler, ir *wazeroir.CompilationR
 		// we don't need to generate native code at all as we never reach the region.
 		if op.Kind == wazeroir.OperationKindLabel {
 			skip = cmp.compileLabel(op)
+			if _, ok := landingPads[wazeroir.Label(op.U1)]; ok && !skip {
+				landingPads[wazeroir.Label(op.U1)] = i
+				if err = cmp.compileLandingPad(); err != nil {
+					err = fmt.Errorf("landing pad: %w", err)
+					return
+				}
+			}
 		}
 		if skip {
 			continue
@@ -1288,6 +1634,14 @@ func compileWasmFunction(buf asm.Buffer, cmp compiler, ir *wazeroir.CompilationR
 		if false {
 			fmt.Printf("compiling op=%s: %s\n", op.Kind, cmp)
 		}
+		memoryIndex := operationMemoryIndex(op)
+		if memoryIndex != 0 {
+			if err = cmp.compileSelectMemory(memoryIndex); err != nil {
+				err = fmt.Errorf("operation %s: %w", op.Kind.String(), err)
+				return
+			}
+		}
+
 		switch op.Kind {
 		case wazeroir.OperationKindUnreachable:
 			err = cmp.compileUnreachable()
@@ -1303,6 +1657,10 @@ func compileWasmFunction(buf asm.Buffer, cmp compiler, ir *wazeroir.CompilationR
 			err = cmp.compileCall(op)
 		case wazeroir.OperationKindCallIndirect:
 			err = cmp.compileCallIndirect(op)
+		case wazeroir.OperationKindTailCall:
+			err = cmp.compileTailCall(op)
+		case wazeroir.OperationKindTailCallIndirect:
+			err = cmp.compileTailCallIndirect(op)
 		case wazeroir.OperationKindDrop:
 			err = cmp.compileDrop(op)
 		case wazeroir.OperationKindSelect:
@@ -1332,7 +1690,7 @@ func compileWasmFunction(buf asm.Buffer, cmp compiler, ir *wazeroir.CompilationR
 		case wazeroir.OperationKindStore32:
 			err = cmp.compileStore32(op)
 		case wazeroir.OperationKindMemorySize:
-			err = cmp.compileMemorySize()
+			err = cmp.compileMemorySize(op)
 		case wazeroir.OperationKindMemoryGrow:
 			err = cmp.compileMemoryGrow()
 		case wazeroir.OperationKindConstI32:
@@ -1438,13 +1796,25 @@ func compileWasmFunction(buf asm.Buffer, cmp compiler, ir *wazeroir.CompilationR
 		case wazeroir.OperationKindSignExtend64From32:
 			err = cmp.compileSignExtend64From32()
 		case wazeroir.OperationKindMemoryInit:
-			err = cmp.compileMemoryInit(op)
+			if op.B3 {
+				err = cmp.compileMemoryBulkBuiltin(op, builtinFunctionIndexMemoryInit)
+			} else {
+				err = cmp.compileMemoryInit(op)
+			}
 		case wazeroir.OperationKindDataDrop:
 			err = cmp.compileDataDrop(op)
 		case wazeroir.OperationKindMemoryCopy:
-			err = cmp.compileMemoryCopy()
+			if op.U1 != op.U2 || op.B3 {
+				err = cmp.compileMemoryBulkBuiltin(op, builtinFunctionIndexMemoryCopy)
+			} else {
+				err = cmp.compileMemoryCopy()
+			}
 		case wazeroir.OperationKindMemoryFill:
-			err = cmp.compileMemoryFill()
+			if op.B3 {
+				err = cmp.compileMemoryBulkBuiltin(op, builtinFunctionIndexMemoryFill)
+			} else {
+				err = cmp.compileMemoryFill()
+			}
 		case wazeroir.OperationKindTableInit:
 			err = cmp.compileTableInit(op)
 		case wazeroir.OperationKindElemDrop:
@@ -1565,27 +1935,77 @@ func compileWasmFunction(buf asm.Buffer, cmp compiler, ir *wazeroir.CompilationR
 			err = cmp.compileV128Narrow(op)
 		case wazeroir.OperationKindV128ITruncSatFromF:
 			err = cmp.compileV128ITruncSatFromF(op)
+		case wazeroir.OperationKindAtomicMemoryWait,
+			wazeroir.OperationKindAtomicMemoryNotify,
+			wazeroir.OperationKindAtomicFence,
+			wazeroir.OperationKindAtomicLoad,
+			wazeroir.OperationKindAtomicLoad8,
+			wazeroir.OperationKindAtomicLoad16,
+			wazeroir.OperationKindAtomicStore,
+			wazeroir.OperationKindAtomicStore8,
+			wazeroir.OperationKindAtomicStore16,
+			wazeroir.OperationKindAtomicRMW,
+			wazeroir.OperationKindAtomicRMW8,
+			wazeroir.OperationKindAtomicRMW16,
+			wazeroir.OperationKindAtomicRMWCmpxchg,
+			wazeroir.OperationKindAtomicRMW8Cmpxchg,
+			wazeroir.OperationKindAtomicRMW16Cmpxchg:
+			err = cmp.compileAtomic(op)
 		case wazeroir.OperationKindBuiltinFunctionCheckExitCode:
 			err = cmp.compileBuiltinFunctionCheckExitCode()
+		case wazeroir.OperationKindBuiltinFunctionConsumeFuel:
+			err = cmp.compileBuiltinFunctionConsumeFuel()
+		case wazeroir.OperationKindBuiltinFunctionCheckEpochDeadline:
+			err = cmp.compileBuiltinFunctionCheckEpochDeadline()
+		case wazeroir.OperationKindThrow:
+			err = cmp.compileThrow(op)
+		case wazeroir.OperationKindThrowRef:
+			err = cmp.compileThrowRef()
+		case wazeroir.OperationKindTryTable:
+			// The payload of the exceptions is written above the values below the parameters of the block.
+			handlers[op.U1].stackPointer = cmp.runtimeValueLocationStack().sp - op.U2
+			err = cmp.compileTryTable(op)
 		default:
 			err = errors.New("unsupported")
 		}
+		if err == nil && memoryIndex != 0 {
+			// Switches back to the first memory which is assumed by all the other operations.
+			err = cmp.compileSelectMemory(0)
+		}
 		if err != nil {
 			err = fmt.Errorf("operation %s: %w", op.Kind.String(), err)
 			return
 		}
 	}
 
+	if irOpBegins != nil {
+		irOpBegins[len(ir.Operations)] = cmp.compileNOP()
+	}
+
 	spCeil, err = cmp.compile(buf)
 	if err != nil {
 		err = fmt.Errorf("failed to compile: %w", err)
 		return
 	}
 
+	for i := range ir.ExceptionHandlers {
+		h, compiled := &ir.ExceptionHandlers[i], &handlers[i]
+		compiled.begin, compiled.end = irOpBegins[h.Begin].OffsetInBinary(), irOpBegins[h.End].OffsetInBinary()
+		compiled.catches = make([]exceptionHandlerCatch, len(h.Catches))
+		for j := range h.Catches {
+			c := &h.Catches[j]
+			compiled.catches[j] = exceptionHandlerCatch{kind: c.Kind, tag: c.Tag}
+			// The landing pad is skipped if the try_table is never reached.
+			if index := landingPads[c.Label]; index >= 0 {
+				compiled.catches[j].padOffset = irOpBegins[index].OffsetInBinary()
+			}
+		}
+	}
+
 	if needSourceOffsets {
-		offsetInNativeBin := append(offsets.values[:0], make([]uint64, len(irOpBegins))...)
+		offsetInNativeBin := append(offsets.values[:0], make([]uint64, len(ir.Operations))...)
 		offsets.values = offsetInNativeBin
-		for i, nop := range irOpBegins {
+		for i, nop := range irOpBegins[:len(ir.Operations)] {
 			offsetInNativeBin[i] = nop.OffsetInBinary()
 		}
 		sm.irOperationOffsetsInNativeBinary = bitpack.NewOffsetArray(offsetInNativeBin)
@@ -1593,3 +2013,28 @@ func compileWasmFunction(buf asm.Buffer, cmp compiler, ir *wazeroir.CompilationR
 	}
 	return
 }
+
+// operationMemoryIndex returns the index of the memory accessed by the operation op, or zero if op doesn't access
+// any memory or accesses the first one.
+func operationMemoryIndex(op *wazeroir.UnionOperation) uint32 {
+	switch op.Kind {
+	case wazeroir.OperationKindLoad, wazeroir.OperationKindLoad8, wazeroir.OperationKindLoad16, wazeroir.OperationKindLoad32,
+		wazeroir.OperationKindStore, wazeroir.OperationKindStore8, wazeroir.OperationKindStore16, wazeroir.OperationKindStore32,
+		wazeroir.OperationKindV128Load, wazeroir.OperationKindV128LoadLane, wazeroir.OperationKindV128Store, wazeroir.OperationKindV128StoreLane,
+		wazeroir.OperationKindAtomicMemoryWait, wazeroir.OperationKindAtomicMemoryNotify,
+		wazeroir.OperationKindAtomicLoad, wazeroir.OperationKindAtomicLoad8, wazeroir.OperationKindAtomicLoad16,
+		wazeroir.OperationKindAtomicStore, wazeroir.OperationKindAtomicStore8, wazeroir.OperationKindAtomicStore16,
+		wazeroir.OperationKindAtomicRMW, wazeroir.OperationKindAtomicRMW8, wazeroir.OperationKindAtomicRMW16,
+		wazeroir.OperationKindAtomicRMWCmpxchg, wazeroir.OperationKindAtomicRMW8Cmpxchg, wazeroir.OperationKindAtomicRMW16Cmpxchg:
+		return uint32(op.U3)
+	case wazeroir.OperationKindMemorySize, wazeroir.OperationKindMemoryGrow, wazeroir.OperationKindMemoryFill:
+		return uint32(op.U1)
+	case wazeroir.OperationKindMemoryInit:
+		return uint32(op.U2)
+	case wazeroir.OperationKindMemoryCopy:
+		if op.U1 == op.U2 {
+			return uint32(op.U1)
+		}
+	}
+	return 0
+}
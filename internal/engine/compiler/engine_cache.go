@@ -3,6 +3,7 @@ package compiler
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"runtime"
@@ -39,18 +40,55 @@ func (e *engine) getCompiledModule(module *wasm.Module, listeners []experimental
 	}
 	cm, ok, err = e.getCompiledModuleFromCache(module)
 	if ok {
-		e.addCompiledModuleToMemory(module, cm)
-		if len(listeners) > 0 {
-			// Files do not contain the actual listener instances (it's impossible to cache them as files!), so assign each here.
-			for i := range cm.functions {
-				cm.functions[i].listener = listeners[i]
-			}
+		e.addDeserializedCompiledModule(module, cm, listeners)
+	}
+	return
+}
+
+// addDeserializedCompiledModule makes the compiledModule read from outside the process ready to use.
+func (e *engine) addDeserializedCompiledModule(module *wasm.Module, cm *compiledModule, listeners []experimental.FunctionListener) {
+	e.addCompiledModuleToMemory(module, cm)
+	if len(listeners) > 0 {
+		// Files do not contain the actual listener instances (it's impossible to cache them as files!), so assign each here.
+		for i := range cm.functions {
+			cm.functions[i].listener = listeners[i]
 		}
+	}
 
-		// As this uses mmap, we need to munmap on the compiled machine code when it's GCed.
-		e.setFinalizer(cm, releaseCompiledModule)
+	// As this uses mmap, we need to munmap on the compiled machine code when it's GCed.
+	e.setFinalizer(cm, releaseCompiledModule)
+}
+
+// SerializeCompiledModule implements the same method as documented on wasm.EngineSerializer.
+func (e *engine) SerializeCompiledModule(module *wasm.Module, w io.Writer) error {
+	if module.IsHostModule {
+		return errors.New("host modules cannot be serialized")
 	}
-	return
+	cm, ok := e.getCompiledModuleFromMemory(module)
+	if !ok {
+		return errors.New("source module must be compiled before serialization")
+	}
+	_, err := io.Copy(w, serializeCompiledModule(e.wazeroVersion, cm))
+	return err
+}
+
+// DeserializeCompiledModule implements the same method as documented on wasm.EngineSerializer.
+func (e *engine) DeserializeCompiledModule(module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool, r io.Reader) error {
+	if _, ok := e.getCompiledModuleFromMemory(module); ok {
+		return nil // Already compiled or deserialized in this engine.
+	}
+
+	cm, staleCache, err := deserializeCompiledModule(e.wazeroVersion, io.NopCloser(r), module)
+	if err != nil {
+		return err
+	} else if staleCache {
+		return errors.New("compiled module was serialized by a different version of wazero")
+	} else if cm.ensureTermination != ensureTermination {
+		return fmt.Errorf("compiled module was serialized with ensureTermination=%v", cm.ensureTermination)
+	}
+	cm.source = module
+	e.addDeserializedCompiledModule(module, cm, listeners)
+	return nil
 }
 
 func (e *engine) addCompiledModuleToMemory(module *wasm.Module, cm *compiledModule) {
@@ -130,9 +168,37 @@ func serializeCompiledModule(wazeroVersion string, cm *compiledModule) io.Reader
 	buf.Write(u64.LeBytes(uint64(cm.executable.Len())))
 	// Append the native code.
 	buf.Write(cm.executable.Bytes())
+	// Append the exception handlers of each function only if any, so that the others are encoded as before.
+	for i := range cm.functions {
+		if len(cm.functions[i].exceptionHandlers) > 0 {
+			serializeExceptionHandlers(buf, cm.functions)
+			break
+		}
+	}
 	return bytes.NewReader(buf.Bytes())
 }
 
+// serializeExceptionHandlers writes the number of exception handlers (4 bytes) for each function followed by them.
+func serializeExceptionHandlers(buf *bytes.Buffer, functions []compiledFunction) {
+	for i := range functions {
+		handlers := functions[i].exceptionHandlers
+		buf.Write(u32.LeBytes(uint32(len(handlers))))
+		for j := range handlers {
+			h := &handlers[j]
+			buf.Write(u64.LeBytes(h.begin))
+			buf.Write(u64.LeBytes(h.end))
+			buf.Write(u64.LeBytes(h.stackPointer))
+			buf.Write(u32.LeBytes(uint32(len(h.catches))))
+			for k := range h.catches {
+				c := &h.catches[k]
+				buf.WriteByte(c.kind)
+				buf.Write(u32.LeBytes(c.tag))
+				buf.Write(u64.LeBytes(c.padOffset))
+			}
+		}
+	}
+}
+
 func deserializeCompiledModule(wazeroVersion string, reader io.ReadCloser, module *wasm.Module) (cm *compiledModule, staleCache bool, err error) {
 	defer reader.Close()
 	cacheHeaderSize := len(wazeroMagic) + 1 /* version size */ + len(wazeroVersion) + 1 /* ensure termination */ + 4 /* number of functions */
@@ -148,6 +214,10 @@ func deserializeCompiledModule(wazeroVersion string, reader io.ReadCloser, modul
 		return nil, false, fmt.Errorf("compilationcache: invalid header length: %d", n)
 	}
 
+	if magic := string(header[:len(wazeroMagic)]); magic != wazeroMagic {
+		return nil, false, fmt.Errorf("compilationcache: invalid magic number: got %s but want %s", magic, wazeroMagic)
+	}
+
 	// Check the version compatibility.
 	versionSize := int(header[len(wazeroMagic)])
 
@@ -216,9 +286,77 @@ func deserializeCompiledModule(wazeroVersion string, reader io.ReadCloser, modul
 			}
 		}
 	}
+
+	if err = deserializeExceptionHandlers(reader, cm.functions); err != nil {
+		err = fmt.Errorf("compilationcache: error reading exception handlers: %v", err)
+	}
 	return
 }
 
+// deserializeExceptionHandlers reads the exception handlers written by serializeExceptionHandlers if any.
+func deserializeExceptionHandlers(reader io.Reader, functions []compiledFunction) error {
+	var fourBytes [4]byte
+	var eightBytes [8]byte
+	for i := range functions {
+		handlersNum, err := readUint32(reader, &fourBytes)
+		if err == io.EOF && i == 0 {
+			return nil // No function has exception handlers.
+		} else if err != nil {
+			return err
+		}
+		if handlersNum == 0 {
+			continue
+		}
+
+		handlers := make([]exceptionHandler, handlersNum)
+		for j := range handlers {
+			h := &handlers[j]
+			if h.begin, err = readUint64(reader, &eightBytes); err != nil {
+				return err
+			}
+			if h.end, err = readUint64(reader, &eightBytes); err != nil {
+				return err
+			}
+			if h.stackPointer, err = readUint64(reader, &eightBytes); err != nil {
+				return err
+			}
+			catchesNum, err := readUint32(reader, &fourBytes)
+			if err != nil {
+				return err
+			}
+			h.catches = make([]exceptionHandlerCatch, catchesNum)
+			for k := range h.catches {
+				c := &h.catches[k]
+				if _, err = io.ReadFull(reader, fourBytes[:1]); err != nil {
+					return err
+				}
+				c.kind = fourBytes[0]
+				if c.tag, err = readUint32(reader, &fourBytes); err != nil {
+					return err
+				}
+				if c.padOffset, err = readUint64(reader, &eightBytes); err != nil {
+					return err
+				}
+			}
+		}
+		functions[i].exceptionHandlers = handlers
+	}
+	return nil
+}
+
+// readUint32 strictly reads an uint32 in little-endian byte order, using the
+// given array as a buffer. This returns io.EOF if less than 4 bytes were read.
+func readUint32(reader io.Reader, b *[4]byte) (uint32, error) {
+	s := b[0:4]
+	n, err := reader.Read(s)
+	if err != nil {
+		return 0, err
+	} else if n < 4 { // more strict than reader.Read
+		return 0, io.EOF
+	}
+	return binary.LittleEndian.Uint32(s), nil
+}
+
 // readUint64 strictly reads an uint64 in little-endian byte order, using the
 // given array as a buffer. This returns io.EOF if less than 8 bytes were read.
 func readUint64(reader io.Reader, b *[8]byte) (uint64, error) {
@@ -107,6 +107,49 @@ func TestSerializeCompiledModule(t *testing.T) {
 				[]byte{1, 2, 3, 4, 5, 1, 2, 3}, // code.
 			),
 		},
+		{
+			in: &compiledModule{
+				compiledCode: &compiledCode{
+					executable: makeCodeSegment(1, 2, 3, 4, 5, 1, 2, 3),
+				},
+				functions: []compiledFunction{
+					{executableOffset: 0, stackPointerCeil: 12345},
+					{
+						executableOffset: 5, stackPointerCeil: 0xffffffff,
+						exceptionHandlers: []exceptionHandler{{
+							begin: 1, end: 3, stackPointer: 10,
+							catches: []exceptionHandlerCatch{{kind: wasm.TryTableCatchKindCatchRef, tag: 2, padOffset: 4}},
+						}},
+					},
+				},
+			},
+			exp: concat(
+				[]byte(wazeroMagic),
+				[]byte{byte(len(testVersion))},
+				[]byte(testVersion),
+				[]byte{0},      // ensure termination.
+				u32.LeBytes(2), // number of functions.
+				// Function index = 0.
+				u64.LeBytes(12345), // stack pointer ceil.
+				u64.LeBytes(0),     // offset.
+				// Function index = 1.
+				u64.LeBytes(0xffffffff), // stack pointer ceil.
+				u64.LeBytes(5),          // offset.
+				// Executable.
+				u64.LeBytes(8),                 // length of code.
+				[]byte{1, 2, 3, 4, 5, 1, 2, 3}, // code.
+				// Exception handlers.
+				u32.LeBytes(0),  // number of handlers of function index = 0.
+				u32.LeBytes(1),  // number of handlers of function index = 1.
+				u64.LeBytes(1),  // begin.
+				u64.LeBytes(3),  // end.
+				u64.LeBytes(10), // stack pointer.
+				u32.LeBytes(1),  // number of catches.
+				[]byte{wasm.TryTableCatchKindCatchRef},
+				u32.LeBytes(2), // tag.
+				u64.LeBytes(4), // pad offset.
+			),
+		},
 	}
 
 	for i, tc := range tests {
@@ -130,6 +173,17 @@ func TestDeserializeCompiledModule(t *testing.T) {
 			in:     []byte{1},
 			expErr: "compilationcache: invalid header length: 1",
 		},
+		{
+			name: "invalid magic",
+			in: concat(
+				[]byte("WAZEVO"),
+				[]byte{byte(len(testVersion))},
+				[]byte(testVersion),
+				[]byte{0},      // ensure termination.
+				u32.LeBytes(1), // number of functions.
+			),
+			expErr: "compilationcache: invalid magic number: got WAZEVO but want WAZERO",
+		},
 		{
 			name: "version mismatch",
 			in: concat(
@@ -229,6 +283,69 @@ func TestDeserializeCompiledModule(t *testing.T) {
 			expStaleCache: false,
 			expErr:        "",
 		},
+		{
+			name: "exception handlers",
+			in: concat(
+				[]byte(wazeroMagic),
+				[]byte{byte(len(testVersion))},
+				[]byte(testVersion),
+				[]byte{0},      // ensure termination.
+				u32.LeBytes(2), // number of functions.
+				// Function index = 0.
+				u64.LeBytes(12345), // stack pointer ceil.
+				u64.LeBytes(0),     // offset.
+				// Function index = 1.
+				u64.LeBytes(0xffffffff), // stack pointer ceil.
+				u64.LeBytes(7),          // offset.
+				// Executable.
+				u64.LeBytes(10),                       // size.
+				[]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, // machine code.
+				// Exception handlers.
+				u32.LeBytes(0),  // number of handlers of function index = 0.
+				u32.LeBytes(1),  // number of handlers of function index = 1.
+				u64.LeBytes(1),  // begin.
+				u64.LeBytes(3),  // end.
+				u64.LeBytes(10), // stack pointer.
+				u32.LeBytes(1),  // number of catches.
+				[]byte{wasm.TryTableCatchKindCatchRef},
+				u32.LeBytes(2), // tag.
+				u64.LeBytes(4), // pad offset.
+			),
+			expCompiledModule: &compiledModule{
+				compiledCode: &compiledCode{
+					executable: makeCodeSegment(1, 2, 3, 4, 5, 6, 7, 8, 9, 10),
+				},
+				functions: []compiledFunction{
+					{executableOffset: 0, stackPointerCeil: 12345, index: 0},
+					{
+						executableOffset: 7, stackPointerCeil: 0xffffffff, index: 1,
+						exceptionHandlers: []exceptionHandler{{
+							begin: 1, end: 3, stackPointer: 10,
+							catches: []exceptionHandlerCatch{{kind: wasm.TryTableCatchKindCatchRef, tag: 2, padOffset: 4}},
+						}},
+					},
+				},
+			},
+		},
+		{
+			name: "reading exception handlers",
+			in: concat(
+				[]byte(wazeroMagic),
+				[]byte{byte(len(testVersion))},
+				[]byte(testVersion),
+				[]byte{0},          // ensure termination.
+				u32.LeBytes(1),     // number of functions.
+				u64.LeBytes(12345), // stack pointer ceil.
+				u64.LeBytes(0),     // offset.
+				// Executable.
+				u64.LeBytes(5),        // size.
+				[]byte{1, 2, 3, 4, 5}, // machine code.
+				// Exception handlers.
+				u32.LeBytes(1), // number of handlers of function index = 0.
+				u64.LeBytes(1), // begin.
+			),
+			expErr: "compilationcache: error reading exception handlers: EOF",
+		},
 		{
 			name: "reading stack pointer",
 			in: concat(
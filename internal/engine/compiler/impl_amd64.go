@@ -280,6 +280,129 @@ func (c *amd64Compiler) compileBuiltinFunctionCheckExitCode() error {
 	return nil
 }
 
+// compileBuiltinFunctionConsumeFuel implements compiler.compileBuiltinFunctionConsumeFuel for the amd64 architecture.
+func (c *amd64Compiler) compileBuiltinFunctionConsumeFuel() error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	tmpRegister, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.locationStack.markRegisterUsed(tmpRegister)
+	fuelRegister, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// "tmpRegister = ce.moduleContext.moduleInstance"
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextModuleInstanceOffset, tmpRegister)
+	// "tmpRegister.Fuel--"
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmpRegister, moduleInstanceFuelOffset, fuelRegister)
+	c.assembler.CompileConstToRegister(amd64.ADDQ, -1, fuelRegister)
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ, fuelRegister, tmpRegister, moduleInstanceFuelOffset)
+
+	c.locationStack.markRegisterUnused(tmpRegister)
+
+	// Exit with nativeCallStatusFuelExhausted if the fuel went negative.
+	c.compileMaybeExitFromNativeCode(amd64.JPL, nativeCallStatusFuelExhausted)
+	return nil
+}
+
+// compileBuiltinFunctionCheckEpochDeadline implements compiler.compileBuiltinFunctionCheckEpochDeadline for the amd64 architecture.
+func (c *amd64Compiler) compileBuiltinFunctionCheckEpochDeadline() error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	tmpRegister, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.locationStack.markRegisterUsed(tmpRegister)
+	epochRegister, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// "tmpRegister = ce.moduleContext.moduleInstance"
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextModuleInstanceOffset, tmpRegister)
+	// "epochRegister = *tmpRegister.Epoch"
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmpRegister, moduleInstanceEpochOffset, epochRegister)
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, epochRegister, 0, epochRegister)
+	// Compare tmpRegister.EpochDeadline with epochRegister.
+	c.assembler.CompileMemoryToRegister(amd64.CMPQ, tmpRegister, moduleInstanceEpochDeadlineOffset, epochRegister)
+
+	c.locationStack.markRegisterUnused(tmpRegister)
+
+	// Exit with nativeCallStatusEpochDeadlineExceeded unless the deadline is above the epoch.
+	c.compileMaybeExitFromNativeCode(amd64.JHI, nativeCallStatusEpochDeadlineExceeded)
+
+	// Same for the deadline of the current call: compare ce.epochDeadline with epochRegister.
+	c.assembler.CompileMemoryToRegister(amd64.CMPQ,
+		amd64ReservedRegisterForCallEngine, callEngineStackContextEpochDeadlineOffset, epochRegister)
+	c.compileMaybeExitFromNativeCode(amd64.JHI, nativeCallStatusEpochDeadlineExceeded)
+	return nil
+}
+
+// compileThrow implements compiler.compileThrow for the amd64 architecture.
+func (c *amd64Compiler) compileThrow(o *wazeroir.UnionOperation) error {
+	// Push the tag index so that the builtin function can pop it above the params of the tag.
+	if err := c.compileConstI32Impl(uint32(o.U1)); err != nil {
+		return err
+	}
+	if err := c.compileCallBuiltinFunction(builtinFunctionIndexThrow); err != nil {
+		return err
+	}
+	// The execution never returns here, but resumes on a landing pad if the exception is caught.
+	c.compileExitFromNativeCode(nativeCallStatusCodeUnreachable)
+	return nil
+}
+
+// compileThrowRef implements compiler.compileThrowRef for the amd64 architecture.
+func (c *amd64Compiler) compileThrowRef() error {
+	if err := c.compileCallBuiltinFunction(builtinFunctionIndexThrowRef); err != nil {
+		return err
+	}
+	// The execution never returns here, but resumes on a landing pad if the exception is caught.
+	c.compileExitFromNativeCode(nativeCallStatusCodeUnreachable)
+	return nil
+}
+
+// compileTryTable implements compiler.compileTryTable for the amd64 architecture.
+func (c *amd64Compiler) compileTryTable(o *wazeroir.UnionOperation) error {
+	// Exceptions are caught in Go, so the values must be on the stack for the landing pads.
+	if err := c.compileReleaseAllRegistersToStack(); err != nil {
+		return err
+	}
+
+	// The landing pads begin with the values below the parameters of the block, followed by the payload.
+	h := &c.ir.ExceptionHandlers[o.U1]
+	for i := range h.Catches {
+		catch := &h.Catches[i]
+		pad := c.label(catch.Label)
+		pad.initialStack.cloneFrom(*c.locationStack)
+		pad.initialStack.sp -= o.U2
+		pad.initialStack.pushExceptionPayload(catch)
+		pad.stackInitialized = true
+	}
+	return nil
+}
+
+// compileLandingPad implements compiler.compileLandingPad for the amd64 architecture.
+func (c *amd64Compiler) compileLandingPad() error {
+	// The landing pad is entered from Go like a function, so initialize the reserved registers as the preamble.
+	if err := c.compileModuleContextInitialization(); err != nil {
+		return err
+	}
+	c.compileReservedStackBasePointerInitialization()
+	c.compileReservedMemoryPointerInitialization()
+	return nil
+}
+
 // compileGoDefinedHostFunction constructs the entire code to enter the host function implementation,
 // and return to the caller.
 func (c *amd64Compiler) compileGoDefinedHostFunction() error {
@@ -417,7 +540,7 @@ func (c *amd64Compiler) compileGlobalGet(o *wazeroir.UnionOperation) error {
 	case wasm.ValueTypeI32:
 		inst = amd64.MOVL
 		vt = runtimeValueTypeI32
-	case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+	case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref:
 		inst = amd64.MOVQ
 		vt = runtimeValueTypeI64
 	case wasm.ValueTypeF32:
@@ -816,9 +939,28 @@ func (c *amd64Compiler) compileLabel(o *wazeroir.UnionOperation) (skipLabel bool
 
 // compileCall implements compiler.compileCall for the amd64 architecture.
 func (c *amd64Compiler) compileCall(o *wazeroir.UnionOperation) error {
-	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+	targetAddressRegister, targetType, err := c.compileCallTargetAddress(o)
+	if err != nil {
 		return err
 	}
+	return c.compileCallFunctionImpl(targetAddressRegister, targetType)
+}
+
+// compileTailCall implements compiler.compileTailCall for the amd64 architecture.
+func (c *amd64Compiler) compileTailCall(o *wazeroir.UnionOperation) error {
+	targetAddressRegister, targetType, err := c.compileCallTargetAddress(o)
+	if err != nil {
+		return err
+	}
+	return c.compileTailCallFunctionImpl(targetAddressRegister, targetType)
+}
+
+// compileCallTargetAddress loads the address of the *function called by wazeroir.OperationCall or
+// wazeroir.OperationTailCall into the returned register.
+func (c *amd64Compiler) compileCallTargetAddress(o *wazeroir.UnionOperation) (asm.Register, *wasm.FunctionType, error) {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return asm.NilRegister, nil, err
+	}
 
 	functionIndex := o.U1
 
@@ -827,7 +969,7 @@ func (c *amd64Compiler) compileCall(o *wazeroir.UnionOperation) error {
 
 	targetAddressRegister, err := c.allocateRegister(registerTypeGeneralPurpose)
 	if err != nil {
-		return err
+		return asm.NilRegister, nil, err
 	}
 
 	// First, push the index to the callEngine.functionsElement0Address into the target register.
@@ -837,34 +979,54 @@ func (c *amd64Compiler) compileCall(o *wazeroir.UnionOperation) error {
 	// to the target register.
 	c.assembler.CompileMemoryToRegister(amd64.ADDQ, amd64ReservedRegisterForCallEngine,
 		callEngineModuleContextFunctionsElement0AddressOffset, targetAddressRegister)
+	return targetAddressRegister, targetType, nil
+}
 
-	if err := c.compileCallFunctionImpl(targetAddressRegister, targetType); err != nil {
-		return err
+// compileCallIndirect implements compiler.compileCallIndirect for the amd64 architecture.
+func (c *amd64Compiler) compileCallIndirect(o *wazeroir.UnionOperation) error {
+	targetAddressRegister, targetType, err := c.compileCallIndirectTargetAddress(o)
+	if err != nil {
+		return nil
 	}
+	if err = c.compileCallFunctionImpl(targetAddressRegister, targetType); err != nil {
+		return nil
+	}
+
+	// The offset register should be marked as un-used as we consumed in the function call.
+	c.locationStack.markRegisterUnused(targetAddressRegister)
 	return nil
 }
 
-// compileCallIndirect implements compiler.compileCallIndirect for the amd64 architecture.
-func (c *amd64Compiler) compileCallIndirect(o *wazeroir.UnionOperation) error {
+// compileTailCallIndirect implements compiler.compileTailCallIndirect for the amd64 architecture.
+func (c *amd64Compiler) compileTailCallIndirect(o *wazeroir.UnionOperation) error {
+	targetAddressRegister, targetType, err := c.compileCallIndirectTargetAddress(o)
+	if err != nil {
+		return err
+	}
+	return c.compileTailCallFunctionImpl(targetAddressRegister, targetType)
+}
+
+// compileCallIndirectTargetAddress loads the address of the *function called by wazeroir.OperationCallIndirect or
+// wazeroir.OperationTailCallIndirect into the returned register, after checking that the target is valid.
+func (c *amd64Compiler) compileCallIndirectTargetAddress(o *wazeroir.UnionOperation) (asm.Register, *wasm.FunctionType, error) {
 	offset := c.locationStack.pop()
 	if err := c.compileEnsureOnRegister(offset); err != nil {
-		return nil
+		return asm.NilRegister, nil, err
 	}
 	typeIndex := o.U1
 	tableIndex := o.U2
 
 	tmp, err := c.allocateRegister(registerTypeGeneralPurpose)
 	if err != nil {
-		return err
+		return asm.NilRegister, nil, err
 	}
 	c.locationStack.markRegisterUsed(tmp)
 
 	tmp2, err := c.allocateRegister(registerTypeGeneralPurpose)
 	if err != nil {
-		return err
+		return asm.NilRegister, nil, err
 	}
 	c.locationStack.markRegisterUsed(tmp2)
-
 	// Load the address of the target table: tmp = &module.Tables[0]
 	c.assembler.CompileMemoryToRegister(amd64.MOVQ, amd64ReservedRegisterForCallEngine, callEngineModuleContextTablesElement0AddressOffset, tmp)
 	// tmp = &module.Tables[0] + Index*8 = &module.Tables[0] + sizeOf(*TableInstance)*index = module.Tables[o.TableIndex].
@@ -906,14 +1068,9 @@ func (c *amd64Compiler) compileCallIndirect(o *wazeroir.UnionOperation) error {
 	// Skipped if the type matches.
 	c.assembler.CompileMemoryToRegister(amd64.CMPL, offset.register, functionTypeIDOffset, tmp2)
 	c.compileMaybeExitFromNativeCode(amd64.JEQ, nativeCallStatusCodeTypeMismatchOnIndirectCall)
-	targetFunctionType := &c.ir.Types[typeIndex]
-	if err = c.compileCallFunctionImpl(offset.register, targetFunctionType); err != nil {
-		return nil
-	}
-
-	// The offset register should be marked as un-used as we consumed in the function call.
-	c.locationStack.markRegisterUnused(offset.register, tmp, tmp2)
-	return nil
+	// The temporary registers are no longer needed.
+	c.locationStack.markRegisterUnused(tmp, tmp2)
+	return offset.register, &c.ir.Types[typeIndex], nil
 }
 
 // compileDrop implements compiler.compileDrop for the amd64 architecture.
@@ -3258,7 +3415,7 @@ func (c *amd64Compiler) compileLoad(o *wazeroir.UnionOperation) error {
 	)
 
 	unsignedType := wazeroir.UnsignedType(o.B1)
-	offset := uint32(o.U2)
+	offset := o.U2
 
 	switch unsignedType {
 	case wazeroir.UnsignedTypeI32:
@@ -3283,7 +3440,7 @@ func (c *amd64Compiler) compileLoad(o *wazeroir.UnionOperation) error {
 		vt = runtimeValueTypeF64
 	}
 
-	reg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes)
+	reg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes, o.B3)
 	if err != nil {
 		return err
 	}
@@ -3316,8 +3473,8 @@ func (c *amd64Compiler) compileLoad(o *wazeroir.UnionOperation) error {
 // compileLoad8 implements compiler.compileLoad8 for the amd64 architecture.
 func (c *amd64Compiler) compileLoad8(o *wazeroir.UnionOperation) error {
 	const targetSizeInBytes = 1
-	offset := uint32(o.U2)
-	reg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes)
+	offset := o.U2
+	reg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes, o.B3)
 	if err != nil {
 		return err
 	}
@@ -3354,8 +3511,8 @@ func (c *amd64Compiler) compileLoad8(o *wazeroir.UnionOperation) error {
 // compileLoad16 implements compiler.compileLoad16 for the amd64 architecture.
 func (c *amd64Compiler) compileLoad16(o *wazeroir.UnionOperation) error {
 	const targetSizeInBytes = 16 / 8
-	offset := uint32(o.U2)
-	reg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes)
+	offset := o.U2
+	reg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes, o.B3)
 	if err != nil {
 		return err
 	}
@@ -3392,8 +3549,8 @@ func (c *amd64Compiler) compileLoad16(o *wazeroir.UnionOperation) error {
 // compileLoad32 implements compiler.compileLoad32 for the amd64 architecture.
 func (c *amd64Compiler) compileLoad32(o *wazeroir.UnionOperation) error {
 	const targetSizeInBytes = 32 / 8
-	offset := uint32(o.U2)
-	reg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes)
+	offset := o.U2
+	reg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes, o.B3)
 	if err != nil {
 		return err
 	}
@@ -3420,15 +3577,19 @@ func (c *amd64Compiler) compileLoad32(o *wazeroir.UnionOperation) error {
 //
 // Note: this also emits the instructions to check the out-of-bounds memory access.
 // In other words, if the ceil exceeds the memory size, the code exits with nativeCallStatusCodeMemoryOutOfBounds status.
-func (c *amd64Compiler) compileMemoryAccessCeilSetup(offsetArg uint32, targetSizeInBytes int64) (asm.Register, error) {
+//
+// memory64 is true when the base is a 64-bit address (api.CoreFeatureMemory64), in which case offsetArg can exceed
+// 32 bits and the addition is checked for overflow.
+func (c *amd64Compiler) compileMemoryAccessCeilSetup(offsetArg uint64, targetSizeInBytes int64, memory64 bool) (asm.Register, error) {
 	base := c.locationStack.pop()
 	if err := c.compileEnsureOnRegister(base); err != nil {
 		return asm.NilRegister, err
 	}
 
 	result := base.register
-	if offsetConst := int64(offsetArg) + targetSizeInBytes; offsetConst <= math.MaxInt32 {
-		c.assembler.CompileConstToRegister(amd64.ADDQ, offsetConst, result)
+	offsetConst := offsetArg + uint64(targetSizeInBytes)
+	if offsetConst <= math.MaxInt32 {
+		c.assembler.CompileConstToRegister(amd64.ADDQ, int64(offsetConst), result)
 	} else if offsetConst <= math.MaxUint32 {
 		// Note: in practice, this branch rarely happens as in this case, the wasm binary know that
 		// memory has more than 1 GBi or at least tries to access above 1 GBi memory region.
@@ -3442,12 +3603,25 @@ func (c *amd64Compiler) compileMemoryAccessCeilSetup(offsetArg uint32, targetSiz
 		}
 		c.assembler.CompileConstToRegister(amd64.MOVL, int64(uint32(offsetConst)), tmp)
 		c.assembler.CompileRegisterToRegister(amd64.ADDQ, tmp, result)
+	} else if memory64 && offsetConst > offsetArg {
+		// 64-bit memories can be larger than 4GiB, so the offset const is added as is.
+		tmp, err := c.allocateRegister(registerTypeGeneralPurpose)
+		if err != nil {
+			return asm.NilRegister, err
+		}
+		c.assembler.CompileConstToRegister(amd64.MOVQ, int64(offsetConst), tmp)
+		c.assembler.CompileRegisterToRegister(amd64.ADDQ, tmp, result)
 	} else {
 		// If the offset const is too large, we exit with nativeCallStatusCodeMemoryOutOfBounds.
 		c.compileExitFromNativeCode(nativeCallStatusCodeMemoryOutOfBounds)
 		return result, nil
 	}
 
+	if memory64 {
+		// The base is 64-bit, so the addition above can overflow, which means the ceil is out of bounds.
+		c.compileMaybeExitFromNativeCode(amd64.JCC, nativeCallStatusCodeMemoryOutOfBounds)
+	}
+
 	// Now we compare the value with the memory length which is held by callEngine.
 	c.assembler.CompileMemoryToRegister(amd64.CMPQ,
 		amd64ReservedRegisterForCallEngine, callEngineModuleContextMemorySliceLenOffset, result)
@@ -3464,7 +3638,7 @@ func (c *amd64Compiler) compileStore(o *wazeroir.UnionOperation) error {
 	var movInst asm.Instruction
 	var targetSizeInByte int64
 	unsignedType := wazeroir.UnsignedType(o.B1)
-	offset := uint32(o.U2)
+	offset := o.U2
 	switch unsignedType {
 	case wazeroir.UnsignedTypeI32, wazeroir.UnsignedTypeF32:
 		movInst = amd64.MOVL
@@ -3473,31 +3647,31 @@ func (c *amd64Compiler) compileStore(o *wazeroir.UnionOperation) error {
 		movInst = amd64.MOVQ
 		targetSizeInByte = 64 / 8
 	}
-	return c.compileStoreImpl(offset, movInst, targetSizeInByte)
+	return c.compileStoreImpl(offset, o.B3, movInst, targetSizeInByte)
 }
 
 // compileStore8 implements compiler.compileStore8 for the amd64 architecture.
 func (c *amd64Compiler) compileStore8(o *wazeroir.UnionOperation) error {
-	return c.compileStoreImpl(uint32(o.U2), amd64.MOVB, 1)
+	return c.compileStoreImpl(o.U2, o.B3, amd64.MOVB, 1)
 }
 
 // compileStore32 implements compiler.compileStore32 for the amd64 architecture.
 func (c *amd64Compiler) compileStore16(o *wazeroir.UnionOperation) error {
-	return c.compileStoreImpl(uint32(o.U2), amd64.MOVW, 16/8)
+	return c.compileStoreImpl(o.U2, o.B3, amd64.MOVW, 16/8)
 }
 
 // compileStore32 implements compiler.compileStore32 for the amd64 architecture.
 func (c *amd64Compiler) compileStore32(o *wazeroir.UnionOperation) error {
-	return c.compileStoreImpl(uint32(o.U2), amd64.MOVL, 32/8)
+	return c.compileStoreImpl(o.U2, o.B3, amd64.MOVL, 32/8)
 }
 
-func (c *amd64Compiler) compileStoreImpl(offsetConst uint32, inst asm.Instruction, targetSizeInBytes int64) error {
+func (c *amd64Compiler) compileStoreImpl(offsetConst uint64, memory64 bool, inst asm.Instruction, targetSizeInBytes int64) error {
 	val := c.locationStack.pop()
 	if err := c.compileEnsureOnRegister(val); err != nil {
 		return err
 	}
 
-	reg, err := c.compileMemoryAccessCeilSetup(offsetConst, targetSizeInBytes)
+	reg, err := c.compileMemoryAccessCeilSetup(offsetConst, targetSizeInBytes, memory64)
 	if err != nil {
 		return err
 	}
@@ -3530,7 +3704,7 @@ func (c *amd64Compiler) compileMemoryGrow() error {
 }
 
 // compileMemorySize implements compiler.compileMemorySize for the amd64 architecture.
-func (c *amd64Compiler) compileMemorySize() error {
+func (c *amd64Compiler) compileMemorySize(o *wazeroir.UnionOperation) error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
 		return err
 	}
@@ -3539,7 +3713,12 @@ func (c *amd64Compiler) compileMemorySize() error {
 	if err != nil {
 		return err
 	}
-	loc := c.pushRuntimeValueLocationOnRegister(reg, runtimeValueTypeI32)
+	vt := runtimeValueTypeI32
+	if o.B3 {
+		// 64-bit memories return the page count as i64.
+		vt = runtimeValueTypeI64
+	}
+	loc := c.pushRuntimeValueLocationOnRegister(reg, vt)
 
 	c.assembler.CompileMemoryToRegister(amd64.MOVQ, amd64ReservedRegisterForCallEngine, callEngineModuleContextMemorySliceLenOffset, loc.register)
 
@@ -4283,6 +4462,116 @@ func (c *amd64Compiler) compileTableGrow(o *wazeroir.UnionOperation) error {
 	return nil
 }
 
+// compileAtomic implements compiler.compileAtomic for the amd64 architecture.
+func (c *amd64Compiler) compileAtomic(o *wazeroir.UnionOperation) error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	// The static offset of a 64-bit memory can exceed 32 bits, so it is pushed separately from the descriptor.
+	if o.B3 {
+		if err := c.compileConstI64(&wazeroir.UnionOperation{U1: o.U2}); err != nil {
+			return err
+		}
+	}
+
+	// Pushes the descriptor of the operation which is decoded by the builtin function.
+	if err := c.compileConstI64(&wazeroir.UnionOperation{U1: atomicOperationDescriptor(o)}); err != nil {
+		return err
+	}
+
+	// Atomic instructions are executed in Go, which synchronizes with the interpreter semantics.
+	if err := c.compileCallBuiltinFunction(builtinFunctionIndexAtomic); err != nil {
+		return err
+	}
+
+	// The builtin function consumes the descriptor and the operands.
+	paramNum, result := atomicOperationStackEffect(o)
+	if o.B3 {
+		paramNum++ // The static offset.
+	}
+	for i := 0; i < paramNum+1; i++ {
+		c.locationStack.pop()
+	}
+
+	if result != runtimeValueTypeNone {
+		loc := c.locationStack.pushRuntimeValueLocationOnStack()
+		loc.valueType = result
+	}
+
+	// After return, we re-initialize reserved registers just like preamble of functions.
+	c.compileReservedStackBasePointerInitialization()
+	c.compileReservedMemoryPointerInitialization()
+	return nil
+}
+
+// compileSelectMemory implements compiler.compileSelectMemory for the amd64 architecture.
+func (c *amd64Compiler) compileSelectMemory(index uint32) error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	tmpRegister, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.locationStack.markRegisterUsed(tmpRegister)
+	tmpRegister2, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// "tmpRegister = ce.moduleContext.moduleInstance.Memories[index]"
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextModuleInstanceOffset, tmpRegister)
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmpRegister, moduleInstanceMemoriesOffset, tmpRegister)
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmpRegister, int64(index)*8, tmpRegister)
+
+	// Set memory instance.
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ, tmpRegister,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextMemoryInstanceOffset)
+
+	// Set length.
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmpRegister, memoryInstanceBufferLenOffset, tmpRegister2)
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ, tmpRegister2,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextMemorySliceLenOffset)
+
+	// Set element zero address.
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmpRegister, memoryInstanceBufferOffset, tmpRegister2)
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ, tmpRegister2,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextMemoryElement0AddressOffset)
+
+	c.locationStack.markRegisterUnused(tmpRegister)
+	c.compileReservedMemoryPointerInitialization()
+	return nil
+}
+
+// compileMemoryBulkBuiltin implements compiler.compileMemoryBulkBuiltin for the amd64 architecture.
+func (c *amd64Compiler) compileMemoryBulkBuiltin(o *wazeroir.UnionOperation, builtin wasm.Index) error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	// Pushes the memory and data indexes which are decoded by the builtin function.
+	if err := c.compileConstI64(&wazeroir.UnionOperation{U1: memoryBulkDescriptor(o)}); err != nil {
+		return err
+	}
+
+	if err := c.compileCallBuiltinFunction(builtin); err != nil {
+		return err
+	}
+
+	// The builtin function consumes the descriptor and the three operands of the operation.
+	for i := 0; i < 4; i++ {
+		c.locationStack.pop()
+	}
+
+	// After return, we re-initialize reserved registers just like preamble of functions.
+	c.compileReservedStackBasePointerInitialization()
+	c.compileReservedMemoryPointerInitialization()
+	return nil
+}
+
 // compileTableSize implements compiler.compileTableSize for the amd64 architecture.
 func (c *amd64Compiler) compileTableSize(o *wazeroir.UnionOperation) error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
@@ -4655,7 +4944,7 @@ func (c *amd64Compiler) compileCallFunctionImpl(functionAddressRegister asm.Regi
 		switch t {
 		case wasm.ValueTypeI32:
 			loc.valueType = runtimeValueTypeI32
-		case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref:
+		case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref, wasm.ValueTypeExnref:
 			loc.valueType = runtimeValueTypeI64
 		case wasm.ValueTypeF32:
 			loc.valueType = runtimeValueTypeF32
@@ -4672,6 +4961,86 @@ func (c *amd64Compiler) compileCallFunctionImpl(functionAddressRegister asm.Regi
 	return nil
 }
 
+// compileTailCallFunctionImpl implements compiler.compileTailCall and compiler.compileTailCallIndirect for the amd64
+// architecture. Instead of pushing a new callFrame, this replaces the current function frame with the one of the
+// target function, so that the target function returns directly to the caller of the current function.
+func (c *amd64Compiler) compileTailCallFunctionImpl(functionAddressRegister asm.Register, functype *wasm.FunctionType) error {
+	if c.withListener {
+		// The listener must observe the return from the current function, so we don't reuse the frame.
+		return c.compileCallAndReturnFunction(functionAddressRegister, functype)
+	}
+
+	// Release all the registers as our calling convention requires the caller-save.
+	if err := c.compileReleaseAllRegistersToStack(); err != nil {
+		return err
+	}
+
+	c.locationStack.markRegisterUsed(functionAddressRegister)
+
+	// Go-defined host functions read the caller's module instance from the callFrame, which would be the one of the
+	// caller of the current function if we reuse the frame. Therefore, check whether the target's goFunc is nil,
+	// and make the regular call in that case.
+	tmpRegister, found := c.locationStack.takeFreeRegister(registerTypeGeneralPurpose)
+	if !found {
+		return fmt.Errorf("could not find enough free registers")
+	}
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, functionAddressRegister, functionParentOffset, tmpRegister)
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, tmpRegister, compiledFunctionGoFuncOffset, tmpRegister)
+	c.assembler.CompileRegisterToRegister(amd64.TESTQ, tmpRegister, tmpRegister)
+	jmpIfGoFunc := c.assembler.CompileJump(amd64.JNE)
+
+	// Save the stack state for the regular call below.
+	initialLocationStack := c.getSavedTemporaryLocationStack()
+
+	if err := compileDropFrameForTailCall(c, c.typ, functype); err != nil {
+		return err
+	}
+
+	// The target function counts itself on entry in place of the current function.
+	if c.ir.LimitsCallStackDepth {
+		c.compileDecrementCallStackDepth(tmpRegister)
+	}
+
+	// Set callEngine.moduleContext.fn to the next *function. Note that callEngine.stackContext.stackBasePointer
+	// is unchanged as the target function reuses the current frame.
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ, functionAddressRegister,
+		amd64ReservedRegisterForCallEngine, callEngineModuleContextFnOffset)
+
+	targetAddressRegister := functionAddressRegister
+	if amd64CallingConventionDestinationFunctionModuleInstanceAddressRegister == targetAddressRegister {
+		// This case we must move the value on targetFunctionAddressRegister to another register, otherwise
+		// the address (jump target below) will be modified and result in segfault.
+		// See #526.
+		c.assembler.CompileRegisterToRegister(amd64.MOVQ, targetAddressRegister, tmpRegister)
+		targetAddressRegister = tmpRegister
+	}
+
+	// Also, we have to put the target function's *wasm.ModuleInstance into amd64CallingConventionDestinationFunctionModuleInstanceAddressRegister.
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ, targetAddressRegister, functionModuleInstanceOffset,
+		amd64CallingConventionDestinationFunctionModuleInstanceAddressRegister)
+
+	// And jump into the initial address of the target function, which never comes back here.
+	c.assembler.CompileJumpToMemory(amd64.JMP, targetAddressRegister, functionCodeInitialAddressOffset)
+
+	// Go-defined host function case.
+	c.assembler.SetJumpTargetOnNext(jmpIfGoFunc)
+	c.locationStack.cloneFrom(initialLocationStack)
+	return c.compileCallAndReturnFunction(functionAddressRegister, functype)
+}
+
+// compileCallAndReturnFunction makes the regular call to the function whose address is in functionAddressRegister,
+// and then returns its results from the current function.
+func (c *amd64Compiler) compileCallAndReturnFunction(functionAddressRegister asm.Register, functype *wasm.FunctionType) error {
+	if err := c.compileCallFunctionImpl(functionAddressRegister, functype); err != nil {
+		return err
+	}
+	r := wazeroir.InclusiveRange{Start: int32(functype.ResultNumInUint64), End: int32(c.locationStack.sp) - 1}
+	if err := compileDropRange(c, r.AsU64()); err != nil {
+		return err
+	}
+	return c.compileReturnFunction()
+}
+
 // returnFunction adds instructions to return from the current callframe back to the caller's frame.
 // If this is the current one is the origin, we return to the callEngine.execWasmFunction with the Returned status.
 // Otherwise, we jump into the callers' return address stored in callFrame.returnAddress while setting
@@ -4704,6 +5073,11 @@ func (c *amd64Compiler) compileReturnFunction() error {
 		panic("BUG: all the registers should be free at this point: " + c.locationStack.String())
 	}
 
+	// Host functions are compiled without ir, and don't count in the call stack depth.
+	if c.ir != nil && c.ir.LimitsCallStackDepth {
+		c.compileDecrementCallStackDepth(returnAddressRegister)
+	}
+
 	returnAddress, callerStackBasePointerInBytes, callerFunction := c.locationStack.getCallFrameLocations(c.typ)
 
 	// A zero return address means return from the execution.
@@ -4911,6 +5285,10 @@ func (c *amd64Compiler) compilePreamble() (err error) {
 		return err
 	}
 
+	if c.ir.LimitsCallStackDepth {
+		c.compileIncrementCallStackDepth()
+	}
+
 	if c.withListener {
 		if err = c.compileCallBuiltinFunction(builtinFunctionIndexFunctionListenerBefore); err != nil {
 			return err
@@ -4924,6 +5302,36 @@ func (c *amd64Compiler) compilePreamble() (err error) {
 	return
 }
 
+// compileIncrementCallStackDepth adds instructions to increment callEngine.callStackDepth, and to exit with
+// nativeCallStatusCallStackOverflow if it exceeds callEngine.callStackDepthCeiling.
+func (c *amd64Compiler) compileIncrementCallStackDepth() {
+	tmpRegister, ok := c.locationStack.takeFreeRegister(registerTypeGeneralPurpose)
+	if !ok {
+		panic("BUG: cannot take free register")
+	}
+
+	// "ce.callStackDepth++"
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ,
+		amd64ReservedRegisterForCallEngine, callEngineStackContextCallStackDepthOffset, tmpRegister)
+	c.assembler.CompileConstToRegister(amd64.ADDQ, 1, tmpRegister)
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ,
+		tmpRegister, amd64ReservedRegisterForCallEngine, callEngineStackContextCallStackDepthOffset)
+
+	// Exit with nativeCallStatusCallStackOverflow unless ce.callStackDepthCeiling >= ce.callStackDepth.
+	c.assembler.CompileMemoryToRegister(amd64.CMPQ,
+		amd64ReservedRegisterForCallEngine, callEngineStackContextCallStackDepthCeilingOffset, tmpRegister)
+	c.compileMaybeExitFromNativeCode(amd64.JCC, nativeCallStatusCallStackOverflow)
+}
+
+// compileDecrementCallStackDepth adds instructions to decrement callEngine.callStackDepth with tmpRegister.
+func (c *amd64Compiler) compileDecrementCallStackDepth(tmpRegister asm.Register) {
+	c.assembler.CompileMemoryToRegister(amd64.MOVQ,
+		amd64ReservedRegisterForCallEngine, callEngineStackContextCallStackDepthOffset, tmpRegister)
+	c.assembler.CompileConstToRegister(amd64.ADDQ, -1, tmpRegister)
+	c.assembler.CompileRegisterToMemory(amd64.MOVQ,
+		tmpRegister, amd64ReservedRegisterForCallEngine, callEngineStackContextCallStackDepthOffset)
+}
+
 func (c *amd64Compiler) compileReservedStackBasePointerInitialization() {
 	// First, make reservedRegisterForStackBasePointer point to the beginning of the slice backing array.
 	c.assembler.CompileMemoryToRegister(amd64.MOVQ,
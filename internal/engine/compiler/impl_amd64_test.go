@@ -484,6 +484,11 @@ func (f *mockCpuFlags) HasExtra(flag uint64) bool {
 	return (f.extraFlags & flag) != 0
 }
 
+// Raw implements the method of the same name in platform.CpuFeatureFlags
+func (f *mockCpuFlags) Raw() uint64 {
+	return 0
+}
+
 // Relates to #1111 (Clz): older AMD64 CPUs do not support the LZCNT instruction
 // CPUID should be used instead. We simulate presence/absence of the feature
 // by overriding the field in the corresponding struct.
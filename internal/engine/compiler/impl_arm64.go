@@ -124,11 +124,11 @@ var arm64CallingConventionModuleInstanceAddressRegister = arm64.RegR29
 
 const (
 	// arm64CallEngineArchContextCompilerCallReturnAddressOffset is the offset of archContext.nativeCallReturnAddress in callEngine.
-	arm64CallEngineArchContextCompilerCallReturnAddressOffset = 144
+	arm64CallEngineArchContextCompilerCallReturnAddressOffset = 160
 	// arm64CallEngineArchContextMinimum32BitSignedIntOffset is the offset of archContext.minimum32BitSignedIntAddress in callEngine.
-	arm64CallEngineArchContextMinimum32BitSignedIntOffset = 152
+	arm64CallEngineArchContextMinimum32BitSignedIntOffset = 168
 	// arm64CallEngineArchContextMinimum64BitSignedIntOffset is the offset of archContext.minimum64BitSignedIntAddress in callEngine.
-	arm64CallEngineArchContextMinimum64BitSignedIntOffset = 160
+	arm64CallEngineArchContextMinimum64BitSignedIntOffset = 176
 )
 
 func isZeroRegister(r asm.Register) bool {
@@ -246,6 +246,10 @@ func (c *arm64Compiler) compilePreamble() error {
 		return err
 	}
 
+	if c.ir.LimitsCallStackDepth {
+		c.compileIncrementCallStackDepth()
+	}
+
 	if c.withListener {
 		if err := c.compileCallGoFunction(nativeCallStatusCodeCallBuiltInFunction, builtinFunctionIndexFunctionListenerBefore); err != nil {
 			return err
@@ -260,6 +264,41 @@ func (c *arm64Compiler) compilePreamble() error {
 	return nil
 }
 
+// compileIncrementCallStackDepth adds instructions to increment callEngine.callStackDepth, and to exit with
+// nativeCallStatusCallStackOverflow if it exceeds callEngine.callStackDepthCeiling.
+func (c *arm64Compiler) compileIncrementCallStackDepth() {
+	tmpX, found := c.locationStack.takeFreeRegister(registerTypeGeneralPurpose)
+	if !found {
+		panic("BUG: all the registers should be free at this point")
+	}
+
+	// "ce.callStackDepth++"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForCallEngine, callEngineStackContextCallStackDepthOffset, tmpX)
+	c.assembler.CompileConstToRegister(arm64.ADD, 1, tmpX)
+	c.assembler.CompileRegisterToMemory(arm64.STRD,
+		tmpX, arm64ReservedRegisterForCallEngine, callEngineStackContextCallStackDepthOffset)
+
+	// "arm64ReservedRegisterForTemporary = ce.callStackDepthCeiling"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForCallEngine, callEngineStackContextCallStackDepthCeilingOffset,
+		arm64ReservedRegisterForTemporary)
+	c.assembler.CompileTwoRegistersToNone(arm64.CMP, arm64ReservedRegisterForTemporary, tmpX)
+
+	// Exit with nativeCallStatusCallStackOverflow unless ce.callStackDepth <= ce.callStackDepthCeiling.
+	c.compileMaybeExitFromNativeCode(arm64.BCONDLS, nativeCallStatusCallStackOverflow)
+}
+
+// compileDecrementCallStackDepth adds instructions to decrement callEngine.callStackDepth.
+func (c *arm64Compiler) compileDecrementCallStackDepth() {
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForCallEngine, callEngineStackContextCallStackDepthOffset,
+		arm64ReservedRegisterForTemporary)
+	c.assembler.CompileConstToRegister(arm64.SUB, 1, arm64ReservedRegisterForTemporary)
+	c.assembler.CompileRegisterToMemory(arm64.STRD,
+		arm64ReservedRegisterForTemporary, arm64ReservedRegisterForCallEngine, callEngineStackContextCallStackDepthOffset)
+}
+
 // compileMaybeGrowStack adds instructions to check the necessity to grow the value stack,
 // and if so, make the builtin function call to do so. These instructions are called in the function's
 // preamble.
@@ -344,6 +383,11 @@ func (c *arm64Compiler) compileReturnFunction() error {
 	c.locationStack.markRegisterUsed(arm64CallingConventionModuleInstanceAddressRegister)
 	defer c.locationStack.markRegisterUnused(arm64CallingConventionModuleInstanceAddressRegister)
 
+	// Host functions are compiled without ir, and don't count in the call stack depth.
+	if c.ir != nil && c.ir.LimitsCallStackDepth {
+		c.compileDecrementCallStackDepth()
+	}
+
 	returnAddress, callerStackBasePointerInBytes, callerFunction := c.locationStack.getCallFrameLocations(c.typ)
 
 	// If the return address is zero, meaning that we return from the execution.
@@ -516,6 +560,132 @@ func (c *arm64Compiler) compileBuiltinFunctionCheckExitCode() error {
 	return nil
 }
 
+// compileBuiltinFunctionConsumeFuel implements compiler.compileBuiltinFunctionConsumeFuel for the arm64 architecture.
+func (c *arm64Compiler) compileBuiltinFunctionConsumeFuel() error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	tmpX, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.markRegisterUsed(tmpX)
+	fuel, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// "tmpX = ce.moduleContext.moduleInstance"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextModuleInstanceOffset, tmpX)
+	// "tmpX.Fuel--"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, tmpX, moduleInstanceFuelOffset, fuel)
+	c.assembler.CompileConstToRegister(arm64.SUBS, 1, fuel)
+	c.assembler.CompileRegisterToMemory(arm64.STRD, fuel, tmpX, moduleInstanceFuelOffset)
+
+	c.markRegisterUnused(tmpX)
+
+	// Exit with nativeCallStatusFuelExhausted if the fuel went negative.
+	c.compileMaybeExitFromNativeCode(arm64.BCONDPL, nativeCallStatusFuelExhausted)
+	return nil
+}
+
+// compileBuiltinFunctionCheckEpochDeadline implements compiler.compileBuiltinFunctionCheckEpochDeadline for the arm64 architecture.
+func (c *arm64Compiler) compileBuiltinFunctionCheckEpochDeadline() error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	tmpX, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.markRegisterUsed(tmpX)
+	epoch, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// "tmpX = ce.moduleContext.moduleInstance"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextModuleInstanceOffset, tmpX)
+	// "epoch = *tmpX.Epoch"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, tmpX, moduleInstanceEpochOffset, epoch)
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, epoch, 0, epoch)
+	// "tmpX = tmpX.EpochDeadline"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, tmpX, moduleInstanceEpochDeadlineOffset, tmpX)
+	c.assembler.CompileTwoRegistersToNone(arm64.CMP, tmpX, epoch)
+
+	// Exit with nativeCallStatusEpochDeadlineExceeded unless the epoch is below the deadline.
+	c.compileMaybeExitFromNativeCode(arm64.BCONDLO, nativeCallStatusEpochDeadlineExceeded)
+
+	// Same for the deadline of the current call: "tmpX = ce.epochDeadline"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForCallEngine, callEngineStackContextEpochDeadlineOffset, tmpX)
+	c.assembler.CompileTwoRegistersToNone(arm64.CMP, tmpX, epoch)
+
+	c.markRegisterUnused(tmpX)
+
+	c.compileMaybeExitFromNativeCode(arm64.BCONDLO, nativeCallStatusEpochDeadlineExceeded)
+	return nil
+}
+
+// compileThrow implements compiler.compileThrow for the arm64 architecture.
+func (c *arm64Compiler) compileThrow(o *wazeroir.UnionOperation) error {
+	// Push the tag index so that the builtin function can pop it above the params of the tag.
+	if err := c.compileIntConstant(true, o.U1); err != nil {
+		return err
+	}
+	if err := c.compileCallGoFunction(nativeCallStatusCodeCallBuiltInFunction, builtinFunctionIndexThrow); err != nil {
+		return err
+	}
+	// The execution never returns here, but resumes on a landing pad if the exception is caught.
+	c.compileExitFromNativeCode(nativeCallStatusCodeUnreachable)
+	return nil
+}
+
+// compileThrowRef implements compiler.compileThrowRef for the arm64 architecture.
+func (c *arm64Compiler) compileThrowRef() error {
+	if err := c.compileCallGoFunction(nativeCallStatusCodeCallBuiltInFunction, builtinFunctionIndexThrowRef); err != nil {
+		return err
+	}
+	// The execution never returns here, but resumes on a landing pad if the exception is caught.
+	c.compileExitFromNativeCode(nativeCallStatusCodeUnreachable)
+	return nil
+}
+
+// compileTryTable implements compiler.compileTryTable for the arm64 architecture.
+func (c *arm64Compiler) compileTryTable(o *wazeroir.UnionOperation) error {
+	// Exceptions are caught in Go, so the values must be on the stack for the landing pads.
+	if err := c.compileReleaseAllRegistersToStack(); err != nil {
+		return err
+	}
+
+	// The landing pads begin with the values below the parameters of the block, followed by the payload.
+	h := &c.ir.ExceptionHandlers[o.U1]
+	for i := range h.Catches {
+		catch := &h.Catches[i]
+		pad := c.label(catch.Label)
+		pad.initialStack.cloneFrom(*c.locationStack)
+		pad.initialStack.sp -= o.U2
+		pad.initialStack.pushExceptionPayload(catch)
+		pad.stackInitialized = true
+	}
+	return nil
+}
+
+// compileLandingPad implements compiler.compileLandingPad for the arm64 architecture.
+func (c *arm64Compiler) compileLandingPad() error {
+	// The landing pad is entered from Go like a function, so initialize the reserved registers as the preamble.
+	if err := c.compileModuleContextInitialization(); err != nil {
+		return err
+	}
+	c.compileReservedStackBasePointerRegisterInitialization()
+	c.compileReservedMemoryRegisterInitialization()
+	return nil
+}
+
 // compileLabel implements compiler.compileLabel for the arm64 architecture.
 func (c *arm64Compiler) compileLabel(o *wazeroir.UnionOperation) (skipThisLabel bool) {
 	labelKey := wazeroir.Label(o.U1)
@@ -612,7 +782,7 @@ func (c *arm64Compiler) compileGlobalGet(o *wazeroir.UnionOperation) error {
 			ldr = arm64.LDRW
 			vt = runtimeValueTypeI32
 			result = globalAddressReg
-		case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref:
 			ldr = arm64.LDRD
 			vt = runtimeValueTypeI64
 			result = globalAddressReg
@@ -675,7 +845,7 @@ func (c *arm64Compiler) compileGlobalSet(o *wazeroir.UnionOperation) error {
 		switch c.ir.Globals[index].ValType {
 		case wasm.ValueTypeI32:
 			str = arm64.STRW
-		case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref:
 			str = arm64.STRD
 		case wasm.ValueTypeF32:
 			str = arm64.FSTRS
@@ -986,9 +1156,30 @@ func (c *arm64Compiler) getSavedTemporaryLocationStack() runtimeValueLocationSta
 
 // compileCall implements compiler.compileCall for the arm64 architecture.
 func (c *arm64Compiler) compileCall(o *wazeroir.UnionOperation) error {
-	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+	targetFunctionAddressReg, tp, err := c.compileCallTargetAddress(o)
+	if err != nil {
 		return err
 	}
+	defer c.markRegisterUnused(targetFunctionAddressReg)
+	return c.compileCallImpl(targetFunctionAddressReg, tp)
+}
+
+// compileTailCall implements compiler.compileTailCall for the arm64 architecture.
+func (c *arm64Compiler) compileTailCall(o *wazeroir.UnionOperation) error {
+	targetFunctionAddressReg, tp, err := c.compileCallTargetAddress(o)
+	if err != nil {
+		return err
+	}
+	defer c.markRegisterUnused(targetFunctionAddressReg)
+	return c.compileTailCallImpl(targetFunctionAddressReg, tp)
+}
+
+// compileCallTargetAddress loads the address of the *function called by wazeroir.OperationCall or
+// wazeroir.OperationTailCall into the returned register, which is marked used.
+func (c *arm64Compiler) compileCallTargetAddress(o *wazeroir.UnionOperation) (asm.Register, *wasm.FunctionType, error) {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return asm.NilRegister, nil, err
+	}
 
 	functionIndex := o.U1
 
@@ -996,10 +1187,9 @@ func (c *arm64Compiler) compileCall(o *wazeroir.UnionOperation) error {
 
 	targetFunctionAddressReg, err := c.allocateRegister(registerTypeGeneralPurpose)
 	if err != nil {
-		return err
+		return asm.NilRegister, nil, err
 	}
 	c.markRegisterUsed(targetFunctionAddressReg)
-	defer c.markRegisterUnused(targetFunctionAddressReg)
 
 	// 3) Set rc.next to specify which function is executed on the current call frame.
 	//
@@ -1013,8 +1203,7 @@ func (c *arm64Compiler) compileCall(o *wazeroir.UnionOperation) error {
 		arm64.ADD,
 		int64(functionIndex)*functionSize, // * 8 because the size of *function equals 8 bytes.
 		targetFunctionAddressReg)
-
-	return c.compileCallImpl(targetFunctionAddressReg, tp)
+	return targetFunctionAddressReg, tp, nil
 }
 
 // compileCallImpl implements compiler.compileCall and compiler.compileCallIndirect for the arm64 architecture.
@@ -1105,7 +1294,7 @@ func (c *arm64Compiler) compileCallImpl(targetFunctionAddressRegister asm.Regist
 		switch t {
 		case wasm.ValueTypeI32:
 			loc.valueType = runtimeValueTypeI32
-		case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref:
+		case wasm.ValueTypeI64, wasm.ValueTypeFuncref, wasm.ValueTypeExternref, wasm.ValueTypeExnref:
 			loc.valueType = runtimeValueTypeI64
 		case wasm.ValueTypeF32:
 			loc.valueType = runtimeValueTypeF32
@@ -1129,20 +1318,131 @@ func (c *arm64Compiler) compileCallImpl(targetFunctionAddressRegister asm.Regist
 	return nil
 }
 
+// compileTailCallImpl implements compiler.compileTailCall and compiler.compileTailCallIndirect for the arm64
+// architecture. Instead of pushing a new callFrame, this replaces the current function frame with the one of the
+// target function, so that the target function returns directly to the caller of the current function.
+func (c *arm64Compiler) compileTailCallImpl(targetFunctionAddressRegister asm.Register, functype *wasm.FunctionType) error {
+	if c.withListener {
+		// The listener must observe the return from the current function, so we don't reuse the frame.
+		return c.compileCallAndReturnFunction(targetFunctionAddressRegister, functype)
+	}
+
+	// Release all the registers as our calling convention requires the caller-save.
+	if err := c.compileReleaseAllRegistersToStack(); err != nil {
+		return err
+	}
+
+	// Go-defined host functions read the caller's module instance from the callFrame, which would be the one of the
+	// caller of the current function if we reuse the frame. Therefore, check whether the target's goFunc is nil,
+	// and make the regular call in that case.
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		targetFunctionAddressRegister, functionParentOffset,
+		arm64ReservedRegisterForTemporary)
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForTemporary, compiledFunctionGoFuncOffset,
+		arm64ReservedRegisterForTemporary)
+	c.assembler.CompileTwoRegistersToNone(arm64.CMP, arm64.RegRZR, arm64ReservedRegisterForTemporary)
+	brIfGoFunc := c.assembler.CompileJump(arm64.BCONDNE)
+
+	// Save the stack state for the regular call below.
+	initialLocationStack := c.getSavedTemporaryLocationStack()
+
+	if err := compileDropFrameForTailCall(c, c.typ, functype); err != nil {
+		return err
+	}
+
+	// The target function counts itself on entry in place of the current function.
+	if c.ir.LimitsCallStackDepth {
+		c.compileDecrementCallStackDepth()
+	}
+
+	// Set callEngine.moduleContext.fn to the next *function. Note that callEngine.stackContext.stackBasePointer
+	// is unchanged as the target function reuses the current frame.
+	c.assembler.CompileRegisterToMemory(arm64.STRD,
+		targetFunctionAddressRegister,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextFnOffset)
+
+	targetAddressRegister := targetFunctionAddressRegister
+	if targetAddressRegister == arm64CallingConventionModuleInstanceAddressRegister {
+		// This case we must move the value on targetFunctionAddressRegister to another register, otherwise
+		// the address (jump target below) will be modified and result in segfault.
+		// See #526.
+		c.assembler.CompileRegisterToRegister(arm64.MOVD, targetAddressRegister, arm64ReservedRegisterForTemporary)
+		targetAddressRegister = arm64ReservedRegisterForTemporary
+	}
+
+	// Also, we have to put the code's moduleInstance address into arm64CallingConventionModuleInstanceAddressRegister.
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		targetAddressRegister, functionModuleInstanceOffset,
+		arm64CallingConventionModuleInstanceAddressRegister,
+	)
+
+	// Then, br into the target function's initial address, which never comes back here.
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		targetAddressRegister, functionCodeInitialAddressOffset,
+		targetAddressRegister)
+
+	c.assembler.CompileJumpToRegister(arm64.B, targetAddressRegister)
+
+	// Go-defined host function case.
+	c.assembler.SetJumpTargetOnNext(brIfGoFunc)
+	c.locationStack.cloneFrom(initialLocationStack)
+	return c.compileCallAndReturnFunction(targetFunctionAddressRegister, functype)
+}
+
+// compileCallAndReturnFunction makes the regular call to the function whose address is in
+// targetFunctionAddressRegister, and then returns its results from the current function.
+func (c *arm64Compiler) compileCallAndReturnFunction(targetFunctionAddressRegister asm.Register, functype *wasm.FunctionType) error {
+	if err := c.compileCallImpl(targetFunctionAddressRegister, functype); err != nil {
+		return err
+	}
+	r := wazeroir.InclusiveRange{Start: int32(functype.ResultNumInUint64), End: int32(c.locationStack.sp) - 1}
+	if err := compileDropRange(c, r.AsU64()); err != nil {
+		return err
+	}
+	return c.compileReturnFunction()
+}
+
 // compileCallIndirect implements compiler.compileCallIndirect for the arm64 architecture.
-func (c *arm64Compiler) compileCallIndirect(o *wazeroir.UnionOperation) (err error) {
+func (c *arm64Compiler) compileCallIndirect(o *wazeroir.UnionOperation) error {
+	offsetReg, targetFunctionType, err := c.compileCallIndirectTargetAddress(o)
+	if err != nil {
+		return err
+	}
+	if err = c.compileCallImpl(offsetReg, targetFunctionType); err != nil {
+		return err
+	}
+
+	// The offset register should be marked as un-used as we consumed in the function call.
+	c.markRegisterUnused(offsetReg)
+	return nil
+}
+
+// compileTailCallIndirect implements compiler.compileTailCallIndirect for the arm64 architecture.
+func (c *arm64Compiler) compileTailCallIndirect(o *wazeroir.UnionOperation) error {
+	offsetReg, targetFunctionType, err := c.compileCallIndirectTargetAddress(o)
+	if err != nil {
+		return err
+	}
+	defer c.markRegisterUnused(offsetReg)
+	return c.compileTailCallImpl(offsetReg, targetFunctionType)
+}
+
+// compileCallIndirectTargetAddress loads the address of the *function called by wazeroir.OperationCallIndirect or
+// wazeroir.OperationTailCallIndirect into the returned register, after checking that the target is valid.
+func (c *arm64Compiler) compileCallIndirectTargetAddress(o *wazeroir.UnionOperation) (offsetReg asm.Register, targetFunctionType *wasm.FunctionType, err error) {
 	offset := c.locationStack.pop()
 	if err = c.compileEnsureOnRegister(offset); err != nil {
-		return err
+		return
 	}
 	typeIndex := o.U1
 	tableIndex := o.U2
 
-	offsetReg := offset.register
+	offsetReg = offset.register
 	if isZeroRegister(offsetReg) {
 		offsetReg, err = c.allocateRegister(registerTypeGeneralPurpose)
 		if err != nil {
-			return err
+			return
 		}
 		c.markRegisterUsed(offsetReg)
 
@@ -1152,13 +1452,13 @@ func (c *arm64Compiler) compileCallIndirect(o *wazeroir.UnionOperation) (err err
 
 	tmp, err := c.allocateRegister(registerTypeGeneralPurpose)
 	if err != nil {
-		return err
+		return
 	}
 	c.markRegisterUsed(tmp)
 
 	tmp2, err := c.allocateRegister(registerTypeGeneralPurpose)
 	if err != nil {
-		return err
+		return
 	}
 	c.markRegisterUsed(tmp2)
 
@@ -1227,14 +1527,10 @@ func (c *arm64Compiler) compileCallIndirect(o *wazeroir.UnionOperation) (err err
 	// Skipped if the type matches.
 	c.compileMaybeExitFromNativeCode(arm64.BCONDEQ, nativeCallStatusCodeTypeMismatchOnIndirectCall)
 
-	targetFunctionType := &c.ir.Types[typeIndex]
-	if err := c.compileCallImpl(offsetReg, targetFunctionType); err != nil {
-		return err
-	}
-
-	// The offset register should be marked as un-used as we consumed in the function call.
-	c.markRegisterUnused(offsetReg, tmp, tmp2)
-	return nil
+	// The temporary registers are no longer needed.
+	c.markRegisterUnused(tmp, tmp2)
+	targetFunctionType = &c.ir.Types[typeIndex]
+	return
 }
 
 // compileDrop implements compiler.compileDrop for the arm64 architecture.
@@ -2640,7 +2936,7 @@ func (c *arm64Compiler) compileLoad(o *wazeroir.UnionOperation) error {
 	)
 
 	unsignedType := wazeroir.UnsignedType(o.B1)
-	offset := uint32(o.U2)
+	offset := o.U2
 
 	switch unsignedType {
 	case wazeroir.UnsignedTypeI32:
@@ -2662,7 +2958,7 @@ func (c *arm64Compiler) compileLoad(o *wazeroir.UnionOperation) error {
 		targetSizeInBytes = 64 / 8
 		vt = runtimeValueTypeF64
 	}
-	return c.compileLoadImpl(offset, loadInst, targetSizeInBytes, isFloat, vt)
+	return c.compileLoadImpl(offset, o.B3, loadInst, targetSizeInBytes, isFloat, vt)
 }
 
 // compileLoad8 implements compiler.compileLoad8 for the arm64 architecture.
@@ -2671,7 +2967,7 @@ func (c *arm64Compiler) compileLoad8(o *wazeroir.UnionOperation) error {
 	var vt runtimeValueType
 
 	signedInt := wazeroir.SignedInt(o.B1)
-	offset := uint32(o.U2)
+	offset := o.U2
 
 	switch signedInt {
 	case wazeroir.SignedInt32:
@@ -2687,7 +2983,7 @@ func (c *arm64Compiler) compileLoad8(o *wazeroir.UnionOperation) error {
 		loadInst = arm64.LDRB
 		vt = runtimeValueTypeI64
 	}
-	return c.compileLoadImpl(offset, loadInst, 1, false, vt)
+	return c.compileLoadImpl(offset, o.B3, loadInst, 1, false, vt)
 }
 
 // compileLoad16 implements compiler.compileLoad16 for the arm64 architecture.
@@ -2696,7 +2992,7 @@ func (c *arm64Compiler) compileLoad16(o *wazeroir.UnionOperation) error {
 	var vt runtimeValueType
 
 	signedInt := wazeroir.SignedInt(o.B1)
-	offset := uint32(o.U2)
+	offset := o.U2
 
 	switch signedInt {
 	case wazeroir.SignedInt32:
@@ -2712,28 +3008,28 @@ func (c *arm64Compiler) compileLoad16(o *wazeroir.UnionOperation) error {
 		loadInst = arm64.LDRH
 		vt = runtimeValueTypeI64
 	}
-	return c.compileLoadImpl(offset, loadInst, 16/8, false, vt)
+	return c.compileLoadImpl(offset, o.B3, loadInst, 16/8, false, vt)
 }
 
 // compileLoad32 implements compiler.compileLoad32 for the arm64 architecture.
 func (c *arm64Compiler) compileLoad32(o *wazeroir.UnionOperation) error {
 	var loadInst asm.Instruction
 	signed := o.B1 == 1
-	offset := uint32(o.U2)
+	offset := o.U2
 
 	if signed {
 		loadInst = arm64.LDRSW
 	} else {
 		loadInst = arm64.LDRW
 	}
-	return c.compileLoadImpl(offset, loadInst, 32/8, false, runtimeValueTypeI64)
+	return c.compileLoadImpl(offset, o.B3, loadInst, 32/8, false, runtimeValueTypeI64)
 }
 
 // compileLoadImpl implements compileLoadImpl* variants for arm64 architecture.
-func (c *arm64Compiler) compileLoadImpl(offsetArg uint32, loadInst asm.Instruction,
+func (c *arm64Compiler) compileLoadImpl(offsetArg uint64, memory64 bool, loadInst asm.Instruction,
 	targetSizeInBytes int64, isFloat bool, resultRuntimeValueType runtimeValueType,
 ) error {
-	offsetReg, err := c.compileMemoryAccessOffsetSetup(offsetArg, targetSizeInBytes)
+	offsetReg, err := c.compileMemoryAccessOffsetSetup(offsetArg, targetSizeInBytes, memory64)
 	if err != nil {
 		return err
 	}
@@ -2763,7 +3059,7 @@ func (c *arm64Compiler) compileStore(o *wazeroir.UnionOperation) error {
 	var movInst asm.Instruction
 	var targetSizeInBytes int64
 	unsignedType := wazeroir.UnsignedType(o.B1)
-	offset := uint32(o.U2)
+	offset := o.U2
 	switch unsignedType {
 	case wazeroir.UnsignedTypeI32:
 		movInst = arm64.STRW
@@ -2778,26 +3074,26 @@ func (c *arm64Compiler) compileStore(o *wazeroir.UnionOperation) error {
 		movInst = arm64.FSTRD
 		targetSizeInBytes = 64 / 8
 	}
-	return c.compileStoreImpl(offset, movInst, targetSizeInBytes)
+	return c.compileStoreImpl(offset, o.B3, movInst, targetSizeInBytes)
 }
 
 // compileStore8 implements compiler.compileStore8 for the arm64 architecture.
 func (c *arm64Compiler) compileStore8(o *wazeroir.UnionOperation) error {
-	return c.compileStoreImpl(uint32(o.U2), arm64.STRB, 1)
+	return c.compileStoreImpl(o.U2, o.B3, arm64.STRB, 1)
 }
 
 // compileStore16 implements compiler.compileStore16 for the arm64 architecture.
 func (c *arm64Compiler) compileStore16(o *wazeroir.UnionOperation) error {
-	return c.compileStoreImpl(uint32(o.U2), arm64.STRH, 16/8)
+	return c.compileStoreImpl(o.U2, o.B3, arm64.STRH, 16/8)
 }
 
 // compileStore32 implements compiler.compileStore32 for the arm64 architecture.
 func (c *arm64Compiler) compileStore32(o *wazeroir.UnionOperation) error {
-	return c.compileStoreImpl(uint32(o.U2), arm64.STRW, 32/8)
+	return c.compileStoreImpl(o.U2, o.B3, arm64.STRW, 32/8)
 }
 
 // compileStoreImpl implements compleStore* variants for arm64 architecture.
-func (c *arm64Compiler) compileStoreImpl(offsetArg uint32, storeInst asm.Instruction, targetSizeInBytes int64) error {
+func (c *arm64Compiler) compileStoreImpl(offsetArg uint64, memory64 bool, storeInst asm.Instruction, targetSizeInBytes int64) error {
 	val, err := c.popValueOnRegister()
 	if err != nil {
 		return err
@@ -2805,7 +3101,7 @@ func (c *arm64Compiler) compileStoreImpl(offsetArg uint32, storeInst asm.Instruc
 	// Mark temporarily used as compileMemoryAccessOffsetSetup might try allocating register.
 	c.markRegisterUsed(val.register)
 
-	offsetReg, err := c.compileMemoryAccessOffsetSetup(offsetArg, targetSizeInBytes)
+	offsetReg, err := c.compileMemoryAccessOffsetSetup(offsetArg, targetSizeInBytes, memory64)
 	if err != nil {
 		return err
 	}
@@ -2827,7 +3123,9 @@ func (c *arm64Compiler) compileStoreImpl(offsetArg uint32, storeInst asm.Instruc
 //
 // Note: this also emits the instructions to check the out of bounds memory access.
 // In other words, if the offset+targetSizeInBytes exceeds the memory size, the code exits with nativeCallStatusCodeMemoryOutOfBounds status.
-func (c *arm64Compiler) compileMemoryAccessOffsetSetup(offsetArg uint32, targetSizeInBytes int64) (offsetRegister asm.Register, err error) {
+//
+// When memory64 is true, the base is a 64-bit address, so the addition is checked for the unsigned overflow as well.
+func (c *arm64Compiler) compileMemoryAccessOffsetSetup(offsetArg uint64, targetSizeInBytes int64, memory64 bool) (offsetRegister asm.Register, err error) {
 	base, err := c.popValueOnRegister()
 	if err != nil {
 		return 0, err
@@ -2842,9 +3140,14 @@ func (c *arm64Compiler) compileMemoryAccessOffsetSetup(offsetArg uint32, targetS
 		c.assembler.CompileRegisterToRegister(arm64.MOVD, arm64.RegRZR, offsetRegister)
 	}
 
-	if offsetConst := int64(offsetArg) + targetSizeInBytes; offsetConst <= math.MaxUint32 {
+	if offsetConst := offsetArg + uint64(targetSizeInBytes); offsetConst <= math.MaxUint32 && !memory64 {
 		// "offsetRegister = base + offsetArg + targetSizeInBytes"
-		c.assembler.CompileConstToRegister(arm64.ADD, offsetConst, offsetRegister)
+		c.assembler.CompileConstToRegister(arm64.ADD, int64(offsetConst), offsetRegister)
+	} else if memory64 && offsetConst >= offsetArg {
+		// "offsetRegister = base + offsetArg + targetSizeInBytes" with setting the carry flag on overflow.
+		c.assembler.CompileConstToRegister(arm64.ADDS, int64(offsetConst), offsetRegister)
+		// If the addition overflows, the ceil is out of bounds.
+		c.compileMaybeExitFromNativeCode(arm64.BCONDLO, nativeCallStatusCodeMemoryOutOfBounds)
 	} else {
 		// If the offset const is too large, we exit with nativeCallStatusCodeMemoryOutOfBounds.
 		c.compileExitFromNativeCode(nativeCallStatusCodeMemoryOutOfBounds)
@@ -2885,7 +3188,7 @@ func (c *arm64Compiler) compileMemoryGrow() error {
 }
 
 // compileMemorySize implements compileMemorySize variants for arm64 architecture.
-func (c *arm64Compiler) compileMemorySize() error {
+func (c *arm64Compiler) compileMemorySize(o *wazeroir.UnionOperation) error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
 		return err
 	}
@@ -2910,7 +3213,12 @@ func (c *arm64Compiler) compileMemorySize() error {
 		reg,
 	)
 
-	c.pushRuntimeValueLocationOnRegister(reg, runtimeValueTypeI32)
+	vt := runtimeValueTypeI32
+	if o.B3 {
+		// 64-bit memories return the page count as i64.
+		vt = runtimeValueTypeI64
+	}
+	c.pushRuntimeValueLocationOnRegister(reg, vt)
 	return nil
 }
 
@@ -3874,6 +4182,116 @@ func (c *arm64Compiler) compileTableGrow(o *wazeroir.UnionOperation) error {
 	return nil
 }
 
+// compileAtomic implements compiler.compileAtomic for the arm64 architecture.
+func (c *arm64Compiler) compileAtomic(o *wazeroir.UnionOperation) error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	// The static offset of a 64-bit memory can exceed 32 bits, so it is pushed separately from the descriptor.
+	if o.B3 {
+		if err := c.compileIntConstant(false, o.U2); err != nil {
+			return err
+		}
+	}
+
+	// Pushes the descriptor of the operation which is decoded by the builtin function.
+	if err := c.compileIntConstant(false, atomicOperationDescriptor(o)); err != nil {
+		return err
+	}
+
+	// Atomic instructions are executed in Go, which synchronizes with the interpreter semantics.
+	if err := c.compileCallGoFunction(nativeCallStatusCodeCallBuiltInFunction, builtinFunctionIndexAtomic); err != nil {
+		return err
+	}
+
+	// The builtin function consumes the descriptor and the operands.
+	paramNum, result := atomicOperationStackEffect(o)
+	if o.B3 {
+		paramNum++ // The static offset.
+	}
+	for i := 0; i < paramNum+1; i++ {
+		c.locationStack.pop()
+	}
+
+	if result != runtimeValueTypeNone {
+		v := c.locationStack.pushRuntimeValueLocationOnStack()
+		v.valueType = result
+	}
+
+	// After return, we re-initialize reserved registers just like preamble of functions.
+	c.compileReservedStackBasePointerRegisterInitialization()
+	c.compileReservedMemoryRegisterInitialization()
+	return nil
+}
+
+// compileSelectMemory implements compiler.compileSelectMemory for the arm64 architecture.
+func (c *arm64Compiler) compileSelectMemory(index uint32) error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	tmpX, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+	c.markRegisterUsed(tmpX)
+	tmpY, err := c.allocateRegister(registerTypeGeneralPurpose)
+	if err != nil {
+		return err
+	}
+
+	// "tmpX = ce.moduleContext.moduleInstance.Memories[index]"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextModuleInstanceOffset, tmpX)
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, tmpX, moduleInstanceMemoriesOffset, tmpX)
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, tmpX, int64(index)*8, tmpX)
+
+	// "ce.MemoryInstance = tmpX"
+	c.assembler.CompileRegisterToMemory(arm64.STRD, tmpX,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextMemoryInstanceOffset)
+
+	// "ce.MemorySliceLen = len(tmpX.Buffer)"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, tmpX, memoryInstanceBufferLenOffset, tmpY)
+	c.assembler.CompileRegisterToMemory(arm64.STRD, tmpY,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextMemorySliceLenOffset)
+
+	// "ce.MemoryElement0Address = &tmpX.Buffer[0]"
+	c.assembler.CompileMemoryToRegister(arm64.LDRD, tmpX, memoryInstanceBufferOffset, tmpY)
+	c.assembler.CompileRegisterToMemory(arm64.STRD, tmpY,
+		arm64ReservedRegisterForCallEngine, callEngineModuleContextMemoryElement0AddressOffset)
+
+	c.markRegisterUnused(tmpX)
+	c.compileReservedMemoryRegisterInitialization()
+	return nil
+}
+
+// compileMemoryBulkBuiltin implements compiler.compileMemoryBulkBuiltin for the arm64 architecture.
+func (c *arm64Compiler) compileMemoryBulkBuiltin(o *wazeroir.UnionOperation, builtin wasm.Index) error {
+	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
+		return err
+	}
+
+	// Pushes the memory and data indexes which are decoded by the builtin function.
+	if err := c.compileIntConstant(false, memoryBulkDescriptor(o)); err != nil {
+		return err
+	}
+
+	if err := c.compileCallGoFunction(nativeCallStatusCodeCallBuiltInFunction, builtin); err != nil {
+		return err
+	}
+
+	// The builtin function consumes the descriptor and the three operands of the operation.
+	for i := 0; i < 4; i++ {
+		c.locationStack.pop()
+	}
+
+	// After return, we re-initialize reserved registers just like preamble of functions.
+	c.compileReservedStackBasePointerRegisterInitialization()
+	c.compileReservedMemoryRegisterInitialization()
+	return nil
+}
+
 // compileTableSize implements compiler.compileTableSize for the arm64 architecture.
 func (c *arm64Compiler) compileTableSize(o *wazeroir.UnionOperation) error {
 	if err := c.maybeCompileMoveTopConditionalToGeneralPurposeRegister(); err != nil {
@@ -123,26 +123,26 @@ func (c *amd64Compiler) compileV128Load(o *wazeroir.UnionOperation) error {
 		return err
 	}
 
-	offset := uint32(o.U2)
+	offset := o.U2
 	loadType := wazeroir.V128LoadType(o.B1)
 
 	switch loadType {
 	case wazeroir.V128LoadType128:
-		err = c.compileV128LoadImpl(amd64.MOVDQU, offset, 16, result)
+		err = c.compileV128LoadImpl(amd64.MOVDQU, offset, o.B3, 16, result)
 	case wazeroir.V128LoadType8x8s:
-		err = c.compileV128LoadImpl(amd64.PMOVSXBW, offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.PMOVSXBW, offset, o.B3, 8, result)
 	case wazeroir.V128LoadType8x8u:
-		err = c.compileV128LoadImpl(amd64.PMOVZXBW, offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.PMOVZXBW, offset, o.B3, 8, result)
 	case wazeroir.V128LoadType16x4s:
-		err = c.compileV128LoadImpl(amd64.PMOVSXWD, offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.PMOVSXWD, offset, o.B3, 8, result)
 	case wazeroir.V128LoadType16x4u:
-		err = c.compileV128LoadImpl(amd64.PMOVZXWD, offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.PMOVZXWD, offset, o.B3, 8, result)
 	case wazeroir.V128LoadType32x2s:
-		err = c.compileV128LoadImpl(amd64.PMOVSXDQ, offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.PMOVSXDQ, offset, o.B3, 8, result)
 	case wazeroir.V128LoadType32x2u:
-		err = c.compileV128LoadImpl(amd64.PMOVZXDQ, offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.PMOVZXDQ, offset, o.B3, 8, result)
 	case wazeroir.V128LoadType8Splat:
-		reg, err := c.compileMemoryAccessCeilSetup(offset, 1)
+		reg, err := c.compileMemoryAccessCeilSetup(offset, 1, o.B3)
 		if err != nil {
 			return err
 		}
@@ -160,7 +160,7 @@ func (c *amd64Compiler) compileV128Load(o *wazeroir.UnionOperation) error {
 		c.assembler.CompileRegisterToRegister(amd64.PXOR, tmpVReg, tmpVReg)
 		c.assembler.CompileRegisterToRegister(amd64.PSHUFB, tmpVReg, result)
 	case wazeroir.V128LoadType16Splat:
-		reg, err := c.compileMemoryAccessCeilSetup(offset, 2)
+		reg, err := c.compileMemoryAccessCeilSetup(offset, 2, o.B3)
 		if err != nil {
 			return err
 		}
@@ -173,7 +173,7 @@ func (c *amd64Compiler) compileV128Load(o *wazeroir.UnionOperation) error {
 		c.assembler.CompileRegisterToRegisterWithArg(amd64.PINSRW, reg, result, 1)
 		c.assembler.CompileRegisterToRegisterWithArg(amd64.PSHUFD, result, result, 0)
 	case wazeroir.V128LoadType32Splat:
-		reg, err := c.compileMemoryAccessCeilSetup(offset, 4)
+		reg, err := c.compileMemoryAccessCeilSetup(offset, 4, o.B3)
 		if err != nil {
 			return err
 		}
@@ -184,7 +184,7 @@ func (c *amd64Compiler) compileV128Load(o *wazeroir.UnionOperation) error {
 		c.assembler.CompileRegisterToRegisterWithArg(amd64.PINSRD, reg, result, 0)
 		c.assembler.CompileRegisterToRegisterWithArg(amd64.PSHUFD, result, result, 0)
 	case wazeroir.V128LoadType64Splat:
-		reg, err := c.compileMemoryAccessCeilSetup(offset, 8)
+		reg, err := c.compileMemoryAccessCeilSetup(offset, 8, o.B3)
 		if err != nil {
 			return err
 		}
@@ -195,9 +195,9 @@ func (c *amd64Compiler) compileV128Load(o *wazeroir.UnionOperation) error {
 		c.assembler.CompileRegisterToRegisterWithArg(amd64.PINSRQ, reg, result, 0)
 		c.assembler.CompileRegisterToRegisterWithArg(amd64.PINSRQ, reg, result, 1)
 	case wazeroir.V128LoadType32zero:
-		err = c.compileV128LoadImpl(amd64.MOVL, offset, 4, result)
+		err = c.compileV128LoadImpl(amd64.MOVL, offset, o.B3, 4, result)
 	case wazeroir.V128LoadType64zero:
-		err = c.compileV128LoadImpl(amd64.MOVQ, offset, 8, result)
+		err = c.compileV128LoadImpl(amd64.MOVQ, offset, o.B3, 8, result)
 	}
 
 	if err != nil {
@@ -208,8 +208,8 @@ func (c *amd64Compiler) compileV128Load(o *wazeroir.UnionOperation) error {
 	return nil
 }
 
-func (c *amd64Compiler) compileV128LoadImpl(inst asm.Instruction, offset uint32, targetSizeInBytes int64, dst asm.Register) error {
-	offsetReg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes)
+func (c *amd64Compiler) compileV128LoadImpl(inst asm.Instruction, offset uint64, memory64 bool, targetSizeInBytes int64, dst asm.Register) error {
+	offsetReg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes, memory64)
 	if err != nil {
 		return err
 	}
@@ -226,7 +226,7 @@ func (c *amd64Compiler) compileV128LoadLane(o *wazeroir.UnionOperation) error {
 	}
 
 	laneSize, laneIndex := o.B1, o.B2
-	offset := uint32(o.U2)
+	offset := o.U2
 
 	var insertInst asm.Instruction
 	switch laneSize {
@@ -241,7 +241,7 @@ func (c *amd64Compiler) compileV128LoadLane(o *wazeroir.UnionOperation) error {
 	}
 
 	targetSizeInBytes := int64(laneSize / 8)
-	offsetReg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes)
+	offsetReg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes, o.B3)
 	if err != nil {
 		return err
 	}
@@ -260,8 +260,8 @@ func (c *amd64Compiler) compileV128Store(o *wazeroir.UnionOperation) error {
 	}
 
 	const targetSizeInBytes = 16
-	offset := uint32(o.U2)
-	offsetReg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes)
+	offset := o.U2
+	offsetReg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes, o.B3)
 	if err != nil {
 		return err
 	}
@@ -278,7 +278,7 @@ func (c *amd64Compiler) compileV128StoreLane(o *wazeroir.UnionOperation) error {
 	var storeInst asm.Instruction
 	laneSize := o.B1
 	laneIndex := o.B2
-	offset := uint32(o.U2)
+	offset := o.U2
 	switch laneSize {
 	case 8:
 		storeInst = amd64.PEXTRB
@@ -296,7 +296,7 @@ func (c *amd64Compiler) compileV128StoreLane(o *wazeroir.UnionOperation) error {
 	}
 
 	targetSizeInBytes := int64(laneSize / 8)
-	offsetReg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes)
+	offsetReg, err := c.compileMemoryAccessCeilSetup(offset, targetSizeInBytes, o.B3)
 	if err != nil {
 		return err
 	}
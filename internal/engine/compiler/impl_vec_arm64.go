@@ -144,12 +144,12 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.UnionOperation) (err error)
 		return err
 	}
 
-	offset := uint32(o.U2)
+	offset := o.U2
 	loadType := wazeroir.V128LoadType(o.B1)
 
 	switch loadType {
 	case wazeroir.V128LoadType128:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 16)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 16, o.B3)
 		if err != nil {
 			return err
 		}
@@ -157,7 +157,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.UnionOperation) (err error)
 			arm64ReservedRegisterForMemory, offset, result, arm64.VectorArrangementQ,
 		)
 	case wazeroir.V128LoadType8x8s:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8, o.B3)
 		if err != nil {
 			return err
 		}
@@ -167,7 +167,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.UnionOperation) (err error)
 		c.assembler.CompileVectorRegisterToVectorRegister(arm64.SSHLL, result, result,
 			arm64.VectorArrangement8B, arm64.VectorIndexNone, arm64.VectorIndexNone)
 	case wazeroir.V128LoadType8x8u:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8, o.B3)
 		if err != nil {
 			return err
 		}
@@ -177,7 +177,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.UnionOperation) (err error)
 		c.assembler.CompileVectorRegisterToVectorRegister(arm64.USHLL, result, result,
 			arm64.VectorArrangement8B, arm64.VectorIndexNone, arm64.VectorIndexNone)
 	case wazeroir.V128LoadType16x4s:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8, o.B3)
 		if err != nil {
 			return err
 		}
@@ -187,7 +187,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.UnionOperation) (err error)
 		c.assembler.CompileVectorRegisterToVectorRegister(arm64.SSHLL, result, result,
 			arm64.VectorArrangement4H, arm64.VectorIndexNone, arm64.VectorIndexNone)
 	case wazeroir.V128LoadType16x4u:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8, o.B3)
 		if err != nil {
 			return err
 		}
@@ -197,7 +197,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.UnionOperation) (err error)
 		c.assembler.CompileVectorRegisterToVectorRegister(arm64.USHLL, result, result,
 			arm64.VectorArrangement4H, arm64.VectorIndexNone, arm64.VectorIndexNone)
 	case wazeroir.V128LoadType32x2s:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8, o.B3)
 		if err != nil {
 			return err
 		}
@@ -207,7 +207,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.UnionOperation) (err error)
 		c.assembler.CompileVectorRegisterToVectorRegister(arm64.SSHLL, result, result,
 			arm64.VectorArrangement2S, arm64.VectorIndexNone, arm64.VectorIndexNone)
 	case wazeroir.V128LoadType32x2u:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8, o.B3)
 		if err != nil {
 			return err
 		}
@@ -217,35 +217,35 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.UnionOperation) (err error)
 		c.assembler.CompileVectorRegisterToVectorRegister(arm64.USHLL, result, result,
 			arm64.VectorArrangement2S, arm64.VectorIndexNone, arm64.VectorIndexNone)
 	case wazeroir.V128LoadType8Splat:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 1)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 1, o.B3)
 		if err != nil {
 			return err
 		}
 		c.assembler.CompileRegisterToRegister(arm64.ADD, arm64ReservedRegisterForMemory, offset)
 		c.assembler.CompileMemoryToVectorRegister(arm64.LD1R, offset, 0, result, arm64.VectorArrangement16B)
 	case wazeroir.V128LoadType16Splat:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 2)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 2, o.B3)
 		if err != nil {
 			return err
 		}
 		c.assembler.CompileRegisterToRegister(arm64.ADD, arm64ReservedRegisterForMemory, offset)
 		c.assembler.CompileMemoryToVectorRegister(arm64.LD1R, offset, 0, result, arm64.VectorArrangement8H)
 	case wazeroir.V128LoadType32Splat:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 4)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 4, o.B3)
 		if err != nil {
 			return err
 		}
 		c.assembler.CompileRegisterToRegister(arm64.ADD, arm64ReservedRegisterForMemory, offset)
 		c.assembler.CompileMemoryToVectorRegister(arm64.LD1R, offset, 0, result, arm64.VectorArrangement4S)
 	case wazeroir.V128LoadType64Splat:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8, o.B3)
 		if err != nil {
 			return err
 		}
 		c.assembler.CompileRegisterToRegister(arm64.ADD, arm64ReservedRegisterForMemory, offset)
 		c.assembler.CompileMemoryToVectorRegister(arm64.LD1R, offset, 0, result, arm64.VectorArrangement2D)
 	case wazeroir.V128LoadType32zero:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 4)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 4, o.B3)
 		if err != nil {
 			return err
 		}
@@ -253,7 +253,7 @@ func (c *arm64Compiler) compileV128Load(o *wazeroir.UnionOperation) (err error)
 			arm64ReservedRegisterForMemory, offset, result, arm64.VectorArrangementS,
 		)
 	case wazeroir.V128LoadType64zero:
-		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8)
+		offset, err := c.compileMemoryAccessOffsetSetup(offset, 8, o.B3)
 		if err != nil {
 			return err
 		}
@@ -274,10 +274,10 @@ func (c *arm64Compiler) compileV128LoadLane(o *wazeroir.UnionOperation) (err err
 	}
 
 	laneSize, laneIndex := o.B1, o.B2
-	offset := uint32(o.U2)
+	offset := o.U2
 
 	targetSizeInBytes := int64(laneSize / 8)
-	source, err := c.compileMemoryAccessOffsetSetup(offset, targetSizeInBytes)
+	source, err := c.compileMemoryAccessOffsetSetup(offset, targetSizeInBytes, o.B3)
 	if err != nil {
 		return err
 	}
@@ -315,8 +315,8 @@ func (c *arm64Compiler) compileV128Store(o *wazeroir.UnionOperation) (err error)
 	}
 
 	const targetSizeInBytes = 16
-	offset := uint32(o.U2)
-	offsetReg, err := c.compileMemoryAccessOffsetSetup(offset, targetSizeInBytes)
+	offset := o.U2
+	offsetReg, err := c.compileMemoryAccessOffsetSetup(offset, targetSizeInBytes, o.B3)
 	if err != nil {
 		return err
 	}
@@ -334,7 +334,7 @@ func (c *arm64Compiler) compileV128StoreLane(o *wazeroir.UnionOperation) (err er
 	var storeInst asm.Instruction
 	laneSize := o.B1
 	laneIndex := o.B2
-	offset := uint32(o.U2)
+	offset := o.U2
 	switch laneSize {
 	case 8:
 		storeInst = arm64.STRB
@@ -356,7 +356,7 @@ func (c *arm64Compiler) compileV128StoreLane(o *wazeroir.UnionOperation) (err er
 	}
 
 	targetSizeInBytes := int64(laneSize / 8)
-	offsetReg, err := c.compileMemoryAccessOffsetSetup(offset, targetSizeInBytes)
+	offsetReg, err := c.compileMemoryAccessOffsetSetup(offset, targetSizeInBytes, o.B3)
 	if err != nil {
 		return err
 	}
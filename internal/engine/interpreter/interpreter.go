@@ -12,6 +12,7 @@ import (
 
 	"github.com/AR1011/wazero/api"
 	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/internal/coredump"
 	"github.com/AR1011/wazero/internal/filecache"
 	"github.com/AR1011/wazero/internal/internalapi"
 	"github.com/AR1011/wazero/internal/moremath"
@@ -19,12 +20,14 @@ import (
 	"github.com/AR1011/wazero/internal/wasmdebug"
 	"github.com/AR1011/wazero/internal/wasmruntime"
 	"github.com/AR1011/wazero/internal/wazeroir"
+	"github.com/AR1011/wazero/sys"
 )
 
-// callStackCeiling is the maximum WebAssembly call frame stack height. This allows wazero to raise
+// callStackCeiling is the default maximum WebAssembly call frame stack height. This allows wazero to raise
 // wasm.ErrCallStackOverflow instead of overflowing the Go runtime.
 //
-// The default value should suffice for most use cases. Those wishing to change this can via `go build -ldflags`.
+// The default value should suffice for most use cases. Those wishing to change this can via `go build -ldflags`,
+// or per module via wasm.Module MaxCallStackDepth.
 var callStackCeiling = 2000
 
 // engine is an interpreter implementation of wasm.Engine
@@ -92,6 +95,16 @@ func (e *moduleEngine) GetGlobalValue(wasm.Index) (lo, hi uint64) {
 	panic("BUG: GetGlobalValue should never be called on interpreter mode")
 }
 
+// SetGlobalValue implements the same method as documented on wasm.ModuleEngine.
+func (e *moduleEngine) SetGlobalValue(wasm.Index, uint64, uint64) {
+	panic("BUG: SetGlobalValue should never be called on interpreter mode")
+}
+
+// MemoryChanged implements the same method as documented on wasm.ModuleEngine.
+func (e *moduleEngine) MemoryChanged() {
+	// The memory is loaded from wasm.ModuleInstance on each function call, so nothing to do.
+}
+
 // OwnsGlobals implements the same method as documented on wasm.ModuleEngine.
 func (e *moduleEngine) OwnsGlobals() bool { return false }
 
@@ -114,6 +127,16 @@ type callEngine struct {
 
 	// stackiterator for Listeners to walk frames and stack.
 	stackIterator stackIterator
+
+	// exceptions holds the exceptions caught with catch_ref or catch_all_ref during the current call. An exnref
+	// value on the stack is the index of this plus one, so that zero represents the null exnref.
+	exceptions []*api.Exception
+
+	// callStackCeiling is the maximum height of frames during the current call.
+	callStackCeiling int
+
+	// epochDeadline is the epoch at which the current call traps in addition to wasm.ModuleInstance EpochDeadline.
+	epochDeadline uint64
 }
 
 func (e *moduleEngine) newCallEngine(compiled *function) *callEngine {
@@ -170,12 +193,21 @@ func (ce *callEngine) drop(raw uint64) {
 }
 
 func (ce *callEngine) pushFrame(frame *callFrame) {
-	if callStackCeiling <= len(ce.frames) {
+	if ce.callStackCeiling <= len(ce.frames) {
 		panic(wasmruntime.ErrRuntimeStackOverflow)
 	}
 	ce.frames = append(ce.frames, frame)
 }
 
+// dropFrameForTailCall discards the values of the current frame, including the parameters, except for the arguments
+// of the tail call to tf placed on top of the stack. The arguments are moved to the beginning of the frame.
+func (ce *callEngine) dropFrameForTailCall(frame *callFrame, tf *function) {
+	start := frame.base - frame.f.funcType.ParamNumInUint64
+	paramNum := tf.funcType.ParamNumInUint64
+	copy(ce.stack[start:], ce.stack[len(ce.stack)-paramNum:])
+	ce.stack = ce.stack[:start+paramNum]
+}
+
 func (ce *callEngine) popFrame() (frame *callFrame) {
 	// No need to check stack bound as we can assume that all the operations are valid thanks to validateFunction at
 	// module validation phase and wazeroir translation before compilation.
@@ -203,6 +235,33 @@ type compiledFunction struct {
 	hostFn              interface{}
 	ensureTermination   bool
 	index               wasm.Index
+	// exceptionHandlers are the lowered wazeroir.ExceptionHandler of this function.
+	exceptionHandlers []exceptionHandler
+	// blockListener is the listener when it implements experimental.BlockListener.
+	blockListener experimental.BlockListener
+	// blockEnds is index-correlated with body, and is the end offset of the block starting at the operation, which is
+	// either the first one or a label. Only set when blockListener is.
+	blockEnds []uint64
+	// instructionListener is the listener when it implements experimental.InstructionListener.
+	instructionListener experimental.InstructionListener
+	// localsNumInUint64 is the number of values taken by the non-param locals. Only set when instructionListener is.
+	localsNumInUint64 int
+}
+
+// exceptionHandler is the lowered form of wazeroir.ExceptionHandler whose labels are resolved to the addresses.
+type exceptionHandler struct {
+	// begin and end are the range of the body, [begin, end), where the exceptions are caught by this handler.
+	begin, end uint64
+	// stackHeight is the same as wazeroir.ExceptionHandler.StackHeightInUint64.
+	stackHeight int
+	catches     []exceptionHandlerCatch
+}
+
+type exceptionHandlerCatch struct {
+	kind wasm.TryTableCatchKind
+	tag  wasm.Index
+	// address is the index of the body where the landing pad begins.
+	address uint64
 }
 
 type function struct {
@@ -312,6 +371,15 @@ func (e *engine) CompileModule(_ context.Context, module *wasm.Module, listeners
 	if err != nil {
 		return err
 	}
+	for _, lsn := range listeners {
+		_, isBlockListener := lsn.(experimental.BlockListener)
+		_, isInstructionListener := lsn.(experimental.InstructionListener)
+		if isBlockListener || isInstructionListener {
+			// Blocks and instructions are notified with their offsets, which are otherwise only recorded for DWARF.
+			irCompiler.RecordSourceOffsets()
+			break
+		}
+	}
 	imported := module.ImportFunctionCount
 	for i := range module.CodeSection {
 		var lsn experimental.FunctionListener
@@ -330,11 +398,25 @@ func (e *engine) CompileModule(_ context.Context, module *wasm.Module, listeners
 			if err != nil {
 				return err
 			}
+			if il, ok := lsn.(experimental.InstructionListener); ok {
+				compiled.instructionListener = il
+				insertInstructionNotifications(ir)
+			}
 			err = e.lowerIR(ir, compiled)
 			if err != nil {
 				def := module.FunctionDefinition(uint32(i) + module.ImportFunctionCount)
 				return fmt.Errorf("failed to lower func[%s] to wazeroir: %w", def.DebugName(), err)
 			}
+			if bl, ok := lsn.(experimental.BlockListener); ok {
+				compiled.blockListener = bl
+				compiled.blockEnds = blockEnds(compiled, codeSeg.BodyOffsetInCodeSection+uint64(len(codeSeg.Body)))
+			}
+			for _, t := range codeSeg.LocalTypes {
+				compiled.localsNumInUint64++
+				if t == wasm.ValueTypeV128 {
+					compiled.localsNumInUint64++
+				}
+			}
 		}
 		compiled.source = module
 		compiled.ensureTermination = ensureTermination
@@ -345,6 +427,52 @@ func (e *engine) CompileModule(_ context.Context, module *wasm.Module, listeners
 	return nil
 }
 
+// blockEnds returns the end offset of the blocks starting at the first operation and at each label, which is the
+// offset of the next block, or bodyEnd for the last one.
+func blockEnds(compiled *compiledFunction, bodyEnd uint64) []uint64 {
+	body, offsets := compiled.body, compiled.offsetsInWasmBinary
+	ends := make([]uint64, len(body))
+	end := bodyEnd
+	for i := len(body) - 1; i >= 0; i-- {
+		if i == 0 || body[i].Kind == wazeroir.OperationKindLabel {
+			ends[i] = end
+			if offsets[i] < end {
+				end = offsets[i]
+			}
+		}
+	}
+	return ends
+}
+
+// insertInstructionNotifications inserts wazeroir.OperationKindBuiltinFunctionNotifyInstruction before the first
+// operation of each instruction, which is when its offset differs from the previous operation. The operations before
+// BodyStart initialize the function, so aren't part of an instruction. This must be done before labels are resolved,
+// as the indexes of the operations change.
+func insertInstructionNotifications(ir *wazeroir.CompilationResult) {
+	ops, offsets := ir.Operations, ir.IROperationSourceOffsetsInWasmBinary
+	// indexes maps the index of each operation, and the end of the body, to the index after insertions.
+	indexes := make([]int, len(ops)+1)
+	newOps := make([]wazeroir.UnionOperation, 0, len(ops))
+	newOffsets := make([]uint64, 0, len(ops))
+	for i := range ops {
+		if i >= ir.BodyStart && (i == ir.BodyStart || offsets[i] != offsets[i-1]) {
+			newOps = append(newOps, wazeroir.NewOperationBuiltinFunctionNotifyInstruction())
+			newOffsets = append(newOffsets, offsets[i])
+		}
+		indexes[i] = len(newOps)
+		newOps = append(newOps, ops[i])
+		newOffsets = append(newOffsets, offsets[i])
+	}
+	indexes[len(ops)] = len(newOps)
+
+	for i := range ir.ExceptionHandlers {
+		h := &ir.ExceptionHandlers[i]
+		h.Begin, h.End = indexes[h.Begin], indexes[h.End]
+	}
+	// BodyStart is unchanged, as nothing is inserted before it.
+	ir.Operations, ir.IROperationSourceOffsetsInWasmBinary = newOps, newOffsets
+}
+
 // NewModuleEngine implements the same method as documented on wasm.Engine.
 func (e *engine) NewModuleEngine(module *wasm.Module, instance *wasm.ModuleInstance) (wasm.ModuleEngine, error) {
 	me := &moduleEngine{
@@ -389,6 +517,10 @@ func (e *engine) lowerIR(ir *wazeroir.CompilationResult, ret *compiledFunction)
 		case wazeroir.OperationKindLabel:
 			label := wazeroir.Label(op.U1)
 			address := uint64(i)
+			if i > 0 && ret.body[i-1].Kind == wazeroir.OperationKindBuiltinFunctionNotifyInstruction {
+				// Branches to the label also notify the instruction starting with it.
+				address--
+			}
 
 			kind, fid := label.Kind(), label.FrameID()
 			frameToAddresses := e.labelAddressResolutionCache[label.Kind()]
@@ -420,6 +552,21 @@ func (e *engine) lowerIR(ir *wazeroir.CompilationResult, ret *compiledFunction)
 		}
 	}
 
+	if len(ir.ExceptionHandlers) > 0 {
+		ret.exceptionHandlers = make([]exceptionHandler, len(ir.ExceptionHandlers))
+		for i := range ir.ExceptionHandlers {
+			h := &ir.ExceptionHandlers[i]
+			lowered := &ret.exceptionHandlers[i]
+			lowered.begin, lowered.end, lowered.stackHeight = uint64(h.Begin), uint64(h.End), h.StackHeightInUint64
+			lowered.catches = make([]exceptionHandlerCatch, len(h.Catches))
+			for j := range h.Catches {
+				c := &h.Catches[j]
+				lowered.catches[j] = exceptionHandlerCatch{kind: c.Kind, tag: c.Tag}
+				e.setLabelAddress(&lowered.catches[j].address, c.Label)
+			}
+		}
+	}
+
 	// Reuses the slices for the subsequent compilation, so clear the content here.
 	for i := range e.labelAddressResolutionCache {
 		e.labelAddressResolutionCache[i] = e.labelAddressResolutionCache[i][:0]
@@ -483,6 +630,11 @@ func (ce *callEngine) Definition() api.FunctionDefinition {
 	return ce.f.definition()
 }
 
+// FunctionReference returns the reference to this function as it's stored in a table, for api.Table SetFunction.
+func (ce *callEngine) FunctionReference() wasm.Reference {
+	return uintptr(unsafe.Pointer(ce.f))
+}
+
 func (f *function) definition() api.FunctionDefinition {
 	compiled := f.parent
 	return compiled.source.FunctionDefinition(compiled.index)
@@ -532,6 +684,12 @@ func (ce *callEngine) call(ctx context.Context, params, results []uint64) (_ []u
 		}
 	}()
 
+	ce.callStackCeiling = callStackCeiling
+	// The ceiling is read from the module instance, as the compiled source is shared with the runtimes which have a
+	// different ceiling but use the same CompilationCache.
+	if ceiling := m.Source.CallStackDepthCeiling(ctx); ceiling > 0 {
+		ce.callStackCeiling = int(ceiling)
+	}
 	ce.pushValues(params)
 
 	if ce.f.parent.ensureTermination {
@@ -539,6 +697,11 @@ func (ce *callEngine) call(ctx context.Context, params, results []uint64) (_ []u
 		defer done()
 	}
 
+	ce.epochDeadline = math.MaxUint64
+	if ticks, ok := ce.f.parent.source.CallEpochDeadline(ctx); ok {
+		ce.epochDeadline = m.EpochAfter(ticks)
+	}
+
 	ce.callFunction(ctx, m, ce.f)
 
 	// This returns a safe copy of the results, instead of a slice view. If we
@@ -549,6 +712,7 @@ func (ce *callEngine) call(ctx context.Context, params, results []uint64) (_ []u
 		results = make([]uint64, ft.ResultNumInUint64)
 	}
 	ce.popValues(results)
+	ce.resetExceptions()
 	return results, nil
 }
 
@@ -564,6 +728,10 @@ type functionListenerInvocation struct {
 // so that it can be used for the subsequent calls.
 func (ce *callEngine) recoverOnCall(ctx context.Context, m *wasm.ModuleInstance, v interface{}) (err error) {
 	builder := wasmdebug.NewErrorBuilder()
+	var coreDump *coredump.Builder
+	if m.Source.CoreDump {
+		coreDump = &coredump.Builder{}
+	}
 	frameCount := len(ce.frames)
 	functionListeners := make([]functionListenerInvocation, 0, 16)
 
@@ -573,7 +741,15 @@ func (ce *callEngine) recoverOnCall(ctx context.Context, m *wasm.ModuleInstance,
 		def := f.definition()
 		var sources []string
 		if parent := frame.f.parent; parent.body != nil && len(parent.offsetsInWasmBinary) > 0 {
-			sources = parent.source.DWARFLines.Line(parent.offsetsInWasmBinary[frame.pc])
+			offset := parent.offsetsInWasmBinary[frame.pc]
+			sources = parent.source.DWARFLines.Line(offset)
+			if coreDump != nil {
+				start, end := frame.base-f.funcType.ParamNumInUint64, frame.base+parent.localsNumInUint64
+				if end > len(ce.stack) { // trapped before the locals were pushed.
+					end = len(ce.stack)
+				}
+				coreDump.AddFrame(f.moduleInstance, parent.index, offset, ce.stack[start:end:end])
+			}
 		}
 		builder.AddFrame(def.DebugName(), def.ParamTypes(), def.ResultTypes(), sources)
 		if f.parent.listener != nil {
@@ -585,15 +761,97 @@ func (ce *callEngine) recoverOnCall(ctx context.Context, m *wasm.ModuleInstance,
 	}
 
 	err = builder.FromRecovered(v)
+	if coreDump != nil {
+		err = coreDump.Wrap(err)
+	}
 	for i := range functionListeners {
 		functionListeners[i].Abort(ctx, m, functionListeners[i].def, err)
 	}
 
 	// Allows the reuse of CallEngine.
 	ce.stack, ce.frames = ce.stack[:0], ce.frames[:0]
+	ce.resetExceptions()
 	return
 }
 
+// resetExceptions releases the exceptions referenced by exnref values after the call.
+func (ce *callEngine) resetExceptions() {
+	for i := range ce.exceptions {
+		ce.exceptions[i] = nil
+	}
+	ce.exceptions = ce.exceptions[:0]
+}
+
+// throw raises the exception in the frame, which either jumps to the landing pad of the innermost handler of
+// the current operation, or panics with the exception to unwind the frame.
+func (ce *callEngine) throw(frame *callFrame, exception *api.Exception) {
+	if !ce.catch(frame, exception) {
+		panic(exception)
+	}
+}
+
+// catch returns true if the exception is caught by a handler of the current operation of the frame. In that case,
+// the stack is unwound to the handler, the payload of the exception is pushed, and the frame jumps to the landing
+// pad.
+func (ce *callEngine) catch(frame *callFrame, exception *api.Exception) bool {
+	handlers := frame.f.parent.exceptionHandlers
+	for i := len(handlers) - 1; i >= 0; i-- {
+		h := &handlers[i]
+		if frame.pc < h.begin || frame.pc >= h.end {
+			continue
+		}
+		for j := range h.catches {
+			c := &h.catches[j]
+			var tag *wasm.TagInstance
+			if c.kind == wasm.TryTableCatchKindCatch || c.kind == wasm.TryTableCatchKindCatchRef {
+				tag = frame.f.moduleInstance.Tags[c.tag]
+				if api.Tag(tag) != exception.Tag() {
+					continue
+				}
+			}
+
+			ce.stack = ce.stack[:frame.base-frame.f.funcType.ParamNumInUint64+h.stackHeight]
+			if tag != nil {
+				params := exception.Params()
+				if len(params) != tag.Type.ParamNumInUint64 {
+					panic(fmt.Errorf("exception has %d params, but the tag expects %d", len(params), tag.Type.ParamNumInUint64))
+				}
+				ce.pushValues(params)
+			}
+			if c.kind == wasm.TryTableCatchKindCatchRef || c.kind == wasm.TryTableCatchKindCatchAllRef {
+				ce.exceptions = append(ce.exceptions, exception)
+				ce.pushValue(uint64(len(ce.exceptions)))
+			}
+			frame.pc = c.address
+			return true
+		}
+	}
+	return false
+}
+
+// callFunctionInFrame calls the function f from the frame, and advances the frame to the next operation. If f throws
+// an exception which is caught by the frame, the frame jumps to the landing pad instead.
+func (ce *callEngine) callFunctionInFrame(ctx context.Context, m *wasm.ModuleInstance, f *function, frame *callFrame) {
+	if frame.f.parent.exceptionHandlers == nil {
+		ce.callFunction(ctx, m, f)
+		frame.pc++
+		return
+	}
+
+	framesLen := len(ce.frames)
+	defer func() {
+		if v := recover(); v != nil {
+			// Leave the frames as is if not caught, so that they are included in the stack trace.
+			if exception, ok := v.(*api.Exception); !ok || !ce.catch(frame, exception) {
+				panic(v)
+			}
+			ce.frames = ce.frames[:framesLen]
+		}
+	}()
+	ce.callFunction(ctx, m, f)
+	frame.pc++
+}
+
 func (ce *callEngine) callFunction(ctx context.Context, m *wasm.ModuleInstance, f *function) {
 	if f.parent.hostFn != nil {
 		ce.callGoFuncWithStack(ctx, m, f)
@@ -636,7 +894,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 	frame := &callFrame{f: f, base: len(ce.stack)}
 	moduleInst := f.moduleInstance
 	functions := moduleInst.Engine.(*moduleEngine).functions
-	memoryInst := moduleInst.MemoryInstance
+	memories := moduleInst.Memories
 	globals := moduleInst.Globals
 	tables := moduleInst.Tables
 	typeIDs := moduleInst.TypeIDs
@@ -656,8 +914,26 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				panic(err)
 			}
 			frame.pc++
+		case wazeroir.OperationKindBuiltinFunctionConsumeFuel:
+			if moduleInst.Fuel--; moduleInst.Fuel < 0 {
+				panic(sys.NewExitError(sys.ExitCodeFuelExhausted))
+			}
+			frame.pc++
+		case wazeroir.OperationKindBuiltinFunctionCheckEpochDeadline:
+			if moduleInst.EpochDeadlineExceeded(ce.epochDeadline) {
+				panic(sys.NewExitError(sys.ExitCodeEpochDeadlineExceeded))
+			}
+			frame.pc++
+		case wazeroir.OperationKindBuiltinFunctionNotifyInstruction:
+			ce.notifyInstruction(ctx, m, frame)
+			frame.pc++
 		case wazeroir.OperationKindUnreachable:
 			panic(wasmruntime.ErrRuntimeUnreachable)
+		case wazeroir.OperationKindLabel:
+			if parent := f.parent; parent.blockListener != nil {
+				parent.blockListener.Block(ctx, m, f.definition(), parent.offsetsInWasmBinary[frame.pc], parent.blockEnds[frame.pc])
+			}
+			frame.pc++
 		case wazeroir.OperationKindBr:
 			frame.pc = op.U1
 		case wazeroir.OperationKindBrIf:
@@ -677,8 +953,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			ce.drop(op.Us[v+1])
 			frame.pc = op.Us[v]
 		case wazeroir.OperationKindCall:
-			ce.callFunction(ctx, f.moduleInstance, &functions[op.U1])
-			frame.pc++
+			ce.callFunctionInFrame(ctx, f.moduleInstance, &functions[op.U1], frame)
 		case wazeroir.OperationKindCallIndirect:
 			offset := ce.popValue()
 			table := tables[op.U2]
@@ -695,8 +970,63 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				panic(wasmruntime.ErrRuntimeIndirectCallTypeMismatch)
 			}
 
-			ce.callFunction(ctx, f.moduleInstance, tf)
-			frame.pc++
+			ce.callFunctionInFrame(ctx, f.moduleInstance, tf, frame)
+		case wazeroir.OperationKindThrow:
+			tag := moduleInst.Tags[op.U1]
+			params := make([]uint64, tag.Type.ParamNumInUint64)
+			ce.popValues(params)
+			ce.throw(frame, api.NewException(tag, params...))
+		case wazeroir.OperationKindThrowRef:
+			ref := ce.popValue()
+			if ref == 0 {
+				panic(wasmruntime.ErrRuntimeNullExceptionReference)
+			}
+			ce.throw(frame, ce.exceptions[ref-1])
+		case wazeroir.OperationKindTailCall, wazeroir.OperationKindTailCallIndirect:
+			var tf *function
+			if op.Kind == wazeroir.OperationKindTailCall {
+				tf = &functions[op.U1]
+			} else {
+				offset := ce.popValue()
+				table := tables[op.U2]
+				if offset >= uint64(len(table.References)) {
+					panic(wasmruntime.ErrRuntimeInvalidTableAccess)
+				}
+				rawPtr := table.References[offset]
+				if rawPtr == 0 {
+					panic(wasmruntime.ErrRuntimeInvalidTableAccess)
+				}
+
+				tf = functionFromUintptr(rawPtr)
+				if tf.typeID != typeIDs[op.U1] {
+					panic(wasmruntime.ErrRuntimeIndirectCallTypeMismatch)
+				}
+			}
+
+			// Discard the current frame except the arguments for the callee.
+			ce.dropFrameForTailCall(frame, tf)
+
+			if tf.parent.hostFn != nil || tf.parent.listener != nil {
+				// Host functions don't recurse, and listeners must observe the call, so call them as usual and then
+				// return the results left on the stack.
+				ce.callFunction(ctx, f.moduleInstance, tf)
+				frame.pc = bodyLen
+				break
+			}
+
+			// Reuse the current frame to execute the callee.
+			m, f = f.moduleInstance, tf
+			moduleInst = f.moduleInstance
+			functions = moduleInst.Engine.(*moduleEngine).functions
+			memories = moduleInst.Memories
+			globals = moduleInst.Globals
+			tables = moduleInst.Tables
+			typeIDs = moduleInst.TypeIDs
+			dataInstances = moduleInst.DataInstances
+			elementInstances = moduleInst.ElementInstances
+			frame.f, frame.pc, frame.base = f, 0, len(ce.stack)
+			body = f.parent.body
+			bodyLen = uint64(len(body))
 		case wazeroir.OperationKindDrop:
 			ce.drop(op.U1)
 			frame.pc++
@@ -750,16 +1080,17 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			g.Val = ce.popValue()
 			frame.pc++
 		case wazeroir.OperationKindLoad:
+			memoryInst := memories[op.U3]
 			offset := ce.popMemoryOffset(op)
 			switch wazeroir.UnsignedType(op.B1) {
 			case wazeroir.UnsignedTypeI32, wazeroir.UnsignedTypeF32:
-				if val, ok := memoryInst.ReadUint32Le(offset); !ok {
+				if val, ok := memoryInst.ReadUint32Le64(offset); !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				} else {
 					ce.pushValue(uint64(val))
 				}
 			case wazeroir.UnsignedTypeI64, wazeroir.UnsignedTypeF64:
-				if val, ok := memoryInst.ReadUint64Le(offset); !ok {
+				if val, ok := memoryInst.ReadUint64Le64(offset); !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				} else {
 					ce.pushValue(val)
@@ -767,7 +1098,8 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case wazeroir.OperationKindLoad8:
-			val, ok := memoryInst.ReadByte(ce.popMemoryOffset(op))
+			memoryInst := memories[op.U3]
+			val, ok := memoryInst.ReadByte64(ce.popMemoryOffset(op))
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
@@ -782,8 +1114,9 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case wazeroir.OperationKindLoad16:
+			memoryInst := memories[op.U3]
 
-			val, ok := memoryInst.ReadUint16Le(ce.popMemoryOffset(op))
+			val, ok := memoryInst.ReadUint16Le64(ce.popMemoryOffset(op))
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
@@ -798,7 +1131,8 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case wazeroir.OperationKindLoad32:
-			val, ok := memoryInst.ReadUint32Le(ce.popMemoryOffset(op))
+			memoryInst := memories[op.U3]
+			val, ok := memoryInst.ReadUint32Le64(ce.popMemoryOffset(op))
 			if !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
@@ -810,46 +1144,60 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case wazeroir.OperationKindStore:
+			memoryInst := memories[op.U3]
 			val := ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			switch wazeroir.UnsignedType(op.B1) {
 			case wazeroir.UnsignedTypeI32, wazeroir.UnsignedTypeF32:
-				if !memoryInst.WriteUint32Le(offset, uint32(val)) {
+				if !memoryInst.WriteUint32Le64(offset, uint32(val)) {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 			case wazeroir.UnsignedTypeI64, wazeroir.UnsignedTypeF64:
-				if !memoryInst.WriteUint64Le(offset, val) {
+				if !memoryInst.WriteUint64Le64(offset, val) {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 			}
 			frame.pc++
 		case wazeroir.OperationKindStore8:
+			memoryInst := memories[op.U3]
 			val := byte(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			if !memoryInst.WriteByte(offset, val) {
+			if !memoryInst.WriteByte64(offset, val) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case wazeroir.OperationKindStore16:
+			memoryInst := memories[op.U3]
 			val := uint16(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			if !memoryInst.WriteUint16Le(offset, val) {
+			if !memoryInst.WriteUint16Le64(offset, val) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case wazeroir.OperationKindStore32:
+			memoryInst := memories[op.U3]
 			val := uint32(ce.popValue())
 			offset := ce.popMemoryOffset(op)
-			if !memoryInst.WriteUint32Le(offset, val) {
+			if !memoryInst.WriteUint32Le64(offset, val) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case wazeroir.OperationKindMemorySize:
+			memoryInst := memories[op.U1]
 			ce.pushValue(uint64(memoryInst.PageSize()))
 			frame.pc++
 		case wazeroir.OperationKindMemoryGrow:
+			memoryInst := memories[op.U1]
 			n := ce.popValue()
-			if res, ok := memoryInst.Grow(uint32(n)); !ok {
+			if op.B3 { // 64-bit memory, which can't have more than wasm.Memory64LimitPages pages.
+				if n > math.MaxUint32 {
+					ce.pushValue(math.MaxUint64) // = -1 in signed 64-bit integer.
+				} else if res, ok := memoryInst.Grow(uint32(n)); !ok {
+					ce.pushValue(math.MaxUint64)
+				} else {
+					ce.pushValue(uint64(res))
+				}
+			} else if res, ok := memoryInst.Grow(uint32(n)); !ok {
 				ce.pushValue(uint64(0xffffffff)) // = -1 in signed 32-bit integer.
 			} else {
 				ce.pushValue(uint64(res))
@@ -1613,12 +1961,13 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			ce.pushValue(uint64(v))
 			frame.pc++
 		case wazeroir.OperationKindMemoryInit:
+			memoryInst := memories[op.U2]
 			dataInstance := dataInstances[op.U1]
 			copySize := ce.popValue()
 			inDataOffset := ce.popValue()
 			inMemoryOffset := ce.popValue()
-			if inDataOffset+copySize > uint64(len(dataInstance)) ||
-				inMemoryOffset+copySize > uint64(len(memoryInst.Buffer)) {
+			if rangeOutOfBounds(inDataOffset, copySize, uint64(len(dataInstance))) ||
+				rangeOutOfBounds(inMemoryOffset, copySize, uint64(len(memoryInst.Buffer))) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			} else if copySize != 0 {
 				copy(memoryInst.Buffer[inMemoryOffset:inMemoryOffset+copySize], dataInstance[inDataOffset:])
@@ -1628,22 +1977,24 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			dataInstances[op.U1] = nil
 			frame.pc++
 		case wazeroir.OperationKindMemoryCopy:
-			memLen := uint64(len(memoryInst.Buffer))
+			dstMemoryInst, srcMemoryInst := memories[op.U1], memories[op.U2]
 			copySize := ce.popValue()
 			sourceOffset := ce.popValue()
 			destinationOffset := ce.popValue()
-			if sourceOffset+copySize > memLen || destinationOffset+copySize > memLen {
+			if rangeOutOfBounds(sourceOffset, copySize, uint64(len(srcMemoryInst.Buffer))) ||
+				rangeOutOfBounds(destinationOffset, copySize, uint64(len(dstMemoryInst.Buffer))) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			} else if copySize != 0 {
-				copy(memoryInst.Buffer[destinationOffset:],
-					memoryInst.Buffer[sourceOffset:sourceOffset+copySize])
+				copy(dstMemoryInst.Buffer[destinationOffset:],
+					srcMemoryInst.Buffer[sourceOffset:sourceOffset+copySize])
 			}
 			frame.pc++
 		case wazeroir.OperationKindMemoryFill:
+			memoryInst := memories[op.U1]
 			fillSize := ce.popValue()
 			value := byte(ce.popValue())
 			offset := ce.popValue()
-			if fillSize+offset > uint64(len(memoryInst.Buffer)) {
+			if rangeOutOfBounds(offset, fillSize, uint64(len(memoryInst.Buffer))) {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			} else if fillSize != 0 {
 				// Uses the copy trick for faster filling buffer.
@@ -1827,21 +2178,22 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case wazeroir.OperationKindV128Load:
+			memoryInst := memories[op.U3]
 			offset := ce.popMemoryOffset(op)
 			switch op.B1 {
 			case wazeroir.V128LoadType128:
-				lo, ok := memoryInst.ReadUint64Le(offset)
+				lo, ok := memoryInst.ReadUint64Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(lo)
-				hi, ok := memoryInst.ReadUint64Le(offset + 8)
+				hi, ok := memoryInst.ReadUint64Le64(offset + 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(hi)
 			case wazeroir.V128LoadType8x8s:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memoryInst.Read64(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1852,7 +2204,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					uint64(uint16(int8(data[7])))<<48 | uint64(uint16(int8(data[6])))<<32 | uint64(uint16(int8(data[5])))<<16 | uint64(uint16(int8(data[4]))),
 				)
 			case wazeroir.V128LoadType8x8u:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memoryInst.Read64(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1863,7 +2215,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					uint64(data[7])<<48 | uint64(data[6])<<32 | uint64(data[5])<<16 | uint64(data[4]),
 				)
 			case wazeroir.V128LoadType16x4s:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memoryInst.Read64(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1876,7 +2228,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 						uint64(uint32(int16(binary.LittleEndian.Uint16(data[4:])))),
 				)
 			case wazeroir.V128LoadType16x4u:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memoryInst.Read64(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1887,21 +2239,21 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					uint64(binary.LittleEndian.Uint16(data[6:]))<<32 | uint64(binary.LittleEndian.Uint16(data[4:])),
 				)
 			case wazeroir.V128LoadType32x2s:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memoryInst.Read64(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(uint64(int32(binary.LittleEndian.Uint32(data))))
 				ce.pushValue(uint64(int32(binary.LittleEndian.Uint32(data[4:]))))
 			case wazeroir.V128LoadType32x2u:
-				data, ok := memoryInst.Read(offset, 8)
+				data, ok := memoryInst.Read64(offset, 8)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(uint64(binary.LittleEndian.Uint32(data)))
 				ce.pushValue(uint64(binary.LittleEndian.Uint32(data[4:])))
 			case wazeroir.V128LoadType8Splat:
-				v, ok := memoryInst.ReadByte(offset)
+				v, ok := memoryInst.ReadByte64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1910,7 +2262,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				ce.pushValue(v8)
 				ce.pushValue(v8)
 			case wazeroir.V128LoadType16Splat:
-				v, ok := memoryInst.ReadUint16Le(offset)
+				v, ok := memoryInst.ReadUint16Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1918,7 +2270,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				ce.pushValue(v4)
 				ce.pushValue(v4)
 			case wazeroir.V128LoadType32Splat:
-				v, ok := memoryInst.ReadUint32Le(offset)
+				v, ok := memoryInst.ReadUint32Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1926,21 +2278,21 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 				ce.pushValue(vv)
 				ce.pushValue(vv)
 			case wazeroir.V128LoadType64Splat:
-				lo, ok := memoryInst.ReadUint64Le(offset)
+				lo, ok := memoryInst.ReadUint64Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(lo)
 				ce.pushValue(lo)
 			case wazeroir.V128LoadType32zero:
-				lo, ok := memoryInst.ReadUint32Le(offset)
+				lo, ok := memoryInst.ReadUint32Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
 				ce.pushValue(uint64(lo))
 				ce.pushValue(0)
 			case wazeroir.V128LoadType64zero:
-				lo, ok := memoryInst.ReadUint64Le(offset)
+				lo, ok := memoryInst.ReadUint64Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1949,11 +2301,12 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			}
 			frame.pc++
 		case wazeroir.OperationKindV128LoadLane:
+			memoryInst := memories[op.U3]
 			hi, lo := ce.popValue(), ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			switch op.B1 {
 			case 8:
-				b, ok := memoryInst.ReadByte(offset)
+				b, ok := memoryInst.ReadByte64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1965,7 +2318,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					hi = (hi & ^(0xff << s)) | uint64(b)<<s
 				}
 			case 16:
-				b, ok := memoryInst.ReadUint16Le(offset)
+				b, ok := memoryInst.ReadUint16Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1977,7 +2330,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					hi = (hi & ^(0xff_ff << s)) | uint64(b)<<s
 				}
 			case 32:
-				b, ok := memoryInst.ReadUint32Le(offset)
+				b, ok := memoryInst.ReadUint32Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -1989,7 +2342,7 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 					hi = (hi & ^(0xff_ff_ff_ff << s)) | uint64(b)<<s
 				}
 			case 64:
-				b, ok := memoryInst.ReadUint64Le(offset)
+				b, ok := memoryInst.ReadUint64Le64(offset)
 				if !ok {
 					panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 				}
@@ -2003,43 +2356,45 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			ce.pushValue(hi)
 			frame.pc++
 		case wazeroir.OperationKindV128Store:
+			memoryInst := memories[op.U3]
 			hi, lo := ce.popValue(), ce.popValue()
 			offset := ce.popMemoryOffset(op)
-			if ok := memoryInst.WriteUint64Le(offset, lo); !ok {
+			if ok := memoryInst.WriteUint64Le64(offset, lo); !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
-			if ok := memoryInst.WriteUint64Le(offset+8, hi); !ok {
+			if ok := memoryInst.WriteUint64Le64(offset+8, hi); !ok {
 				panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 			}
 			frame.pc++
 		case wazeroir.OperationKindV128StoreLane:
+			memoryInst := memories[op.U3]
 			hi, lo := ce.popValue(), ce.popValue()
 			offset := ce.popMemoryOffset(op)
 			var ok bool
 			switch op.B1 {
 			case 8:
 				if op.B2 < 8 {
-					ok = memoryInst.WriteByte(offset, byte(lo>>(op.B2*8)))
+					ok = memoryInst.WriteByte64(offset, byte(lo>>(op.B2*8)))
 				} else {
-					ok = memoryInst.WriteByte(offset, byte(hi>>((op.B2-8)*8)))
+					ok = memoryInst.WriteByte64(offset, byte(hi>>((op.B2-8)*8)))
 				}
 			case 16:
 				if op.B2 < 4 {
-					ok = memoryInst.WriteUint16Le(offset, uint16(lo>>(op.B2*16)))
+					ok = memoryInst.WriteUint16Le64(offset, uint16(lo>>(op.B2*16)))
 				} else {
-					ok = memoryInst.WriteUint16Le(offset, uint16(hi>>((op.B2-4)*16)))
+					ok = memoryInst.WriteUint16Le64(offset, uint16(hi>>((op.B2-4)*16)))
 				}
 			case 32:
 				if op.B2 < 2 {
-					ok = memoryInst.WriteUint32Le(offset, uint32(lo>>(op.B2*32)))
+					ok = memoryInst.WriteUint32Le64(offset, uint32(lo>>(op.B2*32)))
 				} else {
-					ok = memoryInst.WriteUint32Le(offset, uint32(hi>>((op.B2-2)*32)))
+					ok = memoryInst.WriteUint32Le64(offset, uint32(hi>>((op.B2-2)*32)))
 				}
 			case 64:
 				if op.B2 == 0 {
-					ok = memoryInst.WriteUint64Le(offset, lo)
+					ok = memoryInst.WriteUint64Le64(offset, lo)
 				} else {
-					ok = memoryInst.WriteUint64Le(offset, hi)
+					ok = memoryInst.WriteUint64Le64(offset, hi)
 				}
 			}
 			if !ok {
@@ -3897,6 +4252,226 @@ func (ce *callEngine) callNativeFunc(ctx context.Context, m *wasm.ModuleInstance
 			ce.pushValue(retLo)
 			ce.pushValue(retHi)
 			frame.pc++
+		case wazeroir.OperationKindAtomicMemoryWait:
+			memoryInst := memories[op.U3]
+			timeout := int64(ce.popValue())
+			exp := ce.popValue()
+			offset := ce.popMemoryOffset(op)
+			// Runtime instead of validation error because the spec intends to allow binaries to include
+			// such instructions as long as they are not executed.
+			if !memoryInst.Shared {
+				panic(wasmruntime.ErrRuntimeExpectedSharedMemory)
+			}
+
+			switch wazeroir.UnsignedType(op.B1) {
+			case wazeroir.UnsignedTypeI32:
+				checkAtomicAccess(memoryInst, offset, 4)
+				ce.pushValue(memoryInst.Wait32(offset, uint32(exp), timeout, func(mem *wasm.MemoryInstance, offset uint64) uint32 {
+					mem.Mux.Lock()
+					defer mem.Mux.Unlock()
+					value, _ := mem.ReadUint32Le64(offset)
+					return value
+				}))
+			case wazeroir.UnsignedTypeI64:
+				checkAtomicAccess(memoryInst, offset, 8)
+				ce.pushValue(memoryInst.Wait64(offset, exp, timeout, func(mem *wasm.MemoryInstance, offset uint64) uint64 {
+					mem.Mux.Lock()
+					defer mem.Mux.Unlock()
+					value, _ := mem.ReadUint64Le64(offset)
+					return value
+				}))
+			}
+			frame.pc++
+		case wazeroir.OperationKindAtomicMemoryNotify:
+			memoryInst := memories[op.U3]
+			count := ce.popValue()
+			offset := ce.popMemoryOffset(op)
+			checkAtomicAccess(memoryInst, offset, 4)
+			// Just a no-op for unshared memory.
+			if memoryInst.Shared {
+				ce.pushValue(uint64(memoryInst.Notify(offset, uint32(count))))
+			} else {
+				ce.pushValue(0)
+			}
+			frame.pc++
+		case wazeroir.OperationKindAtomicFence:
+			// Memory not required for fence only
+			if len(memories) > 0 {
+				memoryInst := memories[0]
+				// An empty critical section can be used as a synchronization primitive, which is what
+				// fence is. Probably, there are no spectests or defined behavior to confirm this yet.
+				memoryInst.Mux.Lock()
+				memoryInst.Mux.Unlock() //nolint:staticcheck
+			}
+			frame.pc++
+		case wazeroir.OperationKindAtomicLoad:
+			memoryInst := memories[op.U3]
+			offset := ce.popMemoryOffset(op)
+			switch wazeroir.UnsignedType(op.B1) {
+			case wazeroir.UnsignedTypeI32:
+				checkAtomicAccess(memoryInst, offset, 4)
+				memoryInst.Mux.Lock()
+				val, _ := memoryInst.ReadUint32Le64(offset)
+				memoryInst.Mux.Unlock()
+				ce.pushValue(uint64(val))
+			case wazeroir.UnsignedTypeI64:
+				checkAtomicAccess(memoryInst, offset, 8)
+				memoryInst.Mux.Lock()
+				val, _ := memoryInst.ReadUint64Le64(offset)
+				memoryInst.Mux.Unlock()
+				ce.pushValue(val)
+			}
+			frame.pc++
+		case wazeroir.OperationKindAtomicLoad8:
+			memoryInst := memories[op.U3]
+			offset := ce.popMemoryOffset(op)
+			checkAtomicAccess(memoryInst, offset, 1)
+			memoryInst.Mux.Lock()
+			val, _ := memoryInst.ReadByte64(offset)
+			memoryInst.Mux.Unlock()
+			ce.pushValue(uint64(val))
+			frame.pc++
+		case wazeroir.OperationKindAtomicLoad16:
+			memoryInst := memories[op.U3]
+			offset := ce.popMemoryOffset(op)
+			checkAtomicAccess(memoryInst, offset, 2)
+			memoryInst.Mux.Lock()
+			val, _ := memoryInst.ReadUint16Le64(offset)
+			memoryInst.Mux.Unlock()
+			ce.pushValue(uint64(val))
+			frame.pc++
+		case wazeroir.OperationKindAtomicStore:
+			memoryInst := memories[op.U3]
+			val := ce.popValue()
+			offset := ce.popMemoryOffset(op)
+			switch wazeroir.UnsignedType(op.B1) {
+			case wazeroir.UnsignedTypeI32:
+				checkAtomicAccess(memoryInst, offset, 4)
+				memoryInst.Mux.Lock()
+				memoryInst.WriteUint32Le64(offset, uint32(val))
+				memoryInst.Mux.Unlock()
+			case wazeroir.UnsignedTypeI64:
+				checkAtomicAccess(memoryInst, offset, 8)
+				memoryInst.Mux.Lock()
+				memoryInst.WriteUint64Le64(offset, val)
+				memoryInst.Mux.Unlock()
+			}
+			frame.pc++
+		case wazeroir.OperationKindAtomicStore8:
+			memoryInst := memories[op.U3]
+			val := byte(ce.popValue())
+			offset := ce.popMemoryOffset(op)
+			checkAtomicAccess(memoryInst, offset, 1)
+			memoryInst.Mux.Lock()
+			memoryInst.WriteByte64(offset, val)
+			memoryInst.Mux.Unlock()
+			frame.pc++
+		case wazeroir.OperationKindAtomicStore16:
+			memoryInst := memories[op.U3]
+			val := uint16(ce.popValue())
+			offset := ce.popMemoryOffset(op)
+			checkAtomicAccess(memoryInst, offset, 2)
+			memoryInst.Mux.Lock()
+			memoryInst.WriteUint16Le64(offset, val)
+			memoryInst.Mux.Unlock()
+			frame.pc++
+		case wazeroir.OperationKindAtomicRMW:
+			memoryInst := memories[op.U3]
+			val := ce.popValue()
+			offset := ce.popMemoryOffset(op)
+			switch wazeroir.UnsignedType(op.B1) {
+			case wazeroir.UnsignedTypeI32:
+				checkAtomicAccess(memoryInst, offset, 4)
+				memoryInst.Mux.Lock()
+				old, _ := memoryInst.ReadUint32Le64(offset)
+				memoryInst.WriteUint32Le64(offset, uint32(atomicArithmetic(wazeroir.AtomicArithmeticOp(op.B2), uint64(old), val)))
+				memoryInst.Mux.Unlock()
+				ce.pushValue(uint64(old))
+			case wazeroir.UnsignedTypeI64:
+				checkAtomicAccess(memoryInst, offset, 8)
+				memoryInst.Mux.Lock()
+				old, _ := memoryInst.ReadUint64Le64(offset)
+				memoryInst.WriteUint64Le64(offset, atomicArithmetic(wazeroir.AtomicArithmeticOp(op.B2), old, val))
+				memoryInst.Mux.Unlock()
+				ce.pushValue(old)
+			}
+			frame.pc++
+		case wazeroir.OperationKindAtomicRMW8:
+			memoryInst := memories[op.U3]
+			val := ce.popValue()
+			offset := ce.popMemoryOffset(op)
+			checkAtomicAccess(memoryInst, offset, 1)
+			memoryInst.Mux.Lock()
+			old, _ := memoryInst.ReadByte64(offset)
+			memoryInst.WriteByte64(offset, byte(atomicArithmetic(wazeroir.AtomicArithmeticOp(op.B2), uint64(old), val)))
+			memoryInst.Mux.Unlock()
+			ce.pushValue(uint64(old))
+			frame.pc++
+		case wazeroir.OperationKindAtomicRMW16:
+			memoryInst := memories[op.U3]
+			val := ce.popValue()
+			offset := ce.popMemoryOffset(op)
+			checkAtomicAccess(memoryInst, offset, 2)
+			memoryInst.Mux.Lock()
+			old, _ := memoryInst.ReadUint16Le64(offset)
+			memoryInst.WriteUint16Le64(offset, uint16(atomicArithmetic(wazeroir.AtomicArithmeticOp(op.B2), uint64(old), val)))
+			memoryInst.Mux.Unlock()
+			ce.pushValue(uint64(old))
+			frame.pc++
+		case wazeroir.OperationKindAtomicRMWCmpxchg:
+			memoryInst := memories[op.U3]
+			rep := ce.popValue()
+			exp := ce.popValue()
+			offset := ce.popMemoryOffset(op)
+			switch wazeroir.UnsignedType(op.B1) {
+			case wazeroir.UnsignedTypeI32:
+				checkAtomicAccess(memoryInst, offset, 4)
+				memoryInst.Mux.Lock()
+				old, _ := memoryInst.ReadUint32Le64(offset)
+				if old == uint32(exp) {
+					memoryInst.WriteUint32Le64(offset, uint32(rep))
+				}
+				memoryInst.Mux.Unlock()
+				ce.pushValue(uint64(old))
+			case wazeroir.UnsignedTypeI64:
+				checkAtomicAccess(memoryInst, offset, 8)
+				memoryInst.Mux.Lock()
+				old, _ := memoryInst.ReadUint64Le64(offset)
+				if old == exp {
+					memoryInst.WriteUint64Le64(offset, rep)
+				}
+				memoryInst.Mux.Unlock()
+				ce.pushValue(old)
+			}
+			frame.pc++
+		case wazeroir.OperationKindAtomicRMW8Cmpxchg:
+			memoryInst := memories[op.U3]
+			rep := byte(ce.popValue())
+			exp := byte(ce.popValue())
+			offset := ce.popMemoryOffset(op)
+			checkAtomicAccess(memoryInst, offset, 1)
+			memoryInst.Mux.Lock()
+			old, _ := memoryInst.ReadByte64(offset)
+			if old == exp {
+				memoryInst.WriteByte64(offset, rep)
+			}
+			memoryInst.Mux.Unlock()
+			ce.pushValue(uint64(old))
+			frame.pc++
+		case wazeroir.OperationKindAtomicRMW16Cmpxchg:
+			memoryInst := memories[op.U3]
+			rep := uint16(ce.popValue())
+			exp := uint16(ce.popValue())
+			offset := ce.popMemoryOffset(op)
+			checkAtomicAccess(memoryInst, offset, 2)
+			memoryInst.Mux.Lock()
+			old, _ := memoryInst.ReadUint16Le64(offset)
+			if old == exp {
+				memoryInst.WriteUint16Le64(offset, rep)
+			}
+			memoryInst.Mux.Unlock()
+			ce.pushValue(uint64(old))
+			frame.pc++
 		default:
 			frame.pc++
 		}
@@ -4106,19 +4681,72 @@ func (ce *callEngine) callNativeFuncWithListener(ctx context.Context, m *wasm.Mo
 	ce.stackIterator.reset(ce.stack, ce.frames, f)
 	fnl.Before(ctx, m, def, ce.peekValues(typ.ParamNumInUint64), &ce.stackIterator)
 	ce.stackIterator.clear()
+	if bl := f.parent.blockListener; bl != nil && len(f.parent.body) > 0 {
+		bl.Block(ctx, m, def, f.parent.offsetsInWasmBinary[0], f.parent.blockEnds[0])
+	}
 	ce.callNativeFunc(ctx, m, f)
 	fnl.After(ctx, m, def, ce.peekValues(typ.ResultNumInUint64))
 	return ctx
 }
 
+// notifyInstruction notifies the instruction listener of the function of the frame, which is the current one, of the
+// instruction at its program counter.
+func (ce *callEngine) notifyInstruction(ctx context.Context, m *wasm.ModuleInstance, frame *callFrame) {
+	f := frame.f
+	start, end := frame.base-f.funcType.ParamNumInUint64, frame.base+f.parent.localsNumInUint64
+	ce.stackIterator.reset(ce.stack, ce.frames[:len(ce.frames)-1], f)
+	ce.stackIterator.pc = frame.pc
+	f.parent.instructionListener.Instruction(ctx, m, f.definition(), f.parent.offsetsInWasmBinary[frame.pc],
+		ce.stack[start:end:end], &ce.stackIterator)
+	ce.stackIterator.clear()
+}
+
 // popMemoryOffset takes a memory offset off the stack for use in load and store instructions.
 // As the top of stack value is 64-bit, this ensures it is in range before returning it.
-func (ce *callEngine) popMemoryOffset(op *wazeroir.UnionOperation) uint32 {
-	offset := op.U2 + ce.popValue()
-	if offset > math.MaxUint32 {
+func (ce *callEngine) popMemoryOffset(op *wazeroir.UnionOperation) uint64 {
+	base := ce.popValue()
+	offset := op.U2 + base
+	// The effective address is 33-bit for 32-bit memories, so this can only overflow for 64-bit memories.
+	if offset < base {
 		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
 	}
-	return uint32(offset)
+	return offset
+}
+
+// rangeOutOfBounds returns true unless [offset, offset+size) is within [0, length). This doesn't overflow even when the
+// operands are 64-bit, as they are for 64-bit memories (api.CoreFeatureMemory64).
+func rangeOutOfBounds(offset, size, length uint64) bool {
+	return size > length || offset > length-size
+}
+
+// checkAtomicAccess panics unless the access of the given size at the offset is within the memory and aligned to
+// the size, as required for atomic instructions.
+func checkAtomicAccess(mem *wasm.MemoryInstance, offset uint64, size uint64) {
+	if size > uint64(len(mem.Buffer)) || offset > uint64(len(mem.Buffer))-size {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	if offset%size != 0 {
+		panic(wasmruntime.ErrRuntimeUnalignedAtomic)
+	}
+}
+
+// atomicArithmetic returns the result of applying op to the old value and the operand of a read-modify-write
+// instruction. The caller truncates the result to the width of the access.
+func atomicArithmetic(op wazeroir.AtomicArithmeticOp, old, val uint64) uint64 {
+	switch op {
+	case wazeroir.AtomicArithmeticOpAdd:
+		return old + val
+	case wazeroir.AtomicArithmeticOpSub:
+		return old - val
+	case wazeroir.AtomicArithmeticOpAnd:
+		return old & val
+	case wazeroir.AtomicArithmeticOpOr:
+		return old | val
+	case wazeroir.AtomicArithmeticOpXor:
+		return old ^ val
+	default: // wazeroir.AtomicArithmeticOpNop
+		return val
+	}
 }
 
 func (ce *callEngine) callGoFuncWithStack(ctx context.Context, m *wasm.ModuleInstance, f *function) {
@@ -29,7 +29,7 @@ func TestInterpreter_CallEngine_PushFrame(t *testing.T) {
 	f1 := &callFrame{}
 	f2 := &callFrame{}
 
-	ce := callEngine{}
+	ce := callEngine{callStackCeiling: callStackCeiling}
 	require.Zero(t, len(ce.frames), "expected no frames")
 
 	ce.pushFrame(f1)
@@ -40,17 +40,12 @@ func TestInterpreter_CallEngine_PushFrame(t *testing.T) {
 }
 
 func TestInterpreter_CallEngine_PushFrame_StackOverflow(t *testing.T) {
-	saved := callStackCeiling
-	defer func() { callStackCeiling = saved }()
-
-	callStackCeiling = 3
-
 	f1 := &callFrame{}
 	f2 := &callFrame{}
 	f3 := &callFrame{}
 	f4 := &callFrame{}
 
-	vm := callEngine{}
+	vm := callEngine{callStackCeiling: 3}
 	vm.pushFrame(f1)
 	vm.pushFrame(f2)
 	vm.pushFrame(f3)
@@ -241,7 +236,7 @@ func TestInterpreter_NonTrappingFloatToIntConversion(t *testing.T) {
 						wazeroir.UnionOperation{Kind: wazeroir.OperationKindBr, U1: uint64(math.MaxUint64)},
 					)
 
-					ce := &callEngine{}
+					ce := &callEngine{callStackCeiling: callStackCeiling}
 					f := &function{
 						moduleInstance: &wasm.ModuleInstance{Engine: &moduleEngine{}},
 						parent:         &compiledFunction{body: body},
@@ -304,7 +299,7 @@ func TestInterpreter_CallEngine_callNativeFunc_signExtend(t *testing.T) {
 		for _, tt := range tests {
 			tc := tt
 			t.Run(fmt.Sprintf("%s(i32.const(0x%x))", wasm.InstructionName(tc.opcode), tc.in), func(t *testing.T) {
-				ce := &callEngine{}
+				ce := &callEngine{callStackCeiling: callStackCeiling}
 				f := &function{
 					moduleInstance: &wasm.ModuleInstance{Engine: &moduleEngine{}},
 					parent: &compiledFunction{body: []wazeroir.UnionOperation{
@@ -358,7 +353,7 @@ func TestInterpreter_CallEngine_callNativeFunc_signExtend(t *testing.T) {
 		for _, tt := range tests {
 			tc := tt
 			t.Run(fmt.Sprintf("%s(i64.const(0x%x))", wasm.InstructionName(tc.opcode), tc.in), func(t *testing.T) {
-				ce := &callEngine{}
+				ce := &callEngine{callStackCeiling: callStackCeiling}
 				f := &function{
 					moduleInstance: &wasm.ModuleInstance{Engine: &moduleEngine{}},
 					parent: &compiledFunction{body: []wazeroir.UnionOperation{
@@ -430,6 +425,35 @@ func TestInterpreter_Compile(t *testing.T) {
 	})
 }
 
+func TestInsertInstructionNotifications(t *testing.T) {
+	notify := wazeroir.NewOperationBuiltinFunctionNotifyInstruction()
+	label := wazeroir.NewOperationLabel(wazeroir.NewLabel(wazeroir.LabelKindHeader, 1))
+	ir := &wazeroir.CompilationResult{
+		// The first operation initializes the function, then each instruction is compiled to one or two operations.
+		Operations: []wazeroir.UnionOperation{
+			wazeroir.NewOperationConstI32(0), label, wazeroir.NewOperationConstI32(1), wazeroir.NewOperationDrop(wazeroir.NopInclusiveRange),
+			wazeroir.NewOperationBr(wazeroir.NewLabel(wazeroir.LabelKindHeader, 1)),
+		},
+		IROperationSourceOffsetsInWasmBinary: []uint64{0, 3, 5, 5, 7},
+		BodyStart:                            1,
+		ExceptionHandlers:                    []wazeroir.ExceptionHandler{{Begin: 2, End: 5}},
+	}
+	insertInstructionNotifications(ir)
+	require.Equal(t, []wazeroir.UnionOperation{
+		wazeroir.NewOperationConstI32(0), notify, label, notify, wazeroir.NewOperationConstI32(1),
+		wazeroir.NewOperationDrop(wazeroir.NopInclusiveRange), notify, wazeroir.NewOperationBr(wazeroir.NewLabel(wazeroir.LabelKindHeader, 1)),
+	}, ir.Operations)
+	require.Equal(t, []uint64{0, 3, 3, 5, 5, 5, 7, 7}, ir.IROperationSourceOffsetsInWasmBinary)
+	require.Equal(t, 1, ir.BodyStart)
+	require.Equal(t, []wazeroir.ExceptionHandler{{Begin: 4, End: 8}}, ir.ExceptionHandlers)
+
+	// Branches to the label notify the instruction which begins with it.
+	e := NewEngine(testCtx, api.CoreFeaturesV1, nil).(*engine)
+	var compiled compiledFunction
+	require.NoError(t, e.lowerIR(ir, &compiled))
+	require.Equal(t, uint64(1), compiled.body[7].U1)
+}
+
 func TestEngine_CachedCompiledFunctionPerModule(t *testing.T) {
 	e := NewEngine(testCtx, api.CoreFeaturesV1, nil).(*engine)
 	exp := []compiledFunction{
@@ -0,0 +1,161 @@
+package wazevo
+
+import (
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasmruntime"
+)
+
+// executeAtomic executes the atomic instruction of the threads proposal on behalf of the compiled code, and returns
+// the result if any. Operands absent for the instruction are ignored.
+//
+// The operations are serialized by wasm.MemoryInstance Mux, which matches the other engines.
+func executeAtomic(mem *wasm.MemoryInstance, op wasm.OpcodeAtomic, offset, addr uint32, v1, v2 uint64) uint64 {
+	if op == wasm.OpcodeAtomicFence {
+		// Memory not required for fence only.
+		if mem != nil {
+			mem.Mux.Lock()
+			mem.Mux.Unlock() //nolint:staticcheck
+		}
+		return 0
+	}
+
+	ea := uint64(addr) + uint64(offset)
+	size := atomicAccessSize(op)
+	if ea+uint64(size) > uint64(len(mem.Buffer)) {
+		panic(wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+	if ea%uint64(size) != 0 {
+		panic(wasmruntime.ErrRuntimeUnalignedAtomic)
+	}
+	ptr := uint32(ea)
+
+	switch op {
+	case wasm.OpcodeAtomicMemoryNotify:
+		// Just a no-op for unshared memory.
+		if !mem.Shared {
+			return 0
+		}
+		return uint64(mem.Notify(uint64(ptr), uint32(v1)))
+	case wasm.OpcodeAtomicMemoryWait32:
+		// Runtime instead of validation error because the spec intends to allow binaries to include
+		// such instructions as long as they are not executed.
+		if !mem.Shared {
+			panic(wasmruntime.ErrRuntimeExpectedSharedMemory)
+		}
+		return mem.Wait32(uint64(ptr), uint32(v1), int64(v2), func(mem *wasm.MemoryInstance, offset uint64) uint32 {
+			mem.Mux.Lock()
+			defer mem.Mux.Unlock()
+			value, _ := mem.ReadUint32Le64(offset)
+			return value
+		})
+	case wasm.OpcodeAtomicMemoryWait64:
+		if !mem.Shared {
+			panic(wasmruntime.ErrRuntimeExpectedSharedMemory)
+		}
+		return mem.Wait64(uint64(ptr), v1, int64(v2), func(mem *wasm.MemoryInstance, offset uint64) uint64 {
+			mem.Mux.Lock()
+			defer mem.Mux.Unlock()
+			value, _ := mem.ReadUint64Le64(offset)
+			return value
+		})
+	}
+
+	mem.Mux.Lock()
+	defer mem.Mux.Unlock()
+
+	old := readAtomic(mem, ptr, size)
+	switch op {
+	case wasm.OpcodeAtomicI32Load, wasm.OpcodeAtomicI64Load, wasm.OpcodeAtomicI32Load8U, wasm.OpcodeAtomicI32Load16U,
+		wasm.OpcodeAtomicI64Load8U, wasm.OpcodeAtomicI64Load16U, wasm.OpcodeAtomicI64Load32U:
+		return old
+	case wasm.OpcodeAtomicI32Store, wasm.OpcodeAtomicI64Store, wasm.OpcodeAtomicI32Store8, wasm.OpcodeAtomicI32Store16,
+		wasm.OpcodeAtomicI64Store8, wasm.OpcodeAtomicI64Store16, wasm.OpcodeAtomicI64Store32:
+		writeAtomic(mem, ptr, size, v1)
+		return 0
+	case wasm.OpcodeAtomicI32RmwCmpxchg, wasm.OpcodeAtomicI64RmwCmpxchg, wasm.OpcodeAtomicI32Rmw8CmpxchgU,
+		wasm.OpcodeAtomicI32Rmw16CmpxchgU, wasm.OpcodeAtomicI64Rmw8CmpxchgU, wasm.OpcodeAtomicI64Rmw16CmpxchgU,
+		wasm.OpcodeAtomicI64Rmw32CmpxchgU:
+		if size < 8 {
+			v1 &= 1<<(size*8) - 1
+		}
+		if old == v1 {
+			writeAtomic(mem, ptr, size, v2)
+		}
+		return old
+	}
+
+	// The rest are read-modify-write instructions, which are laid out in groups of seven per arithmetic operation.
+	var val uint64
+	switch (op - wasm.OpcodeAtomicI32RmwAdd) / 7 {
+	case 0: // add
+		val = old + v1
+	case 1: // sub
+		val = old - v1
+	case 2: // and
+		val = old & v1
+	case 3: // or
+		val = old | v1
+	case 4: // xor
+		val = old ^ v1
+	default: // xchg
+		val = v1
+	}
+	writeAtomic(mem, ptr, size, val)
+	return old
+}
+
+// atomicAccessSize returns the size in bytes of the memory accessed by the atomic instruction.
+func atomicAccessSize(op wasm.OpcodeAtomic) uint32 {
+	switch op {
+	case wasm.OpcodeAtomicMemoryNotify, wasm.OpcodeAtomicMemoryWait32:
+		return 4
+	case wasm.OpcodeAtomicMemoryWait64:
+		return 8
+	case wasm.OpcodeAtomicI32Load, wasm.OpcodeAtomicI32Store, wasm.OpcodeAtomicI64Load32U, wasm.OpcodeAtomicI64Store32,
+		wasm.OpcodeAtomicI32RmwCmpxchg, wasm.OpcodeAtomicI64Rmw32CmpxchgU:
+		return 4
+	case wasm.OpcodeAtomicI64Load, wasm.OpcodeAtomicI64Store, wasm.OpcodeAtomicI64RmwCmpxchg:
+		return 8
+	case wasm.OpcodeAtomicI32Load8U, wasm.OpcodeAtomicI64Load8U, wasm.OpcodeAtomicI32Store8, wasm.OpcodeAtomicI64Store8,
+		wasm.OpcodeAtomicI32Rmw8CmpxchgU, wasm.OpcodeAtomicI64Rmw8CmpxchgU:
+		return 1
+	case wasm.OpcodeAtomicI32Load16U, wasm.OpcodeAtomicI64Load16U, wasm.OpcodeAtomicI32Store16, wasm.OpcodeAtomicI64Store16,
+		wasm.OpcodeAtomicI32Rmw16CmpxchgU, wasm.OpcodeAtomicI64Rmw16CmpxchgU:
+		return 2
+	}
+	// Read-modify-write instructions are laid out as i32, i64, i32 8-bit, i32 16-bit, i64 8-bit, i64 16-bit and
+	// i64 32-bit variants for each arithmetic operation.
+	return [7]uint32{4, 8, 1, 2, 1, 2, 4}[(op-wasm.OpcodeAtomicI32RmwAdd)%7]
+}
+
+// readAtomic reads the zero-extended value of the size at the offset. The caller must hold mem.Mux.
+func readAtomic(mem *wasm.MemoryInstance, offset, size uint32) uint64 {
+	switch size {
+	case 1:
+		v, _ := mem.ReadByte(offset)
+		return uint64(v)
+	case 2:
+		v, _ := mem.ReadUint16Le(offset)
+		return uint64(v)
+	case 4:
+		v, _ := mem.ReadUint32Le(offset)
+		return uint64(v)
+	default:
+		v, _ := mem.ReadUint64Le(offset)
+		return v
+	}
+}
+
+// writeAtomic writes the value truncated to the size at the offset. The caller must hold mem.Mux.
+func writeAtomic(mem *wasm.MemoryInstance, offset, size uint32, v uint64) {
+	switch size {
+	case 1:
+		mem.WriteByte(offset, byte(v))
+	case 2:
+		mem.WriteUint16Le(offset, uint16(v))
+	case 4:
+		mem.WriteUint32Le(offset, uint32(v))
+	default:
+		mem.WriteUint64Le(offset, v)
+	}
+}
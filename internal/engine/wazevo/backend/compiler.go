@@ -97,6 +97,9 @@ type Compiler interface {
 
 	// Emit4Bytes appends 4 bytes to the buffer. Used during the code emission.
 	Emit4Bytes(b uint32)
+
+	// EmitByte appends a byte to the buffer. Used during the code emission.
+	EmitByte(b byte)
 }
 
 // RelocationInfo represents the relocation information for a call instruction.
@@ -380,6 +383,11 @@ func (c *compiler) Emit4Bytes(b uint32) {
 	c.buf = append(c.buf, byte(b), byte(b>>8), byte(b>>16), byte(b>>24))
 }
 
+// EmitByte implements Compiler.EmitByte.
+func (c *compiler) EmitByte(b byte) {
+	c.buf = append(c.buf, b)
+}
+
 // Buf implements Compiler.Buf.
 func (c *compiler) Buf() []byte {
 	return c.buf
@@ -0,0 +1,354 @@
+package amd64
+
+import (
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend"
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+)
+
+// References:
+// * https://github.com/golang/go/blob/49d42128fd8594c172162961ead19ac95e247d24/src/cmd/compile/abi-internal.md#amd64-architecture
+// * https://gitlab.com/x86-psABIs/x86-64-ABI
+
+var (
+	// intArgResultRegs holds the general purpose registers used for passing arguments and return values.
+	// The first three registers are the same as Go's ABIInternal, so that we can call Go's
+	// assembly functions such as runtime.memmove directly from the generated code.
+	intArgResultRegs = []regalloc.RealReg{rax, rbx, rcx, rdi, rsi, r8, r9, r10}
+	// floatArgResultRegs holds the vector registers used for passing arguments and return values.
+	floatArgResultRegs = []regalloc.RealReg{xmm0, xmm1, xmm2, xmm3, xmm4, xmm5, xmm6, xmm7}
+)
+
+var regInfo = &regalloc.RegisterInfo{
+	AllocatableRegisters: [regalloc.NumRegType][]regalloc.RealReg{
+		// We don't allocate:
+		// - rsp, rbp: the stack pointer and the frame pointer.
+		// - r11(=tmpIntReg): because of the reason described on tmpIntReg.
+		regalloc.RegTypeInt: {
+			rdx, r12, r13, r14, r15,
+			// These are the argument/return registers. Less preferred in the allocation.
+			r10, r9, r8, rsi, rdi, rcx, rbx, rax,
+		},
+		// We don't allocate:
+		// - xmm15(=tmpFloatRegVReg): because of the reason described on tmpFloatRegVReg.
+		regalloc.RegTypeFloat: {
+			xmm8, xmm9, xmm10, xmm11, xmm12, xmm13, xmm14,
+			// These are the argument/return registers. Less preferred in the allocation.
+			xmm7, xmm6, xmm5, xmm4, xmm3, xmm2, xmm1, xmm0,
+		},
+	},
+	CalleeSavedRegisters: [regalloc.RealRegsNumMax]bool{
+		r12: true, r13: true, r14: true, r15: true,
+	},
+	CallerSavedRegisters: [regalloc.RealRegsNumMax]bool{
+		rax: true, rcx: true, rdx: true, rbx: true, rsi: true, rdi: true, r8: true, r9: true, r10: true,
+		xmm0: true, xmm1: true, xmm2: true, xmm3: true, xmm4: true, xmm5: true, xmm6: true, xmm7: true,
+		xmm8: true, xmm9: true, xmm10: true, xmm11: true, xmm12: true, xmm13: true, xmm14: true,
+	},
+	RealRegToVReg: []regalloc.VReg{
+		rax: raxVReg, rcx: rcxVReg, rdx: rdxVReg, rbx: rbxVReg, rsp: rspVReg, rbp: rbpVReg, rsi: rsiVReg, rdi: rdiVReg,
+		r8: r8VReg, r9: r9VReg, r10: r10VReg, r11: r11VReg, r12: r12VReg, r13: r13VReg, r14: r14VReg, r15: r15VReg,
+		xmm0: xmm0VReg, xmm1: xmm1VReg, xmm2: xmm2VReg, xmm3: xmm3VReg, xmm4: xmm4VReg, xmm5: xmm5VReg, xmm6: xmm6VReg,
+		xmm7: xmm7VReg, xmm8: xmm8VReg, xmm9: xmm9VReg, xmm10: xmm10VReg, xmm11: xmm11VReg, xmm12: xmm12VReg,
+		xmm13: xmm13VReg, xmm14: xmm14VReg, xmm15: xmm15VReg,
+	},
+	RealRegName: func(r regalloc.RealReg) string { return regNames[r] },
+	RealRegType: func(r regalloc.RealReg) regalloc.RegType {
+		if r < xmm0 {
+			return regalloc.RegTypeInt
+		}
+		return regalloc.RegTypeFloat
+	},
+}
+
+// abiImpl implements backend.FunctionABI.
+type abiImpl struct {
+	m                          *machine
+	args, rets                 []backend.ABIArg
+	argStackSize, retStackSize int64
+
+	argRealRegs []regalloc.VReg
+	retRealRegs []regalloc.VReg
+}
+
+func (m *machine) getOrCreateABIImpl(sig *ssa.Signature) *abiImpl {
+	if int(sig.ID) >= len(m.abis) {
+		m.abis = append(m.abis, make([]abiImpl, int(sig.ID)+1)...)
+	}
+
+	abi := &m.abis[sig.ID]
+	if abi.m != nil {
+		return abi
+	}
+
+	abi.m = m
+	abi.init(sig)
+	return abi
+}
+
+// init initializes the abiImpl for the given signature.
+func (a *abiImpl) init(sig *ssa.Signature) {
+	if len(a.rets) < len(sig.Results) {
+		a.rets = make([]backend.ABIArg, len(sig.Results))
+	}
+	a.rets = a.rets[:len(sig.Results)]
+	a.retStackSize = a.setABIArgs(a.rets, sig.Results)
+	if argsNum := len(sig.Params); len(a.args) < argsNum {
+		a.args = make([]backend.ABIArg, argsNum)
+	}
+	a.args = a.args[:len(sig.Params)]
+	a.argStackSize = a.setABIArgs(a.args, sig.Params)
+
+	// Gather the real registers usages in arg/return.
+	a.retRealRegs = a.retRealRegs[:0]
+	for i := range a.rets {
+		r := &a.rets[i]
+		if r.Kind == backend.ABIArgKindReg {
+			a.retRealRegs = append(a.retRealRegs, r.Reg)
+		}
+	}
+	a.argRealRegs = a.argRealRegs[:0]
+	for i := range a.args {
+		arg := &a.args[i]
+		if arg.Kind == backend.ABIArgKindReg {
+			a.argRealRegs = append(a.argRealRegs, arg.Reg)
+		}
+	}
+}
+
+// setABIArgs sets the ABI arguments in the given slice. This assumes that len(s) >= len(types)
+// where if len(s) > len(types), the last elements of s is for the multi-return slot.
+func (a *abiImpl) setABIArgs(s []backend.ABIArg, types []ssa.Type) (stackSize int64) {
+	var stackOffset int64
+	var nextInt, nextFloat int
+	for i, typ := range types {
+		arg := &s[i]
+		arg.Index = i
+		arg.Type = typ
+		if typ.IsInt() {
+			if nextInt >= len(intArgResultRegs) {
+				arg.Kind = backend.ABIArgKindStack
+				const slotSize = 8 // Align 8 bytes.
+				arg.Offset = stackOffset
+				stackOffset += slotSize
+			} else {
+				arg.Kind = backend.ABIArgKindReg
+				arg.Reg = regalloc.FromRealReg(intArgResultRegs[nextInt], regalloc.RegTypeInt)
+				nextInt++
+			}
+		} else {
+			if nextFloat >= len(floatArgResultRegs) {
+				arg.Kind = backend.ABIArgKindStack
+				slotSize := int64(8)   // Align at least 8 bytes.
+				if typ.Bits() == 128 { // Vector.
+					slotSize = 16
+				}
+				arg.Offset = stackOffset
+				stackOffset += slotSize
+			} else {
+				arg.Kind = backend.ABIArgKindReg
+				arg.Reg = regalloc.FromRealReg(floatArgResultRegs[nextFloat], regalloc.RegTypeFloat)
+				nextFloat++
+			}
+		}
+	}
+	return stackOffset
+}
+
+// CalleeGenFunctionArgsToVRegs implements backend.FunctionABI.
+func (a *abiImpl) CalleeGenFunctionArgsToVRegs(args []ssa.Value) {
+	for i, ssaArg := range args {
+		if !ssaArg.Valid() {
+			continue
+		}
+		reg := a.m.compiler.VRegOf(ssaArg)
+		arg := &a.args[i]
+		if arg.Kind == backend.ABIArgKindReg {
+			a.m.InsertMove(reg, arg.Reg, arg.Type)
+		} else {
+			//            (high address)
+			//          +-----------------+
+			//          |     .......     |
+			//          |      ret Y      |
+			//          |     .......     |
+			//          |      ret 0      |
+			//          |      arg X      |
+			//          |     .......     |
+			//          |      arg 1      |
+			//          |      arg 0      |    <-|
+			//          |  ReturnAddress  |      |
+			//          | size_of_arg_ret |      |
+			//          |   clobbered N   |      |
+			//          |   ...........   |      |
+			//          |   spill slot 0  |      |   argStackOffset: is unknown at this point of compilation.
+			//          |     xxxxxx      |      |
+			//          |   frame_size    |      |
+			//   SP---> +-----------------+    <-+
+			//             (low address)
+			//
+			// At this point of compilation, we don't yet know how much space exist below the return address.
+			// So we instruct the address mode to add the `argStackOffset` to the offset at the later phase of compilation.
+			m := a.m
+			mem := m.newAmodeImmReg(uint32(arg.Offset), rspVReg)
+			mem.kind = amodeKindArgStackSpace
+			m.insert(m.allocateLoad(arg.Type, mem, reg))
+			m.unresolvedAddressModes = append(m.unresolvedAddressModes, mem)
+		}
+	}
+}
+
+// CalleeGenVRegsToFunctionReturns implements backend.FunctionABI.
+func (a *abiImpl) CalleeGenVRegsToFunctionReturns(rets []ssa.Value) {
+	l := len(rets) - 1
+	for i := range rets {
+		// Reverse order in order to avoid overwriting the stack returns existing in the return registers.
+		ret := rets[l-i]
+		r := &a.rets[l-i]
+		reg := a.m.compiler.VRegOf(ret)
+		if def := a.m.compiler.ValueDefinition(ret); def.IsFromInstr() {
+			// Constant instructions are inlined.
+			if inst := def.Instr; inst.Constant() {
+				a.m.InsertLoadConstant(inst, reg)
+			}
+		}
+		if r.Kind == backend.ABIArgKindReg {
+			a.m.InsertMove(r.Reg, reg, ret.Type())
+		} else {
+			// At this point of compilation, we don't yet know how much space exist below the return address.
+			// So we instruct the address mode to add the `retStackOffset` to the offset at the later phase of compilation.
+			m := a.m
+			mem := m.newAmodeImmReg(uint32(r.Offset), rspVReg)
+			mem.kind = amodeKindResultStackSpace
+			m.insert(m.allocateStore(r.Type, reg, mem))
+			m.unresolvedAddressModes = append(m.unresolvedAddressModes, mem)
+		}
+	}
+}
+
+// callerGenVRegToFunctionArg is the opposite of GenFunctionArgToVReg, which is used to generate the
+// caller side of the function call.
+func (a *abiImpl) callerGenVRegToFunctionArg(argIndex int, reg regalloc.VReg, def *backend.SSAValueDefinition, slotBegin int64) {
+	arg := &a.args[argIndex]
+	if def != nil && def.IsFromInstr() {
+		// Constant instructions are inlined.
+		if inst := def.Instr; inst.Constant() {
+			a.m.InsertLoadConstant(inst, reg)
+		}
+	}
+	if arg.Kind == backend.ABIArgKindReg {
+		a.m.InsertMove(arg.Reg, reg, arg.Type)
+	} else {
+		// Note that the stack pointer is adjusted by the call instruction itself, so at this point
+		// the argument slots are placed below the current stack pointer.
+		m := a.m
+		mem := m.newAmodeImmReg(uint32(arg.Offset-slotBegin), rspVReg)
+		m.insert(m.allocateStore(arg.Type, reg, mem))
+	}
+}
+
+func (a *abiImpl) callerGenFunctionReturnVReg(retIndex int, reg regalloc.VReg, slotBegin int64) {
+	r := &a.rets[retIndex]
+	if r.Kind == backend.ABIArgKindReg {
+		a.m.InsertMove(reg, r.Reg, r.Type)
+	} else {
+		// The stack pointer is already restored by the call instruction, so the result slots are below the stack pointer.
+		m := a.m
+		mem := m.newAmodeImmReg(uint32(a.argStackSize+r.Offset-slotBegin), rspVReg)
+		m.insert(m.allocateLoad(r.Type, mem, reg))
+	}
+}
+
+// allocateLoad allocates the instruction to load the value of the given type from the memory into dst.
+func (m *machine) allocateLoad(typ ssa.Type, mem *amode, dst regalloc.VReg) *instruction {
+	load := m.allocateInstr()
+	switch typ {
+	case ssa.TypeI32:
+		load.asMovzxRmR(extModeLQ, newOperandMem(mem), dst)
+	case ssa.TypeI64:
+		load.asMov64MR(mem, dst)
+	case ssa.TypeF32:
+		load.asXmmUnaryRmR(sseOpcodeMovss, newOperandMem(mem), dst)
+	case ssa.TypeF64:
+		load.asXmmUnaryRmR(sseOpcodeMovsd, newOperandMem(mem), dst)
+	case ssa.TypeV128:
+		load.asXmmUnaryRmR(sseOpcodeMovdqu, newOperandMem(mem), dst)
+	default:
+		panic("BUG")
+	}
+	return load
+}
+
+// allocateStore allocates the instruction to store the value of the given type in src into the memory.
+func (m *machine) allocateStore(typ ssa.Type, src regalloc.VReg, mem *amode) *instruction {
+	store := m.allocateInstr()
+	switch typ {
+	case ssa.TypeI32:
+		store.asMovRM(newOperandReg(src), mem, 4)
+	case ssa.TypeI64:
+		store.asMovRM(newOperandReg(src), mem, 8)
+	case ssa.TypeF32:
+		store.asXmmMovRM(sseOpcodeMovss, src, mem)
+	case ssa.TypeF64:
+		store.asXmmMovRM(sseOpcodeMovsd, src, mem)
+	case ssa.TypeV128:
+		store.asXmmMovRM(sseOpcodeMovdqu, src, mem)
+	default:
+		panic("BUG")
+	}
+	return store
+}
+
+func (a *abiImpl) alignedArgResultStackSlotSize() int64 {
+	stackSlotSize := a.retStackSize + a.argStackSize
+	// Align stackSlotSize to 16 bytes.
+	stackSlotSize = (stackSlotSize + 15) &^ 15
+	return stackSlotSize
+}
+
+func (m *machine) lowerCall(si *ssa.Instruction) {
+	isDirectCall := si.Opcode() == ssa.OpcodeCall
+	var indirectCalleePtr ssa.Value
+	var directCallee ssa.FuncRef
+	var sigID ssa.SignatureID
+	var args []ssa.Value
+	if isDirectCall {
+		directCallee, sigID, args = si.CallData()
+	} else {
+		indirectCalleePtr, sigID, args = si.CallIndirectData()
+	}
+	calleeABI := m.getOrCreateABIImpl(m.compiler.SSABuilder().ResolveSignature(sigID))
+
+	stackSlotSize := calleeABI.alignedArgResultStackSlotSize()
+	if m.maxRequiredStackSizeForCalls < stackSlotSize+16 {
+		m.maxRequiredStackSizeForCalls = stackSlotSize + 16 // return address frame.
+	}
+
+	for i, arg := range args {
+		reg := m.compiler.VRegOf(arg)
+		def := m.compiler.ValueDefinition(arg)
+		calleeABI.callerGenVRegToFunctionArg(i, reg, def, stackSlotSize)
+	}
+
+	if isDirectCall {
+		call := m.allocateInstr()
+		call.asCall(directCallee, calleeABI, stackSlotSize)
+		m.insert(call)
+	} else {
+		ptr := m.compiler.VRegOf(indirectCalleePtr)
+		callInd := m.allocateInstr()
+		callInd.asCallIndirect(newOperandReg(ptr), calleeABI, stackSlotSize)
+		m.insert(callInd)
+	}
+
+	var index int
+	r1, rs := si.Returns()
+	if r1.Valid() {
+		calleeABI.callerGenFunctionReturnVReg(0, m.compiler.VRegOf(r1), stackSlotSize)
+		index++
+	}
+
+	for _, r := range rs {
+		calleeABI.callerGenFunctionReturnVReg(index, m.compiler.VRegOf(r), stackSlotSize)
+		index++
+	}
+}
@@ -0,0 +1,28 @@
+//go:build amd64
+
+#include "funcdata.h"
+#include "textflag.h"
+
+// See the comments on compileEntryPreamble for what this function is supposed to do.
+TEXT ·entrypoint(SB), NOSPLIT|NOFRAME, $0-48
+	MOVQ preambleExecutable+0(FP), R11
+	MOVQ functionExecutable+8(FP), R14
+	MOVQ executionContextPtr+16(FP), AX
+	MOVQ moduleContextPtr+24(FP), BX
+	MOVQ paramResultPtr+32(FP), R12
+	MOVQ goAllocatedStackSlicePtr+40(FP), R13
+	JMP  R11
+
+TEXT ·afterGoFunctionCallEntrypoint(SB), NOSPLIT|NOFRAME, $0-24
+	MOVQ executable+0(FP), CX
+	MOVQ executionContextPtr+8(FP), AX
+	MOVQ stackPointer+16(FP), BX
+
+	// Save the current FP(BP) and SP into the wazevo.executionContext (stored in AX).
+	// The return address to the Go code is at the top of the current stack.
+	MOVQ BP, 16(AX) // Store BP into [AX, #ExecutionContextOffsets.OriginalFramePointer]
+	MOVQ SP, 24(AX) // Store SP into [AX, #ExecutionContextOffsets.OriginalStackPointer]
+
+	// Load the new stack pointer (which sits somewhere in Go-allocated stack) into SP.
+	MOVQ BX, SP
+	JMP  CX
@@ -0,0 +1,156 @@
+package amd64
+
+import (
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend"
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+	"github.com/AR1011/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+var (
+	executionContextPtrReg = raxVReg
+
+	// Followings are callee saved registers. They can be used freely in the entry preamble
+	// since the preamble is called via Go assembly function which has stack-based ABI.
+
+	// savedExecutionContextPtr also must be a callee-saved reg so that they can be used in the prologue and epilogue.
+	savedExecutionContextPtr = r15VReg
+	// paramResultSlicePtr must match with entrypoint function in abi_entry_amd64.s.
+	paramResultSlicePtr = r12VReg
+	// goAllocatedStackPtr must match with entrypoint function in abi_entry_amd64.s.
+	goAllocatedStackPtr = r13VReg
+	// functionExecutable must match with entrypoint function in abi_entry_amd64.s.
+	functionExecutable = r14VReg
+)
+
+// CompileEntryPreamble implements backend.Machine. This assumes `entrypoint` function (in abi_entry_amd64.s) passes:
+//
+//  1. First (execution context ptr) and Second arguments are already passed in rax and rbx.
+//  2. param/result slice ptr in r12; the pointer to []uint64{} which is used to pass arguments and accept return values.
+//  3. Go-allocated stack slice ptr in r13.
+//  4. Function executable in r14.
+//
+// also SP and FP are correct Go-runtime-based values, and the return address to the Go-side caller is at the top of the stack.
+func (m *machine) CompileEntryPreamble(sig *ssa.Signature) []byte {
+	root := m.compileEntryPreamble(sig)
+	m.encode(root)
+	return m.compiler.Buf()
+}
+
+func (m *machine) compileEntryPreamble(sig *ssa.Signature) *instruction {
+	abi := abiImpl{}
+	abi.m = m
+	abi.init(sig)
+
+	root := m.allocateNop()
+
+	//// ----------------------------------- prologue ----------------------------------- ////
+
+	// First, we save executionContextPtrReg into a callee-saved register so that it can be used in epilogue as well.
+	// 		mov savedExecutionContextPtr, rax
+	cur := m.move64(executionContextPtrReg, savedExecutionContextPtr, root)
+
+	// Next, save the current FP and SP into the wazevo.executionContext:
+	// 		mov [savedExecutionContextPtr + OriginalFramePointer], rbp
+	// 		mov [savedExecutionContextPtr + OriginalStackPointer], rsp
+	cur = m.loadOrStoreAtExecutionContext(rbpVReg, wazevoapi.ExecutionContextOffsetOriginalFramePointer, true, cur)
+	cur = m.loadOrStoreAtExecutionContext(rspVReg, wazevoapi.ExecutionContextOffsetOriginalStackPointer, true, cur)
+
+	// Then, move the Go-allocated stack pointer to SP:
+	// 		mov rsp, goAllocatedStackPtr
+	cur = m.move64(goAllocatedStackPtr, rspVReg, cur)
+
+	stackSlotSize := abi.alignedArgResultStackSlotSize()
+	var offset int64
+	for i := range abi.args {
+		if i < 2 {
+			// module context ptr and execution context ptr are passed in rax and rbx by the Go assembly function.
+			continue
+		}
+		arg := &abi.args[i]
+		cur = m.goEntryPreamblePassArg(cur, paramResultSlicePtr, offset, arg, -stackSlotSize)
+		offset += goCallStackSlotSize(arg.Type)
+	}
+
+	// Call the real function.
+	call := m.allocateInstr()
+	call.asCallIndirect(newOperandReg(functionExecutable), &abi, stackSlotSize)
+	cur = linkInstr(cur, call)
+
+	///// ----------------------------------- epilogue ----------------------------------- /////
+
+	// Store the register results into paramResultSlicePtr.
+	offset = 0
+	for i := range abi.rets {
+		r := &abi.rets[i]
+		cur = m.goEntryPreamblePassResult(cur, paramResultSlicePtr, offset, r, abi.argStackSize-stackSlotSize)
+		offset += goCallStackSlotSize(r.Type)
+	}
+
+	// Finally, restore the FP, SP and return to the Go code.
+	// 		mov rbp, [savedExecutionContextPtr + OriginalFramePointer]
+	// 		mov rsp, [savedExecutionContextPtr + OriginalStackPointer]
+	// 		ret ;; --> return to the Go code
+	cur = m.loadOrStoreAtExecutionContext(rbpVReg, wazevoapi.ExecutionContextOffsetOriginalFramePointer, false, cur)
+	cur = m.loadOrStoreAtExecutionContext(rspVReg, wazevoapi.ExecutionContextOffsetOriginalStackPointer, false, cur)
+	retInst := m.allocateInstr()
+	retInst.asRet(nil)
+	linkInstr(cur, retInst)
+	return root
+}
+
+func (m *machine) goEntryPreamblePassArg(cur *instruction, paramSlicePtr regalloc.VReg, offsetInParamSlice int64, arg *backend.ABIArg, argStartOffsetFromSP int64) *instruction {
+	var dst regalloc.VReg
+	isStackArg := arg.Kind == backend.ABIArgKindStack
+	if isStackArg {
+		dst = m.tmpRegOf(arg.Type)
+	} else {
+		dst = arg.Reg
+	}
+
+	load := m.allocateLoad(arg.Type, m.newAmodeImmReg(uint32(offsetInParamSlice), paramSlicePtr), dst)
+	cur = linkInstr(cur, load)
+
+	if isStackArg {
+		store := m.allocateStore(arg.Type, dst, m.newAmodeImmReg(uint32(argStartOffsetFromSP+arg.Offset), rspVReg))
+		cur = linkInstr(cur, store)
+	}
+	return cur
+}
+
+func (m *machine) goEntryPreamblePassResult(cur *instruction, resultSlicePtr regalloc.VReg, offsetInResultSlice int64, result *backend.ABIArg, resultStartOffsetFromSP int64) *instruction {
+	var src regalloc.VReg
+	if result.Kind == backend.ABIArgKindStack {
+		src = m.tmpRegOf(result.Type)
+		load := m.allocateLoad(result.Type, m.newAmodeImmReg(uint32(resultStartOffsetFromSP+result.Offset), rspVReg), src)
+		cur = linkInstr(cur, load)
+	} else {
+		src = result.Reg
+	}
+
+	store := m.allocateStore(goCallStackSlotType(result.Type), src, m.newAmodeImmReg(uint32(offsetInResultSlice), resultSlicePtr))
+	return linkInstr(cur, store)
+}
+
+func (m *machine) move64(src, dst regalloc.VReg, prev *instruction) *instruction {
+	mov := m.allocateInstr()
+	mov.asMovRR(src, dst, true)
+	return linkInstr(prev, mov)
+}
+
+func (m *machine) loadOrStoreAtExecutionContext(d regalloc.VReg, offset wazevoapi.Offset, store bool, prev *instruction) *instruction {
+	mem := m.newAmodeImmReg(offset.U32(), savedExecutionContextPtr)
+	instr := m.allocateInstr()
+	if store {
+		instr.asMovRM(newOperandReg(d), mem, 8)
+	} else {
+		instr.asMov64MR(mem, d)
+	}
+	return linkInstr(prev, instr)
+}
+
+func linkInstr(prev, next *instruction) *instruction {
+	prev.next = next
+	next.prev = prev
+	return next
+}
@@ -0,0 +1,243 @@
+package amd64
+
+import (
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend"
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+	"github.com/AR1011/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+var calleeSavedRegistersSorted = []regalloc.VReg{
+	r12VReg, r13VReg, r14VReg, r15VReg,
+}
+
+// CompileGoFunctionTrampoline implements backend.Machine.
+func (m *machine) CompileGoFunctionTrampoline(exitCode wazevoapi.ExitCode, sig *ssa.Signature, needModuleContextPtr bool) []byte {
+	argBegin := 1 // Skips exec context by default.
+	if needModuleContextPtr {
+		argBegin++
+	}
+
+	abi := &abiImpl{m: m}
+	abi.init(sig)
+	m.currentABI = abi
+
+	cur := m.allocateNop()
+	m.rootInstr = cur
+
+	// Execution context is always the first argument.
+	execCtrPtr := raxVReg
+
+	// In the following, we create the following stack layout:
+	//
+	//                   (high address)
+	//                +-----------------+
+	//                |     .......     |
+	//                |      ret Y      |
+	//                |     .......     |
+	//                |      ret 0      |
+	//                |      arg X      |
+	//                |     .......     |
+	//                |      arg 1      |
+	//                |      arg 0      |
+	//                |  ReturnAddress  |
+	//                | size_of_arg_ret |
+	//                +-----------------+ <----+
+	//                |      xxxx       |      |  ;; might be padded to make it 16-byte aligned.
+	//           +--->|  arg[N]/ret[M]  |      |
+	//  sliceSize|    |   ............  |      | goCallStackSize
+	//           |    |  arg[1]/ret[1]  |      |
+	//           +--->|  arg[0]/ret[0]  | <----+
+	//                |    sliceSize    |
+	//                |   frame_size    |
+	//                +-----------------+ <---- SP
+	//                   (low address)
+	//
+	// where the region of "arg[0]/ret[0] ... arg[N]/ret[M]" is the stack used by the Go functions,
+	// therefore will be accessed as the usual []uint64. So that's where we need to pass/receive
+	// the arguments/return values.
+
+	// First of all, create "size_of_arg_ret" right below the return address.
+	cur = m.createSizeOfArgRetSlot(cur, abi.alignedArgResultStackSlotSize())
+
+	const frameInfoSize = 16 // == frame_size + sliceSize.
+
+	// Next, we should allocate the stack for the Go function call if necessary.
+	goCallStackSize, sliceSizeInBytes := goFunctionCallRequiredStackSize(sig, argBegin)
+	cur = m.insertStackBoundsCheck(goCallStackSize+frameInfoSize, cur)
+
+	// Save the callee saved registers.
+	cur = m.saveRegistersInExecutionContext(cur, execCtrPtr, calleeSavedRegistersSorted)
+
+	if needModuleContextPtr {
+		// Module context is always the second argument.
+		moduleCtrPtr := rbxVReg
+		store := m.allocateInstr()
+		store.asMovRM(newOperandReg(moduleCtrPtr),
+			m.newAmodeImmReg(wazevoapi.ExecutionContextOffsetGoFunctionCallCalleeModuleContextOpaque.U32(), execCtrPtr), 8)
+		cur = linkInstr(cur, store)
+	}
+
+	// Allocates the stack for the Go function call.
+	if goCallStackSize > 0 {
+		cur = m.addOrSubStackPointer(cur, goCallStackSize, false)
+	}
+
+	// The offset of the original arg 0 slot from the current SP: skip the Go call stack, size_of_arg_ret and the return address.
+	originalArg0Offset := goCallStackSize + 16
+	var offset int64
+	for i := range abi.args[argBegin:] {
+		arg := &abi.args[argBegin+i]
+		var v regalloc.VReg
+		if arg.Kind == backend.ABIArgKindReg {
+			v = arg.Reg
+		} else {
+			v = m.tmpRegOf(arg.Type)
+			load := m.allocateLoad(arg.Type, m.newAmodeImmReg(uint32(originalArg0Offset+arg.Offset), rspVReg), v)
+			cur = linkInstr(cur, load)
+		}
+		store := m.allocateStore(goCallStackSlotType(arg.Type), v, m.newAmodeImmReg(uint32(offset), rspVReg))
+		cur = linkInstr(cur, store)
+		offset += goCallStackSlotSize(arg.Type)
+	}
+
+	// Finally, now that we've stored the arguments, we allocate `frame_size + sliceSize`.
+	cur = m.addOrSubStackPointer(cur, frameInfoSize, false)
+	storeFrameSize := m.allocateInstr()
+	storeFrameSize.asMovRM(newOperandImm32(uint32(goCallStackSize)), m.newAmodeImmReg(0, rspVReg), 8)
+	cur = linkInstr(cur, storeFrameSize)
+	storeSliceSize := m.allocateInstr()
+	storeSliceSize.asMovRM(newOperandImm32(uint32(sliceSizeInBytes/8)), m.newAmodeImmReg(8, rspVReg), 8)
+	cur = linkInstr(cur, storeSliceSize)
+
+	// Set the exit status on the execution context.
+	cur = m.setExitCode(cur, execCtrPtr, exitCode)
+
+	// Exit the execution. The execution is resumed right after this with the execution context in rax.
+	exit := m.allocateInstr()
+	exit.asExitSequence(execCtrPtr)
+	cur = linkInstr(cur, exit)
+
+	// After the call, we need to restore the callee saved registers.
+	cur = m.restoreRegistersInExecutionContext(cur, execCtrPtr, calleeSavedRegistersSorted)
+
+	// The offset of the original ret 0 slot from the current SP.
+	originalRet0Offset := frameInfoSize + originalArg0Offset + abi.argStackSize
+	offset = frameInfoSize // Skips `frame_size + sliceSize`.
+	for i := range abi.rets {
+		r := &abi.rets[i]
+		if r.Kind == backend.ABIArgKindReg {
+			load := m.allocateLoad(r.Type, m.newAmodeImmReg(uint32(offset), rspVReg), r.Reg)
+			cur = linkInstr(cur, load)
+		} else {
+			// First we need to load the value to a temporary just like ^^.
+			tmp := m.tmpRegOf(r.Type)
+			load := m.allocateLoad(r.Type, m.newAmodeImmReg(uint32(offset), rspVReg), tmp)
+			cur = linkInstr(cur, load)
+			store := m.allocateStore(r.Type, tmp, m.newAmodeImmReg(uint32(originalRet0Offset+r.Offset), rspVReg))
+			cur = linkInstr(cur, store)
+		}
+		offset += goCallStackSlotSize(r.Type)
+	}
+
+	// Make the SP point to the return address.
+	cur = m.addOrSubStackPointer(cur, frameInfoSize+goCallStackSize+8, true)
+
+	ret := m.allocateInstr()
+	ret.asRet(nil)
+	linkInstr(cur, ret)
+
+	m.encode(m.rootInstr)
+	return m.compiler.Buf()
+}
+
+// tmpRegOf returns the temporary register which can hold the value of the given type.
+func (m *machine) tmpRegOf(typ ssa.Type) regalloc.VReg {
+	if typ.IsInt() {
+		return tmpIntRegVReg
+	}
+	return tmpFloatRegVReg
+}
+
+// goCallStackSlotType returns the type used to store the value of the given type into the Go call stack.
+// We use uint64 for all basic types, except SIMD v128.
+func goCallStackSlotType(typ ssa.Type) ssa.Type {
+	switch typ {
+	case ssa.TypeI32:
+		return ssa.TypeI64
+	case ssa.TypeF32:
+		return ssa.TypeF64
+	default:
+		return typ
+	}
+}
+
+// goCallStackSlotSize returns the size of the slot in the Go call stack for the given type.
+func goCallStackSlotSize(typ ssa.Type) int64 {
+	if typ == ssa.TypeV128 {
+		return 16
+	}
+	return 8 // We use uint64 for all basic types, except SIMD v128.
+}
+
+func (m *machine) saveRegistersInExecutionContext(cur *instruction, execCtx regalloc.VReg, regs []regalloc.VReg) *instruction {
+	offset := wazevoapi.ExecutionContextOffsetSavedRegistersBegin.I64()
+	for _, v := range regs {
+		store := m.allocateInstr()
+		mem := m.newAmodeImmReg(uint32(offset), execCtx)
+		switch v.RegType() {
+		case regalloc.RegTypeInt:
+			store.asMovRM(newOperandReg(v), mem, 8)
+		case regalloc.RegTypeFloat:
+			store.asXmmMovRM(sseOpcodeMovdqu, v, mem)
+		}
+		cur = linkInstr(cur, store)
+		offset += 16 // Unconditionally store regs at the offset of multiple of 16 to match arm64.
+	}
+	return cur
+}
+
+func (m *machine) restoreRegistersInExecutionContext(cur *instruction, execCtx regalloc.VReg, regs []regalloc.VReg) *instruction {
+	offset := wazevoapi.ExecutionContextOffsetSavedRegistersBegin.I64()
+	for _, v := range regs {
+		load := m.allocateInstr()
+		mem := m.newAmodeImmReg(uint32(offset), execCtx)
+		switch v.RegType() {
+		case regalloc.RegTypeInt:
+			load.asMov64MR(mem, v)
+		case regalloc.RegTypeFloat:
+			load.asXmmUnaryRmR(sseOpcodeMovdqu, newOperandMem(mem), v)
+		}
+		cur = linkInstr(cur, load)
+		offset += 16
+	}
+	return cur
+}
+
+func (m *machine) setExitCode(cur *instruction, execCtx regalloc.VReg, exitCode wazevoapi.ExitCode) *instruction {
+	setExitStatus := m.allocateInstr()
+	setExitStatus.asMovRM(newOperandImm32(uint32(exitCode)),
+		m.newAmodeImmReg(wazevoapi.ExecutionContextOffsetExitCodeOffset.U32(), execCtx), 4)
+	return linkInstr(cur, setExitStatus)
+}
+
+// goFunctionCallRequiredStackSize returns the size of the stack required for the Go function call.
+func goFunctionCallRequiredStackSize(sig *ssa.Signature, argBegin int) (ret, retUnaligned int64) {
+	var paramNeededInBytes, resultNeededInBytes int64
+	for _, p := range sig.Params[argBegin:] {
+		paramNeededInBytes += goCallStackSlotSize(p)
+	}
+	for _, r := range sig.Results {
+		resultNeededInBytes += goCallStackSlotSize(r)
+	}
+
+	if paramNeededInBytes > resultNeededInBytes {
+		ret = paramNeededInBytes
+	} else {
+		ret = resultNeededInBytes
+	}
+	retUnaligned = ret
+	// Align to 16 bytes.
+	ret = (ret + 15) &^ 15
+	return
+}
@@ -0,0 +1,88 @@
+package amd64
+
+import (
+	"testing"
+
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend"
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+	"github.com/AR1011/wazero/internal/testing/require"
+)
+
+func TestAbiImpl_init(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		sig  *ssa.Signature
+		exp  abiImpl
+	}{
+		{
+			name: "empty sig",
+			sig:  &ssa.Signature{},
+			exp:  abiImpl{},
+		},
+		{
+			name: "small sig",
+			sig: &ssa.Signature{
+				Params:  []ssa.Type{ssa.TypeI32, ssa.TypeF32, ssa.TypeI32},
+				Results: []ssa.Type{ssa.TypeI64, ssa.TypeF64},
+			},
+			exp: abiImpl{
+				args: []backend.ABIArg{
+					{Index: 0, Kind: backend.ABIArgKindReg, Reg: raxVReg, Type: ssa.TypeI32},
+					{Index: 1, Kind: backend.ABIArgKindReg, Reg: xmm0VReg, Type: ssa.TypeF32},
+					{Index: 2, Kind: backend.ABIArgKindReg, Reg: rbxVReg, Type: ssa.TypeI32},
+				},
+				rets: []backend.ABIArg{
+					{Index: 0, Kind: backend.ABIArgKindReg, Reg: raxVReg, Type: ssa.TypeI64},
+					{Index: 1, Kind: backend.ABIArgKindReg, Reg: xmm0VReg, Type: ssa.TypeF64},
+				},
+				argRealRegs: []regalloc.VReg{raxVReg, xmm0VReg, rbxVReg},
+				retRealRegs: []regalloc.VReg{raxVReg, xmm0VReg},
+			},
+		},
+		{
+			name: "regs stack mix and match",
+			sig: &ssa.Signature{
+				Params: []ssa.Type{
+					ssa.TypeI32, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64,
+					ssa.TypeI32, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64,
+					ssa.TypeI32, ssa.TypeV128, ssa.TypeI64,
+				},
+				Results: []ssa.Type{ssa.TypeI32},
+			},
+			exp: abiImpl{
+				args: []backend.ABIArg{
+					{Index: 0, Kind: backend.ABIArgKindReg, Reg: raxVReg, Type: ssa.TypeI32},
+					{Index: 1, Kind: backend.ABIArgKindReg, Reg: rbxVReg, Type: ssa.TypeI64},
+					{Index: 2, Kind: backend.ABIArgKindReg, Reg: rcxVReg, Type: ssa.TypeI32},
+					{Index: 3, Kind: backend.ABIArgKindReg, Reg: rdiVReg, Type: ssa.TypeI64},
+					{Index: 4, Kind: backend.ABIArgKindReg, Reg: rsiVReg, Type: ssa.TypeI32},
+					{Index: 5, Kind: backend.ABIArgKindReg, Reg: r8VReg, Type: ssa.TypeI64},
+					{Index: 6, Kind: backend.ABIArgKindReg, Reg: r9VReg, Type: ssa.TypeI32},
+					{Index: 7, Kind: backend.ABIArgKindReg, Reg: r10VReg, Type: ssa.TypeI64},
+					{Index: 8, Kind: backend.ABIArgKindStack, Offset: 0, Type: ssa.TypeI32},
+					{Index: 9, Kind: backend.ABIArgKindReg, Reg: xmm0VReg, Type: ssa.TypeV128},
+					{Index: 10, Kind: backend.ABIArgKindStack, Offset: 8, Type: ssa.TypeI64},
+				},
+				rets: []backend.ABIArg{
+					{Index: 0, Kind: backend.ABIArgKindReg, Reg: raxVReg, Type: ssa.TypeI32},
+				},
+				argStackSize: 16,
+				argRealRegs:  []regalloc.VReg{raxVReg, rbxVReg, rcxVReg, rdiVReg, rsiVReg, r8VReg, r9VReg, r10VReg, xmm0VReg},
+				retRealRegs:  []regalloc.VReg{raxVReg},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			abi := abiImpl{}
+			abi.init(tc.sig)
+			require.Equal(t, tc.exp.args, abi.args)
+			require.Equal(t, tc.exp.rets, abi.rets)
+			require.Equal(t, tc.exp.argStackSize, abi.argStackSize)
+			require.Equal(t, tc.exp.retStackSize, abi.retStackSize)
+			require.Equal(t, tc.exp.argRealRegs, abi.argRealRegs)
+			require.Equal(t, tc.exp.retRealRegs, abi.retRealRegs)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package amd64
+
+import (
+	"fmt"
+
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+)
+
+// cond represents the condition code of x86-64, which is encoded in the lower 4 bits of
+// jcc, setcc and cmovcc opcodes.
+//
+// In addition to the hardware condition codes, this also has the pseudo conditions condNPAndZ and condPOrNZ
+// which are used for the floating point equality comparisons, where the unordered case (i.e. NaN) sets the
+// parity flag. They are only used for the conditional jumps and lowered into two jumps.
+type cond byte
+
+const (
+	condO   cond = iota // overflow
+	condNO              // not overflow
+	condB               // below (unsigned <)
+	condNB              // not below (unsigned >=)
+	condZ               // zero (==)
+	condNZ              // not zero (!=)
+	condBE              // below or equal (unsigned <=)
+	condNBE             // not below or equal (unsigned >)
+	condS               // sign
+	condNS              // not sign
+	condP               // parity
+	condNP              // not parity
+	condL               // less (signed <)
+	condNL              // not less (signed >=)
+	condLE              // less or equal (signed <=)
+	condNLE             // not less or equal (signed >)
+
+	// condNPAndZ is true when the parity flag is not set and the zero flag is set. This is for the floating point equality.
+	condNPAndZ
+	// condPOrNZ is true when the parity flag is set or the zero flag is not set. This is for the floating point inequality.
+	condPOrNZ
+
+	condInvalid
+)
+
+// String implements fmt.Stringer.
+func (c cond) String() string {
+	switch c {
+	case condO:
+		return "o"
+	case condNO:
+		return "no"
+	case condB:
+		return "b"
+	case condNB:
+		return "nb"
+	case condZ:
+		return "z"
+	case condNZ:
+		return "nz"
+	case condBE:
+		return "be"
+	case condNBE:
+		return "nbe"
+	case condS:
+		return "s"
+	case condNS:
+		return "ns"
+	case condP:
+		return "p"
+	case condNP:
+		return "np"
+	case condL:
+		return "l"
+	case condNL:
+		return "nl"
+	case condLE:
+		return "le"
+	case condNLE:
+		return "nle"
+	case condNPAndZ:
+		return "np&z"
+	case condPOrNZ:
+		return "p|nz"
+	default:
+		panic(fmt.Sprintf("BUG: invalid cond %d", c))
+	}
+}
+
+// invert returns the inverted condition.
+func (c cond) invert() cond {
+	switch c {
+	case condNPAndZ:
+		return condPOrNZ
+	case condPOrNZ:
+		return condNPAndZ
+	default:
+		return c ^ 1
+	}
+}
+
+// isComposite returns true if the condition cannot be encoded as a single condition code.
+func (c cond) isComposite() bool {
+	return c == condNPAndZ || c == condPOrNZ
+}
+
+// condFromSSAIntCmpCond returns the cond for the given ssa.IntegerCmpCond.
+func condFromSSAIntCmpCond(c ssa.IntegerCmpCond) cond {
+	switch c {
+	case ssa.IntegerCmpCondEqual:
+		return condZ
+	case ssa.IntegerCmpCondNotEqual:
+		return condNZ
+	case ssa.IntegerCmpCondSignedLessThan:
+		return condL
+	case ssa.IntegerCmpCondSignedGreaterThanOrEqual:
+		return condNL
+	case ssa.IntegerCmpCondSignedGreaterThan:
+		return condNLE
+	case ssa.IntegerCmpCondSignedLessThanOrEqual:
+		return condLE
+	case ssa.IntegerCmpCondUnsignedLessThan:
+		return condB
+	case ssa.IntegerCmpCondUnsignedGreaterThanOrEqual:
+		return condNB
+	case ssa.IntegerCmpCondUnsignedGreaterThan:
+		return condNBE
+	case ssa.IntegerCmpCondUnsignedLessThanOrEqual:
+		return condBE
+	default:
+		panic("unreachable")
+	}
+}
+
+// condFromSSAFloatCmpCond returns the cond for the given ssa.FloatCmpCond as well as whether the operands
+// of the ucomis instructions must be swapped.
+//
+// The ucomiss/ucomisd instructions set ZF, PF and CF as follows:
+//
+//	unordered: ZF=1 PF=1 CF=1
+//	less than: ZF=0 PF=0 CF=1
+//	equal:     ZF=1 PF=0 CF=0
+//	greater:   ZF=0 PF=0 CF=0
+//
+// so that "above" and "above or equal" conditions are false for the unordered case. Therefore,
+// less than (or equal) are implemented by swapping the operands.
+func condFromSSAFloatCmpCond(c ssa.FloatCmpCond) (cc cond, swap bool) {
+	switch c {
+	case ssa.FloatCmpCondEqual:
+		return condNPAndZ, false
+	case ssa.FloatCmpCondNotEqual:
+		return condPOrNZ, false
+	case ssa.FloatCmpCondLessThan:
+		return condNBE, true
+	case ssa.FloatCmpCondLessThanOrEqual:
+		return condNB, true
+	case ssa.FloatCmpCondGreaterThan:
+		return condNBE, false
+	case ssa.FloatCmpCondGreaterThanOrEqual:
+		return condNB, false
+	default:
+		panic("unreachable")
+	}
+}
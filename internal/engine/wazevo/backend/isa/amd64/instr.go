@@ -0,0 +1,1200 @@
+package amd64
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+	"github.com/AR1011/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+type (
+	// instruction represents either a real instruction in amd64, or the meta instructions
+	// that are convenient for code generation. For example, inline constants are also treated
+	// as instructions.
+	//
+	// Unlike the actual x86-64 instructions, most of the arithmetic instructions are represented
+	// in the three-operand form, i.e. dst = op1 <op> op2, so that the register allocator doesn't need to
+	// care about the two-operand constraint of x86-64. The encoder inserts the necessary moves
+	// to satisfy the constraint, possibly using the temporary registers. See instr_encoding.go.
+	//
+	// Each field is interpreted depending on the kind.
+	instruction struct {
+		prev, next          *instruction
+		op1, op2            operand
+		dst                 regalloc.VReg
+		u1, u2              uint64
+		b1                  bool
+		addedBeforeRegAlloc bool
+		kind                instructionKind
+		abi                 *abiImpl
+		targets             []uint32
+	}
+
+	// instructionKind represents the kind of instruction.
+	// This controls how the instruction struct is interpreted.
+	instructionKind byte
+)
+
+const (
+	nop0 instructionKind = iota + 1
+
+	// ret is the return instruction.
+	ret
+
+	// imm loads the 64-bit or 32-bit immediate (u1) into dst. b1 is true for the 64-bit case.
+	imm
+
+	// aluRmiR is the integer arithmetic: dst = op1 <u1> op2 where op2 is either a register or a 32-bit immediate.
+	// b1 is true for the 64-bit case.
+	aluRmiR
+
+	// cmpRmiR is either cmp (u1 == 0) or test (u1 == 1) of op1 and op2, which sets the flags as `op1 - op2` or `op1 & op2`.
+	// op2 can be a register, a 32-bit immediate, or a memory for cmp. b1 is true for the 64-bit case.
+	cmpRmiR
+
+	// shiftR is the shift and rotate: dst = op1 <u1> op2 where op2 is either rcx or an immediate.
+	shiftR
+
+	// unaryRmR is the bit counting instructions: dst = <u1> op1.
+	unaryRmR
+
+	// not is the bitwise not: dst = ^op1.
+	not
+
+	// neg is the negation: dst = -op1.
+	neg
+
+	// signExtendData is cdq (32-bit) or cqo (64-bit), which sign-extends rax into rdx.
+	signExtendData
+
+	// div is the pseudo instruction of the division, which takes rax, rdx as dividend, and op1 as divisor.
+	// The result is in either rax (quotient) or rdx (remainder). u1 is the divKind.
+	div
+
+	// movRR is the register to register move: dst = op1. b1 is true for the 64-bit case.
+	movRR
+
+	// movzxRmR zero-extends op1 (register or memory) into dst. u1 is the extMode.
+	movzxRmR
+
+	// movsxRmR sign-extends op1 (register or memory) into dst. u1 is the extMode.
+	movsxRmR
+
+	// mov64MR loads the 64-bit value at op1 into dst.
+	mov64MR
+
+	// movRM stores op1 (register or 32-bit immediate) into the memory op2. u1 is the size in bytes.
+	movRM
+
+	// lea loads the effective address of op1 into dst.
+	lea
+
+	// setcc sets dst to 1 if the condition u1 holds, otherwise 0.
+	setcc
+
+	// cmove is the conditional move: dst = u1 ? op1 : op2. b1 is true for the 64-bit case.
+	cmove
+
+	// xmmMovRM stores op1 into the memory op2 with the store variant of the sseOpcode u1.
+	xmmMovRM
+
+	// xmmUnaryRmR is the unary vector operation: dst = <u1> op1 where op1 is either a register or a memory.
+	xmmUnaryRmR
+
+	// xmmUnaryRmRImm is the same as xmmUnaryRmR but with the 8-bit immediate u2.
+	xmmUnaryRmRImm
+
+	// xmmRmR is the binary vector operation: dst = op1 <u1> op2.
+	xmmRmR
+
+	// xmmRmRImm is the same as xmmRmR but with the 8-bit immediate u2.
+	xmmRmRImm
+
+	// xmmRmiReg is the vector shift: dst = op1 <u1> op2 where op2 is either a register or an immediate.
+	xmmRmiReg
+
+	// gprToXmm is the conversion or move from the general purpose register (or memory) op1 to the vector register dst.
+	// b1 is true if the source is 64-bit.
+	gprToXmm
+
+	// xmmToGpr is the move from the vector register op1 to the general purpose register dst.
+	// b1 is true if the destination is 64-bit.
+	xmmToGpr
+
+	// xmmToGprImm extracts the lane u2 of op1 into the general purpose register dst.
+	xmmToGprImm
+
+	// gprToXmmImm inserts the general purpose register op2 into the lane u2 of op1, and the result is in dst.
+	gprToXmmImm
+
+	// xmmCMov is the conditional move of vector registers: dst = u1 ? op1 : op2.
+	xmmCMov
+
+	// xmmSelfOp is the vector operation whose operands are all dst, e.g. pxor dst, dst to zero dst.
+	xmmSelfOp
+
+	// xmmCmpRmR is the vector comparison which sets the flags, e.g. ucomiss.
+	xmmCmpRmR
+
+	// jmp is the unconditional jump to the label op1, or the indirect jump to the address in register op1.
+	jmp
+
+	// jmpIf is the conditional jump to the label op1 if the condition u1 holds.
+	jmpIf
+
+	// jmpTableSequence is the sequence of the jump table, where op1 holds the index, and targets holds the labels.
+	jmpTableSequence
+
+	// call is the direct call to the function u1. u2 is the size of the argument/result stack slot
+	// which is allocated right before the call and freed right after.
+	call
+
+	// callIndirect is the indirect call to the address held by op1. u2 is the same as call.
+	callIndirect
+
+	// exitIf exits the execution with the exit code u2 if the condition u1 holds, where op1 holds the execution context.
+	// If u1 is condInvalid, this unconditionally exits.
+	exitIf
+
+	// exitSequence exits the execution to go back to the Go world, where op1 holds the execution context.
+	// Unlike exitIf, the execution can be resumed right after this instruction.
+	exitSequence
+
+	// ud2 is the undefined instruction which raises SIGILL.
+	ud2
+
+	// emitSourceOffsetInfo is a dummy instruction to emit source offset info.
+	// The existence of this instruction does not affect the execution.
+	emitSourceOffsetInfo
+
+	// cvtUint64ToFloatSeq is the sequence to convert the unsigned 64-bit integer op1 to the float dst.
+	// b1 is true if the destination is f64. op1 is clobbered.
+	cvtUint64ToFloatSeq
+
+	// cvtFloatToIntSeq is the sequence to convert the float op1 to the integer dst, where op2 holds the execution context
+	// used to exit on the invalid conversion. u1 holds the cvtFloatToIntFlag. op1 is clobbered.
+	cvtFloatToIntSeq
+
+	// numInstructionKinds is the number of instruction kinds, used to define the size of the tables.
+	numInstructionKinds
+)
+
+// IsCall implements regalloc.Instr IsCall.
+func (i *instruction) IsCall() bool { return i.kind == call }
+
+// IsIndirectCall implements regalloc.Instr IsIndirectCall.
+func (i *instruction) IsIndirectCall() bool { return i.kind == callIndirect }
+
+// IsReturn implements regalloc.Instr IsReturn.
+func (i *instruction) IsReturn() bool { return i.kind == ret }
+
+// IsCopy implements regalloc.Instr IsCopy.
+func (i *instruction) IsCopy() bool {
+	switch i.kind {
+	case movRR:
+		// We do not include the 32-bit move as it is not a copy instruction in the sense that it does not preserve the upper 32 bits.
+		return i.b1
+	case xmmUnaryRmR:
+		return sseOpcode(i.u1) == sseOpcodeMovdqa && i.op1.kind == operandKindReg
+	}
+	return false
+}
+
+type defKind byte
+
+const (
+	defKindNone defKind = iota + 1
+	defKindDst
+	defKindCall
+)
+
+var defKinds = [numInstructionKinds]defKind{
+	nop0:                 defKindNone,
+	ret:                  defKindNone,
+	imm:                  defKindDst,
+	aluRmiR:              defKindDst,
+	cmpRmiR:              defKindNone,
+	shiftR:               defKindDst,
+	unaryRmR:             defKindDst,
+	not:                  defKindDst,
+	neg:                  defKindDst,
+	signExtendData:       defKindDst,
+	div:                  defKindDst,
+	movRR:                defKindDst,
+	movzxRmR:             defKindDst,
+	movsxRmR:             defKindDst,
+	mov64MR:              defKindDst,
+	movRM:                defKindNone,
+	lea:                  defKindDst,
+	setcc:                defKindDst,
+	cmove:                defKindDst,
+	xmmMovRM:             defKindNone,
+	xmmUnaryRmR:          defKindDst,
+	xmmUnaryRmRImm:       defKindDst,
+	xmmRmR:               defKindDst,
+	xmmRmRImm:            defKindDst,
+	xmmRmiReg:            defKindDst,
+	gprToXmm:             defKindDst,
+	xmmToGpr:             defKindDst,
+	xmmToGprImm:          defKindDst,
+	gprToXmmImm:          defKindDst,
+	xmmCMov:              defKindDst,
+	xmmSelfOp:            defKindDst,
+	xmmCmpRmR:            defKindNone,
+	jmp:                  defKindNone,
+	jmpIf:                defKindNone,
+	jmpTableSequence:     defKindNone,
+	call:                 defKindCall,
+	callIndirect:         defKindCall,
+	exitIf:               defKindNone,
+	exitSequence:         defKindNone,
+	ud2:                  defKindNone,
+	emitSourceOffsetInfo: defKindNone,
+	cvtUint64ToFloatSeq:  defKindDst,
+	cvtFloatToIntSeq:     defKindDst,
+}
+
+// Defs implements regalloc.Instr Defs.
+func (i *instruction) Defs(regs *[]regalloc.VReg) []regalloc.VReg {
+	*regs = (*regs)[:0]
+	switch defKinds[i.kind] {
+	case defKindNone:
+	case defKindDst:
+		*regs = append(*regs, i.dst)
+	case defKindCall:
+		*regs = append(*regs, i.abi.retRealRegs...)
+	default:
+		panic(fmt.Sprintf("defKind for %v not defined", i))
+	}
+	return *regs
+}
+
+// AssignDef implements regalloc.Instr AssignDef.
+func (i *instruction) AssignDef(reg regalloc.VReg) {
+	switch defKinds[i.kind] {
+	case defKindNone:
+	case defKindDst:
+		i.dst = reg
+	case defKindCall:
+		panic("BUG: call instructions shouldn't be assigned")
+	default:
+		panic(fmt.Sprintf("defKind for %v not defined", i))
+	}
+}
+
+type useKind byte
+
+const (
+	useKindNone useKind = iota + 1
+	useKindOp1
+	useKindOp1Op2
+	useKindDiv
+	useKindRet
+	useKindCall
+	useKindCallInd
+)
+
+var useKinds = [numInstructionKinds]useKind{
+	nop0:                 useKindNone,
+	ret:                  useKindRet,
+	imm:                  useKindNone,
+	aluRmiR:              useKindOp1Op2,
+	cmpRmiR:              useKindOp1Op2,
+	shiftR:               useKindOp1Op2,
+	unaryRmR:             useKindOp1,
+	not:                  useKindOp1,
+	neg:                  useKindOp1,
+	signExtendData:       useKindOp1,
+	div:                  useKindDiv,
+	movRR:                useKindOp1,
+	movzxRmR:             useKindOp1,
+	movsxRmR:             useKindOp1,
+	mov64MR:              useKindOp1,
+	movRM:                useKindOp1Op2,
+	lea:                  useKindOp1,
+	setcc:                useKindNone,
+	cmove:                useKindOp1Op2,
+	xmmMovRM:             useKindOp1Op2,
+	xmmUnaryRmR:          useKindOp1,
+	xmmUnaryRmRImm:       useKindOp1,
+	xmmRmR:               useKindOp1Op2,
+	xmmRmRImm:            useKindOp1Op2,
+	xmmRmiReg:            useKindOp1Op2,
+	gprToXmm:             useKindOp1,
+	xmmToGpr:             useKindOp1,
+	xmmToGprImm:          useKindOp1,
+	gprToXmmImm:          useKindOp1Op2,
+	xmmCMov:              useKindOp1Op2,
+	xmmSelfOp:            useKindNone,
+	xmmCmpRmR:            useKindOp1Op2,
+	jmp:                  useKindOp1,
+	jmpIf:                useKindNone,
+	jmpTableSequence:     useKindOp1,
+	call:                 useKindCall,
+	callIndirect:         useKindCallInd,
+	exitIf:               useKindOp1,
+	exitSequence:         useKindOp1,
+	ud2:                  useKindNone,
+	emitSourceOffsetInfo: useKindNone,
+	cvtUint64ToFloatSeq:  useKindOp1,
+	cvtFloatToIntSeq:     useKindOp1Op2,
+}
+
+// appendRegs appends the registers used by the operand to regs.
+func (o *operand) appendRegs(regs *[]regalloc.VReg) {
+	switch o.kind {
+	case operandKindReg:
+		*regs = append(*regs, o.reg())
+	case operandKindMem:
+		if base := o.amode.base; base.Valid() {
+			*regs = append(*regs, base)
+		}
+		if index := o.amode.index; index.Valid() {
+			*regs = append(*regs, index)
+		}
+	}
+}
+
+// assignUse assigns the register reg to the *index-th register used by the operand.
+// This returns true if the register is assigned, otherwise the *index is decremented
+// by the number of registers used by the operand.
+func (o *operand) assignUse(index *int, reg regalloc.VReg) bool {
+	switch o.kind {
+	case operandKindReg:
+		if *index == 0 {
+			o.data = uint64(reg)
+			return true
+		}
+		*index--
+	case operandKindMem:
+		if o.amode.base.Valid() {
+			if *index == 0 {
+				o.amode.base = reg
+				return true
+			}
+			*index--
+		}
+		if o.amode.index.Valid() {
+			if *index == 0 {
+				o.amode.index = reg
+				return true
+			}
+			*index--
+		}
+	}
+	return false
+}
+
+// Uses implements regalloc.Instr Uses.
+func (i *instruction) Uses(regs *[]regalloc.VReg) []regalloc.VReg {
+	*regs = (*regs)[:0]
+	switch useKinds[i.kind] {
+	case useKindNone:
+	case useKindOp1:
+		i.op1.appendRegs(regs)
+	case useKindOp1Op2:
+		i.op1.appendRegs(regs)
+		i.op2.appendRegs(regs)
+	case useKindDiv:
+		i.op1.appendRegs(regs)
+		*regs = append(*regs, raxVReg, rdxVReg)
+	case useKindRet:
+		*regs = append(*regs, i.abi.retRealRegs...)
+	case useKindCall:
+		*regs = append(*regs, i.abi.argRealRegs...)
+	case useKindCallInd:
+		i.op1.appendRegs(regs)
+		if i.abi != nil {
+			*regs = append(*regs, i.abi.argRealRegs...)
+		}
+	default:
+		panic(fmt.Sprintf("useKind for %v not defined", i))
+	}
+	return *regs
+}
+
+// AssignUse implements regalloc.Instr AssignUse.
+func (i *instruction) AssignUse(index int, reg regalloc.VReg) {
+	switch useKinds[i.kind] {
+	case useKindNone:
+	case useKindOp1, useKindDiv, useKindCallInd:
+		if !i.op1.assignUse(&index, reg) {
+			panic("BUG: invalid use index")
+		}
+	case useKindOp1Op2:
+		if !i.op1.assignUse(&index, reg) && !i.op2.assignUse(&index, reg) {
+			panic("BUG: invalid use index")
+		}
+	case useKindRet, useKindCall:
+		panic("BUG: ret/call instructions shouldn't be assigned")
+	default:
+		panic(fmt.Sprintf("useKind for %v not defined", i))
+	}
+}
+
+func resetInstruction(i *instruction) {
+	*i = instruction{}
+}
+
+func (i *instruction) asNop0() *instruction {
+	i.kind = nop0
+	return i
+}
+
+func (i *instruction) asNop0WithLabel(l label) *instruction {
+	i.kind = nop0
+	i.u1 = uint64(l)
+	return i
+}
+
+func (i *instruction) nop0Label() label {
+	return label(i.u1)
+}
+
+func (i *instruction) asRet(abi *abiImpl) *instruction {
+	i.kind = ret
+	i.abi = abi
+	return i
+}
+
+func (i *instruction) asImm(dst regalloc.VReg, value uint64, _64 bool) *instruction {
+	i.kind = imm
+	i.dst = dst
+	i.u1 = value
+	i.b1 = _64
+	return i
+}
+
+func (i *instruction) asAluRmiR(op aluRmiROpcode, op1 regalloc.VReg, op2 operand, dst regalloc.VReg, _64 bool) *instruction {
+	if op2.kind != operandKindReg && op2.kind != operandKindImm32 {
+		panic("BUG: invalid operand for aluRmiR")
+	}
+	i.kind = aluRmiR
+	i.u1 = uint64(op)
+	i.op1 = newOperandReg(op1)
+	i.op2 = op2
+	i.dst = dst
+	i.b1 = _64
+	return i
+}
+
+func (i *instruction) asCmpRmiR(cmp bool, op1 regalloc.VReg, op2 operand, _64 bool) *instruction {
+	i.kind = cmpRmiR
+	if !cmp {
+		i.u1 = 1
+		if op2.kind == operandKindMem {
+			panic("BUG: test with memory operand is not supported")
+		}
+	}
+	i.op1 = newOperandReg(op1)
+	i.op2 = op2
+	i.b1 = _64
+	return i
+}
+
+func (i *instruction) asShiftR(op shiftROp, op1 regalloc.VReg, amount operand, dst regalloc.VReg, _64 bool) *instruction {
+	if amount.kind == operandKindReg && amount.reg() != rcxVReg {
+		panic("BUG: shift amount must be in rcx")
+	}
+	i.kind = shiftR
+	i.u1 = uint64(op)
+	i.op1 = newOperandReg(op1)
+	i.op2 = amount
+	i.dst = dst
+	i.b1 = _64
+	return i
+}
+
+func (i *instruction) asUnaryRmR(op unaryRmROpcode, op1, dst regalloc.VReg, _64 bool) *instruction {
+	i.kind = unaryRmR
+	i.u1 = uint64(op)
+	i.op1 = newOperandReg(op1)
+	i.dst = dst
+	i.b1 = _64
+	return i
+}
+
+func (i *instruction) asNot(op1, dst regalloc.VReg, _64 bool) *instruction {
+	i.kind = not
+	i.op1 = newOperandReg(op1)
+	i.dst = dst
+	i.b1 = _64
+	return i
+}
+
+func (i *instruction) asNeg(op1, dst regalloc.VReg, _64 bool) *instruction {
+	i.kind = neg
+	i.op1 = newOperandReg(op1)
+	i.dst = dst
+	i.b1 = _64
+	return i
+}
+
+func (i *instruction) asSignExtendData(_64 bool) *instruction {
+	i.kind = signExtendData
+	i.op1 = newOperandReg(raxVReg)
+	i.dst = rdxVReg
+	i.b1 = _64
+	return i
+}
+
+// divKind is the kind of the division.
+type divKind byte
+
+const (
+	divKindUnsignedDiv divKind = iota
+	divKindUnsignedRem
+	divKindSignedDiv
+	divKindSignedRem
+)
+
+func (d divKind) signed() bool { return d == divKindSignedDiv || d == divKindSignedRem }
+
+func (d divKind) rem() bool { return d == divKindUnsignedRem || d == divKindSignedRem }
+
+func (i *instruction) asDiv(kind divKind, divisor regalloc.VReg, _64 bool) *instruction {
+	i.kind = div
+	i.u1 = uint64(kind)
+	i.op1 = newOperandReg(divisor)
+	if kind.rem() {
+		i.dst = rdxVReg
+	} else {
+		i.dst = raxVReg
+	}
+	i.b1 = _64
+	return i
+}
+
+func (i *instruction) asMovRR(src, dst regalloc.VReg, _64 bool) *instruction {
+	i.kind = movRR
+	i.op1 = newOperandReg(src)
+	i.dst = dst
+	i.b1 = _64
+	return i
+}
+
+// extMode represents the mode of the extension of movzx and movsx.
+type extMode byte
+
+const (
+	extModeBL extMode = iota // byte to 32-bit.
+	extModeBQ                // byte to 64-bit.
+	extModeWL                // word to 32-bit.
+	extModeWQ                // word to 64-bit.
+	extModeLQ                // 32-bit to 64-bit.
+)
+
+func (e extMode) srcSize() byte {
+	switch e {
+	case extModeBL, extModeBQ:
+		return 1
+	case extModeWL, extModeWQ:
+		return 2
+	default:
+		return 4
+	}
+}
+
+func (e extMode) dstSize() byte {
+	switch e {
+	case extModeBL, extModeWL:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func (e extMode) String() string {
+	switch e {
+	case extModeBL:
+		return "bl"
+	case extModeBQ:
+		return "bq"
+	case extModeWL:
+		return "wl"
+	case extModeWQ:
+		return "wq"
+	case extModeLQ:
+		return "lq"
+	default:
+		panic("BUG: invalid extMode")
+	}
+}
+
+func (i *instruction) asMovzxRmR(mode extMode, src operand, dst regalloc.VReg) *instruction {
+	i.kind = movzxRmR
+	i.u1 = uint64(mode)
+	i.op1 = src
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asMovsxRmR(mode extMode, src operand, dst regalloc.VReg) *instruction {
+	i.kind = movsxRmR
+	i.u1 = uint64(mode)
+	i.op1 = src
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asMov64MR(src *amode, dst regalloc.VReg) *instruction {
+	i.kind = mov64MR
+	i.op1 = newOperandMem(src)
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asMovRM(src operand, dst *amode, size byte) *instruction {
+	if src.kind != operandKindReg && src.kind != operandKindImm32 {
+		panic("BUG: invalid source operand for movRM")
+	}
+	i.kind = movRM
+	i.op1 = src
+	i.op2 = newOperandMem(dst)
+	i.u1 = uint64(size)
+	return i
+}
+
+func (i *instruction) asLea(src *amode, dst regalloc.VReg) *instruction {
+	i.kind = lea
+	i.op1 = newOperandMem(src)
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asSetcc(c cond, dst regalloc.VReg) *instruction {
+	if c.isComposite() {
+		panic("BUG: composite condition cannot be used for setcc")
+	}
+	i.kind = setcc
+	i.u1 = uint64(c)
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asCmove(c cond, x, y, dst regalloc.VReg, _64 bool) *instruction {
+	if c.isComposite() {
+		panic("BUG: composite condition cannot be used for cmove")
+	}
+	i.kind = cmove
+	i.u1 = uint64(c)
+	i.op1 = newOperandReg(x)
+	i.op2 = newOperandReg(y)
+	i.dst = dst
+	i.b1 = _64
+	return i
+}
+
+func (i *instruction) asXmmMovRM(op sseOpcode, src regalloc.VReg, dst *amode) *instruction {
+	i.kind = xmmMovRM
+	i.u1 = uint64(op)
+	i.op1 = newOperandReg(src)
+	i.op2 = newOperandMem(dst)
+	return i
+}
+
+func (i *instruction) asXmmUnaryRmR(op sseOpcode, src operand, dst regalloc.VReg) *instruction {
+	i.kind = xmmUnaryRmR
+	i.u1 = uint64(op)
+	i.op1 = src
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asXmmUnaryRmRImm(op sseOpcode, imm byte, src operand, dst regalloc.VReg) *instruction {
+	i.kind = xmmUnaryRmRImm
+	i.u1 = uint64(op)
+	i.u2 = uint64(imm)
+	i.op1 = src
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asXmmRmR(op sseOpcode, op1, op2, dst regalloc.VReg) *instruction {
+	i.kind = xmmRmR
+	i.u1 = uint64(op)
+	i.op1 = newOperandReg(op1)
+	i.op2 = newOperandReg(op2)
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asXmmRmRImm(op sseOpcode, imm byte, op1, op2, dst regalloc.VReg) *instruction {
+	i.kind = xmmRmRImm
+	i.u1 = uint64(op)
+	i.u2 = uint64(imm)
+	i.op1 = newOperandReg(op1)
+	i.op2 = newOperandReg(op2)
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asXmmRmiReg(op sseOpcode, op1 regalloc.VReg, amount operand, dst regalloc.VReg) *instruction {
+	if amount.kind != operandKindReg && amount.kind != operandKindImm32 {
+		panic("BUG: invalid shift amount operand")
+	}
+	i.kind = xmmRmiReg
+	i.u1 = uint64(op)
+	i.op1 = newOperandReg(op1)
+	i.op2 = amount
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asGprToXmm(op sseOpcode, src operand, dst regalloc.VReg, _64 bool) *instruction {
+	i.kind = gprToXmm
+	i.u1 = uint64(op)
+	i.op1 = src
+	i.dst = dst
+	i.b1 = _64
+	return i
+}
+
+func (i *instruction) asXmmToGpr(op sseOpcode, src, dst regalloc.VReg, _64 bool) *instruction {
+	i.kind = xmmToGpr
+	i.u1 = uint64(op)
+	i.op1 = newOperandReg(src)
+	i.dst = dst
+	i.b1 = _64
+	return i
+}
+
+func (i *instruction) asXmmToGprImm(op sseOpcode, lane byte, src, dst regalloc.VReg) *instruction {
+	i.kind = xmmToGprImm
+	i.u1 = uint64(op)
+	i.u2 = uint64(lane)
+	i.op1 = newOperandReg(src)
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asGprToXmmImm(op sseOpcode, lane byte, vec, gpr, dst regalloc.VReg) *instruction {
+	i.kind = gprToXmmImm
+	i.u1 = uint64(op)
+	i.u2 = uint64(lane)
+	i.op1 = newOperandReg(vec)
+	i.op2 = newOperandReg(gpr)
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asXmmCMov(c cond, x, y, dst regalloc.VReg) *instruction {
+	i.kind = xmmCMov
+	i.u1 = uint64(c)
+	i.op1 = newOperandReg(x)
+	i.op2 = newOperandReg(y)
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asXmmSelfOp(op sseOpcode, dst regalloc.VReg) *instruction {
+	i.kind = xmmSelfOp
+	i.u1 = uint64(op)
+	i.dst = dst
+	return i
+}
+
+func (i *instruction) asXmmCmpRmR(op sseOpcode, op1, op2 regalloc.VReg) *instruction {
+	i.kind = xmmCmpRmR
+	i.u1 = uint64(op)
+	i.op1 = newOperandReg(op1)
+	i.op2 = newOperandReg(op2)
+	return i
+}
+
+func (i *instruction) asJmp(target operand) *instruction {
+	if target.kind != operandKindLabel && target.kind != operandKindReg {
+		panic("BUG: invalid jump target")
+	}
+	i.kind = jmp
+	i.op1 = target
+	return i
+}
+
+func (i *instruction) asJmpIf(c cond, target label) *instruction {
+	i.kind = jmpIf
+	i.u1 = uint64(c)
+	i.op1 = newOperandLabel(target)
+	return i
+}
+
+func (i *instruction) asJmpTableSequence(index regalloc.VReg, targets []uint32) *instruction {
+	i.kind = jmpTableSequence
+	i.op1 = newOperandReg(index)
+	i.targets = targets
+	return i
+}
+
+func (i *instruction) asCall(ref ssa.FuncRef, abi *abiImpl, stackSlotSize int64) *instruction {
+	i.kind = call
+	i.u1 = uint64(ref)
+	i.abi = abi
+	i.u2 = uint64(stackSlotSize)
+	return i
+}
+
+func (i *instruction) asCallIndirect(ptr operand, abi *abiImpl, stackSlotSize int64) *instruction {
+	if ptr.kind != operandKindReg && ptr.kind != operandKindMem {
+		panic("BUG: invalid operand for callIndirect")
+	}
+	i.kind = callIndirect
+	i.op1 = ptr
+	i.abi = abi
+	i.u2 = uint64(stackSlotSize)
+	return i
+}
+
+func (i *instruction) asExitIf(c cond, execCtx regalloc.VReg, code wazevoapi.ExitCode) *instruction {
+	i.kind = exitIf
+	i.u1 = uint64(c)
+	i.u2 = uint64(code)
+	i.op1 = newOperandReg(execCtx)
+	return i
+}
+
+func (i *instruction) asExitSequence(execCtx regalloc.VReg) *instruction {
+	i.kind = exitSequence
+	i.op1 = newOperandReg(execCtx)
+	return i
+}
+
+func (i *instruction) asUD2() *instruction {
+	i.kind = ud2
+	return i
+}
+
+func (i *instruction) asEmitSourceOffsetInfo(l ssa.SourceOffset) *instruction {
+	i.kind = emitSourceOffsetInfo
+	i.u1 = uint64(l)
+	return i
+}
+
+func (i *instruction) sourceOffsetInfo() ssa.SourceOffset {
+	return ssa.SourceOffset(i.u1)
+}
+
+func (i *instruction) asCvtUint64ToFloatSeq(src, dst regalloc.VReg, dst64 bool) *instruction {
+	i.kind = cvtUint64ToFloatSeq
+	i.op1 = newOperandReg(src)
+	i.dst = dst
+	i.b1 = dst64
+	return i
+}
+
+// cvtFloatToIntFlag is the flags of the cvtFloatToIntSeq instruction.
+type cvtFloatToIntFlag byte
+
+const (
+	cvtFloatToIntFlagSrc64 cvtFloatToIntFlag = 1 << iota
+	cvtFloatToIntFlagDst64
+	cvtFloatToIntFlagSigned
+	cvtFloatToIntFlagSat
+)
+
+func (f cvtFloatToIntFlag) has(flag cvtFloatToIntFlag) bool { return f&flag != 0 }
+
+func (i *instruction) asCvtFloatToIntSeq(src, execCtx, dst regalloc.VReg, flags cvtFloatToIntFlag) *instruction {
+	i.kind = cvtFloatToIntSeq
+	i.op1 = newOperandReg(src)
+	i.op2 = newOperandReg(execCtx)
+	i.dst = dst
+	i.u1 = uint64(flags)
+	return i
+}
+
+// aluRmiROpcode is the opcode of aluRmiR.
+type aluRmiROpcode byte
+
+const (
+	aluRmiROpcodeAdd aluRmiROpcode = iota
+	aluRmiROpcodeSub
+	aluRmiROpcodeAnd
+	aluRmiROpcodeOr
+	aluRmiROpcodeXor
+	aluRmiROpcodeMul
+)
+
+func (a aluRmiROpcode) String() string {
+	switch a {
+	case aluRmiROpcodeAdd:
+		return "add"
+	case aluRmiROpcodeSub:
+		return "sub"
+	case aluRmiROpcodeAnd:
+		return "and"
+	case aluRmiROpcodeOr:
+		return "or"
+	case aluRmiROpcodeXor:
+		return "xor"
+	case aluRmiROpcodeMul:
+		return "imul"
+	default:
+		panic("BUG")
+	}
+}
+
+func (a aluRmiROpcode) commutative() bool {
+	return a != aluRmiROpcodeSub
+}
+
+// shiftROp is the opcode of shiftR.
+type shiftROp byte
+
+const (
+	shiftROpRotateLeft           shiftROp = 0
+	shiftROpRotateRight          shiftROp = 1
+	shiftROpShiftLeft            shiftROp = 4
+	shiftROpShiftRightLogical    shiftROp = 5
+	shiftROpShiftRightArithmetic shiftROp = 7
+)
+
+func (s shiftROp) String() string {
+	switch s {
+	case shiftROpRotateLeft:
+		return "rol"
+	case shiftROpRotateRight:
+		return "ror"
+	case shiftROpShiftLeft:
+		return "shl"
+	case shiftROpShiftRightLogical:
+		return "shr"
+	case shiftROpShiftRightArithmetic:
+		return "sar"
+	default:
+		panic("BUG")
+	}
+}
+
+// unaryRmROpcode is the opcode of unaryRmR.
+type unaryRmROpcode byte
+
+const (
+	unaryRmROpcodeBsr unaryRmROpcode = iota
+	unaryRmROpcodeBsf
+	unaryRmROpcodeLzcnt
+	unaryRmROpcodeTzcnt
+	unaryRmROpcodePopcnt
+)
+
+func (u unaryRmROpcode) String() string {
+	switch u {
+	case unaryRmROpcodeBsr:
+		return "bsr"
+	case unaryRmROpcodeBsf:
+		return "bsf"
+	case unaryRmROpcodeLzcnt:
+		return "lzcnt"
+	case unaryRmROpcodeTzcnt:
+		return "tzcnt"
+	case unaryRmROpcodePopcnt:
+		return "popcnt"
+	default:
+		panic("BUG")
+	}
+}
+
+func sizeSuffix(_64 bool) string {
+	if _64 {
+		return "q"
+	}
+	return "l"
+}
+
+func sizeOf(_64 bool) byte {
+	if _64 {
+		return 8
+	}
+	return 4
+}
+
+// String implements fmt.Stringer.
+func (i *instruction) String() (str string) {
+	switch i.kind {
+	case nop0:
+		if i.u1 != 0 {
+			str = fmt.Sprintf("%s:", label(i.u1))
+		} else {
+			str = "nop0"
+		}
+	case ret:
+		str = "ret"
+	case imm:
+		if i.b1 {
+			str = fmt.Sprintf("movabsq $%d, %s", int64(i.u1), formatVRegSized(i.dst, 8))
+		} else {
+			str = fmt.Sprintf("movl $%d, %s", int32(i.u1), formatVRegSized(i.dst, 4))
+		}
+	case aluRmiR:
+		size := sizeOf(i.b1)
+		str = fmt.Sprintf("%s%s %s, %s, %s", aluRmiROpcode(i.u1), sizeSuffix(i.b1),
+			i.op2.format(size), i.op1.format(size), formatVRegSized(i.dst, size))
+	case cmpRmiR:
+		size := sizeOf(i.b1)
+		op := "cmp"
+		if i.u1 == 1 {
+			op = "test"
+		}
+		str = fmt.Sprintf("%s%s %s, %s", op, sizeSuffix(i.b1), i.op2.format(size), i.op1.format(size))
+	case shiftR:
+		size := sizeOf(i.b1)
+		amount := i.op2.format(1)
+		str = fmt.Sprintf("%s%s %s, %s, %s", shiftROp(i.u1), sizeSuffix(i.b1),
+			amount, i.op1.format(size), formatVRegSized(i.dst, size))
+	case unaryRmR:
+		size := sizeOf(i.b1)
+		str = fmt.Sprintf("%s%s %s, %s", unaryRmROpcode(i.u1), sizeSuffix(i.b1), i.op1.format(size), formatVRegSized(i.dst, size))
+	case not:
+		size := sizeOf(i.b1)
+		str = fmt.Sprintf("not%s %s, %s", sizeSuffix(i.b1), i.op1.format(size), formatVRegSized(i.dst, size))
+	case neg:
+		size := sizeOf(i.b1)
+		str = fmt.Sprintf("neg%s %s, %s", sizeSuffix(i.b1), i.op1.format(size), formatVRegSized(i.dst, size))
+	case signExtendData:
+		if i.b1 {
+			str = "cqo"
+		} else {
+			str = "cdq"
+		}
+	case div:
+		var op string
+		switch divKind(i.u1) {
+		case divKindUnsignedDiv:
+			op = "udiv"
+		case divKindUnsignedRem:
+			op = "urem"
+		case divKindSignedDiv:
+			op = "sdiv"
+		case divKindSignedRem:
+			op = "srem"
+		}
+		size := sizeOf(i.b1)
+		str = fmt.Sprintf("%s%s %s, %s", op, sizeSuffix(i.b1), i.op1.format(size), formatVRegSized(i.dst, size))
+	case movRR:
+		size := sizeOf(i.b1)
+		str = fmt.Sprintf("mov%s %s, %s", sizeSuffix(i.b1), i.op1.format(size), formatVRegSized(i.dst, size))
+	case movzxRmR:
+		mode := extMode(i.u1)
+		if mode == extModeLQ {
+			str = fmt.Sprintf("movl %s, %s", i.op1.format(4), formatVRegSized(i.dst, 4))
+		} else {
+			str = fmt.Sprintf("movzx%s %s, %s", mode, i.op1.format(mode.srcSize()), formatVRegSized(i.dst, mode.dstSize()))
+		}
+	case movsxRmR:
+		mode := extMode(i.u1)
+		str = fmt.Sprintf("movsx%s %s, %s", mode, i.op1.format(mode.srcSize()), formatVRegSized(i.dst, mode.dstSize()))
+	case mov64MR:
+		str = fmt.Sprintf("movq %s, %s", i.op1.format(8), formatVRegSized(i.dst, 8))
+	case movRM:
+		size := byte(i.u1)
+		var suffix string
+		switch size {
+		case 1:
+			suffix = "b"
+		case 2:
+			suffix = "w"
+		case 4:
+			suffix = "l"
+		case 8:
+			suffix = "q"
+		}
+		str = fmt.Sprintf("mov%s %s, %s", suffix, i.op1.format(size), i.op2.format(size))
+	case lea:
+		str = fmt.Sprintf("lea %s, %s", i.op1.format(8), formatVRegSized(i.dst, 8))
+	case setcc:
+		str = fmt.Sprintf("set%s %s", cond(i.u1), formatVRegSized(i.dst, 4))
+	case cmove:
+		size := sizeOf(i.b1)
+		str = fmt.Sprintf("cmov%s%s %s, %s, %s", cond(i.u1), sizeSuffix(i.b1),
+			i.op1.format(size), i.op2.format(size), formatVRegSized(i.dst, size))
+	case xmmMovRM:
+		str = fmt.Sprintf("%s %s, %s", sseOpcode(i.u1), i.op1.format(16), i.op2.format(16))
+	case xmmUnaryRmR:
+		str = fmt.Sprintf("%s %s, %s", sseOpcode(i.u1), i.op1.format(16), formatVRegSized(i.dst, 16))
+	case xmmUnaryRmRImm:
+		str = fmt.Sprintf("%s $%d, %s, %s", sseOpcode(i.u1), i.u2, i.op1.format(16), formatVRegSized(i.dst, 16))
+	case xmmRmR:
+		str = fmt.Sprintf("%s %s, %s, %s", sseOpcode(i.u1), i.op2.format(16), i.op1.format(16), formatVRegSized(i.dst, 16))
+	case xmmRmRImm:
+		str = fmt.Sprintf("%s $%d, %s, %s, %s", sseOpcode(i.u1), i.u2, i.op2.format(16), i.op1.format(16), formatVRegSized(i.dst, 16))
+	case xmmRmiReg:
+		str = fmt.Sprintf("%s %s, %s, %s", sseOpcode(i.u1), i.op2.format(16), i.op1.format(16), formatVRegSized(i.dst, 16))
+	case gprToXmm:
+		str = fmt.Sprintf("%s %s, %s", sseOpcode(i.u1), i.op1.format(sizeOf(i.b1)), formatVRegSized(i.dst, 16))
+	case xmmToGpr:
+		str = fmt.Sprintf("%s %s, %s", sseOpcode(i.u1), i.op1.format(16), formatVRegSized(i.dst, sizeOf(i.b1)))
+	case xmmToGprImm:
+		str = fmt.Sprintf("%s $%d, %s, %s", sseOpcode(i.u1), i.u2, i.op1.format(16), formatVRegSized(i.dst, 8))
+	case gprToXmmImm:
+		str = fmt.Sprintf("%s $%d, %s, %s, %s", sseOpcode(i.u1), i.u2, i.op2.format(8), i.op1.format(16), formatVRegSized(i.dst, 16))
+	case xmmCMov:
+		str = fmt.Sprintf("xmmcmov%s %s, %s, %s", cond(i.u1), i.op1.format(16), i.op2.format(16), formatVRegSized(i.dst, 16))
+	case xmmSelfOp:
+		str = fmt.Sprintf("%s %s, %s", sseOpcode(i.u1), formatVRegSized(i.dst, 16), formatVRegSized(i.dst, 16))
+	case xmmCmpRmR:
+		str = fmt.Sprintf("%s %s, %s", sseOpcode(i.u1), i.op2.format(16), i.op1.format(16))
+	case jmp:
+		if i.op1.kind == operandKindReg {
+			str = fmt.Sprintf("jmp *%s", i.op1.format(8))
+		} else {
+			str = fmt.Sprintf("jmp %s", i.op1.format(8))
+		}
+	case jmpIf:
+		str = fmt.Sprintf("j%s %s", cond(i.u1), i.op1.format(8))
+	case jmpTableSequence:
+		targets := make([]string, len(i.targets))
+		for j, t := range i.targets {
+			targets[j] = label(t).String()
+		}
+		str = fmt.Sprintf("jmp_table_sequence %s, [%s]", i.op1.format(8), strings.Join(targets, ", "))
+	case call:
+		str = fmt.Sprintf("call %s", ssa.FuncRef(i.u1))
+		if i.u2 > 0 {
+			str = fmt.Sprintf("%s; stack_slot_size=%d", str, i.u2)
+		}
+	case callIndirect:
+		str = fmt.Sprintf("call *%s", i.op1.format(8))
+		if i.u2 > 0 {
+			str = fmt.Sprintf("%s; stack_slot_size=%d", str, i.u2)
+		}
+	case exitIf:
+		code := wazevoapi.ExitCode(i.u2)
+		if c := cond(i.u1); c == condInvalid {
+			str = fmt.Sprintf("exit_sequence %s, %s", i.op1.format(8), code)
+		} else {
+			str = fmt.Sprintf("exit_if_%s %s, %s", c, i.op1.format(8), code)
+		}
+	case exitSequence:
+		str = fmt.Sprintf("exit_and_resume_sequence %s", i.op1.format(8))
+	case ud2:
+		str = "ud2"
+	case emitSourceOffsetInfo:
+		str = fmt.Sprintf("source_offset_info %d", ssa.SourceOffset(i.u1))
+	case cvtUint64ToFloatSeq:
+		op := "cvtsi2ss"
+		if i.b1 {
+			op = "cvtsi2sd"
+		}
+		str = fmt.Sprintf("%s_u64_seq %s, %s", op, i.op1.format(8), formatVRegSized(i.dst, 16))
+	case cvtFloatToIntSeq:
+		flags := cvtFloatToIntFlag(i.u1)
+		var op string
+		if flags.has(cvtFloatToIntFlagSrc64) {
+			op = "cvttsd2si"
+		} else {
+			op = "cvttss2si"
+		}
+		if !flags.has(cvtFloatToIntFlagSigned) {
+			op += "_u"
+		}
+		if flags.has(cvtFloatToIntFlagSat) {
+			op += "_sat"
+		}
+		str = fmt.Sprintf("%s_seq %s, %s, %s", op, i.op1.format(16), i.op2.format(8),
+			formatVRegSized(i.dst, sizeOf(flags.has(cvtFloatToIntFlagDst64))))
+	default:
+		panic(fmt.Sprintf("TODO: %d", i.kind))
+	}
+	return
+}
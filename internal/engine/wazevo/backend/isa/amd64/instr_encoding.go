@@ -0,0 +1,1155 @@
+package amd64
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+	"github.com/AR1011/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+// rexInfo is the information to construct the REX prefix.
+type rexInfo byte
+
+const (
+	rexInfoW rexInfo = 1 << iota
+	// rexInfoForce forces the REX prefix to be emitted even if no bits are set. This is necessary
+	// to access the lower byte of rsp, rbp, rsi and rdi (i.e. spl, bpl, sil and dil).
+	rexInfoForce
+)
+
+func rexW(_64 bool) rexInfo {
+	if _64 {
+		return rexInfoW
+	}
+	return 0
+}
+
+// withByteReg returns rexInfo with rexInfoForce set if the given register's lower byte can only be accessed with the REX prefix.
+func (r rexInfo) withByteReg(reg regalloc.VReg) rexInfo {
+	if e := regEncoding(reg.RealReg()); e >= 4 && e <= 7 {
+		return r | rexInfoForce
+	}
+	return r
+}
+
+// labelFixup is the fixup of the 32-bit displacement which refers to the label.
+type labelFixup struct {
+	// offset is the offset of the displacement in the buffer.
+	offset int64
+	// base is the offset from which the displacement is calculated.
+	base int64
+	l    label
+}
+
+func (m *machine) emit1(b byte) { m.compiler.EmitByte(b) }
+
+func (m *machine) emit4(v uint32) { m.compiler.Emit4Bytes(v) }
+
+func (m *machine) emit8(v uint64) {
+	m.compiler.Emit4Bytes(uint32(v))
+	m.compiler.Emit4Bytes(uint32(v >> 32))
+}
+
+func (m *machine) currentOffset() int64 { return int64(len(m.compiler.Buf())) }
+
+// patch4 patches the 4 bytes at the given offset of the buffer.
+func (m *machine) patch4(offset int64, v uint32) {
+	buf := m.compiler.Buf()
+	buf[offset] = byte(v)
+	buf[offset+1] = byte(v >> 8)
+	buf[offset+2] = byte(v >> 16)
+	buf[offset+3] = byte(v >> 24)
+}
+
+// patchRel8 patches the 8-bit relative jump offset at the given offset so that it jumps to the current position.
+func (m *machine) patchRel8(offset int64) {
+	diff := m.currentOffset() - (offset + 1)
+	if diff > math.MaxInt8 {
+		panic("BUG: too large short jump")
+	}
+	m.compiler.Buf()[offset] = byte(diff)
+}
+
+// emitShortJmpIf emits the jcc rel8 instruction whose offset is to be patched by patchRel8, and returns the offset of rel8.
+func (m *machine) emitShortJmpIf(c cond) int64 {
+	m.emit1(0x70 | byte(c))
+	m.emit1(0)
+	return m.currentOffset() - 1
+}
+
+// emitShortJmp emits the jmp rel8 instruction whose offset is to be patched by patchRel8, and returns the offset of rel8.
+func (m *machine) emitShortJmp() int64 {
+	m.emit1(0xeb)
+	m.emit1(0)
+	return m.currentOffset() - 1
+}
+
+// emitJmpIfToLabel emits the jcc rel32 to the label.
+func (m *machine) emitJmpIfToLabel(c cond, l label) {
+	m.emit1(0x0f)
+	m.emit1(0x80 | byte(c))
+	m.emitLabelDisp32(l, 0)
+}
+
+// emitLabelDisp32 emits the placeholder of the 32-bit displacement to the label, where
+// tail is the number of bytes that follow the displacement in the instruction.
+func (m *machine) emitLabelDisp32(l label, tail int64) {
+	offset := m.currentOffset()
+	m.labelFixups = append(m.labelFixups, labelFixup{offset: offset, base: offset + 4 + tail, l: l})
+	m.emit4(0)
+}
+
+// encodeOpcode emits the legacy prefix, the REX prefix, and the opcode.
+func (m *machine) encodeOpcode(prefix byte, opcode uint32, opcodeNum byte, rex rexInfo, r, x, b byte) {
+	if prefix != 0 {
+		m.emit1(prefix)
+	}
+	rexByte := byte(0x40)
+	if rex&rexInfoW != 0 {
+		rexByte |= 0x08
+	}
+	rexByte |= (r >> 3 & 1) << 2
+	rexByte |= (x >> 3 & 1) << 1
+	rexByte |= b >> 3 & 1
+	if rexByte != 0x40 || rex&rexInfoForce != 0 {
+		m.emit1(rexByte)
+	}
+	for i := int(opcodeNum) - 1; i >= 0; i-- {
+		m.emit1(byte(opcode >> (8 * i)))
+	}
+}
+
+// encodeRegReg encodes the instruction whose ModRM.reg is r and ModRM.rm is the register rm.
+func (m *machine) encodeRegReg(prefix byte, opcode uint32, opcodeNum byte, r, rm byte, rex rexInfo) {
+	m.encodeOpcode(prefix, opcode, opcodeNum, rex, r, 0, rm)
+	m.emit1(0xc0 | (r&7)<<3 | rm&7)
+}
+
+// encodeRegMem encodes the instruction whose ModRM.reg is r and ModRM.rm is the memory location a.
+// immSize is the size of the immediate following the displacement, which is necessary to resolve the rip-relative address.
+func (m *machine) encodeRegMem(prefix byte, opcode uint32, opcodeNum byte, r byte, a *amode, rex rexInfo, immSize int64) {
+	switch a.kind {
+	case amodeKindImmReg:
+		base := regEncoding(a.base.RealReg())
+		m.encodeOpcode(prefix, opcode, opcodeNum, rex, r, 0, base)
+		disp := int32(a.imm32)
+		var mod byte
+		if disp == 0 && base&7 != 5 {
+			mod = 0
+		} else if disp >= math.MinInt8 && disp <= math.MaxInt8 {
+			mod = 1
+		} else {
+			mod = 2
+		}
+		if base&7 == 4 {
+			// rsp and r12 requires the SIB byte.
+			m.emit1(mod<<6 | (r&7)<<3 | 4)
+			m.emit1(0b00_100_100)
+		} else {
+			m.emit1(mod<<6 | (r&7)<<3 | base&7)
+		}
+		switch mod {
+		case 1:
+			m.emit1(byte(disp))
+		case 2:
+			m.emit4(uint32(disp))
+		}
+	case amodeKindRegRegShift:
+		base, index := regEncoding(a.base.RealReg()), regEncoding(a.index.RealReg())
+		if index == 4 {
+			panic("BUG: rsp cannot be used as the index register")
+		}
+		m.encodeOpcode(prefix, opcode, opcodeNum, rex, r, index, base)
+		disp := int32(a.imm32)
+		var mod byte
+		if disp == 0 && base&7 != 5 {
+			mod = 0
+		} else if disp >= math.MinInt8 && disp <= math.MaxInt8 {
+			mod = 1
+		} else {
+			mod = 2
+		}
+		m.emit1(mod<<6 | (r&7)<<3 | 4)
+		m.emit1(a.shift<<6 | (index&7)<<3 | base&7)
+		switch mod {
+		case 1:
+			m.emit1(byte(disp))
+		case 2:
+			m.emit4(uint32(disp))
+		}
+	case amodeKindRipLabel:
+		m.encodeOpcode(prefix, opcode, opcodeNum, rex, r, 0, 0)
+		m.emit1((r&7)<<3 | 0b101)
+		m.emitLabelDisp32(label(a.imm32), immSize)
+	default:
+		panic("BUG: unresolved amode: " + a.String())
+	}
+}
+
+// encodeRegOperand is encodeRegReg or encodeRegMem depending on the kind of rm.
+func (m *machine) encodeRegOperand(prefix byte, opcode uint32, opcodeNum byte, r byte, rm *operand, rex rexInfo, immSize int64) {
+	switch rm.kind {
+	case operandKindReg:
+		m.encodeRegReg(prefix, opcode, opcodeNum, r, regEncoding(rm.reg().RealReg()), rex)
+	case operandKindMem:
+		m.encodeRegMem(prefix, opcode, opcodeNum, r, rm.amode, rex, immSize)
+	default:
+		panic("BUG: invalid operand kind for r/m")
+	}
+}
+
+func enc(r regalloc.VReg) byte {
+	if !r.IsRealReg() {
+		panic("BUG: register must be allocated: " + r.String())
+	}
+	return regEncoding(r.RealReg())
+}
+
+// encodeMovRR emits `mov dst, src` for the general purpose registers. The 32-bit move zero-extends the upper 32 bits.
+func (m *machine) encodeMovRR(src, dst byte, _64 bool) {
+	m.encodeRegReg(0, 0x89, 1, src, dst, rexW(_64))
+}
+
+// encodeMovdqa emits `movdqa dst, src` if they are different.
+func (m *machine) encodeMovdqa(src, dst byte) {
+	if src != dst {
+		m.encodeRegReg(0x66, 0x0f6f, 2, dst, src, 0)
+	}
+}
+
+// encodeMovImm emits the move of the immediate into the general purpose register dst.
+func (m *machine) encodeMovImm(dst byte, v uint64, _64 bool) {
+	if !_64 || v <= math.MaxUint32 {
+		// mov r32, imm32 which zero-extends the upper 32 bits.
+		m.encodeOpcode(0, uint32(0xb8|dst&7), 1, 0, 0, 0, dst)
+		m.emit4(uint32(v))
+	} else if fitsInImm32(int64(v)) {
+		// mov r/m64, imm32 which sign-extends the immediate.
+		m.encodeRegReg(0, 0xc7, 1, 0, dst, rexInfoW)
+		m.emit4(uint32(v))
+	} else {
+		// movabs r64, imm64.
+		m.encodeOpcode(0, uint32(0xb8|dst&7), 1, rexInfoW, 0, 0, dst)
+		m.emit8(v)
+	}
+}
+
+// encodeAluImm emits the arithmetic instruction with the immediate: `op dst, imm`, where digit is the ModRM.reg extension.
+func (m *machine) encodeAluImm(digit byte, dst byte, imm int32, _64 bool) {
+	if imm >= math.MinInt8 && imm <= math.MaxInt8 {
+		m.encodeRegReg(0, 0x83, 1, digit, dst, rexW(_64))
+		m.emit1(byte(imm))
+	} else {
+		m.encodeRegReg(0, 0x81, 1, digit, dst, rexW(_64))
+		m.emit4(uint32(imm))
+	}
+}
+
+// aluRmiROpcodeInfo holds the opcode of `op r, r/m` and the ModRM.reg extension of `op r/m, imm`.
+var aluRmiROpcodeInfo = [...]struct {
+	opcode    uint32
+	opcodeNum byte
+	digit     byte
+}{
+	aluRmiROpcodeAdd: {0x03, 1, 0},
+	aluRmiROpcodeSub: {0x2b, 1, 5},
+	aluRmiROpcodeAnd: {0x23, 1, 4},
+	aluRmiROpcodeOr:  {0x0b, 1, 1},
+	aluRmiROpcodeXor: {0x33, 1, 6},
+	aluRmiROpcodeMul: {0x0faf, 2, 0},
+}
+
+// encodeSSE encodes the sseOpcode whose ModRM.reg is r and ModRM.rm is rm.
+func (m *machine) encodeSSE(op sseOpcode, r byte, rm *operand, rex rexInfo, immSize int64) {
+	info := &sseOpcodeInfos[op]
+	var opcode uint32
+	for i := byte(0); i < info.opcodeLen; i++ {
+		opcode = opcode<<8 | uint32(info.opcode[i])
+	}
+	m.encodeRegOperand(info.prefix, opcode, info.opcodeLen, r, rm, rex, immSize)
+}
+
+// encodeSSERegReg is the same as encodeSSE but both operands are registers.
+func (m *machine) encodeSSERegReg(op sseOpcode, r, rm byte, rex rexInfo) {
+	info := &sseOpcodeInfos[op]
+	var opcode uint32
+	for i := byte(0); i < info.opcodeLen; i++ {
+		opcode = opcode<<8 | uint32(info.opcode[i])
+	}
+	m.encodeRegReg(info.prefix, opcode, info.opcodeLen, r, rm, rex)
+}
+
+// xmmShiftImmOpcode returns the opcode and the ModRM.reg extension of the vector shift by the immediate.
+func xmmShiftImmOpcode(op sseOpcode) (opcode uint32, digit byte) {
+	switch op {
+	case sseOpcodePsrlw:
+		return 0x0f71, 2
+	case sseOpcodePsraw:
+		return 0x0f71, 4
+	case sseOpcodePsllw:
+		return 0x0f71, 6
+	case sseOpcodePsrld:
+		return 0x0f72, 2
+	case sseOpcodePsrad:
+		return 0x0f72, 4
+	case sseOpcodePslld:
+		return 0x0f72, 6
+	case sseOpcodePsrlq:
+		return 0x0f73, 2
+	case sseOpcodePsllq:
+		return 0x0f73, 6
+	case sseOpcodePsrldq:
+		return 0x0f73, 3
+	case sseOpcodePslldq:
+		return 0x0f73, 7
+	default:
+		panic("BUG: invalid shift opcode: " + op.String())
+	}
+}
+
+// Encode implements backend.Machine Encode.
+func (m *machine) Encode() {
+	m.encode(m.rootInstr)
+}
+
+func (m *machine) encode(root *instruction) {
+	m.labelFixups = m.labelFixups[:0]
+	for cur := root; cur != nil; cur = cur.next {
+		m.encodeInstr(cur)
+	}
+
+	if len(m.constPool) > 0 {
+		// Align the constant pool to 16 bytes. The function itself is 16-byte aligned.
+		for m.currentOffset()&15 != 0 {
+			m.emit1(0xcc) // int3.
+		}
+		for _, c := range m.constPool {
+			m.setLabelOffset(c.l, m.currentOffset())
+			m.emit8(c.lo)
+			if c.size == 16 {
+				m.emit8(c.hi)
+			}
+			for m.currentOffset()&15 != 0 {
+				m.emit1(0)
+			}
+		}
+	}
+
+	for _, f := range m.labelFixups {
+		target := m.labelOffsets[f.l]
+		diff := target - f.base
+		if diff < math.MinInt32 || diff > math.MaxInt32 {
+			panic("BUG: too large label offset")
+		}
+		m.patch4(f.offset, uint32(int32(diff)))
+	}
+
+	if wazevoapi.PerfMapEnabled {
+		m.addPerfMapEntries()
+	}
+}
+
+func (m *machine) setLabelOffset(l label, offset int64) {
+	if int(l) >= len(m.labelOffsets) {
+		m.labelOffsets = append(m.labelOffsets, make([]int64, int(l)+1-len(m.labelOffsets))...)
+	}
+	m.labelOffsets[l] = offset
+}
+
+func (m *machine) encodeInstr(i *instruction) {
+	switch kind := i.kind; kind {
+	case nop0:
+		if l := i.nop0Label(); l != invalidLabel {
+			m.setLabelOffset(l, m.currentOffset())
+		}
+	case emitSourceOffsetInfo:
+		m.compiler.AddSourceOffsetInfo(m.currentOffset(), i.sourceOffsetInfo())
+	case ret:
+		m.emit1(0xc3)
+	case ud2:
+		m.emit1(0x0f)
+		m.emit1(0x0b)
+	case imm:
+		m.encodeMovImm(enc(i.dst), i.u1, i.b1)
+	case aluRmiR:
+		m.encodeAluRmiR(i)
+	case cmpRmiR:
+		rex := rexW(i.b1)
+		a := enc(i.op1.reg())
+		if i.u1 == 0 { // cmp
+			switch i.op2.kind {
+			case operandKindImm32:
+				m.encodeAluImm(7, a, int32(i.op2.imm32()), i.b1)
+			default:
+				// cmp r, r/m.
+				m.encodeRegOperand(0, 0x3b, 1, a, &i.op2, rex, 0)
+			}
+		} else { // test
+			switch i.op2.kind {
+			case operandKindImm32:
+				m.encodeRegReg(0, 0xf7, 1, 0, a, rex)
+				m.emit4(i.op2.imm32())
+			default:
+				m.encodeRegOperand(0, 0x85, 1, a, &i.op2, rex, 0)
+			}
+		}
+	case shiftR:
+		m.encodeShiftR(i)
+	case unaryRmR:
+		var prefix byte
+		var opcode uint32
+		switch unaryRmROpcode(i.u1) {
+		case unaryRmROpcodeBsr:
+			opcode = 0x0fbd
+		case unaryRmROpcodeBsf:
+			opcode = 0x0fbc
+		case unaryRmROpcodeLzcnt:
+			prefix, opcode = 0xf3, 0x0fbd
+		case unaryRmROpcodeTzcnt:
+			prefix, opcode = 0xf3, 0x0fbc
+		case unaryRmROpcodePopcnt:
+			prefix, opcode = 0xf3, 0x0fb8
+		}
+		m.encodeRegReg(prefix, opcode, 2, enc(i.dst), enc(i.op1.reg()), rexW(i.b1))
+	case not, neg:
+		src, dst := enc(i.op1.reg()), enc(i.dst)
+		if src != dst {
+			m.encodeMovRR(src, dst, i.b1)
+		}
+		digit := byte(2)
+		if kind == neg {
+			digit = 3
+		}
+		m.encodeRegReg(0, 0xf7, 1, digit, dst, rexW(i.b1))
+	case signExtendData:
+		if i.b1 {
+			m.emit1(0x48)
+		}
+		m.emit1(0x99)
+	case div:
+		m.encodeDiv(i)
+	case movRR:
+		src, dst := enc(i.op1.reg()), enc(i.dst)
+		if src != dst || !i.b1 {
+			m.encodeMovRR(src, dst, i.b1)
+		}
+	case movzxRmR, movsxRmR:
+		m.encodeMovExt(i)
+	case mov64MR:
+		m.encodeRegMem(0, 0x8b, 1, enc(i.dst), i.op1.amode, rexInfoW, 0)
+	case movRM:
+		m.encodeMovRM(i)
+	case lea:
+		m.encodeRegMem(0, 0x8d, 1, enc(i.dst), i.op1.amode, rexInfoW, 0)
+	case setcc:
+		dst := enc(i.dst)
+		rex := rexInfo(0).withByteReg(i.dst)
+		m.encodeRegReg(0, 0x0f90|uint32(i.u1), 2, 0, dst, rex)
+		// movzx dst32, dst8
+		m.encodeRegReg(0, 0x0fb6, 2, dst, dst, rex)
+	case cmove:
+		c := cond(i.u1)
+		x, y, dst := enc(i.op1.reg()), enc(i.op2.reg()), enc(i.dst)
+		switch {
+		case x == y:
+			if dst != x || !i.b1 {
+				m.encodeMovRR(x, dst, i.b1)
+			}
+		case dst == x:
+			m.encodeRegReg(0, 0x0f40|uint32(c.invert()), 2, dst, y, rexW(i.b1))
+		default:
+			if dst != y {
+				m.encodeMovRR(y, dst, i.b1)
+			}
+			m.encodeRegReg(0, 0x0f40|uint32(c), 2, dst, x, rexW(i.b1))
+		}
+	case xmmMovRM:
+		op := sseOpcode(i.u1)
+		info := &sseOpcodeInfos[op]
+		var rex rexInfo
+		if op == sseOpcodeMovq {
+			rex = rexInfoW
+		}
+		m.encodeRegMem(info.prefix, 0x0f00|uint32(info.storeOpcode), 2, enc(i.op1.reg()), i.op2.amode, rex, 0)
+	case xmmUnaryRmR:
+		op := sseOpcode(i.u1)
+		if op == sseOpcodeMovdqa && i.op1.kind == operandKindReg {
+			m.encodeMovdqa(enc(i.op1.reg()), enc(i.dst))
+		} else {
+			m.encodeSSE(op, enc(i.dst), &i.op1, 0, 0)
+		}
+	case xmmUnaryRmRImm:
+		m.encodeSSE(sseOpcode(i.u1), enc(i.dst), &i.op1, 0, 1)
+		m.emit1(byte(i.u2))
+	case xmmRmR, xmmRmRImm:
+		m.encodeXmmRmR(i)
+	case xmmRmiReg:
+		op := sseOpcode(i.u1)
+		if i.op2.kind == operandKindImm32 {
+			src, dst := enc(i.op1.reg()), enc(i.dst)
+			m.encodeMovdqa(src, dst)
+			opcode, digit := xmmShiftImmOpcode(op)
+			m.encodeRegReg(0x66, opcode, 2, digit, dst, 0)
+			m.emit1(byte(i.op2.imm32()))
+		} else {
+			m.encodeXmmRmR(i)
+		}
+	case gprToXmm:
+		m.encodeSSE(sseOpcode(i.u1), enc(i.dst), &i.op1, rexW(i.b1), 0)
+	case xmmToGpr:
+		op := sseOpcode(i.u1)
+		src, dst := enc(i.op1.reg()), enc(i.dst)
+		switch op {
+		case sseOpcodeMovd, sseOpcodeMovq:
+			// movd/movq r/m, xmm.
+			info := &sseOpcodeInfos[op]
+			m.encodeRegReg(info.prefix, 0x0f00|uint32(info.storeOpcode), 2, src, dst, rexW(i.b1))
+		default:
+			m.encodeSSERegReg(op, dst, src, rexW(i.b1))
+		}
+	case xmmToGprImm:
+		op := sseOpcode(i.u1)
+		src, dst := enc(i.op1.reg()), enc(i.dst)
+		switch op {
+		case sseOpcodePextrw:
+			m.encodeSSERegReg(op, dst, src, 0)
+		case sseOpcodePextrq:
+			m.encodeSSERegReg(op, src, dst, rexInfoW)
+		default:
+			m.encodeSSERegReg(op, src, dst, 0)
+		}
+		m.emit1(byte(i.u2))
+	case gprToXmmImm:
+		op := sseOpcode(i.u1)
+		vec, gpr, dst := enc(i.op1.reg()), enc(i.op2.reg()), enc(i.dst)
+		m.encodeMovdqa(vec, dst)
+		var rex rexInfo
+		if op == sseOpcodePinsrq {
+			rex = rexInfoW
+		}
+		m.encodeSSERegReg(op, dst, gpr, rex)
+		m.emit1(byte(i.u2))
+	case xmmCMov:
+		c := cond(i.u1)
+		x, y, dst := enc(i.op1.reg()), enc(i.op2.reg()), enc(i.dst)
+		switch {
+		case x == y:
+			m.encodeMovdqa(x, dst)
+		case dst == x:
+			// dst = c ? dst : y
+			skip := m.emitShortJmpIf(c)
+			m.encodeMovdqa(y, dst)
+			m.patchRel8(skip)
+		default:
+			m.encodeMovdqa(y, dst)
+			skip := m.emitShortJmpIf(c.invert())
+			m.encodeMovdqa(x, dst)
+			m.patchRel8(skip)
+		}
+	case xmmSelfOp:
+		dst := enc(i.dst)
+		m.encodeSSERegReg(sseOpcode(i.u1), dst, dst, 0)
+	case xmmCmpRmR:
+		m.encodeSSE(sseOpcode(i.u1), enc(i.op1.reg()), &i.op2, 0, 0)
+	case jmp:
+		if i.op1.kind == operandKindLabel {
+			m.emit1(0xe9)
+			m.emitLabelDisp32(i.op1.label(), 0)
+		} else {
+			m.encodeRegOperand(0, 0xff, 1, 4, &i.op1, 0, 0)
+		}
+	case jmpIf:
+		c, target := cond(i.u1), i.op1.label()
+		switch c {
+		case condNPAndZ:
+			skip := m.emitShortJmpIf(condP)
+			m.emitJmpIfToLabel(condZ, target)
+			m.patchRel8(skip)
+		case condPOrNZ:
+			m.emitJmpIfToLabel(condP, target)
+			m.emitJmpIfToLabel(condNZ, target)
+		default:
+			m.emitJmpIfToLabel(c, target)
+		}
+	case jmpTableSequence:
+		m.encodeJmpTableSequence(i)
+	case call:
+		if i.u2 > 0 {
+			m.encodeAluImm(5, enc(rspVReg), int32(i.u2), true) // sub rsp, imm
+		}
+		m.compiler.AddRelocationInfo(ssa.FuncRef(i.u1))
+		m.emit1(0xe8)
+		m.emit4(0) // placeholder to be resolved by ResolveRelocations.
+		if i.u2 > 0 {
+			m.encodeAluImm(0, enc(rspVReg), int32(i.u2), true) // add rsp, imm
+		}
+	case callIndirect:
+		if i.u2 > 0 {
+			m.encodeAluImm(5, enc(rspVReg), int32(i.u2), true)
+		}
+		m.encodeRegOperand(0, 0xff, 1, 2, &i.op1, 0, 0)
+		if i.u2 > 0 {
+			m.encodeAluImm(0, enc(rspVReg), int32(i.u2), true)
+		}
+	case exitIf:
+		c := cond(i.u1)
+		var skip int64
+		if c != condInvalid {
+			if c.isComposite() {
+				panic("BUG: composite condition cannot be used for exitIf")
+			}
+			skip = m.emitShortJmpIf(c.invert())
+		}
+		m.encodeExitWithCode(enc(i.op1.reg()), wazevoapi.ExitCode(i.u2))
+		if c != condInvalid {
+			m.patchRel8(skip)
+		}
+	case exitSequence:
+		m.encodeExitSequence(enc(i.op1.reg()))
+	case cvtUint64ToFloatSeq:
+		m.encodeCvtUint64ToFloatSeq(i)
+	case cvtFloatToIntSeq:
+		m.encodeCvtFloatToIntSeq(i)
+	default:
+		panic(fmt.Sprintf("TODO: %s", i))
+	}
+}
+
+func (m *machine) encodeAluRmiR(i *instruction) {
+	op := aluRmiROpcode(i.u1)
+	info := &aluRmiROpcodeInfo[op]
+	rex := rexW(i.b1)
+	a, dst := enc(i.op1.reg()), enc(i.dst)
+	if i.op2.kind == operandKindImm32 {
+		v := int32(i.op2.imm32())
+		if op == aluRmiROpcodeMul {
+			// imul dst, a, imm
+			if v >= math.MinInt8 && v <= math.MaxInt8 {
+				m.encodeRegReg(0, 0x6b, 1, dst, a, rex)
+				m.emit1(byte(v))
+			} else {
+				m.encodeRegReg(0, 0x69, 1, dst, a, rex)
+				m.emit4(uint32(v))
+			}
+			return
+		}
+		if a != dst {
+			m.encodeMovRR(a, dst, i.b1)
+		}
+		m.encodeAluImm(info.digit, dst, v, i.b1)
+		return
+	}
+
+	b := enc(i.op2.reg())
+	switch {
+	case dst == a:
+		m.encodeRegReg(0, info.opcode, info.opcodeNum, dst, b, rex)
+	case dst == b:
+		if op.commutative() {
+			m.encodeRegReg(0, info.opcode, info.opcodeNum, dst, a, rex)
+		} else {
+			// dst = a - dst = -dst + a.
+			m.encodeRegReg(0, 0xf7, 1, 3, dst, rex) // neg dst
+			m.encodeRegReg(0, aluRmiROpcodeInfo[aluRmiROpcodeAdd].opcode, 1, dst, a, rex)
+		}
+	default:
+		m.encodeMovRR(a, dst, i.b1)
+		m.encodeRegReg(0, info.opcode, info.opcodeNum, dst, b, rex)
+	}
+}
+
+func (m *machine) encodeShiftR(i *instruction) {
+	digit := byte(i.u1)
+	rex := rexW(i.b1)
+	a, dst := enc(i.op1.reg()), enc(i.dst)
+	if i.op2.kind == operandKindImm32 {
+		if a != dst {
+			m.encodeMovRR(a, dst, i.b1)
+		}
+		m.encodeRegReg(0, 0xc1, 1, digit, dst, rex)
+		m.emit1(byte(i.op2.imm32()))
+		return
+	}
+
+	if dst == regEncoding(rcx) {
+		// The destination is the same as the shift amount register, so we use the temporary register.
+		tmp := regEncoding(tmpIntReg)
+		m.encodeMovRR(a, tmp, i.b1)
+		m.encodeRegReg(0, 0xd3, 1, digit, tmp, rex)
+		m.encodeMovRR(tmp, dst, i.b1)
+		return
+	}
+	if a != dst {
+		m.encodeMovRR(a, dst, i.b1)
+	}
+	m.encodeRegReg(0, 0xd3, 1, digit, dst, rex)
+}
+
+func (m *machine) encodeDiv(i *instruction) {
+	kind := divKind(i.u1)
+	rex := rexW(i.b1)
+	divisor := enc(i.op1.reg())
+	digit := byte(6)
+	if kind.signed() {
+		digit = 7
+	}
+
+	if kind == divKindSignedRem {
+		// The remainder of the division by -1 is always zero, but idiv raises the exception on
+		// MinInt / -1 because of the overflow of the quotient. So we special case it:
+		//
+		//	cmp divisor, -1
+		//	jnz L1
+		//	mov edx, 0
+		//	jmp L2
+		// L1:
+		//	idiv divisor
+		// L2:
+		m.encodeAluImm(7, divisor, -1, i.b1)
+		l1 := m.emitShortJmpIf(condNZ)
+		m.encodeMovImm(regEncoding(rdx), 0, false)
+		l2 := m.emitShortJmp()
+		m.patchRel8(l1)
+		m.encodeRegReg(0, 0xf7, 1, digit, divisor, rex)
+		m.patchRel8(l2)
+		return
+	}
+	m.encodeRegReg(0, 0xf7, 1, digit, divisor, rex)
+}
+
+func (m *machine) encodeMovExt(i *instruction) {
+	mode := extMode(i.u1)
+	dst := enc(i.dst)
+	var rex rexInfo
+	if mode.dstSize() == 8 {
+		rex = rexInfoW
+	}
+	if mode.srcSize() == 1 && i.op1.kind == operandKindReg {
+		rex = rex.withByteReg(i.op1.reg())
+	}
+
+	var opcode uint32
+	var opcodeNum byte = 2
+	if i.kind == movzxRmR {
+		switch mode {
+		case extModeBL, extModeBQ:
+			opcode = 0x0fb6
+		case extModeWL, extModeWQ:
+			opcode = 0x0fb7
+		case extModeLQ:
+			// mov r32, r/m32 zero-extends the upper 32 bits.
+			opcode, opcodeNum, rex = 0x8b, 1, 0
+		}
+	} else {
+		switch mode {
+		case extModeBL, extModeBQ:
+			opcode = 0x0fbe
+		case extModeWL, extModeWQ:
+			opcode = 0x0fbf
+		case extModeLQ:
+			opcode, opcodeNum = 0x63, 1 // movsxd
+		}
+	}
+	m.encodeRegOperand(0, opcode, opcodeNum, dst, &i.op1, rex, 0)
+}
+
+func (m *machine) encodeMovRM(i *instruction) {
+	size := byte(i.u1)
+	a := i.op2.amode
+	if i.op1.kind == operandKindReg {
+		src := i.op1.reg()
+		switch size {
+		case 1:
+			m.encodeRegMem(0, 0x88, 1, enc(src), a, rexInfo(0).withByteReg(src), 0)
+		case 2:
+			m.encodeRegMem(0x66, 0x89, 1, enc(src), a, 0, 0)
+		case 4:
+			m.encodeRegMem(0, 0x89, 1, enc(src), a, 0, 0)
+		case 8:
+			m.encodeRegMem(0, 0x89, 1, enc(src), a, rexInfoW, 0)
+		default:
+			panic("BUG: invalid size")
+		}
+		return
+	}
+
+	v := i.op1.imm32()
+	switch size {
+	case 1:
+		m.encodeRegMem(0, 0xc6, 1, 0, a, 0, 1)
+		m.emit1(byte(v))
+	case 2:
+		m.encodeRegMem(0x66, 0xc7, 1, 0, a, 0, 2)
+		m.emit1(byte(v))
+		m.emit1(byte(v >> 8))
+	case 4:
+		m.encodeRegMem(0, 0xc7, 1, 0, a, 0, 4)
+		m.emit4(v)
+	case 8:
+		m.encodeRegMem(0, 0xc7, 1, 0, a, rexInfoW, 4)
+		m.emit4(v)
+	default:
+		panic("BUG: invalid size")
+	}
+}
+
+// encodeXmmRmR encodes xmmRmR, xmmRmRImm and xmmRmiReg with the register shift amount,
+// i.e. the binary operations in the form of dst = op1 <op> op2.
+func (m *machine) encodeXmmRmR(i *instruction) {
+	op := sseOpcode(i.u1)
+	a, b, dst := enc(i.op1.reg()), enc(i.op2.reg()), enc(i.dst)
+
+	emit := func(rm byte) {
+		m.encodeSSERegReg(op, dst, rm, 0)
+		if i.kind == xmmRmRImm {
+			m.emit1(byte(i.u2))
+		}
+	}
+
+	switch {
+	case dst == a:
+		emit(b)
+	case dst == b:
+		if i.kind == xmmRmR && sseOpcodeInfos[op].commutative {
+			emit(a)
+		} else {
+			tmp := regEncoding(tmpFloatRegVReg.RealReg())
+			m.encodeMovdqa(b, tmp)
+			m.encodeMovdqa(a, dst)
+			emit(tmp)
+		}
+	default:
+		m.encodeMovdqa(a, dst)
+		emit(b)
+	}
+}
+
+// jmpTableSequence is the sequence of the jump table:
+//
+//	lea r11, [rip + table]
+//	movsxd index, dword [r11 + index*4]
+//	add r11, index
+//	jmp r11
+//	table:
+//	  .int32 (target0 - table)
+//	  ...
+func (m *machine) encodeJmpTableSequence(i *instruction) {
+	index := enc(i.op1.reg())
+	tmp := regEncoding(tmpIntReg)
+
+	// lea r11, [rip + table]
+	m.encodeOpcode(0, 0x8d, 1, rexInfoW, tmp, 0, 0)
+	m.emit1((tmp&7)<<3 | 0b101)
+	leaDisp := m.currentOffset()
+	m.emit4(0)
+
+	// movsxd index, dword [r11 + index*4]
+	m.encodeRegMem(0, 0x63, 1, index, &amode{kind: amodeKindRegRegShift, base: tmpIntRegVReg, index: i.op1.reg(), shift: 2}, rexInfoW, 0)
+	// add r11, index
+	m.encodeRegReg(0, 0x01, 1, index, tmp, rexInfoW)
+	// jmp r11
+	m.encodeRegReg(0, 0xff, 1, 4, tmp, 0)
+
+	tableBegin := m.currentOffset()
+	m.patch4(leaDisp, uint32(tableBegin-(leaDisp+4)))
+	for _, t := range i.targets {
+		offset := m.currentOffset()
+		m.labelFixups = append(m.labelFixups, labelFixup{offset: offset, base: tableBegin, l: label(t)})
+		m.emit4(0)
+	}
+}
+
+// encodeExitWithCode encodes the sequence to exit the execution with the given exit code:
+//
+//	mov dword [execCtx + exitCodeOffset], code
+//	mov [execCtx + stackPointerBeforeGoCallOffset], rsp
+//	lea r11, [rip]
+//	mov [execCtx + goCallReturnAddressOffset], r11
+//	mov rbp, [execCtx + originalFramePointerOffset]
+//	mov rsp, [execCtx + originalStackPointerOffset]
+//	ret
+func (m *machine) encodeExitWithCode(execCtx byte, code wazevoapi.ExitCode) {
+	base := execCtxVRegFromEncoding(execCtx)
+	m.encodeRegMem(0, 0xc7, 1, 0, &amode{kind: amodeKindImmReg, base: base,
+		imm32: uint32(wazevoapi.ExecutionContextOffsetExitCodeOffset.I64())}, 0, 4)
+	m.emit4(uint32(code))
+	m.encodeRegMem(0, 0x89, 1, regEncoding(rsp), &amode{kind: amodeKindImmReg, base: base,
+		imm32: uint32(wazevoapi.ExecutionContextOffsetStackPointerBeforeGoCall.I64())}, rexInfoW, 0)
+	tmp := regEncoding(tmpIntReg)
+	// lea r11, [rip]
+	m.encodeOpcode(0, 0x8d, 1, rexInfoW, tmp, 0, 0)
+	m.emit1((tmp&7)<<3 | 0b101)
+	m.emit4(0)
+	m.encodeExitTail(execCtx)
+}
+
+// encodeExitSequence encodes the sequence to exit the execution and come back to the instruction right after this sequence:
+//
+//	mov [execCtx + stackPointerBeforeGoCallOffset], rsp
+//	lea r11, [rip + L1]
+//	mov [execCtx + goCallReturnAddressOffset], r11
+//	mov rbp, [execCtx + originalFramePointerOffset]
+//	mov rsp, [execCtx + originalStackPointerOffset]
+//	ret
+//
+// L1:
+func (m *machine) encodeExitSequence(execCtx byte) {
+	base := execCtxVRegFromEncoding(execCtx)
+	m.encodeRegMem(0, 0x89, 1, regEncoding(rsp), &amode{kind: amodeKindImmReg, base: base,
+		imm32: uint32(wazevoapi.ExecutionContextOffsetStackPointerBeforeGoCall.I64())}, rexInfoW, 0)
+	tmp := regEncoding(tmpIntReg)
+	// lea r11, [rip + L1]
+	m.encodeOpcode(0, 0x8d, 1, rexInfoW, tmp, 0, 0)
+	m.emit1((tmp&7)<<3 | 0b101)
+	disp := m.currentOffset()
+	m.emit4(0)
+	m.encodeExitTail(execCtx)
+	m.patch4(disp, uint32(m.currentOffset()-(disp+4)))
+}
+
+// encodeExitTail encodes the common tail of the exit sequences, assuming that r11 holds the return address.
+func (m *machine) encodeExitTail(execCtx byte) {
+	base := execCtxVRegFromEncoding(execCtx)
+	tmp := regEncoding(tmpIntReg)
+	m.encodeRegMem(0, 0x89, 1, tmp, &amode{kind: amodeKindImmReg, base: base,
+		imm32: uint32(wazevoapi.ExecutionContextOffsetGoCallReturnAddress.I64())}, rexInfoW, 0)
+	m.encodeRegMem(0, 0x8b, 1, regEncoding(rbp), &amode{kind: amodeKindImmReg, base: base,
+		imm32: uint32(wazevoapi.ExecutionContextOffsetOriginalFramePointer.I64())}, rexInfoW, 0)
+	m.encodeRegMem(0, 0x8b, 1, regEncoding(rsp), &amode{kind: amodeKindImmReg, base: base,
+		imm32: uint32(wazevoapi.ExecutionContextOffsetOriginalStackPointer.I64())}, rexInfoW, 0)
+	m.emit1(0xc3) // ret
+}
+
+func execCtxVRegFromEncoding(e byte) regalloc.VReg {
+	return regalloc.FromRealReg(rax+regalloc.RealReg(e), regalloc.RegTypeInt)
+}
+
+// encodeCvtUint64ToFloatSeq encodes the conversion from the unsigned 64-bit integer to the float:
+//
+//	test src, src
+//	js L1
+//	cvtsi2s{s,d} dst, src
+//	jmp L2
+//
+// L1:
+//
+//	mov r11, src
+//	shr r11, 1
+//	and src, 1
+//	or r11, src
+//	cvtsi2s{s,d} dst, r11
+//	adds{s,d} dst, dst
+//
+// L2:
+func (m *machine) encodeCvtUint64ToFloatSeq(i *instruction) {
+	src, dst := enc(i.op1.reg()), enc(i.dst)
+	cvt, add := sseOpcodeCvtsi2ss, sseOpcodeAddss
+	if i.b1 {
+		cvt, add = sseOpcodeCvtsi2sd, sseOpcodeAddsd
+	}
+	tmp := regEncoding(tmpIntReg)
+
+	m.encodeRegReg(0, 0x85, 1, src, src, rexInfoW)
+	l1 := m.emitShortJmpIf(condS)
+	m.encodeSSERegReg(cvt, dst, src, rexInfoW)
+	l2 := m.emitShortJmp()
+	m.patchRel8(l1)
+	m.encodeMovRR(src, tmp, true)
+	m.encodeRegReg(0, 0xd1, 1, byte(shiftROpShiftRightLogical), tmp, rexInfoW) // shr r11, 1
+	m.encodeAluImm(4, src, 1, true)                                            // and src, 1
+	m.encodeRegReg(0, 0x09, 1, src, tmp, rexInfoW)                             // or r11, src
+	m.encodeSSERegReg(cvt, dst, tmp, rexInfoW)
+	m.encodeSSERegReg(add, dst, dst, 0)
+	m.patchRel8(l2)
+}
+
+// encodeCvtFloatToIntSeq encodes the conversion from the float to the integer. The result is first computed
+// in r11 and moved to the destination at the very end, since the destination might be the same register as
+// the execution context which is used by the exit sequences.
+func (m *machine) encodeCvtFloatToIntSeq(i *instruction) {
+	flags := cvtFloatToIntFlag(i.u1)
+	src, execCtx, dst := enc(i.op1.reg()), enc(i.op2.reg()), enc(i.dst)
+	src64, dst64 := flags.has(cvtFloatToIntFlagSrc64), flags.has(cvtFloatToIntFlagDst64)
+	signed, sat := flags.has(cvtFloatToIntFlagSigned), flags.has(cvtFloatToIntFlagSat)
+
+	tmp := regEncoding(tmpIntReg)
+	tmpF := regEncoding(tmpFloatRegVReg.RealReg())
+
+	cvt, ucomis, sub, xor := sseOpcodeCvttss2si, sseOpcodeUcomiss, sseOpcodeSubss, sseOpcodeXorps
+	if src64 {
+		cvt, ucomis, sub, xor = sseOpcodeCvttsd2si, sseOpcodeUcomisd, sseOpcodeSubsd, sseOpcodeXorpd
+	}
+
+	// loadFloatConst loads the float constant into the temporary float register via r11.
+	loadFloatConst := func(f32Bits uint32, f64Bits uint64) {
+		if src64 {
+			m.encodeMovImm(tmp, f64Bits, true)
+			m.encodeRegReg(0x66, 0x0f6e, 2, tmpF, tmp, rexInfoW) // movq
+		} else {
+			m.encodeMovImm(tmp, uint64(f32Bits), false)
+			m.encodeRegReg(0x66, 0x0f6e, 2, tmpF, tmp, 0) // movd
+		}
+	}
+
+	var doneJumps, nanJumps, overflowJumps []int64
+
+	if signed {
+		// cvtts{s,d}2si r11, src
+		m.encodeSSERegReg(cvt, tmp, src, rexW(dst64))
+		// The invalid conversion results in the "integer indefinite" value, i.e. MinInt32 or MinInt64, which sets OF by `cmp r11, 1`.
+		m.encodeAluImm(7, tmp, 1, dst64)
+		doneJumps = append(doneJumps, m.emitShortJmpIf(condNO))
+
+		// Check if src is NaN.
+		m.encodeSSERegReg(ucomis, src, src, 0)
+		if sat {
+			notNaN := m.emitShortJmpIf(condNP)
+			m.encodeMovImm(tmp, 0, false)
+			doneJumps = append(doneJumps, m.emitShortJmp())
+			m.patchRel8(notNaN)
+
+			// If src is negative, the saturated result is the minimum value which r11 holds already.
+			m.encodeSSERegReg(xor, tmpF, tmpF, 0)
+			m.encodeSSERegReg(ucomis, src, tmpF, 0)
+			doneJumps = append(doneJumps, m.emitShortJmpIf(condB))
+			// Otherwise, the result is the maximum value.
+			if dst64 {
+				m.encodeMovImm(tmp, math.MaxInt64, true)
+			} else {
+				m.encodeMovImm(tmp, math.MaxInt32, false)
+			}
+		} else {
+			nanJumps = append(nanJumps, m.emitShortJmpIf(condP))
+
+			// Check if src is the valid minimum value.
+			var minOk cond
+			switch {
+			case !src64 && !dst64: // f32 -> i32
+				loadFloatConst(0xcf000000, 0) // -2^31
+				minOk = condNB
+			case src64 && !dst64: // f64 -> i32
+				loadFloatConst(0, 0xc1e0000000200000) // -2^31 - 1
+				minOk = condNBE
+			case !src64 && dst64: // f32 -> i64
+				loadFloatConst(0xdf000000, 0) // -2^63
+				minOk = condNB
+			default: // f64 -> i64
+				loadFloatConst(0, 0xc3e0000000000000) // -2^63
+				minOk = condNB
+			}
+			m.encodeSSERegReg(ucomis, src, tmpF, 0)
+			overflowJumps = append(overflowJumps, m.emitShortJmpIf(minOk.invert()))
+			// The positive value that results in the indefinite value is the overflow.
+			m.encodeSSERegReg(xor, tmpF, tmpF, 0)
+			m.encodeSSERegReg(ucomis, src, tmpF, 0)
+			overflowJumps = append(overflowJumps, m.emitShortJmpIf(condNBE))
+			// Now we know that the result is the minimum value, so reload it into r11 which was clobbered.
+			if dst64 {
+				m.encodeMovImm(tmp, 1<<63, true)
+			} else {
+				m.encodeMovImm(tmp, 1<<31, false)
+			}
+		}
+	} else if !dst64 {
+		// Unsigned 32-bit conversion is done via the signed 64-bit conversion.
+		m.encodeSSERegReg(ucomis, src, src, 0)
+		if sat {
+			notNaN := m.emitShortJmpIf(condNP)
+			m.encodeMovImm(tmp, 0, false)
+			doneJumps = append(doneJumps, m.emitShortJmp())
+			m.patchRel8(notNaN)
+		} else {
+			nanJumps = append(nanJumps, m.emitShortJmpIf(condP))
+		}
+		m.encodeSSERegReg(cvt, tmp, src, rexInfoW)
+		// Check if the upper 32 bits are zero.
+		m.encodeRegReg(0, 0xc1, 1, byte(shiftROpRotateRight), tmp, rexInfoW)
+		m.emit1(32)
+		m.encodeRegReg(0, 0x85, 1, tmp, tmp, 0) // test r11d, r11d
+		inRange := m.emitShortJmpIf(condZ)
+		if sat {
+			m.encodeSSERegReg(xor, tmpF, tmpF, 0)
+			m.encodeSSERegReg(ucomis, src, tmpF, 0)
+			positive := m.emitShortJmpIf(condNB)
+			m.encodeMovImm(tmp, 0, false)
+			doneJumps = append(doneJumps, m.emitShortJmp())
+			m.patchRel8(positive)
+			m.encodeMovImm(tmp, math.MaxUint32, false)
+			doneJumps = append(doneJumps, m.emitShortJmp())
+		} else {
+			overflowJumps = append(overflowJumps, m.emitShortJmp())
+		}
+		m.patchRel8(inRange)
+		m.encodeRegReg(0, 0xc1, 1, byte(shiftROpRotateRight), tmp, rexInfoW)
+		m.emit1(32)
+	} else {
+		// Unsigned 64-bit conversion.
+		m.encodeSSERegReg(ucomis, src, src, 0)
+		if sat {
+			notNaN := m.emitShortJmpIf(condNP)
+			m.encodeMovImm(tmp, 0, false)
+			doneJumps = append(doneJumps, m.emitShortJmp())
+			m.patchRel8(notNaN)
+		} else {
+			nanJumps = append(nanJumps, m.emitShortJmpIf(condP))
+		}
+
+		loadFloatConst(0x5f000000, 0x43e0000000000000) // 2^63
+		m.encodeSSERegReg(ucomis, src, tmpF, 0)
+		large := m.emitShortJmpIf(condNB)
+
+		// src < 2^63.
+		m.encodeSSERegReg(cvt, tmp, src, rexInfoW)
+		m.encodeRegReg(0, 0x85, 1, tmp, tmp, rexInfoW)
+		doneJumps = append(doneJumps, m.emitShortJmpIf(condNS))
+		if sat {
+			m.encodeMovImm(tmp, 0, false)
+			doneJumps = append(doneJumps, m.emitShortJmp())
+		} else {
+			overflowJumps = append(overflowJumps, m.emitShortJmp())
+		}
+
+		// src >= 2^63.
+		m.patchRel8(large)
+		m.encodeSSERegReg(sub, src, tmpF, 0)
+		m.encodeSSERegReg(cvt, tmp, src, rexInfoW)
+		m.encodeRegReg(0, 0x85, 1, tmp, tmp, rexInfoW)
+		if sat {
+			notOverflow := m.emitShortJmpIf(condNS)
+			m.encodeMovImm(tmp, math.MaxUint64, true)
+			doneJumps = append(doneJumps, m.emitShortJmp())
+			m.patchRel8(notOverflow)
+		} else {
+			overflowJumps = append(overflowJumps, m.emitShortJmpIf(condS))
+		}
+		// btc r11, 63
+		m.encodeRegReg(0, 0x0fba, 2, 7, tmp, rexInfoW)
+		m.emit1(63)
+	}
+
+	var toDone int64 = -1
+	if len(nanJumps) > 0 || len(overflowJumps) > 0 {
+		toDone = m.emitShortJmp()
+	}
+	if len(nanJumps) > 0 {
+		for _, j := range nanJumps {
+			m.patchRel8(j)
+		}
+		m.encodeExitWithCode(execCtx, wazevoapi.ExitCodeInvalidConversionToInteger)
+	}
+	if len(overflowJumps) > 0 {
+		for _, j := range overflowJumps {
+			m.patchRel8(j)
+		}
+		m.encodeExitWithCode(execCtx, wazevoapi.ExitCodeIntegerOverflow)
+	}
+	if toDone >= 0 {
+		m.patchRel8(toDone)
+	}
+	for _, j := range doneJumps {
+		m.patchRel8(j)
+	}
+	m.encodeMovRR(tmp, dst, dst64)
+}
@@ -0,0 +1,71 @@
+package amd64
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/AR1011/wazero/internal/testing/require"
+)
+
+func TestInstruction_encode(t *testing.T) {
+	for _, tc := range []struct {
+		setup func(*machine, *instruction)
+		want  string
+	}{
+		{want: "c3", setup: func(_ *machine, i *instruction) { i.asRet(nil) }},
+		{want: "0f0b", setup: func(_ *machine, i *instruction) { i.asUD2() }},
+		{want: "4889c1", setup: func(_ *machine, i *instruction) { i.asMovRR(raxVReg, rcxVReg, true) }},
+		{want: "89c1", setup: func(_ *machine, i *instruction) { i.asMovRR(raxVReg, rcxVReg, false) }},
+		{want: "4d89c7", setup: func(_ *machine, i *instruction) { i.asMovRR(r8VReg, r15VReg, true) }},
+		{want: "b901000000", setup: func(_ *machine, i *instruction) { i.asImm(rcxVReg, 1, false) }},
+		{want: "48b9efcdab8967452301", setup: func(_ *machine, i *instruction) { i.asImm(rcxVReg, 0x0123456789abcdef, true) }},
+		{want: "4803c1", setup: func(_ *machine, i *instruction) {
+			i.asAluRmiR(aluRmiROpcodeAdd, raxVReg, newOperandReg(rcxVReg), raxVReg, true)
+		}},
+		{want: "83e807", setup: func(_ *machine, i *instruction) {
+			i.asAluRmiR(aluRmiROpcodeSub, raxVReg, newOperandImm32(7), raxVReg, false)
+		}},
+		{want: "483bc1", setup: func(_ *machine, i *instruction) { i.asCmpRmiR(true, raxVReg, newOperandReg(rcxVReg), true) }},
+		{want: "4885c0", setup: func(_ *machine, i *instruction) { i.asCmpRmiR(false, raxVReg, newOperandReg(raxVReg), true) }},
+		{want: "48c1e003", setup: func(_ *machine, i *instruction) {
+			i.asShiftR(shiftROpShiftLeft, raxVReg, newOperandImm32(3), raxVReg, true)
+		}},
+		{want: "d3f8", setup: func(_ *machine, i *instruction) {
+			i.asShiftR(shiftROpShiftRightArithmetic, raxVReg, newOperandReg(rcxVReg), raxVReg, false)
+		}},
+		{want: "488b4808", setup: func(m *machine, i *instruction) { i.asMov64MR(m.newAmodeImmReg(8, raxVReg), rcxVReg) }},
+		{want: "48894c2410", setup: func(m *machine, i *instruction) {
+			i.asMovRM(newOperandReg(rcxVReg), m.newAmodeImmReg(16, rspVReg), 8)
+		}},
+		{want: "488d0c88", setup: func(m *machine, i *instruction) { i.asLea(m.newAmodeRegRegShift(0, raxVReg, rcxVReg, 2), rcxVReg) }},
+		{want: "480fbec1", setup: func(_ *machine, i *instruction) { i.asMovsxRmR(extModeBQ, newOperandReg(rcxVReg), raxVReg) }},
+		{want: "0fb7c1", setup: func(_ *machine, i *instruction) { i.asMovzxRmR(extModeWL, newOperandReg(rcxVReg), raxVReg) }},
+		{want: "660f6fca", setup: func(_ *machine, i *instruction) {
+			i.asXmmUnaryRmR(sseOpcodeMovdqa, newOperandReg(xmm2VReg), xmm1VReg)
+		}},
+		{want: "66450ffec8", setup: func(_ *machine, i *instruction) {
+			i.asXmmRmR(sseOpcodePaddd, xmm9VReg, xmm8VReg, xmm9VReg)
+		}},
+		{want: "660f70ca1b", setup: func(_ *machine, i *instruction) {
+			i.asXmmUnaryRmRImm(sseOpcodePshufd, 0x1b, newOperandReg(xmm2VReg), xmm1VReg)
+		}},
+		{want: "660f72f103", setup: func(_ *machine, i *instruction) {
+			i.asXmmRmiReg(sseOpcodePslld, xmm1VReg, newOperandImm32(3), xmm1VReg)
+		}},
+		{want: "66480f6ec8", setup: func(_ *machine, i *instruction) {
+			i.asGprToXmm(sseOpcodeMovq, newOperandReg(raxVReg), xmm1VReg, true)
+		}},
+		{want: "660fd7c1", setup: func(_ *machine, i *instruction) { i.asXmmToGpr(sseOpcodePmovmskb, xmm1VReg, raxVReg, false) }},
+		{want: "660f3817c9", setup: func(_ *machine, i *instruction) { i.asXmmCmpRmR(sseOpcodePtest, xmm1VReg, xmm1VReg) }},
+		{want: "0f94c00fb6c0", setup: func(_ *machine, i *instruction) { i.asSetcc(condZ, raxVReg) }},
+	} {
+		tc := tc
+		t.Run(tc.want, func(t *testing.T) {
+			mc, _, m := newSetupWithMockContext()
+			i := m.allocateInstr()
+			tc.setup(m, i)
+			m.encode(i)
+			require.Equal(t, tc.want, hex.EncodeToString(mc.buf))
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package amd64
+
+import (
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+)
+
+// lowerConstant allocates a new VReg and inserts the instruction to load the constant value.
+func (m *machine) lowerConstant(instr *ssa.Instruction) (vr regalloc.VReg) {
+	val := instr.Return()
+	valType := val.Type()
+
+	vr = m.compiler.AllocateVReg(valType)
+	m.InsertLoadConstant(instr, vr)
+	return
+}
+
+// InsertLoadConstant implements backend.Machine.
+//
+// Note that none of the instructions inserted here modifies the flags, so that constants can be
+// materialized between the comparison and the conditional instructions.
+func (m *machine) InsertLoadConstant(instr *ssa.Instruction, vr regalloc.VReg) {
+	val := instr.Return()
+	valType := val.Type()
+	v := instr.ConstantVal()
+
+	if valType.Bits() < 64 { // Clear the redundant bits just in case it's unexpectedly sign-extended, etc.
+		v = v & ((1 << valType.Bits()) - 1)
+	}
+
+	switch valType {
+	case ssa.TypeF32, ssa.TypeF64:
+		m.lowerFconst(vr, v, valType == ssa.TypeF64)
+	case ssa.TypeI32:
+		m.lowerIconst(vr, v, false)
+	case ssa.TypeI64:
+		m.lowerIconst(vr, v, true)
+	default:
+		panic("BUG")
+	}
+}
+
+func (m *machine) lowerIconst(dst regalloc.VReg, c uint64, _64 bool) {
+	i := m.allocateInstr()
+	i.asImm(dst, c, _64)
+	m.insert(i)
+}
+
+func (m *machine) lowerFconst(dst regalloc.VReg, c uint64, _64 bool) {
+	if c == 0 {
+		xor := m.allocateInstr()
+		xor.asXmmSelfOp(sseOpcodeXorps, dst)
+		m.insert(xor)
+		return
+	}
+
+	l := m.addConstant(8, c, 0)
+	load := m.allocateInstr()
+	if _64 {
+		load.asXmmUnaryRmR(sseOpcodeMovsd, newOperandMem(m.newAmodeRipLabel(l)), dst)
+	} else {
+		load.asXmmUnaryRmR(sseOpcodeMovss, newOperandMem(m.newAmodeRipLabel(l)), dst)
+	}
+	m.insert(load)
+}
+
+// lowerVconst loads the 128-bit constant into dst.
+func (m *machine) lowerVconst(dst regalloc.VReg, lo, hi uint64) {
+	if lo == 0 && hi == 0 {
+		xor := m.allocateInstr()
+		xor.asXmmSelfOp(sseOpcodePxor, dst)
+		m.insert(xor)
+		return
+	}
+
+	l := m.addConstant(16, lo, hi)
+	load := m.allocateInstr()
+	load.asXmmUnaryRmR(sseOpcodeMovdqu, newOperandMem(m.newAmodeRipLabel(l)), dst)
+	m.insert(load)
+}
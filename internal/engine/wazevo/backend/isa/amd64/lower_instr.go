@@ -0,0 +1,840 @@
+package amd64
+
+// Files prefixed as lower_instr** do the instruction selection, meaning that lowering SSA level instructions
+// into machine specific instructions.
+//
+// Importantly, what the lower** functions does includes tree-matching; find the pattern from the given instruction tree,
+// and merge the multiple instructions if possible. It can be considered as "N:1" instruction selection.
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+	"github.com/AR1011/wazero/internal/engine/wazevo/wazevoapi"
+	"github.com/AR1011/wazero/internal/platform"
+)
+
+// LowerSingleBranch implements backend.Machine.
+func (m *machine) LowerSingleBranch(br *ssa.Instruction) {
+	switch br.Opcode() {
+	case ssa.OpcodeJump:
+		_, _, targetBlk := br.BranchData()
+		if br.IsFallthroughJump() {
+			return
+		}
+		b := m.allocateInstr()
+		target := m.getOrAllocateSSABlockLabel(targetBlk)
+		if target == returnLabel {
+			b.asRet(m.currentABI)
+		} else {
+			b.asJmp(newOperandLabel(target))
+		}
+		m.insert(b)
+	case ssa.OpcodeBrTable:
+		m.lowerBrTable(br)
+	default:
+		panic("BUG: unexpected branch opcode" + br.Opcode().String())
+	}
+}
+
+func (m *machine) lowerBrTable(i *ssa.Instruction) {
+	index, targets := i.BrTableData()
+	indexReg := m.getOperand_Reg(m.compiler.ValueDefinition(index))
+
+	// Firstly, we have to do the bounds check of the index, and
+	// set it to the default target (sitting at the end of the list) if it's out of bounds.
+	//
+	//	mov maxIndexReg, $maximum_index
+	//	cmp index, maxIndexReg
+	//	cmovnbe adjustedIndex, maxIndexReg ;; if index is higher than maxIndexReg.
+	maxIndexReg := m.compiler.AllocateVReg(ssa.TypeI32)
+	m.lowerIconst(maxIndexReg, uint64(len(targets)-1), false)
+	cmp := m.allocateInstr()
+	cmp.asCmpRmiR(true, indexReg, newOperandReg(maxIndexReg), false)
+	m.insert(cmp)
+
+	// The 32-bit cmov always zero-extends the result, which is required by the jump table sequence.
+	adjustedIndex := m.compiler.AllocateVReg(ssa.TypeI64)
+	cmov := m.allocateInstr()
+	cmov.asCmove(condNBE, maxIndexReg, indexReg, adjustedIndex, false)
+	m.insert(cmov)
+
+	// TODO: reuse the slice!
+	labels := make([]uint32, len(targets))
+	for j, target := range targets {
+		labels[j] = uint32(m.getOrAllocateSSABlockLabel(target))
+	}
+
+	jmpTable := m.allocateInstr()
+	jmpTable.asJmpTableSequence(adjustedIndex, labels)
+	m.insert(jmpTable)
+}
+
+// LowerConditionalBranch implements backend.Machine.
+func (m *machine) LowerConditionalBranch(b *ssa.Instruction) {
+	cval, args, targetBlk := b.BranchData()
+	if len(args) > 0 {
+		panic(fmt.Sprintf(
+			"conditional branch shouldn't have args; likely a bug in critical edge splitting: from %s to %s",
+			m.currentSSABlk,
+			targetBlk,
+		))
+	}
+
+	target := m.getOrAllocateSSABlockLabel(targetBlk)
+	cvalDef := m.compiler.ValueDefinition(cval)
+
+	var cc cond
+	switch {
+	case m.compiler.MatchInstr(cvalDef, ssa.OpcodeIcmp): // In this case, we can use the flags set by cmp directly.
+		x, y, c := cvalDef.Instr.IcmpData()
+		cc = condFromSSAIntCmpCond(c)
+		m.lowerIcmpToFlag(x, y)
+		cvalDef.Instr.MarkLowered()
+	case m.compiler.MatchInstr(cvalDef, ssa.OpcodeFcmp): // In this case, we can use the flags set by ucomis directly.
+		x, y, c := cvalDef.Instr.FcmpData()
+		cc = m.lowerFcmpToFlag(x, y, c)
+		cvalDef.Instr.MarkLowered()
+	default:
+		v := m.getOperand_Reg(cvalDef)
+		test := m.allocateInstr()
+		test.asCmpRmiR(false, v, newOperandReg(v), cval.Type().Bits() == 64)
+		m.insert(test)
+		cc = condNZ
+	}
+
+	if b.Opcode() == ssa.OpcodeBrz {
+		cc = cc.invert()
+	}
+	jmp := m.allocateInstr()
+	jmp.asJmpIf(cc, target)
+	m.insert(jmp)
+}
+
+// LowerInstr implements backend.Machine.
+func (m *machine) LowerInstr(instr *ssa.Instruction) {
+	if l := instr.SourceOffset(); l.Valid() {
+		info := m.allocateInstr().asEmitSourceOffsetInfo(l)
+		m.insert(info)
+	}
+
+	switch op := instr.Opcode(); op {
+	case ssa.OpcodeBrz, ssa.OpcodeBrnz, ssa.OpcodeJump, ssa.OpcodeBrTable:
+		panic("BUG: branching instructions are handled by LowerBranches")
+	case ssa.OpcodeReturn:
+		panic("BUG: return must be handled by backend.Compiler")
+	case ssa.OpcodeIconst, ssa.OpcodeF32const, ssa.OpcodeF64const: // Constant instructions are inlined.
+	case ssa.OpcodeIadd:
+		m.lowerAluRmiROp(instr, aluRmiROpcodeAdd)
+	case ssa.OpcodeIsub:
+		m.lowerAluRmiROp(instr, aluRmiROpcodeSub)
+	case ssa.OpcodeImul:
+		m.lowerAluRmiROp(instr, aluRmiROpcodeMul)
+	case ssa.OpcodeBand:
+		m.lowerAluRmiROp(instr, aluRmiROpcodeAnd)
+	case ssa.OpcodeBor:
+		m.lowerAluRmiROp(instr, aluRmiROpcodeOr)
+	case ssa.OpcodeBxor:
+		m.lowerAluRmiROp(instr, aluRmiROpcodeXor)
+	case ssa.OpcodeIshl:
+		m.lowerShiftR(instr, shiftROpShiftLeft)
+	case ssa.OpcodeSshr:
+		m.lowerShiftR(instr, shiftROpShiftRightArithmetic)
+	case ssa.OpcodeUshr:
+		m.lowerShiftR(instr, shiftROpShiftRightLogical)
+	case ssa.OpcodeRotl:
+		m.lowerShiftR(instr, shiftROpRotateLeft)
+	case ssa.OpcodeRotr:
+		m.lowerShiftR(instr, shiftROpRotateRight)
+	case ssa.OpcodeClz:
+		m.lowerClz(instr.Arg(), instr.Return())
+	case ssa.OpcodeCtz:
+		m.lowerCtz(instr.Arg(), instr.Return())
+	case ssa.OpcodePopcnt:
+		x := instr.Arg()
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+		rd := m.compiler.VRegOf(instr.Return())
+		popcnt := m.allocateInstr()
+		popcnt.asUnaryRmR(unaryRmROpcodePopcnt, rn, rd, x.Type().Bits() == 64)
+		m.insert(popcnt)
+	case ssa.OpcodeSdiv, ssa.OpcodeUdiv, ssa.OpcodeSrem, ssa.OpcodeUrem:
+		m.lowerIDivRem(instr, op == ssa.OpcodeSdiv || op == ssa.OpcodeUdiv, op == ssa.OpcodeSdiv || op == ssa.OpcodeSrem)
+	case ssa.OpcodeIcmp:
+		m.lowerIcmp(instr)
+	case ssa.OpcodeFcmp:
+		x, y, c := instr.FcmpData()
+		m.lowerFcmp(x, y, instr.Return(), c)
+	case ssa.OpcodeSelect:
+		m.lowerSelect(instr)
+	case ssa.OpcodeSExtend, ssa.OpcodeUExtend:
+		from, to, signed := instr.ExtendData()
+		m.lowerExtend(instr.Arg(), instr.Return(), from, to, signed)
+	case ssa.OpcodeIreduce:
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(instr.Arg()))
+		retVal := instr.Return()
+		rd := m.compiler.VRegOf(retVal)
+
+		if retVal.Type() != ssa.TypeI32 {
+			panic("BUG: Ireduce is only emitted to i32, but got " + retVal.Type().String())
+		}
+		mov := m.allocateInstr()
+		mov.asMovRR(rn, rd, false)
+		m.insert(mov)
+	case ssa.OpcodeFadd:
+		m.lowerXmmBinOp(instr, sseOpcodeAddss, sseOpcodeAddsd)
+	case ssa.OpcodeFsub:
+		m.lowerXmmBinOp(instr, sseOpcodeSubss, sseOpcodeSubsd)
+	case ssa.OpcodeFmul:
+		m.lowerXmmBinOp(instr, sseOpcodeMulss, sseOpcodeMulsd)
+	case ssa.OpcodeFdiv:
+		m.lowerXmmBinOp(instr, sseOpcodeDivss, sseOpcodeDivsd)
+	case ssa.OpcodeFmin, ssa.OpcodeFmax:
+		x, y := instr.Arg2()
+		m.lowerFminFmax(x, y, instr.Return(), op == ssa.OpcodeFmin, x.Type() == ssa.TypeF64)
+	case ssa.OpcodeFneg:
+		m.lowerFabsFneg(instr.Arg(), instr.Return(), false)
+	case ssa.OpcodeFabs:
+		m.lowerFabsFneg(instr.Arg(), instr.Return(), true)
+	case ssa.OpcodeFcopysign:
+		x, y := instr.Arg2()
+		m.lowerFcopysign(x, y, instr.Return())
+	case ssa.OpcodeSqrt:
+		m.lowerXmmUnaryOp(instr, sseOpcodeSqrtss, sseOpcodeSqrtsd)
+	case ssa.OpcodeNearest:
+		m.lowerRound(instr, roundingModeNearest)
+	case ssa.OpcodeFloor:
+		m.lowerRound(instr, roundingModeDown)
+	case ssa.OpcodeCeil:
+		m.lowerRound(instr, roundingModeUp)
+	case ssa.OpcodeTrunc:
+		m.lowerRound(instr, roundingModeZero)
+	case ssa.OpcodeFdemote:
+		m.lowerXmmUnaryOp(instr, sseOpcodeCvtsd2ss, sseOpcodeCvtsd2ss)
+	case ssa.OpcodeFpromote:
+		m.lowerXmmUnaryOp(instr, sseOpcodeCvtss2sd, sseOpcodeCvtss2sd)
+	case ssa.OpcodeFcvtToSint, ssa.OpcodeFcvtToSintSat, ssa.OpcodeFcvtToUint, ssa.OpcodeFcvtToUintSat:
+		x, ctx := instr.Arg2()
+		result := instr.Return()
+		var flags cvtFloatToIntFlag
+		if x.Type() == ssa.TypeF64 {
+			flags |= cvtFloatToIntFlagSrc64
+		}
+		if result.Type() == ssa.TypeI64 {
+			flags |= cvtFloatToIntFlagDst64
+		}
+		if op == ssa.OpcodeFcvtToSint || op == ssa.OpcodeFcvtToSintSat {
+			flags |= cvtFloatToIntFlagSigned
+		}
+		if op == ssa.OpcodeFcvtToSintSat || op == ssa.OpcodeFcvtToUintSat {
+			flags |= cvtFloatToIntFlagSat
+		}
+		m.lowerFcvtToInt(x, m.compiler.VRegOf(ctx), result, flags)
+	case ssa.OpcodeFcvtFromSint, ssa.OpcodeFcvtFromUint:
+		m.lowerFcvtFromInt(instr.Arg(), instr.Return(), op == ssa.OpcodeFcvtFromSint)
+	case ssa.OpcodeBitcast:
+		m.lowerBitcast(instr)
+	case ssa.OpcodeExitWithCode:
+		execCtx, code := instr.ExitWithCodeData()
+		exit := m.allocateInstr()
+		exit.asExitIf(condInvalid, m.compiler.VRegOf(execCtx), code)
+		m.insert(exit)
+	case ssa.OpcodeExitIfTrueWithCode:
+		execCtx, c, code := instr.ExitIfTrueWithCodeData()
+		m.lowerExitIfTrueWithCode(m.compiler.VRegOf(execCtx), c, code)
+	case ssa.OpcodeUndefined:
+		m.insert(m.allocateInstr().asUD2())
+	case ssa.OpcodeStore, ssa.OpcodeIstore8, ssa.OpcodeIstore16, ssa.OpcodeIstore32:
+		m.lowerStore(instr)
+	case ssa.OpcodeLoad:
+		ptr, offset, typ := instr.LoadData()
+		m.lowerLoad(ptr, offset, typ, instr.Return())
+	case ssa.OpcodeVZeroExtLoad:
+		ptr, offset, typ := instr.VZeroExtLoadData()
+		m.lowerLoad(ptr, offset, typ, instr.Return())
+	case ssa.OpcodeUload8, ssa.OpcodeUload16, ssa.OpcodeUload32, ssa.OpcodeSload8, ssa.OpcodeSload16, ssa.OpcodeSload32:
+		ptr, offset, _ := instr.LoadData()
+		m.lowerExtLoad(op, ptr, offset, instr.Return())
+	case ssa.OpcodeLoadSplat:
+		ptr, offset, lane := instr.LoadSplatData()
+		m.lowerLoadSplat(ptr, offset, lane, instr.Return())
+	case ssa.OpcodeCall, ssa.OpcodeCallIndirect:
+		m.lowerCall(instr)
+	default:
+		if !m.lowerVecInstr(instr) {
+			panic("BUG: the frontend never emits " + op.String())
+		}
+	}
+	m.FlushPendingInstructions()
+}
+
+// InsertMove implements backend.Machine.
+func (m *machine) InsertMove(dst, src regalloc.VReg, typ ssa.Type) {
+	instr := m.allocateInstr()
+	switch typ {
+	case ssa.TypeI32, ssa.TypeI64:
+		instr.asMovRR(src, dst, true)
+	case ssa.TypeF32, ssa.TypeF64, ssa.TypeV128:
+		instr.asXmmUnaryRmR(sseOpcodeMovdqa, newOperandReg(src), dst)
+	default:
+		panic("BUG: invalid type for a move: " + typ.String())
+	}
+	m.insert(instr)
+}
+
+func (m *machine) lowerAluRmiROp(si *ssa.Instruction, op aluRmiROpcode) {
+	x, y := si.Arg2()
+	xDef, yDef := m.compiler.ValueDefinition(x), m.compiler.ValueDefinition(y)
+	if op.commutative() {
+		// Put the constant on the right hand side so that it can be encoded as the immediate.
+		if _, ok := constantOf(xDef); ok {
+			xDef, yDef = yDef, xDef
+		}
+	}
+
+	rn := m.getOperand_Reg(xDef)
+	rm := m.getOperand_Imm32_Reg(yDef)
+	rd := m.compiler.VRegOf(si.Return())
+
+	alu := m.allocateInstr()
+	alu.asAluRmiR(op, rn, rm, rd, x.Type().Bits() == 64)
+	m.insert(alu)
+}
+
+func (m *machine) lowerShiftR(si *ssa.Instruction, op shiftROp) {
+	x, amt := si.Arg2()
+	_64 := x.Type().Bits() == 64
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(si.Return())
+
+	var amount operand
+	amtDef := m.compiler.ValueDefinition(amt)
+	if c, ok := constantOf(amtDef); ok {
+		amtDef.Instr.MarkLowered()
+		amount = newOperandImm32(uint32(c) & uint32(x.Type().Bits()-1))
+	} else {
+		// The variable shift amount must be in rcx. Note that the hardware masks the amount
+		// with the bit width of the operand, which matches the Wasm semantics.
+		m.InsertMove(rcxVReg, m.getOperand_Reg(amtDef), ssa.TypeI64)
+		amount = newOperandReg(rcxVReg)
+	}
+
+	shift := m.allocateInstr()
+	shift.asShiftR(op, rn, amount, rd, _64)
+	m.insert(shift)
+}
+
+func (m *machine) lowerClz(x, result ssa.Value) {
+	_64 := x.Type().Bits() == 64
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(result)
+
+	if platform.CpuFeatures.HasExtra(platform.CpuExtraFeatureABM) {
+		lzcnt := m.allocateInstr()
+		lzcnt.asUnaryRmR(unaryRmROpcodeLzcnt, rn, rd, _64)
+		m.insert(lzcnt)
+		return
+	}
+
+	// Without lzcnt, we use bsr which returns the index of the most significant set bit,
+	// and sets ZF when the source is zero:
+	//
+	//	bsr tmp, x
+	//	mov c, $(2*bits-1)
+	//	cmovz tmp, c
+	//	xor rd, tmp, $(bits-1)
+	//
+	// where the final xor is equivalent to (bits-1)-tmp for tmp in [0, bits-1], and results in bits for the zero case.
+	bits := uint64(x.Type().Bits())
+	tmp := m.compiler.AllocateVReg(x.Type())
+	bsr := m.allocateInstr()
+	bsr.asUnaryRmR(unaryRmROpcodeBsr, rn, tmp, _64)
+	m.insert(bsr)
+
+	c := m.compiler.AllocateVReg(x.Type())
+	m.lowerIconst(c, 2*bits-1, _64)
+
+	adjusted := m.compiler.AllocateVReg(x.Type())
+	cmov := m.allocateInstr()
+	cmov.asCmove(condZ, c, tmp, adjusted, _64)
+	m.insert(cmov)
+
+	xor := m.allocateInstr()
+	xor.asAluRmiR(aluRmiROpcodeXor, adjusted, newOperandImm32(uint32(bits-1)), rd, _64)
+	m.insert(xor)
+}
+
+func (m *machine) lowerCtz(x, result ssa.Value) {
+	_64 := x.Type().Bits() == 64
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(result)
+
+	// bsf returns the index of the least significant set bit, and sets ZF when the source is zero:
+	//
+	//	bsf tmp, x
+	//	mov c, $bits
+	//	cmovz rd, c (otherwise tmp)
+	tmp := m.compiler.AllocateVReg(x.Type())
+	bsf := m.allocateInstr()
+	bsf.asUnaryRmR(unaryRmROpcodeBsf, rn, tmp, _64)
+	m.insert(bsf)
+
+	c := m.compiler.AllocateVReg(x.Type())
+	m.lowerIconst(c, uint64(x.Type().Bits()), _64)
+
+	cmov := m.allocateInstr()
+	cmov.asCmove(condZ, c, tmp, rd, _64)
+	m.insert(cmov)
+}
+
+func (m *machine) lowerIDivRem(si *ssa.Instruction, isDiv, signed bool) {
+	x, y, ctx := si.Arg3()
+	execCtx := m.compiler.VRegOf(ctx)
+	_64 := x.Type().Bits() == 64
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	rd := m.compiler.VRegOf(si.Return())
+
+	// Check the divisor is not zero:
+	//
+	//	test rm, rm
+	//	jnz ok
+	//	exit(ExitCodeIntegerDivisionByZero)
+	// ok:
+	test := m.allocateInstr()
+	test.asCmpRmiR(false, rm, newOperandReg(rm), _64)
+	m.insert(test)
+	m.insert(m.allocateInstr().asExitIf(condZ, execCtx, wazevoapi.ExitCodeIntegerDivisionByZero))
+
+	if isDiv && signed {
+		// The signed division overflows when dividing the minimum value by -1, which raises the
+		// hardware exception. To avoid branching in the middle of the block, we check it as
+		// ((rm + 1) | (rn ^ minInt)) == 0 where both terms are zero iff the overflow happens.
+		plusOne := m.compiler.AllocateVReg(x.Type())
+		add := m.allocateInstr()
+		add.asAluRmiR(aluRmiROpcodeAdd, rm, newOperandImm32(1), plusOne, _64)
+		m.insert(add)
+
+		var minInt operand
+		if _64 {
+			minIntReg := m.compiler.AllocateVReg(ssa.TypeI64)
+			m.lowerIconst(minIntReg, 1<<63, true)
+			minInt = newOperandReg(minIntReg)
+		} else {
+			minInt = newOperandImm32(1 << 31)
+		}
+		isMinInt := m.compiler.AllocateVReg(x.Type())
+		xor := m.allocateInstr()
+		xor.asAluRmiR(aluRmiROpcodeXor, rn, minInt, isMinInt, _64)
+		m.insert(xor)
+
+		or := m.allocateInstr()
+		overflow := m.compiler.AllocateVReg(x.Type())
+		or.asAluRmiR(aluRmiROpcodeOr, plusOne, newOperandReg(isMinInt), overflow, _64)
+		m.insert(or)
+		m.insert(m.allocateInstr().asExitIf(condZ, execCtx, wazevoapi.ExitCodeIntegerOverflow))
+	}
+
+	// The dividend is in rdx:rax.
+	m.InsertMove(raxVReg, rn, ssa.TypeI64)
+	if signed {
+		m.insert(m.allocateInstr().asSignExtendData(_64))
+	} else {
+		m.insert(m.allocateInstr().asImm(rdxVReg, 0, _64))
+	}
+
+	var kind divKind
+	switch {
+	case isDiv && signed:
+		kind = divKindSignedDiv
+	case isDiv && !signed:
+		kind = divKindUnsignedDiv
+	case !isDiv && signed:
+		kind = divKindSignedRem
+	default:
+		kind = divKindUnsignedRem
+	}
+	div := m.allocateInstr()
+	div.asDiv(kind, rm, _64)
+	m.insert(div)
+
+	// The quotient is in rax, and the remainder is in rdx.
+	m.InsertMove(rd, div.dst, ssa.TypeI64)
+}
+
+// lowerIcmpToFlag sets the flags by comparing x and y, i.e. `cmp x, y`.
+func (m *machine) lowerIcmpToFlag(x, y ssa.Value) {
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Imm32_Reg(m.compiler.ValueDefinition(y))
+	cmp := m.allocateInstr()
+	cmp.asCmpRmiR(true, rn, rm, x.Type().Bits() == 64)
+	m.insert(cmp)
+}
+
+func (m *machine) lowerIcmp(si *ssa.Instruction) {
+	x, y, c := si.IcmpData()
+	m.lowerIcmpToFlag(x, y)
+	setcc := m.allocateInstr()
+	setcc.asSetcc(condFromSSAIntCmpCond(c), m.compiler.VRegOf(si.Return()))
+	m.insert(setcc)
+}
+
+// lowerFcmpToFlag sets the flags by comparing x and y with ucomis{s,d}, and returns the condition
+// which holds when the comparison c is true.
+func (m *machine) lowerFcmpToFlag(x, y ssa.Value, c ssa.FloatCmpCond) cond {
+	cc, swap := condFromSSAFloatCmpCond(c)
+	if swap {
+		x, y = y, x
+	}
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	op := sseOpcodeUcomiss
+	if x.Type() == ssa.TypeF64 {
+		op = sseOpcodeUcomisd
+	}
+	cmp := m.allocateInstr()
+	cmp.asXmmCmpRmR(op, rn, rm)
+	m.insert(cmp)
+	return cc
+}
+
+func (m *machine) lowerFcmp(x, y, result ssa.Value, c ssa.FloatCmpCond) {
+	cc := m.lowerFcmpToFlag(x, y, c)
+	rd := m.compiler.VRegOf(result)
+
+	if !cc.isComposite() {
+		setcc := m.allocateInstr()
+		setcc.asSetcc(cc, rd)
+		m.insert(setcc)
+		return
+	}
+
+	// The composite conditions need two setcc instructions:
+	//
+	//	setnp tmp1 ;; or setp
+	//	setz  tmp2 ;; or setnz
+	//	and   rd, tmp1, tmp2 ;; or or
+	c1, c2, op := condNP, condZ, aluRmiROpcodeAnd
+	if cc == condPOrNZ {
+		c1, c2, op = condP, condNZ, aluRmiROpcodeOr
+	}
+	tmp1, tmp2 := m.compiler.AllocateVReg(ssa.TypeI32), m.compiler.AllocateVReg(ssa.TypeI32)
+	set1 := m.allocateInstr()
+	set1.asSetcc(c1, tmp1)
+	m.insert(set1)
+	set2 := m.allocateInstr()
+	set2.asSetcc(c2, tmp2)
+	m.insert(set2)
+	alu := m.allocateInstr()
+	alu.asAluRmiR(op, tmp1, newOperandReg(tmp2), rd, false)
+	m.insert(alu)
+}
+
+func (m *machine) lowerSelect(si *ssa.Instruction) {
+	c, x, y := si.SelectData()
+	cDef := m.compiler.ValueDefinition(c)
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	rd := m.compiler.VRegOf(si.Return())
+
+	var cc cond
+	if m.compiler.MatchInstr(cDef, ssa.OpcodeIcmp) {
+		cx, cy, ic := cDef.Instr.IcmpData()
+		m.lowerIcmpToFlag(cx, cy)
+		cc = condFromSSAIntCmpCond(ic)
+		cDef.Instr.MarkLowered()
+	} else {
+		rc := m.getOperand_Reg(cDef)
+		test := m.allocateInstr()
+		test.asCmpRmiR(false, rc, newOperandReg(rc), c.Type().Bits() == 64)
+		m.insert(test)
+		cc = condNZ
+	}
+
+	sel := m.allocateInstr()
+	if typ := x.Type(); typ.IsInt() {
+		sel.asCmove(cc, rn, rm, rd, typ.Bits() == 64)
+	} else {
+		sel.asXmmCMov(cc, rn, rm, rd)
+	}
+	m.insert(sel)
+}
+
+func (m *machine) lowerExtend(arg, ret ssa.Value, from, to byte, signed bool) {
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(arg))
+	rd := m.compiler.VRegOf(ret)
+
+	var mode extMode
+	switch {
+	case from == 8 && to == 32:
+		mode = extModeBL
+	case from == 8 && to == 64:
+		mode = extModeBQ
+	case from == 16 && to == 32:
+		mode = extModeWL
+	case from == 16 && to == 64:
+		mode = extModeWQ
+	case from == 32 && to == 64:
+		mode = extModeLQ
+	default:
+		panic(fmt.Sprintf("BUG: unsupported extension from %d to %d", from, to))
+	}
+
+	ext := m.allocateInstr()
+	if signed {
+		ext.asMovsxRmR(mode, newOperandReg(rn), rd)
+	} else {
+		ext.asMovzxRmR(mode, newOperandReg(rn), rd)
+	}
+	m.insert(ext)
+}
+
+// lowerXmmBinOp lowers the scalar floating point binary operation, where op32 and op64 are for f32 and f64 respectively.
+func (m *machine) lowerXmmBinOp(si *ssa.Instruction, op32, op64 sseOpcode) {
+	x, y := si.Arg2()
+	op := op32
+	if x.Type() == ssa.TypeF64 {
+		op = op64
+	}
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	m.insertXmmRmR(op, rn, rm, m.compiler.VRegOf(si.Return()))
+}
+
+// lowerXmmUnaryOp lowers the scalar floating point unary operation, where op32 and op64 are
+// for the f32 and f64 source respectively.
+func (m *machine) lowerXmmUnaryOp(si *ssa.Instruction, op32, op64 sseOpcode) {
+	x := si.Arg()
+	op := op32
+	if x.Type() == ssa.TypeF64 {
+		op = op64
+	}
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	m.insertXmmUnary(op, rn, m.compiler.VRegOf(si.Return()))
+}
+
+// roundingMode is the rounding mode encoded in the immediate of round{ss,sd,ps,pd}.
+type roundingMode byte
+
+const (
+	roundingModeNearest roundingMode = iota
+	roundingModeDown
+	roundingModeUp
+	roundingModeZero
+)
+
+func (m *machine) lowerRound(si *ssa.Instruction, mode roundingMode) {
+	x := si.Arg()
+	op := sseOpcodeRoundss
+	if x.Type() == ssa.TypeF64 {
+		op = sseOpcodeRoundsd
+	}
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	m.insertXmmUnaryImm(op, byte(mode), rn, m.compiler.VRegOf(si.Return()))
+}
+
+// lowerFminFmax lowers the Wasm's min/max semantics both for the scalar and vector cases. The packed instructions
+// are used for the scalars as well, since the upper lanes are simply ignored.
+//
+// minps/maxps return the second operand if either of the operands is NaN, or both are zeros regardless of
+// the sign. So we take both orders and merge them: or-ing the results of min gives -0 for min(0, -0), and and-ing
+// the results of max gives 0 for max(0, -0). Finally, the NaN lanes are replaced with x+y which is NaN.
+func (m *machine) lowerFminFmax(x, y, result ssa.Value, isMin, _64 bool) {
+	minMax, merge, add, cmp, and, andn, or := sseOpcodeMaxps, sseOpcodeAndps, sseOpcodeAddps, sseOpcodeCmpps,
+		sseOpcodeAndps, sseOpcodeAndnps, sseOpcodeOrps
+	if _64 {
+		minMax, merge, add, cmp, and, andn, or = sseOpcodeMaxpd, sseOpcodeAndpd, sseOpcodeAddpd, sseOpcodeCmppd,
+			sseOpcodeAndpd, sseOpcodeAndnpd, sseOpcodeOrpd
+	}
+	if isMin {
+		minMax, merge = sseOpcodeMinps, sseOpcodeOrps
+		if _64 {
+			minMax, merge = sseOpcodeMinpd, sseOpcodeOrpd
+		}
+	}
+
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	rd := m.compiler.VRegOf(result)
+
+	t1, t2, t := m.allocateV128(), m.allocateV128(), m.allocateV128()
+	m.insertXmmRmR(minMax, rn, rm, t1)
+	m.insertXmmRmR(minMax, rm, rn, t2)
+	m.insertXmmRmR(merge, t1, t2, t)
+
+	sum, nanMask := m.allocateV128(), m.allocateV128()
+	m.insertXmmRmR(add, rn, rm, sum)
+	m.insertXmmRmRImm(cmp, cmpPredUnord, rn, rm, nanMask)
+
+	nan, notNaN := m.allocateV128(), m.allocateV128()
+	m.insertXmmRmR(and, nanMask, sum, nan)
+	m.insertXmmRmR(andn, nanMask, t, notNaN)
+	m.insertXmmRmR(or, nan, notNaN, rd)
+}
+
+// lowerFabsFneg lowers fabs and fneg by masking the sign bit.
+func (m *machine) lowerFabsFneg(x, result ssa.Value, abs bool) {
+	_64 := x.Type() == ssa.TypeF64
+	var mask uint64
+	switch {
+	case abs && _64:
+		mask = math.MaxInt64
+	case abs && !_64:
+		mask = math.MaxInt32
+	case !abs && _64:
+		mask = 1 << 63
+	default:
+		mask = 1 << 31
+	}
+
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	tmp := m.compiler.AllocateVReg(x.Type())
+	m.lowerFconst(tmp, mask, _64)
+
+	op := sseOpcodeXorps
+	if abs {
+		op = sseOpcodeAndps
+	}
+	m.insertXmmRmR(op, rn, tmp, m.compiler.VRegOf(result))
+}
+
+func (m *machine) lowerFcopysign(x, y, result ssa.Value) {
+	_64 := x.Type() == ssa.TypeF64
+	signMask := uint64(1 << 31)
+	if _64 {
+		signMask = 1 << 63
+	}
+
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	mask := m.compiler.AllocateVReg(x.Type())
+	m.lowerFconst(mask, signMask, _64)
+
+	// rd = (x & ^signMask) | (y & signMask)
+	abs, sign := m.compiler.AllocateVReg(x.Type()), m.compiler.AllocateVReg(x.Type())
+	m.insertXmmRmR(sseOpcodeAndnps, mask, rn, abs)
+	m.insertXmmRmR(sseOpcodeAndps, mask, rm, sign)
+	m.insertXmmRmR(sseOpcodeOrps, abs, sign, m.compiler.VRegOf(result))
+}
+
+func (m *machine) lowerFcvtToInt(x ssa.Value, execCtx regalloc.VReg, result ssa.Value, flags cvtFloatToIntFlag) {
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(result)
+
+	// The conversion sequence clobbers the source, so we copy it to a temporary register.
+	src := m.compiler.AllocateVReg(x.Type())
+	m.InsertMove(src, rn, x.Type())
+
+	cvt := m.allocateInstr()
+	cvt.asCvtFloatToIntSeq(src, execCtx, rd, flags)
+	m.insert(cvt)
+}
+
+func (m *machine) lowerFcvtFromInt(x, result ssa.Value, signed bool) {
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(result)
+	dst64 := result.Type() == ssa.TypeF64
+	op := sseOpcodeCvtsi2ss
+	if dst64 {
+		op = sseOpcodeCvtsi2sd
+	}
+
+	switch {
+	case signed:
+		cvt := m.allocateInstr()
+		cvt.asGprToXmm(op, newOperandReg(rn), rd, x.Type() == ssa.TypeI64)
+		m.insert(cvt)
+	case x.Type() == ssa.TypeI32:
+		// The unsigned 32-bit integer can be converted as the signed 64-bit integer after the zero extension.
+		tmp := m.compiler.AllocateVReg(ssa.TypeI64)
+		ext := m.allocateInstr()
+		ext.asMovzxRmR(extModeLQ, newOperandReg(rn), tmp)
+		m.insert(ext)
+		cvt := m.allocateInstr()
+		cvt.asGprToXmm(op, newOperandReg(tmp), rd, true)
+		m.insert(cvt)
+	default:
+		// The conversion sequence clobbers the source, so we copy it to a temporary register.
+		tmp := m.compiler.AllocateVReg(ssa.TypeI64)
+		m.InsertMove(tmp, rn, ssa.TypeI64)
+		cvt := m.allocateInstr()
+		cvt.asCvtUint64ToFloatSeq(tmp, rd, dst64)
+		m.insert(cvt)
+	}
+}
+
+func (m *machine) lowerBitcast(si *ssa.Instruction) {
+	x, dstType := si.BitcastData()
+	srcType := x.Type()
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(si.Return())
+	_64 := srcType.Bits() == 64
+	op := sseOpcodeMovd
+	if _64 {
+		op = sseOpcodeMovq
+	}
+
+	mov := m.allocateInstr()
+	switch {
+	case srcType.IsInt() && !dstType.IsInt():
+		mov.asGprToXmm(op, newOperandReg(rn), rd, _64)
+	case !srcType.IsInt() && dstType.IsInt():
+		mov.asXmmToGpr(op, rn, rd, _64)
+	default:
+		panic(fmt.Sprintf("BUG: unsupported bitcast from %s to %s", srcType, dstType))
+	}
+	m.insert(mov)
+}
+
+func (m *machine) lowerExitIfTrueWithCode(execCtx regalloc.VReg, c ssa.Value, code wazevoapi.ExitCode) {
+	cDef := m.compiler.ValueDefinition(c)
+	var cc cond
+	if m.compiler.MatchInstr(cDef, ssa.OpcodeIcmp) {
+		x, y, ic := cDef.Instr.IcmpData()
+		m.lowerIcmpToFlag(x, y)
+		cc = condFromSSAIntCmpCond(ic)
+		cDef.Instr.MarkLowered()
+	} else {
+		rc := m.getOperand_Reg(cDef)
+		test := m.allocateInstr()
+		test.asCmpRmiR(false, rc, newOperandReg(rc), c.Type().Bits() == 64)
+		m.insert(test)
+		cc = condNZ
+	}
+	m.insert(m.allocateInstr().asExitIf(cc, execCtx, code))
+}
+
+func (m *machine) allocateV128() regalloc.VReg {
+	return m.compiler.AllocateVReg(ssa.TypeV128)
+}
+
+func (m *machine) insertXmmRmR(op sseOpcode, x, y, dst regalloc.VReg) {
+	instr := m.allocateInstr()
+	instr.asXmmRmR(op, x, y, dst)
+	m.insert(instr)
+}
+
+func (m *machine) insertXmmRmRImm(op sseOpcode, imm byte, x, y, dst regalloc.VReg) {
+	instr := m.allocateInstr()
+	instr.asXmmRmRImm(op, imm, x, y, dst)
+	m.insert(instr)
+}
+
+func (m *machine) insertXmmUnary(op sseOpcode, src, dst regalloc.VReg) {
+	instr := m.allocateInstr()
+	instr.asXmmUnaryRmR(op, newOperandReg(src), dst)
+	m.insert(instr)
+}
+
+func (m *machine) insertXmmUnaryImm(op sseOpcode, imm byte, src, dst regalloc.VReg) {
+	instr := m.allocateInstr()
+	instr.asXmmUnaryRmRImm(op, imm, newOperandReg(src), dst)
+	m.insert(instr)
+}
+
+func (m *machine) insertXmmShift(op sseOpcode, x regalloc.VReg, amount operand, dst regalloc.VReg) {
+	instr := m.allocateInstr()
+	instr.asXmmRmiReg(op, x, amount, dst)
+	m.insert(instr)
+}
@@ -0,0 +1,62 @@
+package amd64
+
+import (
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend"
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+)
+
+// getOperand_Reg returns the register holding the value defined by def.
+// Constant instructions are inlined, i.e. they are materialized into a new register right here.
+func (m *machine) getOperand_Reg(def *backend.SSAValueDefinition) regalloc.VReg {
+	if def.IsFromBlockParam() {
+		return def.BlkParamVReg
+	}
+
+	instr := def.Instr
+	if instr.Constant() {
+		// We inline all the constant instructions so that we could reduce the register usage.
+		v := m.lowerConstant(instr)
+		instr.MarkLowered()
+		return v
+	}
+
+	if n := def.N; n == 0 {
+		return m.compiler.VRegOf(instr.Return())
+	} else {
+		_, rs := instr.Returns()
+		return m.compiler.VRegOf(rs[n-1])
+	}
+}
+
+// getOperand_Imm32_Reg returns the 32-bit immediate operand if the value defined by def is an integer constant
+// which can be encoded as the (sign-extended) 32-bit immediate, otherwise the register operand.
+func (m *machine) getOperand_Imm32_Reg(def *backend.SSAValueDefinition) operand {
+	if def.IsFromInstr() && def.Instr.Constant() {
+		instr := def.Instr
+		if v, ok := asImm32(instr.ConstantVal(), instr.Return().Type()); ok && instr.Return().Type().IsInt() {
+			instr.MarkLowered()
+			return newOperandImm32(v)
+		}
+	}
+	return newOperandReg(m.getOperand_Reg(def))
+}
+
+// asImm32 returns the constant as the 32-bit immediate if it can be encoded as such for the given type.
+func asImm32(v uint64, typ ssa.Type) (uint32, bool) {
+	if typ == ssa.TypeI32 {
+		return uint32(v), true
+	}
+	if fitsInImm32(int64(v)) {
+		return uint32(v), true
+	}
+	return 0, false
+}
+
+// constantOf returns the constant value of the value defined by def if it is an integer constant.
+func constantOf(def *backend.SSAValueDefinition) (uint64, bool) {
+	if def.IsFromInstr() && def.Instr.Constant() && def.Instr.Return().Type().IsInt() {
+		return def.Instr.ConstantVal(), true
+	}
+	return 0, false
+}
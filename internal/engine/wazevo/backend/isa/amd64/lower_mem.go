@@ -0,0 +1,130 @@
+package amd64
+
+import (
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+)
+
+// lowerToAddressMode converts the pointer and the constant offset into the address mode.
+// If the pointer is the result of Iadd, the addition is merged into the address mode.
+func (m *machine) lowerToAddressMode(ptr ssa.Value, offsetBase uint32) *amode {
+	offset := int64(offsetBase)
+	def := m.compiler.ValueDefinition(ptr)
+	if m.compiler.MatchInstr(def, ssa.OpcodeIadd) {
+		x, y := def.Instr.Arg2()
+		xDef, yDef := m.compiler.ValueDefinition(x), m.compiler.ValueDefinition(y)
+		if c, ok := constantOf(yDef); ok && fitsInImm32(offset+int64(c)) {
+			def.Instr.MarkLowered()
+			yDef.Instr.MarkLowered()
+			return m.newAmodeImmReg(uint32(offset+int64(c)), m.getOperand_Reg(xDef))
+		} else if c, ok := constantOf(xDef); ok && fitsInImm32(offset+int64(c)) {
+			def.Instr.MarkLowered()
+			xDef.Instr.MarkLowered()
+			return m.newAmodeImmReg(uint32(offset+int64(c)), m.getOperand_Reg(yDef))
+		} else if fitsInImm32(offset) {
+			def.Instr.MarkLowered()
+			return m.newAmodeRegRegShift(uint32(offset), m.getOperand_Reg(xDef), m.getOperand_Reg(yDef), 0)
+		}
+	}
+
+	base := m.getOperand_Reg(def)
+	if fitsInImm32(offset) {
+		return m.newAmodeImmReg(uint32(offset), base)
+	}
+
+	// The offset doesn't fit in the 32-bit displacement, so we add it to the base explicitly.
+	tmp := m.compiler.AllocateVReg(ssa.TypeI64)
+	m.lowerIconst(tmp, uint64(offset), true)
+	added := m.compiler.AllocateVReg(ssa.TypeI64)
+	add := m.allocateInstr()
+	add.asAluRmiR(aluRmiROpcodeAdd, base, newOperandReg(tmp), added, true)
+	m.insert(add)
+	return m.newAmodeImmReg(0, added)
+}
+
+func (m *machine) lowerLoad(ptr ssa.Value, offset uint32, typ ssa.Type, dst ssa.Value) {
+	mem := m.lowerToAddressMode(ptr, offset)
+	m.insert(m.allocateLoad(typ, mem, m.compiler.VRegOf(dst)))
+}
+
+func (m *machine) lowerExtLoad(op ssa.Opcode, ptr ssa.Value, offset uint32, dst ssa.Value) {
+	mem := newOperandMem(m.lowerToAddressMode(ptr, offset))
+	rd := m.compiler.VRegOf(dst)
+	_64 := dst.Type() == ssa.TypeI64
+
+	load := m.allocateInstr()
+	switch op {
+	case ssa.OpcodeUload8:
+		load.asMovzxRmR(extModeBQ, mem, rd)
+	case ssa.OpcodeUload16:
+		load.asMovzxRmR(extModeWQ, mem, rd)
+	case ssa.OpcodeUload32:
+		load.asMovzxRmR(extModeLQ, mem, rd)
+	case ssa.OpcodeSload8:
+		if _64 {
+			load.asMovsxRmR(extModeBQ, mem, rd)
+		} else {
+			load.asMovsxRmR(extModeBL, mem, rd)
+		}
+	case ssa.OpcodeSload16:
+		if _64 {
+			load.asMovsxRmR(extModeWQ, mem, rd)
+		} else {
+			load.asMovsxRmR(extModeWL, mem, rd)
+		}
+	case ssa.OpcodeSload32:
+		load.asMovsxRmR(extModeLQ, mem, rd)
+	default:
+		panic("BUG")
+	}
+	m.insert(load)
+}
+
+func (m *machine) lowerStore(si *ssa.Instruction) {
+	value, ptr, offset, storeSizeInBits := si.StoreData()
+	valueDef := m.compiler.ValueDefinition(value)
+	mem := m.lowerToAddressMode(ptr, offset)
+
+	store := m.allocateInstr()
+	switch value.Type() {
+	case ssa.TypeI32, ssa.TypeI64:
+		store.asMovRM(m.getOperand_Imm32_Reg(valueDef), mem, storeSizeInBits/8)
+	case ssa.TypeF32:
+		store.asXmmMovRM(sseOpcodeMovss, m.getOperand_Reg(valueDef), mem)
+	case ssa.TypeF64:
+		store.asXmmMovRM(sseOpcodeMovsd, m.getOperand_Reg(valueDef), mem)
+	case ssa.TypeV128:
+		store.asXmmMovRM(sseOpcodeMovdqu, m.getOperand_Reg(valueDef), mem)
+	default:
+		panic("BUG")
+	}
+	m.insert(store)
+}
+
+// lowerLoadSplat loads the value of the lane size at the given address, and splats it into all the lanes of dst.
+func (m *machine) lowerLoadSplat(ptr ssa.Value, offset uint32, lane ssa.VecLane, dst ssa.Value) {
+	mem := newOperandMem(m.lowerToAddressMode(ptr, offset))
+	rd := m.compiler.VRegOf(dst)
+
+	var typ ssa.Type
+	load := m.allocateInstr()
+	switch lane {
+	case ssa.VecLaneI8x16:
+		typ = ssa.TypeI32
+		load.asMovzxRmR(extModeBL, mem, 0)
+	case ssa.VecLaneI16x8:
+		typ = ssa.TypeI32
+		load.asMovzxRmR(extModeWL, mem, 0)
+	case ssa.VecLaneI32x4:
+		typ = ssa.TypeI32
+		load.asMovzxRmR(extModeLQ, mem, 0)
+	case ssa.VecLaneI64x2:
+		typ = ssa.TypeI64
+		load.asMov64MR(mem.amode, 0)
+	default:
+		panic("BUG: unsupported lane " + lane.String())
+	}
+	tmp := m.compiler.AllocateVReg(typ)
+	load.dst = tmp
+	m.insert(load)
+	m.lowerSplatFromGpr(lane, tmp, rd)
+}
@@ -0,0 +1,877 @@
+package amd64
+
+import (
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+)
+
+// The predicates encoded in the immediate of cmp{ps,pd}.
+const (
+	cmpPredEq    byte = 0
+	cmpPredLt    byte = 1
+	cmpPredLe    byte = 2
+	cmpPredUnord byte = 3
+	cmpPredNeq   byte = 4
+)
+
+// lowerVecInstr lowers the vector instruction, and returns false if the instruction is not a vector one.
+func (m *machine) lowerVecInstr(instr *ssa.Instruction) bool {
+	switch op := instr.Opcode(); op {
+	case ssa.OpcodeVconst:
+		lo, hi := instr.VconstData()
+		m.lowerVconst(m.compiler.VRegOf(instr.Return()), lo, hi)
+	case ssa.OpcodeVbnot:
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(instr.Arg()))
+		ones := m.allocateOnes()
+		m.insertXmmRmR(sseOpcodePxor, rn, ones, m.compiler.VRegOf(instr.Return()))
+	case ssa.OpcodeVband:
+		m.lowerVecBinOp(instr, sseOpcodePand)
+	case ssa.OpcodeVbor:
+		m.lowerVecBinOp(instr, sseOpcodePor)
+	case ssa.OpcodeVbxor:
+		m.lowerVecBinOp(instr, sseOpcodePxor)
+	case ssa.OpcodeVbandnot:
+		// pandn computes ^op1 & op2, so x & ^y is pandn(y, x).
+		x, y := instr.Arg2()
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+		rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+		m.insertXmmRmR(sseOpcodePandn, rm, rn, m.compiler.VRegOf(instr.Return()))
+	case ssa.OpcodeVbitselect:
+		c, x, y := instr.SelectData()
+		rc := m.getOperand_Reg(m.compiler.ValueDefinition(c))
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+		rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+		// rd = (x & c) | (y & ^c)
+		t1, t2 := m.allocateV128(), m.allocateV128()
+		m.insertXmmRmR(sseOpcodePand, rn, rc, t1)
+		m.insertXmmRmR(sseOpcodePandn, rc, rm, t2)
+		m.insertXmmRmR(sseOpcodePor, t1, t2, m.compiler.VRegOf(instr.Return()))
+	case ssa.OpcodeVanyTrue, ssa.OpcodeVallTrue:
+		x, lane := instr.ArgWithLane()
+		m.lowerVcheckTrue(op, x, lane, instr.Return())
+	case ssa.OpcodeVhighBits:
+		x, lane := instr.ArgWithLane()
+		m.lowerVhighBits(x, lane, instr.Return())
+	case ssa.OpcodeVIadd:
+		m.lowerVecLaneBinOp(instr, sseOpcodePaddb, sseOpcodePaddw, sseOpcodePaddd, sseOpcodePaddq)
+	case ssa.OpcodeVIsub:
+		m.lowerVecLaneBinOp(instr, sseOpcodePsubb, sseOpcodePsubw, sseOpcodePsubd, sseOpcodePsubq)
+	case ssa.OpcodeVSaddSat:
+		m.lowerVecLaneBinOp(instr, sseOpcodePaddsb, sseOpcodePaddsw, sseOpcodeInvalid, sseOpcodeInvalid)
+	case ssa.OpcodeVUaddSat:
+		m.lowerVecLaneBinOp(instr, sseOpcodePaddusb, sseOpcodePaddusw, sseOpcodeInvalid, sseOpcodeInvalid)
+	case ssa.OpcodeVSsubSat:
+		m.lowerVecLaneBinOp(instr, sseOpcodePsubsb, sseOpcodePsubsw, sseOpcodeInvalid, sseOpcodeInvalid)
+	case ssa.OpcodeVUsubSat:
+		m.lowerVecLaneBinOp(instr, sseOpcodePsubusb, sseOpcodePsubusw, sseOpcodeInvalid, sseOpcodeInvalid)
+	case ssa.OpcodeVImin:
+		m.lowerVecLaneBinOp(instr, sseOpcodePminsb, sseOpcodePminsw, sseOpcodePminsd, sseOpcodeInvalid)
+	case ssa.OpcodeVUmin:
+		m.lowerVecLaneBinOp(instr, sseOpcodePminub, sseOpcodePminuw, sseOpcodePminud, sseOpcodeInvalid)
+	case ssa.OpcodeVImax:
+		m.lowerVecLaneBinOp(instr, sseOpcodePmaxsb, sseOpcodePmaxsw, sseOpcodePmaxsd, sseOpcodeInvalid)
+	case ssa.OpcodeVUmax:
+		m.lowerVecLaneBinOp(instr, sseOpcodePmaxub, sseOpcodePmaxuw, sseOpcodePmaxud, sseOpcodeInvalid)
+	case ssa.OpcodeVAvgRound:
+		m.lowerVecLaneBinOp(instr, sseOpcodePavgb, sseOpcodePavgw, sseOpcodeInvalid, sseOpcodeInvalid)
+	case ssa.OpcodeIaddPairwise:
+		m.lowerVecLaneBinOp(instr, sseOpcodeInvalid, sseOpcodePhaddw, sseOpcodePhaddd, sseOpcodeInvalid)
+	case ssa.OpcodeVImul:
+		_, _, lane := instr.Arg2WithLane()
+		if lane == ssa.VecLaneI64x2 {
+			m.lowerVImul64x2(instr)
+		} else {
+			m.lowerVecLaneBinOp(instr, sseOpcodeInvalid, sseOpcodePmullw, sseOpcodePmulld, sseOpcodeInvalid)
+		}
+	case ssa.OpcodeVIabs:
+		m.lowerVIabs(instr)
+	case ssa.OpcodeVIneg:
+		x, lane := instr.ArgWithLane()
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+		zero := m.allocateV128()
+		m.insert(m.allocateInstr().asXmmSelfOp(sseOpcodePxor, zero))
+		op := laneOpcode(lane, sseOpcodePsubb, sseOpcodePsubw, sseOpcodePsubd, sseOpcodePsubq)
+		m.insertXmmRmR(op, zero, rn, m.compiler.VRegOf(instr.Return()))
+	case ssa.OpcodeVIpopcnt:
+		m.lowerVIpopcnt(instr)
+	case ssa.OpcodeVIshl, ssa.OpcodeVSshr, ssa.OpcodeVUshr:
+		m.lowerVShift(instr)
+	case ssa.OpcodeVIcmp:
+		m.lowerVIcmp(instr)
+	case ssa.OpcodeVFcmp:
+		m.lowerVFcmp(instr)
+	case ssa.OpcodeVFadd:
+		m.lowerVecLaneBinOp(instr, sseOpcodeAddps, sseOpcodeAddpd, sseOpcodeInvalid, sseOpcodeInvalid)
+	case ssa.OpcodeVFsub:
+		m.lowerVecLaneBinOp(instr, sseOpcodeSubps, sseOpcodeSubpd, sseOpcodeInvalid, sseOpcodeInvalid)
+	case ssa.OpcodeVFmul:
+		m.lowerVecLaneBinOp(instr, sseOpcodeMulps, sseOpcodeMulpd, sseOpcodeInvalid, sseOpcodeInvalid)
+	case ssa.OpcodeVFdiv:
+		m.lowerVecLaneBinOp(instr, sseOpcodeDivps, sseOpcodeDivpd, sseOpcodeInvalid, sseOpcodeInvalid)
+	case ssa.OpcodeVFmin, ssa.OpcodeVFmax:
+		x, y, lane := instr.Arg2WithLane()
+		m.lowerFminFmax(x, y, instr.Return(), op == ssa.OpcodeVFmin, lane == ssa.VecLaneF64x2)
+	case ssa.OpcodeVMinPseudo, ssa.OpcodeVMaxPseudo:
+		// pmin(x, y) = y < x ? y : x, which is exactly minps(y, x), and the same goes for pmax.
+		x, y, lane := instr.Arg2WithLane()
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+		rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+		var vop sseOpcode
+		if op == ssa.OpcodeVMinPseudo {
+			vop = laneOpcode(lane, sseOpcodeMinps, sseOpcodeMinpd, sseOpcodeInvalid, sseOpcodeInvalid)
+		} else {
+			vop = laneOpcode(lane, sseOpcodeMaxps, sseOpcodeMaxpd, sseOpcodeInvalid, sseOpcodeInvalid)
+		}
+		m.insertXmmRmR(vop, rm, rn, m.compiler.VRegOf(instr.Return()))
+	case ssa.OpcodeVSqrt:
+		x, lane := instr.ArgWithLane()
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+		vop := laneOpcode(lane, sseOpcodeSqrtps, sseOpcodeSqrtpd, sseOpcodeInvalid, sseOpcodeInvalid)
+		m.insertXmmUnary(vop, rn, m.compiler.VRegOf(instr.Return()))
+	case ssa.OpcodeVFabs, ssa.OpcodeVFneg:
+		x, lane := instr.ArgWithLane()
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+		var mask uint64
+		switch {
+		case op == ssa.OpcodeVFabs && lane == ssa.VecLaneF32x4:
+			mask = 0x7fffffff_7fffffff
+		case op == ssa.OpcodeVFabs && lane == ssa.VecLaneF64x2:
+			mask = 0x7fffffff_ffffffff
+		case op == ssa.OpcodeVFneg && lane == ssa.VecLaneF32x4:
+			mask = 0x80000000_80000000
+		default:
+			mask = 0x80000000_00000000
+		}
+		tmp := m.allocateV128()
+		m.lowerVconst(tmp, mask, mask)
+		vop := sseOpcodeXorps
+		if op == ssa.OpcodeVFabs {
+			vop = sseOpcodeAndps
+		}
+		m.insertXmmRmR(vop, rn, tmp, m.compiler.VRegOf(instr.Return()))
+	case ssa.OpcodeVCeil:
+		m.lowerVRound(instr, roundingModeUp)
+	case ssa.OpcodeVFloor:
+		m.lowerVRound(instr, roundingModeDown)
+	case ssa.OpcodeVTrunc:
+		m.lowerVRound(instr, roundingModeZero)
+	case ssa.OpcodeVNearest:
+		m.lowerVRound(instr, roundingModeNearest)
+	case ssa.OpcodeSqmulRoundSat:
+		m.lowerSqmulRoundSat(instr)
+	case ssa.OpcodeVFcvtToSintSat, ssa.OpcodeVFcvtToUintSat:
+		x, lane := instr.ArgWithLane()
+		m.lowerVFcvtToIntSat(x, lane, instr.Return(), op == ssa.OpcodeVFcvtToSintSat)
+	case ssa.OpcodeVFcvtFromSint, ssa.OpcodeVFcvtFromUint:
+		x, lane := instr.ArgWithLane()
+		m.lowerVFcvtFromInt(x, lane, instr.Return(), op == ssa.OpcodeVFcvtFromSint)
+	case ssa.OpcodeSwidenLow, ssa.OpcodeUwidenLow, ssa.OpcodeSwidenHigh, ssa.OpcodeUwidenHigh:
+		x, lane := instr.ArgWithLane()
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+		var vop sseOpcode
+		if op == ssa.OpcodeSwidenLow || op == ssa.OpcodeSwidenHigh {
+			vop = laneOpcode(lane, sseOpcodePmovsxbw, sseOpcodePmovsxwd, sseOpcodePmovsxdq, sseOpcodeInvalid)
+		} else {
+			vop = laneOpcode(lane, sseOpcodePmovzxbw, sseOpcodePmovzxwd, sseOpcodePmovzxdq, sseOpcodeInvalid)
+		}
+		if op == ssa.OpcodeSwidenHigh || op == ssa.OpcodeUwidenHigh {
+			// Move the higher 64 bits into the lower half.
+			tmp := m.allocateV128()
+			m.insertXmmUnaryImm(sseOpcodePshufd, 0b11_10_11_10, rn, tmp)
+			rn = tmp
+		}
+		m.insertXmmUnary(vop, rn, m.compiler.VRegOf(instr.Return()))
+	case ssa.OpcodeSnarrow:
+		m.lowerVecLaneBinOp(instr, sseOpcodeInvalid, sseOpcodePacksswb, sseOpcodePackssdw, sseOpcodeInvalid)
+	case ssa.OpcodeUnarrow:
+		m.lowerVecLaneBinOp(instr, sseOpcodeInvalid, sseOpcodePackuswb, sseOpcodePackusdw, sseOpcodeInvalid)
+	case ssa.OpcodeFvpromoteLow:
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(instr.Arg()))
+		m.insertXmmUnary(sseOpcodeCvtps2pd, rn, m.compiler.VRegOf(instr.Return()))
+	case ssa.OpcodeFvdemote:
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(instr.Arg()))
+		m.insertXmmUnary(sseOpcodeCvtpd2ps, rn, m.compiler.VRegOf(instr.Return()))
+	case ssa.OpcodeExtractlane:
+		m.lowerExtractlane(instr)
+	case ssa.OpcodeInsertlane:
+		m.lowerInsertlane(instr)
+	case ssa.OpcodeSwizzle:
+		x, y, _ := instr.Arg2WithLane()
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+		rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+		// pshufb zeros the lane only when the most significant bit of the index is set, so the
+		// out-of-range indices (>= 16) are saturated into [0x80, 0xff] by adding 0x70.
+		c, idx := m.allocateV128(), m.allocateV128()
+		m.lowerVconst(c, 0x70707070_70707070, 0x70707070_70707070)
+		m.insertXmmRmR(sseOpcodePaddusb, rm, c, idx)
+		m.insertXmmRmR(sseOpcodePshufb, rn, idx, m.compiler.VRegOf(instr.Return()))
+	case ssa.OpcodeShuffle:
+		x, y, lo, hi := instr.ShuffleData()
+		m.lowerShuffle(x, y, lo, hi, instr.Return())
+	case ssa.OpcodeSplat:
+		x, lane := instr.ArgWithLane()
+		rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+		rd := m.compiler.VRegOf(instr.Return())
+		switch lane {
+		case ssa.VecLaneF32x4:
+			m.insertXmmUnaryImm(sseOpcodePshufd, 0, rn, rd)
+		case ssa.VecLaneF64x2:
+			m.insertXmmUnaryImm(sseOpcodePshufd, 0b01_00_01_00, rn, rd)
+		default:
+			m.lowerSplatFromGpr(lane, rn, rd)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// laneOpcode returns the opcode for the given lane. For integer lanes, b, w, d and q correspond to I8x16, I16x8,
+// I32x4 and I64x2 respectively, while for float lanes, b and w correspond to F32x4 and F64x2.
+func laneOpcode(lane ssa.VecLane, b, w, d, q sseOpcode) (op sseOpcode) {
+	switch lane {
+	case ssa.VecLaneI8x16, ssa.VecLaneF32x4:
+		op = b
+	case ssa.VecLaneI16x8, ssa.VecLaneF64x2:
+		op = w
+	case ssa.VecLaneI32x4:
+		op = d
+	case ssa.VecLaneI64x2:
+		op = q
+	}
+	if op == sseOpcodeInvalid {
+		panic("BUG: unsupported lane " + lane.String())
+	}
+	return
+}
+
+func (m *machine) lowerVecBinOp(si *ssa.Instruction, op sseOpcode) {
+	x, y := si.Arg2()
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	m.insertXmmRmR(op, rn, rm, m.compiler.VRegOf(si.Return()))
+}
+
+func (m *machine) lowerVecLaneBinOp(si *ssa.Instruction, b, w, d, q sseOpcode) {
+	_, _, lane := si.Arg2WithLane()
+	m.lowerVecBinOp(si, laneOpcode(lane, b, w, d, q))
+}
+
+// allocateOnes returns the new register whose bits are all set.
+func (m *machine) allocateOnes() regalloc.VReg {
+	ones := m.allocateV128()
+	m.insert(m.allocateInstr().asXmmSelfOp(sseOpcodePcmpeqd, ones))
+	return ones
+}
+
+func (m *machine) lowerVcheckTrue(op ssa.Opcode, x ssa.Value, lane ssa.VecLane, ret ssa.Value) {
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(ret)
+
+	if op == ssa.OpcodeVanyTrue {
+		//	ptest x, x
+		//	setnz rd
+		test := m.allocateInstr()
+		test.asXmmCmpRmR(sseOpcodePtest, rn, rn)
+		m.insert(test)
+		m.insert(m.allocateInstr().asSetcc(condNZ, rd))
+		return
+	}
+
+	// All lanes are true iff no lane equals zero:
+	//
+	//	pxor zero, zero
+	//	pcmpeq{b,w,d,q} tmp, x, zero
+	//	ptest tmp, tmp
+	//	setz rd
+	zero, tmp := m.allocateV128(), m.allocateV128()
+	m.insert(m.allocateInstr().asXmmSelfOp(sseOpcodePxor, zero))
+	cmp := laneOpcode(lane, sseOpcodePcmpeqb, sseOpcodePcmpeqw, sseOpcodePcmpeqd, sseOpcodePcmpeqq)
+	m.insertXmmRmR(cmp, rn, zero, tmp)
+	test := m.allocateInstr()
+	test.asXmmCmpRmR(sseOpcodePtest, tmp, tmp)
+	m.insert(test)
+	m.insert(m.allocateInstr().asSetcc(condZ, rd))
+}
+
+func (m *machine) lowerVhighBits(x ssa.Value, lane ssa.VecLane, ret ssa.Value) {
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(ret)
+
+	mov := m.allocateInstr()
+	switch lane {
+	case ssa.VecLaneI8x16:
+		mov.asXmmToGpr(sseOpcodePmovmskb, rn, rd, false)
+	case ssa.VecLaneI16x8:
+		// There's no pmovmskw, so we pack the words into bytes with the signed saturation which preserves the signs.
+		// Then the mask of the lower 8 bytes is the same as the higher 8 bytes:
+		//
+		//	packsswb tmp, x, x
+		//	pmovmskb tmp2, tmp
+		//	shr rd, tmp2, 8
+		tmp := m.allocateV128()
+		m.insertXmmRmR(sseOpcodePacksswb, rn, rn, tmp)
+		tmp2 := m.compiler.AllocateVReg(ssa.TypeI32)
+		mask := m.allocateInstr()
+		mask.asXmmToGpr(sseOpcodePmovmskb, tmp, tmp2, false)
+		m.insert(mask)
+		mov.asShiftR(shiftROpShiftRightLogical, tmp2, newOperandImm32(8), rd, false)
+	case ssa.VecLaneI32x4:
+		mov.asXmmToGpr(sseOpcodeMovmskps, rn, rd, false)
+	case ssa.VecLaneI64x2:
+		mov.asXmmToGpr(sseOpcodeMovmskpd, rn, rd, false)
+	default:
+		panic("BUG: unsupported lane " + lane.String())
+	}
+	m.insert(mov)
+}
+
+// lowerVImul64x2 lowers the 64-bit lane-wise multiplication, which doesn't exist in SSE. With x = xh*2^32 + xl
+// and y = yh*2^32 + yl, the lower 64 bits of x*y is xl*yl + ((xh*yl + xl*yh) << 32).
+func (m *machine) lowerVImul64x2(si *ssa.Instruction) {
+	x, y, _ := si.Arg2WithLane()
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	rd := m.compiler.VRegOf(si.Return())
+
+	xh, yh := m.allocateV128(), m.allocateV128()
+	m.insertXmmShift(sseOpcodePsrlq, rn, newOperandImm32(32), xh)
+	m.insertXmmShift(sseOpcodePsrlq, rm, newOperandImm32(32), yh)
+
+	xhyl, xlyh := m.allocateV128(), m.allocateV128()
+	m.insertXmmRmR(sseOpcodePmuludq, xh, rm, xhyl)
+	m.insertXmmRmR(sseOpcodePmuludq, yh, rn, xlyh)
+
+	high, highShifted := m.allocateV128(), m.allocateV128()
+	m.insertXmmRmR(sseOpcodePaddq, xhyl, xlyh, high)
+	m.insertXmmShift(sseOpcodePsllq, high, newOperandImm32(32), highShifted)
+
+	low := m.allocateV128()
+	m.insertXmmRmR(sseOpcodePmuludq, rn, rm, low)
+	m.insertXmmRmR(sseOpcodePaddq, low, highShifted, rd)
+}
+
+func (m *machine) lowerVIabs(si *ssa.Instruction) {
+	x, lane := si.ArgWithLane()
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(si.Return())
+
+	if lane != ssa.VecLaneI64x2 {
+		m.insertXmmUnary(laneOpcode(lane, sseOpcodePabsb, sseOpcodePabsw, sseOpcodePabsd, sseOpcodeInvalid), rn, rd)
+		return
+	}
+
+	// There's no pabsq, so we compute (x ^ sign) - sign where sign is all ones for the negative lanes:
+	//
+	//	psrad tmp, x, 31
+	//	pshufd sign, tmp, 0b11_11_01_01 ;; broadcast the sign of the higher 32 bits.
+	//	pxor tmp2, x, sign
+	//	psubq rd, tmp2, sign
+	tmp, sign, tmp2 := m.allocateV128(), m.allocateV128(), m.allocateV128()
+	m.insertXmmShift(sseOpcodePsrad, rn, newOperandImm32(31), tmp)
+	m.insertXmmUnaryImm(sseOpcodePshufd, 0b11_11_01_01, tmp, sign)
+	m.insertXmmRmR(sseOpcodePxor, rn, sign, tmp2)
+	m.insertXmmRmR(sseOpcodePsubq, tmp2, sign, rd)
+}
+
+// lowerVIpopcnt counts the bits of each byte by looking up the table of nibbles with pshufb.
+func (m *machine) lowerVIpopcnt(si *ssa.Instruction) {
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(si.Arg()))
+	rd := m.compiler.VRegOf(si.Return())
+
+	mask, table := m.allocateV128(), m.allocateV128()
+	m.lowerVconst(mask, 0x0f0f0f0f_0f0f0f0f, 0x0f0f0f0f_0f0f0f0f)
+	m.lowerVconst(table, 0x03020201_02010100, 0x04030302_03020201)
+
+	lo, hiShifted, hi := m.allocateV128(), m.allocateV128(), m.allocateV128()
+	m.insertXmmRmR(sseOpcodePand, rn, mask, lo)
+	m.insertXmmShift(sseOpcodePsrlw, rn, newOperandImm32(4), hiShifted)
+	m.insertXmmRmR(sseOpcodePand, hiShifted, mask, hi)
+
+	loCnt, hiCnt := m.allocateV128(), m.allocateV128()
+	m.insertXmmRmR(sseOpcodePshufb, table, lo, loCnt)
+	m.insertXmmRmR(sseOpcodePshufb, table, hi, hiCnt)
+	m.insertXmmRmR(sseOpcodePaddb, loCnt, hiCnt, rd)
+}
+
+func (m *machine) lowerVShift(si *ssa.Instruction) {
+	op := si.Opcode()
+	x, y, lane := si.Arg2WithLane()
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(si.Return())
+
+	var laneBits uint32
+	switch lane {
+	case ssa.VecLaneI8x16:
+		laneBits = 8
+	case ssa.VecLaneI16x8:
+		laneBits = 16
+	case ssa.VecLaneI32x4:
+		laneBits = 32
+	case ssa.VecLaneI64x2:
+		laneBits = 64
+	default:
+		panic("BUG: unsupported lane " + lane.String())
+	}
+
+	// The shift amount is taken modulo the lane width in Wasm, while SSE shifts saturate it.
+	// amountOf returns the operand holding (y % laneBits) + add.
+	yDef := m.compiler.ValueDefinition(y)
+	amountOf := func(add uint32) operand {
+		if c, ok := constantOf(yDef); ok {
+			yDef.Instr.MarkLowered()
+			return newOperandImm32(uint32(c)&(laneBits-1) + add)
+		}
+		masked := m.compiler.AllocateVReg(ssa.TypeI32)
+		and := m.allocateInstr()
+		and.asAluRmiR(aluRmiROpcodeAnd, m.getOperand_Reg(yDef), newOperandImm32(laneBits-1), masked, false)
+		m.insert(and)
+		if add > 0 {
+			added := m.compiler.AllocateVReg(ssa.TypeI32)
+			alu := m.allocateInstr()
+			alu.asAluRmiR(aluRmiROpcodeAdd, masked, newOperandImm32(add), added, false)
+			m.insert(alu)
+			masked = added
+		}
+		amt := m.allocateV128()
+		mov := m.allocateInstr()
+		mov.asGprToXmm(sseOpcodeMovd, newOperandReg(masked), amt, false)
+		m.insert(mov)
+		return newOperandReg(amt)
+	}
+
+	switch {
+	case lane == ssa.VecLaneI8x16 && op == ssa.OpcodeVSshr:
+		// Unpack each byte into the higher byte of the 16-bit lane, do the arithmetic shift by (amount+8), and pack them again:
+		//
+		//	punpcklbw lo, x, x
+		//	punpckhbw hi, x, x
+		//	psraw lo, amount+8
+		//	psraw hi, amount+8
+		//	packsswb rd, lo, hi
+		amount := amountOf(8)
+		lo, hi, loShifted, hiShifted := m.allocateV128(), m.allocateV128(), m.allocateV128(), m.allocateV128()
+		m.insertXmmRmR(sseOpcodePunpcklbw, rn, rn, lo)
+		m.insertXmmRmR(sseOpcodePunpckhbw, rn, rn, hi)
+		m.insertXmmShift(sseOpcodePsraw, lo, amount, loShifted)
+		m.insertXmmShift(sseOpcodePsraw, hi, amount, hiShifted)
+		m.insertXmmRmR(sseOpcodePacksswb, loShifted, hiShifted, rd)
+	case lane == ssa.VecLaneI8x16:
+		// Shift the 16-bit lanes, and clear the bits shifted in from the adjacent bytes with the mask
+		// which is computed by shifting 0x00ff in each 16-bit lane, then broadcasting its lower byte.
+		vop := sseOpcodePsllw
+		if op == ssa.OpcodeVUshr {
+			vop = sseOpcodePsrlw
+		}
+		amount := amountOf(0)
+		mask := m.allocateV128()
+		if amount.kind == operandKindImm32 {
+			b := uint64(0xff)
+			if op == ssa.OpcodeVIshl {
+				b = (b << amount.imm32()) & 0xff
+			} else {
+				b >>= amount.imm32()
+			}
+			b *= 0x01010101_01010101
+			m.lowerVconst(mask, b, b)
+		} else {
+			ones, lowBytes, shifted, zero := m.allocateOnes(), m.allocateV128(), m.allocateV128(), m.allocateV128()
+			m.insertXmmShift(sseOpcodePsrlw, ones, newOperandImm32(8), lowBytes)
+			m.insertXmmShift(vop, lowBytes, amount, shifted)
+			m.insert(m.allocateInstr().asXmmSelfOp(sseOpcodePxor, zero))
+			m.insertXmmRmR(sseOpcodePshufb, shifted, zero, mask)
+		}
+		tmp := m.allocateV128()
+		m.insertXmmShift(vop, rn, amount, tmp)
+		m.insertXmmRmR(sseOpcodePand, tmp, mask, rd)
+	case lane == ssa.VecLaneI64x2 && op == ssa.OpcodeVSshr:
+		// There's no psraq, so we use the identity sshr(x, n) = (ushr(x, n) ^ m) - m where m = ushr(1<<63, n).
+		amount := amountOf(0)
+		signBit, mask := m.allocateV128(), m.allocateV128()
+		m.lowerVconst(signBit, 1<<63, 1<<63)
+		m.insertXmmShift(sseOpcodePsrlq, signBit, amount, mask)
+		shifted, xored := m.allocateV128(), m.allocateV128()
+		m.insertXmmShift(sseOpcodePsrlq, rn, amount, shifted)
+		m.insertXmmRmR(sseOpcodePxor, shifted, mask, xored)
+		m.insertXmmRmR(sseOpcodePsubq, xored, mask, rd)
+	default:
+		var vop sseOpcode
+		switch op {
+		case ssa.OpcodeVIshl:
+			vop = laneOpcode(lane, sseOpcodeInvalid, sseOpcodePsllw, sseOpcodePslld, sseOpcodePsllq)
+		case ssa.OpcodeVUshr:
+			vop = laneOpcode(lane, sseOpcodeInvalid, sseOpcodePsrlw, sseOpcodePsrld, sseOpcodePsrlq)
+		default:
+			vop = laneOpcode(lane, sseOpcodeInvalid, sseOpcodePsraw, sseOpcodePsrad, sseOpcodeInvalid)
+		}
+		m.insertXmmShift(vop, rn, amountOf(0), rd)
+	}
+}
+
+func (m *machine) lowerVIcmp(si *ssa.Instruction) {
+	x, y, c, lane := si.VIcmpData()
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	rd := m.compiler.VRegOf(si.Return())
+
+	eq := laneOpcode(lane, sseOpcodePcmpeqb, sseOpcodePcmpeqw, sseOpcodePcmpeqd, sseOpcodePcmpeqq)
+	gt := func() sseOpcode {
+		return laneOpcode(lane, sseOpcodePcmpgtb, sseOpcodePcmpgtw, sseOpcodePcmpgtd, sseOpcodePcmpgtq)
+	}
+	umin := func() sseOpcode {
+		return laneOpcode(lane, sseOpcodePminub, sseOpcodePminuw, sseOpcodePminud, sseOpcodeInvalid)
+	}
+	umax := func() sseOpcode {
+		return laneOpcode(lane, sseOpcodePmaxub, sseOpcodePmaxuw, sseOpcodePmaxud, sseOpcodeInvalid)
+	}
+
+	// result is the register holding the comparison result, which is negated if not is true.
+	var result regalloc.VReg
+	var not bool
+	switch c {
+	case ssa.IntegerCmpCondEqual, ssa.IntegerCmpCondNotEqual:
+		result = m.allocateV128()
+		m.insertXmmRmR(eq, rn, rm, result)
+		not = c == ssa.IntegerCmpCondNotEqual
+	case ssa.IntegerCmpCondSignedGreaterThan, ssa.IntegerCmpCondSignedLessThanOrEqual:
+		result = m.allocateV128()
+		m.insertXmmRmR(gt(), rn, rm, result)
+		not = c == ssa.IntegerCmpCondSignedLessThanOrEqual
+	case ssa.IntegerCmpCondSignedLessThan, ssa.IntegerCmpCondSignedGreaterThanOrEqual:
+		result = m.allocateV128()
+		m.insertXmmRmR(gt(), rm, rn, result)
+		not = c == ssa.IntegerCmpCondSignedGreaterThanOrEqual
+	case ssa.IntegerCmpCondUnsignedLessThanOrEqual, ssa.IntegerCmpCondUnsignedGreaterThan:
+		// x <= y iff umin(x, y) == x.
+		tmp := m.allocateV128()
+		m.insertXmmRmR(umin(), rn, rm, tmp)
+		result = m.allocateV128()
+		m.insertXmmRmR(eq, tmp, rn, result)
+		not = c == ssa.IntegerCmpCondUnsignedGreaterThan
+	case ssa.IntegerCmpCondUnsignedGreaterThanOrEqual, ssa.IntegerCmpCondUnsignedLessThan:
+		// x >= y iff umax(x, y) == x.
+		tmp := m.allocateV128()
+		m.insertXmmRmR(umax(), rn, rm, tmp)
+		result = m.allocateV128()
+		m.insertXmmRmR(eq, tmp, rn, result)
+		not = c == ssa.IntegerCmpCondUnsignedLessThan
+	default:
+		panic("BUG: unsupported condition " + c.String())
+	}
+
+	if not {
+		m.insertXmmRmR(sseOpcodePxor, result, m.allocateOnes(), rd)
+	} else {
+		m.InsertMove(rd, result, ssa.TypeV128)
+	}
+}
+
+func (m *machine) lowerVFcmp(si *ssa.Instruction) {
+	x, y, c, lane := si.VFcmpData()
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	rd := m.compiler.VRegOf(si.Return())
+
+	var pred byte
+	switch c {
+	case ssa.FloatCmpCondEqual:
+		pred = cmpPredEq
+	case ssa.FloatCmpCondNotEqual:
+		pred = cmpPredNeq
+	case ssa.FloatCmpCondLessThan:
+		pred = cmpPredLt
+	case ssa.FloatCmpCondLessThanOrEqual:
+		pred = cmpPredLe
+	case ssa.FloatCmpCondGreaterThan:
+		pred, rn, rm = cmpPredLt, rm, rn
+	case ssa.FloatCmpCondGreaterThanOrEqual:
+		pred, rn, rm = cmpPredLe, rm, rn
+	default:
+		panic("BUG: unsupported condition " + c.String())
+	}
+	cmp := laneOpcode(lane, sseOpcodeCmpps, sseOpcodeCmppd, sseOpcodeInvalid, sseOpcodeInvalid)
+	m.insertXmmRmRImm(cmp, pred, rn, rm, rd)
+}
+
+func (m *machine) lowerVRound(si *ssa.Instruction, mode roundingMode) {
+	x, lane := si.ArgWithLane()
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	op := laneOpcode(lane, sseOpcodeRoundps, sseOpcodeRoundpd, sseOpcodeInvalid, sseOpcodeInvalid)
+	m.insertXmmUnaryImm(op, byte(mode), rn, m.compiler.VRegOf(si.Return()))
+}
+
+// lowerSqmulRoundSat lowers the Q15 multiplication with pmulhrsw, which results in 0x8000 only for 0x8000 * 0x8000
+// where the saturated result must be 0x7fff. So we flip such lanes.
+func (m *machine) lowerSqmulRoundSat(si *ssa.Instruction) {
+	x, y, _ := si.Arg2WithLane()
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	rd := m.compiler.VRegOf(si.Return())
+
+	mul, c, overflow := m.allocateV128(), m.allocateV128(), m.allocateV128()
+	m.insertXmmRmR(sseOpcodePmulhrsw, rn, rm, mul)
+	m.lowerVconst(c, 0x80008000_80008000, 0x80008000_80008000)
+	m.insertXmmRmR(sseOpcodePcmpeqw, mul, c, overflow)
+	m.insertXmmRmR(sseOpcodePxor, mul, overflow, rd)
+}
+
+func (m *machine) lowerVFcvtToIntSat(x ssa.Value, lane ssa.VecLane, ret ssa.Value, signed bool) {
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(ret)
+
+	switch {
+	case lane == ssa.VecLaneF32x4 && signed:
+		// cvttps2dq results in 0x80000000 for NaN and the out-of-range values, so we zero the NaN lanes beforehand,
+		// and flip the result of the positive overflow into 0x7fffffff:
+		//
+		//	cmpeqps notNaN, x, x
+		//	andps   a, x, notNaN        ;; zero the NaN lanes.
+		//	pxor    positive, notNaN, a ;; the sign bit is set for the positive lanes.
+		//	cvttps2dq cvt, a
+		//	pand    overflow, positive, cvt ;; the sign bit is set for the positive overflow.
+		//	psrad   overflow, overflow, 31
+		//	pxor    rd, cvt, overflow
+		notNaN, a, positive, cvt := m.allocateV128(), m.allocateV128(), m.allocateV128(), m.allocateV128()
+		m.insertXmmRmRImm(sseOpcodeCmpps, cmpPredEq, rn, rn, notNaN)
+		m.insertXmmRmR(sseOpcodeAndps, rn, notNaN, a)
+		m.insertXmmRmR(sseOpcodePxor, notNaN, a, positive)
+		m.insertXmmUnary(sseOpcodeCvttps2dq, a, cvt)
+		overflow, overflowMask := m.allocateV128(), m.allocateV128()
+		m.insertXmmRmR(sseOpcodePand, positive, cvt, overflow)
+		m.insertXmmShift(sseOpcodePsrad, overflow, newOperandImm32(31), overflowMask)
+		m.insertXmmRmR(sseOpcodePxor, cvt, overflowMask, rd)
+	case lane == ssa.VecLaneF32x4:
+		// The unsigned conversion is done by splitting the value at 2^31:
+		//
+		//	maxps     a, x, zero     ;; clamp the negative and NaN lanes to zero.
+		//	subps     b, a, 2^31
+		//	cmpleps   overflow, 2^31, b ;; b >= 2^31 means a >= 2^32.
+		//	cvttps2dq bi, b
+		//	pxor      bi, bi, overflow  ;; 0x80000000 ^ 0xffffffff = 0x7fffffff for the overflow.
+		//	pmaxsd    bi, bi, zero      ;; zero for a < 2^31.
+		//	cvttps2dq ai, a             ;; 0x80000000 for a >= 2^31.
+		//	paddd     rd, ai, bi
+		zero, a, twoTo31 := m.allocateV128(), m.allocateV128(), m.allocateV128()
+		m.insert(m.allocateInstr().asXmmSelfOp(sseOpcodeXorps, zero))
+		m.insertXmmRmR(sseOpcodeMaxps, rn, zero, a)
+		m.lowerVconst(twoTo31, 0x4f000000_4f000000, 0x4f000000_4f000000)
+		b, overflow, bi, biFlipped, biClamped, ai := m.allocateV128(), m.allocateV128(), m.allocateV128(),
+			m.allocateV128(), m.allocateV128(), m.allocateV128()
+		m.insertXmmRmR(sseOpcodeSubps, a, twoTo31, b)
+		m.insertXmmRmRImm(sseOpcodeCmpps, cmpPredLe, twoTo31, b, overflow)
+		m.insertXmmUnary(sseOpcodeCvttps2dq, b, bi)
+		m.insertXmmRmR(sseOpcodePxor, bi, overflow, biFlipped)
+		m.insertXmmRmR(sseOpcodePmaxsd, biFlipped, zero, biClamped)
+		m.insertXmmUnary(sseOpcodeCvttps2dq, a, ai)
+		m.insertXmmRmR(sseOpcodePaddd, ai, biClamped, rd)
+	case lane == ssa.VecLaneF64x2 && signed:
+		//	cmpeqpd notNaN, x, x
+		//	andpd   limit, notNaN, 2147483647.0 ;; zero for the NaN lanes.
+		//	minpd   a, x, limit
+		//	cvttpd2dq rd, a ;; the higher lanes are zeroed.
+		notNaN, maxInt, limit, a := m.allocateV128(), m.allocateV128(), m.allocateV128(), m.allocateV128()
+		m.insertXmmRmRImm(sseOpcodeCmppd, cmpPredEq, rn, rn, notNaN)
+		m.lowerVconst(maxInt, 0x41dfffff_ffc00000, 0x41dfffff_ffc00000)
+		m.insertXmmRmR(sseOpcodeAndpd, notNaN, maxInt, limit)
+		m.insertXmmRmR(sseOpcodeMinpd, rn, limit, a)
+		m.insertXmmUnary(sseOpcodeCvttpd2dq, a, rd)
+	case lane == ssa.VecLaneF64x2:
+		//	maxpd   a, x, zero ;; clamp the negative and NaN lanes to zero.
+		//	minpd   b, a, 4294967295.0
+		//	roundpd c, b, 3
+		//	addpd   d, c, 2^52 ;; the lower 32 bits of d now holds the integer.
+		//	shufps  rd, d, zero, 0b10_00_10_00
+		zero, a, maxUint, b, c := m.allocateV128(), m.allocateV128(), m.allocateV128(), m.allocateV128(), m.allocateV128()
+		m.insert(m.allocateInstr().asXmmSelfOp(sseOpcodeXorpd, zero))
+		m.insertXmmRmR(sseOpcodeMaxpd, rn, zero, a)
+		m.lowerVconst(maxUint, 0x41efffff_ffe00000, 0x41efffff_ffe00000)
+		m.insertXmmRmR(sseOpcodeMinpd, a, maxUint, b)
+		m.insertXmmUnaryImm(sseOpcodeRoundpd, byte(roundingModeZero), b, c)
+		twoTo52, d := m.allocateV128(), m.allocateV128()
+		m.lowerVconst(twoTo52, 0x43300000_00000000, 0x43300000_00000000)
+		m.insertXmmRmR(sseOpcodeAddpd, c, twoTo52, d)
+		m.insertXmmRmRImm(sseOpcodeShufps, 0b10_00_10_00, d, zero, rd)
+	default:
+		panic("BUG: unsupported lane " + lane.String())
+	}
+}
+
+func (m *machine) lowerVFcvtFromInt(x ssa.Value, lane ssa.VecLane, ret ssa.Value, signed bool) {
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(ret)
+
+	switch {
+	case lane == ssa.VecLaneF32x4 && signed:
+		m.insertXmmUnary(sseOpcodeCvtdq2ps, rn, rd)
+	case lane == ssa.VecLaneF32x4:
+		// Split each lane into the lower 16 bits and the rest, and convert them separately so that
+		// both conversions are exact except the final addition:
+		//
+		//	pblendw   lo, zero, x, 0b01010101
+		//	psubd     hi, x, lo
+		//	cvtdq2ps  loF, lo
+		//	psrld     hi, hi, 1 ;; make it fit in the signed range.
+		//	cvtdq2ps  hiF, hi
+		//	addps     hiF, hiF, hiF
+		//	addps     rd, hiF, loF
+		zero, lo, hi, loF := m.allocateV128(), m.allocateV128(), m.allocateV128(), m.allocateV128()
+		m.insert(m.allocateInstr().asXmmSelfOp(sseOpcodePxor, zero))
+		m.insertXmmRmRImm(sseOpcodePblendw, 0b01010101, zero, rn, lo)
+		m.insertXmmRmR(sseOpcodePsubd, rn, lo, hi)
+		m.insertXmmUnary(sseOpcodeCvtdq2ps, lo, loF)
+		hiHalf, hiF, hiF2 := m.allocateV128(), m.allocateV128(), m.allocateV128()
+		m.insertXmmShift(sseOpcodePsrld, hi, newOperandImm32(1), hiHalf)
+		m.insertXmmUnary(sseOpcodeCvtdq2ps, hiHalf, hiF)
+		m.insertXmmRmR(sseOpcodeAddps, hiF, hiF, hiF2)
+		m.insertXmmRmR(sseOpcodeAddps, hiF2, loF, rd)
+	case lane == ssa.VecLaneF64x2 && signed:
+		// The source is the sign-extended I64x2 of 32-bit integers, so we gather the lower 32 bits and convert them.
+		tmp := m.allocateV128()
+		m.insertXmmUnaryImm(sseOpcodePshufd, 0b00_00_10_00, rn, tmp)
+		m.insertXmmUnary(sseOpcodeCvtdq2pd, tmp, rd)
+	case lane == ssa.VecLaneF64x2:
+		// The source is the zero-extended I64x2 of 32-bit integers, so we construct the float 2^52 + x by
+		// setting the exponent bits, and subtract 2^52 from it.
+		twoTo52, tmp := m.allocateV128(), m.allocateV128()
+		m.lowerVconst(twoTo52, 0x43300000_00000000, 0x43300000_00000000)
+		m.insertXmmRmR(sseOpcodePor, rn, twoTo52, tmp)
+		m.insertXmmRmR(sseOpcodeSubpd, tmp, twoTo52, rd)
+	default:
+		panic("BUG: unsupported lane " + lane.String())
+	}
+}
+
+func (m *machine) lowerExtractlane(si *ssa.Instruction) {
+	x, index, signed, lane := si.ExtractlaneData()
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rd := m.compiler.VRegOf(si.Return())
+
+	switch lane {
+	case ssa.VecLaneI8x16, ssa.VecLaneI16x8:
+		op, mode := sseOpcodePextrb, extModeBL
+		if lane == ssa.VecLaneI16x8 {
+			op, mode = sseOpcodePextrw, extModeWL
+		}
+		dst := rd
+		if signed {
+			dst = m.compiler.AllocateVReg(ssa.TypeI32)
+		}
+		extract := m.allocateInstr()
+		extract.asXmmToGprImm(op, index, rn, dst)
+		m.insert(extract)
+		if signed {
+			ext := m.allocateInstr()
+			ext.asMovsxRmR(mode, newOperandReg(dst), rd)
+			m.insert(ext)
+		}
+	case ssa.VecLaneI32x4:
+		extract := m.allocateInstr()
+		extract.asXmmToGprImm(sseOpcodePextrd, index, rn, rd)
+		m.insert(extract)
+	case ssa.VecLaneI64x2:
+		extract := m.allocateInstr()
+		extract.asXmmToGprImm(sseOpcodePextrq, index, rn, rd)
+		m.insert(extract)
+	case ssa.VecLaneF32x4:
+		if index == 0 {
+			m.InsertMove(rd, rn, ssa.TypeF32)
+		} else {
+			m.insertXmmUnaryImm(sseOpcodePshufd, index, rn, rd)
+		}
+	case ssa.VecLaneF64x2:
+		if index == 0 {
+			m.InsertMove(rd, rn, ssa.TypeF64)
+		} else {
+			m.insertXmmUnaryImm(sseOpcodePshufd, 0b11_10_11_10, rn, rd)
+		}
+	default:
+		panic("BUG: unsupported lane " + lane.String())
+	}
+}
+
+func (m *machine) lowerInsertlane(si *ssa.Instruction) {
+	x, y, index, lane := si.InsertlaneData()
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	rd := m.compiler.VRegOf(si.Return())
+
+	switch lane {
+	case ssa.VecLaneF32x4:
+		// The immediate of insertps specifies the destination lane in the bits 4-5.
+		m.insertXmmRmRImm(sseOpcodeInsertps, index<<4, rn, rm, rd)
+	case ssa.VecLaneF64x2:
+		if index == 0 {
+			// shufps takes the lower two 32-bit lanes from the first operand, and the higher two from the second.
+			m.insertXmmRmRImm(sseOpcodeShufps, 0b11_10_01_00, rm, rn, rd)
+		} else {
+			m.insertXmmRmR(sseOpcodeMovlhps, rn, rm, rd)
+		}
+	default:
+		op := laneOpcode(lane, sseOpcodePinsrb, sseOpcodePinsrw, sseOpcodePinsrd, sseOpcodePinsrq)
+		insert := m.allocateInstr()
+		insert.asGprToXmmImm(op, index, rn, rm, rd)
+		m.insert(insert)
+	}
+}
+
+// lowerShuffle selects the bytes from x and y with two pshufb whose indexes of the other vector are
+// replaced with 0x80 which zeros the byte, and then merges the results.
+func (m *machine) lowerShuffle(x, y ssa.Value, lo, hi uint64, ret ssa.Value) {
+	var xMask, yMask [2]uint64
+	for i := 0; i < 16; i++ {
+		lanes, shift := lo, 8*uint(i)
+		if i >= 8 {
+			lanes, shift = hi, 8*uint(i-8)
+		}
+		idx := byte(lanes >> shift)
+		xIdx, yIdx := uint64(0x80), uint64(0x80)
+		if idx < 16 {
+			xIdx = uint64(idx)
+		} else {
+			yIdx = uint64(idx - 16)
+		}
+		xMask[i/8] |= xIdx << shift
+		yMask[i/8] |= yIdx << shift
+	}
+
+	rn := m.getOperand_Reg(m.compiler.ValueDefinition(x))
+	rm := m.getOperand_Reg(m.compiler.ValueDefinition(y))
+	rd := m.compiler.VRegOf(ret)
+
+	xm, ym, xs, ys := m.allocateV128(), m.allocateV128(), m.allocateV128(), m.allocateV128()
+	m.lowerVconst(xm, xMask[0], xMask[1])
+	m.lowerVconst(ym, yMask[0], yMask[1])
+	m.insertXmmRmR(sseOpcodePshufb, rn, xm, xs)
+	m.insertXmmRmR(sseOpcodePshufb, rm, ym, ys)
+	m.insertXmmRmR(sseOpcodePor, xs, ys, rd)
+}
+
+// lowerSplatFromGpr copies the integer held by the general purpose register src into all the lanes of dst.
+func (m *machine) lowerSplatFromGpr(lane ssa.VecLane, src, dst regalloc.VReg) {
+	tmp := m.allocateV128()
+	mov := m.allocateInstr()
+	mov.asGprToXmm(sseOpcodeMovd, newOperandReg(src), tmp, false)
+	if lane == ssa.VecLaneI64x2 {
+		mov.asGprToXmm(sseOpcodeMovq, newOperandReg(src), tmp, true)
+	}
+	m.insert(mov)
+
+	switch lane {
+	case ssa.VecLaneI8x16:
+		zero := m.allocateV128()
+		m.insert(m.allocateInstr().asXmmSelfOp(sseOpcodePxor, zero))
+		m.insertXmmRmR(sseOpcodePshufb, tmp, zero, dst)
+	case ssa.VecLaneI16x8:
+		tmp2 := m.allocateV128()
+		insert := m.allocateInstr()
+		insert.asGprToXmmImm(sseOpcodePinsrw, 1, tmp, src, tmp2)
+		m.insert(insert)
+		m.insertXmmUnaryImm(sseOpcodePshufd, 0, tmp2, dst)
+	case ssa.VecLaneI32x4:
+		m.insertXmmUnaryImm(sseOpcodePshufd, 0, tmp, dst)
+	case ssa.VecLaneI64x2:
+		m.insertXmmUnaryImm(sseOpcodePshufd, 0b01_00_01_00, tmp, dst)
+	default:
+		panic("BUG: unsupported lane " + lane.String())
+	}
+}
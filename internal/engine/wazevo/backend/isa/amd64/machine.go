@@ -0,0 +1,477 @@
+package amd64
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend"
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+	"github.com/AR1011/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+type (
+	// machine implements backend.Machine for amd64.
+	machine struct {
+		compiler      backend.Compiler
+		currentABI    *abiImpl
+		currentSSABlk ssa.BasicBlock
+		// abis maps ssa.SignatureID to the ABI implementation.
+		abis      []abiImpl
+		instrPool wazevoapi.Pool[instruction]
+		amodePool wazevoapi.Pool[amode]
+		// rootInstr is the root instruction of the currently-compiled function.
+		rootInstr *instruction
+		// perBlockHead and perBlockEnd are the head and tail of the instruction list per currently-compiled ssa.BasicBlock.
+		perBlockHead, perBlockEnd *instruction
+		// pendingInstructions are the instructions which are not yet emitted into the instruction list.
+		pendingInstructions []*instruction
+		regAllocFn          regAllocFunctionImpl
+		nextLabel           label
+
+		// ssaBlockIDToLabels maps an SSA block ID to the label.
+		ssaBlockIDToLabels []label
+		// labelPositions maps a label to the instructions of the region which the label represents.
+		labelPositions    map[label]*labelPosition
+		labelPositionPool wazevoapi.Pool[labelPosition]
+
+		// labelOffsets holds the binary offset of each label, which is populated during encoding.
+		labelOffsets []int64
+		// labelFixups are the 32-bit displacements referring to labels, which are resolved at the end of encoding.
+		labelFixups []labelFixup
+		// constPool holds the constants which are placed right after the function body and loaded via rip-relative addressing.
+		constPool []constPoolEntry
+
+		// unresolvedAddressModes are the address modes which refer to the argument/result stack space,
+		// and need to be resolved after the register allocation.
+		unresolvedAddressModes []*amode
+
+		// addendsWorkQueue is used during address lowering, defined here for reuse.
+		addendsWorkQueue []ssa.Value
+
+		// spillSlotSize is the size of the stack slot in bytes used for spilling registers.
+		// During the execution of the function, the stack looks like:
+		//
+		//
+		//            (high address)
+		//          +-----------------+
+		//          |     .......     |
+		//          |      ret Y      |
+		//          |     .......     |
+		//          |      ret 0      |
+		//          |      arg X      |
+		//          |     .......     |
+		//          |      arg 1      |
+		//          |      arg 0      |
+		//          |  ReturnAddress  |
+		//          | size_of_arg_ret |
+		//          |   clobbered N   |
+		//          |   ...........   |
+		//          |   clobbered 0   |
+		//          |   spill slot M  |
+		//          |   ............  |
+		//          |   spill slot 0  |
+		//          |     xxxxxx      |  ;; unused space to make it 16-byte aligned.
+		//          |   frame_size    |
+		//   SP---> +-----------------+
+		//             (low address)
+		//
+		// Note that this is only known after register allocation. This must be a multiple of 16.
+		spillSlotSize int64
+		spillSlots    map[regalloc.VRegID]int64 // regalloc.VRegID to offset.
+		// clobberedRegs holds real-register backed VRegs saved at the function prologue, and restored at the epilogue.
+		clobberedRegs []regalloc.VReg
+
+		maxRequiredStackSizeForCalls int64
+		stackBoundsCheckDisabled     bool
+
+		regAllocStarted bool
+
+		// perfMapFunctionName and perfMapFunctionIndex are used to add the perf map entries, only used when wazevoapi.PerfMapEnabled.
+		perfMapFunctionName  string
+		perfMapFunctionIndex int
+	}
+
+	// label represents a position in the generated code which is either
+	// a real instruction or the constant pool.
+	//
+	// This is exactly the same as the traditional "label" in assembly code.
+	label uint32
+
+	// labelPosition represents the regions of the generated code which the label represents.
+	labelPosition struct {
+		l          label
+		begin, end *instruction
+	}
+
+	// constPoolEntry is the constant placed in the constant pool, which is referred by the label l.
+	constPoolEntry struct {
+		l      label
+		size   byte
+		lo, hi uint64
+	}
+)
+
+const (
+	invalidLabel = 0
+	returnLabel  = math.MaxUint32
+)
+
+// NewBackend returns a new backend for amd64.
+func NewBackend() backend.Machine {
+	m := &machine{
+		instrPool:         wazevoapi.NewPool[instruction](resetInstruction),
+		amodePool:         wazevoapi.NewPool[amode](resetAmode),
+		labelPositionPool: wazevoapi.NewPool[labelPosition](resetLabelPosition),
+		labelPositions:    make(map[label]*labelPosition),
+		spillSlots:        make(map[regalloc.VRegID]int64),
+		nextLabel:         invalidLabel,
+	}
+	m.regAllocFn.m = m
+	m.regAllocFn.labelToRegAllocBlockIndex = make(map[label]int)
+	return m
+}
+
+// Reset implements backend.Machine.
+func (m *machine) Reset() {
+	m.regAllocStarted = false
+	m.instrPool.Reset()
+	m.amodePool.Reset()
+	m.labelPositionPool.Reset()
+	m.currentSSABlk = nil
+	for l := label(0); l <= m.nextLabel; l++ {
+		delete(m.labelPositions, l)
+	}
+	m.pendingInstructions = m.pendingInstructions[:0]
+	m.clobberedRegs = m.clobberedRegs[:0]
+	for key := range m.spillSlots {
+		m.clobberedRegs = append(m.clobberedRegs, regalloc.VReg(key))
+	}
+	for _, key := range m.clobberedRegs {
+		delete(m.spillSlots, regalloc.VRegID(key))
+	}
+	m.clobberedRegs = m.clobberedRegs[:0]
+	m.regAllocFn.reset()
+	m.spillSlotSize = 0
+	m.unresolvedAddressModes = m.unresolvedAddressModes[:0]
+	m.constPool = m.constPool[:0]
+	m.labelFixups = m.labelFixups[:0]
+	m.rootInstr = nil
+	m.ssaBlockIDToLabels = m.ssaBlockIDToLabels[:0]
+	m.perBlockHead, m.perBlockEnd = nil, nil
+	m.maxRequiredStackSizeForCalls = 0
+	m.nextLabel = invalidLabel
+}
+
+// InitializeABI implements backend.Machine InitializeABI.
+func (m *machine) InitializeABI(sig *ssa.Signature) {
+	m.currentABI = m.getOrCreateABIImpl(sig)
+}
+
+// DisableStackCheck implements backend.Machine DisableStackCheck.
+func (m *machine) DisableStackCheck() {
+	m.stackBoundsCheckDisabled = true
+}
+
+// ABI implements backend.Machine.
+func (m *machine) ABI() backend.FunctionABI {
+	return m.currentABI
+}
+
+// allocateLabel allocates an unused label.
+func (m *machine) allocateLabel() label {
+	m.nextLabel++
+	return m.nextLabel
+}
+
+// SetCompiler implements backend.Machine.
+func (m *machine) SetCompiler(ctx backend.Compiler) {
+	m.compiler = ctx
+}
+
+// StartLoweringFunction implements backend.Machine.
+func (m *machine) StartLoweringFunction(max ssa.BasicBlockID) {
+	imax := int(max)
+	if len(m.ssaBlockIDToLabels) <= imax {
+		// Eagerly allocate labels for the blocks since the underlying slice will be used for the next iteration.
+		m.ssaBlockIDToLabels = append(m.ssaBlockIDToLabels, make([]label, imax+1)...)
+	}
+}
+
+// EndLoweringFunction implements backend.Machine.
+func (m *machine) EndLoweringFunction() {}
+
+// StartBlock implements backend.Machine.
+func (m *machine) StartBlock(blk ssa.BasicBlock) {
+	m.currentSSABlk = blk
+
+	l := m.ssaBlockIDToLabels[m.currentSSABlk.ID()]
+	if l == invalidLabel {
+		l = m.allocateLabel()
+		m.ssaBlockIDToLabels[blk.ID()] = l
+	}
+
+	end := m.allocateNop()
+	m.perBlockHead, m.perBlockEnd = end, end
+
+	labelPos, ok := m.labelPositions[l]
+	if !ok {
+		labelPos = m.allocateLabelPosition(l)
+		m.labelPositions[l] = labelPos
+	}
+	labelPos.begin, labelPos.end = end, end
+	m.regAllocFn.addBlock(blk, l, labelPos)
+}
+
+// EndBlock implements backend.Machine.
+func (m *machine) EndBlock() {
+	// Insert the labeled nop0 as the head of the block so that the encoder can resolve the offset of the block.
+	l := m.ssaBlockIDToLabels[m.currentSSABlk.ID()]
+	m.insertAtPerBlockHead(m.allocateInstr().asNop0WithLabel(l))
+
+	m.labelPositions[l].begin = m.perBlockHead
+
+	if m.currentSSABlk.EntryBlock() {
+		m.rootInstr = m.perBlockHead
+	}
+}
+
+func (m *machine) insert(i *instruction) {
+	m.pendingInstructions = append(m.pendingInstructions, i)
+}
+
+func (m *machine) allocateBrTarget() (nop *instruction, l label) {
+	l = m.allocateLabel()
+	nop = m.allocateInstr()
+	nop.asNop0WithLabel(l)
+	return
+}
+
+func (m *machine) allocateLabelPosition(la label) *labelPosition {
+	l := m.labelPositionPool.Allocate()
+	l.l = la
+	return l
+}
+
+func resetLabelPosition(l *labelPosition) {
+	*l = labelPosition{}
+}
+
+// FlushPendingInstructions implements backend.Machine.
+func (m *machine) FlushPendingInstructions() {
+	l := len(m.pendingInstructions)
+	if l == 0 {
+		return
+	}
+	for i := l - 1; i >= 0; i-- { // reverse because we lower instructions in reverse order.
+		m.insertAtPerBlockHead(m.pendingInstructions[i])
+	}
+	m.pendingInstructions = m.pendingInstructions[:0]
+}
+
+func (m *machine) insertAtPerBlockHead(i *instruction) {
+	if m.perBlockHead == nil {
+		m.perBlockHead = i
+		m.perBlockEnd = i
+		return
+	}
+	i.next = m.perBlockHead
+	m.perBlockHead.prev = i
+	m.perBlockHead = i
+}
+
+// String implements fmt.Stringer.
+func (l label) String() string {
+	return fmt.Sprintf("L%d", l)
+}
+
+// allocateInstr allocates an instruction.
+func (m *machine) allocateInstr() *instruction {
+	instr := m.instrPool.Allocate()
+	if !m.regAllocStarted {
+		instr.addedBeforeRegAlloc = true
+	}
+	return instr
+}
+
+func (m *machine) allocateNop() *instruction {
+	instr := m.allocateInstr()
+	instr.asNop0()
+	return instr
+}
+
+// ResolveRelativeAddresses implements backend.Machine.
+//
+// Unlike arm64, the relative offsets of the branches are resolved by the encoder since the size of
+// each instruction is only known at the encoding phase. Therefore, this only resolves the address modes
+// referring to the argument/result stack space.
+func (m *machine) ResolveRelativeAddresses(ctx context.Context) {
+	if len(m.unresolvedAddressModes) > 0 {
+		arg0offset, ret0offset := m.arg0OffsetFromSP(), m.ret0OffsetFromSP()
+		for _, a := range m.unresolvedAddressModes {
+			switch a.kind {
+			case amodeKindArgStackSpace:
+				a.imm32 = uint32(int64(int32(a.imm32)) + arg0offset)
+			case amodeKindResultStackSpace:
+				a.imm32 = uint32(int64(int32(a.imm32)) + ret0offset)
+			default:
+				panic("BUG")
+			}
+			a.kind = amodeKindImmReg
+		}
+	}
+
+	if wazevoapi.PerfMapEnabled {
+		m.perfMapFunctionName = wazevoapi.GetCurrentFunctionName(ctx)
+		m.perfMapFunctionIndex = wazevoapi.GetCurrentFunctionIndex(ctx)
+	}
+}
+
+// addPerfMapEntries adds the perf map entries for each block of the currently compiled function.
+// This must be called after the encoding so that the label offsets are known.
+func (m *machine) addPerfMapEntries() {
+	labelToSSABlockID := make(map[label]ssa.BasicBlockID)
+	for i, l := range m.ssaBlockIDToLabels {
+		labelToSSABlockID[l] = ssa.BasicBlockID(i)
+	}
+
+	end := m.currentOffset()
+	for cur := m.rootInstr; cur != nil; cur = cur.next {
+		if cur.kind != nop0 {
+			continue
+		}
+		l := cur.nop0Label()
+		blkID, ok := labelToSSABlockID[l]
+		if !ok {
+			continue
+		}
+		begin := m.labelOffsets[l]
+		// The block ends at the beginning of the next block.
+		blockEnd := end
+		for next := cur.next; next != nil; next = next.next {
+			if next.kind == nop0 {
+				if _, ok := labelToSSABlockID[next.nop0Label()]; ok {
+					blockEnd = m.labelOffsets[next.nop0Label()]
+					break
+				}
+			}
+		}
+		if size := blockEnd - begin; size > 0 {
+			wazevoapi.PerfMap.AddModuleEntry(m.perfMapFunctionIndex, begin, uint64(size),
+				fmt.Sprintf("%s:::::%s::SSA_Block[%s]", m.perfMapFunctionName, l, blkID))
+		}
+	}
+}
+
+func (m *machine) getOrAllocateSSABlockLabel(blk ssa.BasicBlock) label {
+	if blk.ReturnBlock() {
+		return returnLabel
+	}
+	l := m.ssaBlockIDToLabels[blk.ID()]
+	if l == invalidLabel {
+		l = m.allocateLabel()
+		m.ssaBlockIDToLabels[blk.ID()] = l
+	}
+	return l
+}
+
+// LinkAdjacentBlocks implements backend.Machine.
+func (m *machine) LinkAdjacentBlocks(prev, next ssa.BasicBlock) {
+	prevLabelPos := m.labelPositions[m.getOrAllocateSSABlockLabel(prev)]
+	nextLabelPos := m.labelPositions[m.getOrAllocateSSABlockLabel(next)]
+	prevLabelPos.end.next = nextLabelPos.begin
+}
+
+// Format implements backend.Machine.
+func (m *machine) Format() string {
+	irBlocks := map[label]ssa.BasicBlockID{}
+	for i, l := range m.ssaBlockIDToLabels {
+		irBlocks[l] = ssa.BasicBlockID(i)
+	}
+
+	var lines []string
+	for cur := m.rootInstr; cur != nil; cur = cur.next {
+		if cur.kind == nop0 {
+			l := cur.nop0Label()
+			if l == invalidLabel {
+				continue
+			}
+			if blkID, ok := irBlocks[l]; ok {
+				lines = append(lines, fmt.Sprintf("%s (SSA Block: %s):", l, blkID))
+			} else {
+				lines = append(lines, fmt.Sprintf("%s:", l))
+			}
+			continue
+		}
+		lines = append(lines, "\t"+cur.String())
+	}
+	for _, c := range m.constPool {
+		if c.size == 16 {
+			lines = append(lines, fmt.Sprintf("%s:\n\t.quad %#x, %#x", c.l, c.lo, c.hi))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s:\n\t.quad %#x", c.l, c.lo))
+		}
+	}
+	return "\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// InsertReturn implements backend.Machine.
+func (m *machine) InsertReturn() {
+	i := m.allocateInstr()
+	i.asRet(m.currentABI)
+	m.insert(i)
+}
+
+func (m *machine) getVRegSpillSlotOffsetFromSP(id regalloc.VRegID, size byte) int64 {
+	offset, ok := m.spillSlots[id]
+	if !ok {
+		offset = m.spillSlotSize
+		m.spillSlots[id] = offset
+		m.spillSlotSize += int64(size)
+	}
+	return offset + 16 // spill slot starts above the frame size and the alignment padding.
+}
+
+func (m *machine) clobberedRegSlotSize() int64 {
+	return int64(len(m.clobberedRegs) * 16)
+}
+
+func (m *machine) arg0OffsetFromSP() int64 {
+	return m.frameSize() +
+		16 + // frame size and the alignment padding saved below the spill slots.
+		8 + // size_of_arg_ret.
+		8 // return address.
+}
+
+func (m *machine) ret0OffsetFromSP() int64 {
+	return m.arg0OffsetFromSP() + m.currentABI.argStackSize
+}
+
+func (m *machine) requiredStackSize() int64 {
+	return m.maxRequiredStackSizeForCalls +
+		m.frameSize() +
+		16 + // frame size and the alignment padding saved below the spill slots.
+		16 // size_of_arg_ret and the return address.
+}
+
+func (m *machine) frameSize() int64 {
+	s := m.clobberedRegSlotSize() + m.spillSlotSize
+	if s&0xf != 0 {
+		panic(fmt.Errorf("BUG: frame size %d is not 16-byte aligned", s))
+	}
+	return s
+}
+
+// addConstant adds the constant of the given size in bytes (8 or 16) to the constant pool, and returns the label referring to it.
+func (m *machine) addConstant(size byte, lo, hi uint64) label {
+	for _, c := range m.constPool {
+		if c.size == size && c.lo == lo && c.hi == hi {
+			return c.l
+		}
+	}
+	l := m.allocateLabel()
+	m.constPool = append(m.constPool, constPoolEntry{l: l, size: size, lo: lo, hi: hi})
+	return l
+}
@@ -0,0 +1,295 @@
+package amd64
+
+import (
+	"fmt"
+
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+	"github.com/AR1011/wazero/internal/engine/wazevo/wazevoapi"
+)
+
+// SetupPrologue implements backend.Machine.
+func (m *machine) SetupPrologue() {
+	cur := m.rootInstr
+	prevInitInst := cur.next
+
+	//
+	//                   (high address)                    (high address)
+	//                 +-----------------+               +------------------+
+	//                 |     .......     |               |     .......      |
+	//                 |      ret Y      |               |      ret Y       |
+	//                 |     .......     |               |     .......      |
+	//                 |      ret 0      |               |      ret 0       |
+	//                 |      arg X      |               |      arg X       |
+	//                 |     .......     |     ====>     |     .......      |
+	//                 |      arg 1      |               |      arg 1       |
+	//                 |      arg 0      |               |      arg 0       |
+	//                 |  ReturnAddress  |               |  ReturnAddress   |
+	//         SP----> +-----------------+               |  size_of_arg_ret |
+	//                                                   +------------------+ <---- SP
+	//                    (low address)                     (low address)
+
+	// The return address is already pushed by the call instruction, so we only save the size_of_arg_ret below it.
+	// size_of_arg_ret is used for stack unwinding.
+	cur = m.createSizeOfArgRetSlot(cur, m.currentABI.alignedArgResultStackSlotSize())
+
+	if !m.stackBoundsCheckDisabled {
+		cur = m.insertStackBoundsCheck(m.requiredStackSize(), cur)
+	}
+
+	if m.spillSlotSize == 0 && len(m.spillSlots) != 0 {
+		panic(fmt.Sprintf("BUG: spillSlotSize=%d, spillSlots=%v\n", m.spillSlotSize, m.spillSlots))
+	}
+
+	if regs := m.clobberedRegs; len(regs) > 0 {
+		//
+		//            (high address)                  (high address)
+		//          +-----------------+             +-----------------+
+		//          |     .......     |             |     .......     |
+		//          |      arg 0      |             |      arg 0      |
+		//          |  ReturnAddress  |             |  ReturnAddress  |
+		//          | size_of_arg_ret |             | size_of_arg_ret |
+		//  SP----> +-----------------+    ====>    +-----------------+
+		//             (low address)                |   clobbered M   |
+		//                                          |   ............  |
+		//                                          |   clobbered 0   |
+		//                                          +-----------------+ <----- SP
+		//                                             (low address)
+		//
+		// Each register occupies 16 bytes so that the stack pointer is kept 16-byte aligned.
+		cur = m.addOrSubStackPointer(cur, int64(len(regs)*16), false)
+		for i, vr := range regs {
+			mem := m.newAmodeImmReg(uint32(i*16), rspVReg)
+			store := m.allocateInstr()
+			switch vr.RegType() {
+			case regalloc.RegTypeInt:
+				store.asMovRM(newOperandReg(vr), mem, 8)
+			case regalloc.RegTypeFloat:
+				store.asXmmMovRM(sseOpcodeMovdqu, vr, mem)
+			}
+			cur = linkInstr(cur, store)
+		}
+	}
+
+	if size := m.spillSlotSize; size > 0 {
+		// Check if size is 16-byte aligned.
+		if size&0xf != 0 {
+			panic(fmt.Errorf("BUG: spill slot size %d is not 16-byte aligned", size))
+		}
+		cur = m.addOrSubStackPointer(cur, size, false)
+	}
+
+	// We push the frame size into the stack to make it possible to unwind stack:
+	//
+	//            (high address)                  (high address)
+	//         +-----------------+                +-----------------+
+	//         |     .......     |                |     .......     |
+	//         |      arg 0      |                |      arg 0      |
+	//         |  ReturnAddress  |                |  ReturnAddress  |
+	//         | size_of_arg_ret |                | size_of_arg_ret |
+	//         +-----------------+      ==>       +-----------------+ <----+
+	//         |   clobbered  M  |                |   clobbered  M  |      |
+	//         |   ............  |                |   ............  |      |
+	//         |   clobbered  0  |                |   clobbered  0  |      | frame size
+	//         |   spill slot N  |                |   spill slot N  |      |
+	//         |   ............  |                |   ............  |      |
+	//         |   spill slot 0  |                |   spill slot 0  | <----+
+	// SP--->  +-----------------+                |     xxxxxx      |  ;; unused space to make it 16-byte aligned.
+	//                                            |   frame_size    |
+	//                                            +-----------------+ <---- SP
+	//            (low address)
+	//
+	cur = m.createFrameSizeSlot(cur, m.frameSize())
+
+	linkInstr(cur, prevInitInst)
+}
+
+// createSizeOfArgRetSlot pushes the size_of_arg_ret onto the stack:
+//
+//	sub rsp, 8
+//	mov qword [rsp], $s
+func (m *machine) createSizeOfArgRetSlot(cur *instruction, s int64) *instruction {
+	cur = m.addOrSubStackPointer(cur, 8, false)
+	store := m.allocateInstr()
+	store.asMovRM(newOperandImm32(uint32(s)), m.newAmodeImmReg(0, rspVReg), 8)
+	return linkInstr(cur, store)
+}
+
+// createFrameSizeSlot allocates the 16 bytes on the stack, and stores the frame size at the bottom:
+//
+//	sub rsp, 16
+//	mov qword [rsp], $s
+func (m *machine) createFrameSizeSlot(cur *instruction, s int64) *instruction {
+	cur = m.addOrSubStackPointer(cur, 16, false)
+	store := m.allocateInstr()
+	store.asMovRM(newOperandImm32(uint32(s)), m.newAmodeImmReg(0, rspVReg), 8)
+	return linkInstr(cur, store)
+}
+
+// addOrSubStackPointer inserts `add rsp, $diff` or `sub rsp, $diff` after cur.
+func (m *machine) addOrSubStackPointer(cur *instruction, diff int64, add bool) *instruction {
+	if !fitsInImm32(diff) {
+		panic(fmt.Sprintf("BUG: too large stack pointer adjustment: %d", diff))
+	}
+	op := aluRmiROpcodeSub
+	if add {
+		op = aluRmiROpcodeAdd
+	}
+	instr := m.allocateInstr()
+	instr.asAluRmiR(op, rspVReg, newOperandImm32(uint32(diff)), rspVReg, true)
+	return linkInstr(cur, instr)
+}
+
+// SetupEpilogue implements backend.Machine.
+func (m *machine) SetupEpilogue() {
+	for cur := m.rootInstr; cur != nil; cur = cur.next {
+		if cur.kind == ret {
+			m.setupEpilogueAfter(cur.prev)
+			continue
+		}
+
+		// Removes the redundant copy instruction.
+		if cur.IsCopy() && cur.op1.reg().RealReg() == cur.dst.RealReg() {
+			prev, next := cur.prev, cur.next
+			// Remove the copy instruction.
+			prev.next = next
+			if next != nil {
+				next.prev = prev
+			}
+		}
+	}
+}
+
+func (m *machine) setupEpilogueAfter(cur *instruction) {
+	prevNext := cur.next
+
+	// We've stored the frame size in the prologue, and now that we are about to return from this function, we won't need it anymore.
+	cur = m.addOrSubStackPointer(cur, 16, true)
+
+	if s := m.spillSlotSize; s > 0 {
+		cur = m.addOrSubStackPointer(cur, s, true)
+	}
+
+	if regs := m.clobberedRegs; len(regs) > 0 {
+		for i, vr := range regs {
+			mem := m.newAmodeImmReg(uint32(i*16), rspVReg)
+			load := m.allocateInstr()
+			switch vr.RegType() {
+			case regalloc.RegTypeInt:
+				load.asMov64MR(mem, vr)
+			case regalloc.RegTypeFloat:
+				load.asXmmUnaryRmR(sseOpcodeMovdqu, newOperandMem(mem), vr)
+			}
+			cur = linkInstr(cur, load)
+		}
+		cur = m.addOrSubStackPointer(cur, int64(len(regs)*16), true)
+	}
+
+	// Finally, discard size_of_arg_ret so that the stack pointer points to the return address,
+	// which is popped by the following ret instruction.
+	cur = m.addOrSubStackPointer(cur, 8, true)
+
+	linkInstr(cur, prevNext)
+}
+
+// saveRequiredRegs is the set of registers that must be saved/restored during growing stack when there's insufficient
+// stack space left. Basically this is the combination of CalleeSavedRegisters plus argument registers except for rax,
+// which always points to the execution context whenever the native code is entered from Go.
+var saveRequiredRegs = []regalloc.VReg{
+	rbxVReg, rcxVReg, rdiVReg, rsiVReg, r8VReg, r9VReg, r10VReg,
+	r12VReg, r13VReg, r14VReg, r15VReg,
+	xmm0VReg, xmm1VReg, xmm2VReg, xmm3VReg, xmm4VReg, xmm5VReg, xmm6VReg, xmm7VReg,
+}
+
+// insertStackBoundsCheck will insert the instructions after `cur` to check the
+// stack bounds, and if there's no sufficient spaces required for the function,
+// exit the execution and try growing it in Go world.
+//
+//	mov r11, rsp
+//	sub r11, $requiredStackSize
+//	cmp r11, [rax + StackBottomPtr]
+//	jae L1
+//	mov r11, $requiredStackSize
+//	mov [rax + StackGrowRequiredSize], r11
+//	call [rax + StackGrowCallTrampolineAddress]
+//
+// L1:
+func (m *machine) insertStackBoundsCheck(requiredStackSize int64, cur *instruction) *instruction {
+	if requiredStackSize%16 != 0 {
+		panic("BUG")
+	}
+
+	// Execution context is always the first argument.
+	execCtx := raxVReg
+
+	mov := m.allocateInstr()
+	mov.asMovRR(rspVReg, tmpIntRegVReg, true)
+	cur = linkInstr(cur, mov)
+
+	sub := m.allocateInstr()
+	if fitsInImm32(requiredStackSize) {
+		sub.asAluRmiR(aluRmiROpcodeSub, tmpIntRegVReg, newOperandImm32(uint32(requiredStackSize)), tmpIntRegVReg, true)
+	} else {
+		panic(fmt.Sprintf("BUG: too large required stack size: %d", requiredStackSize))
+	}
+	cur = linkInstr(cur, sub)
+
+	cmp := m.allocateInstr()
+	cmp.asCmpRmiR(true, tmpIntRegVReg,
+		newOperandMem(m.newAmodeImmReg(wazevoapi.ExecutionContextOffsetStackBottomPtr.U32(), execCtx)), true)
+	cur = linkInstr(cur, cmp)
+
+	okLabel := m.allocateLabel()
+	jae := m.allocateInstr()
+	jae.asJmpIf(condNB, okLabel)
+	cur = linkInstr(cur, jae)
+
+	// Set the required stack size to the exec context.
+	loadSize := m.allocateInstr()
+	loadSize.asImm(tmpIntRegVReg, uint64(requiredStackSize), true)
+	cur = linkInstr(cur, loadSize)
+	setRequiredStackSize := m.allocateInstr()
+	setRequiredStackSize.asMovRM(newOperandReg(tmpIntRegVReg),
+		m.newAmodeImmReg(wazevoapi.ExecutionContextOffsetStackGrowRequiredSize.U32(), execCtx), 8)
+	cur = linkInstr(cur, setRequiredStackSize)
+
+	// Then calls the stack grow call sequence, meaning transferring the control to the code compiled
+	// by CompileStackGrowCallSequence.
+	call := m.allocateInstr()
+	call.asCallIndirect(newOperandMem(m.newAmodeImmReg(wazevoapi.ExecutionContextOffsetStackGrowCallTrampolineAddress.U32(), execCtx)), nil, 0)
+	cur = linkInstr(cur, call)
+
+	ok := m.allocateInstr()
+	ok.asNop0WithLabel(okLabel)
+	return linkInstr(cur, ok)
+}
+
+// CompileStackGrowCallSequence implements backend.Machine.
+func (m *machine) CompileStackGrowCallSequence() []byte {
+	cur := m.allocateNop()
+	m.rootInstr = cur
+
+	// Execution context is always the first argument.
+	execCtx := raxVReg
+
+	// Save the callee saved and argument registers.
+	cur = m.saveRegistersInExecutionContext(cur, execCtx, saveRequiredRegs)
+
+	// Set the exit status on the execution context.
+	cur = m.setExitCode(cur, execCtx, wazevoapi.ExitCodeGrowStack)
+
+	// Exit the execution, which resumes right after the exit sequence once the stack is grown.
+	exit := m.allocateInstr()
+	exit.asExitSequence(execCtx)
+	cur = linkInstr(cur, exit)
+
+	// After the exit, restore the saved registers.
+	cur = m.restoreRegistersInExecutionContext(cur, execCtx, saveRequiredRegs)
+
+	// Then goes back the original address of this stack grow call.
+	ret := m.allocateInstr()
+	ret.asRet(nil)
+	linkInstr(cur, ret)
+
+	m.encode(m.rootInstr)
+	return m.compiler.Buf()
+}
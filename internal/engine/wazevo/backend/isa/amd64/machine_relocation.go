@@ -0,0 +1,24 @@
+package amd64
+
+import (
+	"encoding/binary"
+
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend"
+	"github.com/AR1011/wazero/internal/engine/wazevo/ssa"
+)
+
+// ResolveRelocations implements backend.Machine ResolveRelocations.
+func (m *machine) ResolveRelocations(refToBinaryOffset map[ssa.FuncRef]int, binary []byte, relocations []backend.RelocationInfo) {
+	for _, r := range relocations {
+		// The relocation points to the beginning of `call rel32` (0xe8 + rel32), and
+		// rel32 is relative to the end of the call instruction.
+		instrOffset := r.Offset
+		calleeFnOffset := refToBinaryOffset[r.FuncRef]
+		diff := int64(calleeFnOffset) - (instrOffset + 5)
+		putUint32(binary[instrOffset+1:], uint32(int32(diff)))
+	}
+}
+
+func putUint32(b []byte, v uint32) {
+	binary.LittleEndian.PutUint32(b, v)
+}
@@ -0,0 +1,170 @@
+package amd64
+
+import (
+	"fmt"
+
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+)
+
+type (
+	// operand represents an operand of an instruction, which is either a register, a memory location,
+	// a 32-bit immediate, or a label.
+	operand struct {
+		kind operandKind
+		// data is the register, the immediate or the label depending on the kind.
+		data uint64
+		// amode is valid only when kind == operandKindMem.
+		amode *amode
+	}
+
+	operandKind byte
+
+	// amode represents the addressing mode of the memory operand.
+	amode struct {
+		kind  amodeKind
+		shift byte
+		imm32 uint32
+		base  regalloc.VReg
+		index regalloc.VReg
+	}
+
+	amodeKind byte
+)
+
+const (
+	operandKindNone operandKind = iota
+	// operandKindReg is a register operand.
+	operandKindReg
+	// operandKindMem is a memory operand.
+	operandKindMem
+	// operandKindImm32 is a 32-bit immediate operand, which is sign-extended in the 64-bit instructions.
+	operandKindImm32
+	// operandKindLabel is a label operand used as the jump target.
+	operandKindLabel
+)
+
+const (
+	amodeKindInvalid amodeKind = iota
+	// amodeKindImmReg is [base + imm32].
+	amodeKindImmReg
+	// amodeKindRegRegShift is [base + index<<shift + imm32].
+	amodeKindRegRegShift
+	// amodeKindRipLabel is [rip + offset of the label], where imm32 holds the label.
+	amodeKindRipLabel
+	// amodeKindArgStackSpace is the same as amodeKindImmReg with rsp base, but the offset is relative to
+	// the beginning of the argument stack space of the current function, and resolved after the register allocation.
+	amodeKindArgStackSpace
+	// amodeKindResultStackSpace is the same as amodeKindArgStackSpace but for the result stack space.
+	amodeKindResultStackSpace
+)
+
+func newOperandReg(r regalloc.VReg) operand {
+	return operand{kind: operandKindReg, data: uint64(r)}
+}
+
+func newOperandMem(a *amode) operand {
+	return operand{kind: operandKindMem, amode: a}
+}
+
+func newOperandImm32(imm uint32) operand {
+	return operand{kind: operandKindImm32, data: uint64(imm)}
+}
+
+func newOperandLabel(l label) operand {
+	return operand{kind: operandKindLabel, data: uint64(l)}
+}
+
+// reg returns the register of the operand. This panics if the operand is not a register.
+func (o *operand) reg() regalloc.VReg {
+	if o.kind != operandKindReg {
+		panic("BUG: operand is not a register")
+	}
+	return regalloc.VReg(o.data)
+}
+
+// imm32 returns the 32-bit immediate of the operand. This panics if the operand is not an immediate.
+func (o *operand) imm32() uint32 {
+	if o.kind != operandKindImm32 {
+		panic("BUG: operand is not an immediate")
+	}
+	return uint32(o.data)
+}
+
+func (o *operand) label() label {
+	if o.kind != operandKindLabel {
+		panic("BUG: operand is not a label")
+	}
+	return label(o.data)
+}
+
+// fitsInImm32 returns true if the given 64-bit value can be represented as the sign-extended 32-bit immediate.
+func fitsInImm32(v int64) bool {
+	return int64(int32(v)) == v
+}
+
+func (o *operand) format(size byte) string {
+	switch o.kind {
+	case operandKindReg:
+		return formatVRegSized(o.reg(), size)
+	case operandKindMem:
+		return o.amode.String()
+	case operandKindImm32:
+		return fmt.Sprintf("$%d", int32(o.imm32()))
+	case operandKindLabel:
+		return o.label().String()
+	default:
+		return "<none>"
+	}
+}
+
+// String implements fmt.Stringer.
+func (a *amode) String() string {
+	switch a.kind {
+	case amodeKindImmReg:
+		if a.imm32 == 0 {
+			return fmt.Sprintf("(%s)", formatVRegSized(a.base, 8))
+		}
+		return fmt.Sprintf("%d(%s)", int32(a.imm32), formatVRegSized(a.base, 8))
+	case amodeKindRegRegShift:
+		if a.imm32 == 0 {
+			return fmt.Sprintf("(%s,%s,%d)", formatVRegSized(a.base, 8), formatVRegSized(a.index, 8), 1<<a.shift)
+		}
+		return fmt.Sprintf("%d(%s,%s,%d)", int32(a.imm32), formatVRegSized(a.base, 8), formatVRegSized(a.index, 8), 1<<a.shift)
+	case amodeKindRipLabel:
+		return fmt.Sprintf("%s(%%rip)", label(a.imm32))
+	case amodeKindArgStackSpace:
+		return fmt.Sprintf("arg_stack_space+%d(%%rsp)", int32(a.imm32))
+	case amodeKindResultStackSpace:
+		return fmt.Sprintf("result_stack_space+%d(%%rsp)", int32(a.imm32))
+	default:
+		panic("BUG: invalid amode kind")
+	}
+}
+
+// newAmodeImmReg allocates a new amode of [base + imm32].
+func (m *machine) newAmodeImmReg(imm32 uint32, base regalloc.VReg) *amode {
+	a := m.amodePool.Allocate()
+	*a = amode{kind: amodeKindImmReg, imm32: imm32, base: base}
+	return a
+}
+
+// newAmodeRegRegShift allocates a new amode of [base + index<<shift + imm32].
+func (m *machine) newAmodeRegRegShift(imm32 uint32, base, index regalloc.VReg, shift byte) *amode {
+	if shift > 3 {
+		panic(fmt.Sprintf("BUG: invalid shift (must be 0-3): %d", shift))
+	}
+	a := m.amodePool.Allocate()
+	*a = amode{kind: amodeKindRegRegShift, imm32: imm32, base: base, index: index, shift: shift}
+	return a
+}
+
+// newAmodeRipLabel allocates a new amode of [rip + label].
+func (m *machine) newAmodeRipLabel(l label) *amode {
+	a := m.amodePool.Allocate()
+	*a = amode{kind: amodeKindRipLabel, imm32: uint32(l)}
+	return a
+}
+
+func resetAmode(a *amode) {
+	*a = amode{}
+}
@@ -0,0 +1,186 @@
+package amd64
+
+import (
+	"fmt"
+
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/regalloc"
+)
+
+// Amd64-specific registers.
+//
+// The order of the general purpose registers and the vector registers matches the encoding of them
+// in the ModR/M and REX bytes, i.e. rax has the encoding 0, rcx 1, ..., r15 15, and so on.
+//
+// See https://wiki.osdev.org/X86-64_Instruction_Encoding#Registers
+
+const (
+	// General purpose registers. Note that we do not distinguish eax/rax, etc. registers
+	// because they are the same from the perspective of register allocator, and
+	// the size can be determined by the type of the instruction.
+
+	rax = regalloc.RealRegInvalid + 1 + iota
+	rcx
+	rdx
+	rbx
+	rsp
+	rbp
+	rsi
+	rdi
+	r8
+	r9
+	r10
+	r11
+	r12
+	r13
+	r14
+	r15
+
+	// Vector registers. Note that we do not distinguish xmm0 and ymm0, ... registers
+	// because we only use the SSE instructions.
+
+	xmm0
+	xmm1
+	xmm2
+	xmm3
+	xmm4
+	xmm5
+	xmm6
+	xmm7
+	xmm8
+	xmm9
+	xmm10
+	xmm11
+	xmm12
+	xmm13
+	xmm14
+	xmm15
+)
+
+var (
+	raxVReg = regalloc.FromRealReg(rax, regalloc.RegTypeInt)
+	rcxVReg = regalloc.FromRealReg(rcx, regalloc.RegTypeInt)
+	rdxVReg = regalloc.FromRealReg(rdx, regalloc.RegTypeInt)
+	rbxVReg = regalloc.FromRealReg(rbx, regalloc.RegTypeInt)
+	rspVReg = regalloc.FromRealReg(rsp, regalloc.RegTypeInt)
+	rbpVReg = regalloc.FromRealReg(rbp, regalloc.RegTypeInt)
+	rsiVReg = regalloc.FromRealReg(rsi, regalloc.RegTypeInt)
+	rdiVReg = regalloc.FromRealReg(rdi, regalloc.RegTypeInt)
+	r8VReg  = regalloc.FromRealReg(r8, regalloc.RegTypeInt)
+	r9VReg  = regalloc.FromRealReg(r9, regalloc.RegTypeInt)
+	r10VReg = regalloc.FromRealReg(r10, regalloc.RegTypeInt)
+	r11VReg = regalloc.FromRealReg(r11, regalloc.RegTypeInt)
+	r12VReg = regalloc.FromRealReg(r12, regalloc.RegTypeInt)
+	r13VReg = regalloc.FromRealReg(r13, regalloc.RegTypeInt)
+	r14VReg = regalloc.FromRealReg(r14, regalloc.RegTypeInt)
+	r15VReg = regalloc.FromRealReg(r15, regalloc.RegTypeInt)
+
+	xmm0VReg  = regalloc.FromRealReg(xmm0, regalloc.RegTypeFloat)
+	xmm1VReg  = regalloc.FromRealReg(xmm1, regalloc.RegTypeFloat)
+	xmm2VReg  = regalloc.FromRealReg(xmm2, regalloc.RegTypeFloat)
+	xmm3VReg  = regalloc.FromRealReg(xmm3, regalloc.RegTypeFloat)
+	xmm4VReg  = regalloc.FromRealReg(xmm4, regalloc.RegTypeFloat)
+	xmm5VReg  = regalloc.FromRealReg(xmm5, regalloc.RegTypeFloat)
+	xmm6VReg  = regalloc.FromRealReg(xmm6, regalloc.RegTypeFloat)
+	xmm7VReg  = regalloc.FromRealReg(xmm7, regalloc.RegTypeFloat)
+	xmm8VReg  = regalloc.FromRealReg(xmm8, regalloc.RegTypeFloat)
+	xmm9VReg  = regalloc.FromRealReg(xmm9, regalloc.RegTypeFloat)
+	xmm10VReg = regalloc.FromRealReg(xmm10, regalloc.RegTypeFloat)
+	xmm11VReg = regalloc.FromRealReg(xmm11, regalloc.RegTypeFloat)
+	xmm12VReg = regalloc.FromRealReg(xmm12, regalloc.RegTypeFloat)
+	xmm13VReg = regalloc.FromRealReg(xmm13, regalloc.RegTypeFloat)
+	xmm14VReg = regalloc.FromRealReg(xmm14, regalloc.RegTypeFloat)
+	xmm15VReg = regalloc.FromRealReg(xmm15, regalloc.RegTypeFloat)
+
+	// tmpIntReg is the general purpose register which is never allocated by the register allocator,
+	// and used for the address computation, the large constants, etc. in the machine-level sequences
+	// after the register allocation as well as inside the pseudo instructions.
+	// Therefore, be cautious to use this register in the middle of the compilation.
+	tmpIntReg     = r11
+	tmpIntRegVReg = r11VReg
+	// tmpFloatRegVReg is the vector register which is never allocated by the register allocator.
+	// Similar to tmpIntRegVReg, this is used inside the pseudo instructions.
+	tmpFloatRegVReg = xmm15VReg
+)
+
+var regNames = [...]string{
+	rax:   "rax",
+	rcx:   "rcx",
+	rdx:   "rdx",
+	rbx:   "rbx",
+	rsp:   "rsp",
+	rbp:   "rbp",
+	rsi:   "rsi",
+	rdi:   "rdi",
+	r8:    "r8",
+	r9:    "r9",
+	r10:   "r10",
+	r11:   "r11",
+	r12:   "r12",
+	r13:   "r13",
+	r14:   "r14",
+	r15:   "r15",
+	xmm0:  "xmm0",
+	xmm1:  "xmm1",
+	xmm2:  "xmm2",
+	xmm3:  "xmm3",
+	xmm4:  "xmm4",
+	xmm5:  "xmm5",
+	xmm6:  "xmm6",
+	xmm7:  "xmm7",
+	xmm8:  "xmm8",
+	xmm9:  "xmm9",
+	xmm10: "xmm10",
+	xmm11: "xmm11",
+	xmm12: "xmm12",
+	xmm13: "xmm13",
+	xmm14: "xmm14",
+	xmm15: "xmm15",
+}
+
+// regEncoding returns the 4-bit encoding of the given real register, where the fourth bit
+// is carried in the REX prefix.
+func regEncoding(r regalloc.RealReg) byte {
+	if r >= xmm0 {
+		return byte(r - xmm0)
+	}
+	return byte(r - rax)
+}
+
+// formatVRegSized returns the string representation of the given virtual register
+// with the size in bytes, e.g. "eax" for 4 bytes, "rax" for 8 bytes.
+func formatVRegSized(r regalloc.VReg, size byte) string {
+	if !r.IsRealReg() {
+		return r.String()
+	}
+	real := r.RealReg()
+	if real >= xmm0 {
+		return "%" + regNames[real]
+	}
+	name := regNames[real]
+	switch size {
+	case 8:
+		return "%" + name
+	case 4:
+		if real >= r8 {
+			return "%" + name + "d"
+		}
+		return "%e" + name[1:]
+	case 2:
+		if real >= r8 {
+			return "%" + name + "w"
+		}
+		return "%" + name[1:]
+	case 1:
+		if real >= r8 {
+			return "%" + name + "b"
+		}
+		switch real {
+		case rax, rcx, rdx, rbx:
+			return "%" + name[1:2] + "l"
+		default:
+			return "%" + name[1:] + "l"
+		}
+	default:
+		panic(fmt.Sprintf("BUG: invalid size %d", size))
+	}
+}
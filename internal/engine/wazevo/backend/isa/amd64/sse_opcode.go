@@ -0,0 +1,383 @@
+package amd64
+
+// sseOpcode represents the SSE instructions operating on the vector registers.
+type sseOpcode byte
+
+const (
+	sseOpcodeInvalid sseOpcode = iota
+	sseOpcodeAddps
+	sseOpcodeAddpd
+	sseOpcodeAddss
+	sseOpcodeAddsd
+	sseOpcodeAndps
+	sseOpcodeAndpd
+	sseOpcodeAndnps
+	sseOpcodeAndnpd
+	sseOpcodeCmpps
+	sseOpcodeCmppd
+	sseOpcodeCvtdq2ps
+	sseOpcodeCvtdq2pd
+	sseOpcodeCvtps2pd
+	sseOpcodeCvtpd2ps
+	sseOpcodeCvtss2sd
+	sseOpcodeCvtsd2ss
+	sseOpcodeCvttps2dq
+	sseOpcodeCvttpd2dq
+	sseOpcodeCvtsi2ss
+	sseOpcodeCvtsi2sd
+	sseOpcodeCvttss2si
+	sseOpcodeCvttsd2si
+	sseOpcodeDivps
+	sseOpcodeDivpd
+	sseOpcodeDivss
+	sseOpcodeDivsd
+	sseOpcodeInsertps
+	sseOpcodeMaxps
+	sseOpcodeMaxpd
+	sseOpcodeMaxss
+	sseOpcodeMaxsd
+	sseOpcodeMinps
+	sseOpcodeMinpd
+	sseOpcodeMinss
+	sseOpcodeMinsd
+	sseOpcodeMovd
+	sseOpcodeMovq
+	sseOpcodeMovdqa
+	sseOpcodeMovdqu
+	sseOpcodeMovss
+	sseOpcodeMovsd
+	sseOpcodeMovlhps
+	sseOpcodeMovhlps
+	sseOpcodeMovmskps
+	sseOpcodeMovmskpd
+	sseOpcodeMulps
+	sseOpcodeMulpd
+	sseOpcodeMulss
+	sseOpcodeMulsd
+	sseOpcodeOrps
+	sseOpcodeOrpd
+	sseOpcodePabsb
+	sseOpcodePabsw
+	sseOpcodePabsd
+	sseOpcodePackssdw
+	sseOpcodePacksswb
+	sseOpcodePackusdw
+	sseOpcodePackuswb
+	sseOpcodePaddb
+	sseOpcodePaddw
+	sseOpcodePaddd
+	sseOpcodePaddq
+	sseOpcodePaddsb
+	sseOpcodePaddsw
+	sseOpcodePaddusb
+	sseOpcodePaddusw
+	sseOpcodePalignr
+	sseOpcodePand
+	sseOpcodePandn
+	sseOpcodePavgb
+	sseOpcodePavgw
+	sseOpcodePblendw
+	sseOpcodePcmpeqb
+	sseOpcodePcmpeqw
+	sseOpcodePcmpeqd
+	sseOpcodePcmpeqq
+	sseOpcodePcmpgtb
+	sseOpcodePcmpgtw
+	sseOpcodePcmpgtd
+	sseOpcodePcmpgtq
+	sseOpcodePextrb
+	sseOpcodePextrw
+	sseOpcodePextrd
+	sseOpcodePextrq
+	sseOpcodePhaddw
+	sseOpcodePhaddd
+	sseOpcodePinsrb
+	sseOpcodePinsrw
+	sseOpcodePinsrd
+	sseOpcodePinsrq
+	sseOpcodePmaddwd
+	sseOpcodePmaxsb
+	sseOpcodePmaxsw
+	sseOpcodePmaxsd
+	sseOpcodePmaxub
+	sseOpcodePmaxuw
+	sseOpcodePmaxud
+	sseOpcodePminsb
+	sseOpcodePminsw
+	sseOpcodePminsd
+	sseOpcodePminub
+	sseOpcodePminuw
+	sseOpcodePminud
+	sseOpcodePmovmskb
+	sseOpcodePmovsxbw
+	sseOpcodePmovsxwd
+	sseOpcodePmovsxdq
+	sseOpcodePmovzxbw
+	sseOpcodePmovzxwd
+	sseOpcodePmovzxdq
+	sseOpcodePmulhrsw
+	sseOpcodePmulhw
+	sseOpcodePmulhuw
+	sseOpcodePmulld
+	sseOpcodePmullw
+	sseOpcodePmuludq
+	sseOpcodePor
+	sseOpcodePshufb
+	sseOpcodePshufd
+	sseOpcodePsllw
+	sseOpcodePslld
+	sseOpcodePsllq
+	sseOpcodePsraw
+	sseOpcodePsrad
+	sseOpcodePsrlw
+	sseOpcodePsrld
+	sseOpcodePsrlq
+	sseOpcodePsrldq
+	sseOpcodePslldq
+	sseOpcodePsubb
+	sseOpcodePsubw
+	sseOpcodePsubd
+	sseOpcodePsubq
+	sseOpcodePsubsb
+	sseOpcodePsubsw
+	sseOpcodePsubusb
+	sseOpcodePsubusw
+	sseOpcodePtest
+	sseOpcodePunpckhbw
+	sseOpcodePunpckhwd
+	sseOpcodePunpckhdq
+	sseOpcodePunpckhqdq
+	sseOpcodePunpcklbw
+	sseOpcodePunpcklwd
+	sseOpcodePunpckldq
+	sseOpcodePunpcklqdq
+	sseOpcodePxor
+	sseOpcodeRoundps
+	sseOpcodeRoundpd
+	sseOpcodeRoundss
+	sseOpcodeRoundsd
+	sseOpcodeShufps
+	sseOpcodeSqrtps
+	sseOpcodeSqrtpd
+	sseOpcodeSqrtss
+	sseOpcodeSqrtsd
+	sseOpcodeSubps
+	sseOpcodeSubpd
+	sseOpcodeSubss
+	sseOpcodeSubsd
+	sseOpcodeUcomiss
+	sseOpcodeUcomisd
+	sseOpcodeUnpcklps
+	sseOpcodeXorps
+	sseOpcodeXorpd
+
+	sseOpcodeNum
+)
+
+// sseOpcodeInfo is the encoding information of sseOpcode.
+type sseOpcodeInfo struct {
+	name string
+	// prefix is the mandatory prefix (0x66, 0xf2 or 0xf3), or zero if none.
+	prefix byte
+	// opcode is the opcode bytes excluding the prefixes, and opcodeLen is the number of the valid bytes.
+	opcode    [3]byte
+	opcodeLen byte
+	// storeOpcode is the last opcode byte for the store variant of the move instructions.
+	storeOpcode byte
+	// commutative is true if the operands of the binary operation can be swapped.
+	commutative bool
+}
+
+func sse(name string, prefix byte, opcode ...byte) sseOpcodeInfo {
+	info := sseOpcodeInfo{name: name, prefix: prefix, opcodeLen: byte(len(opcode))}
+	copy(info.opcode[:], opcode)
+	return info
+}
+
+func sseCommutative(name string, prefix byte, opcode ...byte) sseOpcodeInfo {
+	info := sse(name, prefix, opcode...)
+	info.commutative = true
+	return info
+}
+
+func sseMov(name string, prefix byte, load, store byte) sseOpcodeInfo {
+	info := sse(name, prefix, 0x0f, load)
+	info.storeOpcode = store
+	return info
+}
+
+var sseOpcodeInfos = [sseOpcodeNum]sseOpcodeInfo{
+	sseOpcodeAddps:      sseCommutative("addps", 0, 0x0f, 0x58),
+	sseOpcodeAddpd:      sseCommutative("addpd", 0x66, 0x0f, 0x58),
+	sseOpcodeAddss:      sseCommutative("addss", 0xf3, 0x0f, 0x58),
+	sseOpcodeAddsd:      sseCommutative("addsd", 0xf2, 0x0f, 0x58),
+	sseOpcodeAndps:      sseCommutative("andps", 0, 0x0f, 0x54),
+	sseOpcodeAndpd:      sseCommutative("andpd", 0x66, 0x0f, 0x54),
+	sseOpcodeAndnps:     sse("andnps", 0, 0x0f, 0x55),
+	sseOpcodeAndnpd:     sse("andnpd", 0x66, 0x0f, 0x55),
+	sseOpcodeCmpps:      sse("cmpps", 0, 0x0f, 0xc2),
+	sseOpcodeCmppd:      sse("cmppd", 0x66, 0x0f, 0xc2),
+	sseOpcodeCvtdq2ps:   sse("cvtdq2ps", 0, 0x0f, 0x5b),
+	sseOpcodeCvtdq2pd:   sse("cvtdq2pd", 0xf3, 0x0f, 0xe6),
+	sseOpcodeCvtps2pd:   sse("cvtps2pd", 0, 0x0f, 0x5a),
+	sseOpcodeCvtpd2ps:   sse("cvtpd2ps", 0x66, 0x0f, 0x5a),
+	sseOpcodeCvtss2sd:   sse("cvtss2sd", 0xf3, 0x0f, 0x5a),
+	sseOpcodeCvtsd2ss:   sse("cvtsd2ss", 0xf2, 0x0f, 0x5a),
+	sseOpcodeCvttps2dq:  sse("cvttps2dq", 0xf3, 0x0f, 0x5b),
+	sseOpcodeCvttpd2dq:  sse("cvttpd2dq", 0x66, 0x0f, 0xe6),
+	sseOpcodeCvtsi2ss:   sse("cvtsi2ss", 0xf3, 0x0f, 0x2a),
+	sseOpcodeCvtsi2sd:   sse("cvtsi2sd", 0xf2, 0x0f, 0x2a),
+	sseOpcodeCvttss2si:  sse("cvttss2si", 0xf3, 0x0f, 0x2c),
+	sseOpcodeCvttsd2si:  sse("cvttsd2si", 0xf2, 0x0f, 0x2c),
+	sseOpcodeDivps:      sse("divps", 0, 0x0f, 0x5e),
+	sseOpcodeDivpd:      sse("divpd", 0x66, 0x0f, 0x5e),
+	sseOpcodeDivss:      sse("divss", 0xf3, 0x0f, 0x5e),
+	sseOpcodeDivsd:      sse("divsd", 0xf2, 0x0f, 0x5e),
+	sseOpcodeInsertps:   sse("insertps", 0x66, 0x0f, 0x3a, 0x21),
+	sseOpcodeMaxps:      sse("maxps", 0, 0x0f, 0x5f),
+	sseOpcodeMaxpd:      sse("maxpd", 0x66, 0x0f, 0x5f),
+	sseOpcodeMaxss:      sse("maxss", 0xf3, 0x0f, 0x5f),
+	sseOpcodeMaxsd:      sse("maxsd", 0xf2, 0x0f, 0x5f),
+	sseOpcodeMinps:      sse("minps", 0, 0x0f, 0x5d),
+	sseOpcodeMinpd:      sse("minpd", 0x66, 0x0f, 0x5d),
+	sseOpcodeMinss:      sse("minss", 0xf3, 0x0f, 0x5d),
+	sseOpcodeMinsd:      sse("minsd", 0xf2, 0x0f, 0x5d),
+	sseOpcodeMovd:       sseMov("movd", 0x66, 0x6e, 0x7e),
+	sseOpcodeMovq:       sseMov("movq", 0x66, 0x6e, 0x7e),
+	sseOpcodeMovdqa:     sseMov("movdqa", 0x66, 0x6f, 0x7f),
+	sseOpcodeMovdqu:     sseMov("movdqu", 0xf3, 0x6f, 0x7f),
+	sseOpcodeMovss:      sseMov("movss", 0xf3, 0x10, 0x11),
+	sseOpcodeMovsd:      sseMov("movsd", 0xf2, 0x10, 0x11),
+	sseOpcodeMovlhps:    sse("movlhps", 0, 0x0f, 0x16),
+	sseOpcodeMovhlps:    sse("movhlps", 0, 0x0f, 0x12),
+	sseOpcodeMovmskps:   sse("movmskps", 0, 0x0f, 0x50),
+	sseOpcodeMovmskpd:   sse("movmskpd", 0x66, 0x0f, 0x50),
+	sseOpcodeMulps:      sseCommutative("mulps", 0, 0x0f, 0x59),
+	sseOpcodeMulpd:      sseCommutative("mulpd", 0x66, 0x0f, 0x59),
+	sseOpcodeMulss:      sseCommutative("mulss", 0xf3, 0x0f, 0x59),
+	sseOpcodeMulsd:      sseCommutative("mulsd", 0xf2, 0x0f, 0x59),
+	sseOpcodeOrps:       sseCommutative("orps", 0, 0x0f, 0x56),
+	sseOpcodeOrpd:       sseCommutative("orpd", 0x66, 0x0f, 0x56),
+	sseOpcodePabsb:      sse("pabsb", 0x66, 0x0f, 0x38, 0x1c),
+	sseOpcodePabsw:      sse("pabsw", 0x66, 0x0f, 0x38, 0x1d),
+	sseOpcodePabsd:      sse("pabsd", 0x66, 0x0f, 0x38, 0x1e),
+	sseOpcodePackssdw:   sse("packssdw", 0x66, 0x0f, 0x6b),
+	sseOpcodePacksswb:   sse("packsswb", 0x66, 0x0f, 0x63),
+	sseOpcodePackusdw:   sse("packusdw", 0x66, 0x0f, 0x38, 0x2b),
+	sseOpcodePackuswb:   sse("packuswb", 0x66, 0x0f, 0x67),
+	sseOpcodePaddb:      sseCommutative("paddb", 0x66, 0x0f, 0xfc),
+	sseOpcodePaddw:      sseCommutative("paddw", 0x66, 0x0f, 0xfd),
+	sseOpcodePaddd:      sseCommutative("paddd", 0x66, 0x0f, 0xfe),
+	sseOpcodePaddq:      sseCommutative("paddq", 0x66, 0x0f, 0xd4),
+	sseOpcodePaddsb:     sseCommutative("paddsb", 0x66, 0x0f, 0xec),
+	sseOpcodePaddsw:     sseCommutative("paddsw", 0x66, 0x0f, 0xed),
+	sseOpcodePaddusb:    sseCommutative("paddusb", 0x66, 0x0f, 0xdc),
+	sseOpcodePaddusw:    sseCommutative("paddusw", 0x66, 0x0f, 0xdd),
+	sseOpcodePalignr:    sse("palignr", 0x66, 0x0f, 0x3a, 0x0f),
+	sseOpcodePand:       sseCommutative("pand", 0x66, 0x0f, 0xdb),
+	sseOpcodePandn:      sse("pandn", 0x66, 0x0f, 0xdf),
+	sseOpcodePavgb:      sseCommutative("pavgb", 0x66, 0x0f, 0xe0),
+	sseOpcodePavgw:      sseCommutative("pavgw", 0x66, 0x0f, 0xe3),
+	sseOpcodePblendw:    sse("pblendw", 0x66, 0x0f, 0x3a, 0x0e),
+	sseOpcodePcmpeqb:    sseCommutative("pcmpeqb", 0x66, 0x0f, 0x74),
+	sseOpcodePcmpeqw:    sseCommutative("pcmpeqw", 0x66, 0x0f, 0x75),
+	sseOpcodePcmpeqd:    sseCommutative("pcmpeqd", 0x66, 0x0f, 0x76),
+	sseOpcodePcmpeqq:    sseCommutative("pcmpeqq", 0x66, 0x0f, 0x38, 0x29),
+	sseOpcodePcmpgtb:    sse("pcmpgtb", 0x66, 0x0f, 0x64),
+	sseOpcodePcmpgtw:    sse("pcmpgtw", 0x66, 0x0f, 0x65),
+	sseOpcodePcmpgtd:    sse("pcmpgtd", 0x66, 0x0f, 0x66),
+	sseOpcodePcmpgtq:    sse("pcmpgtq", 0x66, 0x0f, 0x38, 0x37),
+	sseOpcodePextrb:     sse("pextrb", 0x66, 0x0f, 0x3a, 0x14),
+	sseOpcodePextrw:     sse("pextrw", 0x66, 0x0f, 0xc5),
+	sseOpcodePextrd:     sse("pextrd", 0x66, 0x0f, 0x3a, 0x16),
+	sseOpcodePextrq:     sse("pextrq", 0x66, 0x0f, 0x3a, 0x16),
+	sseOpcodePhaddw:     sse("phaddw", 0x66, 0x0f, 0x38, 0x01),
+	sseOpcodePhaddd:     sse("phaddd", 0x66, 0x0f, 0x38, 0x02),
+	sseOpcodePinsrb:     sse("pinsrb", 0x66, 0x0f, 0x3a, 0x20),
+	sseOpcodePinsrw:     sse("pinsrw", 0x66, 0x0f, 0xc4),
+	sseOpcodePinsrd:     sse("pinsrd", 0x66, 0x0f, 0x3a, 0x22),
+	sseOpcodePinsrq:     sse("pinsrq", 0x66, 0x0f, 0x3a, 0x22),
+	sseOpcodePmaddwd:    sseCommutative("pmaddwd", 0x66, 0x0f, 0xf5),
+	sseOpcodePmaxsb:     sseCommutative("pmaxsb", 0x66, 0x0f, 0x38, 0x3c),
+	sseOpcodePmaxsw:     sseCommutative("pmaxsw", 0x66, 0x0f, 0xee),
+	sseOpcodePmaxsd:     sseCommutative("pmaxsd", 0x66, 0x0f, 0x38, 0x3d),
+	sseOpcodePmaxub:     sseCommutative("pmaxub", 0x66, 0x0f, 0xde),
+	sseOpcodePmaxuw:     sseCommutative("pmaxuw", 0x66, 0x0f, 0x38, 0x3e),
+	sseOpcodePmaxud:     sseCommutative("pmaxud", 0x66, 0x0f, 0x38, 0x3f),
+	sseOpcodePminsb:     sseCommutative("pminsb", 0x66, 0x0f, 0x38, 0x38),
+	sseOpcodePminsw:     sseCommutative("pminsw", 0x66, 0x0f, 0xea),
+	sseOpcodePminsd:     sseCommutative("pminsd", 0x66, 0x0f, 0x38, 0x39),
+	sseOpcodePminub:     sseCommutative("pminub", 0x66, 0x0f, 0xda),
+	sseOpcodePminuw:     sseCommutative("pminuw", 0x66, 0x0f, 0x38, 0x3a),
+	sseOpcodePminud:     sseCommutative("pminud", 0x66, 0x0f, 0x38, 0x3b),
+	sseOpcodePmovmskb:   sse("pmovmskb", 0x66, 0x0f, 0xd7),
+	sseOpcodePmovsxbw:   sse("pmovsxbw", 0x66, 0x0f, 0x38, 0x20),
+	sseOpcodePmovsxwd:   sse("pmovsxwd", 0x66, 0x0f, 0x38, 0x23),
+	sseOpcodePmovsxdq:   sse("pmovsxdq", 0x66, 0x0f, 0x38, 0x25),
+	sseOpcodePmovzxbw:   sse("pmovzxbw", 0x66, 0x0f, 0x38, 0x30),
+	sseOpcodePmovzxwd:   sse("pmovzxwd", 0x66, 0x0f, 0x38, 0x33),
+	sseOpcodePmovzxdq:   sse("pmovzxdq", 0x66, 0x0f, 0x38, 0x35),
+	sseOpcodePmulhrsw:   sseCommutative("pmulhrsw", 0x66, 0x0f, 0x38, 0x0b),
+	sseOpcodePmulhw:     sseCommutative("pmulhw", 0x66, 0x0f, 0xe5),
+	sseOpcodePmulhuw:    sseCommutative("pmulhuw", 0x66, 0x0f, 0xe4),
+	sseOpcodePmulld:     sseCommutative("pmulld", 0x66, 0x0f, 0x38, 0x40),
+	sseOpcodePmullw:     sseCommutative("pmullw", 0x66, 0x0f, 0xd5),
+	sseOpcodePmuludq:    sseCommutative("pmuludq", 0x66, 0x0f, 0xf4),
+	sseOpcodePor:        sseCommutative("por", 0x66, 0x0f, 0xeb),
+	sseOpcodePshufb:     sse("pshufb", 0x66, 0x0f, 0x38, 0x00),
+	sseOpcodePshufd:     sse("pshufd", 0x66, 0x0f, 0x70),
+	sseOpcodePsllw:      sse("psllw", 0x66, 0x0f, 0xf1),
+	sseOpcodePslld:      sse("pslld", 0x66, 0x0f, 0xf2),
+	sseOpcodePsllq:      sse("psllq", 0x66, 0x0f, 0xf3),
+	sseOpcodePsraw:      sse("psraw", 0x66, 0x0f, 0xe1),
+	sseOpcodePsrad:      sse("psrad", 0x66, 0x0f, 0xe2),
+	sseOpcodePsrlw:      sse("psrlw", 0x66, 0x0f, 0xd1),
+	sseOpcodePsrld:      sse("psrld", 0x66, 0x0f, 0xd2),
+	sseOpcodePsrlq:      sse("psrlq", 0x66, 0x0f, 0xd3),
+	sseOpcodePsrldq:     sse("psrldq", 0x66, 0x0f, 0x73),
+	sseOpcodePslldq:     sse("pslldq", 0x66, 0x0f, 0x73),
+	sseOpcodePsubb:      sse("psubb", 0x66, 0x0f, 0xf8),
+	sseOpcodePsubw:      sse("psubw", 0x66, 0x0f, 0xf9),
+	sseOpcodePsubd:      sse("psubd", 0x66, 0x0f, 0xfa),
+	sseOpcodePsubq:      sse("psubq", 0x66, 0x0f, 0xfb),
+	sseOpcodePsubsb:     sse("psubsb", 0x66, 0x0f, 0xe8),
+	sseOpcodePsubsw:     sse("psubsw", 0x66, 0x0f, 0xe9),
+	sseOpcodePsubusb:    sse("psubusb", 0x66, 0x0f, 0xd8),
+	sseOpcodePsubusw:    sse("psubusw", 0x66, 0x0f, 0xd9),
+	sseOpcodePtest:      sse("ptest", 0x66, 0x0f, 0x38, 0x17),
+	sseOpcodePunpckhbw:  sse("punpckhbw", 0x66, 0x0f, 0x68),
+	sseOpcodePunpckhwd:  sse("punpckhwd", 0x66, 0x0f, 0x69),
+	sseOpcodePunpckhdq:  sse("punpckhdq", 0x66, 0x0f, 0x6a),
+	sseOpcodePunpckhqdq: sse("punpckhqdq", 0x66, 0x0f, 0x6d),
+	sseOpcodePunpcklbw:  sse("punpcklbw", 0x66, 0x0f, 0x60),
+	sseOpcodePunpcklwd:  sse("punpcklwd", 0x66, 0x0f, 0x61),
+	sseOpcodePunpckldq:  sse("punpckldq", 0x66, 0x0f, 0x62),
+	sseOpcodePunpcklqdq: sse("punpcklqdq", 0x66, 0x0f, 0x6c),
+	sseOpcodePxor:       sseCommutative("pxor", 0x66, 0x0f, 0xef),
+	sseOpcodeRoundps:    sse("roundps", 0x66, 0x0f, 0x3a, 0x08),
+	sseOpcodeRoundpd:    sse("roundpd", 0x66, 0x0f, 0x3a, 0x09),
+	sseOpcodeRoundss:    sse("roundss", 0x66, 0x0f, 0x3a, 0x0a),
+	sseOpcodeRoundsd:    sse("roundsd", 0x66, 0x0f, 0x3a, 0x0b),
+	sseOpcodeShufps:     sse("shufps", 0, 0x0f, 0xc6),
+	sseOpcodeSqrtps:     sse("sqrtps", 0, 0x0f, 0x51),
+	sseOpcodeSqrtpd:     sse("sqrtpd", 0x66, 0x0f, 0x51),
+	sseOpcodeSqrtss:     sse("sqrtss", 0xf3, 0x0f, 0x51),
+	sseOpcodeSqrtsd:     sse("sqrtsd", 0xf2, 0x0f, 0x51),
+	sseOpcodeSubps:      sse("subps", 0, 0x0f, 0x5c),
+	sseOpcodeSubpd:      sse("subpd", 0x66, 0x0f, 0x5c),
+	sseOpcodeSubss:      sse("subss", 0xf3, 0x0f, 0x5c),
+	sseOpcodeSubsd:      sse("subsd", 0xf2, 0x0f, 0x5c),
+	sseOpcodeUcomiss:    sse("ucomiss", 0, 0x0f, 0x2e),
+	sseOpcodeUcomisd:    sse("ucomisd", 0x66, 0x0f, 0x2e),
+	sseOpcodeUnpcklps:   sse("unpcklps", 0, 0x0f, 0x14),
+	sseOpcodeXorps:      sseCommutative("xorps", 0, 0x0f, 0x57),
+	sseOpcodeXorpd:      sseCommutative("xorpd", 0x66, 0x0f, 0x57),
+}
+
+// String implements fmt.Stringer.
+func (s sseOpcode) String() string {
+	if s == sseOpcodeInvalid || s >= sseOpcodeNum {
+		panic("BUG: invalid sseOpcode")
+	}
+	return sseOpcodeInfos[s].name
+}
@@ -403,7 +403,11 @@ func (a *Allocator) livenessAnalysis(f Function) {
 			if def.Valid() && s.phiBlk(def) != nil {
 				if use.Valid() && use.IsRealReg() {
 					// If the source is a real register, this is the beginning of the function.
-					a.state.argRealRegs = append(a.state.argRealRegs, use)
+					// Note that the parameters passed on the stack are loaded relative to the stack pointer,
+					// which is not an argument register, so only the copies are recorded.
+					if instr.IsCopy() {
+						a.state.argRealRegs = append(a.state.argRealRegs, use)
+					}
 				} else {
 					// Otherwise, this is the definition of the phi value for the successor block.
 					// So we need to make it outlive the block.
@@ -4,16 +4,19 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"reflect"
 	"unsafe"
 
 	"github.com/AR1011/wazero/api"
 	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/internal/coredump"
 	"github.com/AR1011/wazero/internal/engine/wazevo/wazevoapi"
 	"github.com/AR1011/wazero/internal/internalapi"
 	"github.com/AR1011/wazero/internal/wasm"
 	"github.com/AR1011/wazero/internal/wasmdebug"
 	"github.com/AR1011/wazero/internal/wasmruntime"
+	"github.com/AR1011/wazero/sys"
 )
 
 type (
@@ -80,6 +83,14 @@ type (
 		refFuncTrampolineAddress *byte
 		// memmoveAddress holds the address of memmove function implemented by Go runtime. See memmove.go.
 		memmoveAddress uintptr
+		// atomicTrampolineAddress holds the address of the trampoline function for atomic instructions.
+		atomicTrampolineAddress *byte
+		// callStackDepth is the number of the frames of the functions compiled with wasm.Module MaxCallStackDepth.
+		callStackDepth uint64
+		// callStackDepthCeiling is the maximum of callStackDepth during the current call.
+		callStackDepthCeiling uint64
+		// epochDeadline is the epoch at which the current call traps in addition to wasm.ModuleInstance EpochDeadline.
+		epochDeadline uint64
 	}
 )
 
@@ -121,6 +132,11 @@ func (c *callEngine) Definition() api.FunctionDefinition {
 	return c.parent.module.Source.FunctionDefinition(c.indexInModule)
 }
 
+// FunctionReference returns the reference to this function as it's stored in a table, for api.Table SetFunction.
+func (c *callEngine) FunctionReference() wasm.Reference {
+	return c.parent.FunctionInstanceReference(c.indexInModule)
+}
+
 // Call implements api.Function.
 func (c *callEngine) Call(ctx context.Context, params ...uint64) ([]uint64, error) {
 	if c.requiredParams != len(params) {
@@ -134,7 +150,7 @@ func (c *callEngine) Call(ctx context.Context, params ...uint64) ([]uint64, erro
 	return paramResultSlice[:c.numberOfResults], nil
 }
 
-func (c *callEngine) addFrame(builder wasmdebug.ErrorBuilder, addr uintptr) (def api.FunctionDefinition, listener experimental.FunctionListener) {
+func (c *callEngine) addFrame(builder wasmdebug.ErrorBuilder, coreDump *coredump.Builder, addr uintptr) (def api.FunctionDefinition, listener experimental.FunctionListener) {
 	eng := c.parent.parent.parent
 	cm := eng.compiledModuleOfAddr(addr)
 	if cm != nil {
@@ -145,6 +161,10 @@ func (c *callEngine) addFrame(builder wasmdebug.ErrorBuilder, addr uintptr) (def
 			sourceOffset := cm.getSourceOffset(addr)
 			sources = dw.Line(sourceOffset)
 		}
+		// Only the module instance of the called function is known, as compiled modules are shared by instances.
+		if m := c.parent.module; coreDump != nil && cm.module == m.Source {
+			coreDump.AddFrame(m, cm.module.ImportFunctionCount+index, cm.getSourceOffset(addr), nil)
+		}
 		builder.AddFrame(def.DebugName(), def.ParamTypes(), def.ResultTypes(), sources)
 		if len(cm.listeners) > 0 {
 			listener = cm.listeners[index]
@@ -195,18 +215,25 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 
 			var listeners []listenerForAbort
 			builder := wasmdebug.NewErrorBuilder()
-			def, lsn := c.addFrame(builder, uintptr(unsafe.Pointer(c.execCtx.goCallReturnAddress)))
+			var coreDump *coredump.Builder
+			if m.Source.CoreDump {
+				coreDump = &coredump.Builder{}
+			}
+			def, lsn := c.addFrame(builder, coreDump, uintptr(unsafe.Pointer(c.execCtx.goCallReturnAddress)))
 			if lsn != nil {
 				listeners = append(listeners, listenerForAbort{def, lsn})
 			}
 			returnAddrs := unwindStack(uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)), c.stackTop, nil)
 			for _, retAddr := range returnAddrs[:len(returnAddrs)-1] { // the last return addr is the trampoline, so we skip it.
-				def, lsn = c.addFrame(builder, retAddr)
+				def, lsn = c.addFrame(builder, coreDump, retAddr)
 				if lsn != nil {
 					listeners = append(listeners, listenerForAbort{def, lsn})
 				}
 			}
 			err = builder.FromRecovered(r)
+			if coreDump != nil {
+				err = coreDump.Wrap(err)
+			}
 
 			for _, lsn := range listeners {
 				lsn.lsn.Abort(ctx, m, lsn.def, err)
@@ -228,6 +255,14 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 		defer done()
 	}
 
+	c.execCtx.callStackDepth, c.execCtx.callStackDepthCeiling = 0, math.MaxUint64
+	if ceiling := m.Source.CallStackDepthCeiling(ctx); ceiling > 0 {
+		c.execCtx.callStackDepthCeiling = uint64(ceiling)
+	}
+	c.execCtx.epochDeadline = math.MaxUint64
+	if ticks, ok := m.Source.CallEpochDeadline(ctx); ok {
+		c.execCtx.epochDeadline = m.EpochAfter(ticks)
+	}
 	entrypoint(c.preambleExecutable, c.executable, c.execCtxPtr, c.parent.opaquePtr, paramResultPtr, c.stackTop)
 	for {
 		switch ec := c.execCtx.exitCode; ec & wazevoapi.ExitCodeMask {
@@ -255,7 +290,7 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			} else {
 				*argRes = uint64(res)
 				calleeOpaque := opaqueViewFromPtr(uintptr(unsafe.Pointer(c.execCtx.callerModuleContextPtr)))
-				if mod.Source.MemorySection != nil { // Local memory.
+				if len(mod.Source.MemorySection) > 0 { // Local memory.
 					putLocalMemory(calleeOpaque, 8 /* local memory begins at 8 */, mem)
 				} else {
 					// Imported memory's owner at offset 16 of the callerModuleContextPtr.
@@ -274,6 +309,23 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			s[0] = uint64(uint32(int32(table.Grow(num, ref))))
 			c.execCtx.exitCode = wazevoapi.ExitCodeOK
 			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr, uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)))
+		case wazevoapi.ExitCodeAtomic:
+			mod := c.callerModuleInstance()
+			mem := mod.MemoryInstance
+			s := goCallStackView(c.execCtx.stackPointerBeforeGoCall)
+			desc, addr, v1, v2 := s[0], uint32(s[1]), s[2], s[3]
+			s[0] = executeAtomic(mem, wasm.OpcodeAtomic(desc), uint32(desc>>32), addr, v1, v2)
+			if mem != nil && mem.Shared {
+				// Shared memory might have been grown by another agent, so refresh the cached buffer.
+				calleeOpaque := opaqueViewFromPtr(uintptr(unsafe.Pointer(c.execCtx.callerModuleContextPtr)))
+				if len(mod.Source.MemorySection) > 0 { // Local memory.
+					mem.Mux.Lock()
+					putLocalMemory(calleeOpaque, 8 /* local memory begins at 8 */, mem)
+					mem.Mux.Unlock()
+				}
+			}
+			c.execCtx.exitCode = wazevoapi.ExitCodeOK
+			afterGoFunctionCallEntrypoint(c.execCtx.goCallReturnAddress, c.execCtxPtr, uintptr(unsafe.Pointer(c.execCtx.stackPointerBeforeGoCall)))
 		case wazevoapi.ExitCodeCallGoFunction:
 			index := wazevoapi.GoFunctionIndexFromExitCode(ec)
 			f := hostModuleGoFuncFromOpaque[api.GoFunction](index, c.execCtx.goFunctionCallCalleeModuleContextOpaque)
@@ -376,6 +428,12 @@ func (c *callEngine) callWithStack(ctx context.Context, paramResultStack []uint6
 			panic(wasmruntime.ErrRuntimeIntegerDivideByZero)
 		case wazevoapi.ExitCodeInvalidConversionToInteger:
 			panic(wasmruntime.ErrRuntimeInvalidConversionToInteger)
+		case wazevoapi.ExitCodeFuelExhausted:
+			panic(sys.NewExitError(sys.ExitCodeFuelExhausted))
+		case wazevoapi.ExitCodeEpochDeadlineExceeded:
+			panic(sys.NewExitError(sys.ExitCodeEpochDeadlineExceeded))
+		case wazevoapi.ExitCodeCallStackOverflow:
+			panic(wasmruntime.ErrRuntimeStackOverflow)
 		default:
 			panic("BUG")
 		}
@@ -15,10 +15,10 @@ import (
 	"github.com/AR1011/wazero/experimental/opt"
 	"github.com/AR1011/wazero/internal/engine/wazevo/testcases"
 	"github.com/AR1011/wazero/internal/leb128"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/dwarftestdata"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 const (
@@ -525,7 +525,7 @@ func TestE2E_stores(t *testing.T) {
 func TestE2E_reexported_memory(t *testing.T) {
 	m1 := &wasm.Module{
 		ExportSection: []wasm.Export{{Name: "mem", Type: wasm.ExternTypeMemory, Index: 0}},
-		MemorySection: &wasm.Memory{Min: 1},
+		MemorySection: []wasm.Memory{{Min: 1}},
 		NameSection:   &wasm.NameSection{ModuleName: "m1"},
 	}
 	m2 := &wasm.Module{
@@ -56,7 +56,9 @@ type (
 		// tableGrowExecutable is a compiled trampoline executable for table.grow builtin function.
 		tableGrowExecutable []byte
 		// refFuncExecutable is a compiled trampoline executable for ref.func builtin function.
-		refFuncExecutable         []byte
+		refFuncExecutable []byte
+		// atomicExecutable is a compiled trampoline executable for the atomic instructions of the threads proposal.
+		atomicExecutable          []byte
 		listenerBeforeTrampolines map[*wasm.FunctionType][]byte
 		listenerAfterTrampolines  map[*wasm.FunctionType][]byte
 	}
@@ -96,7 +98,10 @@ type sourceMap struct {
 	wasmBinaryOffsets []uint64
 }
 
-var _ wasm.Engine = (*engine)(nil)
+var (
+	_ wasm.Engine           = (*engine)(nil)
+	_ wasm.EngineSerializer = (*engine)(nil)
+)
 
 // NewEngine returns the implementation of wasm.Engine.
 func NewEngine(ctx context.Context, _ api.CoreFeatures, fc filecache.Cache) wasm.Engine {
@@ -191,6 +196,23 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 		return e.compileHostModule(ctx, module, listeners)
 	}
 
+	if memoryCount := int(module.ImportMemoryCount) + len(module.MemorySection); memoryCount > 1 {
+		return nil, fmt.Errorf("multiple memories are not supported yet: module has %d memories", memoryCount)
+	}
+	for i := range module.MemorySection {
+		if module.MemorySection[i].IsMemory64 {
+			return nil, errors.New("64-bit memories are not supported yet")
+		}
+	}
+	for i := range module.ImportSection {
+		if imp := &module.ImportSection[i]; imp.Type == wasm.ExternTypeMemory && imp.DescMem.IsMemory64 {
+			return nil, errors.New("64-bit memories are not supported yet")
+		}
+	}
+	if module.ImportTagCount > 0 || len(module.TagSection) > 0 {
+		return nil, errors.New("exception handling is not supported yet")
+	}
+
 	importedFns, localFns := int(module.ImportFunctionCount), len(module.FunctionSection)
 	if localFns == 0 {
 		return cm, nil
@@ -201,7 +223,7 @@ func (e *engine) compileModule(ctx context.Context, module *wasm.Module, listene
 		wazevoapi.DeterministicCompilationVerifierRandomizeIndexes(ctx)
 	}
 
-	needSourceInfo := module.DWARFLines != nil
+	needSourceInfo := module.DWARFLines != nil || module.CoreDump
 
 	// Creates new compiler instances which are reused for each function.
 	ssaBuilder := ssa.NewBuilder()
@@ -372,7 +394,15 @@ func (e *engine) compileHostModule(ctx context.Context, module *wasm.Module, lis
 	be := backend.NewCompiler(ctx, machine, ssa.NewBuilder())
 
 	num := len(module.CodeSection)
-	cm := &compiledModule{module: module, listeners: listeners, executables: &executables{}}
+	cm := &compiledModule{
+		module: module, listeners: listeners, executables: &executables{},
+		// Used to structure moduleEngine.hostData, if the host module defines a memory or globals.
+		offsets: wazevoapi.NewModuleContextOffsetData(module, false),
+	}
+	if num == 0 {
+		// e.g. a host module which only exports tables.
+		return cm, nil
+	}
 	cm.functionOffsets = make([]int, num)
 	totalSize := 0 // Total binary size of the executable.
 	bodies := make([][]byte, num)
@@ -559,6 +589,9 @@ func (e *engine) NewModuleEngine(m *wasm.Module, mi *wasm.ModuleInstance) (wasm.
 	if m.IsHostModule {
 		me.opaque = buildHostModuleOpaque(m, compiled.listeners)
 		me.opaquePtr = &me.opaque[0]
+		if len(m.MemorySection)+len(m.GlobalSection) > 0 {
+			me.hostData = make(moduleContextOpaque, compiled.offsets.TotalSize)
+		}
 	} else {
 		if size := compiled.offsets.TotalSize; size != 0 {
 			opaque := make([]byte, size)
@@ -627,6 +660,22 @@ func (e *engine) compileSharedFunctions() {
 		}
 	}
 
+	e.be.Init()
+	{
+		src := e.machine.CompileGoFunctionTrampoline(wazevoapi.ExitCodeAtomic, &ssa.Signature{
+			Params: []ssa.Type{
+				ssa.TypeI64 /* exec context */, ssa.TypeI64, /* descriptor */
+				ssa.TypeI32 /* address */, ssa.TypeI64 /* operand 1 */, ssa.TypeI64, /* operand 2 */
+			},
+			Results: []ssa.Type{ssa.TypeI64},
+		}, false)
+		e.sharedFunctions.atomicExecutable = mmapExecutable(src)
+		if wazevoapi.PerfMapEnabled {
+			exe := e.sharedFunctions.atomicExecutable
+			wazevoapi.PerfMap.AddEntry(uintptr(unsafe.Pointer(&exe[0])), uint64(len(exe)), "atomic_trampoline")
+		}
+	}
+
 	e.be.Init()
 	{
 		src := e.machine.CompileStackGrowCallSequence()
@@ -656,6 +705,9 @@ func sharedFunctionsFinalizer(sf *sharedFunctions) {
 	if err := platform.MunmapCodeSegment(sf.refFuncExecutable); err != nil {
 		panic(err)
 	}
+	if err := platform.MunmapCodeSegment(sf.atomicExecutable); err != nil {
+		panic(err)
+	}
 	for _, f := range sf.listenerBeforeTrampolines {
 		if err := platform.MunmapCodeSegment(f); err != nil {
 			panic(err)
@@ -672,6 +724,7 @@ func sharedFunctionsFinalizer(sf *sharedFunctions) {
 	sf.stackGrowExecutable = nil
 	sf.tableGrowExecutable = nil
 	sf.refFuncExecutable = nil
+	sf.atomicExecutable = nil
 	sf.listenerBeforeTrampolines = nil
 	sf.listenerAfterTrampolines = nil
 }
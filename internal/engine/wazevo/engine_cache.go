@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"runtime"
@@ -47,34 +48,73 @@ func (e *engine) getCompiledModule(module *wasm.Module, listeners []experimental
 	}
 	cm, ok, err = e.getCompiledModuleFromCache(module)
 	if ok {
-		cm.parent = e
-		cm.module = module
-		cm.sharedFunctions = e.sharedFunctions
-		cm.ensureTermination = ensureTermination
-		cm.offsets = wazevoapi.NewModuleContextOffsetData(module, len(listeners) > 0)
-		if len(listeners) > 0 {
-			cm.listeners = listeners
-			cm.listenerBeforeTrampolines = make([]*byte, len(module.TypeSection))
-			cm.listenerAfterTrampolines = make([]*byte, len(module.TypeSection))
-			for i := range module.TypeSection {
-				typ := &module.TypeSection[i]
-				before, after := e.getListenerTrampolineForType(typ)
-				cm.listenerBeforeTrampolines[i] = before
-				cm.listenerAfterTrampolines[i] = after
-			}
-		}
-		e.addCompiledModuleToMemory(module, cm)
-		ssaBuilder := ssa.NewBuilder()
-		machine := newMachine()
-		be := backend.NewCompiler(context.Background(), machine, ssaBuilder)
-		cm.executables.compileEntryPreambles(module, machine, be)
-
-		// Set the finalizer.
-		e.setFinalizer(cm.executables, executablesFinalizer)
+		e.addDeserializedCompiledModule(module, cm, listeners, ensureTermination)
 	}
 	return
 }
 
+// addDeserializedCompiledModule makes the compiledModule read from outside the process ready to use.
+func (e *engine) addDeserializedCompiledModule(module *wasm.Module, cm *compiledModule, listeners []experimental.FunctionListener, ensureTermination bool) {
+	cm.parent = e
+	cm.module = module
+	cm.sharedFunctions = e.sharedFunctions
+	cm.ensureTermination = ensureTermination
+	cm.offsets = wazevoapi.NewModuleContextOffsetData(module, len(listeners) > 0)
+	if len(listeners) > 0 {
+		cm.listeners = listeners
+		cm.listenerBeforeTrampolines = make([]*byte, len(module.TypeSection))
+		cm.listenerAfterTrampolines = make([]*byte, len(module.TypeSection))
+		for i := range module.TypeSection {
+			typ := &module.TypeSection[i]
+			before, after := e.getListenerTrampolineForType(typ)
+			cm.listenerBeforeTrampolines[i] = before
+			cm.listenerAfterTrampolines[i] = after
+		}
+	}
+	e.addCompiledModuleToMemory(module, cm)
+	ssaBuilder := ssa.NewBuilder()
+	machine := newMachine()
+	be := backend.NewCompiler(context.Background(), machine, ssaBuilder)
+	cm.executables.compileEntryPreambles(module, machine, be)
+
+	// Set the finalizer.
+	e.setFinalizer(cm.executables, executablesFinalizer)
+}
+
+// SerializeCompiledModule implements wasm.EngineSerializer.
+func (e *engine) SerializeCompiledModule(module *wasm.Module, w io.Writer) error {
+	if module.IsHostModule {
+		return errors.New("host modules cannot be serialized")
+	}
+	cm, ok := e.getCompiledModuleFromMemory(module)
+	if !ok {
+		return errors.New("source module must be compiled before serialization")
+	}
+	_, err := io.Copy(w, serializeCompiledModule(e.wazeroVersion, cm))
+	return err
+}
+
+// DeserializeCompiledModule implements wasm.EngineSerializer.
+func (e *engine) DeserializeCompiledModule(module *wasm.Module, listeners []experimental.FunctionListener, ensureTermination bool, r io.Reader) error {
+	if wazevoapi.PerfMapEnabled {
+		wazevoapi.PerfMap.Lock()
+		defer wazevoapi.PerfMap.Unlock()
+	}
+
+	if _, ok := e.getCompiledModuleFromMemory(module); ok {
+		return nil // Already compiled or deserialized in this engine.
+	}
+
+	cm, staleCache, err := deserializeCompiledModule(e.wazeroVersion, io.NopCloser(r))
+	if err != nil {
+		return err
+	} else if staleCache {
+		return errors.New("compiled module was serialized by a different version of wazero")
+	}
+	e.addDeserializedCompiledModule(module, cm, listeners, ensureTermination)
+	return nil
+}
+
 func (e *engine) addCompiledModuleToMemory(m *wasm.Module, cm *compiledModule) {
 	e.mux.Lock()
 	defer e.mux.Unlock()
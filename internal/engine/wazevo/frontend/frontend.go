@@ -25,6 +25,7 @@ type Compiler struct {
 	tableGrowSig           ssa.Signature
 	refFuncSig             ssa.Signature
 	memmoveSig             ssa.Signature
+	atomicSig              ssa.Signature
 	checkModuleExitCodeArg [1]ssa.Value
 	ensureTermination      bool
 
@@ -141,6 +142,15 @@ func (c *Compiler) declareSignatures(listenerOn bool) {
 		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32},
 	}
 	c.ssaBuilder.DeclareSignature(&c.memmoveSig)
+
+	c.atomicSig = ssa.Signature{
+		ID: c.memmoveSig.ID + 1,
+		// Takes execution context, the descriptor of the instruction, the address and two operands.
+		Params: []ssa.Type{ssa.TypeI64, ssa.TypeI64, ssa.TypeI32, ssa.TypeI64, ssa.TypeI64},
+		// Returns the result of the instruction if any.
+		Results: []ssa.Type{ssa.TypeI64},
+	}
+	c.ssaBuilder.DeclareSignature(&c.atomicSig)
 }
 
 // SignatureForWasmFunctionType returns the ssa.Signature for the given wasm.FunctionType.
@@ -261,7 +271,7 @@ func (c *Compiler) declareWasmLocals(entry ssa.BasicBlock) {
 }
 
 func (c *Compiler) declareNecessaryVariables() {
-	c.needMemory = c.m.ImportMemoryCount > 0 || c.m.MemorySection != nil
+	c.needMemory = c.m.ImportMemoryCount > 0 || len(c.m.MemorySection) > 0
 	if c.needMemory {
 		c.memoryBaseVariable = c.ssaBuilder.DeclareVariable(ssa.TypeI64)
 		c.memoryLenVariable = c.ssaBuilder.DeclareVariable(ssa.TypeI64)
@@ -154,10 +154,20 @@ func (l *loweringState) ctrlPeekAt(n int) (ret *controlFrame) {
 func (c *Compiler) lowerBody(entryBlk ssa.BasicBlock) {
 	c.ssaBuilder.Seal(entryBlk)
 
+	if c.m.MaxCallStackDepth != 0 {
+		c.incrementCallStackDepth()
+	}
 	if c.needListener {
 		c.callListenerBefore()
 	}
 
+	if c.m.FuelMetering {
+		c.consumeFuel()
+	}
+	if c.m.EpochInterruption {
+		c.checkEpochDeadline()
+	}
+
 	// Pushes the empty control frame which corresponds to the function return.
 	c.loweringState.ctrlPush(controlFrame{
 		kind:           controlFrameKindFunction,
@@ -1368,6 +1378,13 @@ func (c *Compiler) lowerCurrentOpcode() {
 				AsCallIndirect(checkModuleExitCodePtr, &c.checkModuleExitCodeSig, c.checkModuleExitCodeArg[:]).
 				Insert(builder)
 		}
+
+		if c.m.FuelMetering {
+			c.consumeFuel()
+		}
+		if c.m.EpochInterruption {
+			c.checkEpochDeadline()
+		}
 	case wasm.OpcodeIf:
 		bt := c.readBlockType()
 
@@ -1508,10 +1525,24 @@ func (c *Compiler) lowerCurrentOpcode() {
 		targetBlk, argNum := state.brTargetArgNumFor(labelIndex)
 		args := c.loweringState.nPeekDup(argNum)
 
+		var trampoline ssa.BasicBlock
+		if targetBlk.ReturnBlock() && c.m.MaxCallStackDepth != 0 {
+			// Jump to the return block via the trampoline block so that the call stack depth is decremented.
+			currentBlk := builder.CurrentBlock()
+			trampoline = builder.AllocateBasicBlock()
+			builder.SetCurrentBlock(trampoline)
+			c.insertJumpToBlock(args, targetBlk)
+			builder.SetCurrentBlock(currentBlk)
+			targetBlk, args = trampoline, nil
+		}
+
 		// Insert the conditional jump to the target block.
 		brnz := builder.AllocateInstruction()
 		brnz.AsBrnz(v, args, targetBlk)
 		builder.InsertInstruction(brnz)
+		if trampoline != nil {
+			builder.Seal(trampoline)
+		}
 
 		// Insert the unconditional jump to the Else block which corresponds to after br_if.
 		elseBlk := builder.AllocateBasicBlock()
@@ -1548,16 +1579,7 @@ func (c *Compiler) lowerCurrentOpcode() {
 		if state.unreachable {
 			break
 		}
-		if c.needListener {
-			c.callListenerAfter()
-		}
-
-		results := c.loweringState.nPeekDup(c.results())
-		instr := builder.AllocateInstruction()
-
-		instr.AsReturn(results)
-		builder.InsertInstruction(instr)
-		state.unreachable = true
+		c.lowerReturn()
 
 	case wasm.OpcodeUnreachable:
 		if state.unreachable {
@@ -1582,67 +1604,27 @@ func (c *Compiler) lowerCurrentOpcode() {
 			break
 		}
 
-		// Before transfer the control to the callee, we have to store the current module's moduleContextPtr
-		// into execContext.callerModuleContextPtr in case when the callee is a Go function.
-		//
-		// TODO: maybe this can be optimized out if this is in-module function calls. Investigate later.
-		c.storeCallerModuleContext()
+		c.lowerCall(fnIndex)
 
-		var typIndex wasm.Index
-		if fnIndex < c.m.ImportFunctionCount {
-			var fi int
-			for i := range c.m.ImportSection {
-				imp := &c.m.ImportSection[i]
-				if imp.Type == wasm.ExternTypeFunc {
-					if fi == int(fnIndex) {
-						typIndex = imp.DescFunc
-						break
-					}
-					fi++
-				}
-			}
-		} else {
-			typIndex = c.m.FunctionSection[fnIndex-c.m.ImportFunctionCount]
-		}
-		typ := &c.m.TypeSection[typIndex]
-
-		// TODO: reuse slice?
-		argN := len(typ.Params)
-		args := make([]ssa.Value, argN+2)
-		args[0] = c.execCtxPtrValue
-		state.nPopInto(argN, args[2:])
-
-		sig := c.signatures[typ]
-		call := builder.AllocateInstruction()
-		if fnIndex >= c.m.ImportFunctionCount {
-			args[1] = c.moduleCtxPtrValue // This case the callee module is itself.
-			call.AsCall(FunctionIndexToFuncRef(fnIndex), sig, args)
-			builder.InsertInstruction(call)
-		} else {
-			// This case we have to read the address of the imported function from the module context.
-			moduleCtx := c.moduleCtxPtrValue
-			loadFuncPtr, loadModuleCtxPtr := builder.AllocateInstruction(), builder.AllocateInstruction()
-			funcPtrOffset, moduleCtxPtrOffset, _ := c.offset.ImportedFunctionOffset(fnIndex)
-			loadFuncPtr.AsLoad(moduleCtx, funcPtrOffset.U32(), ssa.TypeI64)
-			loadModuleCtxPtr.AsLoad(moduleCtx, moduleCtxPtrOffset.U32(), ssa.TypeI64)
-			builder.InsertInstruction(loadFuncPtr)
-			builder.InsertInstruction(loadModuleCtxPtr)
-
-			args[1] = loadModuleCtxPtr.Return() // This case the callee module is itself.
-
-			call.AsCallIndirect(loadFuncPtr.Return(), sig, args)
-			builder.InsertInstruction(call)
+	case wasm.OpcodeReturnCall:
+		fnIndex := c.readI32u()
+		if state.unreachable {
+			break
 		}
+		// The tail call is lowered as a call followed by return, which doesn't reuse the current frame, so the stack
+		// grows with the depth of the tail recursion. See api.CoreFeatureTailCall.
+		c.lowerCall(fnIndex)
+		c.lowerReturn()
 
-		first, rest := call.Returns()
-		if first.Valid() {
-			state.push(first)
-		}
-		for _, v := range rest {
-			state.push(v)
+	case wasm.OpcodeReturnCallIndirect:
+		typeIndex := c.readI32u()
+		tableIndex := c.readI32u()
+		if state.unreachable {
+			break
 		}
-
-		c.reloadAfterCall()
+		// Lowered as a call followed by return. See OpcodeReturnCall.
+		c.lowerCallIndirect(typeIndex, tableIndex)
+		c.lowerReturn()
 
 	case wasm.OpcodeDrop:
 		if state.unreachable {
@@ -3213,6 +3195,19 @@ func (c *Compiler) lowerCurrentOpcode() {
 		elementAddr := c.lowerAccessTableWithBoundsCheck(tableIndex, targetOffsetInTable)
 		loaded := builder.AllocateInstruction().AsLoad(elementAddr, 0, ssa.TypeI64).Insert(builder).Return()
 		state.push(loaded)
+	case wasm.OpcodeAtomicPrefix:
+		state.pc++
+		atomicOp := c.wasmFunctionBody[state.pc]
+		var offset uint32
+		if atomicOp == wasm.OpcodeAtomicFence {
+			state.pc++ // skips the reserved zero byte.
+		} else {
+			_, offset = c.readMemArg()
+		}
+		if state.unreachable {
+			break
+		}
+		c.lowerAtomic(atomicOp, offset)
 	default:
 		panic("TODO: unsupported in wazevo yet: " + wasm.InstructionName(op))
 	}
@@ -3286,6 +3281,92 @@ func (c *Compiler) lowerAccessTableWithBoundsCheck(tableIndex uint32, elementOff
 	return calcElementAddressInTable.Return()
 }
 
+// lowerCall lowers the call instruction to the function of fnIndex.
+func (c *Compiler) lowerCall(fnIndex uint32) {
+	builder := c.ssaBuilder
+	state := c.state()
+
+	// Before transfer the control to the callee, we have to store the current module's moduleContextPtr
+	// into execContext.callerModuleContextPtr in case when the callee is a Go function.
+	//
+	// TODO: maybe this can be optimized out if this is in-module function calls. Investigate later.
+	c.storeCallerModuleContext()
+
+	var typIndex wasm.Index
+	if fnIndex < c.m.ImportFunctionCount {
+		var fi int
+		for i := range c.m.ImportSection {
+			imp := &c.m.ImportSection[i]
+			if imp.Type == wasm.ExternTypeFunc {
+				if fi == int(fnIndex) {
+					typIndex = imp.DescFunc
+					break
+				}
+				fi++
+			}
+		}
+	} else {
+		typIndex = c.m.FunctionSection[fnIndex-c.m.ImportFunctionCount]
+	}
+	typ := &c.m.TypeSection[typIndex]
+
+	// TODO: reuse slice?
+	argN := len(typ.Params)
+	args := make([]ssa.Value, argN+2)
+	args[0] = c.execCtxPtrValue
+	state.nPopInto(argN, args[2:])
+
+	sig := c.signatures[typ]
+	call := builder.AllocateInstruction()
+	if fnIndex >= c.m.ImportFunctionCount {
+		args[1] = c.moduleCtxPtrValue // This case the callee module is itself.
+		call.AsCall(FunctionIndexToFuncRef(fnIndex), sig, args)
+		builder.InsertInstruction(call)
+	} else {
+		// This case we have to read the address of the imported function from the module context.
+		moduleCtx := c.moduleCtxPtrValue
+		loadFuncPtr, loadModuleCtxPtr := builder.AllocateInstruction(), builder.AllocateInstruction()
+		funcPtrOffset, moduleCtxPtrOffset, _ := c.offset.ImportedFunctionOffset(fnIndex)
+		loadFuncPtr.AsLoad(moduleCtx, funcPtrOffset.U32(), ssa.TypeI64)
+		loadModuleCtxPtr.AsLoad(moduleCtx, moduleCtxPtrOffset.U32(), ssa.TypeI64)
+		builder.InsertInstruction(loadFuncPtr)
+		builder.InsertInstruction(loadModuleCtxPtr)
+
+		args[1] = loadModuleCtxPtr.Return() // This case the callee module is itself.
+
+		call.AsCallIndirect(loadFuncPtr.Return(), sig, args)
+		builder.InsertInstruction(call)
+	}
+
+	first, rest := call.Returns()
+	if first.Valid() {
+		state.push(first)
+	}
+	for _, v := range rest {
+		state.push(v)
+	}
+
+	c.reloadAfterCall()
+}
+
+// lowerReturn lowers the return instruction which returns the values on top of the stack.
+func (c *Compiler) lowerReturn() {
+	builder := c.ssaBuilder
+	if c.needListener {
+		c.callListenerAfter()
+	}
+	if c.m.MaxCallStackDepth != 0 {
+		c.decrementCallStackDepth()
+	}
+
+	results := c.loweringState.nPeekDup(c.results())
+	instr := builder.AllocateInstruction()
+
+	instr.AsReturn(results)
+	builder.InsertInstruction(instr)
+	c.state().unreachable = true
+}
+
 func (c *Compiler) lowerCallIndirect(typeIndex, tableIndex uint32) {
 	builder := c.ssaBuilder
 	state := c.state()
@@ -3672,6 +3753,15 @@ func (c *Compiler) readMemArg() (align, offset uint32) {
 	}
 
 	state.pc += int(num)
+	if align&wasm.MemArgMemoryIndexFlag != 0 {
+		// Skips the memory index which is fixed to zero as multiple memories are rejected at compilation.
+		align ^= wasm.MemArgMemoryIndexFlag
+		_, num, err = leb128.LoadUint32(c.wasmFunctionBody[state.pc+1:])
+		if err != nil {
+			panic(fmt.Errorf("read memory index: %v", err))
+		}
+		state.pc += int(num)
+	}
 	offset, num, err = leb128.LoadUint32(c.wasmFunctionBody[state.pc+1:])
 	if err != nil {
 		panic(fmt.Errorf("read memory offset: %v", err))
@@ -3687,6 +3777,9 @@ func (c *Compiler) insertJumpToBlock(args []ssa.Value, targetBlk ssa.BasicBlock)
 		if c.needListener {
 			c.callListenerAfter()
 		}
+		if c.m.MaxCallStackDepth != 0 {
+			c.decrementCallStackDepth()
+		}
 	}
 
 	builder := c.ssaBuilder
@@ -3792,6 +3885,103 @@ func (l *loweringState) brTargetArgNumFor(labelIndex uint32) (targetBlk ssa.Basi
 	return
 }
 
+// incrementCallStackDepth inserts the instructions to increment the call stack depth in the execution context,
+// and to exit with wazevoapi.ExitCodeCallStackOverflow if it exceeds the ceiling.
+func (c *Compiler) incrementCallStackDepth() {
+	builder := c.ssaBuilder
+	depth := builder.AllocateInstruction().
+		AsLoad(c.execCtxPtrValue, wazevoapi.ExecutionContextOffsetCallStackDepth.U32(), ssa.TypeI64).
+		Insert(builder).Return()
+	one := builder.AllocateInstruction().AsIconst64(1).Insert(builder).Return()
+	depth = builder.AllocateInstruction().AsIadd(depth, one).Insert(builder).Return()
+	builder.AllocateInstruction().
+		AsStore(ssa.OpcodeStore, depth, c.execCtxPtrValue, wazevoapi.ExecutionContextOffsetCallStackDepth.U32()).
+		Insert(builder)
+
+	ceiling := builder.AllocateInstruction().
+		AsLoad(c.execCtxPtrValue, wazevoapi.ExecutionContextOffsetCallStackDepthCeiling.U32(), ssa.TypeI64).
+		Insert(builder).Return()
+	exceeded := builder.AllocateInstruction().
+		AsIcmp(depth, ceiling, ssa.IntegerCmpCondUnsignedGreaterThan).
+		Insert(builder).Return()
+	builder.AllocateInstruction().
+		AsExitIfTrueWithCode(c.execCtxPtrValue, exceeded, wazevoapi.ExitCodeCallStackOverflow).
+		Insert(builder)
+}
+
+// decrementCallStackDepth inserts the instructions to decrement the call stack depth in the execution context.
+func (c *Compiler) decrementCallStackDepth() {
+	builder := c.ssaBuilder
+	depth := builder.AllocateInstruction().
+		AsLoad(c.execCtxPtrValue, wazevoapi.ExecutionContextOffsetCallStackDepth.U32(), ssa.TypeI64).
+		Insert(builder).Return()
+	one := builder.AllocateInstruction().AsIconst64(1).Insert(builder).Return()
+	depth = builder.AllocateInstruction().AsIsub(depth, one).Insert(builder).Return()
+	builder.AllocateInstruction().
+		AsStore(ssa.OpcodeStore, depth, c.execCtxPtrValue, wazevoapi.ExecutionContextOffsetCallStackDepth.U32()).
+		Insert(builder)
+}
+
+// consumeFuel inserts the instructions to consume one unit of wasm.ModuleInstance Fuel,
+// and to exit with wazevoapi.ExitCodeFuelExhausted if it goes negative.
+func (c *Compiler) consumeFuel() {
+	builder := c.ssaBuilder
+	moduleInstancePtr := builder.AllocateInstruction().
+		AsLoad(c.moduleCtxPtrValue, c.offset.ModuleInstanceOffset.U32(), ssa.TypeI64).
+		Insert(builder).Return()
+	fuel := builder.AllocateInstruction().
+		AsLoad(moduleInstancePtr, wazevoapi.ModuleInstanceFuelOffset, ssa.TypeI64).
+		Insert(builder).Return()
+	one := builder.AllocateInstruction().AsIconst64(1).Insert(builder).Return()
+	fuel = builder.AllocateInstruction().AsIsub(fuel, one).Insert(builder).Return()
+	builder.AllocateInstruction().
+		AsStore(ssa.OpcodeStore, fuel, moduleInstancePtr, wazevoapi.ModuleInstanceFuelOffset).
+		Insert(builder)
+
+	zero := builder.AllocateInstruction().AsIconst64(0).Insert(builder).Return()
+	exhausted := builder.AllocateInstruction().
+		AsIcmp(fuel, zero, ssa.IntegerCmpCondSignedLessThan).
+		Insert(builder).Return()
+	builder.AllocateInstruction().
+		AsExitIfTrueWithCode(c.execCtxPtrValue, exhausted, wazevoapi.ExitCodeFuelExhausted).
+		Insert(builder)
+}
+
+// checkEpochDeadline inserts the instructions to exit with wazevoapi.ExitCodeEpochDeadlineExceeded
+// if the epoch of the store reached wasm.ModuleInstance EpochDeadline or the deadline of the current call.
+func (c *Compiler) checkEpochDeadline() {
+	builder := c.ssaBuilder
+	moduleInstancePtr := builder.AllocateInstruction().
+		AsLoad(c.moduleCtxPtrValue, c.offset.ModuleInstanceOffset.U32(), ssa.TypeI64).
+		Insert(builder).Return()
+	epochPtr := builder.AllocateInstruction().
+		AsLoad(moduleInstancePtr, wazevoapi.ModuleInstanceEpochOffset, ssa.TypeI64).
+		Insert(builder).Return()
+	epoch := builder.AllocateInstruction().
+		AsLoad(epochPtr, 0, ssa.TypeI64).
+		Insert(builder).Return()
+	deadline := builder.AllocateInstruction().
+		AsLoad(moduleInstancePtr, wazevoapi.ModuleInstanceEpochDeadlineOffset, ssa.TypeI64).
+		Insert(builder).Return()
+
+	exceeded := builder.AllocateInstruction().
+		AsIcmp(epoch, deadline, ssa.IntegerCmpCondUnsignedGreaterThanOrEqual).
+		Insert(builder).Return()
+	builder.AllocateInstruction().
+		AsExitIfTrueWithCode(c.execCtxPtrValue, exceeded, wazevoapi.ExitCodeEpochDeadlineExceeded).
+		Insert(builder)
+
+	callDeadline := builder.AllocateInstruction().
+		AsLoad(c.execCtxPtrValue, wazevoapi.ExecutionContextOffsetEpochDeadline.U32(), ssa.TypeI64).
+		Insert(builder).Return()
+	callExceeded := builder.AllocateInstruction().
+		AsIcmp(epoch, callDeadline, ssa.IntegerCmpCondUnsignedGreaterThanOrEqual).
+		Insert(builder).Return()
+	builder.AllocateInstruction().
+		AsExitIfTrueWithCode(c.execCtxPtrValue, callExceeded, wazevoapi.ExitCodeEpochDeadlineExceeded).
+		Insert(builder)
+}
+
 func (c *Compiler) callListenerBefore() {
 	c.storeCallerModuleContext()
 
@@ -3945,3 +4135,86 @@ func (c *Compiler) boundsCheckInMemory(memLen, offset, size ssa.Value) {
 		AsExitIfTrueWithCode(c.execCtxPtrValue, cmp, wazevoapi.ExitCodeMemoryOutOfBounds).
 		Insert(builder)
 }
+
+// lowerAtomic lowers the atomic instruction of the threads proposal into the call to the atomic trampoline which
+// executes the instruction in Go. Operands are passed as i64 regardless of their type, and absent operands are zero.
+func (c *Compiler) lowerAtomic(atomicOp wasm.OpcodeAtomic, offset uint32) {
+	state := c.state()
+	builder := c.ssaBuilder
+
+	operandNum, resultType, hasResult := atomicOperandsAndResult(atomicOp)
+	var operands [3]ssa.Value
+	for i := operandNum - 1; i >= 0; i-- {
+		v := state.pop()
+		if i > 0 && v.Type() == ssa.TypeI32 {
+			v = builder.AllocateInstruction().AsUExtend(v, 32, 64).Insert(builder).Return()
+		}
+		operands[i] = v
+	}
+	for i := operandNum; i < 3; i++ {
+		if i == 0 {
+			operands[i] = builder.AllocateInstruction().AsIconst32(0).Insert(builder).Return()
+		} else {
+			operands[i] = builder.AllocateInstruction().AsIconst64(0).Insert(builder).Return()
+		}
+	}
+
+	c.storeCallerModuleContext()
+
+	desc := builder.AllocateInstruction().AsIconst64(uint64(atomicOp) | uint64(offset)<<32).Insert(builder).Return()
+	atomicPtr := builder.AllocateInstruction().
+		AsLoad(c.execCtxPtrValue,
+			wazevoapi.ExecutionContextOffsetAtomicTrampolineAddress.U32(),
+			ssa.TypeI64,
+		).Insert(builder).Return()
+
+	// TODO: reuse the slice.
+	args := []ssa.Value{c.execCtxPtrValue, desc, operands[0], operands[1], operands[2]}
+	ret := builder.
+		AllocateInstruction().
+		AsCallIndirect(atomicPtr, &c.atomicSig, args).
+		Insert(builder).Return()
+
+	if hasResult {
+		if resultType == ssa.TypeI32 {
+			ret = builder.AllocateInstruction().AsIreduce(ret, ssa.TypeI32).Insert(builder).Return()
+		}
+		state.push(ret)
+	}
+
+	// Shared memory might have been grown by another agent, so reload the cached memory base and len.
+	if c.needMemory {
+		c.reloadMemoryBaseLen()
+	}
+}
+
+// atomicOperandsAndResult returns the number of operands including the address, and the result type of the
+// atomic instruction if hasResult is true.
+func atomicOperandsAndResult(atomicOp wasm.OpcodeAtomic) (operandNum int, resultType ssa.Type, hasResult bool) {
+	switch atomicOp {
+	case wasm.OpcodeAtomicFence:
+		return 0, 0, false
+	case wasm.OpcodeAtomicMemoryNotify:
+		return 2, ssa.TypeI32, true
+	case wasm.OpcodeAtomicMemoryWait32, wasm.OpcodeAtomicMemoryWait64:
+		return 3, ssa.TypeI32, true
+	case wasm.OpcodeAtomicI32Load, wasm.OpcodeAtomicI32Load8U, wasm.OpcodeAtomicI32Load16U:
+		return 1, ssa.TypeI32, true
+	case wasm.OpcodeAtomicI64Load, wasm.OpcodeAtomicI64Load8U, wasm.OpcodeAtomicI64Load16U, wasm.OpcodeAtomicI64Load32U:
+		return 1, ssa.TypeI64, true
+	case wasm.OpcodeAtomicI32Store, wasm.OpcodeAtomicI32Store8, wasm.OpcodeAtomicI32Store16,
+		wasm.OpcodeAtomicI64Store, wasm.OpcodeAtomicI64Store8, wasm.OpcodeAtomicI64Store16, wasm.OpcodeAtomicI64Store32:
+		return 2, 0, false
+	case wasm.OpcodeAtomicI32RmwCmpxchg, wasm.OpcodeAtomicI32Rmw8CmpxchgU, wasm.OpcodeAtomicI32Rmw16CmpxchgU:
+		return 3, ssa.TypeI32, true
+	case wasm.OpcodeAtomicI64RmwCmpxchg, wasm.OpcodeAtomicI64Rmw8CmpxchgU, wasm.OpcodeAtomicI64Rmw16CmpxchgU,
+		wasm.OpcodeAtomicI64Rmw32CmpxchgU:
+		return 3, ssa.TypeI64, true
+	}
+	// The rest are read-modify-write instructions, where i32 ones are laid out first in each group of seven.
+	if (atomicOp-wasm.OpcodeAtomicI32RmwAdd)%7 == 0 ||
+		(atomicOp-wasm.OpcodeAtomicI32RmwAdd)%7 == 2 || (atomicOp-wasm.OpcodeAtomicI32RmwAdd)%7 == 3 {
+		return 2, ssa.TypeI32, true
+	}
+	return 2, ssa.TypeI64, true
+}
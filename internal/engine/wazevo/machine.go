@@ -4,6 +4,7 @@ import (
 	"runtime"
 
 	"github.com/AR1011/wazero/internal/engine/wazevo/backend"
+	"github.com/AR1011/wazero/internal/engine/wazevo/backend/isa/amd64"
 	"github.com/AR1011/wazero/internal/engine/wazevo/backend/isa/arm64"
 )
 
@@ -11,6 +12,8 @@ func newMachine() backend.Machine {
 	switch runtime.GOARCH {
 	case "arm64":
 		return arm64.NewBackend()
+	case "amd64":
+		return amd64.NewBackend()
 	default:
 		panic("unsupported architecture")
 	}
@@ -20,6 +23,8 @@ func unwindStack(sp, top uintptr, returnAddresses []uintptr) []uintptr {
 	switch runtime.GOARCH {
 	case "arm64":
 		return arm64.UnwindStack(sp, top, returnAddresses)
+	case "amd64":
+		return amd64.UnwindStack(sp, top, returnAddresses)
 	default:
 		panic("unsupported architecture")
 	}
@@ -29,6 +34,8 @@ func goCallStackView(stackPointerBeforeGoCall *uint64) []uint64 {
 	switch runtime.GOARCH {
 	case "arm64":
 		return arm64.GoCallStackView(stackPointerBeforeGoCall)
+	case "amd64":
+		return amd64.GoCallStackView(stackPointerBeforeGoCall)
 	default:
 		panic("unsupported architecture")
 	}
@@ -15,10 +15,13 @@ type (
 	// moduleEngine implements wasm.ModuleEngine.
 	moduleEngine struct {
 		// opaquePtr equals &opaque[0].
-		opaquePtr              *byte
-		parent                 *compiledModule
-		module                 *wasm.ModuleInstance
-		opaque                 moduleContextOpaque
+		opaquePtr *byte
+		parent    *compiledModule
+		module    *wasm.ModuleInstance
+		opaque    moduleContextOpaque
+		// hostData is laid out as moduleContextOpaque, and holds the memory and globals defined by a host module,
+		// as opaque is structured differently for host modules. nil unless the host module defines any of them.
+		hostData               moduleContextOpaque
 		localFunctionInstances []*functionInstance
 		importedFunctions      []importedFunction
 		listeners              []experimental.FunctionListener
@@ -74,6 +77,15 @@ type (
 	moduleContextOpaque []byte
 )
 
+// dataOpaque returns the moduleContextOpaque structured as documented, which is moduleEngine.hostData for host
+// modules defining a memory or globals.
+func (m *moduleEngine) dataOpaque() moduleContextOpaque {
+	if m.hostData != nil {
+		return m.hostData
+	}
+	return m.opaque
+}
+
 func putLocalMemory(opaque []byte, offset wazevoapi.Offset, mem *wasm.MemoryInstance) {
 	s := uint64(len(mem.Buffer))
 	var b uint64
@@ -87,7 +99,7 @@ func putLocalMemory(opaque []byte, offset wazevoapi.Offset, mem *wasm.MemoryInst
 func (m *moduleEngine) setupOpaque() {
 	inst := m.module
 	offsets := &m.parent.offsets
-	opaque := m.opaque
+	opaque := m.dataOpaque()
 
 	binary.LittleEndian.PutUint64(opaque[offsets.ModuleInstanceOffset:],
 		uint64(uintptr(unsafe.Pointer(m.module))),
@@ -106,7 +118,7 @@ func (m *moduleEngine) setupOpaque() {
 			if i < int(inst.Source.ImportGlobalCount) {
 				importedME := g.Me.(*moduleEngine)
 				offset := importedME.parent.offsets.GlobalInstanceOffset(g.Index)
-				importedMEOpaque := importedME.opaque
+				importedMEOpaque := importedME.dataOpaque()
 				binary.LittleEndian.PutUint64(opaque[globalOffset:],
 					uint64(uintptr(unsafe.Pointer(&importedMEOpaque[offset]))))
 			} else {
@@ -184,6 +196,7 @@ func (m *moduleEngine) NewFunction(index wasm.Index) api.Function {
 	ce.execCtx.tableGrowTrampolineAddress = &m.parent.sharedFunctions.tableGrowExecutable[0]
 	ce.execCtx.refFuncTrampolineAddress = &m.parent.sharedFunctions.refFuncExecutable[0]
 	ce.execCtx.memmoveAddress = memmovPtr
+	ce.execCtx.atomicTrampolineAddress = &m.parent.sharedFunctions.atomicExecutable[0]
 	ce.init()
 	return ce
 }
@@ -191,13 +204,31 @@ func (m *moduleEngine) NewFunction(index wasm.Index) api.Function {
 // GetGlobalValue implements the same method as documented on wasm.ModuleEngine.
 func (m *moduleEngine) GetGlobalValue(i wasm.Index) (lo, hi uint64) {
 	offset := m.parent.offsets.GlobalInstanceOffset(i)
-	buf := m.opaque[offset:]
+	buf := m.dataOpaque()[offset:]
 	if i < m.module.Source.ImportGlobalCount {
 		panic("GetGlobalValue should not be called for imported globals")
 	}
 	return binary.LittleEndian.Uint64(buf), binary.LittleEndian.Uint64(buf[8:])
 }
 
+// SetGlobalValue implements the same method as documented on wasm.ModuleEngine.
+func (m *moduleEngine) SetGlobalValue(i wasm.Index, lo, hi uint64) {
+	offset := m.parent.offsets.GlobalInstanceOffset(i)
+	buf := m.dataOpaque()[offset:]
+	if i < m.module.Source.ImportGlobalCount {
+		panic("SetGlobalValue should not be called for imported globals")
+	}
+	binary.LittleEndian.PutUint64(buf, lo)
+	binary.LittleEndian.PutUint64(buf[8:], hi)
+}
+
+// MemoryChanged implements the same method as documented on wasm.ModuleEngine.
+func (m *moduleEngine) MemoryChanged() {
+	if lm := m.parent.offsets.LocalMemoryBegin; lm >= 0 {
+		putLocalMemory(m.dataOpaque(), lm, m.module.MemoryInstance)
+	}
+}
+
 // OwnsGlobals implements the same method as documented on wasm.ModuleEngine.
 func (m *moduleEngine) OwnsGlobals() bool { return true }
 
@@ -261,7 +292,7 @@ func (m *moduleEngine) ResolveImportedMemory(importedModuleEngine wasm.ModuleEng
 		memOwnerOpaquePtr = binary.LittleEndian.Uint64(importedME.opaque[offset+8:])
 	} else {
 		memInstPtr = uint64(uintptr(unsafe.Pointer(inst.MemoryInstance)))
-		memOwnerOpaquePtr = uint64(uintptr(unsafe.Pointer(importedME.opaquePtr)))
+		memOwnerOpaquePtr = uint64(uintptr(unsafe.Pointer(&importedME.dataOpaque()[0])))
 	}
 	offset := m.parent.offsets.ImportedMemoryBegin
 	binary.LittleEndian.PutUint64(m.opaque[offset:], memInstPtr)
@@ -270,7 +301,7 @@ func (m *moduleEngine) ResolveImportedMemory(importedModuleEngine wasm.ModuleEng
 
 // DoneInstantiation implements wasm.ModuleEngine.
 func (m *moduleEngine) DoneInstantiation() {
-	if !m.module.Source.IsHostModule {
+	if !m.module.Source.IsHostModule || m.hostData != nil {
 		m.setupOpaque()
 	}
 }
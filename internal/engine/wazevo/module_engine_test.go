@@ -298,6 +298,13 @@ func TestModuleEngine_ResolveImportedMemory_reexported(t *testing.T) {
 	require.Equal(t, uint64(0xabcdef1234567890), binary.LittleEndian.Uint64(m.opaque[50+8:]))
 }
 
+func Test_moduleInstance_offsets(t *testing.T) {
+	var m wasm.ModuleInstance
+	require.Equal(t, wazevoapi.ModuleInstanceFuelOffset, int(unsafe.Offsetof(m.Fuel)))
+	require.Equal(t, wazevoapi.ModuleInstanceEpochDeadlineOffset, int(unsafe.Offsetof(m.EpochDeadline)))
+	require.Equal(t, wazevoapi.ModuleInstanceEpochOffset, int(unsafe.Offsetof(m.Epoch)))
+}
+
 func Test_functionInstance_offsets(t *testing.T) {
 	var fi functionInstance
 	require.Equal(t, wazevoapi.FunctionInstanceSize, int(unsafe.Sizeof(fi)))
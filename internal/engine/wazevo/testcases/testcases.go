@@ -986,7 +986,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeLocalGet, 0, // offset
@@ -1005,7 +1005,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i64, f32, f64}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 0, // offset
@@ -1057,7 +1057,7 @@ var (
 				Results: []wasm.ValueType{i32},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeLocalGet, 0,
@@ -1073,7 +1073,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{{Results: []wasm.ValueType{i32, i32, i32}}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1, Max: 2, IsMaxEncoded: true},
+			MemorySection:   []wasm.Memory{{Min: 1, Max: 2, IsMaxEncoded: true}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeI32Const, 1,
@@ -1091,7 +1091,7 @@ var (
 		Module: &wasm.Module{
 			TypeSection:     []wasm.FunctionType{i32_i32, {}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0, 1},
 			CodeSection: []wasm.Code{
 				{Body: []byte{
@@ -1122,7 +1122,7 @@ var (
 				{Name: "mem", Type: wasm.ExternTypeMemory, Index: 0},
 				{Name: "size", Type: wasm.ExternTypeFunc, Index: 0},
 			},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			TypeSection:     []wasm.FunctionType{v_i32},
 			FunctionSection: []wasm.Index{0},
 			CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeMemorySize, 0, wasm.OpcodeEnd}}},
@@ -1301,7 +1301,7 @@ var (
 				},
 			}},
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 1},
+			MemorySection:   []wasm.Memory{{Min: 1}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				// Basic loads (without extensions).
@@ -1491,7 +1491,7 @@ var (
 			},
 
 			ExportSection:   []wasm.Export{{Name: ExportedFunctionName, Type: wasm.ExternTypeFunc, Index: 0}},
-			MemorySection:   &wasm.Memory{Min: 4554},
+			MemorySection:   []wasm.Memory{{Min: 4554}},
 			FunctionSection: []wasm.Index{0},
 			CodeSection: []wasm.Code{{Body: []byte{
 				wasm.OpcodeBlock, 1, // Signature v_i64,
@@ -16,7 +16,7 @@ import (
 var ctx = context.Background()
 
 func TestMain(m *testing.M) {
-	if runtime.GOARCH != "arm64" {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
 		os.Exit(0)
 	}
 	os.Exit(m.Run())
@@ -68,4 +68,8 @@ func Test_ExecutionContextOffsets(t *testing.T) {
 	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.tableGrowTrampolineAddress)), wazevoapi.ExecutionContextOffsetTableGrowTrampolineAddress)
 	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.refFuncTrampolineAddress)), wazevoapi.ExecutionContextOffsetRefFuncTrampolineAddress)
 	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.memmoveAddress)), wazevoapi.ExecutionContextOffsetMemmoveAddress)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.atomicTrampolineAddress)), wazevoapi.ExecutionContextOffsetAtomicTrampolineAddress)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.callStackDepth)), wazevoapi.ExecutionContextOffsetCallStackDepth)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.callStackDepthCeiling)), wazevoapi.ExecutionContextOffsetCallStackDepthCeiling)
+	require.Equal(t, wazevoapi.Offset(unsafe.Offsetof(execCtx.epochDeadline)), wazevoapi.ExecutionContextOffsetEpochDeadline)
 }
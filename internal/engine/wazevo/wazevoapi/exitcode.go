@@ -26,6 +26,10 @@ const (
 	ExitCodeCallGoFunctionWithListener
 	ExitCodeTableGrow
 	ExitCodeRefFunc
+	ExitCodeAtomic
+	ExitCodeFuelExhausted
+	ExitCodeEpochDeadlineExceeded
+	ExitCodeCallStackOverflow
 	exitCodeMax
 )
 
@@ -74,6 +78,14 @@ func (e ExitCode) String() string {
 		return "table_grow"
 	case ExitCodeRefFunc:
 		return "ref_func"
+	case ExitCodeAtomic:
+		return "atomic"
+	case ExitCodeFuelExhausted:
+		return "fuel_exhausted"
+	case ExitCodeEpochDeadlineExceeded:
+		return "epoch_deadline_exceeded"
+	case ExitCodeCallStackOverflow:
+		return "call_stack_overflow"
 	}
 	panic("TODO")
 }
@@ -49,6 +49,23 @@ const (
 	// ExecutionContextOffsetRefFuncTrampolineAddress is an offset of `refFuncTrampolineAddress` field in wazevo.executionContext
 	ExecutionContextOffsetRefFuncTrampolineAddress Offset = 1136
 	ExecutionContextOffsetMemmoveAddress           Offset = 1144
+	// ExecutionContextOffsetAtomicTrampolineAddress is an offset of `atomicTrampolineAddress` field in wazevo.executionContext
+	ExecutionContextOffsetAtomicTrampolineAddress Offset = 1152
+	// ExecutionContextOffsetCallStackDepth is an offset of `callStackDepth` field in wazevo.executionContext
+	ExecutionContextOffsetCallStackDepth Offset = 1160
+	// ExecutionContextOffsetCallStackDepthCeiling is an offset of `callStackDepthCeiling` field in wazevo.executionContext
+	ExecutionContextOffsetCallStackDepthCeiling Offset = 1168
+	// ExecutionContextOffsetEpochDeadline is an offset of `epochDeadline` field in wazevo.executionContext
+	ExecutionContextOffsetEpochDeadline Offset = 1176
+)
+
+const (
+	// ModuleInstanceFuelOffset is an offset of `Fuel` field in wasm.ModuleInstance.
+	ModuleInstanceFuelOffset = 296
+	// ModuleInstanceEpochDeadlineOffset is an offset of `EpochDeadline` field in wasm.ModuleInstance.
+	ModuleInstanceEpochDeadlineOffset = 304
+	// ModuleInstanceEpochOffset is an offset of `Epoch` field in wasm.ModuleInstance.
+	ModuleInstanceEpochOffset = 312
 )
 
 // ModuleContextOffsetData allows the compilers to get the information about offsets to the fields of wazevo.moduleContextOpaque,
@@ -127,7 +144,7 @@ func NewModuleContextOffsetData(m *wasm.Module, withListener bool) ModuleContext
 	ret.ModuleInstanceOffset = 0
 	offset += 8
 
-	if m.MemorySection != nil {
+	if len(m.MemorySection) > 0 {
 		ret.LocalMemoryBegin = offset
 		// buffer base + memory size.
 		const localMemorySizeInOpaqueModuleContext = 16
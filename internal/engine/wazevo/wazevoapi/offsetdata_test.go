@@ -33,7 +33,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 		},
 		{
 			name: "local mem",
-			m:    &wasm.Module{MemorySection: &wasm.Memory{}},
+			m:    &wasm.Module{MemorySection: []wasm.Memory{{}}},
 			exp: ModuleContextOffsetData{
 				LocalMemoryBegin:                    8,
 				ImportedMemoryBegin:                 -1,
@@ -106,7 +106,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				ImportFunctionCount: 10,
 				ImportTableCount:    5,
 				TableSection:        make([]wasm.Table, 10),
-				MemorySection:       &wasm.Memory{},
+				MemorySection:       []wasm.Memory{{}},
 				GlobalSection:       make([]wasm.Global, 20),
 			},
 			exp: ModuleContextOffsetData{
@@ -130,7 +130,7 @@ func TestNewModuleContextOffsetData(t *testing.T) {
 				ImportFunctionCount: 10,
 				ImportTableCount:    5,
 				TableSection:        make([]wasm.Table, 10),
-				MemorySection:       &wasm.Memory{},
+				MemorySection:       []wasm.Memory{{}},
 				GlobalSection:       make([]wasm.Global, 20),
 			},
 			withListener: true,
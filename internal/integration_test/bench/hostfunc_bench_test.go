@@ -173,7 +173,7 @@ func setupHostCallBench(requireNoError func(error)) *wasm.ModuleInstance {
 		ID: wasm.ModuleID{1, 2, 3, 4, 5},
 	}
 
-	host := &wasm.ModuleInstance{ModuleName: "host", TypeIDs: []wasm.FunctionTypeID{0}}
+	host := &wasm.ModuleInstance{ModuleName: "host", TypeIDs: []wasm.FunctionTypeID{0}, Source: hostModule}
 	host.Exports = hostModule.Exports
 
 	err := eng.CompileModule(testCtx, hostModule, nil, false)
@@ -206,14 +206,14 @@ func setupHostCallBench(requireNoError func(error)) *wasm.ModuleInstance {
 			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 1, wasm.OpcodeEnd}}, // Calling the index 1 = host.go-reflect.
 		},
 		// Indicates that this module has a memory so that compilers are able to assemble memory-related initialization.
-		MemorySection: &wasm.Memory{Min: 1},
+		MemorySection: []wasm.Memory{{Min: 1}},
 		ID:            wasm.ModuleID{1},
 	}
 
 	err = eng.CompileModule(testCtx, importingModule, nil, false)
 	requireNoError(err)
 
-	importing := &wasm.ModuleInstance{TypeIDs: []wasm.FunctionTypeID{0}}
+	importing := &wasm.ModuleInstance{TypeIDs: []wasm.FunctionTypeID{0}, Source: importingModule}
 	importing.Exports = importingModule.Exports
 
 	importingMe, err := eng.NewModuleEngine(importingModule, importing)
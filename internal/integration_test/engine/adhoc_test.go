@@ -22,11 +22,11 @@ import (
 	"github.com/AR1011/wazero/experimental/table"
 	"github.com/AR1011/wazero/internal/leb128"
 	"github.com/AR1011/wazero/internal/platform"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/proxy"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
 	"github.com/AR1011/wazero/internal/wasm/binary"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 	"github.com/AR1011/wazero/internal/wasmdebug"
 	"github.com/AR1011/wazero/internal/wasmruntime"
 	"github.com/AR1011/wazero/sys"
@@ -94,7 +94,7 @@ const i32, i64, f32, f64, v128 = wasm.ValueTypeI32, wasm.ValueTypeI64, wasm.Valu
 var memoryCapacityPages = uint32(2)
 
 func TestEngineWazevo(t *testing.T) {
-	if runtime.GOARCH != "arm64" {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
 		t.Skip()
 	}
 	config := opt.NewRuntimeConfigOptimizingCompiler()
@@ -842,7 +842,7 @@ func testMultipleInstantiation(t *testing.T, r wazero.Runtime) {
 	bin := binaryencoding.EncodeModule(&wasm.Module{
 		TypeSection:     []wasm.FunctionType{{}},
 		FunctionSection: []wasm.Index{0},
-		MemorySection:   &wasm.Memory{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true},
+		MemorySection:   []wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}},
 		CodeSection: []wasm.Code{{
 			Body: []byte{
 				wasm.OpcodeI32Const, 1, // i32.const 1    ;; memory offset
@@ -979,7 +979,7 @@ func testMemoryGrowInRecursiveCall(t *testing.T, r wazero.Runtime) {
 				Body: []byte{wasm.OpcodeI32Const, 1, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeDrop, wasm.OpcodeEnd},
 			},
 		},
-		MemorySection:   &wasm.Memory{Max: 1000},
+		MemorySection:   []wasm.Memory{{Max: 1000}},
 		ImportSection:   []wasm.Import{{Module: hostModuleName, Name: hostFnName, DescFunc: 0}},
 		ImportPerModule: map[string][]*wasm.Import{hostModuleName: {{Module: hostModuleName, Name: hostFnName, DescFunc: 0}}},
 		ExportSection: []wasm.Export{
@@ -1065,7 +1065,7 @@ func testModuleMemory(t *testing.T, r wazero.Runtime) {
 	bin := binaryencoding.EncodeModule(&wasm.Module{
 		TypeSection:     []wasm.FunctionType{{Params: []api.ValueType{api.ValueTypeI32}, ParamNumInUint64: 1}, {}},
 		FunctionSection: []wasm.Index{0, 1},
-		MemorySection:   &wasm.Memory{Min: 1, Cap: 1, Max: 2},
+		MemorySection:   []wasm.Memory{{Min: 1, Cap: 1, Max: 2}},
 		DataSection: []wasm.DataSegment{
 			{
 				Passive: true,
@@ -1489,6 +1489,9 @@ func testListenerStackIteratorOffset(t *testing.T, r wazero.Runtime) {
 		0x0, 0x0, 0x0, 0x0, // abbrev offset
 		0x0, // asize
 	}
+	// The abbreviation table at the abbrev offset above, which only has its terminating entry. debug/dwarf.New()
+	// reads it eagerly, so it fails without the section.
+	minimalDWARFAbbrev := []byte{0x0}
 
 	encoded := binaryencoding.EncodeModule(&wasm.Module{
 		TypeSection: []wasm.FunctionType{
@@ -1534,7 +1537,10 @@ func testListenerStackIteratorOffset(t *testing.T, r wazero.Runtime) {
 			{Name: "f2", Type: wasm.ExternTypeFunc, Index: 1},
 			{Name: "f3", Type: wasm.ExternTypeFunc, Index: 2},
 		},
-		CustomSections: []*wasm.CustomSection{{Name: ".debug_info", Data: minimalDWARFInfo}},
+		CustomSections: []*wasm.CustomSection{
+			{Name: ".debug_abbrev", Data: minimalDWARFAbbrev},
+			{Name: ".debug_info", Data: minimalDWARFInfo},
+		},
 	})
 	decoded, err := binary.DecodeModule(encoded, api.CoreFeaturesV2, 0, false, true, true)
 	require.NoError(t, err)
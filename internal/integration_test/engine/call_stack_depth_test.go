@@ -0,0 +1,237 @@
+package adhoc
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+	"github.com/AR1011/wazero/internal/wasmruntime"
+)
+
+var callStackDepthTests = map[string]testCase{
+	"call stack overflow":           {f: testCallStackDepthOverflow},
+	"call stack depth on return":    {f: testCallStackDepthOnReturn},
+	"call stack depth per call":     {f: testCallStackDepthPerCall},
+	"call stack depth on tail call": {f: testCallStackDepthOnTailCall, wazevoSkip: true},
+	// wazevo doesn't support exception handling yet.
+	"call stack depth on exception": {f: testCallStackDepthOnException, wazevoSkip: true},
+}
+
+const callStackDepthFeatures = api.CoreFeaturesV2 | api.CoreFeatureTailCall | api.CoreFeatureExceptionHandling
+
+// maxCallStackDepth is the maximum call stack depth configured for callStackDepthTests.
+const maxCallStackDepth = 100
+
+func TestEngineCompiler_callStackDepth(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	c := wazero.NewRuntimeConfigCompiler().WithCoreFeatures(callStackDepthFeatures).WithMaxCallStackDepth(maxCallStackDepth)
+	runAllTests(t, callStackDepthTests, c, false)
+	testCallStackDepthSharedCache(t, c)
+}
+
+func TestEngineInterpreter_callStackDepth(t *testing.T) {
+	c := wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(callStackDepthFeatures).WithMaxCallStackDepth(maxCallStackDepth)
+	runAllTests(t, callStackDepthTests, c, false)
+	testCallStackDepthSharedCache(t, c)
+}
+
+func TestEngineWazevo_callStackDepth(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	c := opt.NewRuntimeConfigOptimizingCompiler().WithCoreFeatures(callStackDepthFeatures).WithMaxCallStackDepth(maxCallStackDepth)
+	runAllTests(t, callStackDepthTests, c, true)
+	testCallStackDepthSharedCache(t, c)
+}
+
+// testCallStackDepthSharedCache ensures each runtime applies its own depth, even though the module compiled by the
+// first runtime is reused by the second one via the shared CompilationCache.
+func testCallStackDepthSharedCache(t *testing.T, c wazero.RuntimeConfig) {
+	t.Run("call stack depth with shared cache", func(t *testing.T) {
+		cache := wazero.NewCompilationCache()
+		defer cache.Close(testCtx)
+		bin := callStackDepthWasm(t)
+
+		for _, depth := range []uint64{maxCallStackDepth, 2 * maxCallStackDepth} {
+			r := wazero.NewRuntimeWithConfig(testCtx, c.WithCompilationCache(cache).WithMaxCallStackDepth(uint32(depth)))
+			mod, err := r.Instantiate(testCtx, bin)
+			require.NoError(t, err)
+			recurse := mod.ExportedFunction("recurse")
+
+			res, err := recurse.Call(testCtx, depth-1)
+			require.NoError(t, err)
+			require.Equal(t, depth-1, res[0])
+			_, err = recurse.Call(testCtx, depth)
+			require.ErrorIs(t, err, wasmruntime.ErrRuntimeStackOverflow)
+			require.NoError(t, r.Close(testCtx))
+		}
+	})
+}
+
+// callStackDepthWasm exports the following functions of type (i32) -> i32:
+//
+//   - "recurse" which returns the parameter n by recursing n times.
+//   - "tree" which returns 2^n-1 by calling itself twice per call, and returns with br_if at n == 0.
+//   - "tail" which returns zero by tail recursing n times.
+func callStackDepthWasm(t *testing.T) []byte {
+	module := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0, 0, 0},
+		CodeSection: []wasm.Code{
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeIf, byte(wasm.ValueTypeI32),
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub, wasm.OpcodeCall, 0,
+				wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add,
+				wasm.OpcodeElse,
+				wasm.OpcodeI32Const, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{
+				wasm.OpcodeI32Const, 0, wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Eqz, wasm.OpcodeBrIf, 0, wasm.OpcodeDrop,
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub, wasm.OpcodeCall, 1,
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub, wasm.OpcodeCall, 1,
+				wasm.OpcodeI32Add, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Eqz,
+				wasm.OpcodeIf, 0x40, // empty block type.
+				wasm.OpcodeI32Const, 0, wasm.OpcodeReturn,
+				wasm.OpcodeEnd,
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub, wasm.OpcodeReturnCall, 2,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "recurse", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "tree", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "tail", Type: wasm.ExternTypeFunc, Index: 2},
+		},
+		NameSection: &wasm.NameSection{
+			ModuleName:    "depth",
+			FunctionNames: wasm.NameMap{{Index: 0, Name: "recurse"}, {Index: 1, Name: "tree"}, {Index: 2, Name: "tail"}},
+		},
+	}
+	require.NoError(t, module.Validate(callStackDepthFeatures))
+	return binaryencoding.EncodeModule(module)
+}
+
+func testCallStackDepthOverflow(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, callStackDepthWasm(t))
+	require.NoError(t, err)
+	recurse := mod.ExportedFunction("recurse")
+
+	// The initial call is the first frame, so recursing maxCallStackDepth-1 times reaches the limit.
+	res, err := recurse.Call(testCtx, maxCallStackDepth-1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(maxCallStackDepth-1), res[0])
+
+	_, err = recurse.Call(testCtx, maxCallStackDepth)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeStackOverflow)
+	require.Contains(t, err.Error(), "wasm error: stack overflow\nwasm stack trace:\n\tdepth.recurse(i32) i32\n")
+
+	// The function can be called again after the overflow.
+	res, err = recurse.Call(testCtx, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), res[0])
+}
+
+func testCallStackDepthOnReturn(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, callStackDepthWasm(t))
+	require.NoError(t, err)
+
+	// This makes 2^10-1 calls in total, which would overflow unless the depth is decremented on each return.
+	res, err := mod.ExportedFunction("tree").Call(testCtx, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1023), res[0])
+}
+
+func testCallStackDepthPerCall(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, callStackDepthWasm(t))
+	require.NoError(t, err)
+	recurse := mod.ExportedFunction("recurse")
+
+	// Clamp the call lower than the runtime configuration.
+	ctx := experimental.WithMaxCallStackDepth(testCtx, 10)
+	_, err = recurse.Call(ctx, 9)
+	require.NoError(t, err)
+	_, err = recurse.Call(ctx, 10)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeStackOverflow)
+
+	// Allow the call to recurse deeper than the runtime configuration.
+	ctx = experimental.WithMaxCallStackDepth(testCtx, 10*maxCallStackDepth)
+	res, err := recurse.Call(ctx, 5*maxCallStackDepth)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5*maxCallStackDepth), res[0])
+}
+
+func testCallStackDepthOnTailCall(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, callStackDepthWasm(t))
+	require.NoError(t, err)
+
+	// Tail calls reuse the frame, so they don't increase the depth.
+	res, err := mod.ExportedFunction("tail").Call(testCtx, 10*maxCallStackDepth)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+}
+
+func testCallStackDepthOnException(t *testing.T, r wazero.Runtime) {
+	module := &wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{},
+		},
+		TagSection:      []wasm.Index{1},
+		FunctionSection: []wasm.Index{0, 0},
+		CodeSection: []wasm.Code{
+			// throw(n) throws the exception after recursing n times.
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeIf, byte(wasm.ValueTypeI32),
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Sub, wasm.OpcodeCall, 0,
+				wasm.OpcodeElse,
+				wasm.OpcodeThrow, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+			// catch(n) catches the exception thrown by throw(n) n times, and returns n.
+			{
+				LocalTypes: []wasm.ValueType{i32},
+				Body: []byte{
+					wasm.OpcodeLoop, 0x40,
+					wasm.OpcodeBlock, 0x40,
+					wasm.OpcodeTryTable, 0x40, 1, wasm.TryTableCatchKindCatchAll, 0,
+					wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 0, wasm.OpcodeDrop,
+					wasm.OpcodeEnd,
+					wasm.OpcodeEnd,
+					wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Const, 1, wasm.OpcodeI32Add, wasm.OpcodeLocalTee, 1,
+					wasm.OpcodeLocalGet, 0, wasm.OpcodeI32LtU, wasm.OpcodeBrIf, 0,
+					wasm.OpcodeEnd,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeEnd,
+				},
+			},
+		},
+		ExportSection: []wasm.Export{{Name: "catch", Type: wasm.ExternTypeFunc, Index: 1}},
+	}
+	require.NoError(t, module.Validate(callStackDepthFeatures))
+
+	mod, err := r.Instantiate(testCtx, binaryencoding.EncodeModule(module))
+	require.NoError(t, err)
+
+	// Each iteration unwinds 51 frames, which would overflow unless the depth is decremented on unwinding.
+	res, err := mod.ExportedFunction("catch").Call(testCtx, 50)
+	require.NoError(t, err)
+	require.Equal(t, uint64(50), res[0])
+}
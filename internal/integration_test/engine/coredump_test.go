@@ -0,0 +1,215 @@
+package adhoc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	wasmbinary "github.com/AR1011/wazero/internal/wasm/binary"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+	"github.com/AR1011/wazero/internal/wasmruntime"
+	"github.com/AR1011/wazero/sys"
+)
+
+// coreDumpTests returns the tests of coredumps, given whether the engine records the values of locals.
+func coreDumpTests(recordsLocals bool) map[string]testCase {
+	return map[string]testCase{
+		"coredump on trap":    {f: func(t *testing.T, r wazero.Runtime) { testCoreDumpTrap(t, r, recordsLocals) }},
+		"no coredump on exit": {f: testCoreDumpExit},
+	}
+}
+
+func TestEngineCompiler_coreDump(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, coreDumpTests(false), wazero.NewRuntimeConfigCompiler().WithCoreDump(true), false)
+}
+
+func TestEngineInterpreter_coreDump(t *testing.T) {
+	runAllTests(t, coreDumpTests(true), wazero.NewRuntimeConfigInterpreter().WithCoreDump(true), false)
+}
+
+func TestEngineWazevo_coreDump(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	runAllTests(t, coreDumpTests(false), opt.NewRuntimeConfigOptimizingCompiler().WithCoreDump(true), true)
+}
+
+func TestCoreDump_disabled(t *testing.T) {
+	r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(testCtx)
+
+	mod := instantiateCoreDumpModule(t, r)
+	_, err := mod.ExportedFunction("main").Call(testCtx)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeUnreachable)
+	require.Nil(t, experimental.CoreDump(err))
+}
+
+// coreDumpWasm exports the following functions:
+//
+//   - "main" of type () -> () which calls $crash with 5.
+//   - "exit" of type () -> () which calls the imported "env.exit".
+//
+// $crash has a param and an i64 local set to -7, and sets the global to its param before reaching unreachable.
+// The memory contains "hello" at offset 16, and the global is initially 42.
+func coreDumpWasm(t *testing.T) []byte {
+	module := &wasm.Module{
+		TypeSection:         []wasm.FunctionType{{}, {Params: []wasm.ValueType{i32}}},
+		ImportSection:       []wasm.Import{{Module: "env", Name: "exit", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+		ImportFunctionCount: 1,
+		FunctionSection:     []wasm.Index{1, 0, 0},
+		CodeSection: []wasm.Code{
+			{LocalTypes: []wasm.ValueType{i64}, Body: []byte{
+				wasm.OpcodeI64Const, 0x79, // -7
+				wasm.OpcodeLocalSet, 1,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeGlobalSet, 0,
+				wasm.OpcodeUnreachable,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{wasm.OpcodeI32Const, 5, wasm.OpcodeCall, 1, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+		},
+		MemorySection: []wasm.Memory{{Min: 1}},
+		GlobalSection: []wasm.Global{{
+			Type: wasm.GlobalType{ValType: i32, Mutable: true},
+			Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(42)},
+		}},
+		DataSection: []wasm.DataSegment{{
+			OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(16)},
+			Init:             []byte("hello"),
+		}},
+		ExportSection: []wasm.Export{
+			{Name: "main", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "exit", Type: wasm.ExternTypeFunc, Index: 3},
+		},
+	}
+	require.NoError(t, module.Validate(api.CoreFeaturesV2))
+	return binaryencoding.EncodeModule(module)
+}
+
+func instantiateCoreDumpModule(t *testing.T, r wazero.Runtime) api.Module {
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(context.Context) {
+		panic(sys.NewExitError(3))
+	}).Export("exit").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+	mod, err := r.InstantiateWithConfig(testCtx, coreDumpWasm(t), wazero.NewModuleConfig().WithName("crash"))
+	require.NoError(t, err)
+	return mod
+}
+
+// coreDumpFrame is a frame of the corestack section, whose locals are nil when missing.
+type coreDumpFrame struct {
+	instance, function, codeOffset uint32
+	locals                         []interface{}
+}
+
+func testCoreDumpTrap(t *testing.T, r wazero.Runtime, recordsLocals bool) {
+	mod := instantiateCoreDumpModule(t, r)
+
+	_, err := mod.ExportedFunction("main").Call(testCtx)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeUnreachable)
+	dump := experimental.CoreDump(err)
+	require.NotNil(t, dump)
+
+	decoded, err := wasmbinary.DecodeModule(dump, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, false, true)
+	require.NoError(t, err)
+	sections := map[string][]byte{}
+	for _, s := range decoded.CustomSections {
+		sections[s.Name] = s.Data
+	}
+	require.Equal(t, []byte("\x00\x05crash"), sections["core"])
+	require.Equal(t, []byte("\x01\x00\x05crash"), sections["coremodules"])
+	// One instance of the first module, with the first memory and global.
+	require.Equal(t, []byte{1, 0, 0, 1, 0, 1, 0}, sections["coreinstances"])
+
+	// Code offsets are relative to the start of the functions, which begin with the declaration of their locals.
+	crashLocals := []interface{}{nil, nil}
+	if recordsLocals {
+		crashLocals = []interface{}{int32(5), int64(-7)}
+	}
+	require.Equal(t, []coreDumpFrame{
+		{function: 1, codeOffset: 11, locals: crashLocals},
+		{function: 2, codeOffset: 3, locals: []interface{}{}},
+	}, parseCoreStack(t, sections["corestack"]))
+
+	// The memory and the global are dumped as they were when it trapped.
+	require.Equal(t, []wasm.Memory{{Min: 1, Cap: 1, Max: wasm.MemoryLimitPages}}, decoded.MemorySection)
+	require.Equal(t, []wasm.DataSegment{{
+		OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(0)},
+		Init:             append(make([]byte, 16), "hello"...),
+	}}, decoded.DataSection)
+	require.Equal(t, []wasm.Global{{
+		Type: wasm.GlobalType{ValType: i32, Mutable: true},
+		Init: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(5)},
+	}}, decoded.GlobalSection)
+}
+
+func testCoreDumpExit(t *testing.T, r wazero.Runtime) {
+	mod := instantiateCoreDumpModule(t, r)
+
+	_, err := mod.ExportedFunction("exit").Call(testCtx)
+	var exitErr *sys.ExitError
+	require.True(t, errors.As(err, &exitErr))
+	require.Equal(t, uint32(3), exitErr.ExitCode())
+	require.Nil(t, experimental.CoreDump(err))
+}
+
+// parseCoreStack parses the frames of the corestack section of the thread "main".
+func parseCoreStack(t *testing.T, data []byte) (frames []coreDumpFrame) {
+	r := bytes.NewReader(data)
+	u32 := func() uint32 {
+		v, _, err := leb128.DecodeUint32(r)
+		require.NoError(t, err)
+		return v
+	}
+	b := func() byte {
+		v, err := r.ReadByte()
+		require.NoError(t, err)
+		return v
+	}
+	require.Equal(t, byte(0), b())
+	name := make([]byte, u32())
+	_, err := r.Read(name)
+	require.NoError(t, err)
+	require.Equal(t, "main", string(name))
+
+	for i := u32(); i > 0; i-- {
+		require.Equal(t, byte(0), b())
+		f := coreDumpFrame{instance: u32(), function: u32(), codeOffset: u32(), locals: []interface{}{}}
+		for j := u32(); j > 0; j-- {
+			switch tag := b(); tag {
+			case 0x01:
+				f.locals = append(f.locals, nil)
+			case i32:
+				v, _, err := leb128.DecodeInt32(r)
+				require.NoError(t, err)
+				f.locals = append(f.locals, v)
+			case i64:
+				v, _, err := leb128.DecodeInt64(r)
+				require.NoError(t, err)
+				f.locals = append(f.locals, v)
+			default:
+				t.Fatalf("unexpected value type %#x", tag)
+			}
+		}
+		require.Equal(t, uint32(0), u32()) // no values on the operand stack.
+		frames = append(frames, f)
+	}
+	require.Equal(t, 0, r.Len())
+	return
+}
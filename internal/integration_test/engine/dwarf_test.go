@@ -34,7 +34,7 @@ func TestEngineInterpreter_DWARF(t *testing.T) {
 }
 
 func TestEngineWazevo_DWARF(t *testing.T) {
-	if runtime.GOARCH != "arm64" {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
 		t.Skip()
 	}
 	config := opt.NewRuntimeConfigOptimizingCompiler()
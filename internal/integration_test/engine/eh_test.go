@@ -0,0 +1,226 @@
+package adhoc
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+	"github.com/AR1011/wazero/internal/wasmruntime"
+)
+
+var exceptionHandlingTests = map[string]testCase{
+	// wazevo doesn't support exception handling yet.
+	"catch":                    {f: testExceptionHandlingCatch, wazevoSkip: true},
+	"uncaught":                 {f: testExceptionHandlingUncaught, wazevoSkip: true},
+	"throw_ref":                {f: testExceptionHandlingThrowRef, wazevoSkip: true},
+	"host":                     {f: testExceptionHandlingHost, wazevoSkip: true},
+	"rejected without feature": {f: testExceptionHandlingDisabled},
+}
+
+const exceptionHandlingFeatures = api.CoreFeaturesV2 | api.CoreFeatureExceptionHandling
+
+func TestEngineCompiler_exceptionHandling(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, exceptionHandlingTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(exceptionHandlingFeatures), false)
+}
+
+func TestEngineInterpreter_exceptionHandling(t *testing.T) {
+	runAllTests(t, exceptionHandlingTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(exceptionHandlingFeatures), false)
+}
+
+func TestEngineWazevo_exceptionHandling(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	c := opt.NewRuntimeConfigOptimizingCompiler().WithCoreFeatures(exceptionHandlingFeatures)
+	runAllTests(t, exceptionHandlingTests, c, true)
+}
+
+// exceptionHandlingWasm defines the tag "e" carrying an i32, and exports functions throwing and catching
+// exceptions with it. The function "env.throw" is imported to throw the exception from the host.
+func exceptionHandlingWasm(t *testing.T) []byte {
+	const (
+		blockTypeVoid   = 0x40
+		blockTypeI32    = byte(wasm.ValueTypeI32)
+		blockTypeExnref = byte(wasm.ValueTypeExnref)
+		// blockTypeI32Exnref is the index of the type with the results (i32, exnref).
+		blockTypeI32Exnref = 3
+	)
+	tryTable := func(blockType byte, catches ...byte) []byte {
+		return append([]byte{wasm.OpcodeTryTable, blockType}, catches...)
+	}
+
+	module := &wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}},
+			{Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Results: []wasm.ValueType{i32, wasm.ValueTypeExnref}},
+		},
+		ImportSection: []wasm.Import{
+			{Module: "env", Name: "throw", Type: wasm.ExternTypeFunc, DescFunc: 0},
+		},
+		ImportFunctionCount: 1,
+		TagSection:          []wasm.Index{0},
+		FunctionSection:     []wasm.Index{0, 2, 0, 0, 1, 2, 2},
+		CodeSection: []wasm.Code{
+			// throw(v) throws the tag with v.
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeThrow, 0, wasm.OpcodeEnd}},
+			// catch(v) returns 1000+v by catching the exception thrown by throw(v), which unwinds the values on the
+			// stack in the try_table.
+			{Body: concat(
+				[]byte{wasm.OpcodeI32Const, 0xe8, 0x07, wasm.OpcodeBlock, blockTypeI32},
+				tryTable(blockTypeI32, 1, wasm.TryTableCatchKindCatch, 0, 0),
+				[]byte{
+					wasm.OpcodeI32Const, 5, wasm.OpcodeI32Const, 6,
+					wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 1,
+					wasm.OpcodeDrop, wasm.OpcodeDrop, wasm.OpcodeI32Const, 0,
+					wasm.OpcodeEnd, wasm.OpcodeEnd, wasm.OpcodeI32Add, wasm.OpcodeEnd,
+				},
+			)},
+			// uncaught(v) calls throw(v) without catching it.
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 1, wasm.OpcodeEnd}},
+			// rethrow(v) catches the exception thrown by throw(v) with catch_all_ref, and rethrows it.
+			{Body: concat(
+				[]byte{wasm.OpcodeBlock, blockTypeExnref},
+				tryTable(blockTypeVoid, 1, wasm.TryTableCatchKindCatchAllRef, 0),
+				[]byte{
+					wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 1, wasm.OpcodeEnd,
+					wasm.OpcodeUnreachable, wasm.OpcodeEnd, wasm.OpcodeThrowRef, wasm.OpcodeEnd,
+				},
+			)},
+			// catch_all() returns 1 if the exception thrown in the same function is caught.
+			{Body: concat(
+				[]byte{wasm.OpcodeBlock, blockTypeVoid},
+				tryTable(blockTypeVoid, 1, wasm.TryTableCatchKindCatchAll, 0),
+				[]byte{
+					wasm.OpcodeI32Const, 7, wasm.OpcodeThrow, 0, wasm.OpcodeEnd,
+					wasm.OpcodeI32Const, 0, wasm.OpcodeReturn, wasm.OpcodeEnd,
+					wasm.OpcodeI32Const, 1, wasm.OpcodeEnd,
+				},
+			)},
+			// catch_rethrown(v) returns v by catching the exception rethrown by throw_ref after catch_ref in the inner
+			// try_table.
+			{Body: concat(
+				[]byte{wasm.OpcodeBlock, blockTypeI32},
+				tryTable(blockTypeI32, 1, wasm.TryTableCatchKindCatch, 0, 0),
+				[]byte{wasm.OpcodeBlock, blockTypeI32Exnref},
+				tryTable(blockTypeVoid, 1, wasm.TryTableCatchKindCatchRef, 0, 0),
+				[]byte{
+					wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 1, wasm.OpcodeEnd,
+					wasm.OpcodeUnreachable, wasm.OpcodeEnd,
+					wasm.OpcodeThrowRef, wasm.OpcodeEnd, wasm.OpcodeEnd, wasm.OpcodeEnd,
+				},
+			)},
+			// catch_host(v) returns v by catching the exception thrown by env.throw(v).
+			{Body: concat(
+				[]byte{wasm.OpcodeBlock, blockTypeI32},
+				tryTable(blockTypeI32, 1, wasm.TryTableCatchKindCatch, 0, 0),
+				[]byte{
+					wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 0, wasm.OpcodeI32Const, 0,
+					wasm.OpcodeEnd, wasm.OpcodeEnd, wasm.OpcodeEnd,
+				},
+			)},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "e", Type: wasm.ExternTypeTag, Index: 0},
+			{Name: "catch", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "uncaught", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "rethrow", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "catch_all", Type: wasm.ExternTypeFunc, Index: 5},
+			{Name: "catch_rethrown", Type: wasm.ExternTypeFunc, Index: 6},
+			{Name: "catch_host", Type: wasm.ExternTypeFunc, Index: 7},
+		},
+	}
+	require.NoError(t, module.Validate(exceptionHandlingFeatures))
+	return binaryencoding.EncodeModule(module)
+}
+
+func concat(bodies ...[]byte) (ret []byte) {
+	for _, b := range bodies {
+		ret = append(ret, b...)
+	}
+	return
+}
+
+// instantiateExceptionHandlingWasm instantiates exceptionHandlingWasm with env.throw which throws the tag "e" of the
+// calling module.
+func instantiateExceptionHandlingWasm(t *testing.T, r wazero.Runtime) api.Module {
+	_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, mod api.Module, v uint32) {
+			panic(api.NewException(mod.ExportedTag("e"), api.EncodeU32(v)))
+		}).Export("throw").Instantiate(testCtx)
+	require.NoError(t, err)
+
+	mod, err := r.Instantiate(testCtx, exceptionHandlingWasm(t))
+	require.NoError(t, err)
+	return mod
+}
+
+func testExceptionHandlingCatch(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionHandlingWasm(t, r)
+
+	res, err := mod.ExportedFunction("catch").Call(testCtx, 42)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1042), res[0])
+
+	res, err = mod.ExportedFunction("catch_all").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	// The function is still callable after the exception is caught.
+	res, err = mod.ExportedFunction("catch").Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1001), res[0])
+}
+
+func testExceptionHandlingUncaught(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionHandlingWasm(t, r)
+
+	for _, name := range []string{"uncaught", "rethrow"} {
+		_, err := mod.ExportedFunction(name).Call(testCtx, 42)
+		require.Error(t, err)
+
+		var exception *api.Exception
+		require.True(t, errors.As(err, &exception))
+		require.Equal(t, mod.ExportedTag("e"), exception.Tag())
+		require.Equal(t, []uint64{42}, exception.Params())
+	}
+
+	// The exception isn't a trap.
+	_, err := mod.ExportedFunction("uncaught").Call(testCtx, 1)
+	var wasmErr *wasmruntime.Error
+	require.False(t, errors.As(err, &wasmErr))
+}
+
+func testExceptionHandlingThrowRef(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionHandlingWasm(t, r)
+
+	res, err := mod.ExportedFunction("catch_rethrown").Call(testCtx, 42)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
+
+func testExceptionHandlingHost(t *testing.T, r wazero.Runtime) {
+	mod := instantiateExceptionHandlingWasm(t, r)
+
+	res, err := mod.ExportedFunction("catch_host").Call(testCtx, 42)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
+
+func testExceptionHandlingDisabled(t *testing.T, _ wazero.Runtime) {
+	r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(api.CoreFeaturesV2))
+	_, err := r.CompileModule(testCtx, exceptionHandlingWasm(t))
+	require.Error(t, err)
+}
@@ -0,0 +1,181 @@
+package adhoc
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+	"github.com/AR1011/wazero/sys"
+)
+
+var epochTests = map[string]testCase{
+	"epoch deadline exceeded in loop":  {f: testEpochDeadlineExceededInLoop},
+	"epoch deadline exceeded on entry": {f: testEpochDeadlineExceededOnEntry},
+	"no epoch deadline by default":     {f: testEpochNoDeadlineByDefault},
+	"epoch deadline per call":          {f: testEpochDeadlinePerCall},
+	"epoch deadline per call is local": {f: testEpochDeadlinePerCallIsLocal},
+}
+
+func TestEngineCompiler_epoch(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, epochTests, wazero.NewRuntimeConfigCompiler().WithEpochInterruption(true), false)
+}
+
+func TestEngineInterpreter_epoch(t *testing.T) {
+	runAllTests(t, epochTests, wazero.NewRuntimeConfigInterpreter().WithEpochInterruption(true), false)
+}
+
+func TestEngineWazevo_epoch(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	runAllTests(t, epochTests, opt.NewRuntimeConfigOptimizingCompiler().WithEpochInterruption(true), true)
+}
+
+// epochWasm exports "spin" which calls the imported "env.tick" in an infinite loop, and "nop" which returns immediately.
+func epochWasm(t *testing.T) []byte {
+	module := &wasm.Module{
+		TypeSection:         []wasm.FunctionType{{}},
+		ImportSection:       []wasm.Import{{Module: "env", Name: "tick", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+		ImportFunctionCount: 1,
+		FunctionSection:     []wasm.Index{0, 0},
+		CodeSection: []wasm.Code{
+			{Body: []byte{wasm.OpcodeLoop, 0x40, wasm.OpcodeCall, 0, wasm.OpcodeBr, 0, wasm.OpcodeEnd, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeEnd}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "spin", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "nop", Type: wasm.ExternTypeFunc, Index: 2},
+		},
+	}
+	require.NoError(t, module.Validate(api.CoreFeaturesV2))
+	return binaryencoding.EncodeModule(module)
+}
+
+// instantiateEpochModule instantiates epochWasm with the host function "env.tick" which advances the epoch, and
+// returns the number of ticks.
+func instantiateEpochModule(t *testing.T, r wazero.Runtime) (api.Module, *int) {
+	return instantiateEpochModuleWithTick(t, r, func(context.Context, api.Module, int) {})
+}
+
+// instantiateEpochModuleWithTick is like instantiateEpochModule, but also calls onTick with the number of ticks after
+// advancing the epoch.
+func instantiateEpochModuleWithTick(t *testing.T, r wazero.Runtime, onTick func(context.Context, api.Module, int)) (api.Module, *int) {
+	var ticks int
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, mod api.Module) {
+		ticks++
+		r.IncrementEpoch()
+		onTick(ctx, mod, ticks)
+	}).Export("tick").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	mod, err := r.Instantiate(testCtx, epochWasm(t))
+	require.NoError(t, err)
+	return mod, &ticks
+}
+
+func testEpochDeadlineExceededInLoop(t *testing.T, r wazero.Runtime) {
+	mod, ticks := instantiateEpochModule(t, r)
+
+	// The deadline is checked on each iteration, so the call traps right after the third tick.
+	mod.SetEpochDeadline(3)
+	_, err := mod.ExportedFunction("spin").Call(testCtx)
+	require.Equal(t, sys.NewExitError(sys.ExitCodeEpochDeadlineExceeded), err)
+	require.EqualError(t, err, "module exceeded the epoch deadline")
+	require.Equal(t, 3, *ticks)
+
+	// Only the call is trapped, and the module is still usable with a new deadline.
+	require.False(t, mod.IsClosed())
+	mod.SetEpochDeadline(1)
+	_, err = mod.ExportedFunction("nop").Call(testCtx)
+	require.NoError(t, err)
+}
+
+func testEpochDeadlineExceededOnEntry(t *testing.T, r wazero.Runtime) {
+	mod, _ := instantiateEpochModule(t, r)
+
+	// The deadline is the current epoch, so the call traps on entry.
+	mod.SetEpochDeadline(0)
+	_, err := mod.ExportedFunction("nop").Call(testCtx)
+	require.Equal(t, sys.NewExitError(sys.ExitCodeEpochDeadlineExceeded), err)
+
+	mod.SetEpochDeadline(1)
+	_, err = mod.ExportedFunction("nop").Call(testCtx)
+	require.NoError(t, err)
+
+	// The deadline is relative to the epoch when it's set.
+	r.IncrementEpoch()
+	_, err = mod.ExportedFunction("nop").Call(testCtx)
+	require.Equal(t, sys.NewExitError(sys.ExitCodeEpochDeadlineExceeded), err)
+	mod.SetEpochDeadline(1)
+	_, err = mod.ExportedFunction("nop").Call(testCtx)
+	require.NoError(t, err)
+}
+
+func testEpochNoDeadlineByDefault(t *testing.T, r wazero.Runtime) {
+	mod, _ := instantiateEpochModule(t, r)
+
+	for i := 0; i < 10; i++ {
+		r.IncrementEpoch()
+	}
+	_, err := mod.ExportedFunction("nop").Call(testCtx)
+	require.NoError(t, err)
+}
+
+func testEpochDeadlinePerCall(t *testing.T, r wazero.Runtime) {
+	mod, ticks := instantiateEpochModule(t, r)
+
+	// The deadline is relative to the epoch at the start of each call.
+	ctx := experimental.WithEpochDeadline(testCtx, 2)
+	for i := 1; i <= 2; i++ {
+		_, err := mod.ExportedFunction("spin").Call(ctx)
+		require.Equal(t, sys.NewExitError(sys.ExitCodeEpochDeadlineExceeded), err)
+		require.Equal(t, 2*i, *ticks)
+	}
+
+	// The deadline of the module still applies, so the call traps at whichever is reached first.
+	mod.SetEpochDeadline(0)
+	_, err := mod.ExportedFunction("nop").Call(experimental.WithEpochDeadline(testCtx, 1))
+	require.Equal(t, sys.NewExitError(sys.ExitCodeEpochDeadlineExceeded), err)
+	mod.SetEpochDeadline(math.MaxUint64)
+	_, err = mod.ExportedFunction("nop").Call(experimental.WithEpochDeadline(testCtx, 1))
+	require.NoError(t, err)
+}
+
+func testEpochDeadlinePerCallIsLocal(t *testing.T, r wazero.Runtime) {
+	var nestedErr error
+	mod, ticks := instantiateEpochModuleWithTick(t, r, func(ctx context.Context, mod api.Module, ticks int) {
+		switch ticks {
+		case 1:
+			// A call from the host function has its own deadline, which doesn't extend the one of the outer call.
+			_, nestedErr = mod.ExportedFunction("nop").Call(experimental.WithEpochDeadline(ctx, 100))
+		case 4:
+			// The deadline of the module set during a call isn't undone when the call returns.
+			mod.SetEpochDeadline(0)
+		}
+	})
+
+	_, err := mod.ExportedFunction("spin").Call(experimental.WithEpochDeadline(testCtx, 3))
+	require.Equal(t, sys.NewExitError(sys.ExitCodeEpochDeadlineExceeded), err)
+	require.NoError(t, nestedErr)
+	require.Equal(t, 3, *ticks)
+
+	_, err = mod.ExportedFunction("spin").Call(experimental.WithEpochDeadline(testCtx, 100))
+	require.Equal(t, sys.NewExitError(sys.ExitCodeEpochDeadlineExceeded), err)
+	require.Equal(t, 4, *ticks)
+	_, err = mod.ExportedFunction("nop").Call(testCtx)
+	require.Equal(t, sys.NewExitError(sys.ExitCodeEpochDeadlineExceeded), err)
+}
@@ -0,0 +1,126 @@
+package adhoc
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+)
+
+var externrefTests = map[string]testCase{
+	"go values": {f: testExternrefGoValues},
+}
+
+func TestEngineCompiler_externref(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, externrefTests, wazero.NewRuntimeConfigCompiler(), false)
+}
+
+func TestEngineInterpreter_externref(t *testing.T) {
+	runAllTests(t, externrefTests, wazero.NewRuntimeConfigInterpreter(), false)
+}
+
+func TestEngineWazevo_externref(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	runAllTests(t, externrefTests, opt.NewRuntimeConfigOptimizingCompiler(), true)
+}
+
+// externrefWasm imports "env.open" of type (i32) -> (externref) and "env.query" of type (externref) -> (i32), and
+// exports:
+//
+//   - "open" of type (i32) -> () which keeps the result of "env.open" in a global.
+//   - "set" of type (externref) -> () which sets the global.
+//   - "query" of type () -> (i32) which calls "env.query" with the global.
+func externrefWasm(t *testing.T) []byte {
+	module := &wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{wasm.ValueTypeExternref}},
+			{Params: []wasm.ValueType{wasm.ValueTypeExternref}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}},
+			{Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{wasm.ValueTypeExternref}},
+		},
+		ImportSection: []wasm.Import{
+			{Module: "env", Name: "open", Type: wasm.ExternTypeFunc, DescFunc: 0},
+			{Module: "env", Name: "query", Type: wasm.ExternTypeFunc, DescFunc: 1},
+		},
+		ImportFunctionCount: 2,
+		FunctionSection:     []wasm.Index{2, 3, 4},
+		GlobalSection: []wasm.Global{{
+			Type: wasm.GlobalType{ValType: wasm.ValueTypeExternref, Mutable: true},
+			Init: wasm.ConstantExpression{Opcode: wasm.OpcodeRefNull, Data: []byte{wasm.RefTypeExternref}},
+		}},
+		CodeSection: []wasm.Code{
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 0, wasm.OpcodeGlobalSet, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeGlobalGet, 0, wasm.OpcodeCall, 1, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeGlobalSet, 0, wasm.OpcodeEnd}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "open", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "query", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "set", Type: wasm.ExternTypeFunc, Index: 4},
+		},
+	}
+	require.NoError(t, module.Validate(api.CoreFeaturesV2))
+	return binaryencoding.EncodeModule(module)
+}
+
+// testConn stands in for a host object, such as a database connection.
+type testConn struct{ id uint32 }
+
+func testExternrefGoValues(t *testing.T, r wazero.Runtime) {
+	defer r.Close(testCtx)
+
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(_ context.Context, id uint32) interface{} {
+		if id == 0 {
+			return nil
+		}
+		return &testConn{id: id}
+	}).Export("open").
+		NewFunctionBuilder().WithFunc(func(_ context.Context, conn interface{}) uint32 {
+		if conn == nil {
+			return 0
+		}
+		return conn.(*testConn).id * 10
+	}).Export("query").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	guest, err := r.Instantiate(testCtx, externrefWasm(t))
+	require.NoError(t, err)
+
+	for _, id := range []uint64{0, 4, 2} {
+		_, err = guest.ExportedFunction("open").Call(testCtx, id)
+		require.NoError(t, err)
+		res, err := guest.ExportedFunction("query").Call(testCtx)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{id * 10}, res)
+	}
+	require.Equal(t, 2, r.Externrefs().Len())
+
+	// Converting explicitly is the same as the host functions do.
+	ref := r.Externrefs().Ref(&testConn{id: 3})
+	_, err = guest.ExportedFunction("set").Call(testCtx, ref)
+	require.NoError(t, err)
+	res, err := guest.ExportedFunction("query").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{30}, res)
+
+	// A released externref is nil for the host.
+	r.Externrefs().Release(ref)
+	res, err = guest.ExportedFunction("query").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{0}, res)
+}
@@ -0,0 +1,174 @@
+package adhoc
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+	"github.com/AR1011/wazero/sys"
+)
+
+var fuelTests = map[string]testCase{
+	"fuel consumption":           {f: testFuelConsumption},
+	"fuel exhausted":             {f: testFuelExhausted},
+	"fuel exhausted on start":    {f: testFuelExhaustedOnStart},
+	"fuel not consumed by hosts": {f: testFuelHostFunction},
+	"fuel not inherited":         {f: testFuelNestedInstantiation},
+}
+
+func TestEngineCompiler_fuel(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, fuelTests, wazero.NewRuntimeConfigCompiler().WithFuelMetering(true), false)
+}
+
+func TestEngineInterpreter_fuel(t *testing.T) {
+	runAllTests(t, fuelTests, wazero.NewRuntimeConfigInterpreter().WithFuelMetering(true), false)
+}
+
+func TestEngineWazevo_fuel(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	runAllTests(t, fuelTests, opt.NewRuntimeConfigOptimizingCompiler().WithFuelMetering(true), true)
+}
+
+// fuelWasm exports the following functions, and consumes one unit of fuel with the empty start function:
+//
+//   - "loop" of type (i32) -> () which iterates the loop the given number of times.
+//   - "call" of type (i32) -> () which calls "loop" with the given parameter.
+//   - "host" of type (i32) -> () which calls the imported "env.host" with the given parameter.
+func fuelWasm(t *testing.T) []byte {
+	module := &wasm.Module{
+		TypeSection:         []wasm.FunctionType{{Params: []wasm.ValueType{i32}}, {}},
+		ImportSection:       []wasm.Import{{Module: "env", Name: "host", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+		ImportFunctionCount: 1,
+		FunctionSection:     []wasm.Index{0, 0, 0, 1},
+		CodeSection: []wasm.Code{
+			{Body: []byte{
+				wasm.OpcodeLoop, 0x40, // empty block type.
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Sub,
+				wasm.OpcodeLocalTee, 0,
+				wasm.OpcodeBrIf, 0,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 1, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeCall, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeEnd}},
+		},
+		StartSection: func() *wasm.Index { idx := wasm.Index(4); return &idx }(),
+		ExportSection: []wasm.Export{
+			{Name: "loop", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "call", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "host", Type: wasm.ExternTypeFunc, Index: 3},
+		},
+	}
+	require.NoError(t, module.Validate(api.CoreFeaturesV2))
+	return binaryencoding.EncodeModule(module)
+}
+
+// instantiateFuelModule instantiates fuelWasm with the given initial fuel, and the host function which
+// calls "loop" of the guest module.
+func instantiateFuelModule(t *testing.T, r wazero.Runtime, fuel uint64) (api.Module, error) {
+	_, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(ctx context.Context, m api.Module, n uint32) {
+		_, err := m.ExportedFunction("loop").Call(ctx, uint64(n))
+		require.NoError(t, err)
+	}).Export("host").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+	return r.InstantiateWithConfig(testCtx, fuelWasm(t), wazero.NewModuleConfig().WithFuel(fuel))
+}
+
+func testFuelConsumption(t *testing.T, r wazero.Runtime) {
+	mod, err := instantiateFuelModule(t, r, 100)
+	require.NoError(t, err)
+	// The start function consumes one unit on entry.
+	require.Equal(t, uint64(99), mod.RemainingFuel())
+
+	// One unit on entry, and one per iteration.
+	_, err = mod.ExportedFunction("loop").Call(testCtx, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(88), mod.RemainingFuel())
+
+	// One unit more for the caller.
+	_, err = mod.ExportedFunction("call").Call(testCtx, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(76), mod.RemainingFuel())
+
+	mod.SetFuel(5)
+	require.Equal(t, uint64(5), mod.RemainingFuel())
+	mod.AddFuel(10)
+	require.Equal(t, uint64(15), mod.RemainingFuel())
+}
+
+func testFuelExhausted(t *testing.T, r wazero.Runtime) {
+	mod, err := instantiateFuelModule(t, r, 6)
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("loop").Call(testCtx, 10)
+	require.Equal(t, sys.NewExitError(sys.ExitCodeFuelExhausted), err)
+	require.EqualError(t, err, "module ran out of fuel")
+	require.Equal(t, uint64(0), mod.RemainingFuel())
+
+	// The module is not closed, so the call can be retried after adding fuel.
+	require.False(t, mod.IsClosed())
+	mod.AddFuel(20)
+	_, err = mod.ExportedFunction("loop").Call(testCtx, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), mod.RemainingFuel())
+}
+
+func testFuelExhaustedOnStart(t *testing.T, r wazero.Runtime) {
+	_, err := instantiateFuelModule(t, r, 0)
+	require.Equal(t, sys.NewExitError(sys.ExitCodeFuelExhausted), err)
+}
+
+func testFuelHostFunction(t *testing.T, r wazero.Runtime) {
+	mod, err := instantiateFuelModule(t, r, 100)
+	require.NoError(t, err)
+
+	// One unit for "host", nothing for "env.host", and 11 units for "loop" called by the host.
+	_, err = mod.ExportedFunction("host").Call(testCtx, 10)
+	require.NoError(t, err)
+	require.Equal(t, uint64(87), mod.RemainingFuel())
+}
+
+// testFuelNestedInstantiation ensures a module instantiated by a host function called from the start function of
+// another module doesn't inherit the initial fuel of the caller.
+func testFuelNestedInstantiation(t *testing.T, r wazero.Runtime) {
+	var nested api.Module
+	_, err := r.NewHostModuleBuilder("nest").NewFunctionBuilder().WithFunc(func(ctx context.Context) {
+		var err error
+		nested, err = r.InstantiateWithConfig(ctx, binaryencoding.EncodeModule(&wasm.Module{}),
+			wazero.NewModuleConfig().WithName("nested"))
+		require.NoError(t, err)
+	}).Export("instantiate").Instantiate(testCtx)
+	require.NoError(t, err)
+
+	start := wasm.Index(1)
+	caller := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{{}},
+		ImportSection:   []wasm.Import{{Type: wasm.ExternTypeFunc, Module: "nest", Name: "instantiate", DescFunc: 0}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}}},
+		StartSection:    &start,
+	}
+	mod, err := r.InstantiateWithConfig(testCtx, binaryencoding.EncodeModule(caller),
+		wazero.NewModuleConfig().WithName("caller").WithFuel(100))
+	require.NoError(t, err)
+	require.Equal(t, uint64(99), mod.RemainingFuel())
+	require.NotNil(t, nested)
+	require.Equal(t, uint64(0), nested.RemainingFuel())
+}
@@ -33,7 +33,7 @@ func TestEngineInterpreter_hammer(t *testing.T) {
 }
 
 func TestEngineWazevo_hammer(t *testing.T) {
-	if runtime.GOARCH != "arm64" {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
 		t.Skip()
 	}
 	c := opt.NewRuntimeConfigOptimizingCompiler()
@@ -0,0 +1,156 @@
+package adhoc
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+)
+
+var hostExportsTests = map[string]testCase{
+	"host memory":  {f: testHostMemory},
+	"host globals": {f: testHostGlobals},
+}
+
+func TestEngineCompiler_hostExports(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, hostExportsTests, wazero.NewRuntimeConfigCompiler(), false)
+}
+
+func TestEngineInterpreter_hostExports(t *testing.T) {
+	runAllTests(t, hostExportsTests, wazero.NewRuntimeConfigInterpreter(), false)
+}
+
+func TestEngineWazevo_hostExports(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	runAllTests(t, hostExportsTests, opt.NewRuntimeConfigOptimizingCompiler(), true)
+}
+
+// hostExportsWasm imports the memory "env.memory", the mutable i64 global "env.sp" and the i32 global "env.flag",
+// and exports:
+//
+//   - "load" of type (i32) -> (i32) which loads the i32 at the given offset of the memory.
+//   - "store" of type (i32, i32) -> () which stores the second parameter at the offset given by the first.
+//   - "get_sp" of type () -> (i64) which returns "env.sp".
+//   - "set_sp" of type (i64) -> () which sets "env.sp".
+//   - "flag" of type () -> (i32) which returns "env.flag".
+func hostExportsWasm(t *testing.T) []byte {
+	module := &wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32}},
+			{Results: []wasm.ValueType{i64}},
+			{Params: []wasm.ValueType{i64}},
+			{Results: []wasm.ValueType{i32}},
+		},
+		ImportSection: []wasm.Import{
+			{Module: "env", Name: "memory", Type: wasm.ExternTypeMemory, DescMem: &wasm.Memory{Min: 1}},
+			{Module: "env", Name: "sp", Type: wasm.ExternTypeGlobal, DescGlobal: wasm.GlobalType{ValType: i64, Mutable: true}},
+			{Module: "env", Name: "flag", Type: wasm.ExternTypeGlobal, DescGlobal: wasm.GlobalType{ValType: i32}},
+		},
+		ImportMemoryCount: 1,
+		ImportGlobalCount: 2,
+		FunctionSection:   []wasm.Index{0, 1, 2, 3, 4},
+		CodeSection: []wasm.Code{
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Load, 0x2, 0x0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Store, 0x2, 0x0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeGlobalGet, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeGlobalSet, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeGlobalGet, 1, wasm.OpcodeEnd}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "load", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "store", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "get_sp", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "set_sp", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "flag", Type: wasm.ExternTypeFunc, Index: 4},
+		},
+	}
+	require.NoError(t, module.Validate(api.CoreFeaturesV2))
+	return binaryencoding.EncodeModule(module)
+}
+
+func instantiateHostExportsGuests(t *testing.T, r wazero.Runtime) (env, guest1, guest2 api.Module) {
+	env, err := r.NewHostModuleBuilder("env").
+		ExportMemoryWithMax("memory", 1, 2).
+		ExportMutableGlobal("sp", api.ValueTypeI64, 1024).
+		ExportGlobal("flag", api.ValueTypeI32, api.EncodeI32(1)).
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	compiled, err := r.CompileModule(testCtx, hostExportsWasm(t))
+	require.NoError(t, err)
+	guest1, err = r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig().WithName("guest1"))
+	require.NoError(t, err)
+	guest2, err = r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig().WithName("guest2"))
+	require.NoError(t, err)
+	return
+}
+
+func testHostMemory(t *testing.T, r wazero.Runtime) {
+	defer r.Close(testCtx)
+	env, guest1, guest2 := instantiateHostExportsGuests(t, r)
+
+	mem := env.ExportedMemory("memory")
+	require.Equal(t, []string{"memory"}, mem.Definition().ExportNames())
+	max, ok := mem.Definition().Max()
+	require.True(t, ok)
+	require.Equal(t, uint32(2), max)
+
+	// The host writes, and both guests read the same memory.
+	require.True(t, mem.WriteUint32Le(8, 42))
+	for _, guest := range []api.Module{guest1, guest2} {
+		res, err := guest.ExportedFunction("load").Call(testCtx, 8)
+		require.NoError(t, err)
+		require.Equal(t, []uint64{42}, res)
+	}
+
+	// One guest writes, and the other guest and the host read.
+	_, err := guest1.ExportedFunction("store").Call(testCtx, 16, 7)
+	require.NoError(t, err)
+	res, err := guest2.ExportedFunction("load").Call(testCtx, 16)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{7}, res)
+	v, ok := mem.ReadUint32Le(16)
+	require.True(t, ok)
+	require.Equal(t, uint32(7), v)
+}
+
+func testHostGlobals(t *testing.T, r wazero.Runtime) {
+	defer r.Close(testCtx)
+	env, guest1, guest2 := instantiateHostExportsGuests(t, r)
+
+	res, err := guest1.ExportedFunction("flag").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1}, res)
+
+	sp := env.ExportedGlobal("sp").(api.MutableGlobal)
+	require.Equal(t, uint64(1024), sp.Get())
+	require.Nil(t, env.ExportedGlobal("sp_missing"))
+	_, isMutable := env.ExportedGlobal("flag").(api.MutableGlobal)
+	require.False(t, isMutable)
+
+	// A guest sets the global, and the host and the other guest see it.
+	_, err = guest1.ExportedFunction("set_sp").Call(testCtx, 2048)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2048), sp.Get())
+	res, err = guest2.ExportedFunction("get_sp").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2048}, res)
+
+	// The host sets the global, and the guests see it.
+	sp.Set(4096)
+	res, err = guest1.ExportedFunction("get_sp").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{4096}, res)
+}
@@ -0,0 +1,223 @@
+package adhoc
+
+import (
+	"math"
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+	"github.com/AR1011/wazero/internal/wasmruntime"
+)
+
+var memory64Tests = map[string]testCase{
+	// wazevo doesn't support 64-bit memories yet.
+	"load and store":           {f: testMemory64LoadStore, wazevoSkip: true},
+	"out of bounds":            {f: testMemory64OutOfBounds, wazevoSkip: true},
+	"size and grow":            {f: testMemory64SizeGrow, wazevoSkip: true},
+	"copy fill and init":       {f: testMemory64Bulk, wazevoSkip: true},
+	"rejected without feature": {f: testMemory64Disabled},
+}
+
+const memory64Features = api.CoreFeaturesV2 | api.CoreFeatureMemory64
+
+func TestEngineCompiler_memory64(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, memory64Tests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(memory64Features), false)
+}
+
+func TestEngineInterpreter_memory64(t *testing.T) {
+	runAllTests(t, memory64Tests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(memory64Features), false)
+}
+
+func TestEngineWazevo_memory64(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	c := opt.NewRuntimeConfigOptimizingCompiler().WithCoreFeatures(memory64Features)
+	runAllTests(t, memory64Tests, c, true)
+}
+
+// memory64Wasm defines a 64-bit memory exported as "mem" which is initialized with "hello" at offset 8, and exports
+// functions accessing it with i64 addresses.
+func memory64Wasm(t *testing.T) []byte {
+	// memArg encodes the memory argument of a load or store with the 64-bit offset.
+	memArg := func(align byte, offset uint64) []byte {
+		return append([]byte{align}, leb128.EncodeUint64(offset)...)
+	}
+	bulk := func(instruction ...byte) []byte {
+		body := []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2, wasm.OpcodeMiscPrefix}
+		body = append(body, instruction...)
+		return append(body, wasm.OpcodeEnd)
+	}
+
+	module := &wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i64}},
+			{Params: []wasm.ValueType{i64, i64}},
+			{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i32}},
+			{Results: []wasm.ValueType{i64}},
+			{Params: []wasm.ValueType{i64, i32, i64}},
+			{Params: []wasm.ValueType{i64, i64, i64}},
+			{Params: []wasm.ValueType{i64, i32, i32}},
+		},
+		FunctionSection: []wasm.Index{0, 1, 2, 3, 0, 4, 5, 6},
+		CodeSection: []wasm.Code{
+			{Body: append(append([]byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI64Load}, memArg(3, 0)...), wasm.OpcodeEnd)},
+			{Body: append(append([]byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI64Store}, memArg(3, 0)...), wasm.OpcodeEnd)},
+			// load_above_4gib(addr) reads a byte at addr+4GiB.
+			{Body: append(append([]byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Load8U}, memArg(0, 1<<32)...), wasm.OpcodeEnd)},
+			{Body: []byte{wasm.OpcodeMemorySize, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeEnd}},
+			// fill(offset, value, size)
+			{Body: bulk(wasm.OpcodeMiscMemoryFill, 0)},
+			// copy(dst, src, size)
+			{Body: bulk(wasm.OpcodeMiscMemoryCopy, 0, 0)},
+			// init(offset, data offset, size) from the passive data segment.
+			{Body: bulk(wasm.OpcodeMiscMemoryInit, 1, 0)},
+		},
+		MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: 3, IsMaxEncoded: true, IsMemory64: true}},
+		DataSection: []wasm.DataSegment{
+			{
+				OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: leb128.EncodeInt64(8)},
+				Init:             []byte("hello"),
+			},
+			{Passive: true, Init: []byte("world")},
+		},
+		DataCountSection: &[]uint32{2}[0],
+		ExportSection: []wasm.Export{
+			{Name: "mem", Type: wasm.ExternTypeMemory, Index: 0},
+			{Name: "load", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "store", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "load_above_4gib", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "size", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "grow", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "fill", Type: wasm.ExternTypeFunc, Index: 5},
+			{Name: "copy", Type: wasm.ExternTypeFunc, Index: 6},
+			{Name: "init", Type: wasm.ExternTypeFunc, Index: 7},
+		},
+	}
+	require.NoError(t, module.Validate(memory64Features))
+	return binaryencoding.EncodeModule(module)
+}
+
+func testMemory64LoadStore(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, memory64Wasm(t))
+	require.NoError(t, err)
+
+	mem := mod.ExportedMemory("mem")
+	require.NotNil(t, mem)
+
+	// The active data segment is applied at the i64 offset.
+	buf, ok := mem.Read64(8, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(buf))
+
+	_, err = mod.ExportedFunction("store").Call(testCtx, 16, 0xdeadbeef_cafebabe)
+	require.NoError(t, err)
+	res, err := mod.ExportedFunction("load").Call(testCtx, 16)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0xdeadbeef_cafebabe), res[0])
+
+	v, ok := mem.ReadUint64Le64(16)
+	require.True(t, ok)
+	require.Equal(t, uint64(0xdeadbeef_cafebabe), v)
+
+	require.True(t, mem.WriteUint64Le64(24, 42))
+	res, err = mod.ExportedFunction("load").Call(testCtx, 24)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
+
+func testMemory64OutOfBounds(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, memory64Wasm(t))
+	require.NoError(t, err)
+
+	for _, addr := range []uint64{
+		uint64(wasm.MemoryPageSize) - 7,
+		1 << 32,
+		// The effective address overflows when the access size is added.
+		math.MaxUint64 - 3,
+		math.MaxUint64,
+	} {
+		_, err = mod.ExportedFunction("load").Call(testCtx, addr)
+		require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+		_, err = mod.ExportedFunction("store").Call(testCtx, addr, 1)
+		require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	}
+
+	// The static offset above 4GiB doesn't wrap around.
+	_, err = mod.ExportedFunction("load_above_4gib").Call(testCtx, 0)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+
+	_, ok := mod.ExportedMemory("mem").ReadByte64(1 << 32)
+	require.False(t, ok)
+}
+
+func testMemory64SizeGrow(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, memory64Wasm(t))
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("grow").Call(testCtx, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	res, err = mod.ExportedFunction("size").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), res[0])
+	require.Equal(t, uint64(3*wasm.MemoryPageSize), mod.ExportedMemory("mem").Size64())
+
+	// Exceeds the maximum, which results in -1 in signed 64-bit integer.
+	res, err = mod.ExportedFunction("grow").Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(math.MaxUint64), res[0])
+	res, err = mod.ExportedFunction("grow").Call(testCtx, 1<<32)
+	require.NoError(t, err)
+	require.Equal(t, uint64(math.MaxUint64), res[0])
+}
+
+func testMemory64Bulk(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, memory64Wasm(t))
+	require.NoError(t, err)
+	mem := mod.ExportedMemory("mem")
+
+	_, err = mod.ExportedFunction("copy").Call(testCtx, 100, 8, 5)
+	require.NoError(t, err)
+	buf, ok := mem.Read64(100, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(buf))
+
+	_, err = mod.ExportedFunction("fill").Call(testCtx, 110, 'x', 3)
+	require.NoError(t, err)
+	buf, ok = mem.Read64(110, 3)
+	require.True(t, ok)
+	require.Equal(t, "xxx", string(buf))
+
+	_, err = mod.ExportedFunction("init").Call(testCtx, 120, 0, 5)
+	require.NoError(t, err)
+	buf, ok = mem.Read64(120, 5)
+	require.True(t, ok)
+	require.Equal(t, "world", string(buf))
+
+	// The ranges must not wrap around.
+	_, err = mod.ExportedFunction("copy").Call(testCtx, 0, 8, math.MaxUint64)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	_, err = mod.ExportedFunction("fill").Call(testCtx, math.MaxUint64, 'x', 2)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	_, err = mod.ExportedFunction("init").Call(testCtx, 1<<32, 0, 5)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+}
+
+func testMemory64Disabled(t *testing.T, _ wazero.Runtime) {
+	r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(api.CoreFeaturesV2))
+	_, err := r.CompileModule(testCtx, memory64Wasm(t))
+	require.Error(t, err)
+}
@@ -0,0 +1,328 @@
+package adhoc
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+	"github.com/AR1011/wazero/internal/wasmruntime"
+)
+
+var multiMemoryTests = map[string]testCase{
+	// wazevo doesn't support multiple memories yet.
+	"load and store":           {f: testMultiMemoryLoadStore, wazevoSkip: true},
+	"size and grow":            {f: testMultiMemorySizeGrow, wazevoSkip: true},
+	"copy fill and init":       {f: testMultiMemoryBulk, wazevoSkip: true},
+	"imported memory":          {f: testMultiMemoryImported, wazevoSkip: true},
+	"out of bounds":            {f: testMultiMemoryOutOfBounds, wazevoSkip: true},
+	"memory definitions":       {f: testMultiMemoryDefinitions, wazevoSkip: true},
+	"rejected without feature": {f: testMultiMemoryDisabled},
+}
+
+const multiMemoryFeatures = api.CoreFeaturesV2 | api.CoreFeatureMultiMemory
+
+func TestEngineCompiler_multiMemory(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, multiMemoryTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(multiMemoryFeatures), false)
+}
+
+func TestEngineInterpreter_multiMemory(t *testing.T) {
+	runAllTests(t, multiMemoryTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(multiMemoryFeatures), false)
+}
+
+func TestEngineWazevo_multiMemory(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	c := opt.NewRuntimeConfigOptimizingCompiler().WithCoreFeatures(multiMemoryFeatures)
+	runAllTests(t, multiMemoryTests, c, true)
+}
+
+// multiMemoryWasm defines two memories exported as "mem0" and "mem1", where the latter is initialized with "hello",
+// and exports functions whose name is suffixed by the index of the memory they access.
+func multiMemoryWasm(t *testing.T) []byte {
+	// memArg encodes the memory argument of a load or store with the explicit memory index.
+	memArg := func(align, memoryIndex byte) []byte {
+		return []byte{align | byte(wasm.MemArgMemoryIndexFlag), memoryIndex, 0}
+	}
+	load := func(memoryIndex byte) []byte {
+		body := []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Load}
+		body = append(body, memArg(2, memoryIndex)...)
+		return append(body, wasm.OpcodeEnd)
+	}
+	store := func(memoryIndex byte) []byte {
+		body := []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Store}
+		body = append(body, memArg(2, memoryIndex)...)
+		return append(body, wasm.OpcodeEnd)
+	}
+
+	module := &wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32}},
+			{Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32, i32}},
+		},
+		FunctionSection: []wasm.Index{0, 0, 1, 1, 2, 2, 0, 3, 3, 3, 3},
+		CodeSection: []wasm.Code{
+			{Body: load(0)},
+			{Body: load(1)},
+			{Body: store(0)},
+			{Body: store(1)},
+			{Body: []byte{wasm.OpcodeMemorySize, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeMemorySize, 1, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeMemoryGrow, 1, wasm.OpcodeEnd}},
+			// copy_1_to_0(dst, src, size)
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 0, 1,
+				wasm.OpcodeEnd,
+			}},
+			// copy_1_to_1(dst, src, size)
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryCopy, 1, 1,
+				wasm.OpcodeEnd,
+			}},
+			// fill_1(offset, value, size)
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryFill, 1,
+				wasm.OpcodeEnd,
+			}},
+			// init_1(offset, data offset, size) from the passive data segment.
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscMemoryInit, 1, 1,
+				wasm.OpcodeEnd,
+			}},
+		},
+		MemorySection: []wasm.Memory{
+			{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true},
+			{Min: 1, Cap: 1, Max: 3, IsMaxEncoded: true},
+		},
+		DataSection: []wasm.DataSegment{
+			{
+				OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(0)},
+				MemoryIndex:      1,
+				Init:             []byte("hello"),
+			},
+			{Passive: true, Init: []byte("world")},
+		},
+		DataCountSection: &[]uint32{2}[0],
+		ExportSection: []wasm.Export{
+			{Name: "mem0", Type: wasm.ExternTypeMemory, Index: 0},
+			{Name: "mem1", Type: wasm.ExternTypeMemory, Index: 1},
+			{Name: "load0", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "load1", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "store0", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "store1", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "size0", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "size1", Type: wasm.ExternTypeFunc, Index: 5},
+			{Name: "grow1", Type: wasm.ExternTypeFunc, Index: 6},
+			{Name: "copy_1_to_0", Type: wasm.ExternTypeFunc, Index: 7},
+			{Name: "copy_1_to_1", Type: wasm.ExternTypeFunc, Index: 8},
+			{Name: "fill_1", Type: wasm.ExternTypeFunc, Index: 9},
+			{Name: "init_1", Type: wasm.ExternTypeFunc, Index: 10},
+		},
+	}
+	require.NoError(t, module.Validate(multiMemoryFeatures))
+	return binaryencoding.EncodeModule(module)
+}
+
+func testMultiMemoryLoadStore(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, multiMemoryWasm(t))
+	require.NoError(t, err)
+
+	mem0, mem1 := mod.ExportedMemory("mem0"), mod.ExportedMemory("mem1")
+	require.NotNil(t, mem0)
+	require.NotNil(t, mem1)
+	require.Equal(t, mem0, mod.Memory())
+
+	// The active data segment is only applied to the second memory.
+	buf, ok := mem1.Read(0, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(buf))
+	buf, ok = mem0.Read(0, 5)
+	require.True(t, ok)
+	require.Equal(t, make([]byte, 5), buf)
+
+	_, err = mod.ExportedFunction("store1").Call(testCtx, 8, 0xdeadbeef)
+	require.NoError(t, err)
+	_, err = mod.ExportedFunction("store0").Call(testCtx, 8, 0xcafe)
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("load1").Call(testCtx, 8)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0xdeadbeef), res[0])
+	res, err = mod.ExportedFunction("load0").Call(testCtx, 8)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0xcafe), res[0])
+
+	// Writes by the host to the second memory are visible to the guest, without touching the first one.
+	require.True(t, mem1.WriteUint32Le(16, 42))
+	res, err = mod.ExportedFunction("load1").Call(testCtx, 16)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+	res, err = mod.ExportedFunction("load0").Call(testCtx, 16)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+}
+
+func testMultiMemorySizeGrow(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, multiMemoryWasm(t))
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("grow1").Call(testCtx, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	res, err = mod.ExportedFunction("size1").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), res[0])
+	res, err = mod.ExportedFunction("size0").Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+	require.Equal(t, uint32(3*wasm.MemoryPageSize), mod.ExportedMemory("mem1").Size())
+	require.Equal(t, uint32(wasm.MemoryPageSize), mod.ExportedMemory("mem0").Size())
+
+	// The grown region of the second memory is accessible, but not the same offset of the first one.
+	_, err = mod.ExportedFunction("store1").Call(testCtx, 2*uint64(wasm.MemoryPageSize), 1)
+	require.NoError(t, err)
+	_, err = mod.ExportedFunction("store0").Call(testCtx, 2*uint64(wasm.MemoryPageSize), 1)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+
+	// Exceeds the maximum of the second memory.
+	res, err = mod.ExportedFunction("grow1").Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0xffffffff), res[0])
+}
+
+func testMultiMemoryBulk(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, multiMemoryWasm(t))
+	require.NoError(t, err)
+	mem0, mem1 := mod.ExportedMemory("mem0"), mod.ExportedMemory("mem1")
+
+	_, err = mod.ExportedFunction("copy_1_to_0").Call(testCtx, 100, 0, 5)
+	require.NoError(t, err)
+	buf, ok := mem0.Read(100, 5)
+	require.True(t, ok)
+	require.Equal(t, "hello", string(buf))
+
+	_, err = mod.ExportedFunction("copy_1_to_1").Call(testCtx, 1, 0, 5)
+	require.NoError(t, err)
+	buf, ok = mem1.Read(0, 6)
+	require.True(t, ok)
+	require.Equal(t, "hhello", string(buf))
+
+	_, err = mod.ExportedFunction("fill_1").Call(testCtx, 10, 'x', 3)
+	require.NoError(t, err)
+	buf, ok = mem1.Read(10, 3)
+	require.True(t, ok)
+	require.Equal(t, "xxx", string(buf))
+
+	_, err = mod.ExportedFunction("init_1").Call(testCtx, 20, 0, 5)
+	require.NoError(t, err)
+	buf, ok = mem1.Read(20, 5)
+	require.True(t, ok)
+	require.Equal(t, "world", string(buf))
+
+	// None of the above touched the first memory except the copy.
+	buf, ok = mem0.Read(0, 30)
+	require.True(t, ok)
+	require.Equal(t, make([]byte, 30), buf)
+}
+
+func testMultiMemoryOutOfBounds(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, multiMemoryWasm(t))
+	require.NoError(t, err)
+
+	// Grow the second memory so that the bounds differ between the source and the destination.
+	_, err = mod.ExportedFunction("grow1").Call(testCtx, 1)
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("copy_1_to_0").Call(testCtx, 0, uint64(wasm.MemoryPageSize), 8)
+	require.NoError(t, err)
+	_, err = mod.ExportedFunction("copy_1_to_0").Call(testCtx, uint64(wasm.MemoryPageSize), 0, 8)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+	_, err = mod.ExportedFunction("load1").Call(testCtx, 2*uint64(wasm.MemoryPageSize))
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+}
+
+// testMultiMemoryImported ensures the memory index space begins with the imported memories.
+func testMultiMemoryImported(t *testing.T, r wazero.Runtime) {
+	_, err := r.InstantiateWithConfig(testCtx, multiMemoryWasm(t), wazero.NewModuleConfig().WithName("multi"))
+	require.NoError(t, err)
+
+	module := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0, 0},
+		CodeSection: []wasm.Code{
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Load8U, byte(wasm.MemArgMemoryIndexFlag), 0, 0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Load8U, byte(wasm.MemArgMemoryIndexFlag), 1, 0,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ImportSection: []wasm.Import{{
+			Module: "multi", Name: "mem1", Type: wasm.ExternTypeMemory,
+			DescMem: &wasm.Memory{Min: 1, Max: 3, IsMaxEncoded: true},
+		}},
+		ImportMemoryCount: 1,
+		MemorySection:     []wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}},
+		DataSection: []wasm.DataSegment{{
+			OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(0)},
+			MemoryIndex:      1,
+			Init:             []byte("own"),
+		}},
+		ExportSection: []wasm.Export{
+			{Name: "load_imported", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "load_own", Type: wasm.ExternTypeFunc, Index: 1},
+		},
+	}
+	require.NoError(t, module.Validate(multiMemoryFeatures))
+
+	mod, err := r.Instantiate(testCtx, binaryencoding.EncodeModule(module))
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("load_imported").Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64('h'), res[0])
+	res, err = mod.ExportedFunction("load_own").Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64('o'), res[0])
+}
+
+func testMultiMemoryDefinitions(t *testing.T, r wazero.Runtime) {
+	compiled, err := r.CompileModule(testCtx, multiMemoryWasm(t))
+	require.NoError(t, err)
+
+	exported := compiled.ExportedMemories()
+	require.Equal(t, 2, len(exported))
+	require.Equal(t, uint32(0), exported["mem0"].Index())
+	require.Equal(t, uint32(1), exported["mem1"].Index())
+	max, ok := exported["mem1"].Max()
+	require.True(t, ok)
+	require.Equal(t, uint32(3), max)
+	require.Nil(t, compiled.ImportedMemories())
+}
+
+func testMultiMemoryDisabled(t *testing.T, _ wazero.Runtime) {
+	r := wazero.NewRuntimeWithConfig(testCtx, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(api.CoreFeaturesV2))
+	_, err := r.CompileModule(testCtx, multiMemoryWasm(t))
+	require.Error(t, err)
+}
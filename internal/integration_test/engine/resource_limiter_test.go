@@ -0,0 +1,202 @@
+package adhoc
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+)
+
+var resourceLimiterTests = map[string]testCase{
+	"memory growth":           {f: testResourceLimiterMemory},
+	"table growth":            {f: testResourceLimiterTable},
+	"memory growth from host": {f: testResourceLimiterHost},
+	"nested instantiation":    {f: testResourceLimiterNested},
+}
+
+func TestEngineCompiler_resourceLimiter(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, resourceLimiterTests, wazero.NewRuntimeConfigCompiler(), false)
+}
+
+func TestEngineInterpreter_resourceLimiter(t *testing.T) {
+	runAllTests(t, resourceLimiterTests, wazero.NewRuntimeConfigInterpreter(), false)
+}
+
+func TestEngineWazevo_resourceLimiter(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	runAllTests(t, resourceLimiterTests, opt.NewRuntimeConfigOptimizingCompiler(), true)
+}
+
+// resourceLimiterWasm has a memory of one page and a funcref table of one element, both up to ten, and exports:
+//
+//   - "grow_memory" of type (i32) -> (i32) which grows the memory by the given number of pages.
+//   - "grow_table" of type (i32) -> (i32) which grows the table by the given number of elements.
+//   - "memory_size" of type () -> (i32) which returns the memory size in pages.
+//   - "table_size" of type () -> (i32) which returns the table size.
+func resourceLimiterWasm(t *testing.T) []byte {
+	ten := uint32(10)
+	module := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}}, {Results: []wasm.ValueType{i32}}},
+		FunctionSection: []wasm.Index{0, 0, 1, 1},
+		CodeSection: []wasm.Code{
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeMemoryGrow, 0, wasm.OpcodeEnd}},
+			{Body: []byte{
+				wasm.OpcodeRefNull, wasm.RefTypeFuncref,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeMiscPrefix, wasm.OpcodeMiscTableGrow, 0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{wasm.OpcodeMemorySize, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeMiscPrefix, wasm.OpcodeMiscTableSize, 0, wasm.OpcodeEnd}},
+		},
+		MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: 10, IsMaxEncoded: true}},
+		TableSection:  []wasm.Table{{Min: 1, Max: &ten, Type: wasm.RefTypeFuncref}},
+		ExportSection: []wasm.Export{
+			{Name: "grow_memory", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "grow_table", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "memory_size", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "table_size", Type: wasm.ExternTypeFunc, Index: 3},
+		},
+	}
+	require.NoError(t, module.Validate(api.CoreFeaturesV2))
+	return binaryencoding.EncodeModule(module)
+}
+
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// quotaLimiter allows growing up to three pages or elements, denies growing up to seven, and traps beyond.
+type quotaLimiter struct {
+	mux   sync.Mutex
+	calls [][2]uint32
+}
+
+func (l *quotaLimiter) MemoryGrowing(current, desired uint32) (bool, error) {
+	return l.growing(current, desired)
+}
+
+func (l *quotaLimiter) TableGrowing(current, desired uint32) (bool, error) {
+	return l.growing(current, desired)
+}
+
+func (l *quotaLimiter) growing(current, desired uint32) (bool, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.calls = append(l.calls, [2]uint32{current, desired})
+	if desired > 7 {
+		return false, errQuotaExceeded
+	}
+	return desired <= 3, nil
+}
+
+func instantiateResourceLimiterModule(t *testing.T, r wazero.Runtime, limiter wazero.ResourceLimiter) api.Module {
+	mod, err := r.InstantiateWithConfig(testCtx, resourceLimiterWasm(t), wazero.NewModuleConfig().WithResourceLimiter(limiter))
+	require.NoError(t, err)
+	return mod
+}
+
+func testResourceLimiterMemory(t *testing.T, r wazero.Runtime) {
+	defer r.Close(testCtx)
+	limiter := &quotaLimiter{}
+	mod := instantiateResourceLimiterModule(t, r, limiter)
+	testResourceLimiterGrow(t, mod, "grow_memory", "memory_size")
+	require.Equal(t, [][2]uint32{{1, 2}, {2, 3}, {3, 4}, {3, 8}}, limiter.calls)
+}
+
+func testResourceLimiterTable(t *testing.T, r wazero.Runtime) {
+	defer r.Close(testCtx)
+	limiter := &quotaLimiter{}
+	mod := instantiateResourceLimiterModule(t, r, limiter)
+	testResourceLimiterGrow(t, mod, "grow_table", "table_size")
+	require.Equal(t, [][2]uint32{{1, 2}, {2, 3}, {3, 4}, {3, 8}}, limiter.calls)
+}
+
+func testResourceLimiterGrow(t *testing.T, mod api.Module, grow, size string) {
+	// Allowed.
+	for _, exp := range []uint64{1, 2} {
+		res, err := mod.ExportedFunction(grow).Call(testCtx, 1)
+		require.NoError(t, err)
+		require.Equal(t, exp, res[0])
+	}
+
+	// Denied: returns -1.
+	res, err := mod.ExportedFunction(grow).Call(testCtx, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0xffffffff), uint32(res[0]))
+
+	// Beyond the declared maximum: the limiter isn't consulted.
+	res, err = mod.ExportedFunction(grow).Call(testCtx, 100)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0xffffffff), uint32(res[0]))
+
+	// Traps with the error of the limiter.
+	_, err = mod.ExportedFunction(grow).Call(testCtx, 5)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), errQuotaExceeded.Error())
+
+	res, err = mod.ExportedFunction(size).Call(testCtx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), res[0])
+}
+
+func testResourceLimiterHost(t *testing.T, r wazero.Runtime) {
+	defer r.Close(testCtx)
+	limiter := &quotaLimiter{}
+	mod := instantiateResourceLimiterModule(t, r, limiter)
+
+	_, ok := mod.Memory().Grow(2)
+	require.True(t, ok)
+	_, ok = mod.Memory().Grow(1)
+	require.False(t, ok)
+	require.Equal(t, uint32(3), mod.Memory().Size()/wasm.MemoryPageSize)
+	require.Equal(t, [][2]uint32{{1, 3}, {3, 4}}, limiter.calls)
+}
+
+// testResourceLimiterNested ensures a module instantiated by a host function called from the start function of a
+// limited module isn't limited by the limiter of the caller.
+func testResourceLimiterNested(t *testing.T, r wazero.Runtime) {
+	defer r.Close(testCtx)
+	bin := resourceLimiterWasm(t)
+
+	var nested api.Module
+	_, err := r.NewHostModuleBuilder("env").NewFunctionBuilder().WithFunc(func(ctx context.Context) {
+		var err error
+		nested, err = r.InstantiateWithConfig(ctx, bin, wazero.NewModuleConfig().WithName("nested"))
+		require.NoError(t, err)
+	}).Export("instantiate").Instantiate(testCtx)
+	require.NoError(t, err)
+
+	start := wasm.Index(1)
+	caller := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{{}},
+		ImportSection:   []wasm.Import{{Type: wasm.ExternTypeFunc, Module: "env", Name: "instantiate", DescFunc: 0}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeCall, 0, wasm.OpcodeEnd}}},
+		StartSection:    &start,
+	}
+	limiter := &quotaLimiter{}
+	_, err = r.InstantiateWithConfig(testCtx, binaryencoding.EncodeModule(caller),
+		wazero.NewModuleConfig().WithName("caller").WithResourceLimiter(limiter))
+	require.NoError(t, err)
+	require.NotNil(t, nested)
+
+	_, ok := nested.Memory().Grow(5)
+	require.True(t, ok)
+	res, err := nested.ExportedFunction("grow_table").Call(testCtx, 5)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+	require.Nil(t, limiter.calls)
+}
@@ -0,0 +1,170 @@
+package adhoc
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+)
+
+var tableTests = map[string]testCase{
+	"host table":  {f: testHostTable},
+	"guest table": {f: testGuestTable},
+	// wazevo doesn't support api.Function of host modules.
+	"host function in table": {f: testHostFunctionInTable, wazevoSkip: true},
+}
+
+func TestEngineCompiler_table(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, tableTests, wazero.NewRuntimeConfigCompiler(), false)
+}
+
+func TestEngineInterpreter_table(t *testing.T) {
+	runAllTests(t, tableTests, wazero.NewRuntimeConfigInterpreter(), false)
+}
+
+func TestEngineWazevo_table(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	runAllTests(t, tableTests, opt.NewRuntimeConfigOptimizingCompiler(), true)
+}
+
+// tableWasm has a table of funcref, imported from "env.callbacks" if imported is true, and exports:
+//
+//   - "call" of type (i32, i32) -> (i32) which calls the function of type (i32) -> (i32) at the index of the table
+//     given by the first parameter, with the second parameter.
+//   - "double" of type (i32) -> (i32) which doubles the parameter.
+//   - "table" which is the table.
+func tableWasm(t *testing.T, imported bool) []byte {
+	module := &wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+		},
+		FunctionSection: []wasm.Index{1, 0},
+		CodeSection: []wasm.Code{
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeCallIndirect, 0, 0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 0, wasm.OpcodeI32Add, wasm.OpcodeEnd}},
+		},
+		ExportSection: []wasm.Export{
+			{Name: "call", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "double", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "table", Type: wasm.ExternTypeTable, Index: 0},
+		},
+	}
+	if imported {
+		module.ImportSection = []wasm.Import{{Module: "env", Name: "callbacks", Type: wasm.ExternTypeTable, DescTable: wasm.Table{Min: 2, Type: wasm.RefTypeFuncref}}}
+		module.ImportTableCount = 1
+	} else {
+		module.TableSection = []wasm.Table{{Min: 2, Type: wasm.RefTypeFuncref}}
+	}
+	require.NoError(t, module.Validate(api.CoreFeaturesV2))
+	return binaryencoding.EncodeModule(module)
+}
+
+func testHostTable(t *testing.T, r wazero.Runtime) {
+	defer r.Close(testCtx)
+
+	env, err := r.NewHostModuleBuilder("env").
+		ExportTableWithMax("callbacks", api.ValueTypeFuncref, 2, 3).
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	compiled, err := r.CompileModule(testCtx, tableWasm(t, true))
+	require.NoError(t, err)
+	imports := compiled.ImportedTables()
+	require.Equal(t, 1, len(imports))
+	moduleName, name, isImport := imports[0].Import()
+	require.Equal(t, [3]interface{}{"env", "callbacks", true}, [3]interface{}{moduleName, name, isImport})
+	require.Equal(t, api.ValueTypeFuncref, imports[0].RefType())
+
+	guest, err := r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	table := env.ExportedTable("callbacks")
+	require.Equal(t, uint32(2), table.Size())
+	max, ok := table.Definition().Max()
+	require.True(t, ok)
+	require.Equal(t, uint32(3), max)
+
+	require.True(t, table.SetFunction(0, guest.ExportedFunction("double")))
+	requireCall(t, guest, 0, 21, 42)
+
+	// A function reference can be read, but only written with SetFunction.
+	ref, ok := table.Get(0)
+	require.True(t, ok)
+	require.NotEqual(t, uint64(0), ref)
+	require.False(t, table.Set(1, ref))
+	_, ok = table.Grow(1, ref)
+	require.False(t, ok)
+	prev, ok := table.Grow(1, 0)
+	require.True(t, ok)
+	require.Equal(t, uint32(2), prev)
+	require.True(t, table.SetFunction(2, guest.ExportedFunction("double")))
+	requireCall(t, guest, 2, 5, 10)
+	_, ok = table.Grow(1, 0)
+	require.False(t, ok)
+
+	// The table is shared with the guest.
+	require.Equal(t, uint32(3), guest.ExportedTable("table").Size())
+
+	// Calling a null reference traps.
+	require.True(t, table.Set(0, 0))
+	_, err = guest.ExportedFunction("call").Call(testCtx, 0, 1)
+	require.Error(t, err)
+}
+
+func testHostFunctionInTable(t *testing.T, r wazero.Runtime) {
+	defer r.Close(testCtx)
+
+	env, err := r.NewHostModuleBuilder("env").
+		NewFunctionBuilder().WithFunc(func(_ context.Context, x uint32) uint32 { return x + 1 }).Export("add_one").
+		ExportTable("callbacks", api.ValueTypeFuncref, 2).
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	guest, err := r.Instantiate(testCtx, tableWasm(t, true))
+	require.NoError(t, err)
+
+	require.True(t, env.ExportedTable("callbacks").SetFunction(1, env.ExportedFunction("add_one")))
+	requireCall(t, guest, 1, 41, 42)
+}
+
+func testGuestTable(t *testing.T, r wazero.Runtime) {
+	defer r.Close(testCtx)
+
+	compiled, err := r.CompileModule(testCtx, tableWasm(t, false))
+	require.NoError(t, err)
+	require.Nil(t, compiled.ImportedTables())
+	require.Equal(t, uint32(2), compiled.ExportedTables()["table"].Min())
+
+	guest, err := r.InstantiateModule(testCtx, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+	require.Nil(t, guest.ExportedTable("call"))
+
+	table := guest.ExportedTable("table")
+	require.Equal(t, []string{"table"}, table.Definition().ExportNames())
+	require.True(t, table.SetFunction(1, guest.ExportedFunction("double")))
+	requireCall(t, guest, 1, 4, 8)
+}
+
+func requireCall(t *testing.T, guest api.Module, idx, param, expected uint64) {
+	res, err := guest.ExportedFunction("call").Call(testCtx, idx, param)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{expected}, res)
+}
@@ -0,0 +1,335 @@
+package adhoc
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+	"github.com/AR1011/wazero/internal/wasmruntime"
+)
+
+var tailCallTests = map[string]testCase{
+	// wazevo lowers tail calls as regular calls followed by return, so deep recursion exceeds the stack. See
+	// TestEngineWazevo_tailCallStackOverflow.
+	"deep tail recursion":          {f: testTailCallDeepRecursion, wazevoSkip: true},
+	"deep indirect tail recursion": {f: testTailCallIndirectDeepRecursion, wazevoSkip: true},
+	"mutual tail recursion":        {f: testTailCallMutualRecursion, wazevoSkip: true},
+	"tail call multiple results":   {f: testTailCallMultipleResults},
+	"tail call host function":      {f: testTailCallHostFunction},
+	"tail call with listener":      {f: testTailCallWithListener},
+	"tail call indirect mismatch":  {f: testTailCallIndirectTypeMismatch},
+}
+
+const tailCallFeatures = api.CoreFeaturesV2 | api.CoreFeatureTailCall
+
+func TestEngineCompiler_tailCall(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, tailCallTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(tailCallFeatures), false)
+}
+
+func TestEngineInterpreter_tailCall(t *testing.T) {
+	runAllTests(t, tailCallTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(tailCallFeatures), false)
+}
+
+func TestEngineWazevo_tailCall(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	c := opt.NewRuntimeConfigOptimizingCompiler().WithCoreFeatures(tailCallFeatures)
+	runAllTests(t, tailCallTests, c, true)
+}
+
+// TestEngineWazevo_tailCallStackOverflow documents that the optimizing compiler doesn't reuse frames on tail calls,
+// unlike the other engines which pass "deep tail recursion".
+func TestEngineWazevo_tailCallStackOverflow(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	r := wazero.NewRuntimeWithConfig(testCtx, opt.NewRuntimeConfigOptimizingCompiler().WithCoreFeatures(tailCallFeatures))
+	defer r.Close(testCtx)
+
+	for _, indirect := range []bool{false, true} {
+		mod, err := r.InstantiateWithConfig(testCtx, countWasm(t, indirect), wazero.NewModuleConfig().WithName(""))
+		require.NoError(t, err)
+
+		_, err = mod.ExportedFunction("count").Call(testCtx, tailCallDepth, 100)
+		require.ErrorIs(t, err, wasmruntime.ErrRuntimeStackOverflow)
+	}
+}
+
+// tailCallDepth is large enough to exceed the call stack ceiling of all engines unless frames are reused.
+const tailCallDepth = 10_000_000
+
+// countWasm exports the function "count" of type (i32, i64) -> i64 which adds the first parameter to the second by
+// tail recursion. When indirect is true, the recursive call is made by return_call_indirect.
+func countWasm(t *testing.T, indirect bool) []byte {
+	recursiveCall := []byte{wasm.OpcodeReturnCall, 0}
+	if indirect {
+		recursiveCall = []byte{wasm.OpcodeI32Const, 0, wasm.OpcodeReturnCallIndirect, 0, 0}
+	}
+	body := []byte{
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeI32Eqz,
+		wasm.OpcodeIf, wasm.ValueTypeI64,
+		wasm.OpcodeLocalGet, 1,
+		wasm.OpcodeElse,
+		// Set the local, so that the frame is larger than the parameters.
+		wasm.OpcodeI64Const, 1,
+		wasm.OpcodeLocalSet, 2,
+		wasm.OpcodeLocalGet, 0,
+		wasm.OpcodeI32Const, 1,
+		wasm.OpcodeI32Sub,
+		wasm.OpcodeLocalGet, 1,
+		wasm.OpcodeLocalGet, 2,
+		wasm.OpcodeI64Add,
+	}
+	body = append(body, recursiveCall...)
+	body = append(body, wasm.OpcodeEnd, wasm.OpcodeEnd)
+
+	module := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i64}, Results: []wasm.ValueType{i64}}},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []wasm.Code{{Body: body, LocalTypes: []wasm.ValueType{i64}}},
+		ExportSection:   []wasm.Export{{Name: "count", Type: wasm.ExternTypeFunc, Index: 0}},
+	}
+	if indirect {
+		module.TableSection = []wasm.Table{{Type: wasm.RefTypeFuncref, Min: 1}}
+		module.ElementSection = []wasm.ElementSegment{{
+			OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(0)},
+			Init:       []wasm.Index{0},
+			Type:       wasm.RefTypeFuncref,
+		}}
+	}
+	require.NoError(t, module.Validate(tailCallFeatures))
+	return binaryencoding.EncodeModule(module)
+}
+
+func testTailCallDeepRecursion(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, countWasm(t, false))
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("count").Call(testCtx, tailCallDepth, 100)
+	require.NoError(t, err)
+	require.Equal(t, uint64(tailCallDepth+100), res[0])
+}
+
+func testTailCallIndirectDeepRecursion(t *testing.T, r wazero.Runtime) {
+	mod, err := r.Instantiate(testCtx, countWasm(t, true))
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("count").Call(testCtx, tailCallDepth, 100)
+	require.NoError(t, err)
+	require.Equal(t, uint64(tailCallDepth+100), res[0])
+}
+
+// testTailCallMutualRecursion ensures frames are reused between functions of different parameter counts.
+func testTailCallMutualRecursion(t *testing.T, r wazero.Runtime) {
+	module := &wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i64}},
+			{Params: []wasm.ValueType{i32, i64, i64, i64, i64, i64}, Results: []wasm.ValueType{i64}},
+		},
+		FunctionSection: []wasm.Index{0, 1},
+		CodeSection: []wasm.Code{
+			// few(n) = n == 0 ? 42 : many(n - 1, 1, 2, 3, 4, 5)
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Eqz,
+				wasm.OpcodeIf, wasm.ValueTypeI64,
+				wasm.OpcodeI64Const, 42,
+				wasm.OpcodeElse,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Sub,
+				wasm.OpcodeI64Const, 1,
+				wasm.OpcodeI64Const, 2,
+				wasm.OpcodeI64Const, 3,
+				wasm.OpcodeI64Const, 4,
+				wasm.OpcodeI64Const, 5,
+				wasm.OpcodeReturnCall, 1,
+				wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			}},
+			// many(n, a, b, c, d, e) = a+b+c+d+e == 15 ? few(n) : unreachable
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeI64Add,
+				wasm.OpcodeLocalGet, 3,
+				wasm.OpcodeI64Add,
+				wasm.OpcodeLocalGet, 4,
+				wasm.OpcodeI64Add,
+				wasm.OpcodeLocalGet, 5,
+				wasm.OpcodeI64Add,
+				wasm.OpcodeI64Const, 15,
+				wasm.OpcodeI64Ne,
+				wasm.OpcodeIf, 0x40,
+				wasm.OpcodeUnreachable,
+				wasm.OpcodeEnd,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeReturnCall, 0,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []wasm.Export{{Name: "few", Type: wasm.ExternTypeFunc, Index: 0}},
+	}
+	require.NoError(t, module.Validate(tailCallFeatures))
+
+	mod, err := r.Instantiate(testCtx, binaryencoding.EncodeModule(module))
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("few").Call(testCtx, tailCallDepth)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
+
+// testTailCallMultipleResults ensures the callFrame is placed correctly when there are more results than parameters.
+func testTailCallMultipleResults(t *testing.T, r wazero.Runtime) {
+	module := &wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i64, i64, i64}},
+			{Results: []wasm.ValueType{i64, i64, i64}},
+		},
+		FunctionSection: []wasm.Index{0, 1},
+		CodeSection: []wasm.Code{
+			// f(n) = n == 0 ? g() : f(n - 1)
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Eqz,
+				wasm.OpcodeIf, 0x40,
+				wasm.OpcodeReturnCall, 1,
+				wasm.OpcodeEnd,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Sub,
+				wasm.OpcodeReturnCall, 0,
+				wasm.OpcodeEnd,
+			}},
+			// g() = (1, 2, 3)
+			{Body: []byte{
+				wasm.OpcodeI64Const, 1,
+				wasm.OpcodeI64Const, 2,
+				wasm.OpcodeI64Const, 3,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []wasm.Export{{Name: "f", Type: wasm.ExternTypeFunc, Index: 0}},
+	}
+	require.NoError(t, module.Validate(tailCallFeatures))
+
+	mod, err := r.Instantiate(testCtx, binaryencoding.EncodeModule(module))
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("f").Call(testCtx, 1000)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{1, 2, 3}, res)
+}
+
+func testTailCallHostFunction(t *testing.T, r wazero.Runtime) {
+	_, err := r.NewHostModuleBuilder("host").NewFunctionBuilder().
+		WithFunc(func(v uint32) uint64 { return uint64(v) * 2 }).
+		Export("double").
+		Instantiate(testCtx)
+	require.NoError(t, err)
+
+	module := &wasm.Module{
+		TypeSection: []wasm.FunctionType{{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i64}}},
+		ImportSection: []wasm.Import{{
+			Module: "host", Name: "double", Type: wasm.ExternTypeFunc, DescFunc: 0,
+		}},
+		ImportFunctionCount: 1,
+		FunctionSection:     []wasm.Index{0},
+		CodeSection: []wasm.Code{
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeI32Const, 1,
+				wasm.OpcodeI32Add,
+				wasm.OpcodeReturnCall, 0,
+				wasm.OpcodeEnd,
+			}},
+		},
+		ExportSection: []wasm.Export{{Name: "f", Type: wasm.ExternTypeFunc, Index: 1}},
+	}
+	require.NoError(t, module.Validate(tailCallFeatures))
+
+	mod, err := r.Instantiate(testCtx, binaryencoding.EncodeModule(module))
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("f").Call(testCtx, 20)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+}
+
+func testTailCallWithListener(t *testing.T, r wazero.Runtime) {
+	var before, after int
+	factory := experimental.FunctionListenerFactoryFunc(func(api.FunctionDefinition) experimental.FunctionListener {
+		return &tailCallListener{before: &before, after: &after}
+	})
+	ctx := context.WithValue(testCtx, experimental.FunctionListenerFactoryKey{}, factory)
+
+	compiled, err := r.CompileModule(ctx, countWasm(t, false))
+	require.NoError(t, err)
+	mod, err := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+	require.NoError(t, err)
+
+	res, err := mod.ExportedFunction("count").Call(ctx, 100, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(101), res[0])
+
+	// The listener observes each call, including the tail calls.
+	require.Equal(t, 101, before)
+	require.Equal(t, 101, after)
+}
+
+type tailCallListener struct {
+	before, after *int
+}
+
+func (l *tailCallListener) Before(context.Context, api.Module, api.FunctionDefinition, []uint64, experimental.StackIterator) {
+	*l.before++
+}
+
+func (l *tailCallListener) After(context.Context, api.Module, api.FunctionDefinition, []uint64) {
+	*l.after++
+}
+
+func (l *tailCallListener) Abort(context.Context, api.Module, api.FunctionDefinition, error) {}
+
+func testTailCallIndirectTypeMismatch(t *testing.T, r wazero.Runtime) {
+	module := &wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Results: []wasm.ValueType{i32}},
+			{Results: []wasm.ValueType{i64}},
+		},
+		FunctionSection: []wasm.Index{0, 1},
+		TableSection:    []wasm.Table{{Type: wasm.RefTypeFuncref, Min: 1}},
+		ElementSection: []wasm.ElementSegment{{
+			OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(0)},
+			Init:       []wasm.Index{1},
+			Type:       wasm.RefTypeFuncref,
+		}},
+		CodeSection: []wasm.Code{
+			{Body: []byte{wasm.OpcodeI32Const, 0, wasm.OpcodeReturnCallIndirect, 0, 0, wasm.OpcodeEnd}},
+			{Body: []byte{wasm.OpcodeI64Const, 0, wasm.OpcodeEnd}},
+		},
+		ExportSection: []wasm.Export{{Name: "f", Type: wasm.ExternTypeFunc, Index: 0}},
+	}
+	require.NoError(t, module.Validate(tailCallFeatures))
+
+	mod, err := r.Instantiate(testCtx, binaryencoding.EncodeModule(module))
+	require.NoError(t, err)
+
+	_, err = mod.ExportedFunction("f").Call(testCtx)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeIndirectCallTypeMismatch)
+}
@@ -0,0 +1,270 @@
+package adhoc
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/AR1011/wazero"
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/experimental/opt"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/hammer"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+	"github.com/AR1011/wazero/internal/wasmruntime"
+)
+
+var threadTests = map[string]testCase{
+	"shared memory between instances": {f: testSharedMemoryBetweenInstances},
+	"atomic rmw":                      {f: testAtomicRMW},
+	"atomic wait timeout":             {f: testAtomicWaitTimeout},
+	"atomic wait and notify":          {f: testAtomicWaitNotify},
+	"atomic traps":                    {f: testAtomicTraps},
+}
+
+const threadsFeatures = api.CoreFeaturesV2 | api.CoreFeatureThreads
+
+func TestEngineCompiler_threads(t *testing.T) {
+	if !platform.CompilerSupported() {
+		t.Skip()
+	}
+	runAllTests(t, threadTests, wazero.NewRuntimeConfigCompiler().WithCoreFeatures(threadsFeatures), false)
+}
+
+func TestEngineInterpreter_threads(t *testing.T) {
+	runAllTests(t, threadTests, wazero.NewRuntimeConfigInterpreter().WithCoreFeatures(threadsFeatures), false)
+}
+
+func TestEngineWazevo_threads(t *testing.T) {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
+		t.Skip()
+	}
+	c := opt.NewRuntimeConfigOptimizingCompiler().WithCoreFeatures(threadsFeatures)
+	runAllTests(t, threadTests, c, true)
+}
+
+// threadsMemoryWasm exports a shared memory of one page.
+func threadsMemoryWasm(t *testing.T) []byte {
+	module := &wasm.Module{
+		MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true, IsShared: true}},
+		ExportSection: []wasm.Export{{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0}},
+	}
+	require.NoError(t, module.Validate(threadsFeatures))
+	return binaryencoding.EncodeModule(module)
+}
+
+// threadsWorkerWasm imports the memory exported by threadsMemoryWasm, or defines a non-shared memory when
+// importedFrom is empty, and exports functions which wrap atomic instructions.
+func threadsWorkerWasm(t *testing.T, importedFrom string) []byte {
+	module := &wasm.Module{
+		TypeSection: []wasm.FunctionType{
+			{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32, i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i32, i64}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32}, Results: []wasm.ValueType{i32}},
+			{Params: []wasm.ValueType{i32, i64}, Results: []wasm.ValueType{i64}},
+		},
+		FunctionSection: []wasm.Index{0, 1, 2, 0, 3, 4},
+		ExportSection: []wasm.Export{
+			{Name: "add", Type: wasm.ExternTypeFunc, Index: 0},
+			{Name: "cmpxchg", Type: wasm.ExternTypeFunc, Index: 1},
+			{Name: "wait", Type: wasm.ExternTypeFunc, Index: 2},
+			{Name: "notify", Type: wasm.ExternTypeFunc, Index: 3},
+			{Name: "load", Type: wasm.ExternTypeFunc, Index: 4},
+			{Name: "xchg32", Type: wasm.ExternTypeFunc, Index: 5},
+		},
+		CodeSection: []wasm.Code{
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI32RmwAdd, 0x2, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI32RmwCmpxchg, 0x2, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeLocalGet, 2,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicMemoryWait32, 0x2, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicMemoryNotify, 0x2, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicFence, 0x0,
+				wasm.OpcodeLocalGet, 0,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI32Load, 0x2, 0x0,
+				wasm.OpcodeEnd,
+			}},
+			{Body: []byte{
+				wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1,
+				wasm.OpcodeAtomicPrefix, wasm.OpcodeAtomicI64Rmw32XchgU, 0x2, 0x0,
+				wasm.OpcodeEnd,
+			}},
+		},
+	}
+	if importedFrom != "" {
+		module.ImportSection = []wasm.Import{{
+			Module: importedFrom, Name: "memory", Type: wasm.ExternTypeMemory,
+			DescMem: &wasm.Memory{Min: 1, Max: 1, IsMaxEncoded: true, IsShared: true},
+		}}
+		module.ImportMemoryCount = 1
+	} else {
+		module.MemorySection = []wasm.Memory{{Min: 1, Cap: 1, Max: 1, IsMaxEncoded: true}}
+	}
+	require.NoError(t, module.Validate(threadsFeatures))
+	return binaryencoding.EncodeModule(module)
+}
+
+func instantiateThreadsWorkers(t *testing.T, r wazero.Runtime, n int) (mem api.Module, workers []api.Module) {
+	mem, err := r.InstantiateWithConfig(testCtx, threadsMemoryWasm(t), wazero.NewModuleConfig().WithName("memory"))
+	require.NoError(t, err)
+
+	bin := threadsWorkerWasm(t, "memory")
+	for i := 0; i < n; i++ {
+		worker, err := r.InstantiateWithConfig(testCtx, bin, wazero.NewModuleConfig().WithName(""))
+		require.NoError(t, err)
+		workers = append(workers, worker)
+	}
+	return
+}
+
+func testSharedMemoryBetweenInstances(t *testing.T, r wazero.Runtime) {
+	mem, workers := instantiateThreadsWorkers(t, r, 2)
+
+	_, err := workers[0].ExportedFunction("add").Call(testCtx, 8, 42)
+	require.NoError(t, err)
+
+	// The other instance sees the write, as does the exporting module.
+	res, err := workers[1].ExportedFunction("load").Call(testCtx, 8)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), res[0])
+	v, ok := mem.ExportedMemory("memory").ReadUint32Le(8)
+	require.True(t, ok)
+	require.Equal(t, uint32(42), v)
+}
+
+func testAtomicRMW(t *testing.T, r wazero.Runtime) {
+	P := 8               // max count of goroutines
+	N := 1000            // count of add calls per goroutine
+	if testing.Short() { // Adjust down if `-test.short`
+		P = 4
+		N = 100
+	}
+
+	_, workers := instantiateThreadsWorkers(t, r, P)
+
+	var next atomic.Uint32
+	hammer.NewHammer(t, P, N).Run(func(name string) {
+		// Spread the calls over all instances, which share the same memory.
+		w := workers[next.Add(1)%uint32(P)]
+		_, err := w.ExportedFunction("add").Call(testCtx, 0, 1)
+		require.NoError(t, err)
+	}, nil)
+	if t.Failed() {
+		return // At least one test failed, so return now.
+	}
+
+	res, err := workers[0].ExportedFunction("load").Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(P*N), res[0])
+
+	// Swaps only if the expected value matches, and returns the previous value either way.
+	res, err = workers[0].ExportedFunction("cmpxchg").Call(testCtx, 0, 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(P*N), res[0])
+	res, err = workers[0].ExportedFunction("cmpxchg").Call(testCtx, 0, uint64(P*N), 2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(P*N), res[0])
+	res, err = workers[0].ExportedFunction("load").Call(testCtx, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res[0])
+
+	// The 32-bit variant on i64 only uses the lower bits of the operand, and zero extends the result.
+	res, err = workers[0].ExportedFunction("xchg32").Call(testCtx, 0, 0xffffffff_00000003)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res[0])
+	res, err = workers[0].ExportedFunction("xchg32").Call(testCtx, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), res[0])
+}
+
+func testAtomicWaitTimeout(t *testing.T, r wazero.Runtime) {
+	_, workers := instantiateThreadsWorkers(t, r, 1)
+	wait := workers[0].ExportedFunction("wait")
+
+	// Not equal to the expected value.
+	res, err := wait.Call(testCtx, 0, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), res[0])
+
+	// Times out after 1ms.
+	res, err = wait.Call(testCtx, 0, 0, 1_000_000)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), res[0])
+
+	// No waiters to notify.
+	res, err = workers[0].ExportedFunction("notify").Call(testCtx, 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+}
+
+func testAtomicWaitNotify(t *testing.T, r wazero.Runtime) {
+	_, workers := instantiateThreadsWorkers(t, r, 2)
+
+	errCh := make(chan error)
+	resCh := make(chan uint64)
+	go func() {
+		// Waits forever until notified.
+		res, err := workers[0].ExportedFunction("wait").Call(testCtx, 0, 0, uint64(0xffffffffffffffff))
+		if err != nil {
+			errCh <- err
+			return
+		}
+		resCh <- res[0]
+	}()
+
+	notify := workers[1].ExportedFunction("notify")
+	for {
+		res, err := notify.Call(testCtx, 0, 1)
+		require.NoError(t, err)
+		if res[0] == 1 {
+			break
+		}
+		runtime.Gosched() // The waiter isn't yet waiting.
+	}
+
+	select {
+	case err := <-errCh:
+		t.Fatal(err)
+	case res := <-resCh:
+		require.Equal(t, uint64(0), res)
+	}
+}
+
+func testAtomicTraps(t *testing.T, r wazero.Runtime) {
+	_, workers := instantiateThreadsWorkers(t, r, 1)
+
+	_, err := workers[0].ExportedFunction("add").Call(testCtx, 1, 1)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeUnalignedAtomic)
+
+	_, err = workers[0].ExportedFunction("load").Call(testCtx, uint64(wasm.MemoryPageSize))
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeOutOfBoundsMemoryAccess)
+
+	// Waiting on a non-shared memory traps, while notify is a no-op.
+	unshared, err := r.InstantiateWithConfig(testCtx, threadsWorkerWasm(t, ""), wazero.NewModuleConfig().WithName(""))
+	require.NoError(t, err)
+
+	_, err = unshared.ExportedFunction("wait").Call(testCtx, 0, 0, 0)
+	require.ErrorIs(t, err, wasmruntime.ErrRuntimeExpectedSharedMemory)
+
+	res, err := unshared.ExportedFunction("notify").Call(testCtx, 0, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), res[0])
+}
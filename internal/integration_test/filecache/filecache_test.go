@@ -19,9 +19,9 @@ import (
 	"github.com/AR1011/wazero/internal/integration_test/spectest"
 	v1 "github.com/AR1011/wazero/internal/integration_test/spectest/v1"
 	"github.com/AR1011/wazero/internal/platform"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 func TestFileCacheSpecTest_compiler(t *testing.T) {
@@ -32,7 +32,7 @@ func TestFileCacheSpecTest_compiler(t *testing.T) {
 }
 
 func TestFileCacheSpecTest_wazevo(t *testing.T) {
-	if runtime.GOARCH != "arm64" {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
 		return
 	}
 	config := opt.NewRuntimeConfigOptimizingCompiler()
@@ -16,8 +16,8 @@ import (
 	"github.com/AR1011/wazero"
 	"github.com/AR1011/wazero/experimental/opt"
 	"github.com/AR1011/wazero/internal/leb128"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 func main() {}
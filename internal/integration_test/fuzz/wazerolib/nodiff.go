@@ -13,8 +13,8 @@ import (
 
 	"github.com/AR1011/wazero"
 	"github.com/AR1011/wazero/api"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 // require_no_diff ensures that the behavior is the same between the compiler and the interpreter for any given binary.
@@ -259,8 +259,8 @@ func ensureDummyImports(r wazero.Runtime, origin *wasm.Module, requireNoError fu
 					Type: imp.DescGlobal, Init: wasm.ConstantExpression{Opcode: opcode, Data: data},
 				})
 			case wasm.ExternTypeMemory:
-				m.MemorySection = imp.DescMem
-				index = 0
+				index = uint32(len(m.MemorySection))
+				m.MemorySection = append(m.MemorySection, *imp.DescMem)
 			case wasm.ExternTypeTable:
 				index = uint32(len(m.TableSection))
 				m.TableSection = append(m.TableSection, imp.DescTable)
@@ -12,9 +12,9 @@ import (
 	"github.com/AR1011/wazero/api"
 	"github.com/AR1011/wazero/experimental/opt"
 	"github.com/AR1011/wazero/internal/platform"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 var ctx = context.Background()
@@ -62,7 +62,7 @@ func runWithWazevo(t *testing.T, runner func(t *testing.T, r wazero.Runtime)) {
 func run(t *testing.T, runner func(t *testing.T, r wazero.Runtime)) {
 	runWithInterpreter(t, runner)
 	runWithCompiler(t, runner)
-	if runtime.GOARCH == "arm64" {
+	if runtime.GOARCH == "arm64" || runtime.GOARCH == "amd64" {
 		runWithWazevo(t, runner)
 	}
 }
@@ -380,7 +380,7 @@ func Test888(t *testing.T) {
 	// FuncRef global works fine.
 	run(t, func(t *testing.T, r wazero.Runtime) {
 		imported := binaryencoding.EncodeModule(&wasm.Module{
-			MemorySection: &wasm.Memory{Min: 0, Max: 5, IsMaxEncoded: true},
+			MemorySection: []wasm.Memory{{Min: 0, Max: 5, IsMaxEncoded: true}},
 			GlobalSection: []wasm.Global{
 				{
 					Type: wasm.GlobalType{
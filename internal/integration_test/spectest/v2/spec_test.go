@@ -26,7 +26,7 @@ func TestInterpreter(t *testing.T) {
 }
 
 func TestWazevo(t *testing.T) {
-	if runtime.GOARCH != "arm64" {
+	if runtime.GOARCH != "arm64" && runtime.GOARCH != "amd64" {
 		t.Skip()
 	}
 	c := opt.NewRuntimeConfigOptimizingCompiler().WithCoreFeatures(enabledFeatures)
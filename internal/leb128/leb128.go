@@ -130,6 +130,27 @@ func decodeUint32(next nextByte) (ret uint32, bytesRead uint64, err error) {
 	return 0, 0, errOverflow32
 }
 
+func DecodeUint64(r io.ByteReader) (ret uint64, bytesRead uint64, err error) {
+	// Derived from https://github.com/golang/go/blob/go1.20/src/encoding/binary/varint.go
+	var s uint64
+	for i := 0; i < maxVarintLen64; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, err
+		}
+		if b < 0x80 {
+			// Unused bits (non first bit) must all be zero.
+			if i == maxVarintLen64-1 && b > 1 {
+				return 0, 0, errOverflow64
+			}
+			return ret | uint64(b)<<s, uint64(i) + 1, nil
+		}
+		ret |= (uint64(b) & 0x7f) << s
+		s += 7
+	}
+	return 0, 0, errOverflow64
+}
+
 func LoadUint64(buf []byte) (ret uint64, bytesRead uint64, err error) {
 	bufLen := len(buf)
 	if bufLen == 0 {
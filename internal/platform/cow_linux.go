@@ -0,0 +1,63 @@
+//go:build linux && (amd64 || arm64)
+
+package platform
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// mfdCloexec is MFD_CLOEXEC of memfd_create, which isn't defined in the syscall package.
+const mfdCloexec = 0x1
+
+// CopyOnWriteSupported is true when MmapCopyOnWrite is supported on this platform.
+const CopyOnWriteSupported = true
+
+// MmapCopyOnWrite returns a private mapping of `size` bytes which initially holds the contents of `image` followed
+// by zeros. Writes to the mapping are copy-on-write, so ResetCopyOnWrite can discard them cheaply.
+//
+// The mapping is backed by an anonymous memory file holding `image`, which is released by MunmapCopyOnWrite.
+func MmapCopyOnWrite(image []byte, size int) ([]byte, error) {
+	if size == 0 || size < len(image) {
+		panic("BUG: MmapCopyOnWrite with invalid size")
+	}
+
+	name, err := syscall.BytePtrFromString("wazero")
+	if err != nil {
+		return nil, err
+	}
+	fd, _, errno := syscall.Syscall(sysMemfdCreate, uintptr(unsafe.Pointer(name)), mfdCloexec, 0)
+	if errno != 0 {
+		return nil, errno
+	}
+	// The mapping keeps the file alive, so it can be closed regardless of the result.
+	defer syscall.Close(int(fd))
+
+	// Size the file first, as accessing a mapping past the end of its file raises SIGBUS. The file is sparse, so
+	// the pages past the image don't consume memory until written.
+	if err = syscall.Ftruncate(int(fd), int64(size)); err != nil {
+		return nil, err
+	}
+	for written := 0; written < len(image); {
+		n, err := syscall.Pwrite(int(fd), image[written:], int64(written))
+		if err != nil {
+			return nil, err
+		}
+		written += n
+	}
+	return syscall.Mmap(int(fd), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE)
+}
+
+// ResetCopyOnWrite discards the writes to `b`, a part of the mapping returned by MmapCopyOnWrite, so that it holds
+// the initial contents again. Only the pages written since the last reset are released.
+func ResetCopyOnWrite(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	return syscall.Madvise(b, syscall.MADV_DONTNEED)
+}
+
+// MunmapCopyOnWrite releases the mapping returned by MmapCopyOnWrite.
+func MunmapCopyOnWrite(b []byte) error {
+	return syscall.Munmap(b)
+}
@@ -0,0 +1,4 @@
+package platform
+
+// sysMemfdCreate is the number of the memfd_create system call, which isn't defined in the syscall package.
+const sysMemfdCreate = 319
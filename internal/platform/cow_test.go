@@ -0,0 +1,37 @@
+package platform
+
+import (
+	"testing"
+
+	"github.com/AR1011/wazero/internal/testing/require"
+)
+
+func TestMmapCopyOnWrite(t *testing.T) {
+	if !CopyOnWriteSupported {
+		t.Skip()
+	}
+
+	const size = 3 * 4096
+	b, err := MmapCopyOnWrite([]byte("wazero"), size)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, MunmapCopyOnWrite(b))
+	}()
+	require.Equal(t, size, len(b))
+	require.Equal(t, "wazero", string(b[:6]))
+	require.Equal(t, make([]byte, size-6), b[6:])
+
+	copy(b, "bears")
+	b[size-1] = 1
+
+	require.NoError(t, ResetCopyOnWrite(b))
+	require.Equal(t, "wazero", string(b[:6]))
+	require.Equal(t, make([]byte, size-6), b[6:])
+
+	t.Run("panic on invalid size", func(t *testing.T) {
+		captured := require.CapturePanic(func() {
+			_, _ = MmapCopyOnWrite([]byte("wazero"), 1)
+		})
+		require.EqualError(t, captured, "BUG: MmapCopyOnWrite with invalid size")
+	})
+}
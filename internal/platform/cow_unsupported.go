@@ -0,0 +1,18 @@
+//go:build !(linux && (amd64 || arm64))
+
+package platform
+
+// CopyOnWriteSupported is true when MmapCopyOnWrite is supported on this platform.
+const CopyOnWriteSupported = false
+
+func MmapCopyOnWrite(image []byte, size int) ([]byte, error) {
+	panic("BUG: MmapCopyOnWrite is not supported on this platform")
+}
+
+func ResetCopyOnWrite(b []byte) error {
+	panic("BUG: ResetCopyOnWrite is not supported on this platform")
+}
+
+func MunmapCopyOnWrite(b []byte) error {
+	panic("BUG: MunmapCopyOnWrite is not supported on this platform")
+}
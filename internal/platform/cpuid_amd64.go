@@ -1,30 +1,8 @@
 package platform
 
-const (
-	// CpuFeatureSSE3 is the flag to query CpuFeatureFlags.Has for SSEv3 capabilities
-	CpuFeatureSSE3 = uint64(1)
-	// CpuFeatureSSE4_1 is the flag to query CpuFeatureFlags.Has for SSEv4.1 capabilities
-	CpuFeatureSSE4_1 = uint64(1) << 19
-	// CpuFeatureSSE4_2 is the flag to query CpuFeatureFlags.Has for SSEv4.2 capabilities
-	CpuFeatureSSE4_2 = uint64(1) << 20
-)
-
-const (
-	// CpuExtraFeatureABM is the flag to query CpuFeatureFlags.HasExtra for Advanced Bit Manipulation capabilities (e.g. LZCNT)
-	CpuExtraFeatureABM = uint64(1) << 5
-)
-
 // CpuFeatures exposes the capabilities for this CPU, queried via the Has, HasExtra methods
 var CpuFeatures CpuFeatureFlags = loadCpuFeatureFlags()
 
-// CpuFeatureFlags exposes methods for querying CPU capabilities
-type CpuFeatureFlags interface {
-	// Has returns true when the specified flag (represented as uint64) is supported
-	Has(cpuFeature uint64) bool
-	// HasExtra returns true when the specified extraFlag (represented as uint64) is supported
-	HasExtra(cpuFeature uint64) bool
-}
-
 // cpuFeatureFlags implements CpuFeatureFlags interface
 type cpuFeatureFlags struct {
 	flags      uint64
@@ -77,3 +55,22 @@ func (f *cpuFeatureFlags) Has(cpuFeature uint64) bool {
 func (f *cpuFeatureFlags) HasExtra(cpuFeature uint64) bool {
 	return (f.extraFlags & cpuFeature) != 0
 }
+
+// Raw implements the same method on the CpuFeatureFlags interface
+func (f *cpuFeatureFlags) Raw() uint64 {
+	// Only set the bits for the features wazero cares about, instead of all the flags obtained via CPUID.
+	var ret uint64
+	if f.Has(CpuFeatureSSE3) {
+		ret |= 1 << 0
+	}
+	if f.Has(CpuFeatureSSE4_1) {
+		ret |= 1 << 1
+	}
+	if f.Has(CpuFeatureSSE4_2) {
+		ret |= 1 << 2
+	}
+	if f.HasExtra(CpuExtraFeatureABM) {
+		ret |= 1 << 3
+	}
+	return ret
+}
@@ -16,3 +16,11 @@ func TestAmd64CpuId_cpuHasFeature(t *testing.T) {
 	require.True(t, flags.HasExtra(CpuExtraFeatureABM))
 	require.False(t, flags.HasExtra(1<<6)) // some other value
 }
+
+func TestAmd64CpuFeatureFlags_Raw(t *testing.T) {
+	flags := cpuFeatureFlags{
+		flags:      CpuFeatureSSE3 | CpuFeatureSSE4_2,
+		extraFlags: CpuExtraFeatureABM | 1<<6,
+	}
+	require.Equal(t, uint64(0b1101), flags.Raw())
+}
@@ -0,0 +1,19 @@
+//go:build !amd64
+
+package platform
+
+// CpuFeatures exposes the capabilities for this CPU, queried via the Has, HasExtra methods.
+// No features are reported on this architecture.
+var CpuFeatures CpuFeatureFlags = cpuFeatureFlags{}
+
+// cpuFeatureFlags implements CpuFeatureFlags for unsupported platforms
+type cpuFeatureFlags struct{}
+
+// Has implements the same method on the CpuFeatureFlags interface
+func (cpuFeatureFlags) Has(cpuFeature uint64) bool { return false }
+
+// HasExtra implements the same method on the CpuFeatureFlags interface
+func (cpuFeatureFlags) HasExtra(cpuFeature uint64) bool { return false }
+
+// Raw implements the same method on the CpuFeatureFlags interface
+func (cpuFeatureFlags) Raw() uint64 { return 0 }
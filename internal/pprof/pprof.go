@@ -0,0 +1,213 @@
+// Package pprof writes profiles in the format read by "go tool pprof", which is a gzipped protocol buffer.
+//
+// See https://github.com/google/pprof/blob/main/proto/profile.proto
+package pprof
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// ValueType describes the values of samples, e.g. "cpu" in "nanoseconds".
+type ValueType struct {
+	Type, Unit string
+}
+
+// Frame is a position in a call stack, which is the location of a sample.
+type Frame struct {
+	// Function is the name of the function.
+	Function string
+	// File and Line are the position in the source code, or empty and zero when unknown.
+	File string
+	Line int64
+	// Address identifies the position in the module, e.g. the offset of the instruction in the code section.
+	Address uint64
+}
+
+// Profile accumulates samples, deduplicating their locations and functions, until it is written.
+type Profile struct {
+	sampleTypes   []ValueType
+	periodType    ValueType
+	period        int64
+	timeNanos     int64
+	durationNanos int64
+	mapping       string
+
+	strings   []string
+	stringIDs map[string]int64
+	locations map[Frame]uint64
+	functions map[functionKey]uint64
+	// encodedLocations and encodedFunctions are the messages of locations and functions, in the order of their IDs.
+	encodedLocations [][]byte
+	encodedFunctions [][]byte
+	samples          [][]byte
+}
+
+type functionKey struct {
+	name, file string
+}
+
+// New returns an empty profile whose samples have values of the given types. periodType and period are the
+// distance between samples, e.g. 10000000 "cpu" "nanoseconds" for 100 samples per second.
+//
+// mapping is the name of the binary, e.g. the name of the module.
+func New(sampleTypes []ValueType, periodType ValueType, period int64, mapping string) *Profile {
+	return &Profile{
+		sampleTypes: sampleTypes,
+		periodType:  periodType,
+		period:      period,
+		mapping:     mapping,
+		strings:     []string{""}, // The first string must be empty.
+		stringIDs:   map[string]int64{"": 0},
+		locations:   map[Frame]uint64{},
+		functions:   map[functionKey]uint64{},
+	}
+}
+
+// SetTime sets the time when the profile started, and how long it lasted, in nanoseconds.
+func (p *Profile) SetTime(timeNanos, durationNanos int64) {
+	p.timeNanos, p.durationNanos = timeNanos, durationNanos
+}
+
+// AddSample adds a sample with the call stack, where the first frame is the innermost, and a value for each sample
+// type.
+func (p *Profile) AddSample(stack []Frame, values ...int64) {
+	var locationIDs, encodedValues []byte
+	for _, f := range stack {
+		locationIDs = appendVarint(locationIDs, p.locationID(f))
+	}
+	for _, v := range values {
+		encodedValues = appendVarint(encodedValues, uint64(v))
+	}
+	var sample []byte
+	sample = appendBytes(sample, 1, locationIDs)
+	sample = appendBytes(sample, 2, encodedValues)
+	p.samples = append(p.samples, sample)
+}
+
+// locationID returns the ID of the location for the frame, adding it if new. IDs start at one.
+func (p *Profile) locationID(f Frame) uint64 {
+	if id, ok := p.locations[f]; ok {
+		return id
+	}
+	id := uint64(len(p.encodedLocations) + 1)
+	p.locations[f] = id
+
+	var line []byte
+	line = appendVarintField(line, 1, p.functionID(f.Function, f.File, f.Line))
+	line = appendVarintField(line, 2, uint64(f.Line))
+
+	var loc []byte
+	loc = appendVarintField(loc, 1, id)
+	loc = appendVarintField(loc, 2, 1) // mapping
+	loc = appendVarintField(loc, 3, f.Address)
+	loc = appendBytes(loc, 4, line)
+	p.encodedLocations = append(p.encodedLocations, loc)
+	return id
+}
+
+// functionID returns the ID of the function, adding it if new. IDs start at one. The start line is the line of the
+// first frame in the function.
+func (p *Profile) functionID(name, file string, line int64) uint64 {
+	key := functionKey{name, file}
+	if id, ok := p.functions[key]; ok {
+		return id
+	}
+	id := uint64(len(p.encodedFunctions) + 1)
+	p.functions[key] = id
+
+	var fn []byte
+	fn = appendVarintField(fn, 1, id)
+	fn = appendVarintField(fn, 2, uint64(p.stringID(name)))
+	fn = appendVarintField(fn, 3, uint64(p.stringID(name)))
+	fn = appendVarintField(fn, 4, uint64(p.stringID(file)))
+	fn = appendVarintField(fn, 5, uint64(line))
+	p.encodedFunctions = append(p.encodedFunctions, fn)
+	return id
+}
+
+func (p *Profile) stringID(s string) int64 {
+	if id, ok := p.stringIDs[s]; ok {
+		return id
+	}
+	id := int64(len(p.strings))
+	p.strings = append(p.strings, s)
+	p.stringIDs[s] = id
+	return id
+}
+
+// Write writes the gzipped profile.
+func (p *Profile) Write(w io.Writer) error {
+	var buf []byte
+	for _, t := range p.sampleTypes {
+		buf = appendBytes(buf, 1, p.valueType(t))
+	}
+	for _, s := range p.samples {
+		buf = appendBytes(buf, 2, s)
+	}
+
+	var mapping []byte
+	mapping = appendVarintField(mapping, 1, 1)
+	mapping = appendVarintField(mapping, 5, uint64(p.stringID(p.mapping)))
+	mapping = appendVarintField(mapping, 7, 1) // has_functions
+	mapping = appendVarintField(mapping, 8, 1) // has_filenames
+	mapping = appendVarintField(mapping, 9, 1) // has_line_numbers
+	buf = appendBytes(buf, 3, mapping)
+
+	for _, l := range p.encodedLocations {
+		buf = appendBytes(buf, 4, l)
+	}
+	for _, f := range p.encodedFunctions {
+		buf = appendBytes(buf, 5, f)
+	}
+	periodType := p.valueType(p.periodType) // adds strings, so must be before the string table.
+	for _, s := range p.strings {
+		buf = appendBytes(buf, 6, []byte(s))
+	}
+	buf = appendVarintField(buf, 9, uint64(p.timeNanos))
+	buf = appendVarintField(buf, 10, uint64(p.durationNanos))
+	buf = appendBytes(buf, 11, periodType)
+	buf = appendVarintField(buf, 12, uint64(p.period))
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(buf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func (p *Profile) valueType(t ValueType) []byte {
+	var buf []byte
+	buf = appendVarintField(buf, 1, uint64(p.stringID(t.Type)))
+	buf = appendVarintField(buf, 2, uint64(p.stringID(t.Unit)))
+	return buf
+}
+
+// Wire types of protocol buffers.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+// appendVarintField appends the field unless it is zero, which is the default value.
+func appendVarintField(buf []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return buf
+	}
+	buf = appendVarint(buf, uint64(field)<<3|wireVarint)
+	return appendVarint(buf, v)
+}
+
+func appendBytes(buf []byte, field int, b []byte) []byte {
+	buf = appendVarint(buf, uint64(field)<<3|wireBytes)
+	buf = appendVarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
@@ -0,0 +1,104 @@
+package pprof
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/AR1011/wazero/internal/testing/require"
+)
+
+func TestProfile_Write(t *testing.T) {
+	p := New([]ValueType{{Type: "samples", Unit: "count"}}, ValueType{Type: "cpu", Unit: "nanoseconds"}, 10, "test")
+	p.SetTime(1, 2)
+	p.AddSample([]Frame{{Function: "inner", File: "a.c", Line: 3, Address: 0x10}, {Function: "main"}}, 5)
+	// The location of main is shared, and the function of inner is shared by its locations.
+	p.AddSample([]Frame{{Function: "inner", File: "a.c", Line: 4, Address: 0x12}, {Function: "main"}}, 7)
+
+	var buf bytes.Buffer
+	require.NoError(t, p.Write(&buf))
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+
+	fields := decodeFields(t, b)
+	require.Equal(t, 1, len(fields[1]))  // sample_type
+	require.Equal(t, 2, len(fields[2]))  // sample
+	require.Equal(t, 1, len(fields[3]))  // mapping
+	require.Equal(t, 3, len(fields[4]))  // location
+	require.Equal(t, 2, len(fields[5]))  // function
+	require.Equal(t, 1, len(fields[11])) // period_type
+
+	var strs []string
+	for _, s := range fields[6] {
+		strs = append(strs, string(s.bytes))
+	}
+	require.Equal(t, []string{"", "inner", "a.c", "main", "samples", "count", "test", "cpu", "nanoseconds"}, strs)
+	require.Equal(t, uint64(1), fields[9][0].varint)
+	require.Equal(t, uint64(2), fields[10][0].varint)
+	require.Equal(t, uint64(10), fields[12][0].varint)
+
+	// The second sample has the locations 3 and 2, and the value 7.
+	sample := decodeFields(t, fields[2][1].bytes)
+	require.Equal(t, []byte{3, 2}, sample[1][0].bytes)
+	require.Equal(t, []byte{7}, sample[2][0].bytes)
+}
+
+type field struct {
+	varint uint64
+	bytes  []byte
+}
+
+// decodeFields decodes a protocol buffer message into its fields by number.
+func decodeFields(t *testing.T, b []byte) map[int][]field {
+	fields := map[int][]field{}
+	for len(b) > 0 {
+		key := readVarint(t, &b)
+		var f field
+		switch key & 7 {
+		case wireVarint:
+			f.varint = readVarint(t, &b)
+		case wireBytes:
+			n := readVarint(t, &b)
+			f.bytes, b = b[:n], b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields[int(key>>3)] = append(fields[int(key>>3)], f)
+	}
+	return fields
+}
+
+func readVarint(t *testing.T, b *[]byte) (v uint64) {
+	for shift := 0; ; shift += 7 {
+		if len(*b) == 0 {
+			t.Fatal("unexpected end of message")
+		}
+		c := (*b)[0]
+		*b = (*b)[1:]
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return
+		}
+	}
+}
+
+func TestAppendVarint(t *testing.T) {
+	tests := []struct {
+		v        uint64
+		expected []byte
+	}{
+		{v: 0, expected: []byte{0}},
+		{v: 1, expected: []byte{1}},
+		{v: 127, expected: []byte{0x7f}},
+		{v: 128, expected: []byte{0x80, 0x01}},
+		{v: 300, expected: []byte{0xac, 0x02}},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		require.Equal(t, tc.expected, appendVarint(nil, tc.v))
+	}
+}
@@ -47,9 +47,10 @@ func decodeDataSegment(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret *w
 			d, _, err := leb128.DecodeUint32(r)
 			if err != nil {
 				return fmt.Errorf("read memory index: %v", err)
-			} else if d != 0 {
+			} else if d != 0 && !enabledFeatures.IsEnabled(api.CoreFeatureMultiMemory) {
 				return fmt.Errorf("memory index must be zero but was %d", d)
 			}
+			ret.MemoryIndex = d
 		}
 
 		err = decodeConstantExpression(r, enabledFeatures, &ret.OffsetExpression)
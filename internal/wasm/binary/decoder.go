@@ -106,7 +106,7 @@ func DecodeModule(
 		case wasm.SectionIDType:
 			m.TypeSection, err = decodeTypeSection(enabledFeatures, r)
 		case wasm.SectionIDImport:
-			m.ImportSection, m.ImportPerModule, m.ImportFunctionCount, m.ImportGlobalCount, m.ImportMemoryCount, m.ImportTableCount, err = decodeImportSection(r, memSizer, memoryLimitPages, enabledFeatures)
+			m.ImportSection, m.ImportPerModule, m.ImportFunctionCount, m.ImportGlobalCount, m.ImportMemoryCount, m.ImportTableCount, m.ImportTagCount, err = decodeImportSection(r, memSizer, memoryLimitPages, enabledFeatures)
 			if err != nil {
 				return nil, err // avoid re-wrapping the error.
 			}
@@ -115,7 +115,12 @@ func DecodeModule(
 		case wasm.SectionIDTable:
 			m.TableSection, err = decodeTableSection(r, enabledFeatures)
 		case wasm.SectionIDMemory:
-			m.MemorySection, err = decodeMemorySection(r, memSizer, memoryLimitPages)
+			m.MemorySection, err = decodeMemorySection(r, enabledFeatures, memSizer, memoryLimitPages)
+		case wasm.SectionIDTag:
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+				return nil, fmt.Errorf("tag section not supported as %v", err)
+			}
+			m.TagSection, err = decodeTagSection(r)
 		case wasm.SectionIDGlobal:
 			if m.GlobalSection, err = decodeGlobalSection(r, enabledFeatures); err != nil {
 				return nil, err // avoid re-wrapping the error.
@@ -165,29 +170,37 @@ func DecodeModule(
 }
 
 // memorySizer derives min, capacity and max pages from decoded wasm.
-type memorySizer func(minPages uint32, maxPages *uint32) (min uint32, capacity uint32, max uint32)
+type memorySizer func(minPages uint32, maxPages *uint32, is64 bool) (min uint32, capacity uint32, max uint32)
 
 // newMemorySizer sets capacity to minPages unless max is defined and
 // memoryCapacityFromMax is true.
+//
+// Note: memoryLimitPages is capped to wasm.MemoryLimitPages unless is64.
 func newMemorySizer(memoryLimitPages uint32, memoryCapacityFromMax bool) memorySizer {
-	return func(minPages uint32, maxPages *uint32) (min, capacity, max uint32) {
+	return func(minPages uint32, maxPages *uint32, is64 bool) (min, capacity, max uint32) {
+		limitPages, specLimitPages := memoryLimitPages, wasm.MemoryLimitPages
+		if is64 {
+			specLimitPages = wasm.Memory64LimitPages
+		} else if limitPages > wasm.MemoryLimitPages {
+			limitPages = wasm.MemoryLimitPages
+		}
 		if maxPages != nil {
 			if memoryCapacityFromMax {
 				return minPages, *maxPages, *maxPages
 			}
 			// This is an invalid value: let it propagate, we will fail later.
-			if *maxPages > wasm.MemoryLimitPages {
+			if *maxPages > specLimitPages {
 				return minPages, minPages, *maxPages
 			}
 			// This is a valid value, but it goes over the run-time limit: return the limit.
-			if *maxPages > memoryLimitPages {
-				return minPages, minPages, memoryLimitPages
+			if *maxPages > limitPages {
+				return minPages, minPages, limitPages
 			}
 			return minPages, minPages, *maxPages
 		}
 		if memoryCapacityFromMax {
-			return minPages, memoryLimitPages, memoryLimitPages
+			return minPages, limitPages, limitPages
 		}
-		return minPages, minPages, memoryLimitPages
+		return minPages, minPages, limitPages
 	}
 }
@@ -6,9 +6,9 @@ import (
 	"testing"
 
 	"github.com/AR1011/wazero/api"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 func TestFunctionType(t *testing.T) {
@@ -3,9 +3,9 @@ package binary
 import (
 	"testing"
 
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 func TestEncodeImport(t *testing.T) {
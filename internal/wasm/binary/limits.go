@@ -3,6 +3,7 @@ package binary
 import (
 	"bytes"
 	"fmt"
+	"math"
 
 	"github.com/AR1011/wazero/internal/leb128"
 )
@@ -10,33 +11,52 @@ import (
 // decodeLimitsType returns the `limitsType` (min, max) decoded with the WebAssembly 1.0 (20191205) Binary Format.
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#limits%E2%91%A6
-func decodeLimitsType(r *bytes.Reader) (min uint32, max *uint32, err error) {
+//
+// Extended in threads proposal: https://webassembly.github.io/threads/core/binary/types.html#limits
+//
+// Extended in memory64 proposal: https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md#binary-format
+func decodeLimitsType(r *bytes.Reader) (min uint32, max *uint32, shared, memory64 bool, err error) {
 	var flag byte
 	if flag, err = r.ReadByte(); err != nil {
 		err = fmt.Errorf("read leading byte: %v", err)
 		return
 	}
 
-	switch flag {
-	case 0x00:
-		min, _, err = leb128.DecodeUint32(r)
-		if err != nil {
-			err = fmt.Errorf("read min of limit: %v", err)
-		}
-	case 0x01:
-		min, _, err = leb128.DecodeUint32(r)
-		if err != nil {
-			err = fmt.Errorf("read min of limit: %v", err)
-			return
-		}
+	if flag > 0x07 {
+		err = fmt.Errorf("%v for limits: %#x not in (0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07)", ErrInvalidByte, flag)
+		return
+	}
+
+	shared = flag&0x02 != 0
+	memory64 = flag&0x04 != 0
+
+	if min, err = decodeLimit(r, memory64); err != nil {
+		err = fmt.Errorf("read min of limit: %v", err)
+		return
+	}
+	if flag&0x01 != 0 {
 		var m uint32
-		if m, _, err = leb128.DecodeUint32(r); err != nil {
+		if m, err = decodeLimit(r, memory64); err != nil {
 			err = fmt.Errorf("read max of limit: %v", err)
 		} else {
 			max = &m
 		}
-	default:
-		err = fmt.Errorf("%v for limits: %#x != 0x00 or 0x01", ErrInvalidByte, flag)
 	}
 	return
 }
+
+// decodeLimit decodes a single limit, which is encoded as u64 for memory64. As wazero doesn't support more than
+// wasm.Memory64LimitPages pages, a limit which doesn't fit in uint32 is an error.
+func decodeLimit(r *bytes.Reader, memory64 bool) (uint32, error) {
+	if !memory64 {
+		v, _, err := leb128.DecodeUint32(r)
+		return v, err
+	}
+	v, _, err := leb128.DecodeUint64(r)
+	if err != nil {
+		return 0, err
+	} else if v > math.MaxUint32 {
+		return 0, fmt.Errorf("%d pages is over the limit of %d pages", v, uint32(math.MaxUint32))
+	}
+	return uint32(v), nil
+}
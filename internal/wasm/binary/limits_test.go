@@ -6,8 +6,8 @@ import (
 	"math"
 	"testing"
 
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 func TestLimitsType(t *testing.T) {
@@ -56,10 +56,71 @@ func TestLimitsType(t *testing.T) {
 		})
 
 		t.Run(fmt.Sprintf("decode - %s", tc.name), func(t *testing.T) {
-			min, max, err := decodeLimitsType(bytes.NewReader(b))
+			min, max, shared, memory64, err := decodeLimitsType(bytes.NewReader(b))
 			require.NoError(t, err)
 			require.Equal(t, min, tc.min)
 			require.Equal(t, max, tc.max)
+			require.False(t, shared)
+			require.False(t, memory64)
+		})
+	}
+}
+
+func TestLimitsType_Memory64(t *testing.T) {
+	one, largest := uint32(1), uint32(math.MaxUint32)
+
+	tests := []struct {
+		name        string
+		input       []byte
+		expectedMin uint32
+		expectedMax *uint32
+		expectedErr string
+	}{
+		{
+			name:  "min 0",
+			input: []byte{0x4, 0},
+		},
+		{
+			name:        "min 0, max 1",
+			input:       []byte{0x5, 0, 1},
+			expectedMax: &one,
+		},
+		{
+			name:        "min largest max largest",
+			input:       []byte{0x5, 0xff, 0xff, 0xff, 0xff, 0xf, 0xff, 0xff, 0xff, 0xff, 0xf},
+			expectedMin: largest,
+			expectedMax: &largest,
+		},
+		{
+			name:        "min over uint32",
+			input:       []byte{0x4, 0x80, 0x80, 0x80, 0x80, 0x10},
+			expectedErr: "read min of limit: 4294967296 pages is over the limit of 4294967295 pages",
+		},
+		{
+			name:        "max over uint32",
+			input:       []byte{0x5, 0, 0x80, 0x80, 0x80, 0x80, 0x10},
+			expectedErr: "read max of limit: 4294967296 pages is over the limit of 4294967295 pages",
+		},
+		{
+			name:        "invalid flag",
+			input:       []byte{0x8, 0},
+			expectedErr: "invalid byte for limits: 0x8 not in (0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07)",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			min, max, _, memory64, err := decodeLimitsType(bytes.NewReader(tc.input))
+			if tc.expectedErr != "" {
+				require.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, memory64)
+			require.Equal(t, tc.expectedMin, min)
+			require.Equal(t, tc.expectedMax, max)
 		})
 	}
 }
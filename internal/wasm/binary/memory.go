@@ -2,7 +2,9 @@ package binary
 
 import (
 	"bytes"
+	"fmt"
 
+	"github.com/AR1011/wazero/api"
 	"github.com/AR1011/wazero/internal/wasm"
 )
 
@@ -11,16 +13,35 @@ import (
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-memory
 func decodeMemory(
 	r *bytes.Reader,
-	memorySizer func(minPages uint32, maxPages *uint32) (min, capacity, max uint32),
+	enabledFeatures api.CoreFeatures,
+	memorySizer func(minPages uint32, maxPages *uint32, is64 bool) (min, capacity, max uint32),
 	memoryLimitPages uint32,
 ) (*wasm.Memory, error) {
-	min, maxP, err := decodeLimitsType(r)
+	min, maxP, shared, memory64, err := decodeLimitsType(r)
 	if err != nil {
 		return nil, err
 	}
 
-	min, capacity, max := memorySizer(min, maxP)
-	mem := &wasm.Memory{Min: min, Cap: capacity, Max: max, IsMaxEncoded: maxP != nil}
+	if memory64 {
+		if err = enabledFeatures.RequireEnabled(api.CoreFeatureMemory64); err != nil {
+			return nil, fmt.Errorf("64-bit memory requested but %w", err)
+		}
+	}
+
+	if shared {
+		if !enabledFeatures.IsEnabled(api.CoreFeatureThreads) {
+			return nil, fmt.Errorf("shared memory requested but threads feature not enabled")
+		}
+
+		// This restriction may be lifted in the future.
+		// https://webassembly.github.io/threads/core/binary/types.html#memory-types
+		if maxP == nil {
+			return nil, fmt.Errorf("shared memory requires a maximum size to be specified")
+		}
+	}
+
+	min, capacity, max := memorySizer(min, maxP, memory64)
+	mem := &wasm.Memory{Min: min, Cap: capacity, Max: max, IsMaxEncoded: maxP != nil, IsShared: shared, IsMemory64: memory64}
 
 	return mem, mem.Validate(memoryLimitPages)
 }
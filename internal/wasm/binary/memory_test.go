@@ -5,14 +5,16 @@ import (
 	"fmt"
 	"testing"
 
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
+	"github.com/AR1011/wazero/api"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 func Test_newMemorySizer(t *testing.T) {
 	zero := uint32(0)
 	ten := uint32(10)
+	largest := wasm.Memory64LimitPages
 	defaultLimit := wasm.MemoryLimitPages
 
 	tests := []struct {
@@ -21,6 +23,7 @@ func Test_newMemorySizer(t *testing.T) {
 		limit                                      uint32
 		min                                        uint32
 		max                                        *uint32
+		is64                                       bool
 		expectedMin, expectedCapacity, expectedMax uint32
 	}{
 		{
@@ -103,13 +106,40 @@ func Test_newMemorySizer(t *testing.T) {
 			expectedCapacity: 0,
 			expectedMax:      5,
 		},
+		{
+			name:             "32-bit memory caps the limit",
+			limit:            defaultLimit * 2,
+			min:              0,
+			expectedMin:      0,
+			expectedCapacity: 0,
+			expectedMax:      defaultLimit,
+		},
+		{
+			name:             "64-bit memory over the 32-bit limit",
+			limit:            defaultLimit * 2,
+			min:              0,
+			is64:             true,
+			expectedMin:      0,
+			expectedCapacity: 0,
+			expectedMax:      defaultLimit * 2,
+		},
+		{
+			name:             "64-bit memory max over the limit",
+			limit:            defaultLimit * 2,
+			min:              0,
+			max:              &largest,
+			is64:             true,
+			expectedMin:      0,
+			expectedCapacity: 0,
+			expectedMax:      defaultLimit * 2,
+		},
 	}
 
 	for _, tt := range tests {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
 			sizer := newMemorySizer(tc.limit, tc.memoryCapacityFromMax)
-			min, capacity, max := sizer(tc.min, tc.max)
+			min, capacity, max := sizer(tc.min, tc.max, tc.is64)
 			require.Equal(t, tc.expectedMin, min)
 			require.Equal(t, tc.expectedCapacity, capacity)
 			require.Equal(t, tc.expectedMax, max)
@@ -163,6 +193,16 @@ func TestMemoryType(t *testing.T) {
 			memoryLimitPages: 512,
 			expected:         []byte{0x1, 0, 0x80, 0x80, 0x4},
 		},
+		{
+			name:     "shared",
+			input:    &wasm.Memory{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true, IsShared: true},
+			expected: []byte{0x3, 1, 2},
+		},
+		{
+			name:     "memory64",
+			input:    &wasm.Memory{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true, IsMemory64: true},
+			expected: []byte{0x5, 1, 2},
+		},
 	}
 
 	for _, tt := range tests {
@@ -182,7 +222,7 @@ func TestMemoryType(t *testing.T) {
 				expectedDecoded.Max = tmax
 			}
 
-			binary, err := decodeMemory(bytes.NewReader(b), newMemorySizer(tmax, false), tmax)
+			binary, err := decodeMemory(bytes.NewReader(b), api.CoreFeaturesV2|api.CoreFeatureThreads|api.CoreFeatureMemory64, newMemorySizer(tmax, false), tmax)
 			require.NoError(t, err)
 			require.Equal(t, binary, expectedDecoded)
 		})
@@ -193,9 +233,10 @@ func TestDecodeMemoryType_Errors(t *testing.T) {
 	max := wasm.MemoryLimitPages
 
 	tests := []struct {
-		name        string
-		input       []byte
-		expectedErr string
+		name            string
+		input           []byte
+		enabledFeatures api.CoreFeatures
+		expectedErr     string
 	}{
 		{
 			name:        "max < min",
@@ -205,12 +246,34 @@ func TestDecodeMemoryType_Errors(t *testing.T) {
 		{
 			name:        "min > limit",
 			input:       []byte{0x0, 0xff, 0xff, 0xff, 0xff, 0xf},
-			expectedErr: "min 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "min 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 		{
 			name:        "max > limit",
 			input:       []byte{0x1, 0, 0xff, 0xff, 0xff, 0xff, 0xf},
-			expectedErr: "max 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "max 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
+		},
+		{
+			name:        "shared but threads disabled",
+			input:       []byte{0x3, 0, 1},
+			expectedErr: "shared memory requested but threads feature not enabled",
+		},
+		{
+			name:            "shared but no max",
+			input:           []byte{0x2, 0},
+			enabledFeatures: api.CoreFeatureThreads,
+			expectedErr:     "shared memory requires a maximum size to be specified",
+		},
+		{
+			name:        "memory64 but memory64 disabled",
+			input:       []byte{0x5, 0, 1},
+			expectedErr: "64-bit memory requested but feature \"memory64\" is disabled",
+		},
+		{
+			name:            "memory64 min > limit",
+			input:           []byte{0x4, 0x81, 0x80, 0x4},
+			enabledFeatures: api.CoreFeatureMemory64,
+			expectedErr:     "min 65537 pages (4 Gi) over limit of 65536 pages (4 Gi)",
 		},
 	}
 
@@ -218,7 +281,7 @@ func TestDecodeMemoryType_Errors(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := decodeMemory(bytes.NewReader(tc.input), newMemorySizer(max, false), max)
+			_, err := decodeMemory(bytes.NewReader(tc.input), api.CoreFeaturesV2|tc.enabledFeatures, newMemorySizer(max, false), max)
 			require.EqualError(t, err, tc.expectedErr)
 		})
 	}
@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"testing"
 
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 // TestDecodeNameSection relies on unit tests for NameSection.EncodeData, specifically that the encoding is
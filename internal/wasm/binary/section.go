@@ -33,7 +33,7 @@ func decodeImportSection(
 	enabledFeatures api.CoreFeatures,
 ) (result []wasm.Import,
 	perModule map[string][]*wasm.Import,
-	funcCount, globalCount, memoryCount, tableCount wasm.Index, err error,
+	funcCount, globalCount, memoryCount, tableCount, tagCount wasm.Index, err error,
 ) {
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
@@ -61,6 +61,9 @@ func decodeImportSection(
 		case wasm.ExternTypeTable:
 			imp.IndexPerType = tableCount
 			tableCount++
+		case wasm.ExternTypeTag:
+			imp.IndexPerType = tagCount
+			tagCount++
 		}
 		perModule[imp.Module] = append(perModule[imp.Module], imp)
 	}
@@ -105,21 +108,65 @@ func decodeTableSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]wa
 
 func decodeMemorySection(
 	r *bytes.Reader,
+	enabledFeatures api.CoreFeatures,
 	memorySizer memorySizer,
 	memoryLimitPages uint32,
-) (*wasm.Memory, error) {
+) ([]wasm.Memory, error) {
 	vs, _, err := leb128.DecodeUint32(r)
 	if err != nil {
 		return nil, fmt.Errorf("error reading size")
 	}
-	if vs > 1 {
+	if vs > 1 && !enabledFeatures.IsEnabled(api.CoreFeatureMultiMemory) {
 		return nil, fmt.Errorf("at most one memory allowed in module, but read %d", vs)
 	} else if vs == 0 {
 		// memory count can be zero.
 		return nil, nil
 	}
 
-	return decodeMemory(r, memorySizer, memoryLimitPages)
+	ret := make([]wasm.Memory, vs)
+	for i := range ret {
+		mem, err := decodeMemory(r, enabledFeatures, memorySizer, memoryLimitPages)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = *mem
+	}
+	return ret, nil
+}
+
+// decodeTagSection decodes the type index of each tag defined in the module.
+//
+// See https://webassembly.github.io/exception-handling/core/binary/modules.html#tag-section
+func decodeTagSection(r *bytes.Reader) ([]wasm.Index, error) {
+	vs, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return nil, fmt.Errorf("get size of vector: %w", err)
+	}
+
+	result := make([]wasm.Index, vs)
+	for i := uint32(0); i < vs; i++ {
+		if result[i], err = decodeTagType(r); err != nil {
+			return nil, fmt.Errorf("read %d-th tag: %w", i, err)
+		}
+	}
+	return result, nil
+}
+
+// decodeTagType decodes a tag type, which is the exception attribute followed by a type index.
+func decodeTagType(r *bytes.Reader) (wasm.Index, error) {
+	attribute, err := r.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("read attribute: %w", err)
+	}
+	// The only attribute defined is zero, which means the tag is for exceptions.
+	if attribute != 0 {
+		return 0, fmt.Errorf("%w: invalid tag attribute: %#x", ErrInvalidByte, attribute)
+	}
+	typeIndex, _, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return 0, fmt.Errorf("get type index: %w", err)
+	}
+	return typeIndex, nil
 }
 
 func decodeGlobalSection(r *bytes.Reader, enabledFeatures api.CoreFeatures) ([]wasm.Global, error) {
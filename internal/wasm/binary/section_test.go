@@ -5,9 +5,9 @@ import (
 	"testing"
 
 	"github.com/AR1011/wazero/api"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 func TestTableSection(t *testing.T) {
@@ -87,16 +87,31 @@ func TestMemorySection(t *testing.T) {
 	three := uint32(3)
 	tests := []struct {
 		name     string
+		features api.CoreFeatures
 		input    []byte
-		expected *wasm.Memory
+		expected []wasm.Memory
 	}{
 		{
-			name: "min and min with max",
+			name:     "min and min with max",
+			features: api.CoreFeaturesV2,
 			input: []byte{
 				0x01,             // 1 memory
 				0x01, 0x02, 0x03, // (memory 2 3)
 			},
-			expected: &wasm.Memory{Min: 2, Cap: 2, Max: three, IsMaxEncoded: true},
+			expected: []wasm.Memory{{Min: 2, Cap: 2, Max: three, IsMaxEncoded: true}},
+		},
+		{
+			name:     "multiple memories",
+			features: api.CoreFeaturesV2 | api.CoreFeatureMultiMemory,
+			input: []byte{
+				0x02,       // 2 memories
+				0x00, 0x01, // (memory 1)
+				0x01, 0x02, 0x03, // (memory 2 3)
+			},
+			expected: []wasm.Memory{
+				{Min: 1, Cap: 1, Max: max},
+				{Min: 2, Cap: 2, Max: three, IsMaxEncoded: true},
+			},
 		},
 	}
 
@@ -104,7 +119,7 @@ func TestMemorySection(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			memories, err := decodeMemorySection(bytes.NewReader(tc.input), newMemorySizer(max, false), max)
+			memories, err := decodeMemorySection(bytes.NewReader(tc.input), tc.features, newMemorySizer(max, false), max)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, memories)
 		})
@@ -134,7 +149,7 @@ func TestMemorySection_Errors(t *testing.T) {
 		tc := tt
 
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := decodeMemorySection(bytes.NewReader(tc.input), newMemorySizer(max, false), max)
+			_, err := decodeMemorySection(bytes.NewReader(tc.input), api.CoreFeaturesV2, newMemorySizer(max, false), max)
 			require.EqualError(t, err, tc.expectedErr)
 		})
 	}
@@ -241,3 +256,19 @@ func TestDecodeDataCountSection(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestDecodeTagSection(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		tags, err := decodeTagSection(bytes.NewReader([]byte{
+			0x02,    // 2 tags
+			0x00, 1, // (tag (type 1))
+			0x00, 0, // (tag (type 0))
+		}))
+		require.NoError(t, err)
+		require.Equal(t, []wasm.Index{1, 0}, tags)
+	})
+	t.Run("invalid attribute", func(t *testing.T) {
+		_, err := decodeTagSection(bytes.NewReader([]byte{0x01, 0x01, 0}))
+		require.EqualError(t, err, "read 0-th tag: invalid byte: invalid tag attribute: 0x1")
+	})
+}
@@ -23,10 +23,17 @@ func decodeTable(r *bytes.Reader, enabledFeatures api.CoreFeatures, ret *wasm.Ta
 		}
 	}
 
-	ret.Min, ret.Max, err = decodeLimitsType(r)
+	var shared, table64 bool
+	ret.Min, ret.Max, shared, table64, err = decodeLimitsType(r)
 	if err != nil {
 		return fmt.Errorf("read limits: %v", err)
 	}
+	if shared {
+		return fmt.Errorf("tables cannot be marked as shared")
+	}
+	if table64 {
+		return fmt.Errorf("tables cannot be 64-bit")
+	}
 	if ret.Min > wasm.MaximumFunctionIndex {
 		return fmt.Errorf("table min must be at most %d", wasm.MaximumFunctionIndex)
 	}
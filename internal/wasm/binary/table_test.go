@@ -6,9 +6,9 @@ import (
 	"testing"
 
 	"github.com/AR1011/wazero/api"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 func TestTableType(t *testing.T) {
@@ -93,6 +93,12 @@ func TestDecodeTableType_Errors(t *testing.T) {
 			expectedErr: "table min must be at most 134217728",
 			features:    api.CoreFeatureReferenceTypes,
 		},
+		{
+			name:        "shared",
+			input:       []byte{wasm.RefTypeFuncref, 0x3, 0x1, 0x2},
+			expectedErr: "tables cannot be marked as shared",
+			features:    api.CoreFeatureReferenceTypes | api.CoreFeatureThreads,
+		},
 	}
 
 	for _, tt := range tests {
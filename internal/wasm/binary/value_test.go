@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"testing"
 
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 )
 
 func TestEncodeValTypes(t *testing.T) {
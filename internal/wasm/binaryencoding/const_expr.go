@@ -5,6 +5,10 @@ import (
 )
 
 func encodeConstantExpression(expr wasm.ConstantExpression) (ret []byte) {
+	// The vector prefix isn't retained when decoding v128.const, as no other vector instruction is constant.
+	if expr.Opcode == wasm.OpcodeVecV128Const {
+		ret = append(ret, wasm.OpcodeVecPrefix)
+	}
 	ret = append(ret, expr.Opcode)
 	ret = append(ret, expr.Data...)
 	ret = append(ret, wasm.OpcodeEnd)
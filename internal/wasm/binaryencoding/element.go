@@ -0,0 +1,93 @@
+package binaryencoding
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/wasm"
+)
+
+func ensureElementKindFuncRef(r *bytes.Reader) error {
+	elemKind, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("read element prefix: %w", err)
+	}
+	if elemKind != 0x0 { // ElemKind is fixed to 0x0 now: https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/binary/modules.html#element-section
+		return fmt.Errorf("element kind must be zero but was 0x%x", elemKind)
+	}
+	return nil
+}
+
+// encodeElement returns the wasm.ElementSegment encoded in WebAssembly 2.0 Binary Format.
+//
+// The most compact prefix is chosen: segments that only contain function indexes are encoded as vec(funcidx), and
+// others (e.g. ref.null or global.get) as vec(expr).
+//
+// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/binary/modules.html#element-section
+func encodeElement(e *wasm.ElementSegment) (ret []byte) {
+	refType := e.Type
+	if refType == 0 { // defaults to funcref, as in WebAssembly 1.0
+		refType = wasm.RefTypeFuncref
+	}
+	funcIndexesOnly := refType == wasm.RefTypeFuncref
+	for _, idx := range e.Init {
+		if idx&(wasm.ElementInitNullReference|wasm.ElementInitImportedGlobalFunctionReference) != 0 {
+			funcIndexesOnly = false
+			break
+		}
+	}
+
+	var prefix uint32
+	switch e.Mode {
+	case wasm.ElementModeActive:
+		// The table index can only be omitted for table zero of funcref.
+		if e.TableIndex != 0 || refType != wasm.RefTypeFuncref {
+			prefix = 2
+		}
+	case wasm.ElementModePassive:
+		prefix = 1
+	case wasm.ElementModeDeclarative:
+		prefix = 3
+	}
+	if !funcIndexesOnly {
+		prefix += 4
+	}
+	ret = append(ret, leb128.EncodeUint32(prefix)...)
+
+	if e.Mode == wasm.ElementModeActive {
+		if prefix == 2 || prefix == 6 {
+			ret = append(ret, leb128.EncodeUint32(e.TableIndex)...)
+		}
+		ret = append(ret, encodeConstantExpression(e.OffsetExpr)...)
+	}
+
+	// Only the legacy (0) and funcref const expr (4) forms omit the element kind or reference type.
+	if prefix != 0 && prefix != 4 {
+		if funcIndexesOnly {
+			ret = append(ret, 0x0) // elemkind funcref
+		} else {
+			ret = append(ret, refType)
+		}
+	}
+
+	ret = append(ret, leb128.EncodeUint32(uint32(len(e.Init)))...)
+	for _, idx := range e.Init {
+		if funcIndexesOnly {
+			ret = append(ret, leb128.EncodeUint32(idx)...)
+			continue
+		}
+		switch {
+		case idx == wasm.ElementInitNullReference:
+			ret = append(ret, wasm.OpcodeRefNull, refType)
+		case idx&wasm.ElementInitImportedGlobalFunctionReference != 0:
+			ret = append(ret, wasm.OpcodeGlobalGet)
+			ret = append(ret, leb128.EncodeUint32(idx&^wasm.ElementInitImportedGlobalFunctionReference)...)
+		default:
+			ret = append(ret, wasm.OpcodeRefFunc)
+			ret = append(ret, leb128.EncodeUint32(idx)...)
+		}
+		ret = append(ret, wasm.OpcodeEnd)
+	}
+	return
+}
@@ -0,0 +1,93 @@
+package binaryencoding
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+)
+
+func Test_ensureElementKindFuncRef(t *testing.T) {
+	require.NoError(t, ensureElementKindFuncRef(bytes.NewReader([]byte{0x0})))
+	require.Error(t, ensureElementKindFuncRef(bytes.NewReader([]byte{0x1})))
+}
+
+func Test_encodeElement(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    *wasm.ElementSegment
+		expected []byte
+	}{
+		{
+			name: "active table 0",
+			input: &wasm.ElementSegment{
+				OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{1}},
+				Init:       []wasm.Index{0, 2},
+				Type:       wasm.RefTypeFuncref,
+				Mode:       wasm.ElementModeActive,
+			},
+			expected: []byte{0, wasm.OpcodeI32Const, 1, wasm.OpcodeEnd, 2, 0, 2},
+		},
+		{
+			name: "active table 1",
+			input: &wasm.ElementSegment{
+				OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{1}},
+				TableIndex: 1,
+				Init:       []wasm.Index{3},
+				Type:       wasm.RefTypeFuncref,
+				Mode:       wasm.ElementModeActive,
+			},
+			expected: []byte{2, 1, wasm.OpcodeI32Const, 1, wasm.OpcodeEnd, 0, 1, 3},
+		},
+		{
+			name:     "passive",
+			input:    &wasm.ElementSegment{Init: []wasm.Index{1}, Type: wasm.RefTypeFuncref, Mode: wasm.ElementModePassive},
+			expected: []byte{1, 0, 1, 1},
+		},
+		{
+			name:     "declarative",
+			input:    &wasm.ElementSegment{Init: []wasm.Index{1}, Type: wasm.RefTypeFuncref, Mode: wasm.ElementModeDeclarative},
+			expected: []byte{3, 0, 1, 1},
+		},
+		{
+			name: "active table 0 with null",
+			input: &wasm.ElementSegment{
+				OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{0}},
+				Init:       []wasm.Index{wasm.ElementInitNullReference, 1},
+				Type:       wasm.RefTypeFuncref,
+				Mode:       wasm.ElementModeActive,
+			},
+			expected: []byte{
+				4, wasm.OpcodeI32Const, 0, wasm.OpcodeEnd, 2,
+				wasm.OpcodeRefNull, wasm.RefTypeFuncref, wasm.OpcodeEnd,
+				wasm.OpcodeRefFunc, 1, wasm.OpcodeEnd,
+			},
+		},
+		{
+			name: "passive externref",
+			input: &wasm.ElementSegment{
+				Init: []wasm.Index{wasm.ElementInitNullReference},
+				Type: wasm.RefTypeExternref,
+				Mode: wasm.ElementModePassive,
+			},
+			expected: []byte{5, wasm.RefTypeExternref, 1, wasm.OpcodeRefNull, wasm.RefTypeExternref, wasm.OpcodeEnd},
+		},
+		{
+			name: "declarative global.get",
+			input: &wasm.ElementSegment{
+				Init: []wasm.Index{wasm.ElementInitImportedGlobalFunctionReference | 2},
+				Type: wasm.RefTypeFuncref,
+				Mode: wasm.ElementModeDeclarative,
+			},
+			expected: []byte{7, wasm.RefTypeFuncref, 1, wasm.OpcodeGlobalGet, 2, wasm.OpcodeEnd},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, encodeElement(tc.input))
+		})
+	}
+}
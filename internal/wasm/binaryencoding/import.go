@@ -18,14 +18,14 @@ func EncodeImport(i *wasm.Import) []byte {
 	case wasm.ExternTypeFunc:
 		data = append(data, leb128.EncodeUint32(i.DescFunc)...)
 	case wasm.ExternTypeTable:
-		data = append(data, wasm.RefTypeFuncref)
+		refType := i.DescTable.Type
+		if refType == 0 { // defaults to funcref, as in WebAssembly 1.0
+			refType = wasm.RefTypeFuncref
+		}
+		data = append(data, refType)
 		data = append(data, EncodeLimitsType(i.DescTable.Min, i.DescTable.Max)...)
 	case wasm.ExternTypeMemory:
-		maxPtr := &i.DescMem.Max
-		if !i.DescMem.IsMaxEncoded {
-			maxPtr = nil
-		}
-		data = append(data, EncodeLimitsType(i.DescMem.Min, maxPtr)...)
+		data = append(data, EncodeMemory(i.DescMem)...)
 	case wasm.ExternTypeGlobal:
 		g := i.DescGlobal
 		var mutable byte
@@ -33,6 +33,9 @@ func EncodeImport(i *wasm.Import) []byte {
 			mutable = 1
 		}
 		data = append(data, g.ValType, mutable)
+	case wasm.ExternTypeTag:
+		data = append(data, 0) // exception attribute
+		data = append(data, leb128.EncodeUint32(i.DescTag)...)
 	default:
 		panic(fmt.Errorf("invalid externtype: %s", wasm.ExternTypeName(i.Type)))
 	}
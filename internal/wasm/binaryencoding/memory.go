@@ -0,0 +1,33 @@
+package binaryencoding
+
+import (
+	"github.com/AR1011/wazero/internal/wasm"
+)
+
+// EncodeMemory returns the wasm.Memory encoded in WebAssembly 1.0 (20191205) Binary Format.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#binary-memory
+func EncodeMemory(i *wasm.Memory) []byte {
+	maxPtr := &i.Max
+	if !i.IsMaxEncoded {
+		maxPtr = nil
+	}
+	return encodeMemoryLimits(i.Min, maxPtr, i.IsShared, i.IsMemory64)
+}
+
+// encodeMemoryLimits is like EncodeLimitsType, except it sets the shared
+// flag defined in the threads proposal, and the 64-bit flag defined in the
+// memory64 proposal.
+//
+// See https://webassembly.github.io/threads/core/binary/types.html#limits
+// and https://github.com/WebAssembly/memory64/blob/main/proposals/memory64/Overview.md#binary-format
+func encodeMemoryLimits(min uint32, max *uint32, shared, memory64 bool) []byte {
+	ret := EncodeLimitsType(min, max)
+	if shared {
+		ret[0] |= 0x02
+	}
+	if memory64 {
+		ret[0] |= 0x04
+	}
+	return ret
+}
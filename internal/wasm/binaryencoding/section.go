@@ -84,11 +84,27 @@ func encodeTableSection(tables []wasm.Table) []byte {
 //
 // See EncodeMemory
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-section%E2%91%A0
-func encodeMemorySection(memory *wasm.Memory) []byte {
-	contents := append([]byte{1}, EncodeMemory(memory)...)
+func encodeMemorySection(memories []wasm.Memory) []byte {
+	contents := leb128.EncodeUint32(uint32(len(memories)))
+	for i := range memories {
+		contents = append(contents, EncodeMemory(&memories[i])...)
+	}
 	return encodeSection(wasm.SectionIDMemory, contents)
 }
 
+// encodeTagSection encodes a wasm.SectionIDTag for the given tag type indexes, which is defined by the exception
+// handling proposal.
+//
+// See https://webassembly.github.io/exception-handling/core/binary/modules.html#tag-section
+func encodeTagSection(tags []wasm.Index) []byte {
+	contents := leb128.EncodeUint32(uint32(len(tags)))
+	for _, typeIndex := range tags {
+		contents = append(contents, 0) // exception attribute
+		contents = append(contents, leb128.EncodeUint32(typeIndex)...)
+	}
+	return encodeSection(wasm.SectionIDTag, contents)
+}
+
 // encodeGlobalSection encodes a wasm.SectionIDGlobal for the given globals in WebAssembly 1.0 (20191205) Binary
 // Format.
 //
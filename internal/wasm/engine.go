@@ -2,6 +2,7 @@ package wasm
 
 import (
 	"context"
+	"io"
 
 	"github.com/AR1011/wazero/api"
 	"github.com/AR1011/wazero/experimental"
@@ -34,6 +35,25 @@ type Engine interface {
 	NewModuleEngine(module *Module, instance *ModuleInstance) (ModuleEngine, error)
 }
 
+// SerializedModuleMagic is the first bytes of a serialized wazero.CompiledModule, which can't be mistaken for the
+// WebAssembly binary starting with "\x00asm".
+var SerializedModuleMagic = []byte("\x00cwasm")
+
+// EngineSerializer is implemented by an Engine whose compilation results can be persisted outside the process,
+// e.g. the compilers. The serialized form is the same as the one stored in the filecache.Cache.
+type EngineSerializer interface {
+	// SerializeCompiledModule writes the compilation result of the given module into `w`. The module must have been
+	// compiled with Engine.CompileModule before.
+	SerializeCompiledModule(module *Module, w io.Writer) error
+
+	// DeserializeCompiledModule reads the compilation result of the given module written by SerializeCompiledModule
+	// from `r`, so that the module can be instantiated without Engine.CompileModule.
+	//
+	// Note: `module` must have the same ID as the one which was serialized, and the parameters are the same as
+	// Engine.CompileModule. This returns an error if the content was written by a different version of wazero.
+	DeserializeCompiledModule(module *Module, listeners []experimental.FunctionListener, ensureTermination bool, r io.Reader) error
+}
+
 // ModuleEngine implements function calls for a given module.
 type ModuleEngine interface {
 	// DoneInstantiation is called at the end of the instantiation of the module.
@@ -58,6 +78,14 @@ type ModuleEngine interface {
 	// Only called when OwnsGlobals() returns true, and must not be called for imported globals
 	GetGlobalValue(idx Index) (lo, hi uint64)
 
+	// SetGlobalValue sets the value of the global variable at the given Index.
+	// Only called when OwnsGlobals() returns true, and must not be called for imported globals
+	SetGlobalValue(idx Index, lo, hi uint64)
+
+	// MemoryChanged is called when the Buffer of the local memory is replaced or resized out of function calls,
+	// e.g. on ModuleInstance.ResetState, so that any address or length of the memory cached by the engine is updated.
+	MemoryChanged()
+
 	// OwnsGlobals returns true if this ModuleEngine owns the global variables. If true, wasm.GlobalInstance's Val,ValHi should
 	// not be accessed directly.
 	OwnsGlobals() bool
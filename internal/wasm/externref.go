@@ -0,0 +1,104 @@
+package wasm
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/AR1011/wazero/internal/internalapi"
+)
+
+// ExternrefTable implements api.ExternrefTable.
+//
+// Externrefs are never reused, so that a released one doesn't map to an unrelated Go value.
+type ExternrefTable struct {
+	internalapi.WazeroOnlyType
+
+	mux sync.RWMutex
+	// last is the last externref assigned. Zero is the null reference.
+	last uint64
+	// values maps externrefs to their Go values.
+	values map[uint64]interface{}
+	// refs maps Go values, which can be map keys, to their externrefs.
+	refs map[interface{}]uint64
+}
+
+// NewExternrefTable returns an empty ExternrefTable.
+func NewExternrefTable() *ExternrefTable {
+	return &ExternrefTable{values: map[uint64]interface{}{}, refs: map[interface{}]uint64{}}
+}
+
+// Ref implements the same method as documented on api.ExternrefTable.
+func (t *ExternrefTable) Ref(v interface{}) uint64 {
+	if v == nil {
+		return 0
+	}
+	hashable := isHashable(reflect.TypeOf(v))
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if hashable {
+		if ref, ok := t.refs[v]; ok {
+			return ref
+		}
+	}
+	t.last++
+	ref := t.last
+	t.values[ref] = v
+	if hashable {
+		t.refs[v] = ref
+	}
+	return ref
+}
+
+// Value implements the same method as documented on api.ExternrefTable.
+func (t *ExternrefTable) Value(ref uint64) interface{} {
+	if ref == 0 {
+		return nil
+	}
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return t.values[ref]
+}
+
+// Release implements the same method as documented on api.ExternrefTable.
+func (t *ExternrefTable) Release(ref uint64) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	v, ok := t.values[ref]
+	if !ok {
+		return
+	}
+	delete(t.values, ref)
+	if isHashable(reflect.TypeOf(v)) {
+		delete(t.refs, v)
+	}
+}
+
+// Len implements the same method as documented on api.ExternrefTable.
+func (t *ExternrefTable) Len() int {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	return len(t.values)
+}
+
+// reset releases all externrefs, so that their Go values can be garbage collected.
+func (t *ExternrefTable) reset() {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.values = map[uint64]interface{}{}
+	t.refs = map[interface{}]uint64{}
+}
+
+// isHashable returns true if values of the type are equal to themselves and can be map keys without panicking. This
+// is conservative: for example, a struct isn't, as it may have an interface field holding a slice, and neither are
+// floats, as NaN isn't equal to itself.
+func isHashable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.String, reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return true
+	default:
+		return false
+	}
+}
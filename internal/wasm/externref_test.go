@@ -0,0 +1,38 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/AR1011/wazero/internal/testing/require"
+)
+
+func TestExternrefTable(t *testing.T) {
+	et := NewExternrefTable()
+	require.Equal(t, uint64(0), et.Ref(nil))
+	require.Nil(t, et.Value(0))
+
+	p := new(int)
+	ref := et.Ref(p)
+	require.Equal(t, uint64(1), ref)
+	require.Equal(t, p, et.Value(ref))
+	require.Equal(t, ref, et.Ref(p)) // same pointer, same externref.
+
+	// Values which can't be map keys get a new externref each time.
+	s := []string{"a"}
+	sRef := et.Ref(s)
+	require.Equal(t, uint64(2), sRef)
+	require.Equal(t, uint64(3), et.Ref(s))
+	require.Equal(t, s, et.Value(sRef))
+	require.Equal(t, 3, et.Len())
+
+	// Released externrefs aren't reused.
+	et.Release(ref)
+	require.Nil(t, et.Value(ref))
+	require.Equal(t, uint64(4), et.Ref(p))
+	et.Release(ref) // no-op
+	require.Equal(t, 3, et.Len())
+
+	et.reset()
+	require.Equal(t, 0, et.Len())
+	require.Nil(t, et.Value(sRef))
+}
@@ -14,25 +14,53 @@ import (
 // The wazero specific limitation described at RATIONALE.md.
 const maximumValuesOnStack = 1 << 27
 
+// FunctionError is the cause of a function failing Module.Validate, which locates the offending instruction.
+//
+// Note: The message is the same as the wrapped error, as Module.Validate adds the description of the function.
+type FunctionError struct {
+	// Index is the position of the function in the FunctionSection, which excludes imported functions.
+	Index Index
+	// Offset is the position of the offending instruction in the code section, in the same way as
+	// Code.BodyOffsetInCodeSection.
+	Offset uint64
+	err    error
+}
+
+// Error implements the same method as documented on error.
+func (e *FunctionError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error describing why the instruction is invalid.
+func (e *FunctionError) Unwrap() error {
+	return e.err
+}
+
 // validateFunction validates the instruction sequence of a function.
 // following the specification https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#instructions%E2%91%A2.
 //
 // * idx is the index in the FunctionSection
 // * functions are the function index, which is prefixed by imports. The value is the TypeSection index.
 // * globals are the global index, which is prefixed by imports.
-// * memory is the potentially imported memory and can be nil.
+// * memories are the memory index, which is prefixed by imports.
 // * table is the potentially imported table and can be nil.
 // * declaredFunctionIndexes is the set of function indexes declared by declarative element segments which can be acceed by OpcodeRefFunc instruction.
 //
 // Returns an error if the instruction sequence is not valid,
 // or potentially it can exceed the maximum number of values on the stack.
 func (m *Module) validateFunction(sts *stacks, enabledFeatures api.CoreFeatures, idx Index, functions []Index,
-	globals []GlobalType, memory *Memory, tables []Table, declaredFunctionIndexes map[Index]struct{}, br *bytes.Reader,
+	globals []GlobalType, memories []*Memory, tables []Table, declaredFunctionIndexes map[Index]struct{}, br *bytes.Reader,
 ) error {
-	return m.validateFunctionWithMaxStackValues(sts, enabledFeatures, idx, functions, globals, memory, tables, maximumValuesOnStack, declaredFunctionIndexes, br)
+	return m.validateFunctionWithMaxStackValues(sts, enabledFeatures, idx, functions, globals, memories, tables, maximumValuesOnStack, declaredFunctionIndexes, br)
 }
 
-func readMemArg(pc uint64, body []byte) (align, offset uint32, read uint64, err error) {
+// readMemArg reads the memarg immediate at pc, and returns the memory accessed by the instruction.
+//
+// When api.CoreFeatureMultiMemory is enabled, MemArgMemoryIndexFlag set in the alignment means that the memory index
+// is encoded between the alignment and the offset. Otherwise, the memory index is zero.
+//
+// The offset is encoded as u64 when the memory is 64-bit (api.CoreFeatureMemory64), and u32 otherwise.
+func readMemArg(pc uint64, body []byte, enabledFeatures api.CoreFeatures, memories []*Memory) (memory *Memory, align uint32, offset uint64, read uint64, err error) {
 	align, num, err := leb128.LoadUint32(body[pc:])
 	if err != nil {
 		err = fmt.Errorf("read memory align: %v", err)
@@ -40,14 +68,40 @@ func readMemArg(pc uint64, body []byte) (align, offset uint32, read uint64, err
 	}
 	read += num
 
-	offset, num, err = leb128.LoadUint32(body[pc+num:])
+	var memoryIndex uint32
+	if align&MemArgMemoryIndexFlag != 0 {
+		if err = enabledFeatures.RequireEnabled(api.CoreFeatureMultiMemory); err != nil {
+			err = fmt.Errorf("memory index is invalid as %w", err)
+			return
+		}
+		align &^= MemArgMemoryIndexFlag
+		memoryIndex, num, err = leb128.LoadUint32(body[pc+read:])
+		if err != nil {
+			err = fmt.Errorf("read memory index: %v", err)
+			return
+		}
+		read += num
+	}
+	if memoryIndex >= uint32(len(memories)) {
+		err = fmt.Errorf("unknown memory %d", memoryIndex)
+		return
+	}
+
+	memory = memories[memoryIndex]
+	if memory.IsMemory64 {
+		offset, num, err = leb128.LoadUint64(body[pc+read:])
+	} else {
+		var offset32 uint32
+		offset32, num, err = leb128.LoadUint32(body[pc+read:])
+		offset = uint64(offset32)
+	}
 	if err != nil {
 		err = fmt.Errorf("read memory offset: %v", err)
 		return
 	}
 
 	read += num
-	return align, offset, read, nil
+	return memory, align, offset, read, nil
 }
 
 // validateFunctionWithMaxStackValues is like validateFunction, but allows overriding maxStackValues for testing.
@@ -60,12 +114,12 @@ func (m *Module) validateFunctionWithMaxStackValues(
 	idx Index,
 	functions []Index,
 	globals []GlobalType,
-	memory *Memory,
+	memories []*Memory,
 	tables []Table,
 	maxStackValues int,
 	declaredFunctionIndexes map[Index]struct{},
 	br *bytes.Reader,
-) error {
+) (err error) {
 	functionType := &m.TypeSection[m.FunctionSection[idx]]
 	code := &m.CodeSection[idx]
 	body := code.Body
@@ -76,9 +130,18 @@ func (m *Module) validateFunctionWithMaxStackValues(
 	// We start with the outermost control block which is for function return if the code branches into it.
 	controlBlockStack := &sts.cs
 
+	// instructionStart is the position in the body of the instruction being validated, which is reported on error.
+	var instructionStart uint64
+	defer func() {
+		if err != nil {
+			err = &FunctionError{Index: idx, Offset: code.BodyOffsetInCodeSection + instructionStart, err: err}
+		}
+	}()
+
 	// Now start walking through all the instructions in the body while tracking
 	// control blocks and value types to check the validity of all instructions.
 	for pc := uint64(0); pc < uint64(len(body)); pc++ {
+		instructionStart = pc
 		op := body[pc]
 		if false {
 			var instName string
@@ -86,6 +149,8 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				instName = MiscInstructionName(body[pc+1])
 			} else if op == OpcodeVecPrefix {
 				instName = VectorInstructionName(body[pc+1])
+			} else if op == OpcodeAtomicPrefix {
+				instName = AtomicInstructionName(body[pc+1])
 			} else {
 				instName = InstructionName(op)
 			}
@@ -93,21 +158,22 @@ func (m *Module) validateFunctionWithMaxStackValues(
 		}
 
 		if OpcodeI32Load <= op && op <= OpcodeI64Store32 {
-			if memory == nil {
+			if len(memories) == 0 {
 				return fmt.Errorf("memory must exist for %s", InstructionName(op))
 			}
 			pc++
-			align, _, read, err := readMemArg(pc, body)
+			memory, align, _, read, err := readMemArg(pc, body, enabledFeatures, memories)
 			if err != nil {
 				return err
 			}
+			addressType := memory.AddressType()
 			pc += read - 1
 			switch op {
 			case OpcodeI32Load:
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -115,7 +181,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeF32)
@@ -126,7 +192,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeF32Store:
@@ -136,14 +202,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeF32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI64Load:
 				if 1<<align > 64/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -151,7 +217,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 64/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeF64)
@@ -162,7 +228,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeF64Store:
@@ -172,14 +238,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeF64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI32Load8S:
 				if 1<<align > 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -187,7 +253,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -195,7 +261,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 1 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -206,7 +272,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI64Store8:
@@ -216,14 +282,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI32Load16S, OpcodeI32Load16U:
 				if 1<<align > 16/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI32)
@@ -231,7 +297,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > 16/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -242,7 +308,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI64Store16:
@@ -252,14 +318,14 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			case OpcodeI64Load32S, OpcodeI64Load32U:
 				if 1<<align > 32/8 {
 					return fmt.Errorf("invalid memory alignment")
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 				valueTypeStack.push(ValueTypeI64)
@@ -270,12 +336,12 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
 					return err
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
 			}
 		} else if OpcodeMemorySize <= op && op <= OpcodeMemoryGrow {
-			if memory == nil {
+			if len(memories) == 0 {
 				return fmt.Errorf("memory must exist for %s", InstructionName(op))
 			}
 			pc++
@@ -283,17 +349,22 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			if err != nil {
 				return fmt.Errorf("read immediate: %v", err)
 			}
-			if val != 0 || num != 1 {
+			if enabledFeatures.IsEnabled(api.CoreFeatureMultiMemory) {
+				if val >= uint32(len(memories)) {
+					return fmt.Errorf("unknown memory %d", val)
+				}
+			} else if val != 0 || num != 1 {
 				return fmt.Errorf("memory instruction reserved bytes not zero with 1 byte")
 			}
+			addressType := memories[val].AddressType()
 			switch Opcode(op) {
 			case OpcodeMemoryGrow:
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(addressType); err != nil {
 					return err
 				}
-				valueTypeStack.push(ValueTypeI32)
+				valueTypeStack.push(addressType)
 			case OpcodeMemorySize:
-				valueTypeStack.push(ValueTypeI32)
+				valueTypeStack.push(addressType)
 			}
 			pc += num - 1
 		} else if OpcodeI32Const <= op && op <= OpcodeF64Const {
@@ -590,6 +661,73 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			for _, exp := range funcType.Results {
 				valueTypeStack.push(exp)
 			}
+		} else if op == OpcodeReturnCall {
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureTailCall); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeReturnCallName, err)
+			}
+			pc++
+			index, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			}
+			pc += num - 1
+			if int(index) >= len(functions) {
+				return fmt.Errorf("invalid function index")
+			}
+			funcType := &m.TypeSection[functions[index]]
+			if !funcType.EqualsSignature(funcType.Params, functionType.Results) {
+				return fmt.Errorf("type mismatch on %s operation: callee results must match the function results", OpcodeReturnCallName)
+			}
+			for i := 0; i < len(funcType.Params); i++ {
+				if err := valueTypeStack.popAndVerifyType(funcType.Params[len(funcType.Params)-1-i]); err != nil {
+					return fmt.Errorf("type mismatch on %s operation param type: %v", OpcodeReturnCallName, err)
+				}
+			}
+			// return_call instruction is stack-polymorphic.
+			valueTypeStack.unreachable()
+		} else if op == OpcodeReturnCallIndirect {
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureTailCall); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeReturnCallIndirectName, err)
+			}
+			pc++
+			typeIndex, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			}
+			pc += num
+
+			if int(typeIndex) >= len(m.TypeSection) {
+				return fmt.Errorf("invalid type index at %s: %d", OpcodeReturnCallIndirectName, typeIndex)
+			}
+
+			tableIndex, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read table index: %v", err)
+			}
+			pc += num - 1
+			if tableIndex >= uint32(len(tables)) {
+				return fmt.Errorf("unknown table index: %d", tableIndex)
+			}
+
+			table := tables[tableIndex]
+			if table.Type != RefTypeFuncref {
+				return fmt.Errorf("table is not funcref type but was %s for %s", RefTypeName(table.Type), OpcodeReturnCallIndirectName)
+			}
+
+			funcType := &m.TypeSection[typeIndex]
+			if !funcType.EqualsSignature(funcType.Params, functionType.Results) {
+				return fmt.Errorf("type mismatch on %s operation: callee results must match the function results", OpcodeReturnCallIndirectName)
+			}
+			if err = valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				return fmt.Errorf("cannot pop the offset in table for %s", OpcodeReturnCallIndirectName)
+			}
+			for i := 0; i < len(funcType.Params); i++ {
+				if err = valueTypeStack.popAndVerifyType(funcType.Params[len(funcType.Params)-1-i]); err != nil {
+					return fmt.Errorf("type mismatch on %s operation input type", OpcodeReturnCallIndirectName)
+				}
+			}
+			// return_call_indirect instruction is stack-polymorphic.
+			valueTypeStack.unreachable()
 		} else if OpcodeI32Eqz <= op && op <= OpcodeI64Extend32S {
 			switch op {
 			case OpcodeI32Eqz:
@@ -815,6 +953,11 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					valueTypeStack.push(ValueTypeExternref)
 				case ValueTypeFuncref:
 					valueTypeStack.push(ValueTypeFuncref)
+				case ValueTypeExnref:
+					if err := enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+						return fmt.Errorf("ref.null exnref invalid as %v", err)
+					}
+					valueTypeStack.push(ValueTypeExnref)
 				default:
 					return fmt.Errorf("unknown type for ref.null: 0x%x", reftype)
 				}
@@ -923,7 +1066,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 					}
 					pc += num - 1
 				case OpcodeMiscMemoryInit, OpcodeMiscMemoryCopy, OpcodeMiscMemoryFill:
-					if memory == nil {
+					if len(memories) == 0 {
 						return fmt.Errorf("memory must exist for %s", MiscInstructionName(miscOpcode))
 					}
 					params = []ValueType{ValueTypeI32, ValueTypeI32, ValueTypeI32}
@@ -945,24 +1088,42 @@ func (m *Module) validateFunctionWithMaxStackValues(
 						pc += num - 1
 					}
 
-					pc++
-					val, num, err := leb128.LoadUint32(body[pc:])
-					if err != nil {
-						return fmt.Errorf("failed to read memory index for %s: %v", MiscInstructionName(miscOpcode), err)
-					}
-					if val != 0 || num != 1 {
-						return fmt.Errorf("%s reserved byte must be zero encoded with 1 byte", MiscInstructionName(miscOpcode))
-					}
+					// memory.copy needs two memory indexes for the destination and the source.
+					memoryIndexNum := 1
 					if miscOpcode == OpcodeMiscMemoryCopy {
+						memoryIndexNum = 2
+					}
+					var addressTypes [2]ValueType
+					for i := 0; i < memoryIndexNum; i++ {
 						pc++
-						// memory.copy needs two memory index which are reserved as zero.
 						val, num, err := leb128.LoadUint32(body[pc:])
 						if err != nil {
 							return fmt.Errorf("failed to read memory index for %s: %v", MiscInstructionName(miscOpcode), err)
 						}
-						if val != 0 || num != 1 {
+						if enabledFeatures.IsEnabled(api.CoreFeatureMultiMemory) {
+							if val >= uint32(len(memories)) {
+								return fmt.Errorf("unknown memory %d for %s", val, MiscInstructionName(miscOpcode))
+							}
+							pc += num - 1
+						} else if val != 0 || num != 1 {
 							return fmt.Errorf("%s reserved byte must be zero encoded with 1 byte", MiscInstructionName(miscOpcode))
 						}
+						addressTypes[i] = memories[val].AddressType()
+					}
+
+					// Addresses are typed by their memory (api.CoreFeatureMemory64). Note that params are
+					// popped in order, so the last operand comes first.
+					switch miscOpcode {
+					case OpcodeMiscMemoryInit:
+						params[2] = addressTypes[0]
+					case OpcodeMiscMemoryCopy:
+						params[2], params[1] = addressTypes[0], addressTypes[1]
+						// The size is only 64-bit when both memories are.
+						if addressTypes[0] == ValueTypeI64 && addressTypes[1] == ValueTypeI64 {
+							params[0] = ValueTypeI64
+						}
+					case OpcodeMiscMemoryFill:
+						params[2], params[0] = addressTypes[0], addressTypes[0]
 					}
 
 				case OpcodeMiscTableInit:
@@ -1111,11 +1272,11 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				OpcodeVecV128Load32x2s, OpcodeVecV128Load32x2u, OpcodeVecV128Load8Splat, OpcodeVecV128Load16Splat,
 				OpcodeVecV128Load32Splat, OpcodeVecV128Load64Splat,
 				OpcodeVecV128Load32zero, OpcodeVecV128Load64zero:
-				if memory == nil {
+				if len(memories) == 0 {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				memory, align, _, read, err := readMemArg(pc, body, enabledFeatures, memories)
 				if err != nil {
 					return err
 				}
@@ -1144,16 +1305,16 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if 1<<align > maxAlign {
 					return fmt.Errorf("invalid memory alignment %d for %s", align, VectorInstructionName(vecOpcode))
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(memory.AddressType()); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", VectorInstructionName(vecOpcode), err)
 				}
 				valueTypeStack.push(ValueTypeV128)
 			case OpcodeVecV128Store:
-				if memory == nil {
+				if len(memories) == 0 {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				memory, align, _, read, err := readMemArg(pc, body, enabledFeatures, memories)
 				if err != nil {
 					return err
 				}
@@ -1164,16 +1325,16 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeVecV128StoreName, err)
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(memory.AddressType()); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeVecV128StoreName, err)
 				}
 			case OpcodeVecV128Load8Lane, OpcodeVecV128Load16Lane, OpcodeVecV128Load32Lane, OpcodeVecV128Load64Lane:
-				if memory == nil {
+				if len(memories) == 0 {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				attr := vecLoadLanes[vecOpcode]
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				memory, align, _, read, err := readMemArg(pc, body, enabledFeatures, memories)
 				if err != nil {
 					return err
 				}
@@ -1191,17 +1352,17 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(memory.AddressType()); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
 				valueTypeStack.push(ValueTypeV128)
 			case OpcodeVecV128Store8Lane, OpcodeVecV128Store16Lane, OpcodeVecV128Store32Lane, OpcodeVecV128Store64Lane:
-				if memory == nil {
+				if len(memories) == 0 {
 					return fmt.Errorf("memory must exist for %s", VectorInstructionName(vecOpcode))
 				}
 				attr := vecStoreLanes[vecOpcode]
 				pc++
-				align, _, read, err := readMemArg(pc, body)
+				memory, align, _, read, err := readMemArg(pc, body, enabledFeatures, memories)
 				if err != nil {
 					return err
 				}
@@ -1219,7 +1380,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				if err := valueTypeStack.popAndVerifyType(ValueTypeV128); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
-				if err := valueTypeStack.popAndVerifyType(ValueTypeI32); err != nil {
+				if err := valueTypeStack.popAndVerifyType(memory.AddressType()); err != nil {
 					return fmt.Errorf("cannot pop the operand for %s: %v", vectorInstructionName[vecOpcode], err)
 				}
 			case OpcodeVecI8x16ExtractLaneS,
@@ -1388,6 +1549,143 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			default:
 				return fmt.Errorf("TODO: SIMD instruction %s will be implemented in #506", vectorInstructionName[vecOpcode])
 			}
+		} else if op == OpcodeAtomicPrefix {
+			pc++
+			// Atomic instructions come with two bytes where the first byte is always OpcodeAtomicPrefix,
+			// and the second byte determines the actual instruction.
+			atomicOpcode := body[pc]
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureThreads); err != nil {
+				return fmt.Errorf("%s invalid as %v", AtomicInstructionName(atomicOpcode), err)
+			}
+			pc++
+
+			if atomicOpcode == OpcodeAtomicFence {
+				// No memory requirement and no arguments or return, however the immediate byte value must be 0.
+				if int(pc) >= len(body) {
+					return fmt.Errorf("cannot read immediate value for %s", AtomicInstructionName(atomicOpcode))
+				}
+				if imm := body[pc]; imm != 0x0 {
+					return fmt.Errorf("invalid immediate value for %s", AtomicInstructionName(atomicOpcode))
+				}
+				continue
+			}
+
+			// All atomic operations except fence (checked above) require memory
+			if len(memories) == 0 {
+				return fmt.Errorf("memory must exist for %s", AtomicInstructionName(atomicOpcode))
+			}
+			memory, align, _, read, err := readMemArg(pc, body, enabledFeatures, memories)
+			if err != nil {
+				return err
+			}
+			pc += read - 1
+
+			var natural uint32
+			var valType ValueType
+			var operandCount int
+			switch atomicOpcode {
+			case OpcodeAtomicMemoryNotify:
+				// memory.atomic.notify takes the count of waiters to wake as an i32.
+				natural, valType, operandCount = 32/8, ValueTypeI32, 1
+			case OpcodeAtomicMemoryWait32:
+				natural = 32 / 8
+				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
+					return fmt.Errorf("cannot pop the timeout for %s: %v", AtomicInstructionName(atomicOpcode), err)
+				}
+				valType, operandCount = ValueTypeI32, 1
+			case OpcodeAtomicMemoryWait64:
+				natural = 64 / 8
+				if err := valueTypeStack.popAndVerifyType(ValueTypeI64); err != nil {
+					return fmt.Errorf("cannot pop the timeout for %s: %v", AtomicInstructionName(atomicOpcode), err)
+				}
+				valType, operandCount = ValueTypeI64, 1
+			case OpcodeAtomicI32Load:
+				natural, valType = 32/8, ValueTypeI32
+			case OpcodeAtomicI64Load:
+				natural, valType = 64/8, ValueTypeI64
+			case OpcodeAtomicI32Load8U:
+				natural, valType = 1, ValueTypeI32
+			case OpcodeAtomicI32Load16U:
+				natural, valType = 16/8, ValueTypeI32
+			case OpcodeAtomicI64Load8U:
+				natural, valType = 1, ValueTypeI64
+			case OpcodeAtomicI64Load16U:
+				natural, valType = 16/8, ValueTypeI64
+			case OpcodeAtomicI64Load32U:
+				natural, valType = 32/8, ValueTypeI64
+			case OpcodeAtomicI32Store:
+				natural, valType, operandCount = 32/8, ValueTypeI32, 1
+			case OpcodeAtomicI64Store:
+				natural, valType, operandCount = 64/8, ValueTypeI64, 1
+			case OpcodeAtomicI32Store8:
+				natural, valType, operandCount = 1, ValueTypeI32, 1
+			case OpcodeAtomicI32Store16:
+				natural, valType, operandCount = 16/8, ValueTypeI32, 1
+			case OpcodeAtomicI64Store8:
+				natural, valType, operandCount = 1, ValueTypeI64, 1
+			case OpcodeAtomicI64Store16:
+				natural, valType, operandCount = 16/8, ValueTypeI64, 1
+			case OpcodeAtomicI64Store32:
+				natural, valType, operandCount = 32/8, ValueTypeI64, 1
+			case OpcodeAtomicI32RmwAdd, OpcodeAtomicI32RmwSub, OpcodeAtomicI32RmwAnd, OpcodeAtomicI32RmwOr,
+				OpcodeAtomicI32RmwXor, OpcodeAtomicI32RmwXchg:
+				natural, valType, operandCount = 32/8, ValueTypeI32, 1
+			case OpcodeAtomicI64RmwAdd, OpcodeAtomicI64RmwSub, OpcodeAtomicI64RmwAnd, OpcodeAtomicI64RmwOr,
+				OpcodeAtomicI64RmwXor, OpcodeAtomicI64RmwXchg:
+				natural, valType, operandCount = 64/8, ValueTypeI64, 1
+			case OpcodeAtomicI32Rmw8AddU, OpcodeAtomicI32Rmw8SubU, OpcodeAtomicI32Rmw8AndU, OpcodeAtomicI32Rmw8OrU,
+				OpcodeAtomicI32Rmw8XorU, OpcodeAtomicI32Rmw8XchgU:
+				natural, valType, operandCount = 1, ValueTypeI32, 1
+			case OpcodeAtomicI32Rmw16AddU, OpcodeAtomicI32Rmw16SubU, OpcodeAtomicI32Rmw16AndU, OpcodeAtomicI32Rmw16OrU,
+				OpcodeAtomicI32Rmw16XorU, OpcodeAtomicI32Rmw16XchgU:
+				natural, valType, operandCount = 16/8, ValueTypeI32, 1
+			case OpcodeAtomicI64Rmw8AddU, OpcodeAtomicI64Rmw8SubU, OpcodeAtomicI64Rmw8AndU, OpcodeAtomicI64Rmw8OrU,
+				OpcodeAtomicI64Rmw8XorU, OpcodeAtomicI64Rmw8XchgU:
+				natural, valType, operandCount = 1, ValueTypeI64, 1
+			case OpcodeAtomicI64Rmw16AddU, OpcodeAtomicI64Rmw16SubU, OpcodeAtomicI64Rmw16AndU, OpcodeAtomicI64Rmw16OrU,
+				OpcodeAtomicI64Rmw16XorU, OpcodeAtomicI64Rmw16XchgU:
+				natural, valType, operandCount = 16/8, ValueTypeI64, 1
+			case OpcodeAtomicI64Rmw32AddU, OpcodeAtomicI64Rmw32SubU, OpcodeAtomicI64Rmw32AndU, OpcodeAtomicI64Rmw32OrU,
+				OpcodeAtomicI64Rmw32XorU, OpcodeAtomicI64Rmw32XchgU:
+				natural, valType, operandCount = 32/8, ValueTypeI64, 1
+			case OpcodeAtomicI32RmwCmpxchg:
+				natural, valType, operandCount = 32/8, ValueTypeI32, 2
+			case OpcodeAtomicI64RmwCmpxchg:
+				natural, valType, operandCount = 64/8, ValueTypeI64, 2
+			case OpcodeAtomicI32Rmw8CmpxchgU:
+				natural, valType, operandCount = 1, ValueTypeI32, 2
+			case OpcodeAtomicI32Rmw16CmpxchgU:
+				natural, valType, operandCount = 16/8, ValueTypeI32, 2
+			case OpcodeAtomicI64Rmw8CmpxchgU:
+				natural, valType, operandCount = 1, ValueTypeI64, 2
+			case OpcodeAtomicI64Rmw16CmpxchgU:
+				natural, valType, operandCount = 16/8, ValueTypeI64, 2
+			case OpcodeAtomicI64Rmw32CmpxchgU:
+				natural, valType, operandCount = 32/8, ValueTypeI64, 2
+			default:
+				return fmt.Errorf("invalid atomic opcode: 0x%x", atomicOpcode)
+			}
+
+			// Unlike non-atomic memory instructions, the alignment must equal the natural alignment.
+			if 1<<align != natural {
+				return fmt.Errorf("invalid memory alignment")
+			}
+			for i := 0; i < operandCount; i++ {
+				if err := valueTypeStack.popAndVerifyType(valType); err != nil {
+					return fmt.Errorf("cannot pop the operand for %s: %v", AtomicInstructionName(atomicOpcode), err)
+				}
+			}
+			if err := valueTypeStack.popAndVerifyType(memory.AddressType()); err != nil {
+				return fmt.Errorf("cannot pop the operand for %s: %v", AtomicInstructionName(atomicOpcode), err)
+			}
+			switch atomicOpcode {
+			case OpcodeAtomicMemoryNotify, OpcodeAtomicMemoryWait32, OpcodeAtomicMemoryWait64:
+				valueTypeStack.push(ValueTypeI32)
+			case OpcodeAtomicI32Store, OpcodeAtomicI64Store, OpcodeAtomicI32Store8, OpcodeAtomicI32Store16,
+				OpcodeAtomicI64Store8, OpcodeAtomicI64Store16, OpcodeAtomicI64Store32:
+			default:
+				valueTypeStack.push(valType)
+			}
 		} else if op == OpcodeBlock {
 			br.Reset(body[pc+1:])
 			bt, num, err := DecodeBlockType(m.TypeSection, br, enabledFeatures)
@@ -1439,6 +1737,65 @@ func (m *Module) validateFunctionWithMaxStackValues(
 			}
 			valueTypeStack.pushStackLimit(len(bt.Params))
 			pc += num
+		} else if op == OpcodeTryTable {
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeTryTableName, err)
+			}
+			br.Reset(body[pc+1:])
+			bt, num, err := DecodeBlockType(m.TypeSection, br, enabledFeatures)
+			if err != nil {
+				return fmt.Errorf("read block: %w", err)
+			}
+			catches, catchesNum, err := DecodeTryTableCatches(br)
+			if err != nil {
+				return fmt.Errorf("read %s catches: %w", OpcodeTryTableName, err)
+			}
+			// Catch labels are relative to the block enclosing the try_table, so check them before pushing it.
+			for i := range catches {
+				if err = m.validateTryTableCatch(&catches[i], controlBlockStack); err != nil {
+					return fmt.Errorf("invalid %s catch[%d]: %w", OpcodeTryTableName, i, err)
+				}
+			}
+			controlBlockStack.push(pc, 0, 0, bt, num, op)
+			if err = valueTypeStack.popParams(op, bt.Params, false); err != nil {
+				return err
+			}
+			// Plus we have to push any block params again.
+			for _, p := range bt.Params {
+				valueTypeStack.push(p)
+			}
+			valueTypeStack.pushStackLimit(len(bt.Params))
+			pc += num + catchesNum
+		} else if op == OpcodeThrow {
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeThrowName, err)
+			}
+			pc++
+			index, num, err := leb128.LoadUint32(body[pc:])
+			if err != nil {
+				return fmt.Errorf("read immediate: %v", err)
+			}
+			pc += num - 1
+			tagType := m.TagType(index)
+			if tagType == nil {
+				return fmt.Errorf("unknown tag index: %d", index)
+			}
+			for i := 0; i < len(tagType.Params); i++ {
+				if err = valueTypeStack.popAndVerifyType(tagType.Params[len(tagType.Params)-1-i]); err != nil {
+					return fmt.Errorf("type mismatch on %s operation param type: %v", OpcodeThrowName, err)
+				}
+			}
+			// throw instruction is stack-polymorphic.
+			valueTypeStack.unreachable()
+		} else if op == OpcodeThrowRef {
+			if err := enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+				return fmt.Errorf("%s invalid as %v", OpcodeThrowRefName, err)
+			}
+			if err := valueTypeStack.popAndVerifyType(ValueTypeExnref); err != nil {
+				return fmt.Errorf("cannot pop the operand for %s: %v", OpcodeThrowRefName, err)
+			}
+			// throw_ref instruction is stack-polymorphic.
+			valueTypeStack.unreachable()
 		} else if op == OpcodeElse {
 			if len(controlBlockStack.stack) == 0 {
 				return fmt.Errorf("redundant Else instruction at %#x", pc)
@@ -1533,7 +1890,7 @@ func (m *Module) validateFunctionWithMaxStackValues(
 				pc++
 				tp := body[pc]
 				if tp != ValueTypeI32 && tp != ValueTypeI64 && tp != ValueTypeF32 && tp != ValueTypeF64 &&
-					tp != api.ValueTypeExternref && tp != ValueTypeFuncref && tp != ValueTypeV128 {
+					tp != api.ValueTypeExternref && tp != ValueTypeFuncref && tp != ValueTypeV128 && tp != ValueTypeExnref {
 					return fmt.Errorf("invalid type %s for %s", ValueTypeName(tp), OpcodeTypedSelectName)
 				}
 			} else if isReferenceValueType(v1) || isReferenceValueType(v2) {
@@ -1557,6 +1914,10 @@ func (m *Module) validateFunctionWithMaxStackValues(
 		}
 	}
 
+	// Errors after the last instruction are reported at the end of the function.
+	if len(body) > 0 {
+		instructionStart = uint64(len(body)) - 1
+	}
 	if len(controlBlockStack.stack) > 0 {
 		return fmt.Errorf("ill-nested block exists")
 	}
@@ -1874,6 +2235,38 @@ func (s *valueTypeStack) String() string {
 		strings.Join(typeStrs, ", "), strings.Join(limits, ","))
 }
 
+// validateTryTableCatch ensures the label of the catch clause exists in the control blocks enclosing the try_table,
+// and its types match the values pushed when the exception is caught.
+func (m *Module) validateTryTableCatch(c *TryTableCatch, controlBlockStack *controlBlockStack) error {
+	var payload []ValueType
+	if c.Kind == TryTableCatchKindCatch || c.Kind == TryTableCatchKindCatchRef {
+		tagType := m.TagType(c.Tag)
+		if tagType == nil {
+			return fmt.Errorf("unknown tag index: %d", c.Tag)
+		}
+		payload = append(payload, tagType.Params...)
+	}
+	if c.Kind == TryTableCatchKindCatchRef || c.Kind == TryTableCatchKindCatchAllRef {
+		payload = append(payload, ValueTypeExnref)
+	}
+
+	if int(c.Label) >= len(controlBlockStack.stack) {
+		return fmt.Errorf("label index out of range: %d", c.Label)
+	}
+	target := &controlBlockStack.stack[len(controlBlockStack.stack)-int(c.Label)-1]
+	targetResultType := target.blockType.Results
+	if target.op == OpcodeLoop {
+		targetResultType = target.blockType.Params
+	}
+	if !bytes.Equal(payload, targetResultType) {
+		var have, want strings.Builder
+		writeValueTypes(payload, &have)
+		writeValueTypes(targetResultType, &want)
+		return fmt.Errorf("type mismatch: caught values (%s) != label types (%s)", have.String(), want.String())
+	}
+	return nil
+}
+
 type controlBlock struct {
 	startAt, elseAt, endAt uint64
 	blockType              *FunctionType
@@ -1912,6 +2305,11 @@ func DecodeBlockType(types []FunctionType, r *bytes.Reader, enabledFeatures api.
 		ret = blockType_v_funcref
 	case -17: // 0x6f in original byte = externref
 		ret = blockType_v_externref
+	case -23: // 0x69 in original byte = exnref
+		if err = enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+			return nil, num, fmt.Errorf("block with exnref result invalid as %v", err)
+		}
+		ret = blockType_v_exnref
 	default:
 		if err = enabledFeatures.RequireEnabled(api.CoreFeatureMultiValue); err != nil {
 			return nil, num, fmt.Errorf("block with function type return invalid as %v", err)
@@ -1934,8 +2332,71 @@ var (
 	blockType_v_v128      = &FunctionType{Results: []ValueType{ValueTypeV128}, ResultNumInUint64: 2}
 	blockType_v_funcref   = &FunctionType{Results: []ValueType{ValueTypeFuncref}, ResultNumInUint64: 1}
 	blockType_v_externref = &FunctionType{Results: []ValueType{ValueTypeExternref}, ResultNumInUint64: 1}
+	blockType_v_exnref    = &FunctionType{Results: []ValueType{ValueTypeExnref}, ResultNumInUint64: 1}
 )
 
+// TryTableCatchKind is the kind of catch clause of OpcodeTryTable.
+type TryTableCatchKind = byte
+
+const (
+	// TryTableCatchKindCatch catches exceptions with the tag, and pushes their values.
+	TryTableCatchKindCatch TryTableCatchKind = 0x00
+	// TryTableCatchKindCatchRef is like TryTableCatchKindCatch, but also pushes the exnref of the exception.
+	TryTableCatchKindCatchRef TryTableCatchKind = 0x01
+	// TryTableCatchKindCatchAll catches all exceptions, and pushes nothing.
+	TryTableCatchKindCatchAll TryTableCatchKind = 0x02
+	// TryTableCatchKindCatchAllRef catches all exceptions, and pushes the exnref of the exception.
+	TryTableCatchKindCatchAllRef TryTableCatchKind = 0x03
+)
+
+// TryTableCatch is a catch clause of OpcodeTryTable, which branches to Label when an exception is caught.
+type TryTableCatch struct {
+	Kind TryTableCatchKind
+	// Tag is the tag index of the caught exceptions, which is only valid for TryTableCatchKindCatch and
+	// TryTableCatchKindCatchRef.
+	Tag Index
+	// Label is the branch target, relative to the block enclosing the try_table.
+	Label Index
+}
+
+// DecodeTryTableCatches decodes the vector of catch clauses following the block type of OpcodeTryTable, and returns
+// them with the number of bytes read.
+//
+// See https://github.com/WebAssembly/exception-handling/blob/main/proposals/exception-handling/Exceptions.md
+func DecodeTryTableCatches(r *bytes.Reader) ([]TryTableCatch, uint64, error) {
+	n, read, err := leb128.DecodeUint32(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read the number of catches: %w", err)
+	}
+
+	catches := make([]TryTableCatch, n)
+	for i := range catches {
+		c := &catches[i]
+		if c.Kind, err = r.ReadByte(); err != nil {
+			return nil, 0, fmt.Errorf("read catch kind: %w", err)
+		}
+		read++
+
+		var num uint64
+		switch c.Kind {
+		case TryTableCatchKindCatch, TryTableCatchKindCatchRef:
+			if c.Tag, num, err = leb128.DecodeUint32(r); err != nil {
+				return nil, 0, fmt.Errorf("read tag index: %w", err)
+			}
+			read += num
+		case TryTableCatchKindCatchAll, TryTableCatchKindCatchAllRef:
+		default:
+			return nil, 0, fmt.Errorf("invalid catch kind: %#x", c.Kind)
+		}
+
+		if c.Label, num, err = leb128.DecodeUint32(r); err != nil {
+			return nil, 0, fmt.Errorf("read label index: %w", err)
+		}
+		read += num
+	}
+	return catches, read, nil
+}
+
 // SplitCallStack returns the input stack resliced to the count of params and
 // results, or errors if it isn't long enough for either.
 func SplitCallStack(ft *FunctionType, stack []uint64) (params []uint64, results []uint64, err error) {
@@ -301,7 +301,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 					DataCountSection: &c,
 				}
 				err := m.validateFunction(&stacks{}, api.CoreFeatureBulkMemoryOperations,
-					0, []Index{0}, nil, &Memory{}, []Table{{}, {}}, nil, bytes.NewReader(nil))
+					0, []Index{0}, nil, []*Memory{{}}, []Table{{}, {}}, nil, bytes.NewReader(nil))
 				require.NoError(t, err)
 			})
 		}
@@ -312,7 +312,7 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			dataSection         []DataSegment
 			elementSection      []ElementSegment
 			dataCountSectionNil bool
-			memory              *Memory
+			memory              []*Memory
 			tables              []Table
 			flag                api.CoreFeatures
 			expectedErr         string
@@ -338,48 +338,48 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "failed to read data segment index for memory.init: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 100 /* data section out of range */},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "index 100 out of range of data section(len=1)",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "failed to read memory index for memory.init: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 1},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "memory.init reserved byte must be zero encoded with 1 byte",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "cannot pop the operand for memory.init: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "cannot pop the operand for memory.init: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryInit, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "cannot pop the operand for memory.init: i32 missing",
 			},
@@ -392,20 +392,20 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:                []byte{OpcodeMiscPrefix, OpcodeMiscDataDrop},
 				dataCountSectionNil: true,
-				memory:              &Memory{},
+				memory:              []*Memory{{}},
 				flag:                api.CoreFeatureBulkMemoryOperations,
 				expectedErr:         `data.drop requires data count section`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscDataDrop},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "failed to read data segment index for data.drop: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscDataDrop, 100 /* data section out of range */},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				dataSection: []DataSegment{{}},
 				expectedErr: "index 100 out of range of data section(len=1)",
 			},
@@ -424,37 +424,37 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: `failed to read memory index for memory.copy: EOF`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "failed to read memory index for memory.copy: EOF",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 1},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "memory.copy reserved byte must be zero encoded with 1 byte",
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.copy: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.copy: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.copy: i32 missing",
 			},
 			// memory.fill
@@ -472,31 +472,31 @@ func TestModule_ValidateFunction_BulkMemoryOperations(t *testing.T) {
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: `failed to read memory index for memory.fill: EOF`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill, 1},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: `memory.fill reserved byte must be zero encoded with 1 byte`,
 			},
 			{
 				body:        []byte{OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.fill: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.fill: i32 missing",
 			},
 			{
 				body:        []byte{OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0},
 				flag:        api.CoreFeatureBulkMemoryOperations,
-				memory:      &Memory{},
+				memory:      []*Memory{{}},
 				expectedErr: "cannot pop the operand for memory.fill: i32 missing",
 			},
 			// table.init
@@ -2220,7 +2220,7 @@ func TestModule_funcValidation_CallIndirect(t *testing.T) {
 			}}},
 		}
 		err := m.validateFunction(&stacks{}, api.CoreFeatureReferenceTypes,
-			0, []Index{0}, nil, &Memory{}, []Table{{Type: RefTypeFuncref}}, nil, bytes.NewReader(nil))
+			0, []Index{0}, nil, []*Memory{{}}, []Table{{Type: RefTypeFuncref}}, nil, bytes.NewReader(nil))
 		require.NoError(t, err)
 	})
 	t.Run("non zero table index", func(t *testing.T) {
@@ -2235,12 +2235,12 @@ func TestModule_funcValidation_CallIndirect(t *testing.T) {
 		}
 		t.Run("disabled", func(t *testing.T) {
 			err := m.validateFunction(&stacks{}, api.CoreFeaturesV1,
-				0, []Index{0}, nil, &Memory{}, []Table{{}, {}}, nil, bytes.NewReader(nil))
+				0, []Index{0}, nil, []*Memory{{}}, []Table{{}, {}}, nil, bytes.NewReader(nil))
 			require.EqualError(t, err, "table index must be zero but was 100: feature \"reference-types\" is disabled")
 		})
 		t.Run("enabled but out of range", func(t *testing.T) {
 			err := m.validateFunction(&stacks{}, api.CoreFeatureReferenceTypes,
-				0, []Index{0}, nil, &Memory{}, []Table{{}, {}}, nil, bytes.NewReader(nil))
+				0, []Index{0}, nil, []*Memory{{}}, []Table{{}, {}}, nil, bytes.NewReader(nil))
 			require.EqualError(t, err, "unknown table index: 100")
 		})
 	})
@@ -2255,7 +2255,7 @@ func TestModule_funcValidation_CallIndirect(t *testing.T) {
 			}}},
 		}
 		err := m.validateFunction(&stacks{}, api.CoreFeatureReferenceTypes,
-			0, []Index{0}, nil, &Memory{}, []Table{{Type: RefTypeExternref}}, nil, bytes.NewReader(nil))
+			0, []Index{0}, nil, []*Memory{{}}, []Table{{Type: RefTypeExternref}}, nil, bytes.NewReader(nil))
 		require.EqualError(t, err, "table is not funcref type but was externref for call_indirect")
 	})
 }
@@ -3177,7 +3177,7 @@ func TestModule_funcValidation_SIMD(t *testing.T) {
 				CodeSection:     []Code{{Body: tc.body}},
 			}
 			err := m.validateFunction(&stacks{}, api.CoreFeatureSIMD,
-				0, []Index{0}, nil, &Memory{}, nil, nil, bytes.NewReader(nil))
+				0, []Index{0}, nil, []*Memory{{}}, nil, nil, bytes.NewReader(nil))
 			require.NoError(t, err)
 		})
 	}
@@ -3323,12 +3323,564 @@ func TestModule_funcValidation_SIMD_error(t *testing.T) {
 				CodeSection:     []Code{{Body: tc.body}},
 			}
 			err := m.validateFunction(&stacks{}, tc.flag,
-				0, []Index{0}, nil, &Memory{}, nil, nil, bytes.NewReader(nil))
+				0, []Index{0}, nil, []*Memory{{}}, nil, nil, bytes.NewReader(nil))
 			require.EqualError(t, err, tc.expectedErr)
 		})
 	}
 }
 
+func TestModule_funcValidation_Atomic(t *testing.T) {
+	t.Run("valid bytecode", func(t *testing.T) {
+		tests := []struct {
+			name               string
+			body               []byte
+			noDropBeforeReturn bool
+		}{
+			{
+				name: "i32.atomic.load",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeAtomicPrefix, OpcodeAtomicI32Load, 0x2, 0x8, // alignment=2 (natural alignment) staticOffset=8
+				},
+			},
+			{
+				name: "i64.atomic.load8_u",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeAtomicPrefix, OpcodeAtomicI64Load8U, 0x0, 0x8, // alignment=0 (natural alignment) staticOffset=8
+				},
+			},
+			{
+				name: "i64.atomic.store32",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeI64Const, 0x1,
+					OpcodeAtomicPrefix, OpcodeAtomicI64Store32, 0x2, 0x8, // alignment=2 (natural alignment) staticOffset=8
+				},
+				noDropBeforeReturn: true,
+			},
+			{
+				name: "i32.atomic.rmw16.add_u",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeI32Const, 0x1,
+					OpcodeAtomicPrefix, OpcodeAtomicI32Rmw16AddU, 0x1, 0x8, // alignment=1 (natural alignment) staticOffset=8
+				},
+			},
+			{
+				name: "i64.atomic.rmw.cmpxchg",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeI64Const, 0x1,
+					OpcodeI64Const, 0x2,
+					OpcodeAtomicPrefix, OpcodeAtomicI64RmwCmpxchg, 0x3, 0x8, // alignment=3 (natural alignment) staticOffset=8
+				},
+			},
+			{
+				name: "memory.atomic.wait32",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeI32Const, 0x1,
+					OpcodeI64Const, 0x2,
+					OpcodeAtomicPrefix, OpcodeAtomicMemoryWait32, 0x2, 0x8, // alignment=2 (natural alignment) staticOffset=8
+				},
+			},
+			{
+				name: "memory.atomic.wait64",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeI64Const, 0x1,
+					OpcodeI64Const, 0x2,
+					OpcodeAtomicPrefix, OpcodeAtomicMemoryWait64, 0x3, 0x8, // alignment=3 (natural alignment) staticOffset=8
+				},
+			},
+			{
+				name: "memory.atomic.notify",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeI32Const, 0x1,
+					OpcodeAtomicPrefix, OpcodeAtomicMemoryNotify, 0x2, 0x8, // alignment=2 (natural alignment) staticOffset=8
+				},
+			},
+			{
+				name: "atomic.fence",
+				body: []byte{
+					OpcodeAtomicPrefix, OpcodeAtomicFence, 0x0,
+				},
+				noDropBeforeReturn: true,
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				body := append([]byte{}, tc.body...)
+				if !tc.noDropBeforeReturn {
+					body = append(body, OpcodeDrop)
+				}
+				body = append(body, OpcodeEnd)
+				m := &Module{
+					TypeSection:     []FunctionType{v_v},
+					FunctionSection: []Index{0},
+					CodeSection:     []Code{{Body: body}},
+				}
+				err := m.validateFunction(&stacks{}, api.CoreFeaturesV2|api.CoreFeatureThreads,
+					0, []Index{0}, nil, []*Memory{{}}, nil, nil, bytes.NewReader(nil))
+				require.NoError(t, err)
+			})
+		}
+	})
+
+	t.Run("atomic.fence without memory", func(t *testing.T) {
+		m := &Module{
+			TypeSection:     []FunctionType{v_v},
+			FunctionSection: []Index{0},
+			CodeSection:     []Code{{Body: []byte{OpcodeAtomicPrefix, OpcodeAtomicFence, 0x0, OpcodeEnd}}},
+		}
+		err := m.validateFunction(&stacks{}, api.CoreFeaturesV2|api.CoreFeatureThreads,
+			0, []Index{0}, nil, nil, nil, nil, bytes.NewReader(nil))
+		require.NoError(t, err)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			body        []byte
+			flag        api.CoreFeatures
+			noMemory    bool
+			expectedErr string
+		}{
+			{
+				name: "threads disabled",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeAtomicPrefix, OpcodeAtomicI32Load, 0x2, 0x8,
+				},
+				flag:        api.CoreFeaturesV2,
+				expectedErr: "i32.atomic.load invalid as feature \"threads\" is disabled",
+			},
+			{
+				name: "no memory",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeAtomicPrefix, OpcodeAtomicI32Load, 0x2, 0x8,
+				},
+				flag:        api.CoreFeatureThreads,
+				noMemory:    true,
+				expectedErr: "memory must exist for i32.atomic.load",
+			},
+			{
+				name: "alignment smaller than natural",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeAtomicPrefix, OpcodeAtomicI32Load, 0x1, 0x8,
+				},
+				flag:        api.CoreFeatureThreads,
+				expectedErr: "invalid memory alignment",
+			},
+			{
+				name: "alignment larger than natural",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeAtomicPrefix, OpcodeAtomicI32Load8U, 0x1, 0x8,
+				},
+				flag:        api.CoreFeatureThreads,
+				expectedErr: "invalid memory alignment",
+			},
+			{
+				name: "operand type mismatch",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeI32Const, 0x1,
+					OpcodeAtomicPrefix, OpcodeAtomicI64RmwAdd, 0x3, 0x8,
+				},
+				flag:        api.CoreFeatureThreads,
+				expectedErr: "cannot pop the operand for i64.atomic.rmw.add: type mismatch: expected i64, but was i32",
+			},
+			{
+				name: "wait timeout missing",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeI32Const, 0x1,
+					OpcodeAtomicPrefix, OpcodeAtomicMemoryWait32, 0x2, 0x8,
+				},
+				flag:        api.CoreFeatureThreads,
+				expectedErr: "cannot pop the timeout for memory.atomic.wait32: type mismatch: expected i64, but was i32",
+			},
+			{
+				name: "fence non-zero immediate",
+				body: []byte{
+					OpcodeAtomicPrefix, OpcodeAtomicFence, 0x1,
+				},
+				flag:        api.CoreFeatureThreads,
+				expectedErr: "invalid immediate value for atomic.fence",
+			},
+			{
+				name: "invalid opcode",
+				body: []byte{
+					OpcodeI32Const, 0x0,
+					OpcodeAtomicPrefix, 0x4f, 0x2, 0x8,
+				},
+				flag:        api.CoreFeatureThreads,
+				expectedErr: "invalid atomic opcode: 0x4f",
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				m := &Module{
+					TypeSection:     []FunctionType{v_v},
+					FunctionSection: []Index{0},
+					CodeSection:     []Code{{Body: append(tc.body, OpcodeDrop, OpcodeEnd)}},
+				}
+				var mem []*Memory
+				if !tc.noMemory {
+					mem = []*Memory{{}}
+				}
+				err := m.validateFunction(&stacks{}, tc.flag,
+					0, []Index{0}, nil, mem, nil, nil, bytes.NewReader(nil))
+				require.EqualError(t, err, tc.expectedErr)
+			})
+		}
+	})
+}
+
+func TestModule_funcValidation_TailCall(t *testing.T) {
+	// Function 0 is the function being validated of type i32_i32, function 1 is of type v_v and function 2 is of
+	// type i32_i32 as well.
+	functions := []Index{0, 1, 0}
+	tables := []Table{{Type: RefTypeFuncref}}
+
+	t.Run("valid bytecode", func(t *testing.T) {
+		tests := []struct {
+			name string
+			body []byte
+		}{
+			{
+				name: "return_call",
+				body: []byte{OpcodeLocalGet, 0, OpcodeReturnCall, 2, OpcodeEnd},
+			},
+			{
+				name: "return_call followed by unreachable code",
+				body: []byte{OpcodeLocalGet, 0, OpcodeReturnCall, 2, OpcodeDrop, OpcodeEnd},
+			},
+			{
+				name: "return_call_indirect",
+				body: []byte{OpcodeLocalGet, 0, OpcodeI32Const, 0, OpcodeReturnCallIndirect, 0, 0, OpcodeEnd},
+			},
+			{
+				name: "return_call in block",
+				body: []byte{
+					OpcodeBlock, 0x40,
+					OpcodeLocalGet, 0, OpcodeReturnCall, 0,
+					OpcodeEnd,
+					OpcodeI32Const, 0,
+					OpcodeEnd,
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				m := &Module{
+					TypeSection:     []FunctionType{i32_i32, v_v},
+					FunctionSection: []Index{0},
+					CodeSection:     []Code{{Body: tc.body}},
+				}
+				err := m.validateFunction(&stacks{}, api.CoreFeaturesV2|api.CoreFeatureTailCall,
+					0, functions, nil, nil, tables, nil, bytes.NewReader(nil))
+				require.NoError(t, err)
+			})
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			body        []byte
+			flag        api.CoreFeatures
+			expectedErr string
+		}{
+			{
+				name:        "tail-call disabled",
+				body:        []byte{OpcodeLocalGet, 0, OpcodeReturnCall, 2, OpcodeEnd},
+				flag:        api.CoreFeaturesV2,
+				expectedErr: "return_call invalid as feature \"tail-call\" is disabled",
+			},
+			{
+				name:        "tail-call disabled indirect",
+				body:        []byte{OpcodeLocalGet, 0, OpcodeI32Const, 0, OpcodeReturnCallIndirect, 0, 0, OpcodeEnd},
+				flag:        api.CoreFeaturesV2,
+				expectedErr: "return_call_indirect invalid as feature \"tail-call\" is disabled",
+			},
+			{
+				name:        "invalid function index",
+				body:        []byte{OpcodeLocalGet, 0, OpcodeReturnCall, 3, OpcodeEnd},
+				flag:        api.CoreFeatureTailCall,
+				expectedErr: "invalid function index",
+			},
+			{
+				name:        "results mismatch",
+				body:        []byte{OpcodeReturnCall, 1, OpcodeEnd},
+				flag:        api.CoreFeatureTailCall,
+				expectedErr: "type mismatch on return_call operation: callee results must match the function results",
+			},
+			{
+				name:        "results mismatch indirect",
+				body:        []byte{OpcodeI32Const, 0, OpcodeReturnCallIndirect, 1, 0, OpcodeEnd},
+				flag:        api.CoreFeatureTailCall,
+				expectedErr: "type mismatch on return_call_indirect operation: callee results must match the function results",
+			},
+			{
+				name:        "param type mismatch",
+				body:        []byte{OpcodeI64Const, 0, OpcodeReturnCall, 2, OpcodeEnd},
+				flag:        api.CoreFeatureTailCall,
+				expectedErr: "type mismatch on return_call operation param type: type mismatch: expected i32, but was i64",
+			},
+			{
+				name:        "unknown table",
+				body:        []byte{OpcodeLocalGet, 0, OpcodeI32Const, 0, OpcodeReturnCallIndirect, 0, 1, OpcodeEnd},
+				flag:        api.CoreFeatureTailCall,
+				expectedErr: "unknown table index: 1",
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				m := &Module{
+					TypeSection:     []FunctionType{i32_i32, v_v},
+					FunctionSection: []Index{0},
+					CodeSection:     []Code{{Body: tc.body}},
+				}
+				err := m.validateFunction(&stacks{}, tc.flag,
+					0, functions, nil, nil, tables, nil, bytes.NewReader(nil))
+				require.EqualError(t, err, tc.expectedErr)
+			})
+		}
+	})
+}
+
+func TestModule_funcValidation_MultiMemory(t *testing.T) {
+	memories := []*Memory{{Min: 1}, {Min: 1}}
+
+	t.Run("valid bytecode", func(t *testing.T) {
+		tests := []struct {
+			name string
+			body []byte
+		}{
+			{
+				name: "i32.load on memory 1",
+				body: []byte{OpcodeI32Const, 0, OpcodeI32Load, 0x2 | byte(MemArgMemoryIndexFlag), 1, 0, OpcodeDrop, OpcodeEnd},
+			},
+			{
+				name: "i64.store on memory 0 with explicit index",
+				body: []byte{OpcodeI32Const, 0, OpcodeI64Const, 0, OpcodeI64Store, 0x3 | byte(MemArgMemoryIndexFlag), 0, 8, OpcodeEnd},
+			},
+			{
+				name: "memory.size and memory.grow on memory 1",
+				body: []byte{OpcodeMemorySize, 1, OpcodeMemoryGrow, 1, OpcodeDrop, OpcodeEnd},
+			},
+			{
+				name: "memory.copy between memories",
+				body: []byte{
+					OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeI32Const, 0,
+					OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 1, 0,
+					OpcodeEnd,
+				},
+			},
+			{
+				name: "memory.fill on memory 1",
+				body: []byte{
+					OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeI32Const, 0,
+					OpcodeMiscPrefix, OpcodeMiscMemoryFill, 1,
+					OpcodeEnd,
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				m := &Module{
+					TypeSection:     []FunctionType{v_v},
+					FunctionSection: []Index{0},
+					CodeSection:     []Code{{Body: tc.body}},
+				}
+				err := m.validateFunction(&stacks{}, api.CoreFeaturesV2|api.CoreFeatureMultiMemory,
+					0, []Index{0}, nil, memories, nil, nil, bytes.NewReader(nil))
+				require.NoError(t, err)
+			})
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			body        []byte
+			flag        api.CoreFeatures
+			expectedErr string
+		}{
+			{
+				name:        "memory index on load with multi-memory disabled",
+				body:        []byte{OpcodeI32Const, 0, OpcodeI32Load, 0x2 | byte(MemArgMemoryIndexFlag), 1, 0, OpcodeDrop, OpcodeEnd},
+				flag:        api.CoreFeaturesV2,
+				expectedErr: `memory index is invalid as feature "multi-memory" is disabled`,
+			},
+			{
+				name:        "memory.size with multi-memory disabled",
+				body:        []byte{OpcodeMemorySize, 1, OpcodeDrop, OpcodeEnd},
+				flag:        api.CoreFeaturesV2,
+				expectedErr: "memory instruction reserved bytes not zero with 1 byte",
+			},
+			{
+				name:        "unknown memory on load",
+				body:        []byte{OpcodeI32Const, 0, OpcodeI32Load, 0x2 | byte(MemArgMemoryIndexFlag), 2, 0, OpcodeDrop, OpcodeEnd},
+				flag:        api.CoreFeaturesV2 | api.CoreFeatureMultiMemory,
+				expectedErr: "unknown memory 2",
+			},
+			{
+				name:        "unknown memory on memory.grow",
+				body:        []byte{OpcodeI32Const, 0, OpcodeMemoryGrow, 2, OpcodeDrop, OpcodeEnd},
+				flag:        api.CoreFeaturesV2 | api.CoreFeatureMultiMemory,
+				expectedErr: "unknown memory 2",
+			},
+			{
+				name: "unknown memory on memory.copy",
+				body: []byte{
+					OpcodeI32Const, 0, OpcodeI32Const, 0, OpcodeI32Const, 0,
+					OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 2,
+					OpcodeEnd,
+				},
+				flag:        api.CoreFeaturesV2 | api.CoreFeatureMultiMemory,
+				expectedErr: "unknown memory 2 for memory.copy",
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				m := &Module{
+					TypeSection:     []FunctionType{v_v},
+					FunctionSection: []Index{0},
+					CodeSection:     []Code{{Body: tc.body}},
+				}
+				err := m.validateFunction(&stacks{}, tc.flag,
+					0, []Index{0}, nil, memories, nil, nil, bytes.NewReader(nil))
+				require.EqualError(t, err, tc.expectedErr)
+			})
+		}
+	})
+}
+
+func TestModule_funcValidation_Memory64(t *testing.T) {
+	memories := []*Memory{{Min: 1, IsMemory64: true}, {Min: 1}}
+
+	t.Run("valid bytecode", func(t *testing.T) {
+		tests := []struct {
+			name string
+			body []byte
+		}{
+			{
+				name: "i32.load with i64 address",
+				body: []byte{OpcodeI64Const, 0, OpcodeI32Load, 0x2, 0, OpcodeDrop, OpcodeEnd},
+			},
+			{
+				name: "i64.store with offset above 4GiB",
+				body: []byte{OpcodeI64Const, 0, OpcodeI64Const, 0, OpcodeI64Store, 0x3, 0x80, 0x80, 0x80, 0x80, 0x10, OpcodeEnd},
+			},
+			{
+				name: "memory.size and memory.grow are i64",
+				body: []byte{OpcodeMemorySize, 0, OpcodeMemoryGrow, 0, OpcodeI64Eqz, OpcodeDrop, OpcodeEnd},
+			},
+			{
+				name: "memory.fill",
+				body: []byte{
+					OpcodeI64Const, 0, OpcodeI32Const, 0, OpcodeI64Const, 0,
+					OpcodeMiscPrefix, OpcodeMiscMemoryFill, 0,
+					OpcodeEnd,
+				},
+			},
+			{
+				name: "memory.copy from 32-bit memory",
+				body: []byte{
+					OpcodeI64Const, 0, OpcodeI32Const, 0, OpcodeI32Const, 0,
+					OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 1,
+					OpcodeEnd,
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				m := &Module{
+					TypeSection:     []FunctionType{v_v},
+					FunctionSection: []Index{0},
+					CodeSection:     []Code{{Body: tc.body}},
+				}
+				err := m.validateFunction(&stacks{}, api.CoreFeaturesV2|api.CoreFeatureMultiMemory|api.CoreFeatureMemory64,
+					0, []Index{0}, nil, memories, nil, nil, bytes.NewReader(nil))
+				require.NoError(t, err)
+			})
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			body        []byte
+			expectedErr string
+		}{
+			{
+				name:        "i32 address",
+				body:        []byte{OpcodeI32Const, 0, OpcodeI32Load, 0x2, 0, OpcodeDrop, OpcodeEnd},
+				expectedErr: "type mismatch: expected i64, but was i32",
+			},
+			{
+				name:        "memory.grow with i32 delta",
+				body:        []byte{OpcodeI32Const, 0, OpcodeMemoryGrow, 0, OpcodeDrop, OpcodeEnd},
+				expectedErr: "type mismatch: expected i64, but was i32",
+			},
+			{
+				name:        "memory.size result as i32",
+				body:        []byte{OpcodeMemorySize, 0, OpcodeI32Eqz, OpcodeDrop, OpcodeEnd},
+				expectedErr: "cannot pop the operand for i32.eqz: type mismatch: expected i32, but was i64",
+			},
+			{
+				name: "memory.copy with i64 size from 32-bit memory",
+				body: []byte{
+					OpcodeI64Const, 0, OpcodeI32Const, 0, OpcodeI64Const, 0,
+					OpcodeMiscPrefix, OpcodeMiscMemoryCopy, 0, 1,
+					OpcodeEnd,
+				},
+				expectedErr: "cannot pop the operand for memory.copy: type mismatch: expected i32, but was i64",
+			},
+			{
+				name:        "offset above 4GiB on 32-bit memory",
+				body:        []byte{OpcodeI32Const, 0, OpcodeI32Load, 0x2 | byte(MemArgMemoryIndexFlag), 1, 0x80, 0x80, 0x80, 0x80, 0x10, OpcodeDrop, OpcodeEnd},
+				expectedErr: "read memory offset: overflows a 32-bit integer",
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				m := &Module{
+					TypeSection:     []FunctionType{v_v},
+					FunctionSection: []Index{0},
+					CodeSection:     []Code{{Body: tc.body}},
+				}
+				err := m.validateFunction(&stacks{}, api.CoreFeaturesV2|api.CoreFeatureMultiMemory|api.CoreFeatureMemory64,
+					0, []Index{0}, nil, memories, nil, nil, bytes.NewReader(nil))
+				require.EqualError(t, err, tc.expectedErr)
+			})
+		}
+	})
+}
+
 func TestDecodeBlockType(t *testing.T) {
 	t.Run("primitive", func(t *testing.T) {
 		for _, tc := range []struct {
@@ -3678,3 +4230,134 @@ func Test_SplitCallStack(t *testing.T) {
 		})
 	}
 }
+
+func TestModule_funcValidation_ExceptionHandling(t *testing.T) {
+	// The tag 0 carries an i32.
+	m := &Module{
+		TypeSection:     []FunctionType{v_v, i32_v, v_i32},
+		TagSection:      []Index{1},
+		FunctionSection: []Index{0},
+	}
+
+	t.Run("valid bytecode", func(t *testing.T) {
+		tests := []struct {
+			name string
+			body []byte
+		}{
+			{
+				name: "throw",
+				body: []byte{OpcodeI32Const, 1, OpcodeThrow, 0, OpcodeEnd},
+			},
+			{
+				name: "throw followed by unreachable code",
+				body: []byte{OpcodeI32Const, 1, OpcodeThrow, 0, OpcodeDrop, OpcodeEnd},
+			},
+			{
+				name: "try_table with catch",
+				body: []byte{
+					OpcodeBlock, ValueTypeI32,
+					OpcodeTryTable, 0x40, 1, TryTableCatchKindCatch, 0, 0,
+					OpcodeEnd,
+					OpcodeI32Const, 0,
+					OpcodeEnd,
+					OpcodeDrop,
+					OpcodeEnd,
+				},
+			},
+			{
+				name: "try_table with catch_all_ref and throw_ref",
+				body: []byte{
+					OpcodeBlock, ValueTypeExnref,
+					OpcodeTryTable, 0x40, 1, TryTableCatchKindCatchAllRef, 0,
+					OpcodeEnd,
+					OpcodeReturn,
+					OpcodeEnd,
+					OpcodeThrowRef,
+					OpcodeEnd,
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				m.CodeSection = []Code{{Body: tc.body}}
+				err := m.validateFunction(&stacks{}, api.CoreFeaturesV2|api.CoreFeatureExceptionHandling,
+					0, []Index{0}, nil, nil, nil, nil, bytes.NewReader(nil))
+				require.NoError(t, err)
+			})
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			name        string
+			body        []byte
+			flag        api.CoreFeatures
+			expectedErr string
+		}{
+			{
+				name:        "exception handling disabled",
+				body:        []byte{OpcodeI32Const, 1, OpcodeThrow, 0, OpcodeEnd},
+				flag:        api.CoreFeaturesV2,
+				expectedErr: "throw invalid as feature \"exception-handling\" is disabled",
+			},
+			{
+				name:        "unknown tag",
+				body:        []byte{OpcodeI32Const, 1, OpcodeThrow, 1, OpcodeEnd},
+				flag:        api.CoreFeatureExceptionHandling,
+				expectedErr: "unknown tag index: 1",
+			},
+			{
+				name:        "throw param type mismatch",
+				body:        []byte{OpcodeI64Const, 1, OpcodeThrow, 0, OpcodeEnd},
+				flag:        api.CoreFeatureExceptionHandling,
+				expectedErr: "type mismatch on throw operation param type: type mismatch: expected i32, but was i64",
+			},
+			{
+				name:        "throw_ref without exnref",
+				body:        []byte{OpcodeI32Const, 1, OpcodeThrowRef, OpcodeEnd},
+				flag:        api.CoreFeatureExceptionHandling,
+				expectedErr: "cannot pop the operand for throw_ref: type mismatch: expected exnref, but was i32",
+			},
+			{
+				name: "catch label type mismatch",
+				body: []byte{
+					OpcodeBlock, 0x40,
+					OpcodeTryTable, 0x40, 1, TryTableCatchKindCatch, 0, 0,
+					OpcodeEnd,
+					OpcodeEnd,
+					OpcodeEnd,
+				},
+				flag:        api.CoreFeatureExceptionHandling,
+				expectedErr: "invalid try_table catch[0]: type mismatch: caught values (i32) != label types ()",
+			},
+			{
+				name: "catch label out of range",
+				body: []byte{
+					OpcodeTryTable, 0x40, 1, TryTableCatchKindCatchAll, 2,
+					OpcodeEnd,
+					OpcodeEnd,
+				},
+				flag:        api.CoreFeatureExceptionHandling,
+				expectedErr: "invalid try_table catch[0]: label index out of range: 2",
+			},
+			{
+				name:        "invalid catch kind",
+				body:        []byte{OpcodeTryTable, 0x40, 1, 4, 0, OpcodeEnd, OpcodeEnd},
+				flag:        api.CoreFeatureExceptionHandling,
+				expectedErr: "read try_table catches: invalid catch kind: 0x4",
+			},
+		}
+
+		for _, tt := range tests {
+			tc := tt
+			t.Run(tc.name, func(t *testing.T) {
+				m.CodeSection = []Code{{Body: tc.body}}
+				err := m.validateFunction(&stacks{}, tc.flag,
+					0, []Index{0}, nil, nil, nil, nil, bytes.NewReader(nil))
+				require.EqualError(t, err, tc.expectedErr)
+			})
+		}
+	})
+}
@@ -51,5 +51,10 @@ func (g mutableGlobal) String() string {
 
 // Set implements the same method as documented on api.MutableGlobal.
 func (g mutableGlobal) Set(v uint64) {
-	g.g.Val = v
+	if me := g.g.Me; me != nil {
+		_, hi := me.GetGlobalValue(g.g.Index)
+		me.SetGlobalValue(g.g.Index, v, hi)
+	} else {
+		g.g.Val = v
+	}
 }
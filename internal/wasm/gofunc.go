@@ -31,14 +31,32 @@ var (
 // api.GoModuleFunction.
 var _ api.GoModuleFunction = (*reflectGoModuleFunction)(nil)
 
+// reflectGoModuleFunction is used when the function has an api.Module parameter, or any externref parameter or result
+// of type interface{}, which are converted with the ExternrefTable of the calling module.
 type reflectGoModuleFunction struct {
 	fn              *reflect.Value
+	pk              paramsKind
 	params, results []ValueType
 }
 
 // Call implements the same method as documented on api.GoModuleFunction.
 func (f *reflectGoModuleFunction) Call(ctx context.Context, mod api.Module, stack []uint64) {
-	callGoFunc(ctx, mod, f.fn, stack)
+	externrefs := externrefTableOf(mod)
+	switch f.pk {
+	case paramsKindNoContext:
+		ctx, mod = nil, nil
+	case paramsKindContext:
+		mod = nil
+	}
+	callGoFunc(ctx, mod, externrefs, f.fn, stack)
+}
+
+// externrefTableOf returns the ExternrefTable of the store the module belongs to, or nil if unknown.
+func externrefTableOf(mod api.Module) *ExternrefTable {
+	if m, ok := mod.(*ModuleInstance); ok && m.s != nil {
+		return m.s.Externrefs
+	}
+	return nil
 }
 
 // EqualTo is exposed for testing.
@@ -47,7 +65,8 @@ func (f *reflectGoModuleFunction) EqualTo(that interface{}) bool {
 		return false
 	} else {
 		// TODO compare reflect pointers
-		return bytes.Equal(f.params, f2.params) && bytes.Equal(f.results, f2.results)
+		return f.pk == f2.pk &&
+			bytes.Equal(f.params, f2.params) && bytes.Equal(f.results, f2.results)
 	}
 }
 
@@ -76,12 +95,14 @@ func (f *reflectGoFunction) Call(ctx context.Context, stack []uint64) {
 	if f.pk == paramsKindNoContext {
 		ctx = nil
 	}
-	callGoFunc(ctx, nil, f.fn, stack)
+	callGoFunc(ctx, nil, nil, f.fn, stack)
 }
 
 // callGoFunc executes the reflective function by converting params to Go
 // types. The results of the function call are converted back to api.ValueType.
-func callGoFunc(ctx context.Context, mod api.Module, fn *reflect.Value, stack []uint64) {
+//
+// externrefs is only used for params or results of type interface{}.
+func callGoFunc(ctx context.Context, mod api.Module, externrefs *ExternrefTable, fn *reflect.Value, stack []uint64) {
 	tp := fn.Type()
 
 	var in []reflect.Value
@@ -115,6 +136,12 @@ func callGoFunc(ctx context.Context, mod api.Module, fn *reflect.Value, stack []
 				val.SetUint(raw)
 			case reflect.Int32, reflect.Int64:
 				val.SetInt(int64(raw))
+			case reflect.Interface:
+				if raw != 0 && externrefs != nil {
+					if v := externrefs.Value(raw); v != nil {
+						val.Set(reflect.ValueOf(v))
+					}
+				}
 			default:
 				panic(fmt.Errorf("BUG: param[%d] has an invalid type: %v", i, k))
 			}
@@ -133,6 +160,14 @@ func callGoFunc(ctx context.Context, mod api.Module, fn *reflect.Value, stack []
 			stack[i] = ret.Uint()
 		case reflect.Int32, reflect.Int64:
 			stack[i] = uint64(ret.Int())
+		case reflect.Interface:
+			if ret.IsNil() {
+				stack[i] = 0
+			} else if externrefs == nil {
+				panic(fmt.Errorf("result[%d] is an externref, but the module has no externref table", i))
+			} else {
+				stack[i] = externrefs.Ref(ret.Interface())
+			}
 		default:
 			panic(fmt.Errorf("BUG: result[%d] has an invalid type: %v", i, ret.Kind()))
 		}
@@ -187,14 +222,18 @@ func parseGoReflectFunc(fn interface{}) (params, results []ValueType, code Code,
 		pOffset = 2
 	}
 
+	// usesExternrefs is true when a param or result is converted with ExternrefTable.
+	var usesExternrefs bool
+
 	pCount := p.NumIn() - pOffset
 	if pCount > 0 {
 		params = make([]ValueType, pCount)
 	}
 	for i := 0; i < len(params); i++ {
 		pI := p.In(i + pOffset)
-		if t, ok := getTypeOf(pI.Kind()); ok {
+		if t, ok := getTypeOf(pI); ok {
 			params[i] = t
+			usesExternrefs = usesExternrefs || pI.Kind() == reflect.Interface
 			continue
 		}
 
@@ -220,8 +259,9 @@ func parseGoReflectFunc(fn interface{}) (params, results []ValueType, code Code,
 	}
 	for i := 0; i < len(results); i++ {
 		rI := p.Out(i)
-		if t, ok := getTypeOf(rI.Kind()); ok {
+		if t, ok := getTypeOf(rI); ok {
 			results[i] = t
+			usesExternrefs = usesExternrefs || rI.Kind() == reflect.Interface
 			continue
 		}
 
@@ -235,8 +275,8 @@ func parseGoReflectFunc(fn interface{}) (params, results []ValueType, code Code,
 	}
 
 	code = Code{}
-	if pk == paramsKindContextModule {
-		code.GoFunc = &reflectGoModuleFunction{fn: &fnV, params: params, results: results}
+	if pk == paramsKindContextModule || usesExternrefs {
+		code.GoFunc = &reflectGoModuleFunction{fn: &fnV, pk: pk, params: params, results: results}
 	} else {
 		code.GoFunc = &reflectGoFunction{pk: pk, fn: &fnV, params: params, results: results}
 	}
@@ -261,8 +301,8 @@ func kind(p reflect.Type) (paramsKind, error) {
 	return paramsKindNoContext, nil
 }
 
-func getTypeOf(kind reflect.Kind) (ValueType, bool) {
-	switch kind {
+func getTypeOf(t reflect.Type) (ValueType, bool) {
+	switch t.Kind() {
 	case reflect.Float64:
 		return ValueTypeF64, true
 	case reflect.Float32:
@@ -273,6 +313,12 @@ func getTypeOf(kind reflect.Kind) (ValueType, bool) {
 		return ValueTypeI64, true
 	case reflect.Uintptr:
 		return ValueTypeExternref, true
+	case reflect.Interface:
+		// Only interface{}, as other interfaces, such as error, can't represent any Go value.
+		if t.NumMethod() == 0 {
+			return ValueTypeExternref, true
+		}
+		return 0x00, false
 	default:
 		return 0x00, false
 	}
@@ -52,6 +52,12 @@ func Test_parseGoFunc(t *testing.T) {
 			expectNeedsModule: true,
 			expectedType:      &FunctionType{Params: []ValueType{i32, i64, f32, f64, externref}, Results: []ValueType{i32}},
 		},
+		{
+			name:              "interface{} param and result - (ctx)",
+			input:             func(context.Context, interface{}, uint32) interface{} { return nil },
+			expectNeedsModule: true, // to convert with the ExternrefTable.
+			expectedType:      &FunctionType{Params: []ValueType{externref, i32}, Results: []ValueType{externref}},
+		},
 	}
 	for _, tt := range tests {
 		tc := tt
@@ -97,6 +103,11 @@ func Test_parseGoFunc_Errors(t *testing.T) {
 			input:       func() error { return nil },
 			expectedErr: "result[0] is an error, which is unsupported",
 		},
+		{
+			name:        "interface param other than interface{}",
+			input:       func(error) {},
+			expectedErr: "param[0] is unsupported: interface",
+		},
 		{
 			name:        "incorrect order",
 			input:       func(api.Module, context.Context) error { return nil },
@@ -243,3 +254,31 @@ func Test_callGoFunc(t *testing.T) {
 		})
 	}
 }
+
+func Test_callGoFunc_externref(t *testing.T) {
+	s := NewStore(api.CoreFeaturesV2, nil)
+	inst := &ModuleInstance{s: s}
+	type conn struct{ name string }
+	c := &conn{name: "db"}
+
+	open := MustParseGoReflectFuncCode(func() interface{} { return c }).GoFunc.(api.GoModuleFunction)
+	stack := []uint64{0}
+	open.Call(testCtx, inst, stack)
+	ref := stack[0]
+	require.NotEqual(t, uint64(0), ref)
+	require.Equal(t, c, s.Externrefs.Value(ref))
+
+	var got interface{}
+	use := MustParseGoReflectFuncCode(func(ctx context.Context, v interface{}) { got = v }).GoFunc.(api.GoModuleFunction)
+	use.Call(testCtx, inst, []uint64{ref})
+	require.Equal(t, c, got)
+
+	// The null reference is nil.
+	use.Call(testCtx, inst, []uint64{0})
+	require.Nil(t, got)
+
+	// A released reference is nil.
+	s.Externrefs.Release(ref)
+	use.Call(testCtx, inst, []uint64{ref})
+	require.Nil(t, got)
+}
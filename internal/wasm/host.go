@@ -1,10 +1,12 @@
 package wasm
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 
 	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/leb128"
 	"github.com/AR1011/wazero/internal/wasmdebug"
 )
 
@@ -44,6 +46,36 @@ func (f *HostFunc) WithGoModuleFunc(fn api.GoModuleFunc) *HostFunc {
 	return &ret
 }
 
+// HostTable is a table defined by a host module, used for Module.AddHostTables.
+type HostTable struct {
+	// ExportName is the name the table is exported as.
+	ExportName string
+
+	// Table is the type of the table.
+	Table Table
+}
+
+// HostMemory is a memory defined by a host module, used for Module.AddHostMemories.
+type HostMemory struct {
+	// ExportName is the name the memory is exported as.
+	ExportName string
+
+	// Memory is the type of the memory.
+	Memory Memory
+}
+
+// HostGlobal is a global defined by a host module, used for Module.AddHostGlobals.
+type HostGlobal struct {
+	// ExportName is the name the global is exported as.
+	ExportName string
+
+	// Type is the type of the global, which must be a numeric type.
+	Type GlobalType
+
+	// Value is the initial value of the global, encoded the same way as api.Global Get.
+	Value uint64
+}
+
 // NewHostModule is defined internally for use in WASI tests and to keep the code size in the root directory small.
 func NewHostModule(
 	moduleName string,
@@ -76,6 +108,110 @@ func NewHostModule(
 	return
 }
 
+// AddHostTables defines the tables in this module, which was created by NewHostModule, and exports them.
+//
+// The tables are validated here, as Module.Validate relies on the decoder to validate their types.
+func (m *Module) AddHostTables(tables []HostTable, enabledFeatures api.CoreFeatures) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	moduleName := m.NameSection.ModuleName
+	for i := range tables {
+		ht := &tables[i]
+		if err := m.checkHostExportName("table", ht.ExportName); err != nil {
+			return err
+		}
+		switch t := &ht.Table; {
+		case t.Type != RefTypeFuncref && t.Type != RefTypeExternref:
+			return fmt.Errorf("table[%s.%s] has invalid type %#x", moduleName, ht.ExportName, t.Type)
+		case t.Type == RefTypeExternref && !enabledFeatures.IsEnabled(api.CoreFeatureReferenceTypes):
+			return fmt.Errorf("table[%s.%s] type externref is invalid: %w", moduleName, ht.ExportName,
+				enabledFeatures.RequireEnabled(api.CoreFeatureReferenceTypes))
+		case t.Min > MaximumFunctionIndex:
+			return fmt.Errorf("table[%s.%s] min must be at most %d", moduleName, ht.ExportName, MaximumFunctionIndex)
+		case t.Max != nil && *t.Max < t.Min:
+			return fmt.Errorf("table[%s.%s] min %d must not be greater than max %d", moduleName, ht.ExportName, t.Min, *t.Max)
+		}
+		m.ExportSection = append(m.ExportSection, Export{Type: ExternTypeTable, Name: ht.ExportName, Index: Index(len(m.TableSection))})
+		m.TableSection = append(m.TableSection, ht.Table)
+	}
+	m.rebuildHostExports()
+	return nil
+}
+
+// AddHostMemories defines the memories in this module, which was created by NewHostModule, and exports them.
+//
+// The memories are validated here, as Module.Validate relies on the decoder to validate their limits.
+func (m *Module) AddHostMemories(memories []HostMemory, memoryLimitPages uint32) error {
+	if len(memories) == 0 {
+		return nil
+	}
+	moduleName := m.NameSection.ModuleName
+	for i := range memories {
+		hm := &memories[i]
+		if err := m.checkHostExportName("memory", hm.ExportName); err != nil {
+			return err
+		}
+		if err := hm.Memory.Validate(memoryLimitPages); err != nil {
+			return fmt.Errorf("memory[%s.%s] %w", moduleName, hm.ExportName, err)
+		}
+		m.ExportSection = append(m.ExportSection, Export{Type: ExternTypeMemory, Name: hm.ExportName, Index: Index(len(m.MemorySection))})
+		m.MemorySection = append(m.MemorySection, hm.Memory)
+	}
+	m.rebuildHostExports()
+	return nil
+}
+
+// AddHostGlobals defines the globals in this module, which was created by NewHostModule, and exports them.
+func (m *Module) AddHostGlobals(globals []HostGlobal) error {
+	if len(globals) == 0 {
+		return nil
+	}
+	moduleName := m.NameSection.ModuleName
+	for i := range globals {
+		hg := &globals[i]
+		if err := m.checkHostExportName("global", hg.ExportName); err != nil {
+			return err
+		}
+		var init ConstantExpression
+		switch hg.Type.ValType {
+		case ValueTypeI32:
+			init = ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeInt32(int32(hg.Value))}
+		case ValueTypeI64:
+			init = ConstantExpression{Opcode: OpcodeI64Const, Data: leb128.EncodeInt64(int64(hg.Value))}
+		case ValueTypeF32:
+			init = ConstantExpression{Opcode: OpcodeF32Const, Data: binary.LittleEndian.AppendUint32(nil, uint32(hg.Value))}
+		case ValueTypeF64:
+			init = ConstantExpression{Opcode: OpcodeF64Const, Data: binary.LittleEndian.AppendUint64(nil, hg.Value)}
+		default:
+			return fmt.Errorf("global[%s.%s] has unsupported type %s", moduleName, hg.ExportName, ValueTypeName(hg.Type.ValType))
+		}
+		m.ExportSection = append(m.ExportSection, Export{Type: ExternTypeGlobal, Name: hg.ExportName, Index: Index(len(m.GlobalSection))})
+		m.GlobalSection = append(m.GlobalSection, Global{Type: hg.Type, Init: init})
+	}
+	m.rebuildHostExports()
+	return nil
+}
+
+// checkHostExportName returns an error if the name is already exported by this module.
+func (m *Module) checkHostExportName(kind, exportName string) error {
+	for i := range m.ExportSection {
+		if m.ExportSection[i].Name == exportName {
+			return fmt.Errorf("%s[%s.%s] conflicts with another export of the same name", kind, m.NameSection.ModuleName, exportName)
+		}
+	}
+	return nil
+}
+
+// rebuildHostExports rebuilds Exports, as appending to ExportSection may have moved it.
+func (m *Module) rebuildHostExports() {
+	m.Exports = make(map[string]*Export, len(m.ExportSection))
+	for i := range m.ExportSection {
+		e := &m.ExportSection[i]
+		m.Exports[e.Name] = e
+	}
+}
+
 func addFuncs(
 	m *Module,
 	exportNames []string,
@@ -184,3 +184,148 @@ func TestNewHostModule_Errors(t *testing.T) {
 		})
 	}
 }
+
+func TestModule_AddHostTables(t *testing.T) {
+	m, err := NewHostModule("env", []string{"swap"}, map[string]*HostFunc{"swap": {ExportName: "swap", Code: Code{GoFunc: swap}}}, api.CoreFeaturesV2)
+	require.NoError(t, err)
+
+	ten := uint32(10)
+	err = m.AddHostTables([]HostTable{
+		{ExportName: "funcs", Table: Table{Min: 1, Max: &ten, Type: RefTypeFuncref}},
+		{ExportName: "externs", Table: Table{Type: RefTypeExternref}},
+	}, api.CoreFeaturesV2)
+	require.NoError(t, err)
+	require.Equal(t, []Table{{Min: 1, Max: &ten, Type: RefTypeFuncref}, {Type: RefTypeExternref}}, m.TableSection)
+	require.Equal(t, &Export{Type: ExternTypeFunc, Name: "swap", Index: 0}, m.Exports["swap"])
+	require.Equal(t, &Export{Type: ExternTypeTable, Name: "funcs", Index: 0}, m.Exports["funcs"])
+	require.Equal(t, &Export{Type: ExternTypeTable, Name: "externs", Index: 1}, m.Exports["externs"])
+	require.NoError(t, m.Validate(api.CoreFeaturesV2))
+}
+
+func TestModule_AddHostTables_Errors(t *testing.T) {
+	one := uint32(1)
+	tests := []struct {
+		name            string
+		table           HostTable
+		enabledFeatures api.CoreFeatures
+		expectedErr     string
+	}{
+		{
+			name:            "conflicts with function",
+			table:           HostTable{ExportName: "swap", Table: Table{Type: RefTypeFuncref}},
+			enabledFeatures: api.CoreFeaturesV2,
+			expectedErr:     "table[env.swap] conflicts with another export of the same name",
+		},
+		{
+			name:            "invalid type",
+			table:           HostTable{ExportName: "t", Table: Table{Type: ValueTypeI32}},
+			enabledFeatures: api.CoreFeaturesV2,
+			expectedErr:     "table[env.t] has invalid type 0x7f",
+		},
+		{
+			name:            "externref disabled",
+			table:           HostTable{ExportName: "t", Table: Table{Type: RefTypeExternref}},
+			enabledFeatures: api.CoreFeaturesV1,
+			expectedErr:     "table[env.t] type externref is invalid: feature \"reference-types\" is disabled",
+		},
+		{
+			name:            "min too large",
+			table:           HostTable{ExportName: "t", Table: Table{Min: MaximumFunctionIndex + 1, Type: RefTypeFuncref}},
+			enabledFeatures: api.CoreFeaturesV2,
+			expectedErr:     "table[env.t] min must be at most 134217728",
+		},
+		{
+			name:            "min greater than max",
+			table:           HostTable{ExportName: "t", Table: Table{Min: 2, Max: &one, Type: RefTypeFuncref}},
+			enabledFeatures: api.CoreFeaturesV2,
+			expectedErr:     "table[env.t] min 2 must not be greater than max 1",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := NewHostModule("env", []string{"swap"}, map[string]*HostFunc{"swap": {ExportName: "swap", Code: Code{GoFunc: argsSizesGet}}}, tc.enabledFeatures)
+			require.NoError(t, err)
+			require.EqualError(t, m.AddHostTables([]HostTable{tc.table}, tc.enabledFeatures), tc.expectedErr)
+		})
+	}
+}
+
+func TestModule_AddHostMemories(t *testing.T) {
+	m, err := NewHostModule("env", nil, nil, api.CoreFeaturesV2)
+	require.NoError(t, err)
+
+	err = m.AddHostMemories([]HostMemory{{ExportName: "memory", Memory: Memory{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true}}}, MemoryLimitPages)
+	require.NoError(t, err)
+	require.Equal(t, []Memory{{Min: 1, Cap: 1, Max: 2, IsMaxEncoded: true}}, m.MemorySection)
+	require.Equal(t, &Export{Type: ExternTypeMemory, Name: "memory", Index: 0}, m.Exports["memory"])
+	require.NoError(t, m.Validate(api.CoreFeaturesV2))
+}
+
+func TestModule_AddHostMemories_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		memory      HostMemory
+		expectedErr string
+	}{
+		{
+			name:        "conflicts with function",
+			memory:      HostMemory{ExportName: "swap", Memory: Memory{Max: 1}},
+			expectedErr: "memory[env.swap] conflicts with another export of the same name",
+		},
+		{
+			name:        "max over limit",
+			memory:      HostMemory{ExportName: "memory", Memory: Memory{Max: 3}},
+			expectedErr: "memory[env.memory] max 3 pages (192 Ki) over limit of 2 pages (128 Ki)",
+		},
+		{
+			name:        "min greater than max",
+			memory:      HostMemory{ExportName: "memory", Memory: Memory{Min: 2, Cap: 2, Max: 1}},
+			expectedErr: "memory[env.memory] min 2 pages (128 Ki) > max 1 pages (64 Ki)",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := NewHostModule("env", []string{"swap"}, map[string]*HostFunc{"swap": {ExportName: "swap", Code: Code{GoFunc: argsSizesGet}}}, api.CoreFeaturesV2)
+			require.NoError(t, err)
+			require.EqualError(t, m.AddHostMemories([]HostMemory{tc.memory}, 2), tc.expectedErr)
+		})
+	}
+}
+
+func TestModule_AddHostGlobals(t *testing.T) {
+	m, err := NewHostModule("env", nil, nil, api.CoreFeaturesV2)
+	require.NoError(t, err)
+
+	err = m.AddHostGlobals([]HostGlobal{
+		{ExportName: "i32", Type: GlobalType{ValType: ValueTypeI32}, Value: api.EncodeI32(-1)},
+		{ExportName: "i64", Type: GlobalType{ValType: ValueTypeI64, Mutable: true}, Value: 64},
+		{ExportName: "f32", Type: GlobalType{ValType: ValueTypeF32}, Value: api.EncodeF32(1.5)},
+		{ExportName: "f64", Type: GlobalType{ValType: ValueTypeF64}, Value: api.EncodeF64(1.5)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []Global{
+		{Type: GlobalType{ValType: ValueTypeI32}, Init: ConstantExpression{Opcode: OpcodeI32Const, Data: []byte{0x7f}}},
+		{Type: GlobalType{ValType: ValueTypeI64, Mutable: true}, Init: ConstantExpression{Opcode: OpcodeI64Const, Data: []byte{0xc0, 0x00}}},
+		{Type: GlobalType{ValType: ValueTypeF32}, Init: ConstantExpression{Opcode: OpcodeF32Const, Data: []byte{0x00, 0x00, 0xc0, 0x3f}}},
+		{Type: GlobalType{ValType: ValueTypeF64}, Init: ConstantExpression{Opcode: OpcodeF64Const, Data: []byte{0, 0, 0, 0, 0, 0, 0xf8, 0x3f}}},
+	}, m.GlobalSection)
+	require.Equal(t, &Export{Type: ExternTypeGlobal, Name: "f64", Index: 3}, m.Exports["f64"])
+	require.NoError(t, m.Validate(api.CoreFeaturesV2))
+}
+
+func TestModule_AddHostGlobals_Errors(t *testing.T) {
+	m, err := NewHostModule("env", []string{"swap"}, map[string]*HostFunc{"swap": {ExportName: "swap", Code: Code{GoFunc: argsSizesGet}}}, api.CoreFeaturesV2)
+	require.NoError(t, err)
+
+	err = m.AddHostGlobals([]HostGlobal{{ExportName: "swap", Type: GlobalType{ValType: ValueTypeI32}}})
+	require.EqualError(t, err, "global[env.swap] conflicts with another export of the same name")
+
+	err = m.AddHostGlobals([]HostGlobal{{ExportName: "ref", Type: GlobalType{ValType: ValueTypeFuncref}}})
+	require.EqualError(t, err, "global[env.ref] has unsupported type funcref")
+}
@@ -1,14 +1,18 @@
 package wasm
 
 import (
+	"container/list"
 	"encoding/binary"
 	"fmt"
 	"math"
 	"reflect"
+	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/AR1011/wazero/api"
 	"github.com/AR1011/wazero/internal/internalapi"
+	"github.com/AR1011/wazero/internal/wasmruntime"
 )
 
 const (
@@ -19,8 +23,16 @@ const (
 	// MemoryLimitPages is maximum number of pages defined (2^16).
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#grow-mem
 	MemoryLimitPages = uint32(65536)
+	// Memory64LimitPages is the maximum number of pages of a 64-bit memory (api.CoreFeatureMemory64) supported by
+	// wazero. This is lower than the 2^48 pages allowed by the memory64 proposal, but still far beyond the physical
+	// memory of any host.
+	Memory64LimitPages = uint32(math.MaxUint32)
 	// MemoryPageSizeInBits satisfies the relation: "1 << MemoryPageSizeInBits == MemoryPageSize".
 	MemoryPageSizeInBits = 16
+	// MemArgMemoryIndexFlag is set in the alignment of a memarg when the memory index follows the alignment.
+	// This is only valid when api.CoreFeatureMultiMemory is enabled.
+	// See https://github.com/WebAssembly/multi-memory/blob/main/proposals/multi-memory/Overview.md#binary-format
+	MemArgMemoryIndexFlag = uint32(1 << 6)
 )
 
 // compile-time check to ensure MemoryInstance implements api.Memory
@@ -36,19 +48,43 @@ type MemoryInstance struct {
 
 	Buffer        []byte
 	Min, Cap, Max uint32
+	// Shared is true when the memory was declared shared (CoreFeatureThreads).
+	Shared bool
+	// Memory64 is true when the memory is addressed with i64 (CoreFeatureMemory64).
+	Memory64 bool
 	// definition is known at compile time.
 	definition api.MemoryDefinition
+
+	// Mux is used in interpreter mode to prevent overlapping calls to atomic instructions,
+	// introduced with CoreFeatureThreads. It is also used to serialize Grow on shared memory.
+	Mux sync.Mutex
+
+	// waiters implements atomic wait and notify. It is implemented similarly to golang.org/x/sync/semaphore,
+	// with a fixed weight of 1 and no spurious notifications.
+	waiters sync.Map
+
+	// Limiter if non-nil is consulted by Grow.
+	Limiter ResourceLimiter
 }
 
 // NewMemoryInstance creates a new instance based on the parameters in the SectionIDMemory.
 func NewMemoryInstance(memSec *Memory) *MemoryInstance {
 	min := MemoryPagesToBytesNum(memSec.Min)
 	capacity := MemoryPagesToBytesNum(memSec.Cap)
+	memCap := memSec.Cap
+	if memSec.IsShared {
+		// A shared memory can be accessed from multiple agents concurrently, so its buffer must never be relocated
+		// by Grow. Reserve the maximum size upfront, which is always encoded for shared memory.
+		capacity = MemoryPagesToBytesNum(memSec.Max)
+		memCap = memSec.Max
+	}
 	return &MemoryInstance{
-		Buffer: make([]byte, min, capacity),
-		Min:    memSec.Min,
-		Cap:    memSec.Cap,
-		Max:    memSec.Max,
+		Buffer:   make([]byte, min, capacity),
+		Min:      memSec.Min,
+		Cap:      memCap,
+		Max:      memSec.Max,
+		Shared:   memSec.IsShared,
+		Memory64: memSec.IsMemory64,
 	}
 }
 
@@ -59,11 +95,21 @@ func (m *MemoryInstance) Definition() api.MemoryDefinition {
 
 // Size implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Size() uint32 {
+	return uint32(m.size())
+}
+
+// Size64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) Size64() uint64 {
 	return m.size()
 }
 
 // ReadByte implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadByte(offset uint32) (byte, bool) {
+	return m.ReadByte64(uint64(offset))
+}
+
+// ReadByte64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) ReadByte64(offset uint64) (byte, bool) {
 	if offset >= m.size() {
 		return 0, false
 	}
@@ -72,6 +118,11 @@ func (m *MemoryInstance) ReadByte(offset uint32) (byte, bool) {
 
 // ReadUint16Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadUint16Le(offset uint32) (uint16, bool) {
+	return m.ReadUint16Le64(uint64(offset))
+}
+
+// ReadUint16Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) ReadUint16Le64(offset uint64) (uint16, bool) {
 	if !m.hasSize(offset, 2) {
 		return 0, false
 	}
@@ -80,11 +131,21 @@ func (m *MemoryInstance) ReadUint16Le(offset uint32) (uint16, bool) {
 
 // ReadUint32Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadUint32Le(offset uint32) (uint32, bool) {
+	return m.readUint32Le(uint64(offset))
+}
+
+// ReadUint32Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) ReadUint32Le64(offset uint64) (uint32, bool) {
 	return m.readUint32Le(offset)
 }
 
 // ReadFloat32Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadFloat32Le(offset uint32) (float32, bool) {
+	return m.ReadFloat32Le64(uint64(offset))
+}
+
+// ReadFloat32Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) ReadFloat32Le64(offset uint64) (float32, bool) {
 	v, ok := m.readUint32Le(offset)
 	if !ok {
 		return 0, false
@@ -94,11 +155,21 @@ func (m *MemoryInstance) ReadFloat32Le(offset uint32) (float32, bool) {
 
 // ReadUint64Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadUint64Le(offset uint32) (uint64, bool) {
+	return m.readUint64Le(uint64(offset))
+}
+
+// ReadUint64Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) ReadUint64Le64(offset uint64) (uint64, bool) {
 	return m.readUint64Le(offset)
 }
 
 // ReadFloat64Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) ReadFloat64Le(offset uint32) (float64, bool) {
+	return m.ReadFloat64Le64(uint64(offset))
+}
+
+// ReadFloat64Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) ReadFloat64Le64(offset uint64) (float64, bool) {
 	v, ok := m.readUint64Le(offset)
 	if !ok {
 		return 0, false
@@ -108,7 +179,12 @@ func (m *MemoryInstance) ReadFloat64Le(offset uint32) (float64, bool) {
 
 // Read implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Read(offset, byteCount uint32) ([]byte, bool) {
-	if !m.hasSize(offset, uint64(byteCount)) {
+	return m.Read64(uint64(offset), uint64(byteCount))
+}
+
+// Read64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) Read64(offset, byteCount uint64) ([]byte, bool) {
+	if !m.hasSize(offset, byteCount) {
 		return nil, false
 	}
 	return m.Buffer[offset : offset+byteCount : offset+byteCount], true
@@ -116,6 +192,11 @@ func (m *MemoryInstance) Read(offset, byteCount uint32) ([]byte, bool) {
 
 // WriteByte implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteByte(offset uint32, v byte) bool {
+	return m.WriteByte64(uint64(offset), v)
+}
+
+// WriteByte64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteByte64(offset uint64, v byte) bool {
 	if offset >= m.size() {
 		return false
 	}
@@ -125,6 +206,11 @@ func (m *MemoryInstance) WriteByte(offset uint32, v byte) bool {
 
 // WriteUint16Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteUint16Le(offset uint32, v uint16) bool {
+	return m.WriteUint16Le64(uint64(offset), v)
+}
+
+// WriteUint16Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteUint16Le64(offset uint64, v uint16) bool {
 	if !m.hasSize(offset, 2) {
 		return false
 	}
@@ -134,26 +220,51 @@ func (m *MemoryInstance) WriteUint16Le(offset uint32, v uint16) bool {
 
 // WriteUint32Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteUint32Le(offset, v uint32) bool {
+	return m.writeUint32Le(uint64(offset), v)
+}
+
+// WriteUint32Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteUint32Le64(offset uint64, v uint32) bool {
 	return m.writeUint32Le(offset, v)
 }
 
 // WriteFloat32Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteFloat32Le(offset uint32, v float32) bool {
+	return m.writeUint32Le(uint64(offset), math.Float32bits(v))
+}
+
+// WriteFloat32Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteFloat32Le64(offset uint64, v float32) bool {
 	return m.writeUint32Le(offset, math.Float32bits(v))
 }
 
 // WriteUint64Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteUint64Le(offset uint32, v uint64) bool {
+	return m.writeUint64Le(uint64(offset), v)
+}
+
+// WriteUint64Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteUint64Le64(offset uint64, v uint64) bool {
 	return m.writeUint64Le(offset, v)
 }
 
 // WriteFloat64Le implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteFloat64Le(offset uint32, v float64) bool {
+	return m.writeUint64Le(uint64(offset), math.Float64bits(v))
+}
+
+// WriteFloat64Le64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteFloat64Le64(offset uint64, v float64) bool {
 	return m.writeUint64Le(offset, math.Float64bits(v))
 }
 
 // Write implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Write(offset uint32, val []byte) bool {
+	return m.Write64(uint64(offset), val)
+}
+
+// Write64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) Write64(offset uint64, val []byte) bool {
 	if !m.hasSize(offset, uint64(len(val))) {
 		return false
 	}
@@ -163,6 +274,11 @@ func (m *MemoryInstance) Write(offset uint32, val []byte) bool {
 
 // WriteString implements the same method as documented on api.Memory.
 func (m *MemoryInstance) WriteString(offset uint32, val string) bool {
+	return m.WriteString64(uint64(offset), val)
+}
+
+// WriteString64 implements the same method as documented on api.Memory.
+func (m *MemoryInstance) WriteString64(offset uint64, val string) bool {
 	if !m.hasSize(offset, uint64(len(val))) {
 		return false
 	}
@@ -177,16 +293,31 @@ func MemoryPagesToBytesNum(pages uint32) (bytesNum uint64) {
 
 // Grow implements the same method as documented on api.Memory.
 func (m *MemoryInstance) Grow(delta uint32) (result uint32, ok bool) {
+	if m.Shared {
+		m.Mux.Lock()
+		defer m.Mux.Unlock()
+	}
+
 	currentPages := memoryBytesNumToPages(uint64(len(m.Buffer)))
 	if delta == 0 {
 		return currentPages, true
 	}
 
 	// If exceeds the max of memory size, we push -1 according to the spec.
+	// The sum is computed in uint64 as it can overflow for 64-bit memories.
 	newPages := currentPages + delta
-	if newPages > m.Max {
+	if uint64(currentPages)+uint64(delta) > uint64(m.Max) {
 		return 0, false
-	} else if newPages > m.Cap { // grow the memory.
+	} else if m.Limiter != nil {
+		// The error is raised as a panic, which is recovered as a trap by the engines.
+		if allowed, err := m.Limiter.MemoryGrowing(currentPages, newPages); err != nil {
+			panic(err)
+		} else if !allowed {
+			return 0, false
+		}
+	}
+
+	if newPages > m.Cap { // grow the memory.
 		m.Buffer = append(m.Buffer, make([]byte, MemoryPagesToBytesNum(delta))...)
 		m.Cap = newPages
 		return currentPages, true
@@ -206,7 +337,7 @@ func (m *MemoryInstance) PageSize() (result uint32) {
 //
 // See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-instances%E2%91%A0
 func PagesToUnitOfBytes(pages uint32) string {
-	k := pages * 64
+	k := uint64(pages) * 64
 	if k < 1024 {
 		return fmt.Sprintf("%d Ki", k)
 	}
@@ -229,20 +360,21 @@ func memoryBytesNumToPages(bytesNum uint64) (pages uint32) {
 }
 
 // size returns the size in bytes of the buffer.
-func (m *MemoryInstance) size() uint32 {
-	return uint32(len(m.Buffer)) // We don't lock here because size can't become smaller.
+func (m *MemoryInstance) size() uint64 {
+	return uint64(len(m.Buffer)) // We don't lock here because size can't become smaller.
 }
 
 // hasSize returns true if Len is sufficient for byteCount at the given offset.
 //
 // Note: This is always fine, because memory can grow, but never shrink.
-func (m *MemoryInstance) hasSize(offset uint32, byteCount uint64) bool {
-	return uint64(offset)+byteCount <= uint64(len(m.Buffer)) // uint64 prevents overflow on add
+func (m *MemoryInstance) hasSize(offset uint64, byteCount uint64) bool {
+	size := m.size()
+	return byteCount <= size && offset <= size-byteCount // subtraction prevents overflow on add
 }
 
 // readUint32Le implements ReadUint32Le without using a context. This is extracted as both ints and floats are stored in
 // memory as uint32le.
-func (m *MemoryInstance) readUint32Le(offset uint32) (uint32, bool) {
+func (m *MemoryInstance) readUint32Le(offset uint64) (uint32, bool) {
 	if !m.hasSize(offset, 4) {
 		return 0, false
 	}
@@ -251,7 +383,7 @@ func (m *MemoryInstance) readUint32Le(offset uint32) (uint32, bool) {
 
 // readUint64Le implements ReadUint64Le without using a context. This is extracted as both ints and floats are stored in
 // memory as uint64le.
-func (m *MemoryInstance) readUint64Le(offset uint32) (uint64, bool) {
+func (m *MemoryInstance) readUint64Le(offset uint64) (uint64, bool) {
 	if !m.hasSize(offset, 8) {
 		return 0, false
 	}
@@ -260,7 +392,7 @@ func (m *MemoryInstance) readUint64Le(offset uint32) (uint64, bool) {
 
 // writeUint32Le implements WriteUint32Le without using a context. This is extracted as both ints and floats are stored
 // in memory as uint32le.
-func (m *MemoryInstance) writeUint32Le(offset uint32, v uint32) bool {
+func (m *MemoryInstance) writeUint32Le(offset uint64, v uint32) bool {
 	if !m.hasSize(offset, 4) {
 		return false
 	}
@@ -270,10 +402,126 @@ func (m *MemoryInstance) writeUint32Le(offset uint32, v uint32) bool {
 
 // writeUint64Le implements WriteUint64Le without using a context. This is extracted as both ints and floats are stored
 // in memory as uint64le.
-func (m *MemoryInstance) writeUint64Le(offset uint32, v uint64) bool {
+func (m *MemoryInstance) writeUint64Le(offset uint64, v uint64) bool {
 	if !m.hasSize(offset, 8) {
 		return false
 	}
 	binary.LittleEndian.PutUint64(m.Buffer[offset:], v)
 	return true
 }
+
+type waiters struct {
+	mux sync.Mutex
+	l   *list.List
+}
+
+// Notify wakes up at most count waiters at the given offset.
+func (m *MemoryInstance) Notify(offset uint64, count uint32) uint32 {
+	wAny, ok := m.waiters.Load(offset)
+	if !ok {
+		return 0
+	}
+	w := wAny.(*waiters)
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.l == nil {
+		return 0
+	}
+
+	res := uint32(0)
+	for num := w.l.Len(); num > 0 && res < count; num = w.l.Len() {
+		ready := w.l.Remove(w.l.Front()).(chan struct{})
+		close(ready)
+		res++
+	}
+
+	return res
+}
+
+// Wait32 suspends the caller until the offset is notified by a different agent.
+//
+// The result is 0 if woken by Notify, 1 if the value at the offset didn't equal exp, and 2 if the timeout in
+// nanoseconds elapsed. A negative timeout waits forever.
+func (m *MemoryInstance) Wait32(offset uint64, exp uint32, timeout int64, reader func(mem *MemoryInstance, offset uint64) uint32) uint64 {
+	w := m.getWaiters(offset)
+	w.mux.Lock()
+
+	cur := reader(m, offset)
+	if cur != exp {
+		w.mux.Unlock()
+		return 1
+	}
+
+	return m.wait(w, timeout)
+}
+
+// Wait64 is like Wait32, except it compares a 64-bit value at the offset.
+func (m *MemoryInstance) Wait64(offset uint64, exp uint64, timeout int64, reader func(mem *MemoryInstance, offset uint64) uint64) uint64 {
+	w := m.getWaiters(offset)
+	w.mux.Lock()
+
+	cur := reader(m, offset)
+	if cur != exp {
+		w.mux.Unlock()
+		return 1
+	}
+
+	return m.wait(w, timeout)
+}
+
+// wait blocks until notified or the timeout elapses. The caller must hold w.mux, which is released here.
+func (m *MemoryInstance) wait(w *waiters, timeout int64) uint64 {
+	if w.l == nil {
+		w.l = list.New()
+	}
+
+	// The specification requires a trap if the number of existing waiters + 1 == 2^32, so we add a check here.
+	// In practice, it is unlikely the application would ever accumulate such a large number of waiters as it
+	// indicates several GB of RAM used just for the list of waiters.
+	// https://github.com/WebAssembly/threads/blob/main/proposals/threads/Overview.md#wait
+	if uint64(w.l.Len()+1) == 1<<32 {
+		w.mux.Unlock()
+		panic(wasmruntime.ErrRuntimeTooManyWaiters)
+	}
+
+	ready := make(chan struct{})
+	elem := w.l.PushBack(ready)
+	w.mux.Unlock()
+
+	if timeout < 0 {
+		<-ready
+		return 0
+	}
+
+	timer := time.NewTimer(time.Duration(timeout))
+	defer timer.Stop()
+	select {
+	case <-ready:
+		return 0
+	case <-timer.C:
+		// The notifier may have removed this waiter concurrently with the timeout, in which case it is
+		// already closed and must be reported as woken, otherwise Notify would over-count.
+		w.mux.Lock()
+		defer w.mux.Unlock()
+		select {
+		case <-ready:
+			return 0
+		default:
+			w.l.Remove(elem)
+			return 2
+		}
+	}
+}
+
+func (m *MemoryInstance) getWaiters(offset uint64) *waiters {
+	wAny, ok := m.waiters.Load(offset)
+	if !ok {
+		// The first time an address is waited on, simultaneous waits will cause extra allocations.
+		// Further operations will be loaded above, which is also the general pattern of usage with
+		// mutexes.
+		wAny, _ = m.waiters.LoadOrStore(offset, &waiters{})
+	}
+
+	return wAny.(*waiters)
+}
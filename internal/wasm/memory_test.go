@@ -1,6 +1,7 @@
 package wasm
 
 import (
+	"errors"
 	"math"
 	"reflect"
 	"strings"
@@ -90,6 +91,47 @@ func TestMemoryInstance_Grow_Size(t *testing.T) {
 	}
 }
 
+type testResourceLimiter struct {
+	calls [][2]uint32
+	max   uint32
+	err   error
+}
+
+func (l *testResourceLimiter) MemoryGrowing(current, desired uint32) (bool, error) {
+	l.calls = append(l.calls, [2]uint32{current, desired})
+	return desired <= l.max, l.err
+}
+
+func (l *testResourceLimiter) TableGrowing(current, desired uint32) (bool, error) {
+	l.calls = append(l.calls, [2]uint32{current, desired})
+	return desired <= l.max, l.err
+}
+
+func TestMemoryInstance_Grow_Limiter(t *testing.T) {
+	limiter := &testResourceLimiter{max: 2}
+	m := &MemoryInstance{Max: 10, Buffer: make([]byte, MemoryPageSize), Limiter: limiter}
+
+	res, ok := m.Grow(1)
+	require.True(t, ok)
+	require.Equal(t, uint32(1), res)
+
+	_, ok = m.Grow(1)
+	require.False(t, ok)
+	require.Equal(t, uint32(2), m.PageSize())
+
+	// Neither growing zero pages nor beyond the maximum consults the limiter.
+	_, ok = m.Grow(0)
+	require.True(t, ok)
+	_, ok = m.Grow(9)
+	require.False(t, ok)
+	require.Equal(t, [][2]uint32{{1, 2}, {2, 3}}, limiter.calls)
+
+	limiter.err = errors.New("quota exceeded")
+	err := require.CapturePanic(func() { m.Grow(1) })
+	require.EqualError(t, err, "quota exceeded")
+	require.Equal(t, uint32(2), m.PageSize())
+}
+
 func TestMemoryInstance_ReadByte(t *testing.T) {
 	mem := &MemoryInstance{Buffer: []byte{0, 0, 0, 0, 0, 0, 0, 16}, Min: 1}
 	v, ok := mem.ReadByte(7)
@@ -132,7 +174,7 @@ func TestPagesToUnitOfBytes(t *testing.T) {
 		{
 			name:     "max uint32",
 			pages:    math.MaxUint32,
-			expected: "3 Ti",
+			expected: "255 Ti",
 		},
 	}
 
@@ -150,7 +192,7 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 
 	tests := []struct {
 		name        string
-		offset      uint32
+		offset      uint64
 		sizeInBytes uint64
 		expected    bool
 	}{
@@ -162,7 +204,7 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 		},
 		{
 			name:        "maximum valid sizeInBytes",
-			offset:      memory.Size() - 8,
+			offset:      uint64(memory.Size() - 8),
 			sizeInBytes: 8,
 			expected:    true,
 		},
@@ -174,7 +216,7 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 		},
 		{
 			name:        "offset exceeds the memory size",
-			offset:      memory.Size(),
+			offset:      uint64(memory.Size()),
 			sizeInBytes: 1, // arbitrary size
 			expected:    false,
 		},
@@ -190,6 +232,12 @@ func TestMemoryInstance_HasSize(t *testing.T) {
 			sizeInBytes: 1,
 			expected:    false,
 		},
+		{
+			name:        "offset + sizeInBytes overflows in uint64",
+			offset:      math.MaxUint64 - 1,
+			sizeInBytes: 4,
+			expected:    false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -754,6 +802,68 @@ func TestMemoryInstance_Write_overflow(t *testing.T) {
 	require.False(t, mem.Write(4, buf))
 }
 
+func TestMemoryInstance_64(t *testing.T) {
+	mem := &MemoryInstance{Buffer: make([]byte, 16), Min: 1, Memory64: true}
+	require.Equal(t, uint64(16), mem.Size64())
+
+	require.True(t, mem.WriteByte64(0, 1))
+	require.True(t, mem.WriteUint16Le64(1, 0x0302))
+	require.True(t, mem.WriteUint32Le64(4, 0x07060504))
+	require.True(t, mem.WriteUint64Le64(8, 0x0f0e0d0c0b0a0908))
+	require.Equal(t, []byte{1, 2, 3, 0, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, mem.Buffer)
+
+	b, ok := mem.ReadByte64(0)
+	require.True(t, ok)
+	require.Equal(t, byte(1), b)
+	u16, ok := mem.ReadUint16Le64(1)
+	require.True(t, ok)
+	require.Equal(t, uint16(0x0302), u16)
+	u32, ok := mem.ReadUint32Le64(4)
+	require.True(t, ok)
+	require.Equal(t, uint32(0x07060504), u32)
+	u64, ok := mem.ReadUint64Le64(8)
+	require.True(t, ok)
+	require.Equal(t, uint64(0x0f0e0d0c0b0a0908), u64)
+
+	require.True(t, mem.WriteFloat32Le64(0, 1.5))
+	f32, ok := mem.ReadFloat32Le64(0)
+	require.True(t, ok)
+	require.Equal(t, float32(1.5), f32)
+	require.True(t, mem.WriteFloat64Le64(8, 2.5))
+	f64, ok := mem.ReadFloat64Le64(8)
+	require.True(t, ok)
+	require.Equal(t, 2.5, f64)
+
+	require.True(t, mem.WriteString64(12, "bear"))
+	buf, ok := mem.Read64(12, 4)
+	require.True(t, ok)
+	require.Equal(t, []byte("bear"), buf)
+	require.True(t, mem.Write64(12, []byte("cats")))
+	require.Equal(t, []byte("cats"), buf)
+
+	// Offsets which are only valid in their lower 32 bits must not wrap around.
+	for _, offset := range []uint64{1 << 32, math.MaxUint64} {
+		_, ok = mem.ReadByte64(offset)
+		require.False(t, ok)
+		_, ok = mem.ReadUint16Le64(offset)
+		require.False(t, ok)
+		_, ok = mem.ReadUint32Le64(offset)
+		require.False(t, ok)
+		_, ok = mem.ReadUint64Le64(offset)
+		require.False(t, ok)
+		_, ok = mem.Read64(offset, 1)
+		require.False(t, ok)
+		require.False(t, mem.WriteByte64(offset, 1))
+		require.False(t, mem.WriteUint16Le64(offset, 1))
+		require.False(t, mem.WriteUint32Le64(offset, 1))
+		require.False(t, mem.WriteUint64Le64(offset, 1))
+		require.False(t, mem.Write64(offset, []byte{1}))
+		require.False(t, mem.WriteString64(offset, "a"))
+	}
+	_, ok = mem.Read64(1, math.MaxUint64)
+	require.False(t, ok)
+}
+
 func TestMemoryInstance_WriteString(t *testing.T) {
 	mem := &MemoryInstance{Buffer: []byte{0, 0, 0, 0, 16, 0, 0, 0}, Min: 1}
 
@@ -2,9 +2,9 @@ package wasm
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
 	"sort"
@@ -45,12 +45,13 @@ type Module struct {
 	//
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#import-section%E2%91%A0
 	ImportSection []Import
-	// ImportFunctionCount ImportGlobalCount ImportMemoryCount, and ImportTableCount are
+	// ImportFunctionCount ImportGlobalCount ImportMemoryCount, ImportTableCount and ImportTagCount are
 	// the cached import count per ExternType set during decoding.
 	ImportFunctionCount,
 	ImportGlobalCount,
 	ImportMemoryCount,
-	ImportTableCount Index
+	ImportTableCount,
+	ImportTagCount Index
 	// ImportPerModule maps a module name to the list of Import to be imported from the module.
 	// This is used to do fast import resolution during instantiation.
 	ImportPerModule map[string][]*Import
@@ -86,16 +87,27 @@ type Module struct {
 	// MemorySection contains each memory defined in this module.
 	//
 	// Note: The memory Index space begins with imported memories and ends with those defined in this module.
-	// For example, if there are two imported memories and one defined in this module, the memory Index 3 is defined in
-	// this module at TableSection[0].
+	// For example, if there are two imported memories and one defined in this module, the memory Index 2 is defined in
+	// this module at MemorySection[0].
 	//
 	// Note: Version 1.0 (20191205) of the WebAssembly spec allows at most one memory definition per module, so the
-	// length of the MemorySection can be zero or one, and can only be one if there is no imported memory.
+	// length of the MemorySection can be zero or one, and can only be one if there is no imported memory. This is
+	// relaxed by api.CoreFeatureMultiMemory.
 	//
 	// Note: In the Binary Format, this is SectionIDMemory.
 	//
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#memory-section%E2%91%A0
-	MemorySection *Memory
+	MemorySection []Memory
+
+	// TagSection contains the index in TypeSection of each exception tag defined in this module. The type of a tag
+	// describes the values carried by the exceptions thrown with it, and has no results.
+	//
+	// Note: The tag Index space begins with imported tags and ends with those defined in this module.
+	//
+	// Note: In the Binary Format, this is SectionIDTag, which requires api.CoreFeatureExceptionHandling.
+	//
+	// See https://webassembly.github.io/exception-handling/core/binary/modules.html#tag-section
+	TagSection []Index
 
 	// GlobalSection contains each global defined in this module.
 	//
@@ -172,6 +184,23 @@ type Module struct {
 	// IsHostModule true if this is the host module, false otherwise.
 	IsHostModule bool
 
+	// FuelMetering is true if the compiled functions of this module consume fuel from ModuleInstance.Fuel.
+	// This is set by the runtime before AssignModuleID so that the flag is reflected in ID.
+	FuelMetering bool
+
+	// EpochInterruption is true if the compiled functions of this module check ModuleInstance.EpochDeadline.
+	// Similar to FuelMetering, this is set by the runtime before AssignModuleID.
+	EpochInterruption bool
+
+	// MaxCallStackDepth is the maximum depth of the call stack of the function calls, or zero to use the default
+	// limit of the engine. When non-zero, the compiled functions of this module track the depth.
+	// Similar to FuelMetering, this is set by the runtime before AssignModuleID.
+	MaxCallStackDepth uint32
+
+	// CoreDump is true if the errors of the function calls trapping in this module carry a coredump. As the compiled
+	// functions retain the offsets of their instructions for it, this is also set by the runtime before AssignModuleID.
+	CoreDump bool
+
 	// functionDefinitionSectionInitOnce guards FunctionDefinitionSection so that it is initialized exactly once.
 	functionDefinitionSectionInitOnce sync.Once
 
@@ -181,6 +210,9 @@ type Module struct {
 	// MemoryDefinitionSection is a wazero-specific section.
 	MemoryDefinitionSection []MemoryDefinition
 
+	// TableDefinitionSection is a wazero-specific section.
+	TableDefinitionSection []TableDefinition
+
 	// DWARFLines is used to emit DWARF based stack trace. This is created from the multiple custom sections
 	// as described in https://yurydelendik.github.io/webassembly-dwarf/, though it is not specified in the Wasm
 	// specification: https://github.com/WebAssembly/debugging/issues/1
@@ -198,6 +230,11 @@ const (
 	MaximumTableIndex    = uint32(1 << 27)
 )
 
+// CoreFeaturesAll are all the features which wazero supports, for decoding a module which has already been compiled or
+// is only inspected, so that it isn't rejected for features the runtime which runs it may enable.
+const CoreFeaturesAll = api.CoreFeaturesV2 | api.CoreFeatureThreads | api.CoreFeatureTailCall |
+	api.CoreFeatureMultiMemory | api.CoreFeatureMemory64 | api.CoreFeatureExceptionHandling
+
 // AssignModuleID calculates a sha256 checksum on `wasm` and other args, and set Module.ID to the result.
 // See the doc on Module.ID on what it's used for.
 func (m *Module) AssignModuleID(wasm []byte, listeners []experimental.FunctionListener, withEnsureTermination bool) {
@@ -214,10 +251,51 @@ func (m *Module) AssignModuleID(wasm []byte, listeners []experimental.FunctionLi
 	// Write the flag of ensureTermination to the checksum.
 	m.ID[0] = boolToByte(withEnsureTermination)
 	h.Write(m.ID[:1])
+	if m.FuelMetering {
+		// Only write the flag when enabled so that the IDs of the modules without fuel metering are unchanged.
+		m.ID[0] = 0xf
+		h.Write(m.ID[:1])
+	}
+	if m.EpochInterruption {
+		m.ID[0] = 0xe
+		h.Write(m.ID[:1])
+	}
+	if m.MaxCallStackDepth != 0 {
+		// Only the presence of the limit affects the compiled code, as the depth is compared at runtime.
+		m.ID[0] = 0xd
+		h.Write(m.ID[:1])
+	}
+	if m.CoreDump {
+		m.ID[0] = 0xc
+		h.Write(m.ID[:1])
+	}
 	// Get checksum by passing the slice underlying m.ID.
 	h.Sum(m.ID[:0])
 }
 
+// CallStackDepthCeiling returns the maximum depth of the call stack for the function call with the given
+// context.Context, which is either overridden via experimental.WithMaxCallStackDepth or MaxCallStackDepth.
+// This returns zero if MaxCallStackDepth is not configured, in which case the engine's default limit applies.
+func (m *Module) CallStackDepthCeiling(ctx context.Context) uint32 {
+	if m.MaxCallStackDepth == 0 {
+		return 0
+	}
+	if depth, ok := ctx.Value(experimental.MaxCallStackDepthKey{}).(uint32); ok && depth > 0 {
+		return depth
+	}
+	return m.MaxCallStackDepth
+}
+
+// CallEpochDeadline returns the ticks of the epoch deadline for the function call with the given context.Context,
+// which is set via experimental.WithEpochDeadline, or false if it has none or EpochInterruption is disabled.
+func (m *Module) CallEpochDeadline(ctx context.Context) (ticks uint64, ok bool) {
+	if !m.EpochInterruption {
+		return 0, false
+	}
+	ticks, ok = ctx.Value(experimental.EpochDeadlineKey{}).(uint64)
+	return
+}
+
 func boolToByte(b bool) (ret byte) {
 	if b {
 		ret = 1
@@ -267,7 +345,7 @@ func (m *Module) Validate(enabledFeatures api.CoreFeatures) error {
 		return err
 	}
 
-	functions, globals, memory, tables, err := m.AllDeclarations()
+	functions, globals, memories, tables, err := m.AllDeclarations()
 	if err != nil {
 		return err
 	}
@@ -280,16 +358,20 @@ func (m *Module) Validate(enabledFeatures api.CoreFeatures) error {
 		return err
 	}
 
-	if err = m.validateMemory(memory, globals, enabledFeatures); err != nil {
+	if err = m.validateMemory(memories, globals, enabledFeatures); err != nil {
+		return err
+	}
+
+	if err = m.validateTags(enabledFeatures); err != nil {
 		return err
 	}
 
-	if err = m.validateExports(enabledFeatures, functions, globals, memory, tables); err != nil {
+	if err = m.validateExports(enabledFeatures, functions, globals, memories, tables); err != nil {
 		return err
 	}
 
 	if m.CodeSection != nil {
-		if err = m.validateFunctions(enabledFeatures, functions, globals, memory, tables, MaximumFunctionIndex); err != nil {
+		if err = m.validateFunctions(enabledFeatures, functions, globals, memories, tables, MaximumFunctionIndex); err != nil {
 			return err
 		}
 	} // No need to validate host functions as NewHostModule validates
@@ -337,7 +419,7 @@ func (m *Module) validateGlobals(globals []GlobalType, numFuncts, maxGlobals uin
 	return nil
 }
 
-func (m *Module) validateFunctions(enabledFeatures api.CoreFeatures, functions []Index, globals []GlobalType, memory *Memory, tables []Table, maximumFunctionIndex uint32) error {
+func (m *Module) validateFunctions(enabledFeatures api.CoreFeatures, functions []Index, globals []GlobalType, memories []*Memory, tables []Table, maximumFunctionIndex uint32) error {
 	if uint32(len(functions)) > maximumFunctionIndex {
 		return fmt.Errorf("too many functions (%d) in a module", len(functions))
 	}
@@ -371,7 +453,7 @@ func (m *Module) validateFunctions(enabledFeatures api.CoreFeatures, functions [
 		if c.GoFunc != nil {
 			continue
 		}
-		if err = m.validateFunction(vs, enabledFeatures, Index(idx), functions, globals, memory, tables, declaredFuncIndexes, br); err != nil {
+		if err = m.validateFunction(vs, enabledFeatures, Index(idx), functions, globals, memories, tables, declaredFuncIndexes, br); err != nil {
 			return fmt.Errorf("invalid %s: %w", m.funcDesc(SectionIDFunction, Index(idx)), err)
 		}
 	}
@@ -449,17 +531,19 @@ func (m *Module) funcDesc(sectionID SectionID, sectionIndex Index) string {
 	return fmt.Sprintf("%s[%d] export[%s]", sectionIDName, sectionIndex, strings.Join(exportNames, ","))
 }
 
-func (m *Module) validateMemory(memory *Memory, globals []GlobalType, _ api.CoreFeatures) error {
-	var activeElementCount int
+func (m *Module) validateMemory(memories []*Memory, globals []GlobalType, enabledFeatures api.CoreFeatures) error {
+	if len(memories) > 1 {
+		if err := enabledFeatures.RequireEnabled(api.CoreFeatureMultiMemory); err != nil {
+			return fmt.Errorf("at most one memory allowed in module as %w", err)
+		}
+	}
+
 	for i := range m.DataSection {
 		d := &m.DataSection[i]
-		if !d.IsPassive() {
-			activeElementCount++
+		if !d.IsPassive() && d.MemoryIndex >= uint32(len(memories)) {
+			return fmt.Errorf("unknown memory")
 		}
 	}
-	if activeElementCount > 0 && memory == nil {
-		return fmt.Errorf("unknown memory")
-	}
 
 	// Constant expression can only reference imported globals.
 	// https://github.com/WebAssembly/spec/blob/5900d839f38641989a9d8df2df4aee0513365d39/test/core/data.wast#L84-L91
@@ -467,7 +551,7 @@ func (m *Module) validateMemory(memory *Memory, globals []GlobalType, _ api.Core
 	for i := range m.DataSection {
 		d := &m.DataSection[i]
 		if !d.IsPassive() {
-			if err := validateConstExpression(importedGlobals, 0, &d.OffsetExpression, ValueTypeI32); err != nil {
+			if err := validateConstExpression(importedGlobals, 0, &d.OffsetExpression, memories[d.MemoryIndex].AddressType()); err != nil {
 				return fmt.Errorf("calculate offset: %w", err)
 			}
 		}
@@ -475,6 +559,26 @@ func (m *Module) validateMemory(memory *Memory, globals []GlobalType, _ api.Core
 	return nil
 }
 
+// validateTags ensures the type of each tag, including imported ones, exists and has no results.
+func (m *Module) validateTags(enabledFeatures api.CoreFeatures) error {
+	tags := m.AllTags()
+	if len(tags) == 0 {
+		return nil
+	}
+	if err := enabledFeatures.RequireEnabled(api.CoreFeatureExceptionHandling); err != nil {
+		return fmt.Errorf("tags invalid: %w", err)
+	}
+	for i, typeIndex := range tags {
+		if typeIndex >= uint32(len(m.TypeSection)) {
+			return fmt.Errorf("invalid tag[%d]: type index out of range", i)
+		}
+		if tp := &m.TypeSection[typeIndex]; len(tp.Results) != 0 {
+			return fmt.Errorf("invalid tag[%d]: type must have no results: %s", i, tp)
+		}
+	}
+	return nil
+}
+
 func (m *Module) validateImports(enabledFeatures api.CoreFeatures) error {
 	for i := range m.ImportSection {
 		imp := &m.ImportSection[i]
@@ -498,7 +602,7 @@ func (m *Module) validateImports(enabledFeatures api.CoreFeatures) error {
 	return nil
 }
 
-func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []Index, globals []GlobalType, memory *Memory, tables []Table) error {
+func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []Index, globals []GlobalType, memories []*Memory, tables []Table) error {
 	for i := range m.ExportSection {
 		exp := &m.ExportSection[i]
 		index := exp.Index
@@ -518,13 +622,17 @@ func (m *Module) validateExports(enabledFeatures api.CoreFeatures, functions []I
 				return fmt.Errorf("invalid export[%q] global[%d]: %w", exp.Name, index, err)
 			}
 		case ExternTypeMemory:
-			if index > 0 || memory == nil {
+			if index >= uint32(len(memories)) {
 				return fmt.Errorf("memory for export[%q] out of range", exp.Name)
 			}
 		case ExternTypeTable:
 			if index >= uint32(len(tables)) {
 				return fmt.Errorf("table for export[%q] out of range", exp.Name)
 			}
+		case ExternTypeTag:
+			if index >= m.ImportTagCount+uint32(len(m.TagSection)) {
+				return fmt.Errorf("tag for export[%q] out of range", exp.Name)
+			}
 		}
 	}
 	return nil
@@ -648,10 +756,13 @@ func paramNames(localNames IndirectNameMap, funcIdx uint32, paramLen int) []stri
 }
 
 func (m *ModuleInstance) buildMemory(module *Module) {
-	memSec := module.MemorySection
-	if memSec != nil {
-		m.MemoryInstance = NewMemoryInstance(memSec)
-		m.MemoryInstance.definition = &module.MemoryDefinitionSection[0]
+	for i := range module.MemorySection {
+		memory := NewMemoryInstance(&module.MemorySection[i])
+		memory.definition = &module.MemoryDefinitionSection[int(module.ImportMemoryCount)+i]
+		m.Memories = append(m.Memories, memory)
+	}
+	if len(m.Memories) > 0 {
+		m.MemoryInstance = m.Memories[0]
 	}
 }
 
@@ -757,6 +868,8 @@ type Import struct {
 	DescMem *Memory
 	// DescGlobal is the inlined GlobalType when Type equals ExternTypeGlobal
 	DescGlobal GlobalType
+	// DescTag is the index in Module.TypeSection when Type equals ExternTypeTag
+	DescTag Index
 	// IndexPerType has the index of this import per ExternType.
 	IndexPerType Index
 }
@@ -766,11 +879,29 @@ type Memory struct {
 	Min, Cap, Max uint32
 	// IsMaxEncoded true if the Max is encoded in the original binary.
 	IsMaxEncoded bool
+	// IsShared true if the memory is shared for access from multiple agents.
+	IsShared bool
+	// IsMemory64 true if the memory is addressed with i64 (api.CoreFeatureMemory64).
+	IsMemory64 bool
+}
+
+// AddressType returns the type of the addresses used to access this memory, which is also the type of its size in
+// pages.
+func (m *Memory) AddressType() ValueType {
+	if m.IsMemory64 {
+		return ValueTypeI64
+	}
+	return ValueTypeI32
 }
 
 // Validate ensures values assigned to Min, Cap and Max are within valid thresholds.
+//
+// Note: memoryLimitPages is capped to MemoryLimitPages unless this is a 64-bit memory.
 func (m *Memory) Validate(memoryLimitPages uint32) error {
 	min, capacity, max := m.Min, m.Cap, m.Max
+	if !m.IsMemory64 && memoryLimitPages > MemoryLimitPages {
+		memoryLimitPages = MemoryLimitPages
+	}
 
 	if max > memoryLimitPages {
 		return fmt.Errorf("max %d pages (%s) over limit of %d pages (%s)",
@@ -841,12 +972,19 @@ type Code struct {
 	// BodyOffsetInCodeSection is the offset of the beginning of the body in the code section.
 	// This is used for DWARF based stack trace where a program counter represents an offset in code section.
 	BodyOffsetInCodeSection uint64
+
+	// OffsetInCodeSection is the offset of the beginning of the function, which is its locals, in the code section.
+	// Coredumps report the offsets of the instructions relative to it.
+	OffsetInCodeSection uint64
 }
 
 type DataSegment struct {
 	OffsetExpression ConstantExpression
 	Init             []byte
 	Passive          bool
+	// MemoryIndex is the index of the memory initialized by this segment if it is active. This can be non-zero only
+	// when api.CoreFeatureMultiMemory is enabled.
+	MemoryIndex Index
 }
 
 // IsPassive returns true if this data segment is "passive" in the sense that memory offset and
@@ -930,7 +1068,7 @@ type NameMapAssoc struct {
 }
 
 // AllDeclarations returns all declarations for functions, globals, memories and tables in a module including imported ones.
-func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, memory *Memory, tables []Table, err error) {
+func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, memories []*Memory, tables []Table, err error) {
 	for i := range m.ImportSection {
 		imp := &m.ImportSection[i]
 		switch imp.Type {
@@ -939,7 +1077,7 @@ func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, mem
 		case ExternTypeGlobal:
 			globals = append(globals, imp.DescGlobal)
 		case ExternTypeMemory:
-			memory = imp.DescMem
+			memories = append(memories, imp.DescMem)
 		case ExternTypeTable:
 			tables = append(tables, imp.DescTable)
 		}
@@ -950,12 +1088,8 @@ func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, mem
 		g := &m.GlobalSection[i]
 		globals = append(globals, g.Type)
 	}
-	if m.MemorySection != nil {
-		if memory != nil { // shouldn't be possible due to Validate
-			err = errors.New("at most one table allowed in module")
-			return
-		}
-		memory = m.MemorySection
+	for i := range m.MemorySection {
+		memories = append(memories, &m.MemorySection[i])
 	}
 	if m.TableSection != nil {
 		tables = append(tables, m.TableSection...)
@@ -963,6 +1097,40 @@ func (m *Module) AllDeclarations() (functions []Index, globals []GlobalType, mem
 	return
 }
 
+// AllTags returns the type index of each tag in a module including imported ones, indexed by the tag index.
+func (m *Module) AllTags() (tags []Index) {
+	if m.ImportTagCount > 0 {
+		for i := range m.ImportSection {
+			if imp := &m.ImportSection[i]; imp.Type == ExternTypeTag {
+				tags = append(tags, imp.DescTag)
+			}
+		}
+	}
+	return append(tags, m.TagSection...)
+}
+
+// TagType returns the type of the tag at the given tag index, which begins with imported tags, or nil if it doesn't
+// exist.
+func (m *Module) TagType(tagIndex Index) *FunctionType {
+	var typeIndex Index
+	if tagIndex < m.ImportTagCount {
+		for i := range m.ImportSection {
+			if imp := &m.ImportSection[i]; imp.Type == ExternTypeTag && imp.IndexPerType == tagIndex {
+				typeIndex = imp.DescTag
+				break
+			}
+		}
+	} else if tagIndex -= m.ImportTagCount; tagIndex < uint32(len(m.TagSection)) {
+		typeIndex = m.TagSection[tagIndex]
+	} else {
+		return nil
+	}
+	if typeIndex >= uint32(len(m.TypeSection)) {
+		return nil
+	}
+	return &m.TypeSection[typeIndex]
+}
+
 // SectionID identifies the sections of a Module in the WebAssembly 1.0 (20191205) Binary Format.
 //
 // Note: these are defined in the wasm package, instead of the binary package, as a key per section is needed regardless
@@ -991,6 +1159,12 @@ const (
 	// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/binary/modules.html#data-count-section
 	// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/appendix/changes.html#bulk-memory-and-table-instructions
 	SectionIDDataCount
+
+	// SectionIDTag requires api.CoreFeatureExceptionHandling, and is ordered between SectionIDMemory and
+	// SectionIDGlobal in the binary format.
+	//
+	// See https://webassembly.github.io/exception-handling/core/binary/modules.html#tag-section
+	SectionIDTag
 )
 
 // SectionIDName returns the canonical name of a module section.
@@ -1023,6 +1197,8 @@ func SectionIDName(sectionID SectionID) string {
 		return "data"
 	case SectionIDDataCount:
 		return "data_count"
+	case SectionIDTag:
+		return "tag"
 	}
 	return "unknown"
 }
@@ -1036,24 +1212,25 @@ const (
 	ValueTypeF32 = api.ValueTypeF32
 	ValueTypeF64 = api.ValueTypeF64
 	// TODO: ValueTypeV128 is not exposed in the api pkg yet.
-	ValueTypeV128 ValueType = 0x7b
-	// TODO: ValueTypeFuncref is not exposed in the api pkg yet.
-	ValueTypeFuncref   ValueType = 0x70
+	ValueTypeV128      ValueType = 0x7b
+	ValueTypeFuncref             = api.ValueTypeFuncref
 	ValueTypeExternref           = api.ValueTypeExternref
+	// ValueTypeExnref is a reference to a caught exception, which requires api.CoreFeatureExceptionHandling.
+	ValueTypeExnref ValueType = 0x69
 )
 
 // ValueTypeName is an alias of api.ValueTypeName defined to simplify imports.
 func ValueTypeName(t ValueType) string {
-	if t == ValueTypeFuncref {
-		return "funcref"
-	} else if t == ValueTypeV128 {
+	if t == ValueTypeV128 {
 		return "v128"
+	} else if t == ValueTypeExnref {
+		return "exnref"
 	}
 	return api.ValueTypeName(t)
 }
 
 func isReferenceValueType(vt ValueType) bool {
-	return vt == ValueTypeExternref || vt == ValueTypeFuncref
+	return vt == ValueTypeExternref || vt == ValueTypeFuncref || vt == ValueTypeExnref
 }
 
 // ExternType is an alias of api.ExternType defined to simplify imports.
@@ -1068,6 +1245,8 @@ const (
 	ExternTypeMemoryName = api.ExternTypeMemoryName
 	ExternTypeGlobal     = api.ExternTypeGlobal
 	ExternTypeGlobalName = api.ExternTypeGlobalName
+	ExternTypeTag        = api.ExternTypeTag
+	ExternTypeTagName    = api.ExternTypeTagName
 )
 
 // ExternTypeName is an alias of api.ExternTypeName defined to simplify imports.
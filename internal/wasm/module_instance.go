@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"sync/atomic"
 
 	"github.com/AR1011/wazero/api"
 	"github.com/AR1011/wazero/sys"
@@ -180,27 +182,32 @@ func (m *ModuleInstance) Memory() api.Memory {
 
 // ExportedMemory implements the same method as documented on api.Module.
 func (m *ModuleInstance) ExportedMemory(name string) api.Memory {
-	_, err := m.getExport(name, ExternTypeMemory)
+	exp, err := m.getExport(name, ExternTypeMemory)
 	if err != nil {
 		return nil
 	}
-	// We Assume that we have at most one memory.
-	return m.MemoryInstance
+	return m.Memories[exp.Index]
 }
 
 // ExportedMemoryDefinitions implements the same method as documented on
 // api.Module.
 func (m *ModuleInstance) ExportedMemoryDefinitions() map[string]api.MemoryDefinition {
-	// Special case as we currently only support one memory.
-	if mem := m.MemoryInstance; mem != nil {
-		// Now, find out if it is exported
-		for name, exp := range m.Exports {
-			if exp.Type == ExternTypeMemory {
-				return map[string]api.MemoryDefinition{name: mem.definition}
-			}
+	ret := map[string]api.MemoryDefinition{}
+	for name, exp := range m.Exports {
+		if exp.Type == ExternTypeMemory {
+			ret[name] = m.Memories[exp.Index].definition
 		}
 	}
-	return map[string]api.MemoryDefinition{}
+	return ret
+}
+
+// ExportedTable implements the same method as documented on api.Module.
+func (m *ModuleInstance) ExportedTable(name string) api.Table {
+	exp, err := m.getExport(name, ExternTypeTable)
+	if err != nil {
+		return nil
+	}
+	return exportedTable{t: m.Tables[exp.Index]}
 }
 
 // ExportedFunction implements the same method as documented on api.Module.
@@ -242,6 +249,90 @@ func (m *ModuleInstance) ExportedGlobal(name string) api.Global {
 	return constantGlobal{g: g}
 }
 
+// ResourceLimiter is consulted before a memory or a table defined by a module grows.
+//
+// See wazero.ResourceLimiter
+type ResourceLimiter interface {
+	// MemoryGrowing returns true if the memory can grow from current to desired pages. A non-nil error traps.
+	MemoryGrowing(current, desired uint32) (bool, error)
+
+	// TableGrowing returns true if the table can grow from current to desired elements. A non-nil error traps.
+	TableGrowing(current, desired uint32) (bool, error)
+}
+
+// setResourceLimiter sets the limiter to the memories and tables defined by the module.
+func (m *ModuleInstance) setResourceLimiter(module *Module, limiter ResourceLimiter) {
+	for _, t := range m.Tables[module.ImportTableCount:] {
+		t.Limiter = limiter
+	}
+	for _, mem := range m.Memories[module.ImportMemoryCount:] {
+		mem.Limiter = limiter
+	}
+}
+
+// maxFuel is the maximum value of ModuleInstance.Fuel.
+const maxFuel = uint64(math.MaxInt64)
+
+// SetFuel implements the same method as documented on api.Module.
+func (m *ModuleInstance) SetFuel(fuel uint64) {
+	if fuel > maxFuel {
+		fuel = maxFuel
+	}
+	m.Fuel = int64(fuel)
+}
+
+// AddFuel implements the same method as documented on api.Module.
+func (m *ModuleInstance) AddFuel(fuel uint64) {
+	remaining := m.RemainingFuel()
+	if fuel > maxFuel-remaining {
+		m.Fuel = int64(maxFuel)
+	} else {
+		m.Fuel = int64(remaining + fuel)
+	}
+}
+
+// RemainingFuel implements the same method as documented on api.Module.
+func (m *ModuleInstance) RemainingFuel() uint64 {
+	if m.Fuel < 0 {
+		return 0
+	}
+	return uint64(m.Fuel)
+}
+
+// SetEpochDeadline implements the same method as documented on api.Module.
+func (m *ModuleInstance) SetEpochDeadline(ticks uint64) {
+	m.EpochDeadline = m.EpochAfter(ticks)
+}
+
+// EpochAfter returns the value of Epoch the given number of ticks after the current one, or math.MaxUint64 if that
+// overflows.
+func (m *ModuleInstance) EpochAfter(ticks uint64) uint64 {
+	var epoch uint64
+	if m.Epoch != nil {
+		epoch = atomic.LoadUint64(m.Epoch)
+	}
+	if deadline := epoch + ticks; deadline >= epoch {
+		return deadline
+	}
+	return math.MaxUint64
+}
+
+// EpochDeadlineExceeded returns true if the epoch of the Store reached EpochDeadline or the given deadline of the
+// current call.
+func (m *ModuleInstance) EpochDeadlineExceeded(callDeadline uint64) bool {
+	epoch := atomic.LoadUint64(m.Epoch)
+	return epoch >= m.EpochDeadline || epoch >= callDeadline
+}
+
+// ExportedTag implements the same method as documented on api.Module.
+func (m *ModuleInstance) ExportedTag(name string) api.Tag {
+	exp, err := m.getExport(name, ExternTypeTag)
+	if err != nil {
+		return nil
+	}
+	return m.Tags[exp.Index]
+}
+
 // NumGlobal implements experimental.InternalModule.
 func (m *ModuleInstance) NumGlobal() int {
 	return len(m.Globals)
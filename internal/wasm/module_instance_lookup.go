@@ -22,9 +22,9 @@ func (m *ModuleInstance) LookupFunction(t *TableInstance, typeId FunctionTypeID,
 		switch typed := goF.(type) {
 		case api.GoFunction:
 			// GoFunction doesn't need looked up module.
-			return &lookedUpGoFunction{def: def, g: goFunctionAsGoModuleFunction(typed)}
+			return &lookedUpGoFunction{def: def, owner: fm, index: index, g: goFunctionAsGoModuleFunction(typed)}
 		case api.GoModuleFunction:
-			return &lookedUpGoFunction{def: def, lookedUpModule: m, g: typed}
+			return &lookedUpGoFunction{def: def, owner: fm, index: index, lookedUpModule: m, g: typed}
 		default:
 			panic(fmt.Sprintf("unexpected GoFunc type: %T", goF))
 		}
@@ -40,6 +40,9 @@ type lookedUpGoFunction struct {
 	// lookedUpModule is the *ModuleInstance from which this Go function is looked up, i.e. owner of the table.
 	lookedUpModule *ModuleInstance
 	g              api.GoModuleFunction
+	// owner is the host module defining this function at the index.
+	owner *ModuleInstance
+	index Index
 }
 
 // goFunctionAsGoModuleFunction converts api.GoFunction to api.GoModuleFunction which ignores the api.Module argument.
@@ -52,6 +55,11 @@ func goFunctionAsGoModuleFunction(g api.GoFunction) api.GoModuleFunction {
 // Definition implements api.Function.
 func (l *lookedUpGoFunction) Definition() api.FunctionDefinition { return l.def }
 
+// FunctionReference implements functionReferencer.
+func (l *lookedUpGoFunction) FunctionReference() Reference {
+	return l.owner.Engine.FunctionInstanceReference(l.index)
+}
+
 // Call implements api.Function.
 func (l *lookedUpGoFunction) Call(ctx context.Context, params ...uint64) ([]uint64, error) {
 	typ := l.def.Functype
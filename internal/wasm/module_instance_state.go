@@ -0,0 +1,152 @@
+package wasm
+
+import (
+	"errors"
+
+	"github.com/AR1011/wazero/internal/platform"
+)
+
+// InstanceState is the state of a ModuleInstance captured by ModuleInstance.CaptureState, which can be restored by
+// ModuleInstance.ResetState any number of times.
+//
+// Only the state defined by the module is captured, e.g. imported memories are not restored.
+type InstanceState struct {
+	memories         []memoryState
+	globals          []globalState
+	tables           []tableState
+	dataInstances    []DataInstance
+	elementInstances []ElementInstance
+	fuel             int64
+	epochDeadline    uint64
+}
+
+type memoryState struct {
+	mem *MemoryInstance
+	// image is a copy of the contents of mem, or nil if mapping is non-nil.
+	image []byte
+	// mapping is the copy-on-write mapping which backs mem.Buffer, and size is the initial length of mem.Buffer.
+	mapping []byte
+	size    int
+}
+
+type globalState struct {
+	g      *GlobalInstance
+	lo, hi uint64
+}
+
+type tableState struct {
+	t          *TableInstance
+	references []Reference
+}
+
+// CaptureState captures the current state of this module, which must not be executing any function.
+//
+// When copyOnWrite is true and platform.CopyOnWriteSupported, the memories which are not shared are moved to
+// copy-on-write mappings, so that ResetState only needs to discard the pages written since the capture. Otherwise, or
+// if the mapping fails, the contents of the memories are copied. InstanceState.Release must be called to release the
+// mappings once this module is closed.
+func (m *ModuleInstance) CaptureState(copyOnWrite bool) *InstanceState {
+	s := &InstanceState{
+		dataInstances:    append([]DataInstance(nil), m.DataInstances...),
+		elementInstances: append([]ElementInstance(nil), m.ElementInstances...),
+		fuel:             m.Fuel,
+		epochDeadline:    m.EpochDeadline,
+	}
+
+	source := m.Source
+	for _, mem := range m.Memories[source.ImportMemoryCount:] {
+		ms := memoryState{mem: mem, size: len(mem.Buffer)}
+		// The mapping reserves the maximum size, so that the buffer never relocates on Grow.
+		reserve := MemoryPagesToBytesNum(mem.Max)
+		if copyOnWrite && platform.CopyOnWriteSupported && !mem.Shared && reserve > 0 && mem.Max <= MemoryLimitPages {
+			if mapping, err := platform.MmapCopyOnWrite(mem.Buffer, int(reserve)); err == nil {
+				ms.mapping = mapping
+				mem.Buffer = mapping[:ms.size]
+				mem.Cap = mem.Max
+			}
+		}
+		if ms.mapping == nil {
+			ms.image = append([]byte{}, mem.Buffer...)
+		}
+		s.memories = append(s.memories, ms)
+	}
+	if len(s.memories) > 0 {
+		m.Engine.MemoryChanged()
+	}
+
+	for _, g := range m.Globals[source.ImportGlobalCount:] {
+		if g.Type.Mutable {
+			lo, hi := g.Value()
+			s.globals = append(s.globals, globalState{g: g, lo: lo, hi: hi})
+		}
+	}
+
+	for _, t := range m.Tables[source.ImportTableCount:] {
+		s.tables = append(s.tables, tableState{t: t, references: append([]Reference{}, t.References...)})
+	}
+	return s
+}
+
+// ResetState restores the state captured by CaptureState. This module must not be executing any function.
+func (m *ModuleInstance) ResetState(s *InstanceState) error {
+	if m.Closed.Load() != 0 {
+		return errors.New("module closed")
+	}
+
+	for i := range s.memories {
+		ms := &s.memories[i]
+		mem := ms.mem
+		if ms.mapping != nil {
+			if err := platform.ResetCopyOnWrite(mem.Buffer); err != nil {
+				return err
+			}
+			mem.Buffer = mem.Buffer[:ms.size]
+		} else {
+			// Grow assumes the region past the length is zeroed, so clear it before shrinking.
+			grown := mem.Buffer[len(ms.image):]
+			for i := range grown {
+				grown[i] = 0
+			}
+			mem.Buffer = mem.Buffer[:len(ms.image)]
+			copy(mem.Buffer, ms.image)
+		}
+	}
+	if len(s.memories) > 0 {
+		m.Engine.MemoryChanged()
+	}
+
+	for i := range s.globals {
+		gs := &s.globals[i]
+		if g := gs.g; g.Me != nil {
+			g.Me.SetGlobalValue(g.Index, gs.lo, gs.hi)
+		} else {
+			g.Val, g.ValHi = gs.lo, gs.hi
+		}
+	}
+
+	for i := range s.tables {
+		ts := &s.tables[i]
+		ts.t.References = append(ts.t.References[:0], ts.references...)
+	}
+
+	// Copy in place, as the engines may refer to the first element of these.
+	copy(m.DataInstances, s.dataInstances)
+	copy(m.ElementInstances, s.elementInstances)
+	m.Fuel, m.EpochDeadline = s.fuel, s.epochDeadline
+	return nil
+}
+
+// Release releases the copy-on-write mappings of the memories, so the module instance must be closed before this.
+func (s *InstanceState) Release() (err error) {
+	for i := range s.memories {
+		ms := &s.memories[i]
+		if ms.mapping != nil {
+			ms.mem.Buffer = nil
+			if e := platform.MunmapCopyOnWrite(ms.mapping); e != nil && err == nil {
+				err = e
+			}
+			ms.mapping = nil
+		}
+	}
+	return
+}
@@ -0,0 +1,78 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+)
+
+func TestModuleInstance_ResetState(t *testing.T) {
+	for _, copyOnWrite := range []bool{false, true} {
+		copyOnWrite := copyOnWrite
+		name := "copy"
+		if copyOnWrite {
+			name = "copy-on-write"
+		}
+		t.Run(name, func(t *testing.T) {
+			imported := &MemoryInstance{Buffer: []byte{1}, Max: 1}
+			mem := NewMemoryInstance(&Memory{Min: 1, Cap: 1, Max: 3})
+			copy(mem.Buffer, "wazero")
+			g := &GlobalInstance{Type: GlobalType{ValType: ValueTypeI32, Mutable: true}, Val: 1}
+			table := &TableInstance{References: []Reference{1, 2}}
+			m := &ModuleInstance{
+				Source:           &Module{ImportMemoryCount: 1, ImportGlobalCount: 1, ImportTableCount: 1},
+				Engine:           &mockModuleEngine{},
+				Memories:         []*MemoryInstance{imported, mem},
+				MemoryInstance:   imported,
+				Globals:          []*GlobalInstance{{Type: GlobalType{ValType: ValueTypeI32, Mutable: true}}, g},
+				Tables:           []*TableInstance{{}, table},
+				DataInstances:    []DataInstance{[]byte("bears")},
+				ElementInstances: []ElementInstance{{1}},
+				Fuel:             100,
+			}
+
+			s := m.CaptureState(copyOnWrite)
+			defer func() {
+				require.NoError(t, s.Release())
+			}()
+			if copyOnWrite && platform.CopyOnWriteSupported {
+				require.Equal(t, mem.Max, mem.Cap)
+			}
+
+			for i := 0; i < 2; i++ {
+				// Mutate everything.
+				imported.Buffer[0] = 2
+				_, ok := mem.Grow(1)
+				require.True(t, ok)
+				copy(mem.Buffer, "bears")
+				mem.Buffer[len(mem.Buffer)-1] = 1
+				g.Val = 2
+				table.Grow(1, 3)
+				table.References[0] = 0
+				m.DataInstances[0] = nil
+				m.ElementInstances[0] = nil
+				m.Fuel = 0
+
+				require.NoError(t, m.ResetState(s))
+				require.Equal(t, byte(2), imported.Buffer[0]) // Imported state isn't reset.
+				require.Equal(t, int(MemoryPageSize), len(mem.Buffer))
+				require.Equal(t, "wazero", string(mem.Buffer[:6]))
+				require.Equal(t, uint64(1), g.Val)
+				require.Equal(t, []Reference{1, 2}, table.References)
+				require.Equal(t, "bears", string(m.DataInstances[0]))
+				require.Equal(t, ElementInstance{1}, m.ElementInstances[0])
+				require.Equal(t, int64(100), m.Fuel)
+
+				// The region grown before the reset is zeroed when growing again.
+				_, ok = mem.Grow(1)
+				require.True(t, ok)
+				require.Equal(t, make([]byte, MemoryPageSize), mem.Buffer[MemoryPageSize:])
+				require.NoError(t, m.ResetState(s))
+			}
+
+			m.Closed.Store(1)
+			require.EqualError(t, m.ResetState(s), "module closed")
+		})
+	}
+}
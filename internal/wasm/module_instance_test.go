@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"sync"
 	"testing"
 	"time"
@@ -39,7 +40,7 @@ func TestModuleInstance_String(t *testing.T) {
 
 		t.Run(tc.name, func(t *testing.T) {
 			// Ensure paths that can create the host module can see the name.
-			m, err := s.Instantiate(testCtx, &Module{}, tc.moduleName, nil, nil)
+			m, err := s.Instantiate(testCtx, &Module{}, tc.moduleName, nil, nil, 0, nil)
 			defer m.Close(testCtx) //nolint
 
 			require.NoError(t, err)
@@ -86,7 +87,7 @@ func TestModuleInstance_Close(t *testing.T) {
 		t.Run(fmt.Sprintf("%s calls ns.CloseWithExitCode(module.name))", tc.name), func(t *testing.T) {
 			for _, ctx := range []context.Context{nil, testCtx} { // Ensure it doesn't crash on nil!
 				moduleName := t.Name()
-				m, err := s.Instantiate(ctx, &Module{}, moduleName, nil, nil)
+				m, err := s.Instantiate(ctx, &Module{}, moduleName, nil, nil, 0, nil)
 				require.NoError(t, err)
 
 				// We use side effects to see if Close called ns.CloseWithExitCode (without repeating store_test.go).
@@ -118,7 +119,7 @@ func TestModuleInstance_Close(t *testing.T) {
 		_, errno := fsCtx.OpenFile(testFS, "/foo", sys.O_RDONLY, 0)
 		require.EqualErrno(t, 0, errno)
 
-		m, err := s.Instantiate(testCtx, &Module{}, t.Name(), sysCtx, nil)
+		m, err := s.Instantiate(testCtx, &Module{}, t.Name(), sysCtx, nil, 0, nil)
 		require.NoError(t, err)
 
 		// We use side effects to determine if Close in fact called Context.Close (without repeating sys_test.go).
@@ -153,7 +154,7 @@ func TestModuleInstance_Close(t *testing.T) {
 		_, errno := fsCtx.OpenFile(testFS, "/foo", sys.O_RDONLY, 0)
 		require.EqualErrno(t, 0, errno)
 
-		m, err := s.Instantiate(testCtx, &Module{}, t.Name(), sysCtx, nil)
+		m, err := s.Instantiate(testCtx, &Module{}, t.Name(), sysCtx, nil, 0, nil)
 		require.NoError(t, err)
 
 		// In sys.FS, non syscall errors map to sys.EIO.
@@ -194,7 +195,7 @@ func TestModuleInstance_CallDynamic(t *testing.T) {
 		t.Run(fmt.Sprintf("%s calls ns.CloseWithExitCode(module.name))", tc.name), func(t *testing.T) {
 			for _, ctx := range []context.Context{nil, testCtx} { // Ensure it doesn't crash on nil!
 				moduleName := t.Name()
-				m, err := s.Instantiate(ctx, &Module{}, moduleName, nil, nil)
+				m, err := s.Instantiate(ctx, &Module{}, moduleName, nil, nil, 0, nil)
 				require.NoError(t, err)
 
 				// We use side effects to see if Close called ns.CloseWithExitCode (without repeating store_test.go).
@@ -223,7 +224,7 @@ func TestModuleInstance_CallDynamic(t *testing.T) {
 		_, errno := fsCtx.OpenFile(testFS, "/foo", sys.O_RDONLY, 0)
 		require.EqualErrno(t, 0, errno)
 
-		m, err := s.Instantiate(testCtx, &Module{}, t.Name(), sysCtx, nil)
+		m, err := s.Instantiate(testCtx, &Module{}, t.Name(), sysCtx, nil, 0, nil)
 		require.NoError(t, err)
 
 		// We use side effects to determine if Close in fact called Context.Close (without repeating sys_test.go).
@@ -252,7 +253,7 @@ func TestModuleInstance_CallDynamic(t *testing.T) {
 		_, errno := fsCtx.OpenFile(testFS, path, sys.O_RDONLY, 0)
 		require.EqualErrno(t, 0, errno)
 
-		m, err := s.Instantiate(testCtx, &Module{}, t.Name(), sysCtx, nil)
+		m, err := s.Instantiate(testCtx, &Module{}, t.Name(), sysCtx, nil, 0, nil)
 		require.NoError(t, err)
 
 		// In sys.FS, non syscall errors map to sys.EIO.
@@ -452,3 +453,54 @@ func TestModuleInstance_ensureResourcesClosed(t *testing.T) {
 	}
 	require.Equal(t, 2, closer.called)
 }
+
+func TestModuleInstance_Fuel(t *testing.T) {
+	m := &ModuleInstance{}
+	require.Equal(t, uint64(0), m.RemainingFuel())
+
+	m.SetFuel(10)
+	require.Equal(t, int64(10), m.Fuel)
+	m.AddFuel(5)
+	require.Equal(t, uint64(15), m.RemainingFuel())
+
+	// The negative fuel left after running out is not visible, and is reset on AddFuel.
+	m.Fuel = -1
+	require.Equal(t, uint64(0), m.RemainingFuel())
+	m.AddFuel(5)
+	require.Equal(t, uint64(5), m.RemainingFuel())
+
+	// Both SetFuel and AddFuel saturate at math.MaxInt64.
+	m.SetFuel(math.MaxUint64)
+	require.Equal(t, uint64(math.MaxInt64), m.RemainingFuel())
+	m.SetFuel(10)
+	m.AddFuel(math.MaxInt64)
+	require.Equal(t, uint64(math.MaxInt64), m.RemainingFuel())
+}
+
+func TestModuleInstance_SetEpochDeadline(t *testing.T) {
+	s := newStore()
+	m := &ModuleInstance{Epoch: s.epoch}
+
+	m.SetEpochDeadline(2)
+	require.Equal(t, uint64(2), m.EpochDeadline)
+	require.False(t, m.EpochDeadlineExceeded(math.MaxUint64))
+
+	s.IncrementEpoch()
+	require.False(t, m.EpochDeadlineExceeded(math.MaxUint64))
+	s.IncrementEpoch()
+	require.True(t, m.EpochDeadlineExceeded(math.MaxUint64))
+
+	// The deadline is relative to the current epoch.
+	m.SetEpochDeadline(1)
+	require.Equal(t, uint64(3), m.EpochDeadline)
+	require.False(t, m.EpochDeadlineExceeded(math.MaxUint64))
+
+	// The deadline saturates.
+	m.SetEpochDeadline(math.MaxUint64)
+	require.Equal(t, uint64(math.MaxUint64), m.EpochDeadline)
+
+	// The deadline of the call applies even when the module has none.
+	require.True(t, m.EpochDeadlineExceeded(m.EpochAfter(0)))
+	require.False(t, m.EpochDeadlineExceeded(m.EpochAfter(1)))
+	require.Equal(t, uint64(math.MaxUint64), m.EpochAfter(math.MaxUint64))
+}
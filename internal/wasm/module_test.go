@@ -2,6 +2,7 @@ package wasm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"testing"
@@ -88,7 +89,7 @@ func TestMemory_Validate(t *testing.T) {
 		{
 			name:        "cap > maxLimit",
 			mem:         &Memory{Min: 2, Cap: math.MaxUint32, Max: 2},
-			expectedErr: "capacity 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "capacity 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 		{
 			name:        "max < min",
@@ -98,12 +99,12 @@ func TestMemory_Validate(t *testing.T) {
 		{
 			name:        "min > limit",
 			mem:         &Memory{Min: math.MaxUint32},
-			expectedErr: "min 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "min 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 		{
 			name:        "max > limit",
 			mem:         &Memory{Max: math.MaxUint32, IsMaxEncoded: true},
-			expectedErr: "max 4294967295 pages (3 Ti) over limit of 65536 pages (4 Gi)",
+			expectedErr: "max 4294967295 pages (255 Ti) over limit of 65536 pages (4 Gi)",
 		},
 	}
 
@@ -126,7 +127,7 @@ func TestModule_allDeclarations(t *testing.T) {
 		module            *Module
 		expectedFunctions []Index
 		expectedGlobals   []GlobalType
-		expectedMemory    *Memory
+		expectedMemories  []*Memory
 		expectedTables    []Table
 	}{
 		// Functions.
@@ -174,13 +175,20 @@ func TestModule_allDeclarations(t *testing.T) {
 			module: &Module{
 				ImportSection: []Import{{Type: ExternTypeMemory, DescMem: &Memory{Min: 1, Max: 10}}},
 			},
-			expectedMemory: &Memory{Min: 1, Max: 10},
+			expectedMemories: []*Memory{{Min: 1, Max: 10}},
 		},
 		{
 			module: &Module{
-				MemorySection: &Memory{Min: 100},
+				MemorySection: []Memory{{Min: 100}},
 			},
-			expectedMemory: &Memory{Min: 100},
+			expectedMemories: []*Memory{{Min: 100}},
+		},
+		{
+			module: &Module{
+				ImportSection: []Import{{Type: ExternTypeMemory, DescMem: &Memory{Min: 1, Max: 10}}},
+				MemorySection: []Memory{{Min: 100}, {Min: 200}},
+			},
+			expectedMemories: []*Memory{{Min: 1, Max: 10}, {Min: 100}, {Min: 200}},
 		},
 		// Tables.
 		{
@@ -200,12 +208,12 @@ func TestModule_allDeclarations(t *testing.T) {
 	for i, tt := range tests {
 		tc := tt
 		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
-			functions, globals, memory, tables, err := tc.module.AllDeclarations()
+			functions, globals, memories, tables, err := tc.module.AllDeclarations()
 			require.NoError(t, err)
 			require.Equal(t, tc.expectedFunctions, functions)
 			require.Equal(t, tc.expectedGlobals, globals)
 			require.Equal(t, tc.expectedTables, tables)
-			require.Equal(t, tc.expectedMemory, memory)
+			require.Equal(t, tc.expectedMemories, memories)
 		})
 	}
 }
@@ -500,6 +508,21 @@ func TestModule_validateFunctions(t *testing.T) {
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "invalid function[0]: cannot pop the 1st f32 operand")
 	})
+	t.Run("error locates instruction", func(t *testing.T) {
+		m := Module{
+			TypeSection:     []FunctionType{v_v},
+			FunctionSection: []Index{0, 0},
+			CodeSection: []Code{
+				{Body: []byte{OpcodeEnd}},
+				{Body: []byte{OpcodeNop, OpcodeF32Abs, OpcodeEnd}, BodyOffsetInCodeSection: 10},
+			},
+		}
+		err := m.validateFunctions(api.CoreFeaturesV1, nil, nil, nil, nil, MaximumFunctionIndex)
+		var fe *FunctionError
+		require.True(t, errors.As(err, &fe))
+		require.Equal(t, Index(1), fe.Index)
+		require.Equal(t, uint64(11), fe.Offset)
+	})
 	t.Run("in- exported", func(t *testing.T) {
 		m := Module{
 			TypeSection:     []FunctionType{v_v},
@@ -553,7 +576,7 @@ func TestModule_validateMemory(t *testing.T) {
 				Opcode: OpcodeUnreachable, // Invalid!
 			},
 		}}}
-		err := m.validateMemory(&Memory{}, nil, api.CoreFeaturesV1)
+		err := m.validateMemory([]*Memory{{}}, nil, api.CoreFeaturesV1)
 		require.EqualError(t, err, "calculate offset: invalid opcode for const expression: 0x0")
 	})
 	t.Run("ok", func(t *testing.T) {
@@ -564,7 +587,28 @@ func TestModule_validateMemory(t *testing.T) {
 				Data:   leb128.EncodeInt32(1),
 			},
 		}}}
-		err := m.validateMemory(&Memory{}, nil, api.CoreFeaturesV1)
+		err := m.validateMemory([]*Memory{{}}, nil, api.CoreFeaturesV1)
+		require.NoError(t, err)
+	})
+	t.Run("multiple memories disabled", func(t *testing.T) {
+		m := Module{}
+		err := m.validateMemory([]*Memory{{}, {}}, nil, api.CoreFeaturesV2)
+		require.EqualError(t, err, `at most one memory allowed in module as feature "multi-memory" is disabled`)
+	})
+	t.Run("data segment memory index out of range", func(t *testing.T) {
+		m := Module{DataSection: []DataSegment{{
+			OffsetExpression: ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeInt32(0)},
+			MemoryIndex:      2,
+		}}}
+		err := m.validateMemory([]*Memory{{}, {}}, nil, api.CoreFeaturesV2|api.CoreFeatureMultiMemory)
+		require.EqualError(t, err, "unknown memory")
+	})
+	t.Run("multiple memories", func(t *testing.T) {
+		m := Module{DataSection: []DataSegment{{
+			OffsetExpression: ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeInt32(0)},
+			MemoryIndex:      1,
+		}}}
+		err := m.validateMemory([]*Memory{{}, {}}, nil, api.CoreFeaturesV2|api.CoreFeatureMultiMemory)
 		require.NoError(t, err)
 	})
 }
@@ -651,7 +695,7 @@ func TestModule_validateExports(t *testing.T) {
 		exportSection   []Export
 		functions       []Index
 		globals         []GlobalType
-		memory          *Memory
+		memories        []*Memory
 		tables          []Table
 		expectedErr     string
 	}{
@@ -718,7 +762,13 @@ func TestModule_validateExports(t *testing.T) {
 			name:            "memory",
 			enabledFeatures: api.CoreFeaturesV1,
 			exportSection:   []Export{{Type: ExternTypeMemory, Index: 0}},
-			memory:          &Memory{},
+			memories:        []*Memory{{}},
+		},
+		{
+			name:            "multiple memories",
+			enabledFeatures: api.CoreFeaturesV2 | api.CoreFeatureMultiMemory,
+			exportSection:   []Export{{Type: ExternTypeMemory, Index: 0}, {Type: ExternTypeMemory, Index: 1}},
+			memories:        []*Memory{{}, {}},
 		},
 		{
 			name:            "memory out of range",
@@ -733,7 +783,7 @@ func TestModule_validateExports(t *testing.T) {
 		tc := tt
 		t.Run(tc.name, func(t *testing.T) {
 			m := Module{ExportSection: tc.exportSection}
-			err := m.validateExports(tc.enabledFeatures, tc.functions, tc.globals, tc.memory, tc.tables)
+			err := m.validateExports(tc.enabledFeatures, tc.functions, tc.globals, tc.memories, tc.tables)
 			if tc.expectedErr != "" {
 				require.EqualError(t, err, tc.expectedErr)
 			} else {
@@ -848,7 +898,7 @@ func TestModule_buildMemoryInstance(t *testing.T) {
 		mDef := MemoryDefinition{moduleName: "foo"}
 		m := ModuleInstance{}
 		m.buildMemory(&Module{
-			MemorySection:           &Memory{Min: min, Cap: min, Max: max},
+			MemorySection:           []Memory{{Min: min, Cap: min, Max: max}},
 			MemoryDefinitionSection: []MemoryDefinition{mDef},
 		})
 		mem := m.MemoryInstance
@@ -1013,8 +1063,8 @@ func TestModule_declaredFunctionIndexes(t *testing.T) {
 }
 
 func TestModule_AssignModuleID(t *testing.T) {
-	getID := func(bin []byte, lsns []experimental.FunctionListener, withEnsureTermination bool) ModuleID {
-		m := Module{}
+	getID := func(bin []byte, lsns []experimental.FunctionListener, withEnsureTermination, fuelMetering, epochInterruption bool, maxCallStackDepth uint32) ModuleID {
+		m := Module{FuelMetering: fuelMetering, EpochInterruption: epochInterruption, MaxCallStackDepth: maxCallStackDepth}
 		m.AssignModuleID(bin, lsns, withEnsureTermination)
 		return m.ID
 	}
@@ -1026,10 +1076,19 @@ func TestModule_AssignModuleID(t *testing.T) {
 	for i, tc := range []struct {
 		bin                   []byte
 		withEnsureTermination bool
+		fuelMetering          bool
+		epochInterruption     bool
+		maxCallStackDepth     uint32
 		listeners             []experimental.FunctionListener
 	}{
 		{bin: []byte{1, 2, 3}, withEnsureTermination: false},
 		{bin: []byte{1, 2, 3}, withEnsureTermination: true},
+		{bin: []byte{1, 2, 3}, fuelMetering: true},
+		{bin: []byte{1, 2, 3}, withEnsureTermination: true, fuelMetering: true},
+		{bin: []byte{1, 2, 3}, epochInterruption: true},
+		{bin: []byte{1, 2, 3}, fuelMetering: true, epochInterruption: true},
+		{bin: []byte{1, 2, 3}, maxCallStackDepth: 100},
+		{bin: []byte{1, 2, 3}, epochInterruption: true, maxCallStackDepth: 100},
 		{
 			bin:                   []byte{1, 2, 3},
 			listeners:             []experimental.FunctionListener{ml},
@@ -1083,11 +1142,28 @@ func TestModule_AssignModuleID(t *testing.T) {
 			withEnsureTermination: false,
 		},
 	} {
-		id := getID(tc.bin, tc.listeners, tc.withEnsureTermination)
+		id := getID(tc.bin, tc.listeners, tc.withEnsureTermination, tc.fuelMetering, tc.epochInterruption, tc.maxCallStackDepth)
 		_, exist := exists[id]
 		require.False(t, exist, i)
 		exists[id] = struct{}{}
 	}
+
+	// The compiled code only depends on whether the call stack depth is limited.
+	require.Equal(t, getID([]byte{1, 2, 3}, nil, false, false, false, 100),
+		getID([]byte{1, 2, 3}, nil, false, false, false, 200))
+}
+
+func TestModule_CallStackDepthCeiling(t *testing.T) {
+	ctx := experimental.WithMaxCallStackDepth(context.Background(), 10)
+
+	m := &Module{}
+	require.Equal(t, uint32(0), m.CallStackDepthCeiling(context.Background()))
+	// The override has no effect unless MaxCallStackDepth is configured.
+	require.Equal(t, uint32(0), m.CallStackDepthCeiling(ctx))
+
+	m.MaxCallStackDepth = 100
+	require.Equal(t, uint32(100), m.CallStackDepthCeiling(context.Background()))
+	require.Equal(t, uint32(10), m.CallStackDepthCeiling(ctx))
 }
 
 type mockListener struct{}
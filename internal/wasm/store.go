@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"sync"
 	"sync/atomic"
 
@@ -58,6 +59,15 @@ type (
 		// Note: this is fixed to 2^27 but have this a field for testability.
 		functionMaxTypes uint32
 
+		// epoch is the epoch counter shared by all the modules in this store. This is allocated separately
+		// so that ModuleInstance.Epoch can point to it from the native code.
+		//
+		// Note: Exclusively reading and updating this with atomics guarantees cross-goroutine observations.
+		epoch *uint64
+
+		// Externrefs maps the externref values passed to or from host functions to Go values.
+		Externrefs *ExternrefTable
+
 		// mux is used to guard the fields from concurrent access.
 		mux sync.RWMutex
 	}
@@ -126,6 +136,29 @@ type (
 
 		// CloseNotifier is an experimental hook called once on close.
 		CloseNotifier close.Notifier
+
+		// Memories holds all the memories of this module including the imported ones, indexed by the memory index.
+		// This has more than one element only when api.CoreFeatureMultiMemory is enabled, and MemoryInstance is
+		// always the first element if any.
+		//
+		// Note: this is the last field so that adding it didn't change the offsets used by the native code.
+		Memories []*MemoryInstance
+
+		// Tags holds all the exception tags of this module including the imported ones, indexed by the tag index.
+		// This is only non-empty when api.CoreFeatureExceptionHandling is enabled.
+		Tags []*TagInstance
+
+		// Fuel is the fuel remaining for the functions defined in this module, which is only consumed
+		// when Module.FuelMetering is true. The function call traps once this goes negative.
+		//
+		// Note: this is accessed by the native code, so the offset must be kept in sync with the engines.
+		Fuel int64
+
+		// EpochDeadline is the value of Epoch at which the functions defined in this module trap, which is only
+		// checked when Module.EpochInterruption is true.
+		EpochDeadline uint64
+		// Epoch points to the epoch counter of the Store.
+		Epoch *uint64
 	}
 
 	// DataInstance holds bytes corresponding to the data segment in a module.
@@ -250,9 +283,7 @@ func (m *ModuleInstance) validateData(data []DataSegment) (err error) {
 	for i := range data {
 		d := &data[i]
 		if !d.IsPassive() {
-			offset := int(executeConstExpressionI32(m.Globals, &d.OffsetExpression))
-			ceil := offset + len(d.Init)
-			if offset < 0 || ceil > len(m.MemoryInstance.Buffer) {
+			if _, ok := m.dataSegmentOffset(d); !ok {
 				return fmt.Errorf("%s[%d]: out of bounds memory access", SectionIDName(SectionIDData), i)
 			}
 		}
@@ -269,16 +300,31 @@ func (m *ModuleInstance) applyData(data []DataSegment) error {
 		d := &data[i]
 		m.DataInstances[i] = d.Init
 		if !d.IsPassive() {
-			offset := executeConstExpressionI32(m.Globals, &d.OffsetExpression)
-			if offset < 0 || int(offset)+len(d.Init) > len(m.MemoryInstance.Buffer) {
+			offset, ok := m.dataSegmentOffset(d)
+			if !ok {
 				return fmt.Errorf("%s[%d]: out of bounds memory access", SectionIDName(SectionIDData), i)
 			}
-			copy(m.MemoryInstance.Buffer[offset:], d.Init)
+			copy(m.Memories[d.MemoryIndex].Buffer[offset:], d.Init)
 		}
 	}
 	return nil
 }
 
+// dataSegmentOffset returns the offset of the active data segment in its memory, and false if the segment doesn't fit
+// in the memory. The offset is an i64 when the memory is 64-bit (CoreFeatureMemory64).
+func (m *ModuleInstance) dataSegmentOffset(d *DataSegment) (offset uint64, ok bool) {
+	mem := m.Memories[d.MemoryIndex]
+	if mem.Memory64 {
+		offset = uint64(executeConstExpressionI64(m.Globals, &d.OffsetExpression))
+	} else if v := executeConstExpressionI32(m.Globals, &d.OffsetExpression); v < 0 {
+		return 0, false
+	} else {
+		offset = uint64(v)
+	}
+	size := uint64(len(mem.Buffer))
+	return offset, offset <= size && uint64(len(d.Init)) <= size-offset
+}
+
 // GetExport returns an export of the given name and type or errs if not exported or the wrong type.
 func (m *ModuleInstance) getExport(name string, et ExternType) (*Export, error) {
 	exp, ok := m.Exports[name]
@@ -299,15 +345,25 @@ func NewStore(enabledFeatures api.CoreFeatures, engine Engine) *Store {
 		Engine:           engine,
 		typeIDs:          map[string]FunctionTypeID{},
 		functionMaxTypes: maximumFunctionTypes,
+		epoch:            new(uint64),
+		Externrefs:       NewExternrefTable(),
 	}
 }
 
+// IncrementEpoch advances the epoch counter of this store by one.
+// This is safe to call concurrently with function calls.
+func (s *Store) IncrementEpoch() {
+	atomic.AddUint64(s.epoch, 1)
+}
+
 // Instantiate uses name instead of the Module.NameSection ModuleName as it allows instantiating the same module under
 // different names safely and concurrently.
 //
 // * ctx: the default context used for function calls.
 // * name: the name of the module.
 // * sys: the system context, which will be closed (SysContext.Close) on ModuleInstance.Close.
+// * fuel: the fuel set before the start function is executed, when Module.FuelMetering is true.
+// * limiter: consulted when the memories or tables defined by the module grow, or nil for none.
 //
 // Note: Module.Validate must be called prior to instantiation.
 func (s *Store) Instantiate(
@@ -316,9 +372,11 @@ func (s *Store) Instantiate(
 	name string,
 	sys *internalsys.Context,
 	typeIDs []FunctionTypeID,
+	fuel uint64,
+	limiter ResourceLimiter,
 ) (*ModuleInstance, error) {
 	// Instantiate the module and add it to the store so that other modules can import it.
-	m, err := s.instantiate(ctx, module, name, sys, typeIDs)
+	m, err := s.instantiate(ctx, module, name, sys, typeIDs, fuel, limiter)
 	if err != nil {
 		return nil, err
 	}
@@ -337,11 +395,23 @@ func (s *Store) instantiate(
 	name string,
 	sysCtx *internalsys.Context,
 	typeIDs []FunctionTypeID,
+	fuel uint64,
+	limiter ResourceLimiter,
 ) (m *ModuleInstance, err error) {
-	m = &ModuleInstance{ModuleName: name, TypeIDs: typeIDs, Sys: sysCtx, s: s, Source: module}
+	m = &ModuleInstance{
+		ModuleName: name, TypeIDs: typeIDs, Sys: sysCtx, s: s, Source: module,
+		Epoch: s.epoch, EpochDeadline: math.MaxUint64,
+	}
+	if module.FuelMetering {
+		m.SetFuel(fuel)
+	}
 
 	m.Tables = make([]*TableInstance, int(module.ImportTableCount)+len(module.TableSection))
+	m.Memories = make([]*MemoryInstance, module.ImportMemoryCount, int(module.ImportMemoryCount)+len(module.MemorySection))
 	m.Globals = make([]*GlobalInstance, int(module.ImportGlobalCount)+len(module.GlobalSection))
+	if tagCount := int(module.ImportTagCount) + len(module.TagSection); tagCount > 0 {
+		m.Tags = make([]*TagInstance, tagCount)
+	}
 	m.Engine, err = s.Engine.NewModuleEngine(module, m)
 	if err != nil {
 		return nil, err
@@ -360,6 +430,10 @@ func (s *Store) instantiate(
 
 	m.buildGlobals(module, m.Engine.FunctionInstanceReference)
 	m.buildMemory(module)
+	if limiter != nil {
+		m.setResourceLimiter(module, limiter)
+	}
+	m.buildTags(module)
 	m.Exports = module.Exports
 
 	// As of reference types proposal, data segment validation must happen after instantiation,
@@ -450,7 +524,7 @@ func (m *ModuleInstance) resolveImports(module *Module) (err error) {
 				m.Tables[i.IndexPerType] = importedTable
 			case ExternTypeMemory:
 				expected := i.DescMem
-				importedMemory := importedModule.MemoryInstance
+				importedMemory := importedModule.Memories[imported.Index]
 
 				if expected.Min > memoryBytesNumToPages(uint64(len(importedMemory.Buffer))) {
 					err = errorMinSizeMismatch(i, expected.Min, importedMemory.Min)
@@ -461,8 +535,23 @@ func (m *ModuleInstance) resolveImports(module *Module) (err error) {
 					err = errorMaxSizeMismatch(i, expected.Max, importedMemory.Max)
 					return
 				}
-				m.MemoryInstance = importedMemory
-				m.Engine.ResolveImportedMemory(importedModule.Engine)
+
+				if expected.IsShared != importedMemory.Shared {
+					err = errorInvalidImport(i, fmt.Errorf("shared mismatch: %t != %t",
+						expected.IsShared, importedMemory.Shared))
+					return
+				}
+
+				if expected.IsMemory64 != importedMemory.Memory64 {
+					err = errorInvalidImport(i, fmt.Errorf("memory64 mismatch: %t != %t",
+						expected.IsMemory64, importedMemory.Memory64))
+					return
+				}
+				m.Memories[i.IndexPerType] = importedMemory
+				if i.IndexPerType == 0 {
+					m.MemoryInstance = importedMemory
+					m.Engine.ResolveImportedMemory(importedModule.Engine)
+				}
 			case ExternTypeGlobal:
 				expected := i.DescGlobal
 				importedGlobal := importedModule.Globals[imported.Index]
@@ -479,6 +568,14 @@ func (m *ModuleInstance) resolveImports(module *Module) (err error) {
 					return
 				}
 				m.Globals[i.IndexPerType] = importedGlobal
+			case ExternTypeTag:
+				expectedType := &module.TypeSection[i.DescTag]
+				importedTag := importedModule.Tags[imported.Index]
+				if !importedTag.Type.EqualsSignature(expectedType.Params, expectedType.Results) {
+					err = errorInvalidImport(i, fmt.Errorf("signature mismatch: %s != %s", expectedType, importedTag.Type))
+					return
+				}
+				m.Tags[i.IndexPerType] = importedTag
 			}
 		}
 	}
@@ -516,6 +613,18 @@ func executeConstExpressionI32(importedGlobals []*GlobalInstance, expr *Constant
 	return
 }
 
+func executeConstExpressionI64(importedGlobals []*GlobalInstance, expr *ConstantExpression) (ret int64) {
+	switch expr.Opcode {
+	case OpcodeI64Const:
+		ret, _, _ = leb128.LoadInt64(expr.Data)
+	case OpcodeGlobalGet:
+		id, _, _ := leb128.LoadUint32(expr.Data)
+		g := importedGlobals[id]
+		ret = int64(g.Val)
+	}
+	return
+}
+
 // initialize initializes the value of this global instance given the const expr and imported globals.
 // funcRefResolver is called to get the actual funcref (engine specific) from the OpcodeRefFunc const expr.
 //
@@ -637,5 +746,6 @@ func (s *Store) CloseWithExitCode(ctx context.Context, exitCode uint32) (err err
 	s.nameToModule = nil
 	s.nameToModuleCap = 0
 	s.typeIDs = nil
+	s.Externrefs.reset()
 	return
 }
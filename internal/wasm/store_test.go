@@ -32,14 +32,14 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory not exported, one page",
 			input: &Module{
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 			},
 		},
 		{
 			name: "memory exported, different name",
 			input: &Module{
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				ExportSection:           []Export{{Type: ExternTypeMemory, Name: "momory", Index: 0}},
 			},
@@ -47,7 +47,7 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory exported, but zero length",
 			input: &Module{
-				MemorySection:           &Memory{},
+				MemorySection:           []Memory{{}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				Exports:                 map[string]*Export{"memory": {Type: ExternTypeMemory, Name: "memory"}},
 			},
@@ -56,7 +56,7 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory exported, one page",
 			input: &Module{
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				Exports:                 map[string]*Export{"memory": {Type: ExternTypeMemory, Name: "memory"}},
 			},
@@ -66,7 +66,7 @@ func TestModuleInstance_Memory(t *testing.T) {
 		{
 			name: "memory exported, two pages",
 			input: &Module{
-				MemorySection:           &Memory{Min: 2, Cap: 2},
+				MemorySection:           []Memory{{Min: 2, Cap: 2}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				Exports:                 map[string]*Export{"memory": {Type: ExternTypeMemory, Name: "memory"}},
 			},
@@ -81,7 +81,7 @@ func TestModuleInstance_Memory(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			s := newStore()
 
-			instance, err := s.Instantiate(testCtx, tc.input, "test", nil, nil)
+			instance, err := s.Instantiate(testCtx, tc.input, "test", nil, nil, 0, nil)
 			require.NoError(t, err)
 
 			mem := instance.ExportedMemory("memory")
@@ -105,7 +105,7 @@ func TestStore_Instantiate(t *testing.T) {
 	require.NoError(t, err)
 
 	sysCtx := sys.DefaultContext(nil)
-	mod, err := s.Instantiate(testCtx, m, "bar", sysCtx, []FunctionTypeID{0})
+	mod, err := s.Instantiate(testCtx, m, "bar", sysCtx, []FunctionTypeID{0}, 0, nil)
 	require.NoError(t, err)
 	defer mod.Close(testCtx)
 
@@ -146,18 +146,18 @@ func TestStore_CloseWithExitCode(t *testing.T) {
 				CodeSection:               []Code{{Body: []byte{OpcodeEnd}}},
 				Exports:                   map[string]*Export{"fn": {Type: ExternTypeFunc, Name: "fn"}},
 				FunctionDefinitionSection: []FunctionDefinition{{Functype: &v_v}},
-			}, importedModuleName, nil, []FunctionTypeID{0})
+			}, importedModuleName, nil, []FunctionTypeID{0}, 0, nil)
 			require.NoError(t, err)
 
 			m2, err := s.Instantiate(testCtx, &Module{
 				ImportFunctionCount:     1,
 				TypeSection:             []FunctionType{v_v},
 				ImportSection:           []Import{{Type: ExternTypeFunc, Module: importedModuleName, Name: "fn", DescFunc: 0}},
-				MemorySection:           &Memory{Min: 1, Cap: 1},
+				MemorySection:           []Memory{{Min: 1, Cap: 1}},
 				MemoryDefinitionSection: []MemoryDefinition{{}},
 				GlobalSection:           []Global{{Type: GlobalType{}, Init: ConstantExpression{Opcode: OpcodeI32Const, Data: const1}}},
 				TableSection:            []Table{{Min: 10}},
-			}, importingModuleName, nil, []FunctionTypeID{0})
+			}, importingModuleName, nil, []FunctionTypeID{0}, 0, nil)
 			require.NoError(t, err)
 
 			if tc.testClosed {
@@ -189,7 +189,7 @@ func TestStore_hammer(t *testing.T) {
 	require.NoError(t, err)
 
 	s := newStore()
-	imported, err := s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0})
+	imported, err := s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0}, 0, nil)
 	require.NoError(t, err)
 
 	_, ok := s.nameToModule[imported.Name()]
@@ -200,7 +200,7 @@ func TestStore_hammer(t *testing.T) {
 		TypeSection:             []FunctionType{v_v},
 		FunctionSection:         []uint32{0},
 		CodeSection:             []Code{{Body: []byte{OpcodeEnd}}},
-		MemorySection:           &Memory{Min: 1, Cap: 1},
+		MemorySection:           []Memory{{Min: 1, Cap: 1}},
 		MemoryDefinitionSection: []MemoryDefinition{{}},
 		GlobalSection: []Global{{
 			Type: GlobalType{ValType: ValueTypeI32},
@@ -221,7 +221,7 @@ func TestStore_hammer(t *testing.T) {
 		N = 100
 	}
 	hammer.NewHammer(t, P, N).Run(func(name string) {
-		mod, instantiateErr := s.Instantiate(testCtx, importingModule, name, sys.DefaultContext(nil), []FunctionTypeID{0})
+		mod, instantiateErr := s.Instantiate(testCtx, importingModule, name, sys.DefaultContext(nil), []FunctionTypeID{0}, 0, nil)
 		require.NoError(t, instantiateErr)
 		require.NoError(t, mod.Close(testCtx))
 	}, nil)
@@ -248,7 +248,7 @@ func TestStore_hammer_close(t *testing.T) {
 	require.NoError(t, err)
 
 	s := newStore()
-	imported, err := s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0})
+	imported, err := s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0}, 0, nil)
 	require.NoError(t, err)
 
 	_, ok := s.nameToModule[imported.Name()]
@@ -259,7 +259,7 @@ func TestStore_hammer_close(t *testing.T) {
 		TypeSection:             []FunctionType{v_v},
 		FunctionSection:         []uint32{0},
 		CodeSection:             []Code{{Body: []byte{OpcodeEnd}}},
-		MemorySection:           &Memory{Min: 1, Cap: 1},
+		MemorySection:           []Memory{{Min: 1, Cap: 1}},
 		MemoryDefinitionSection: []MemoryDefinition{{}},
 		GlobalSection: []Global{{
 			Type: GlobalType{ValType: ValueTypeI32},
@@ -274,7 +274,7 @@ func TestStore_hammer_close(t *testing.T) {
 	const instCount = 10000
 	instances := make([]api.Module, instCount)
 	for i := 0; i < instCount; i++ {
-		mod, instantiateErr := s.Instantiate(testCtx, importingModule, strconv.Itoa(i), sys.DefaultContext(nil), []FunctionTypeID{0})
+		mod, instantiateErr := s.Instantiate(testCtx, importingModule, strconv.Itoa(i), sys.DefaultContext(nil), []FunctionTypeID{0}, 0, nil)
 		require.NoError(t, instantiateErr)
 		instances[i] = mod
 	}
@@ -313,17 +313,17 @@ func TestStore_Instantiate_Errors(t *testing.T) {
 
 	t.Run("Fails if module name already in use", func(t *testing.T) {
 		s := newStore()
-		_, err = s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0})
+		_, err = s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0}, 0, nil)
 		require.NoError(t, err)
 
 		// Trying to register it again should fail
-		_, err = s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0})
+		_, err = s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0}, 0, nil)
 		require.EqualError(t, err, "module[imported] has already been instantiated")
 	})
 
 	t.Run("fail resolve import", func(t *testing.T) {
 		s := newStore()
-		_, err = s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0})
+		_, err = s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0}, 0, nil)
 		require.NoError(t, err)
 
 		hm := s.nameToModule[importedModuleName]
@@ -341,14 +341,14 @@ func TestStore_Instantiate_Errors(t *testing.T) {
 				importedModuleName: {{Type: ExternTypeFunc, Module: importedModuleName, Name: "fn", DescFunc: 0}},
 				"non-exist":        {{Name: "fn", DescFunc: 0}},
 			},
-		}, importingModuleName, nil, nil)
+		}, importingModuleName, nil, nil, 0, nil)
 		require.EqualError(t, err, "module[non-exist] not instantiated")
 	})
 
 	t.Run("creating engine failed", func(t *testing.T) {
 		s := newStore()
 
-		_, err = s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0})
+		_, err = s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0}, 0, nil)
 		require.NoError(t, err)
 
 		hm := s.nameToModule[importedModuleName]
@@ -370,7 +370,7 @@ func TestStore_Instantiate_Errors(t *testing.T) {
 			},
 		}
 
-		_, err = s.Instantiate(testCtx, importingModule, importingModuleName, nil, []FunctionTypeID{0})
+		_, err = s.Instantiate(testCtx, importingModule, importingModuleName, nil, []FunctionTypeID{0}, 0, nil)
 		require.EqualError(t, err, "some engine creation error")
 	})
 
@@ -379,7 +379,7 @@ func TestStore_Instantiate_Errors(t *testing.T) {
 		engine := s.Engine.(*mockEngine)
 		engine.callFailIndex = 1
 
-		_, err = s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0})
+		_, err = s.Instantiate(testCtx, m, importedModuleName, nil, []FunctionTypeID{0}, 0, nil)
 		require.NoError(t, err)
 
 		hm := s.nameToModule[importedModuleName]
@@ -397,7 +397,7 @@ func TestStore_Instantiate_Errors(t *testing.T) {
 			},
 		}
 
-		_, err = s.Instantiate(testCtx, importingModule, importingModuleName, nil, []FunctionTypeID{0})
+		_, err = s.Instantiate(testCtx, importingModule, importingModuleName, nil, []FunctionTypeID{0}, 0, nil)
 		require.EqualError(t, err, "start function[1] failed: call failed")
 	})
 }
@@ -469,6 +469,12 @@ func (e *mockModuleEngine) GetGlobalValue(idx Index) (lo, hi uint64) { panic("BU
 // OwnsGlobals implements the same method as documented on wasm.ModuleEngine.
 func (e *mockModuleEngine) OwnsGlobals() bool { return false }
 
+// SetGlobalValue implements the same method as documented on wasm.ModuleEngine.
+func (e *mockModuleEngine) SetGlobalValue(idx Index, lo, hi uint64) { panic("BUG") }
+
+// MemoryChanged implements the same method as documented on wasm.ModuleEngine.
+func (e *mockModuleEngine) MemoryChanged() {}
+
 // DoneInstantiation implements the same method as documented on wasm.ModuleEngine.
 func (e *mockModuleEngine) DoneInstantiation() {}
 
@@ -831,14 +837,14 @@ func Test_resolveImports(t *testing.T) {
 			s := newStore()
 			importedME := &mockModuleEngine{}
 			s.nameToModule[moduleName] = &ModuleInstance{
-				MemoryInstance: memoryInst,
+				Memories: []*MemoryInstance{memoryInst},
 				Exports: map[string]*Export{name: {
 					Type: ExternTypeMemory,
 				}},
 				ModuleName: moduleName,
 				Engine:     importedME,
 			}
-			m := &ModuleInstance{s: s, Engine: &mockModuleEngine{resolveImportsCalled: map[Index]Index{}}}
+			m := &ModuleInstance{s: s, Memories: make([]*MemoryInstance, 1), Engine: &mockModuleEngine{resolveImportsCalled: map[Index]Index{}}}
 			err := m.resolveImports(&Module{
 				ImportPerModule: map[string][]*Import{
 					moduleName: {{Module: moduleName, Name: name, Type: ExternTypeMemory, DescMem: &Memory{Max: max}}},
@@ -852,7 +858,7 @@ func Test_resolveImports(t *testing.T) {
 			importMemoryType := &Memory{Min: 2, Cap: 2}
 			s := newStore()
 			s.nameToModule[moduleName] = &ModuleInstance{
-				MemoryInstance: &MemoryInstance{Min: importMemoryType.Min - 1, Cap: 2},
+				Memories: []*MemoryInstance{{Min: importMemoryType.Min - 1, Cap: 2}},
 				Exports: map[string]*Export{name: {
 					Type: ExternTypeMemory,
 				}},
@@ -869,7 +875,7 @@ func Test_resolveImports(t *testing.T) {
 		t.Run("maximum size mismatch", func(t *testing.T) {
 			s := newStore()
 			s.nameToModule[moduleName] = &ModuleInstance{
-				MemoryInstance: &MemoryInstance{Max: MemoryLimitPages},
+				Memories: []*MemoryInstance{{Max: MemoryLimitPages}},
 				Exports: map[string]*Export{name: {
 					Type: ExternTypeMemory,
 				}},
@@ -888,7 +894,7 @@ func Test_resolveImports(t *testing.T) {
 }
 
 func TestModuleInstance_validateData(t *testing.T) {
-	m := &ModuleInstance{MemoryInstance: &MemoryInstance{Buffer: make([]byte, 5)}}
+	m := &ModuleInstance{Memories: []*MemoryInstance{{Buffer: make([]byte, 5)}}}
 	tests := []struct {
 		name   string
 		data   []DataSegment
@@ -933,17 +939,27 @@ func TestModuleInstance_validateData(t *testing.T) {
 
 func TestModuleInstance_applyData(t *testing.T) {
 	t.Run("ok", func(t *testing.T) {
-		m := &ModuleInstance{MemoryInstance: &MemoryInstance{Buffer: make([]byte, 10)}}
+		m := &ModuleInstance{Memories: []*MemoryInstance{{Buffer: make([]byte, 10)}}}
 		err := m.applyData([]DataSegment{
 			{OffsetExpression: ConstantExpression{Opcode: OpcodeI32Const, Data: const0}, Init: []byte{0xa, 0xf}},
 			{OffsetExpression: ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeUint32(8)}, Init: []byte{0x1, 0x5}},
 		})
 		require.NoError(t, err)
-		require.Equal(t, []byte{0xa, 0xf, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x5}, m.MemoryInstance.Buffer)
+		require.Equal(t, []byte{0xa, 0xf, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x1, 0x5}, m.Memories[0].Buffer)
 		require.Equal(t, [][]byte{{0xa, 0xf}, {0x1, 0x5}}, m.DataInstances)
 	})
+	t.Run("multiple memories", func(t *testing.T) {
+		m := &ModuleInstance{Memories: []*MemoryInstance{{Buffer: make([]byte, 2)}, {Buffer: make([]byte, 4)}}}
+		err := m.applyData([]DataSegment{
+			{OffsetExpression: ConstantExpression{Opcode: OpcodeI32Const, Data: const0}, Init: []byte{0xa}},
+			{OffsetExpression: ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeUint32(2)}, Init: []byte{0x1, 0x5}, MemoryIndex: 1},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []byte{0xa, 0x0}, m.Memories[0].Buffer)
+		require.Equal(t, []byte{0x0, 0x0, 0x1, 0x5}, m.Memories[1].Buffer)
+	})
 	t.Run("error", func(t *testing.T) {
-		m := &ModuleInstance{MemoryInstance: &MemoryInstance{Buffer: make([]byte, 5)}}
+		m := &ModuleInstance{Memories: []*MemoryInstance{{Buffer: make([]byte, 5)}}}
 		err := m.applyData([]DataSegment{
 			{OffsetExpression: ConstantExpression{Opcode: OpcodeI32Const, Data: leb128.EncodeUint32(8)}, Init: []byte{}},
 		})
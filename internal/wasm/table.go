@@ -5,6 +5,7 @@ import (
 	"math"
 
 	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/internalapi"
 	"github.com/AR1011/wazero/internal/leb128"
 )
 
@@ -125,6 +126,12 @@ type TableInstance struct {
 
 	// Type is either RefTypeFuncref or RefTypeExternRef.
 	Type RefType
+
+	// Limiter if non-nil is consulted by Grow.
+	Limiter ResourceLimiter
+
+	// definition is known at compile time.
+	definition api.TableDefinition
 }
 
 // ElementInstance represents an element instance in a module.
@@ -240,6 +247,9 @@ func (m *ModuleInstance) buildTables(module *Module, skipBoundCheck bool) (err e
 			References: make([]Reference, tsec.Min), Min: tsec.Min, Max: tsec.Max,
 			Type: tsec.Type,
 		}
+		if len(module.TableDefinitionSection) > 0 {
+			m.Tables[idx].definition = &module.TableDefinitionSection[idx]
+		}
 		idx++
 	}
 
@@ -313,6 +323,13 @@ func (t *TableInstance) Grow(delta uint32, initialRef Reference) (currentLen uin
 	if newLen := int64(currentLen) + int64(delta); // adding as 64bit ints to avoid overflow.
 	newLen >= math.MaxUint32 || (t.Max != nil && newLen > int64(*t.Max)) {
 		return 0xffffffff // = -1 in signed 32-bit integer.
+	} else if t.Limiter != nil {
+		// The error is raised as a panic, which is recovered as a trap by the engines.
+		if allowed, err := t.Limiter.TableGrowing(currentLen, uint32(newLen)); err != nil {
+			panic(err)
+		} else if !allowed {
+			return 0xffffffff
+		}
 	}
 	t.References = append(t.References, make([]uintptr, delta)...)
 
@@ -325,3 +342,69 @@ func (t *TableInstance) Grow(delta uint32, initialRef Reference) (currentLen uin
 	}
 	return
 }
+
+// functionReferencer is implemented by the api.Function of the engines, so that it can be written into a table.
+type functionReferencer interface {
+	// FunctionReference returns the reference to the function as it's stored in a table of RefTypeFuncref.
+	FunctionReference() Reference
+}
+
+// exportedTable implements api.Table, which isn't implemented by TableInstance as the signature of Grow differs.
+type exportedTable struct {
+	internalapi.WazeroOnlyType
+	t *TableInstance
+}
+
+// Definition implements the same method as documented on api.Table.
+func (t exportedTable) Definition() api.TableDefinition {
+	return t.t.definition
+}
+
+// Size implements the same method as documented on api.Table.
+func (t exportedTable) Size() uint32 {
+	return uint32(len(t.t.References))
+}
+
+// Get implements the same method as documented on api.Table.
+func (t exportedTable) Get(offset uint32) (uint64, bool) {
+	if offset >= uint32(len(t.t.References)) {
+		return 0, false
+	}
+	return uint64(t.t.References[offset]), true
+}
+
+// Set implements the same method as documented on api.Table.
+func (t exportedTable) Set(offset uint32, ref uint64) bool {
+	if !t.acceptsRef(ref) || offset >= uint32(len(t.t.References)) {
+		return false
+	}
+	t.t.References[offset] = Reference(ref)
+	return true
+}
+
+// SetFunction implements the same method as documented on api.Table.
+func (t exportedTable) SetFunction(offset uint32, fn api.Function) bool {
+	f, ok := fn.(functionReferencer)
+	if !ok || t.t.Type != RefTypeFuncref || offset >= uint32(len(t.t.References)) {
+		return false
+	}
+	t.t.References[offset] = f.FunctionReference()
+	return true
+}
+
+// acceptsRef returns true if the reference can be written by the host. A funcref is a pointer to a function of the
+// engine, so only the null reference is accepted, as any other value can't be checked.
+func (t exportedTable) acceptsRef(ref uint64) bool {
+	return ref == 0 || t.t.Type != RefTypeFuncref
+}
+
+// Grow implements the same method as documented on api.Table.
+func (t exportedTable) Grow(delta uint32, initialRef uint64) (uint32, bool) {
+	if !t.acceptsRef(initialRef) {
+		return 0, false
+	}
+	if res := t.t.Grow(delta, Reference(initialRef)); res != 0xffffffff {
+		return res, true
+	}
+	return 0, false
+}
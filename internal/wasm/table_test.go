@@ -1,6 +1,7 @@
 package wasm
 
 import (
+	"errors"
 	"math"
 	"testing"
 
@@ -1073,6 +1074,75 @@ func TestTableInstance_Grow(t *testing.T) {
 	}
 }
 
+func TestTableInstance_Grow_Limiter(t *testing.T) {
+	limiter := &testResourceLimiter{max: 2}
+	table := &TableInstance{References: make([]uintptr, 1), Limiter: limiter}
+
+	require.Equal(t, uint32(1), table.Grow(1, 0))
+	require.Equal(t, uint32(0xffff_ffff), table.Grow(1, 0))
+	require.Equal(t, 2, len(table.References))
+	require.Equal(t, [][2]uint32{{1, 2}, {2, 3}}, limiter.calls)
+
+	limiter.err = errors.New("quota exceeded")
+	err := require.CapturePanic(func() { table.Grow(1, 0) })
+	require.EqualError(t, err, "quota exceeded")
+	require.Equal(t, 2, len(table.References))
+}
+
+type testFunctionReference struct {
+	api.Function
+	ref Reference
+}
+
+func (f testFunctionReference) FunctionReference() Reference { return f.ref }
+
+func TestModuleInstance_ExportedTable(t *testing.T) {
+	two := uint32(2)
+	def := &TableDefinition{table: &Table{Min: 1, Max: &two, Type: RefTypeFuncref}}
+	ti := &TableInstance{References: []Reference{0}, Min: 1, Max: &two, Type: RefTypeFuncref, definition: def}
+	m := &ModuleInstance{
+		Tables:  []*TableInstance{ti},
+		Exports: map[string]*Export{"table": {Type: ExternTypeTable, Name: "table", Index: 0}},
+	}
+	require.Nil(t, m.ExportedTable("missing"))
+
+	table := m.ExportedTable("table")
+	require.Equal(t, api.TableDefinition(def), table.Definition())
+	require.Equal(t, uint32(1), table.Size())
+
+	ref, ok := table.Get(0)
+	require.True(t, ok)
+	require.Zero(t, ref)
+	_, ok = table.Get(1)
+	require.False(t, ok)
+
+	// Only the null reference can be written into a table of funcref, as other values can't be checked.
+	require.False(t, table.Set(0, 10))
+	require.True(t, table.Set(0, 0))
+	require.False(t, table.Set(1, 0))
+	_, ok = table.Grow(1, 20)
+	require.False(t, ok)
+	require.Equal(t, []Reference{0}, ti.References)
+
+	prev, ok := table.Grow(1, 0)
+	require.True(t, ok)
+	require.Equal(t, uint32(1), prev)
+	require.Equal(t, []Reference{0, 0}, ti.References)
+	_, ok = table.Grow(1, 0)
+	require.False(t, ok)
+
+	require.True(t, table.SetFunction(1, testFunctionReference{ref: 30}))
+	require.Equal(t, []Reference{0, 30}, ti.References)
+	require.False(t, table.SetFunction(2, testFunctionReference{ref: 30}))
+	require.False(t, table.SetFunction(0, nil))
+
+	// Functions can't be written into a table of externref, but any reference can.
+	ti.Type = RefTypeExternref
+	require.False(t, table.SetFunction(0, testFunctionReference{ref: 30}))
+	require.True(t, table.Set(0, 10))
+	require.Equal(t, []Reference{10, 30}, ti.References)
+}
+
 func Test_unwrapElementInitGlobalReference(t *testing.T) {
 	actual, ok := unwrapElementInitGlobalReference(12345 | ElementInitImportedGlobalFunctionReference)
 	require.True(t, ok)
@@ -0,0 +1,31 @@
+package wasm
+
+import (
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/internalapi"
+)
+
+// TagInstance represents an exception tag instance in a store, which requires api.CoreFeatureExceptionHandling.
+// Tags are compared by identity, so a tag imported from another module is the same pointer as the exported one.
+//
+// See https://webassembly.github.io/exception-handling/core/exec/runtime.html#tag-instances
+//
+// This implements api.Tag.
+type TagInstance struct {
+	internalapi.WazeroOnlyType
+
+	// Type is the type of the values carried by the exceptions with this tag.
+	Type *FunctionType
+}
+
+// ParamTypes implements api.Tag.
+func (t *TagInstance) ParamTypes() []api.ValueType {
+	return t.Type.Params
+}
+
+// buildTags creates the tags defined in this module. Imported tags are resolved by resolveImports.
+func (m *ModuleInstance) buildTags(module *Module) {
+	for i, typeIndex := range module.TagSection {
+		m.Tags[int(module.ImportTagCount)+i] = &TagInstance{Type: &module.TypeSection[typeIndex]}
+	}
+}
@@ -132,6 +132,11 @@ func (s *stackTrace) FromRecovered(recovered interface{}) error {
 		return fmt.Errorf("wasm error: %w\nwasm stack trace:\n\t%s", wasmErr, stack)
 	}
 
+	// An exception thrown by the guest or a host function wasn't caught, so it isn't a bug of either.
+	if exception, ok := recovered.(*api.Exception); ok {
+		return fmt.Errorf("wasm error: %w\nwasm stack trace:\n\t%s", exception, stack)
+	}
+
 	// If we have a runtime.Error, something severe happened which should include the stack trace. This could be
 	// a nil pointer from wazero or a user-defined function from HostModuleBuilder.
 	if runtimeErr, ok := recovered.(runtime.Error); ok {
@@ -72,6 +72,7 @@ var (
 )
 
 func TestErrorBuilder(t *testing.T) {
+	exception := api.NewException(nil, 1, 2)
 	tests := []struct {
 		name         string
 		build        func(ErrorBuilder) error
@@ -117,6 +118,17 @@ wasm stack trace:
 	x.y()`,
 			expectUnwrap: wasmruntime.ErrRuntimeStackOverflow,
 		},
+		{
+			name: "api.Exception",
+			build: func(builder ErrorBuilder) error {
+				builder.AddFrame("x.y", nil, nil, nil)
+				return builder.FromRecovered(exception)
+			},
+			expectedErr: `wasm error: uncaught exception with params [1 2]
+wasm stack trace:
+	x.y()`,
+			expectUnwrap: exception,
+		},
 	}
 
 	for _, tt := range tests {
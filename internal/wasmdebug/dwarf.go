@@ -207,6 +207,57 @@ entry:
 	return
 }
 
+// LineEntry is a row of the DWARF line table, which maps an instruction to its position in the source code.
+type LineEntry struct {
+	// Address is the offset of the instruction in the code section of the original Wasm binary.
+	Address uint64
+	File    string
+	Line    int
+	Column  int
+}
+
+// LineEntries returns the rows of the line tables of all compilation units, sorted by their address. Rows without a
+// line, and the ones at tombstone addresses are skipped.
+func (d *DWARFLines) LineEntries() (ret []LineEntry) {
+	if d == nil {
+		return
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	r := d.d.Reader()
+	for {
+		ent, err := r.Next()
+		if err != nil || ent == nil {
+			break
+		}
+		if ent.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+			continue
+		}
+
+		lineReader, err := d.d.LineReader(ent)
+		if err != nil || lineReader == nil {
+			r.SkipChildren()
+			continue
+		}
+		var le dwarf.LineEntry
+		for {
+			if err = lineReader.Next(&le); err != nil {
+				break // io.EOF at the end of the table.
+			}
+			if le.EndSequence || le.Line == 0 || le.File == nil || isTombstoneAddr(le.Address) {
+				continue
+			}
+			ret = append(ret, LineEntry{Address: le.Address, File: le.File.Name, Line: le.Line, Column: le.Column})
+		}
+		r.SkipChildren()
+	}
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Address < ret[j].Address })
+	return
+}
+
 func formatLine(prefix, fileName string, line, col int64, inlined bool) string {
 	builder := strings.Builder{}
 	builder.WriteString(prefix)
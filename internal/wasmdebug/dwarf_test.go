@@ -11,6 +11,7 @@ import (
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
 	"github.com/AR1011/wazero/internal/wasm/binary"
+	"github.com/AR1011/wazero/internal/wasmdebug"
 )
 
 func TestDWARFLines_Line_Zig(t *testing.T) {
@@ -273,3 +274,28 @@ func TestDWARFLines_Line_TinyGo(t *testing.T) {
 		})
 	}
 }
+
+func TestDWARFLines_LineEntries(t *testing.T) {
+	mod, err := binary.DecodeModule(dwarftestdata.ZigWasm, api.CoreFeaturesV2, wasm.MemoryLimitPages, false, true, false)
+	require.NoError(t, err)
+
+	entries := mod.DWARFLines.LineEntries()
+	require.NotEqual(t, 0, len(entries))
+	for i := 1; i < len(entries); i++ {
+		require.True(t, entries[i-1].Address <= entries[i].Address)
+	}
+
+	// The instruction at 0x6b is in the row starting before it, which is the same as TestDWARFLines_Line_Zig.
+	var last wasmdebug.LineEntry
+	for _, e := range entries {
+		if e.Address <= 0x6b-0x46 {
+			last = e
+		}
+	}
+	require.True(t, strings.HasSuffix(last.File, "zig/main.zig"), last.File)
+	require.Equal(t, 10, last.Line)
+	require.Equal(t, 5, last.Column)
+
+	var nilLines *wasmdebug.DWARFLines
+	require.Nil(t, nilLines.LineEntries())
+}
@@ -0,0 +1,684 @@
+package wat
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"strings"
+
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/wasm"
+)
+
+// funcParser encodes the instructions of a function body or a constant expression.
+//
+// Instructions are either plain, such as "i32.add" followed by its immediates, or folded into an s-expression whose
+// operands precede it, such as (i32.add (local.get 0) (i32.const 1)). Both can be mixed.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#folded-instructions%E2%91%A0
+type funcParser struct {
+	p *moduleParser
+	// locals are the indices of named parameters and locals, which is nil in a constant expression.
+	locals     map[string]wasm.Index
+	localCount wasm.Index
+	// labels are the blocks enclosing the current instruction, innermost last.
+	labels []label
+	body   []byte
+	// count is the number of instructions encoded, excluding the end of blocks.
+	count int
+}
+
+// label is a block which can be the target of a branch.
+type label struct {
+	// id is the symbolic identifier, or empty if there isn't one.
+	id    string
+	block *node
+}
+
+// parseInstrs encodes a sequence of instructions, which may be plain or folded.
+func (f *funcParser) parseInstrs(nodes []*node) error {
+	for i := 0; i < len(nodes); {
+		n := nodes[i]
+		i++
+		if n.isList() {
+			if err := f.parseFolded(n); err != nil {
+				return err
+			}
+			continue
+		} else if n.typ != tokenKeyword {
+			return n.errorf("expected an instruction, but found %s", n.describe())
+		}
+
+		switch n.text {
+		case "block", "loop", "if", "try_table":
+			header, id, consumed, err := f.parseBlockHeader(n, nodes[i:])
+			if err != nil {
+				return err
+			}
+			i += consumed
+			f.body = append(f.body, header...)
+			f.labels = append(f.labels, label{id: id, block: n})
+		case "else", "end":
+			if len(f.labels) == 0 {
+				return n.errorf("unexpected %s outside a block", n.text)
+			}
+			// The label of the block can be repeated after else or end.
+			if i < len(nodes) && nodes[i].isAtom(tokenID) {
+				if id := f.labels[len(f.labels)-1].id; nodes[i].text != id {
+					return nodes[i].errorf("mismatching label %s", nodes[i].text)
+				}
+				i++
+			}
+			if n.text == "else" {
+				f.body = append(f.body, wasm.OpcodeElse)
+			} else {
+				f.body = append(f.body, wasm.OpcodeEnd)
+				f.labels = f.labels[:len(f.labels)-1]
+			}
+		default:
+			encoded, consumed, err := f.parseInstr(n, nodes[i:])
+			if err != nil {
+				return err
+			}
+			i += consumed
+			f.body = append(f.body, encoded...)
+		}
+	}
+	return nil
+}
+
+// parseFolded encodes a folded instruction, such as (i32.add (local.get 0) (i32.const 1)) or
+// (if (result i32) (local.get 0) (then ...) (else ...)).
+func (f *funcParser) parseFolded(n *node) error {
+	if len(n.children) == 0 || n.children[0].typ != tokenKeyword {
+		return n.errorf("expected an instruction, but found %s", n.describe())
+	}
+	op, args := n.children[0], n.children[1:]
+	switch op.text {
+	case "block", "loop", "try_table":
+		header, id, consumed, err := f.parseBlockHeader(op, args)
+		if err != nil {
+			return err
+		}
+		f.body = append(f.body, header...)
+		f.labels = append(f.labels, label{id: id, block: op})
+		if err = f.parseInstrs(args[consumed:]); err != nil {
+			return err
+		}
+		return f.endBlock(n)
+	case "if":
+		header, id, consumed, err := f.parseBlockHeader(op, args)
+		if err != nil {
+			return err
+		}
+		// The condition precedes the block, so isn't in the scope of its label.
+		rest := args[consumed:]
+		for len(rest) > 0 && rest[0].head() != "then" {
+			if err = f.parseFolded(rest[0]); err != nil {
+				return err
+			}
+			rest = rest[1:]
+		}
+		if len(rest) == 0 {
+			return n.errorf("missing (then ...)")
+		}
+		f.body = append(f.body, header...)
+		f.labels = append(f.labels, label{id: id, block: op})
+		if err = f.parseInstrs(rest[0].children[1:]); err != nil {
+			return err
+		}
+		if rest = rest[1:]; len(rest) > 0 && rest[0].head() == "else" {
+			if elseInstrs := rest[0].children[1:]; len(elseInstrs) > 0 { // an empty else is redundant
+				f.body = append(f.body, wasm.OpcodeElse)
+				if err = f.parseInstrs(elseInstrs); err != nil {
+					return err
+				}
+			}
+			rest = rest[1:]
+		}
+		if len(rest) > 0 {
+			return rest[0].errorf("unexpected %s in if", rest[0].describe())
+		}
+		return f.endBlock(n)
+	case "else", "end", "then":
+		return op.errorf("unexpected %s", op.text)
+	}
+
+	encoded, consumed, err := f.parseInstr(op, args)
+	if err != nil {
+		return err
+	}
+	for _, operand := range args[consumed:] {
+		if !operand.isList() {
+			return operand.errorf("unexpected %s in %s", operand.describe(), op.text)
+		} else if err = f.parseFolded(operand); err != nil {
+			return err
+		}
+	}
+	f.body = append(f.body, encoded...)
+	return nil
+}
+
+// endBlock ends the innermost block, which must be the folded block n.
+func (f *funcParser) endBlock(n *node) error {
+	if last := len(f.labels) - 1; f.labels[last].block != n.children[0] {
+		return f.labels[last].block.errorf("missing end of %s", f.labels[last].block.text)
+	}
+	f.labels = f.labels[:len(f.labels)-1]
+	f.body = append(f.body, wasm.OpcodeEnd)
+	return nil
+}
+
+// parseBlockHeader encodes the start of a block, which is an optional label followed by the block type. try_table
+// also has catch clauses.
+func (f *funcParser) parseBlockHeader(op *node, args []*node) (encoded []byte, id string, consumed int, err error) {
+	f.count++
+	switch op.text {
+	case "block":
+		encoded = []byte{wasm.OpcodeBlock}
+	case "loop":
+		encoded = []byte{wasm.OpcodeLoop}
+	case "if":
+		encoded = []byte{wasm.OpcodeIf}
+	case "try_table":
+		encoded = []byte{wasm.OpcodeTryTable}
+	}
+	if len(args) > 0 && args[0].isAtom(tokenID) {
+		id = args[0].text
+		consumed++
+	}
+
+	// The block type is empty, a single result type, or otherwise a type index.
+	end := consumed
+	for end < len(args) && (args[end].head() == "type" || args[end].head() == "param" || args[end].head() == "result") {
+		end++
+	}
+	var ft *wasm.FunctionType
+	var typeIndex wasm.Index
+	if typeUse := args[consumed:end]; len(typeUse) > 0 && typeUse[0].head() == "type" {
+		var paramNames []*node
+		if typeIndex, paramNames, _, err = f.p.typeUse(typeUse); err != nil {
+			return nil, "", 0, err
+		} else if err = checkNoParamNames(paramNames); err != nil {
+			return nil, "", 0, err
+		}
+		ft = &f.p.m.TypeSection[typeIndex]
+	} else {
+		// Only add a type when the signature can't be encoded inline.
+		sig, paramNames, _, err := parseSignature(typeUse)
+		if err != nil {
+			return nil, "", 0, err
+		} else if err = checkNoParamNames(paramNames); err != nil {
+			return nil, "", 0, err
+		}
+		ft = &sig
+		if len(sig.Params) > 0 || len(sig.Results) > 1 {
+			typeIndex = f.p.functionType(sig)
+		}
+	}
+	consumed = end
+	if len(ft.Params) == 0 && len(ft.Results) == 0 {
+		encoded = append(encoded, 0x40)
+	} else if len(ft.Params) == 0 && len(ft.Results) == 1 {
+		encoded = append(encoded, ft.Results[0])
+	} else {
+		encoded = append(encoded, leb128.EncodeInt64(int64(typeIndex))...)
+	}
+
+	if op.text != "try_table" {
+		return
+	}
+
+	// Catch labels are relative to the block enclosing try_table, which doesn't yet include it.
+	var catches []byte
+	catchCount := uint32(0)
+	for ; consumed < len(args); consumed++ {
+		c := args[consumed]
+		var kind wasm.TryTableCatchKind
+		switch c.head() {
+		case "catch":
+			kind = wasm.TryTableCatchKindCatch
+		case "catch_ref":
+			kind = wasm.TryTableCatchKindCatchRef
+		case "catch_all":
+			kind = wasm.TryTableCatchKindCatchAll
+		case "catch_all_ref":
+			kind = wasm.TryTableCatchKindCatchAllRef
+		default:
+			encoded = append(encoded, leb128.EncodeUint32(catchCount)...)
+			return append(encoded, catches...), id, consumed, nil
+		}
+
+		catchArgs := c.children[1:]
+		catches = append(catches, kind)
+		if kind == wasm.TryTableCatchKindCatch || kind == wasm.TryTableCatchKindCatchRef {
+			if len(catchArgs) != 2 {
+				return nil, "", 0, c.errorf("expected (%s tag label)", c.head())
+			}
+			tag, err := f.p.index(spaceTag, catchArgs[0])
+			if err != nil {
+				return nil, "", 0, err
+			}
+			catches = append(catches, leb128.EncodeUint32(tag)...)
+			catchArgs = catchArgs[1:]
+		} else if len(catchArgs) != 1 {
+			return nil, "", 0, c.errorf("expected (%s label)", c.head())
+		}
+		l, err := f.labelIndex(catchArgs[0])
+		if err != nil {
+			return nil, "", 0, err
+		}
+		catches = append(catches, leb128.EncodeUint32(l)...)
+		catchCount++
+	}
+	encoded = append(encoded, leb128.EncodeUint32(catchCount)...)
+	return append(encoded, catches...), id, consumed, nil
+}
+
+// parseInstr encodes a plain instruction, and returns the number of nodes in args consumed by its immediates.
+func (f *funcParser) parseInstr(op *node, args []*node) (encoded []byte, consumed int, err error) {
+	name := op.text
+	in, ok := instructions[name]
+	if !ok {
+		return nil, 0, op.errorf("unknown instruction %s", name)
+	}
+	f.count++
+	encoded = in.encode()
+
+	// requireIndex returns the index in the space of the next argument.
+	requireIndex := func(s space) (wasm.Index, error) {
+		if consumed >= len(args) {
+			return 0, op.errorf("missing %s index for %s", spaceNames[s], name)
+		}
+		consumed++
+		return f.p.index(s, args[consumed-1])
+	}
+	// appendIndex appends the index of the next argument, or zero if it isn't an index.
+	appendIndex := func(s space, optional bool) error {
+		var index wasm.Index
+		if !optional || isIndex(args, consumed) {
+			if index, err = requireIndex(s); err != nil {
+				return err
+			}
+		}
+		encoded = append(encoded, leb128.EncodeUint32(index)...)
+		return nil
+	}
+	// appendIndexPair appends two indices, which are either both present or both zero.
+	appendIndexPair := func(s space) error {
+		if !isIndex(args, consumed) {
+			encoded = append(encoded, 0, 0)
+			return nil
+		} else if err = appendIndex(s, false); err != nil {
+			return err
+		}
+		return appendIndex(s, false)
+	}
+
+	switch {
+	case name == "br" || name == "br_if":
+		if consumed >= len(args) {
+			return nil, 0, op.errorf("missing label for %s", name)
+		}
+		l, err := f.labelIndex(args[0])
+		if err != nil {
+			return nil, 0, err
+		}
+		encoded = append(encoded, leb128.EncodeUint32(l)...)
+		consumed = 1
+	case name == "br_table":
+		var labels []byte
+		for ; isIndex(args, consumed); consumed++ {
+			l, err := f.labelIndex(args[consumed])
+			if err != nil {
+				return nil, 0, err
+			}
+			labels = append(labels, leb128.EncodeUint32(l)...)
+		}
+		if consumed == 0 {
+			return nil, 0, op.errorf("missing default label for %s", name)
+		}
+		// The last label is the default, which isn't included in the count.
+		encoded = append(encoded, leb128.EncodeUint32(uint32(consumed-1))...)
+		encoded = append(encoded, labels...)
+	case name == "call" || name == "return_call" || name == "ref.func":
+		err = appendIndex(spaceFunc, false)
+	case name == "call_indirect" || name == "return_call_indirect":
+		var table wasm.Index
+		if isIndex(args, consumed) {
+			if table, err = requireIndex(spaceTable); err != nil {
+				return nil, 0, err
+			}
+		}
+		end := consumed
+		for end < len(args) && (args[end].head() == "type" || args[end].head() == "param" || args[end].head() == "result") {
+			end++
+		}
+		typeIndex, paramNames, _, err := f.p.typeUse(args[consumed:end])
+		if err != nil {
+			return nil, 0, err
+		} else if err = checkNoParamNames(paramNames); err != nil {
+			return nil, 0, err
+		}
+		consumed = end
+		encoded = append(encoded, leb128.EncodeUint32(typeIndex)...)
+		encoded = append(encoded, leb128.EncodeUint32(table)...)
+	case name == "local.get" || name == "local.set" || name == "local.tee":
+		if consumed >= len(args) {
+			return nil, 0, op.errorf("missing local index for %s", name)
+		}
+		index, err := f.localIndex(args[0])
+		if err != nil {
+			return nil, 0, err
+		}
+		encoded = append(encoded, leb128.EncodeUint32(index)...)
+		consumed = 1
+	case name == "global.get" || name == "global.set":
+		err = appendIndex(spaceGlobal, false)
+	case name == "table.get" || name == "table.set" || name == "table.size" || name == "table.grow" || name == "table.fill":
+		err = appendIndex(spaceTable, true)
+	case name == "table.copy":
+		err = appendIndexPair(spaceTable)
+	case name == "table.init":
+		// The table index is optional, but precedes the element index, which is encoded first.
+		var table, elem wasm.Index
+		if isIndex(args, 1) {
+			if table, err = requireIndex(spaceTable); err != nil {
+				return nil, 0, err
+			}
+		}
+		if elem, err = requireIndex(spaceElem); err != nil {
+			return nil, 0, err
+		}
+		encoded = append(encoded, leb128.EncodeUint32(elem)...)
+		encoded = append(encoded, leb128.EncodeUint32(table)...)
+	case name == "elem.drop":
+		err = appendIndex(spaceElem, false)
+	case name == "memory.size" || name == "memory.grow" || name == "memory.fill":
+		err = appendIndex(spaceMemory, true)
+	case name == "memory.copy":
+		err = appendIndexPair(spaceMemory)
+	case name == "memory.init":
+		// The memory index is optional, but precedes the data index, which is encoded first.
+		var memory, data wasm.Index
+		if isIndex(args, 1) {
+			if memory, err = requireIndex(spaceMemory); err != nil {
+				return nil, 0, err
+			}
+		}
+		if data, err = requireIndex(spaceData); err != nil {
+			return nil, 0, err
+		}
+		encoded = append(encoded, leb128.EncodeUint32(data)...)
+		encoded = append(encoded, leb128.EncodeUint32(memory)...)
+		f.p.usesDataCount = true
+	case name == "data.drop":
+		err = appendIndex(spaceData, false)
+		f.p.usesDataCount = true
+	case name == "throw":
+		err = appendIndex(spaceTag, false)
+	case name == "i32.const" || name == "i64.const" || name == "f32.const" || name == "f64.const":
+		if consumed >= len(args) {
+			return nil, 0, op.errorf("missing constant for %s", name)
+		}
+		var imm []byte
+		if imm, err = encodeConst(name[:3], args[0]); err != nil {
+			return nil, 0, err
+		}
+		encoded = append(encoded, imm...)
+		consumed = 1
+	case name == "v128.const":
+		var imm []byte
+		if imm, consumed, err = parseV128Const(op, args); err != nil {
+			return nil, 0, err
+		}
+		encoded = append(encoded, imm...)
+	case name == "i8x16.shuffle":
+		for ; consumed < 16; consumed++ {
+			if consumed >= len(args) {
+				return nil, 0, op.errorf("expected 16 lane indices for %s", name)
+			}
+			lane, err := parseLane(args[consumed])
+			if err != nil {
+				return nil, 0, err
+			}
+			encoded = append(encoded, lane)
+		}
+	case isMemoryInstruction(in):
+		var imm []byte
+		if imm, consumed, err = f.parseMemArg(op, in, args); err != nil {
+			return nil, 0, err
+		}
+		encoded = append(encoded, imm...)
+	case strings.HasSuffix(name, "extract_lane_s") || strings.HasSuffix(name, "extract_lane_u") ||
+		strings.HasSuffix(name, "extract_lane") || strings.HasSuffix(name, "replace_lane"):
+		if consumed >= len(args) {
+			return nil, 0, op.errorf("missing lane index for %s", name)
+		}
+		lane, err := parseLane(args[0])
+		if err != nil {
+			return nil, 0, err
+		}
+		encoded = append(encoded, lane)
+		consumed = 1
+	case name == "select":
+		// select with result types is encoded as a different instruction.
+		var types []wasm.ValueType
+		typed := false
+		for ; consumed < len(args) && args[consumed].head() == "result"; consumed++ {
+			vts, _, err := parseValueTypes(args[consumed], false)
+			if err != nil {
+				return nil, 0, err
+			}
+			types, typed = append(types, vts...), true
+		}
+		if typed {
+			encoded = append([]byte{wasm.OpcodeTypedSelect}, leb128.EncodeUint32(uint32(len(types)))...)
+			encoded = append(encoded, types...)
+		}
+	case name == "ref.null":
+		if consumed >= len(args) {
+			return nil, 0, op.errorf("missing heap type for %s", name)
+		}
+		switch args[0].text {
+		case "func", "funcref":
+			encoded = append(encoded, wasm.RefTypeFuncref)
+		case "extern", "externref":
+			encoded = append(encoded, wasm.RefTypeExternref)
+		case "exn", "exnref":
+			encoded = append(encoded, wasm.ValueTypeExnref)
+		default:
+			return nil, 0, args[0].errorf("unknown heap type %s", args[0].describe())
+		}
+		consumed = 1
+	case name == "atomic.fence":
+		encoded = append(encoded, 0) // reserved byte
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return
+}
+
+// parseMemArg encodes the optional memory index, offset and alignment of a memory instruction, and the lane index
+// of vector lane instructions.
+func (f *funcParser) parseMemArg(op *node, in instruction, args []*node) (encoded []byte, consumed int, err error) {
+	lane := isLaneMemoryInstruction(in)
+	var memory wasm.Index
+	// The lane index is also a number, so a number is only the memory index when followed by another argument.
+	if isIndex(args, 0) && (!lane || args[0].isAtom(tokenID) || len(args) > 1 && (isIndex(args, 1) || isMemArgKeyword(args[1]))) {
+		if memory, err = f.p.index(spaceMemory, args[0]); err != nil {
+			return
+		}
+		consumed++
+	}
+
+	var offset uint64
+	align := naturalAlignment(op.text)
+	if consumed < len(args) && strings.HasPrefix(args[consumed].text, "offset=") && args[consumed].typ == tokenKeyword {
+		n := args[consumed]
+		value := n.text[len("offset="):]
+		if value == "" {
+			return nil, 0, n.errorf("missing value for offset=")
+		}
+		if offset, err = parseU64(token{typ: classify(value), text: value}); err != nil {
+			return nil, 0, n.wrap(err)
+		}
+		if !f.p.isMemory64(memory) && offset > math.MaxUint32 {
+			return nil, 0, n.wrap(errOutOfRange)
+		}
+		consumed++
+	}
+	if consumed < len(args) && strings.HasPrefix(args[consumed].text, "align=") && args[consumed].typ == tokenKeyword {
+		n := args[consumed]
+		value := n.text[len("align="):]
+		if value == "" {
+			return nil, 0, n.errorf("missing value for align=")
+		}
+		a, err := parseU32(token{typ: classify(value), text: value})
+		if err != nil {
+			return nil, 0, n.wrap(err)
+		} else if a == 0 || a&(a-1) != 0 {
+			return nil, 0, n.errorf("alignment must be a power of two")
+		}
+		align = uint32(bits.TrailingZeros32(a))
+		consumed++
+	}
+
+	if memory != 0 {
+		encoded = append(encoded, leb128.EncodeUint32(align|wasm.MemArgMemoryIndexFlag)...)
+		encoded = append(encoded, leb128.EncodeUint32(memory)...)
+	} else {
+		encoded = append(encoded, leb128.EncodeUint32(align)...)
+	}
+	encoded = append(encoded, leb128.EncodeUint64(offset)...)
+
+	if lane {
+		if consumed >= len(args) {
+			return nil, 0, op.errorf("missing lane index for %s", op.text)
+		}
+		l, err := parseLane(args[consumed])
+		if err != nil {
+			return nil, 0, err
+		}
+		encoded = append(encoded, l)
+		consumed++
+	}
+	return
+}
+
+// labelIndex returns the relative depth of a label, which is either numeric or a symbolic identifier.
+func (f *funcParser) labelIndex(n *node) (wasm.Index, error) {
+	switch n.typ {
+	case tokenUN:
+		index, err := parseU32(n.token)
+		return index, n.wrap(err)
+	case tokenID:
+		for i := len(f.labels) - 1; i >= 0; i-- {
+			if f.labels[i].id == n.text {
+				return wasm.Index(len(f.labels) - 1 - i), nil
+			}
+		}
+		return 0, n.errorf("unknown label %s", n.text)
+	}
+	return 0, n.errorf("expected a label, but found %s", n.describe())
+}
+
+// localIndex returns the index of a local, which is either numeric or a symbolic identifier.
+func (f *funcParser) localIndex(n *node) (wasm.Index, error) {
+	switch n.typ {
+	case tokenUN:
+		index, err := parseU32(n.token)
+		return index, n.wrap(err)
+	case tokenID:
+		if index, ok := f.locals[n.text]; ok {
+			return index, nil
+		}
+		return 0, n.errorf("unknown local %s", n.text)
+	}
+	return 0, n.errorf("expected a local index, but found %s", n.describe())
+}
+
+func isIndex(args []*node, i int) bool {
+	return i < len(args) && (args[i].isAtom(tokenUN) || args[i].isAtom(tokenID))
+}
+
+func isMemArgKeyword(n *node) bool {
+	return n.typ == tokenKeyword && (strings.HasPrefix(n.text, "offset=") || strings.HasPrefix(n.text, "align="))
+}
+
+func parseLane(n *node) (byte, error) {
+	lane, err := parseU32(n.token)
+	if err != nil {
+		return 0, n.wrap(err)
+	} else if lane > math.MaxUint8 {
+		return 0, n.wrap(errOutOfRange)
+	}
+	return byte(lane), nil
+}
+
+// encodeConst encodes the immediate of a constant instruction of the value type, such as "i32".
+func encodeConst(valueType string, n *node) ([]byte, error) {
+	switch valueType {
+	case "i32":
+		v, err := parseInt(n.token, 32)
+		return leb128.EncodeInt32(int32(uint32(v))), n.wrap(err)
+	case "i64":
+		v, err := parseInt(n.token, 64)
+		return leb128.EncodeInt64(int64(v)), n.wrap(err)
+	case "f32":
+		v, err := parseF32(n.token)
+		return binary.LittleEndian.AppendUint32(nil, v), n.wrap(err)
+	default: // f64
+		v, err := parseF64(n.token)
+		return binary.LittleEndian.AppendUint64(nil, v), n.wrap(err)
+	}
+}
+
+// parseV128Const encodes the 16 bytes of v128.const, which are written as a shape followed by a number for each lane,
+// such as "i32x4 1 2 3 4".
+func parseV128Const(op *node, args []*node) (encoded []byte, consumed int, err error) {
+	if len(args) == 0 || args[0].typ != tokenKeyword {
+		return nil, 0, op.errorf("missing shape for v128.const")
+	}
+	shape := args[0]
+	var lanes int
+	var encode func(n *node) error
+	switch shape.text {
+	case "i8x16", "i16x8", "i32x4", "i64x2":
+		bitSize := map[string]uint{"i8x16": 8, "i16x8": 16, "i32x4": 32, "i64x2": 64}[shape.text]
+		lanes = int(128 / bitSize)
+		encode = func(n *node) error {
+			v, err := parseInt(n.token, bitSize)
+			for i := uint(0); i < bitSize; i += 8 {
+				encoded = append(encoded, byte(v>>i))
+			}
+			return n.wrap(err)
+		}
+	case "f32x4":
+		lanes = 4
+		encode = func(n *node) error {
+			v, err := parseF32(n.token)
+			encoded = binary.LittleEndian.AppendUint32(encoded, v)
+			return n.wrap(err)
+		}
+	case "f64x2":
+		lanes = 2
+		encode = func(n *node) error {
+			v, err := parseF64(n.token)
+			encoded = binary.LittleEndian.AppendUint64(encoded, v)
+			return n.wrap(err)
+		}
+	default:
+		return nil, 0, shape.errorf("unknown shape %s for v128.const", shape.text)
+	}
+	if len(args) < 1+lanes {
+		return nil, 0, op.errorf("expected %d lanes for v128.const %s", lanes, shape.text)
+	}
+	for _, n := range args[1 : 1+lanes] {
+		if err = encode(n); err != nil {
+			return nil, 0, err
+		}
+	}
+	return encoded, 1 + lanes, nil
+}
@@ -0,0 +1,331 @@
+package wat
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// tokenType is the kind of a token in the WebAssembly Text Format.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#tokens%E2%91%A0
+type tokenType byte
+
+const (
+	tokenInvalid tokenType = iota
+	// tokenKeyword is a token that begins with a lowercase letter, such as "module" or "i32.add".
+	tokenKeyword
+	// tokenUN is an unsigned integer, such as "42" or "0x2a".
+	tokenUN
+	// tokenSN is a signed integer, such as "-42" or "+0x2a".
+	tokenSN
+	// tokenFN is a floating point number, such as "1.5e3", "-inf" or "nan:0x200000".
+	tokenFN
+	// tokenString is a quoted string, such as "hello". The decoded bytes may not be valid UTF-8.
+	tokenString
+	// tokenID is a symbolic identifier, such as "$main".
+	tokenID
+	// tokenLParen is an opening parenthesis.
+	tokenLParen
+	// tokenRParen is a closing parenthesis.
+	tokenRParen
+	// tokenReserved is any other run of identifier characters, which is invalid everywhere.
+	tokenReserved
+)
+
+var tokenNames = [...]string{
+	tokenInvalid:  "invalid",
+	tokenKeyword:  "keyword",
+	tokenUN:       "uN",
+	tokenSN:       "sN",
+	tokenFN:       "fN",
+	tokenString:   "string",
+	tokenID:       "id",
+	tokenLParen:   "(",
+	tokenRParen:   ")",
+	tokenReserved: "reserved",
+}
+
+func (t tokenType) String() string {
+	if int(t) < len(tokenNames) {
+		return tokenNames[t]
+	}
+	return "unknown"
+}
+
+// token is a lexical token and its position in the source.
+type token struct {
+	typ tokenType
+	// text is the source of the token, except for tokenString, where it is the decoded value.
+	text      string
+	line, col uint32
+}
+
+// lexer splits WebAssembly Text Format source into tokens, skipping whitespace and comments.
+type lexer struct {
+	source    []byte
+	pos       int
+	line, col uint32
+}
+
+func newLexer(source []byte) *lexer {
+	return &lexer{source: source, line: 1, col: 1}
+}
+
+// checkUTF8 returns an error at the first invalid UTF-8 sequence in the source, which is malformed even in strings
+// and comments.
+func (l *lexer) checkUTF8() error {
+	if utf8.Valid(l.source) {
+		return nil
+	}
+	line, col := uint32(1), uint32(1)
+	for i := 0; i < len(l.source); {
+		r, size := utf8.DecodeRune(l.source[i:])
+		if r == utf8.RuneError && size == 1 {
+			return &FormatError{Line: line, Col: col, cause: errors.New("malformed UTF-8 encoding")}
+		} else if r == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+		i += size
+	}
+	return nil
+}
+
+// next returns the next token, or a token of type tokenInvalid at the end of the source.
+func (l *lexer) next() (tok token, err error) {
+	if err = l.skipSpaceAndComments(); err != nil {
+		return
+	}
+	tok.line, tok.col = l.line, l.col
+	if l.pos == len(l.source) {
+		return
+	}
+
+	switch c := l.source[l.pos]; {
+	case c == '(':
+		tok.typ, tok.text = tokenLParen, "("
+		l.advance(1)
+	case c == ')':
+		tok.typ, tok.text = tokenRParen, ")"
+		l.advance(1)
+	case c == '"':
+		tok.typ = tokenString
+		tok.text, err = l.readString()
+	case isIDChar(c):
+		start := l.pos
+		for l.pos < len(l.source) && isIDChar(l.source[l.pos]) {
+			l.advance(1)
+		}
+		tok.text = string(l.source[start:l.pos])
+		tok.typ = classify(tok.text)
+	default:
+		err = fmt.Errorf("unexpected character %q", rune(c))
+	}
+	// Tokens other than parentheses must be separated by whitespace, a comment or a parenthesis.
+	if err == nil && tok.typ != tokenLParen && tok.typ != tokenRParen && l.pos < len(l.source) {
+		switch c := l.source[l.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(' || c == ')':
+		case c == ';' && l.peek(1) == ';':
+		default:
+			err = fmt.Errorf("missing separator after %s", tok.typ)
+		}
+	}
+	if err != nil {
+		err = &FormatError{Line: tok.line, Col: tok.col, cause: err}
+	}
+	return
+}
+
+// advance moves the position n bytes forward, tracking the line and column.
+func (l *lexer) advance(n int) {
+	for i := 0; i < n; i++ {
+		if l.source[l.pos] == '\n' {
+			l.line++
+			l.col = 1
+		} else if l.source[l.pos]&0xc0 != 0x80 { // only count the first byte of a UTF-8 sequence
+			l.col++
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) skipSpaceAndComments() error {
+	for l.pos < len(l.source) {
+		switch c := l.source[l.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			l.advance(1)
+		case c == ';' && l.peek(1) == ';': // line comment
+			for l.pos < len(l.source) && l.source[l.pos] != '\n' {
+				l.advance(1)
+			}
+		case c == '(' && l.peek(1) == ';': // block comment, which may be nested
+			line, col := l.line, l.col
+			l.advance(2)
+			for depth := 1; depth > 0; {
+				if l.pos == len(l.source) {
+					return &FormatError{Line: line, Col: col, cause: errors.New("unterminated block comment")}
+				}
+				if l.source[l.pos] == '(' && l.peek(1) == ';' {
+					depth++
+					l.advance(2)
+				} else if l.source[l.pos] == ';' && l.peek(1) == ')' {
+					depth--
+					l.advance(2)
+				} else {
+					l.advance(1)
+				}
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) peek(n int) byte {
+	if l.pos+n < len(l.source) {
+		return l.source[l.pos+n]
+	}
+	return 0
+}
+
+// readString decodes a string literal, whose opening quote is at the current position.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#strings%E2%91%A0
+func (l *lexer) readString() (string, error) {
+	l.advance(1)
+	var buf []byte
+	for {
+		if l.pos == len(l.source) {
+			return "", errors.New("unterminated string")
+		}
+		c := l.source[l.pos]
+		switch {
+		case c == '"':
+			l.advance(1)
+			return string(buf), nil
+		case c < 0x20 || c == 0x7f:
+			return "", fmt.Errorf("invalid character in string: %#x", c)
+		case c != '\\':
+			buf = append(buf, c)
+			l.advance(1)
+			continue
+		}
+
+		// Otherwise, this is an escape sequence.
+		l.advance(1)
+		if l.pos == len(l.source) {
+			return "", errors.New("unterminated string")
+		}
+		switch c = l.source[l.pos]; c {
+		case 't':
+			buf = append(buf, '\t')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case '"', '\'', '\\':
+			buf = append(buf, c)
+		case 'u':
+			if l.peek(1) != '{' {
+				return "", errors.New("invalid unicode escape")
+			}
+			l.advance(2)
+			var r rune
+			digits := 0
+			for ; l.pos < len(l.source) && l.source[l.pos] != '}'; l.advance(1) {
+				if l.source[l.pos] == '_' && digits > 0 {
+					continue
+				}
+				d, ok := hexDigit(l.source[l.pos])
+				if !ok || r > utf8.MaxRune {
+					return "", errors.New("invalid unicode escape")
+				}
+				r = r<<4 | rune(d)
+				digits++
+			}
+			if digits == 0 || l.pos == len(l.source) || r > utf8.MaxRune || (r >= 0xd800 && r < 0xe000) {
+				return "", errors.New("invalid unicode escape")
+			}
+			buf = utf8.AppendRune(buf, r)
+		default:
+			hi, ok1 := hexDigit(c)
+			lo, ok2 := hexDigit(l.peek(1))
+			if !ok1 || !ok2 {
+				return "", fmt.Errorf("invalid escape %q", "\\"+string(c))
+			}
+			buf = append(buf, hi<<4|lo)
+			l.advance(1)
+		}
+		l.advance(1)
+	}
+}
+
+// classify returns the type of a token made of identifier characters.
+func classify(text string) tokenType {
+	if text == "" {
+		return tokenReserved
+	}
+	c := text[0]
+	switch {
+	case c == '$':
+		if len(text) == 1 {
+			return tokenReserved
+		}
+		return tokenID
+	case c >= 'a' && c <= 'z':
+		if isFloat(text) { // inf and nan
+			return tokenFN
+		}
+		return tokenKeyword
+	}
+
+	digits := text
+	if c == '+' || c == '-' {
+		digits = text[1:]
+	}
+	if isFloat(digits) {
+		return tokenFN
+	} else if _, ok := parseDigits(digits); ok {
+		if digits == text {
+			return tokenUN
+		}
+		return tokenSN
+	} else if isHugeInteger(digits) {
+		// Integers too large for 64 bits are still numbers, so that the error is about range, not syntax.
+		if digits == text {
+			return tokenUN
+		}
+		return tokenSN
+	}
+	return tokenReserved
+}
+
+// isIDChar returns true if the character can be in a keyword, identifier or number.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#text-idchar
+func isIDChar(c byte) bool {
+	switch {
+	case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return true
+	}
+	switch c {
+	case '!', '#', '$', '%', '&', '\'', '*', '+', '-', '.', '/', ':', '<', '=', '>', '?', '@', '\\', '^', '_', '`', '|', '~':
+		return true
+	}
+	return false
+}
+
+func hexDigit(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,70 @@
+package wat
+
+import (
+	"testing"
+
+	"github.com/AR1011/wazero/internal/testing/require"
+)
+
+func TestLexer(t *testing.T) {
+	source := `(module ;; comment
+  (; block (; nested ;) comment ;)
+  (func $f (result f32) f32.const -0x1p3 i32.const +1 "a\n\u{1F600}\ff"))`
+	var tokens []token
+	l := newLexer([]byte(source))
+	for {
+		tok, err := l.next()
+		require.NoError(t, err)
+		if tok.typ == tokenInvalid {
+			break
+		}
+		tokens = append(tokens, tok)
+	}
+	require.Equal(t, []token{
+		{typ: tokenLParen, text: "(", line: 1, col: 1},
+		{typ: tokenKeyword, text: "module", line: 1, col: 2},
+		{typ: tokenLParen, text: "(", line: 3, col: 3},
+		{typ: tokenKeyword, text: "func", line: 3, col: 4},
+		{typ: tokenID, text: "$f", line: 3, col: 9},
+		{typ: tokenLParen, text: "(", line: 3, col: 12},
+		{typ: tokenKeyword, text: "result", line: 3, col: 13},
+		{typ: tokenKeyword, text: "f32", line: 3, col: 20},
+		{typ: tokenRParen, text: ")", line: 3, col: 23},
+		{typ: tokenKeyword, text: "f32.const", line: 3, col: 25},
+		{typ: tokenFN, text: "-0x1p3", line: 3, col: 35},
+		{typ: tokenKeyword, text: "i32.const", line: 3, col: 42},
+		{typ: tokenSN, text: "+1", line: 3, col: 52},
+		{typ: tokenString, text: "a\n\U0001F600\xff", line: 3, col: 55},
+		{typ: tokenRParen, text: ")", line: 3, col: 72},
+		{typ: tokenRParen, text: ")", line: 3, col: 73},
+	}, tokens)
+}
+
+func TestLexer_Errors(t *testing.T) {
+	tests := []struct {
+		name, input, expectedErr string
+	}{
+		{name: "unterminated string", input: `"abc`, expectedErr: "1:1: unterminated string"},
+		{name: "invalid escape", input: `"\q"`, expectedErr: `1:1: invalid escape "\\q"`},
+		{name: "surrogate escape", input: `"\u{d800}"`, expectedErr: "1:1: invalid unicode escape"},
+		{name: "unterminated comment", input: "\n  (; abc", expectedErr: "2:3: unterminated block comment"},
+		{name: "unexpected character", input: "[", expectedErr: `1:1: unexpected character '['`},
+		{name: "missing separator", input: `i32.const"1"`, expectedErr: "1:1: missing separator after keyword"},
+		{name: "malformed UTF-8", input: ";; \xff", expectedErr: "1:4: malformed UTF-8 encoding"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			l := newLexer([]byte(tc.input))
+			err := l.checkUTF8()
+			for err == nil {
+				var tok token
+				if tok, err = l.next(); tok.typ == tokenInvalid && err == nil {
+					t.Fatal("expected an error")
+				}
+			}
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,1014 @@
+package wat
+
+import (
+	"sort"
+
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/wasm"
+)
+
+// space is an index space, which is separate for each kind of definition.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#indices%E2%91%A4
+type space byte
+
+const (
+	spaceType space = iota
+	spaceFunc
+	spaceTable
+	spaceMemory
+	spaceGlobal
+	spaceTag
+	spaceElem
+	spaceData
+	spaceCount
+)
+
+var spaceNames = [spaceCount]string{"type", "func", "table", "memory", "global", "tag", "elem", "data"}
+
+// spaceOf returns the index space of definitions that can be imported, or false if there isn't one.
+func spaceOf(keyword string) (space, bool) {
+	switch keyword {
+	case "func":
+		return spaceFunc, true
+	case "table":
+		return spaceTable, true
+	case "memory":
+		return spaceMemory, true
+	case "global":
+		return spaceGlobal, true
+	case "tag":
+		return spaceTag, true
+	}
+	return 0, false
+}
+
+var externTypes = [spaceCount]wasm.ExternType{
+	spaceFunc:   wasm.ExternTypeFunc,
+	spaceTable:  wasm.ExternTypeTable,
+	spaceMemory: wasm.ExternTypeMemory,
+	spaceGlobal: wasm.ExternTypeGlobal,
+	spaceTag:    wasm.ExternTypeTag,
+}
+
+// moduleParser converts module fields into a wasm.Module.
+//
+// Fields can refer to definitions that appear later in the source, so parsing is in two passes:
+//  1. declare assigns indices to the symbolic identifiers of all definitions, and adds explicit types.
+//  2. define adds each field in the order of the source, including function bodies. Types implied by type uses are
+//     added in the order they appear.
+type moduleParser struct {
+	m *wasm.Module
+	// names are the indices of symbolic identifiers, such as "$main", in each index space.
+	names [spaceCount]map[string]wasm.Index
+	// counts are the number of definitions declared in each index space.
+	counts [spaceCount]wasm.Index
+	// hasDefinition is true when a function, table, memory, global or tag that isn't imported was declared, as
+	// imports must precede them.
+	hasDefinition bool
+	// memory64 is true for each memory addressed with i64, as instructions can access memories defined later.
+	memory64 []bool
+
+	funcNames  wasm.NameMap
+	localNames wasm.IndirectNameMap
+	// usesDataCount is true when a function body refers to a data segment, which requires the data count section.
+	usesDataCount bool
+}
+
+// fieldHeader is the start of a module field, which may have an identifier, inline exports and an inline import.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#text-func-abbrev
+type fieldHeader struct {
+	id                       *node
+	exports                  []string
+	importModule, importName string
+	imported                 bool
+	// rest are the nodes after the header.
+	rest []*node
+}
+
+func parseFieldHeader(f *node) (h fieldHeader, err error) {
+	rest := f.children[1:]
+	if len(rest) > 0 && rest[0].isAtom(tokenID) {
+		h.id, rest = rest[0], rest[1:]
+	}
+	for len(rest) > 0 && rest[0].head() == "export" {
+		e := rest[0]
+		if len(e.children) != 2 || !e.children[1].isName() {
+			return h, e.errorf("expected (export \"name\")")
+		}
+		h.exports = append(h.exports, e.children[1].text)
+		rest = rest[1:]
+	}
+	if len(rest) > 0 && rest[0].head() == "import" {
+		i := rest[0]
+		if len(i.children) != 3 || !i.children[1].isName() || !i.children[2].isName() {
+			return h, i.errorf("expected (import \"module\" \"name\")")
+		}
+		h.importModule, h.importName, h.imported = i.children[1].text, i.children[2].text, true
+		rest = rest[1:]
+	}
+	h.rest = rest
+	return
+}
+
+// parseModule returns a wasm.Module for the fields of a module, which is named when id isn't nil.
+func parseModule(id *node, fields []*node) (*wasm.Module, error) {
+	p := &moduleParser{m: &wasm.Module{}}
+	for i := range p.names {
+		p.names[i] = map[string]wasm.Index{}
+	}
+	if err := p.declare(fields); err != nil {
+		return nil, err
+	}
+	if err := p.define(fields); err != nil {
+		return nil, err
+	}
+
+	if p.usesDataCount {
+		count := uint32(len(p.m.DataSection))
+		p.m.DataCountSection = &count
+	}
+	if id != nil || len(p.funcNames) > 0 || len(p.localNames) > 0 {
+		p.m.NameSection = &wasm.NameSection{FunctionNames: p.funcNames, LocalNames: p.localNames}
+		if id != nil {
+			p.m.NameSection.ModuleName = id.text[1:]
+		}
+		sort.Slice(p.funcNames, func(i, j int) bool { return p.funcNames[i].Index < p.funcNames[j].Index })
+	}
+	return p.m, nil
+}
+
+// declare assigns an index to each definition, and adds the explicit types, as they precede those implied by
+// type uses.
+func (p *moduleParser) declare(fields []*node) error {
+	for _, f := range fields {
+		if !f.isList() || f.head() == "" {
+			return f.errorf("expected a module field, but found %s", f.describe())
+		}
+		switch keyword := f.head(); keyword {
+		case "type":
+			if err := p.declareType(f); err != nil {
+				return err
+			}
+		case "import":
+			if len(f.children) != 4 || !f.children[3].isList() {
+				return f.errorf("expected (import \"module\" \"name\" (kind ...))")
+			}
+			desc := f.children[3]
+			s, ok := spaceOf(desc.head())
+			if !ok {
+				return desc.errorf("unknown import kind %s", desc.describe())
+			}
+			if err := p.declareIndex(s, desc, true); err != nil {
+				return err
+			}
+			if s == spaceMemory {
+				rest := desc.children[1:]
+				if len(rest) > 0 && rest[0].isAtom(tokenID) {
+					rest = rest[1:]
+				}
+				p.memory64 = append(p.memory64, len(rest) > 0 && rest[0].isKeyword("i64"))
+			}
+		case "func", "table", "memory", "global", "tag":
+			s, _ := spaceOf(keyword)
+			h, err := parseFieldHeader(f)
+			if err != nil {
+				return err
+			}
+			if err = p.declareIndex(s, f, h.imported); err != nil {
+				return err
+			}
+			if s == spaceMemory {
+				p.memory64 = append(p.memory64, len(h.rest) > 0 && h.rest[0].isKeyword("i64"))
+			}
+			// Tables and memories can be initialized inline, which implies a segment.
+			if !h.imported && len(h.rest) > 0 {
+				last := h.rest[len(h.rest)-1]
+				if keyword == "table" && last.head() == "elem" {
+					p.counts[spaceElem]++
+				} else if keyword == "memory" && last.head() == "data" {
+					p.counts[spaceData]++
+				}
+			}
+		case "elem", "data":
+			s, target := spaceElem, spaceTable
+			if keyword == "data" {
+				s, target = spaceData, spaceMemory
+			}
+			if p.isLegacySegmentTarget(f, target) {
+				p.counts[s]++
+			} else if err := p.declareIndex(s, f, false); err != nil {
+				return err
+			}
+		case "export", "start":
+		default:
+			return f.errorf("unknown module field %s", keyword)
+		}
+	}
+	return nil
+}
+
+// isLegacySegmentTarget returns true if the identifier of a segment is the table or memory it initializes, as in
+// WebAssembly 1.0 (20191205), rather than the identifier of the segment. This is the case when it names a table or
+// memory, and is followed by the offset.
+func (p *moduleParser) isLegacySegmentTarget(f *node, target space) bool {
+	if len(f.children) < 3 || !f.children[1].isAtom(tokenID) || !f.children[2].isList() {
+		return false
+	}
+	_, ok := p.names[target][f.children[1].text]
+	return ok
+}
+
+// declareIndex assigns the next index in the space to the field, and its identifier if it has one.
+func (p *moduleParser) declareIndex(s space, f *node, imported bool) error {
+	if imported && p.hasDefinition {
+		return f.errorf("imports must occur before all non-import definitions")
+	} else if !imported && s != spaceType && s != spaceElem && s != spaceData {
+		p.hasDefinition = true
+	}
+	if len(f.children) > 1 && f.children[1].isAtom(tokenID) {
+		id := f.children[1]
+		if _, ok := p.names[s][id.text]; ok {
+			return id.errorf("duplicate %s %s", spaceNames[s], id.text)
+		}
+		p.names[s][id.text] = p.counts[s]
+	}
+	p.counts[s]++
+	return nil
+}
+
+func (p *moduleParser) declareType(f *node) error {
+	rest := f.children[1:]
+	if len(rest) > 0 && rest[0].isAtom(tokenID) {
+		rest = rest[1:]
+	}
+	if len(rest) != 1 || rest[0].head() != "func" {
+		return f.errorf("expected (type (func ...))")
+	}
+	ft, _, remaining, err := parseSignature(rest[0].children[1:])
+	if err != nil {
+		return err
+	} else if len(remaining) > 0 {
+		return remaining[0].errorf("unexpected %s in function type", remaining[0].describe())
+	}
+	if err = p.declareIndex(spaceType, f, false); err != nil {
+		return err
+	}
+	p.m.TypeSection = append(p.m.TypeSection, ft)
+	return nil
+}
+
+// define adds the fields to the module in the order of the source.
+func (p *moduleParser) define(fields []*node) (err error) {
+	for _, f := range fields {
+		switch f.head() {
+		case "import":
+			err = p.defineImport(f)
+		case "func":
+			err = p.defineFunc(f)
+		case "table":
+			err = p.defineTable(f)
+		case "memory":
+			err = p.defineMemory(f)
+		case "global":
+			err = p.defineGlobal(f)
+		case "tag":
+			err = p.defineTag(f)
+		case "export":
+			err = p.defineExport(f)
+		case "start":
+			err = p.defineStart(f)
+		case "elem":
+			err = p.defineElem(f)
+		case "data":
+			err = p.defineData(f)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (p *moduleParser) defineImport(f *node) error {
+	if !f.children[1].isName() || !f.children[2].isName() {
+		return f.errorf("expected (import \"module\" \"name\" (kind ...))")
+	}
+	desc := f.children[3]
+	s, _ := spaceOf(desc.head())
+	rest := desc.children[1:]
+	var id *node
+	if len(rest) > 0 && rest[0].isAtom(tokenID) {
+		id, rest = rest[0], rest[1:]
+	}
+	_, err := p.addImport(s, f.children[1].text, f.children[2].text, id, desc, rest)
+	return err
+}
+
+// addImport adds an import of the description in rest, and returns its index.
+func (p *moduleParser) addImport(s space, module, name string, id, desc *node, rest []*node) (index wasm.Index, err error) {
+	m := p.m
+	imp := wasm.Import{Type: externTypes[s], Module: module, Name: name}
+	switch s {
+	case spaceFunc:
+		if imp.DescFunc, _, rest, err = p.typeUse(rest); err != nil {
+			return
+		}
+		index = m.ImportFunctionCount
+		m.ImportFunctionCount++
+		p.addFunctionName(index, id)
+	case spaceTable:
+		if imp.DescTable, rest, err = parseTableType(desc, rest); err != nil {
+			return
+		}
+		index = m.ImportTableCount
+		m.ImportTableCount++
+	case spaceMemory:
+		var mem wasm.Memory
+		if mem, rest, err = parseMemoryType(desc, rest); err != nil {
+			return
+		}
+		imp.DescMem = &mem
+		index = m.ImportMemoryCount
+		m.ImportMemoryCount++
+	case spaceGlobal:
+		if len(rest) == 0 {
+			return 0, desc.errorf("missing global type")
+		}
+		if imp.DescGlobal, err = parseGlobalType(rest[0]); err != nil {
+			return
+		}
+		rest = rest[1:]
+		index = m.ImportGlobalCount
+		m.ImportGlobalCount++
+	case spaceTag:
+		if imp.DescTag, _, rest, err = p.typeUse(rest); err != nil {
+			return
+		}
+		index = m.ImportTagCount
+		m.ImportTagCount++
+	}
+	if len(rest) > 0 {
+		return 0, rest[0].errorf("unexpected %s in import", rest[0].describe())
+	}
+	imp.IndexPerType = index
+	m.ImportSection = append(m.ImportSection, imp)
+	return
+}
+
+func (p *moduleParser) addExports(s space, index wasm.Index, names []string) {
+	for _, name := range names {
+		p.m.ExportSection = append(p.m.ExportSection, wasm.Export{Type: externTypes[s], Name: name, Index: index})
+	}
+}
+
+func (p *moduleParser) addFunctionName(index wasm.Index, id *node) {
+	if id != nil {
+		p.funcNames = append(p.funcNames, wasm.NameAssoc{Index: index, Name: id.text[1:]})
+	}
+}
+
+func (p *moduleParser) defineFunc(f *node) error {
+	h, err := parseFieldHeader(f)
+	if err != nil {
+		return err
+	}
+	var index wasm.Index
+	if h.imported {
+		if index, err = p.addImport(spaceFunc, h.importModule, h.importName, h.id, f, h.rest); err != nil {
+			return err
+		}
+	} else {
+		index = p.m.ImportFunctionCount + wasm.Index(len(p.m.FunctionSection))
+		typeIndex, paramNames, rest, err := p.typeUse(h.rest)
+		if err != nil {
+			return err
+		}
+		p.m.FunctionSection = append(p.m.FunctionSection, typeIndex)
+		p.addFunctionName(index, h.id)
+		if err = p.parseBody(index, paramNames, rest); err != nil {
+			return err
+		}
+	}
+	p.addExports(spaceFunc, index, h.exports)
+	return nil
+}
+
+func (p *moduleParser) defineTable(f *node) error {
+	h, err := parseFieldHeader(f)
+	if err != nil {
+		return err
+	}
+	m := p.m
+	index := m.ImportTableCount + wasm.Index(len(m.TableSection))
+	if h.imported {
+		if _, err = p.addImport(spaceTable, h.importModule, h.importName, h.id, f, h.rest); err != nil {
+			return err
+		}
+	} else if len(h.rest) == 2 && h.rest[1].head() == "elem" {
+		// (table reftype (elem ...)) is a table sized to fit an active segment at offset zero.
+		refType, err := parseRefType(h.rest[0])
+		if err != nil {
+			return err
+		}
+		init, err := p.parseElemList(h.rest[1], h.rest[1].children[1:], refType)
+		if err != nil {
+			return err
+		}
+		size := uint32(len(init))
+		m.TableSection = append(m.TableSection, wasm.Table{Min: size, Max: &size, Type: refType})
+		m.ElementSection = append(m.ElementSection, wasm.ElementSegment{
+			OffsetExpr: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(0)},
+			TableIndex: index,
+			Init:       init,
+			Type:       refType,
+			Mode:       wasm.ElementModeActive,
+		})
+	} else {
+		table, rest, err := parseTableType(f, h.rest)
+		if err != nil {
+			return err
+		} else if len(rest) > 0 {
+			return rest[0].errorf("unexpected %s in table", rest[0].describe())
+		}
+		m.TableSection = append(m.TableSection, table)
+	}
+	p.addExports(spaceTable, index, h.exports)
+	return nil
+}
+
+func (p *moduleParser) defineMemory(f *node) error {
+	h, err := parseFieldHeader(f)
+	if err != nil {
+		return err
+	}
+	m := p.m
+	index := m.ImportMemoryCount + wasm.Index(len(m.MemorySection))
+	if h.imported {
+		if _, err = p.addImport(spaceMemory, h.importModule, h.importName, h.id, f, h.rest); err != nil {
+			return err
+		}
+	} else if n := len(h.rest); n > 0 && h.rest[n-1].head() == "data" {
+		// (memory (data ...)) is a memory sized to fit an active segment at offset zero.
+		rest, memory64 := h.rest, false
+		if n == 2 && rest[0].isKeyword("i64") {
+			rest, memory64 = rest[1:], true
+		} else if n != 1 {
+			return rest[0].errorf("unexpected %s in memory", rest[0].describe())
+		}
+		init, err := parseDataStrings(rest[0].children[1:])
+		if err != nil {
+			return err
+		}
+		pages := uint32((uint64(len(init)) + uint64(wasm.MemoryPageSize) - 1) / uint64(wasm.MemoryPageSize))
+		m.MemorySection = append(m.MemorySection, wasm.Memory{Min: pages, Max: pages, IsMaxEncoded: true, IsMemory64: memory64})
+		offset := wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: leb128.EncodeInt32(0)}
+		if memory64 {
+			offset = wasm.ConstantExpression{Opcode: wasm.OpcodeI64Const, Data: leb128.EncodeInt64(0)}
+		}
+		m.DataSection = append(m.DataSection, wasm.DataSegment{OffsetExpression: offset, Init: init, MemoryIndex: index})
+	} else {
+		mem, rest, err := parseMemoryType(f, h.rest)
+		if err != nil {
+			return err
+		} else if len(rest) > 0 {
+			return rest[0].errorf("unexpected %s in memory", rest[0].describe())
+		}
+		m.MemorySection = append(m.MemorySection, mem)
+	}
+	p.addExports(spaceMemory, index, h.exports)
+	return nil
+}
+
+func (p *moduleParser) defineGlobal(f *node) error {
+	h, err := parseFieldHeader(f)
+	if err != nil {
+		return err
+	}
+	m := p.m
+	index := m.ImportGlobalCount + wasm.Index(len(m.GlobalSection))
+	if h.imported {
+		if _, err = p.addImport(spaceGlobal, h.importModule, h.importName, h.id, f, h.rest); err != nil {
+			return err
+		}
+	} else {
+		if len(h.rest) == 0 {
+			return f.errorf("missing global type")
+		}
+		gt, err := parseGlobalType(h.rest[0])
+		if err != nil {
+			return err
+		}
+		init, err := p.parseConstantExpression(f, h.rest[1:])
+		if err != nil {
+			return err
+		}
+		m.GlobalSection = append(m.GlobalSection, wasm.Global{Type: gt, Init: init})
+	}
+	p.addExports(spaceGlobal, index, h.exports)
+	return nil
+}
+
+func (p *moduleParser) defineTag(f *node) error {
+	h, err := parseFieldHeader(f)
+	if err != nil {
+		return err
+	}
+	m := p.m
+	index := m.ImportTagCount + wasm.Index(len(m.TagSection))
+	if h.imported {
+		if _, err = p.addImport(spaceTag, h.importModule, h.importName, h.id, f, h.rest); err != nil {
+			return err
+		}
+	} else {
+		typeIndex, _, rest, err := p.typeUse(h.rest)
+		if err != nil {
+			return err
+		} else if len(rest) > 0 {
+			return rest[0].errorf("unexpected %s in tag", rest[0].describe())
+		}
+		m.TagSection = append(m.TagSection, typeIndex)
+	}
+	p.addExports(spaceTag, index, h.exports)
+	return nil
+}
+
+func (p *moduleParser) defineExport(f *node) error {
+	if len(f.children) != 3 || !f.children[1].isName() || !f.children[2].isList() {
+		return f.errorf("expected (export \"name\" (kind index))")
+	}
+	desc := f.children[2]
+	s, ok := spaceOf(desc.head())
+	if !ok || len(desc.children) != 2 {
+		return desc.errorf("expected (kind index), but found %s", desc.describe())
+	}
+	index, err := p.index(s, desc.children[1])
+	if err != nil {
+		return err
+	}
+	p.addExports(s, index, []string{f.children[1].text})
+	return nil
+}
+
+func (p *moduleParser) defineStart(f *node) error {
+	if len(f.children) != 2 {
+		return f.errorf("expected (start index)")
+	} else if p.m.StartSection != nil {
+		return f.errorf("multiple start sections")
+	}
+	index, err := p.index(spaceFunc, f.children[1])
+	if err != nil {
+		return err
+	}
+	p.m.StartSection = &index
+	return nil
+}
+
+// defineElem adds an element segment, which is passive unless it has an offset, or declarative.
+//
+// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/text/modules.html#element-segments
+func (p *moduleParser) defineElem(f *node) (err error) {
+	rest := f.children[1:]
+	if len(rest) > 0 && rest[0].isAtom(tokenID) && !p.isLegacySegmentTarget(f, spaceTable) {
+		rest = rest[1:]
+	}
+	seg := wasm.ElementSegment{Type: wasm.RefTypeFuncref, Mode: wasm.ElementModePassive}
+	if len(rest) > 0 && rest[0].isKeyword("declare") {
+		seg.Mode = wasm.ElementModeDeclarative
+		rest = rest[1:]
+	} else {
+		hasTable := false
+		if len(rest) > 0 && rest[0].head() == "table" {
+			if len(rest[0].children) != 2 {
+				return rest[0].errorf("expected (table index)")
+			}
+			if seg.TableIndex, err = p.index(spaceTable, rest[0].children[1]); err != nil {
+				return
+			}
+			hasTable, rest = true, rest[1:]
+		} else if len(rest) > 0 && (rest[0].isAtom(tokenUN) || rest[0].isAtom(tokenID)) {
+			// WebAssembly 1.0 (20191205) allows the table index without the (table ...) wrapper.
+			if seg.TableIndex, err = p.index(spaceTable, rest[0]); err != nil {
+				return
+			}
+			hasTable, rest = true, rest[1:]
+		}
+		if len(rest) > 0 && rest[0].isList() {
+			if seg.OffsetExpr, err = p.parseOffset(rest[0]); err != nil {
+				return
+			}
+			seg.Mode, rest = wasm.ElementModeActive, rest[1:]
+		} else if hasTable {
+			return f.errorf("missing offset for the table")
+		}
+	}
+
+	// An element list starts with "func" or a reference type, except in the WebAssembly 1.0 (20191205) form, which
+	// is a list of function indices.
+	if len(rest) > 0 && rest[0].isKeyword("func") {
+		rest = rest[1:]
+	} else if len(rest) > 0 && rest[0].typ == tokenKeyword {
+		if seg.Type, err = parseRefType(rest[0]); err != nil {
+			return
+		}
+		rest = rest[1:]
+	} else if seg.Mode != wasm.ElementModeActive && len(rest) > 0 {
+		return rest[0].errorf("expected func or a reference type, but found %s", rest[0].describe())
+	}
+	if seg.Init, err = p.parseElemList(f, rest, seg.Type); err != nil {
+		return
+	}
+	p.m.ElementSection = append(p.m.ElementSection, seg)
+	return
+}
+
+// parseElemList returns the initial values of an element segment, which are either all function indices, or all
+// expressions, such as (ref.func $f) or (item ref.null func).
+func (p *moduleParser) parseElemList(parent *node, items []*node, refType wasm.RefType) ([]wasm.Index, error) {
+	init := make([]wasm.Index, 0, len(items))
+	for _, item := range items {
+		if !item.isList() {
+			index, err := p.index(spaceFunc, item)
+			if err != nil {
+				return nil, err
+			}
+			init = append(init, index)
+			continue
+		}
+
+		exprNodes := []*node{item}
+		if item.head() == "item" {
+			exprNodes = item.children[1:]
+		}
+		expr, err := p.parseConstantExpression(item, exprNodes)
+		if err != nil {
+			return nil, err
+		}
+		switch expr.Opcode {
+		case wasm.OpcodeRefFunc:
+			index, _, _ := leb128.LoadUint32(expr.Data)
+			init = append(init, index)
+		case wasm.OpcodeRefNull:
+			init = append(init, wasm.ElementInitNullReference)
+		case wasm.OpcodeGlobalGet:
+			index, _, _ := leb128.LoadUint32(expr.Data)
+			init = append(init, wasm.ElementInitImportedGlobalFunctionReference|index)
+		default:
+			return nil, item.errorf("element must be ref.func, ref.null or global.get, but was %s", wasm.InstructionName(expr.Opcode))
+		}
+	}
+	return init, nil
+}
+
+// defineData adds a data segment, which is passive unless it has an offset.
+//
+// See https://www.w3.org/TR/2022/WD-wasm-core-2-20220419/text/modules.html#data-segments
+func (p *moduleParser) defineData(f *node) (err error) {
+	rest := f.children[1:]
+	if len(rest) > 0 && rest[0].isAtom(tokenID) && !p.isLegacySegmentTarget(f, spaceMemory) {
+		rest = rest[1:]
+	}
+	seg := wasm.DataSegment{Passive: true}
+	hasMemory := false
+	if len(rest) > 0 && rest[0].head() == "memory" {
+		if len(rest[0].children) != 2 {
+			return rest[0].errorf("expected (memory index)")
+		}
+		if seg.MemoryIndex, err = p.index(spaceMemory, rest[0].children[1]); err != nil {
+			return
+		}
+		hasMemory, rest = true, rest[1:]
+	} else if len(rest) > 0 && (rest[0].isAtom(tokenUN) || rest[0].isAtom(tokenID)) {
+		// WebAssembly 1.0 (20191205) allows the memory index without the (memory ...) wrapper.
+		if seg.MemoryIndex, err = p.index(spaceMemory, rest[0]); err != nil {
+			return
+		}
+		hasMemory, rest = true, rest[1:]
+	}
+	if len(rest) > 0 && rest[0].isList() {
+		if seg.OffsetExpression, err = p.parseOffset(rest[0]); err != nil {
+			return
+		}
+		seg.Passive, rest = false, rest[1:]
+	} else if hasMemory {
+		return f.errorf("missing offset for the memory")
+	}
+	if seg.Init, err = parseDataStrings(rest); err != nil {
+		return
+	}
+	p.m.DataSection = append(p.m.DataSection, seg)
+	return
+}
+
+// parseOffset parses (offset expr), or its abbreviation as a single folded instruction.
+func (p *moduleParser) parseOffset(n *node) (wasm.ConstantExpression, error) {
+	if n.head() == "offset" {
+		return p.parseConstantExpression(n, n.children[1:])
+	}
+	return p.parseConstantExpression(n, []*node{n})
+}
+
+// parseConstantExpression parses the instructions of a constant expression, which must be a single instruction.
+func (p *moduleParser) parseConstantExpression(parent *node, nodes []*node) (wasm.ConstantExpression, error) {
+	fp := &funcParser{p: p}
+	if err := fp.parseInstrs(nodes); err != nil {
+		return wasm.ConstantExpression{}, err
+	} else if fp.count != 1 || len(fp.labels) > 0 {
+		return wasm.ConstantExpression{}, parent.errorf("constant expression must be a single instruction")
+	}
+	body := fp.body
+	if body[0] == wasm.OpcodeVecPrefix { // v128.const is the only constant vector instruction
+		return wasm.ConstantExpression{Opcode: body[1], Data: body[2:]}, nil
+	}
+	return wasm.ConstantExpression{Opcode: body[0], Data: body[1:]}, nil
+}
+
+// parseBody adds the code of a function defined in the module, whose parameters are already known.
+func (p *moduleParser) parseBody(index wasm.Index, paramNames []*node, nodes []*node) error {
+	fp := &funcParser{p: p, locals: map[string]wasm.Index{}}
+	var names wasm.NameMap
+	addLocal := func(id *node) error {
+		localIndex := fp.localCount
+		fp.localCount++
+		if id == nil {
+			return nil
+		} else if _, ok := fp.locals[id.text]; ok {
+			return id.errorf("duplicate local %s", id.text)
+		}
+		fp.locals[id.text] = localIndex
+		names = append(names, wasm.NameAssoc{Index: localIndex, Name: id.text[1:]})
+		return nil
+	}
+	for _, id := range paramNames {
+		if err := addLocal(id); err != nil {
+			return err
+		}
+	}
+
+	var localTypes []wasm.ValueType
+	for len(nodes) > 0 && nodes[0].head() == "local" {
+		types, ids, err := parseValueTypes(nodes[0], true)
+		if err != nil {
+			return err
+		}
+		for i, id := range ids {
+			if err = addLocal(id); err != nil {
+				return err
+			}
+			localTypes = append(localTypes, types[i])
+		}
+		nodes = nodes[1:]
+	}
+
+	if err := fp.parseInstrs(nodes); err != nil {
+		return err
+	} else if n := len(fp.labels); n > 0 {
+		return fp.labels[n-1].block.errorf("missing end of %s", fp.labels[n-1].block.text)
+	}
+	p.m.CodeSection = append(p.m.CodeSection, wasm.Code{LocalTypes: localTypes, Body: append(fp.body, wasm.OpcodeEnd)})
+	if len(names) > 0 {
+		p.localNames = append(p.localNames, wasm.NameMapAssoc{Index: index, NameMap: names})
+	}
+	return nil
+}
+
+// index returns the index in the space, which is either numeric, or a symbolic identifier.
+func (p *moduleParser) index(s space, n *node) (wasm.Index, error) {
+	switch n.typ {
+	case tokenUN:
+		index, err := parseU32(n.token)
+		return index, n.wrap(err)
+	case tokenID:
+		if index, ok := p.names[s][n.text]; ok {
+			return index, nil
+		}
+		return 0, n.errorf("unknown %s %s", spaceNames[s], n.text)
+	}
+	return 0, n.errorf("expected a %s index, but found %s", spaceNames[s], n.describe())
+}
+
+// isMemory64 returns true if the memory at the index is addressed with i64.
+func (p *moduleParser) isMemory64(index wasm.Index) bool {
+	return index < wasm.Index(len(p.memory64)) && p.memory64[index]
+}
+
+// typeUse parses an optional (type index), and the (param ...) and (result ...) that follow. When the type index
+// is absent, the first type with the same signature is used, or one is added.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#type-uses%E2%91%A0
+func (p *moduleParser) typeUse(nodes []*node) (typeIndex wasm.Index, paramNames []*node, rest []*node, err error) {
+	var typeRef *node
+	if len(nodes) > 0 && nodes[0].head() == "type" {
+		if typeRef, nodes = nodes[0], nodes[1:]; len(typeRef.children) != 2 {
+			return 0, nil, nil, typeRef.errorf("expected (type index)")
+		}
+	}
+	var ft wasm.FunctionType
+	if ft, paramNames, rest, err = parseSignature(nodes); err != nil {
+		return
+	}
+	if typeRef == nil {
+		return p.functionType(ft), paramNames, rest, nil
+	}
+
+	if typeIndex, err = p.index(spaceType, typeRef.children[1]); err != nil {
+		return
+	} else if typeIndex >= wasm.Index(len(p.m.TypeSection)) {
+		return 0, nil, nil, typeRef.errorf("unknown type %d", typeIndex)
+	}
+	explicit := &p.m.TypeSection[typeIndex]
+	if len(ft.Params)+len(ft.Results) > 0 && !explicit.EqualsSignature(ft.Params, ft.Results) {
+		return 0, nil, nil, typeRef.errorf("inline function type %s doesn't match type %d %s", ft.String(), typeIndex, explicit.String())
+	}
+	if paramNames == nil {
+		paramNames = make([]*node, len(explicit.Params))
+	}
+	return typeIndex, paramNames, rest, nil
+}
+
+// functionType returns the index of the first type with the same signature, adding it if it doesn't exist.
+func (p *moduleParser) functionType(ft wasm.FunctionType) wasm.Index {
+	for i := range p.m.TypeSection {
+		if p.m.TypeSection[i].EqualsSignature(ft.Params, ft.Results) {
+			return wasm.Index(i)
+		}
+	}
+	p.m.TypeSection = append(p.m.TypeSection, ft)
+	return wasm.Index(len(p.m.TypeSection) - 1)
+}
+
+// parseSignature parses (param ...) and (result ...) lists at the start of nodes, and returns the remaining nodes.
+// paramNames has an entry for each parameter, which is nil when it has no identifier.
+func parseSignature(nodes []*node) (ft wasm.FunctionType, paramNames []*node, rest []*node, err error) {
+	rest = nodes
+	for len(rest) > 0 && rest[0].head() == "param" {
+		types, ids, err := parseValueTypes(rest[0], true)
+		if err != nil {
+			return ft, nil, nil, err
+		}
+		ft.Params = append(ft.Params, types...)
+		paramNames = append(paramNames, ids...)
+		rest = rest[1:]
+	}
+	for len(rest) > 0 && rest[0].head() == "result" {
+		types, _, err := parseValueTypes(rest[0], false)
+		if err != nil {
+			return ft, nil, nil, err
+		}
+		ft.Results = append(ft.Results, types...)
+		rest = rest[1:]
+	}
+	if len(rest) > 0 && (rest[0].head() == "param" || rest[0].head() == "type") {
+		return ft, nil, nil, rest[0].errorf("unexpected %s after results", rest[0].describe())
+	}
+	return
+}
+
+// checkNoParamNames returns an error if any parameter has an identifier, which is only allowed in functions.
+func checkNoParamNames(paramNames []*node) error {
+	for _, id := range paramNames {
+		if id != nil {
+			return id.errorf("unexpected identifier %s", id.text)
+		}
+	}
+	return nil
+}
+
+// parseValueTypes parses a list such as (param $x i32) or (local i32 i64). A single value type can have an
+// identifier if allowed, and the identifier of each type is returned, which is nil if there isn't one.
+func parseValueTypes(n *node, allowID bool) (types []wasm.ValueType, ids []*node, err error) {
+	rest := n.children[1:]
+	var id *node
+	if len(rest) > 0 && rest[0].isAtom(tokenID) {
+		if !allowID {
+			return nil, nil, rest[0].errorf("unexpected identifier %s", rest[0].text)
+		} else if len(rest) != 2 {
+			return nil, nil, n.errorf("expected a single value type after %s", rest[0].text)
+		}
+		id, rest = rest[0], rest[1:]
+	}
+	for _, t := range rest {
+		vt, err := parseValueType(t)
+		if err != nil {
+			return nil, nil, err
+		}
+		types = append(types, vt)
+		ids = append(ids, id)
+	}
+	return
+}
+
+// parseValueType parses a value type, such as i32 or funcref.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#value-types%E2%91%A2
+func parseValueType(n *node) (wasm.ValueType, error) {
+	if n.typ == tokenKeyword {
+		switch n.text {
+		case "i32":
+			return wasm.ValueTypeI32, nil
+		case "i64":
+			return wasm.ValueTypeI64, nil
+		case "f32":
+			return wasm.ValueTypeF32, nil
+		case "f64":
+			return wasm.ValueTypeF64, nil
+		case "v128":
+			return wasm.ValueTypeV128, nil
+		case "funcref":
+			return wasm.ValueTypeFuncref, nil
+		case "externref":
+			return wasm.ValueTypeExternref, nil
+		case "exnref":
+			return wasm.ValueTypeExnref, nil
+		}
+	}
+	return 0, n.errorf("unknown value type %s", n.describe())
+}
+
+// parseRefType parses a reference type, which is funcref or externref.
+func parseRefType(n *node) (wasm.RefType, error) {
+	switch {
+	case n.isKeyword("funcref"):
+		return wasm.RefTypeFuncref, nil
+	case n.isKeyword("externref"):
+		return wasm.RefTypeExternref, nil
+	}
+	return 0, n.errorf("unknown reference type %s", n.describe())
+}
+
+// parseGlobalType parses a value type, or (mut valtype) for a mutable global.
+func parseGlobalType(n *node) (gt wasm.GlobalType, err error) {
+	if n.head() == "mut" {
+		if len(n.children) != 2 {
+			return gt, n.errorf("expected (mut valtype)")
+		}
+		gt.Mutable = true
+		n = n.children[1]
+	}
+	gt.ValType, err = parseValueType(n)
+	return
+}
+
+// parseLimits parses a minimum and an optional maximum.
+func parseLimits(parent *node, nodes []*node) (min uint32, max *uint32, rest []*node, err error) {
+	if len(nodes) == 0 || !nodes[0].isAtom(tokenUN) {
+		return 0, nil, nil, parent.errorf("missing minimum")
+	}
+	if min, err = parseU32(nodes[0].token); err != nil {
+		return 0, nil, nil, nodes[0].wrap(err)
+	}
+	rest = nodes[1:]
+	if len(rest) > 0 && rest[0].isAtom(tokenUN) {
+		v, err := parseU32(rest[0].token)
+		if err != nil {
+			return 0, nil, nil, rest[0].wrap(err)
+		}
+		max, rest = &v, rest[1:]
+	}
+	return
+}
+
+// parseTableType parses limits followed by a reference type.
+func parseTableType(parent *node, nodes []*node) (table wasm.Table, rest []*node, err error) {
+	if table.Min, table.Max, rest, err = parseLimits(parent, nodes); err != nil {
+		return
+	} else if len(rest) == 0 {
+		return table, nil, parent.errorf("missing reference type")
+	}
+	table.Type, err = parseRefType(rest[0])
+	return table, rest[1:], err
+}
+
+// parseMemoryType parses limits which may be preceded by i64 for a 64-bit memory, and followed by shared.
+func parseMemoryType(parent *node, nodes []*node) (mem wasm.Memory, rest []*node, err error) {
+	if len(nodes) > 0 && nodes[0].isKeyword("i64") {
+		mem.IsMemory64, nodes = true, nodes[1:]
+	} else if len(nodes) > 0 && nodes[0].isKeyword("i32") {
+		nodes = nodes[1:]
+	}
+	var max *uint32
+	if mem.Min, max, rest, err = parseLimits(parent, nodes); err != nil {
+		return
+	}
+	if max != nil {
+		mem.Max, mem.IsMaxEncoded = *max, true
+	}
+	if len(rest) > 0 && rest[0].isKeyword("shared") {
+		mem.IsShared, rest = true, rest[1:]
+	}
+	mem.Cap = mem.Min
+	return
+}
+
+// parseDataStrings concatenates the strings of a data segment.
+func parseDataStrings(nodes []*node) ([]byte, error) {
+	init := []byte{}
+	for _, n := range nodes {
+		if !n.isAtom(tokenString) {
+			return nil, n.errorf("expected a string, but found %s", n.describe())
+		}
+		init = append(init, n.text...)
+	}
+	return init, nil
+}
@@ -0,0 +1,131 @@
+package wat
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// FormatError is an error in the WebAssembly Text Format source, at the given 1-based line and column.
+type FormatError struct {
+	Line, Col uint32
+	cause     error
+}
+
+// Error implements error.
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("%d:%d: %v", e.Line, e.Col, e.cause)
+}
+
+// Unwrap returns the underlying error.
+func (e *FormatError) Unwrap() error {
+	return e.cause
+}
+
+// node is either a token, or a parenthesized list of nodes, known as an s-expression.
+type node struct {
+	// token is the atom, or the opening parenthesis of a list.
+	token
+	// children are the nodes inside a list.
+	children []*node
+}
+
+func (n *node) isList() bool {
+	return n.typ == tokenLParen
+}
+
+// head returns the keyword at the start of a list, or an empty string if there isn't one.
+func (n *node) head() string {
+	if n.isList() && len(n.children) > 0 && n.children[0].typ == tokenKeyword {
+		return n.children[0].text
+	}
+	return ""
+}
+
+// isAtom returns true if n is a token of the given type.
+func (n *node) isAtom(typ tokenType) bool {
+	return n.typ == typ
+}
+
+// isKeyword returns true if n is the given keyword.
+func (n *node) isKeyword(keyword string) bool {
+	return n.typ == tokenKeyword && n.text == keyword
+}
+
+// isName returns true if n is a string that is valid UTF-8, as names of imports and exports must be.
+func (n *node) isName() bool {
+	return n.typ == tokenString && utf8.ValidString(n.text)
+}
+
+// errorf returns a FormatError at the position of the node.
+func (n *node) errorf(format string, args ...interface{}) error {
+	return &FormatError{Line: n.line, Col: n.col, cause: fmt.Errorf(format, args...)}
+}
+
+// wrap returns err as a FormatError at the position of the node, unless it already has a position.
+func (n *node) wrap(err error) error {
+	var fe *FormatError
+	if err == nil || errors.As(err, &fe) {
+		return err
+	}
+	return &FormatError{Line: n.line, Col: n.col, cause: err}
+}
+
+// describe returns a short description of the node for error messages.
+func (n *node) describe() string {
+	if n.isList() {
+		if h := n.head(); h != "" {
+			return "(" + h + " ...)"
+		}
+		return "list"
+	} else if n.typ == tokenString {
+		return fmt.Sprintf("%q", n.text)
+	}
+	return n.text
+}
+
+// parseNodes reads all s-expressions in the source.
+func parseNodes(source []byte) ([]*node, error) {
+	l := newLexer(source)
+	if err := l.checkUTF8(); err != nil {
+		return nil, err
+	}
+	var stack []*node
+	var top []*node
+	for {
+		tok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		switch tok.typ {
+		case tokenInvalid: // end of the source
+			if len(stack) > 0 {
+				open := stack[len(stack)-1]
+				return nil, open.errorf("unclosed parenthesis")
+			}
+			return top, nil
+		case tokenLParen:
+			stack = append(stack, &node{token: tok})
+			continue
+		case tokenRParen:
+			if len(stack) == 0 {
+				return nil, &FormatError{Line: tok.line, Col: tok.col, cause: errors.New("unexpected )")}
+			}
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			top = appendChild(stack, top, n)
+		default:
+			top = appendChild(stack, top, &node{token: tok})
+		}
+	}
+}
+
+// appendChild appends the node to the innermost open list, or to the top-level nodes when there isn't one.
+func appendChild(stack, top []*node, n *node) []*node {
+	if len(stack) == 0 {
+		return append(top, n)
+	}
+	parent := stack[len(stack)-1]
+	parent.children = append(parent.children, n)
+	return top
+}
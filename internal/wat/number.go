@@ -0,0 +1,232 @@
+package wat
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var errOutOfRange = errors.New("constant out of range")
+
+// scanDigits returns the end of a run of digits in s, which may be separated by single underscores. The result is
+// zero if s doesn't begin with a digit.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#text-int
+func scanDigits(s string, hex bool) int {
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		if c == '_' && i > 0 && i+1 < len(s) {
+			if _, ok := digitValue(s[i+1], hex); ok {
+				i++
+				continue
+			}
+		}
+		if _, ok := digitValue(c, hex); !ok {
+			break
+		}
+		i++
+	}
+	return i
+}
+
+func digitValue(c byte, hex bool) (byte, bool) {
+	if hex {
+		return hexDigit(c)
+	} else if c >= '0' && c <= '9' {
+		return c - '0', true
+	}
+	return 0, false
+}
+
+// scanInteger parses an unsigned integer in decimal, or hexadecimal when prefixed with "0x". ok is false when the
+// syntax is invalid, and overflow is true when the value doesn't fit in 64 bits.
+func scanInteger(s string) (v uint64, overflow, ok bool) {
+	hex := strings.HasPrefix(s, "0x")
+	if hex {
+		s = s[2:]
+	}
+	if n := scanDigits(s, hex); n == 0 || n != len(s) {
+		return 0, false, false
+	}
+	base := uint64(10)
+	if hex {
+		base = 16
+	}
+	for i := 0; i < len(s); i++ {
+		d, ok := digitValue(s[i], hex)
+		if !ok { // underscore
+			continue
+		}
+		if v > (math.MaxUint64-uint64(d))/base {
+			overflow = true
+		}
+		v = v*base + uint64(d)
+	}
+	return v, overflow, true
+}
+
+// parseDigits is like scanInteger, except ok is false on overflow.
+func parseDigits(s string) (uint64, bool) {
+	v, overflow, ok := scanInteger(s)
+	return v, ok && !overflow
+}
+
+// isHugeInteger returns true if s is a syntactically valid integer which doesn't fit in 64 bits.
+func isHugeInteger(s string) bool {
+	_, overflow, ok := scanInteger(s)
+	return ok && overflow
+}
+
+// isFloat returns true if s, without a sign, is a floating point number which isn't also an integer.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#floating-point%E2%91%A6
+func isFloat(s string) bool {
+	if s == "inf" || s == "nan" {
+		return true
+	} else if strings.HasPrefix(s, "nan:0x") {
+		_, ok := parseDigits(s[4:])
+		return ok
+	}
+
+	hex := strings.HasPrefix(s, "0x")
+	if hex {
+		s = s[2:]
+	}
+	n := scanDigits(s, hex)
+	if n == 0 {
+		return false
+	}
+	s = s[n:]
+	fractional := false
+	if strings.HasPrefix(s, ".") {
+		fractional = true
+		s = s[1:]
+		s = s[scanDigits(s, hex):]
+	}
+	if s == "" {
+		return fractional
+	}
+	if hex && (s[0] == 'p' || s[0] == 'P') || !hex && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+			s = s[1:]
+		}
+		n = scanDigits(s, false)
+		return n > 0 && n == len(s)
+	}
+	return false
+}
+
+// parseU32 parses an unsigned 32-bit integer, such as an index or a limit.
+func parseU32(tok token) (uint32, error) {
+	if tok.typ != tokenUN {
+		return 0, fmt.Errorf("expected an unsigned integer, but found %s", tok.text)
+	}
+	v, ok := parseDigits(tok.text)
+	if !ok || v > math.MaxUint32 {
+		return 0, errOutOfRange
+	}
+	return uint32(v), nil
+}
+
+// parseU64 parses an unsigned 64-bit integer, such as a memory offset.
+func parseU64(tok token) (uint64, error) {
+	if tok.typ != tokenUN {
+		return 0, fmt.Errorf("expected an unsigned integer, but found %s", tok.text)
+	}
+	v, ok := parseDigits(tok.text)
+	if !ok {
+		return 0, errOutOfRange
+	}
+	return v, nil
+}
+
+// parseInt parses an integer of the given bit size, which is either signed or unsigned in the text format, and
+// returns it as two's complement.
+func parseInt(tok token, bitSize uint) (uint64, error) {
+	if tok.typ != tokenUN && tok.typ != tokenSN {
+		return 0, fmt.Errorf("expected an integer, but found %s", tok.text)
+	}
+	s := tok.text
+	negative := s[0] == '-'
+	if s[0] == '-' || s[0] == '+' {
+		s = s[1:]
+	}
+	v, ok := parseDigits(s)
+	if !ok {
+		return 0, errOutOfRange
+	}
+	mask := uint64(math.MaxUint64) >> (64 - bitSize)
+	if negative {
+		if v > mask>>1+1 { // e.g. 2^31 for i32
+			return 0, errOutOfRange
+		}
+		return -v & mask, nil
+	} else if v > mask {
+		return 0, errOutOfRange
+	}
+	return v, nil
+}
+
+// parseF32 parses a 32-bit float, which may also be written as an integer, and returns its bits.
+func parseF32(tok token) (uint32, error) {
+	bits, err := parseFloat(tok, 32)
+	return uint32(bits), err
+}
+
+// parseF64 parses a 64-bit float, which may also be written as an integer, and returns its bits.
+func parseF64(tok token) (uint64, error) {
+	return parseFloat(tok, 64)
+}
+
+func parseFloat(tok token, bitSize int) (uint64, error) {
+	if tok.typ != tokenUN && tok.typ != tokenSN && tok.typ != tokenFN {
+		return 0, fmt.Errorf("expected a number, but found %s", tok.text)
+	}
+	s := tok.text
+	negative := s[0] == '-'
+	if s[0] == '-' || s[0] == '+' {
+		s = s[1:]
+	}
+
+	// The layout of the sign, exponent and significand depends on the size.
+	signBit, expBits, fracBits := uint64(1)<<63, uint64(0x7ff)<<52, uint64(1)<<52-1
+	if bitSize == 32 {
+		signBit, expBits, fracBits = 1<<31, 0xff<<23, 1<<23-1
+	}
+
+	var bits uint64
+	switch {
+	case s == "inf":
+		bits = expBits
+	case s == "nan": // canonical NaN, where only the most significant bit of the significand is set
+		bits = expBits | (fracBits+1)>>1
+	case strings.HasPrefix(s, "nan:"):
+		payload, ok := parseDigits(s[4:])
+		if !ok || payload == 0 || payload > fracBits {
+			return 0, errOutOfRange
+		}
+		bits = expBits | payload
+	default:
+		s = strings.ReplaceAll(s, "_", "")
+		if strings.HasPrefix(s, "0x") && !strings.ContainsAny(s, "pP") {
+			s += "p0" // strconv requires an exponent on hexadecimal floats
+		}
+		f, err := strconv.ParseFloat(s, bitSize)
+		if err != nil && !(errors.Is(err, strconv.ErrRange) && !math.IsInf(f, 0)) {
+			return 0, errOutOfRange
+		}
+		if bitSize == 32 {
+			bits = uint64(math.Float32bits(float32(f)))
+		} else {
+			bits = math.Float64bits(f)
+		}
+	}
+	if negative {
+		bits |= signBit
+	}
+	return bits, nil
+}
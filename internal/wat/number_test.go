@@ -0,0 +1,113 @@
+package wat
+
+import (
+	"math"
+	"testing"
+
+	"github.com/AR1011/wazero/internal/testing/require"
+)
+
+func tokenOf(text string) token {
+	return token{typ: classify(text), text: text}
+}
+
+func TestParseInt(t *testing.T) {
+	tests := []struct {
+		input    string
+		bitSize  uint
+		expected uint64
+	}{
+		{input: "0", bitSize: 32, expected: 0},
+		{input: "1_000", bitSize: 32, expected: 1000},
+		{input: "0xffff_ffff", bitSize: 32, expected: math.MaxUint32},
+		{input: "-1", bitSize: 32, expected: math.MaxUint32},
+		{input: "-0x8000_0000", bitSize: 32, expected: 0x8000_0000},
+		{input: "+42", bitSize: 32, expected: 42},
+		{input: "-1", bitSize: 64, expected: math.MaxUint64},
+		{input: "18446744073709551615", bitSize: 64, expected: math.MaxUint64},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.input, func(t *testing.T) {
+			v, err := parseInt(tokenOf(tc.input), tc.bitSize)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, v)
+		})
+	}
+}
+
+func TestParseInt_Errors(t *testing.T) {
+	tests := []struct {
+		input       string
+		bitSize     uint
+		expectedErr string
+	}{
+		{input: "0x1_0000_0000", bitSize: 32, expectedErr: "constant out of range"},
+		{input: "-0x8000_0001", bitSize: 32, expectedErr: "constant out of range"},
+		{input: "18446744073709551616", bitSize: 64, expectedErr: "constant out of range"},
+		{input: "1.5", bitSize: 32, expectedErr: "expected an integer, but found 1.5"},
+		{input: "", bitSize: 32, expectedErr: "expected an integer, but found "},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.input, func(t *testing.T) {
+			_, err := parseInt(tokenOf(tc.input), tc.bitSize)
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestParseF32(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected uint32
+	}{
+		{input: "0", expected: 0},
+		{input: "-0", expected: 0x8000_0000},
+		{input: "1.5", expected: math.Float32bits(1.5)},
+		{input: "1e3", expected: math.Float32bits(1000)},
+		{input: "0x1p-1", expected: math.Float32bits(0.5)},
+		{input: "0x1.8", expected: math.Float32bits(1.5)},
+		{input: "inf", expected: 0x7f80_0000},
+		{input: "-inf", expected: 0xff80_0000},
+		{input: "nan", expected: 0x7fc0_0000},
+		{input: "-nan:0x1", expected: 0xff80_0001},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.input, func(t *testing.T) {
+			v, err := parseF32(tokenOf(tc.input))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, v)
+		})
+	}
+}
+
+func TestParseF64(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected uint64
+	}{
+		{input: "1_000.000_1", expected: math.Float64bits(1000.0001)},
+		{input: "0x1.fffffffffffffp1023", expected: math.Float64bits(math.MaxFloat64)},
+		{input: "nan", expected: 0x7ff8_0000_0000_0000},
+		{input: "nan:0xf_ffff_ffff_ffff", expected: 0x7fff_ffff_ffff_ffff},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.input, func(t *testing.T) {
+			v, err := parseF64(tokenOf(tc.input))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, v)
+		})
+	}
+
+	t.Run("nan payload out of range", func(t *testing.T) {
+		_, err := parseF64(tokenOf("nan:0x10_0000_0000_0000"))
+		require.EqualError(t, err, "constant out of range")
+	})
+}
@@ -0,0 +1,128 @@
+package wat
+
+import (
+	"strings"
+
+	"github.com/AR1011/wazero/internal/leb128"
+	"github.com/AR1011/wazero/internal/wasm"
+)
+
+// instruction is the binary encoding of an instruction name, without its immediates.
+type instruction struct {
+	// prefix is wasm.OpcodeMiscPrefix, wasm.OpcodeVecPrefix or wasm.OpcodeAtomicPrefix, or zero when the opcode is a
+	// single byte.
+	prefix wasm.Opcode
+	opcode byte
+}
+
+// encode returns the binary encoding of the instruction, without its immediates.
+func (in instruction) encode() []byte {
+	if in.prefix != 0 {
+		return append([]byte{in.prefix}, leb128.EncodeUint32(uint32(in.opcode))...)
+	}
+	return []byte{in.opcode}
+}
+
+// Instruction returns the binary encoding of the instruction with the given name in the text format, such as
+// "i32.add", without its immediates. This returns false if the name is unknown.
+func Instruction(name string) ([]byte, bool) {
+	in, ok := instructions[name]
+	if !ok {
+		return nil, false
+	}
+	return in.encode(), true
+}
+
+// MemoryInstruction is like Instruction, except it only accepts instructions with a memarg immediate, such as
+// "i32.load", and also returns their natural alignment, as the exponent of a power of two.
+func MemoryInstruction(name string) (encoded []byte, align uint32, ok bool) {
+	in, ok := instructions[name]
+	if !ok || !isMemoryInstruction(in) || isLaneMemoryInstruction(in) {
+		return nil, 0, false
+	}
+	return in.encode(), naturalAlignment(name), true
+}
+
+// instructions are indexed by their name in the text format.
+var instructions = map[string]instruction{}
+
+func init() {
+	for i := 0; i < 256; i++ {
+		op := byte(i)
+		switch op {
+		case wasm.OpcodeMiscPrefix, wasm.OpcodeVecPrefix, wasm.OpcodeAtomicPrefix, wasm.OpcodeTypedSelect:
+			// These aren't instructions in the text format.
+		default:
+			if name := wasm.InstructionName(op); name != "" {
+				instructions[name] = instruction{opcode: op}
+			}
+		}
+		if name := wasm.MiscInstructionName(op); name != "" {
+			instructions[name] = instruction{prefix: wasm.OpcodeMiscPrefix, opcode: op}
+		}
+		if name := wasm.VectorInstructionName(op); name != "" {
+			instructions[name] = instruction{prefix: wasm.OpcodeVecPrefix, opcode: op}
+		}
+		if name := wasm.AtomicInstructionName(op); name != "" {
+			instructions[name] = instruction{prefix: wasm.OpcodeAtomicPrefix, opcode: op}
+		}
+	}
+
+	// Some internal names differ from the text format, so add the standard ones.
+	instructions["f32.convert_i64_u"] = instruction{opcode: wasm.OpcodeF32ConvertI64U}
+	instructions["i8x16.shuffle"] = instruction{prefix: wasm.OpcodeVecPrefix, opcode: wasm.OpcodeVecV128i8x16Shuffle}
+	instructions["i8x16.sub_sat_s"] = instruction{prefix: wasm.OpcodeVecPrefix, opcode: wasm.OpcodeVecI8x16SubSatS}
+	instructions["i8x16.sub_sat_u"] = instruction{prefix: wasm.OpcodeVecPrefix, opcode: wasm.OpcodeVecI8x16SubSatU}
+	instructions["i64x2.lt_s"] = instruction{prefix: wasm.OpcodeVecPrefix, opcode: wasm.OpcodeVecI64x2LtS}
+	instructions["i64x2.gt_s"] = instruction{prefix: wasm.OpcodeVecPrefix, opcode: wasm.OpcodeVecI64x2GtS}
+	instructions["i64x2.le_s"] = instruction{prefix: wasm.OpcodeVecPrefix, opcode: wasm.OpcodeVecI64x2LeS}
+	instructions["i64x2.ge_s"] = instruction{prefix: wasm.OpcodeVecPrefix, opcode: wasm.OpcodeVecI64x2GeS}
+}
+
+// isMemoryInstruction returns true if the instruction has a memarg immediate.
+func isMemoryInstruction(in instruction) bool {
+	switch in.prefix {
+	case 0:
+		return in.opcode >= wasm.OpcodeI32Load && in.opcode <= wasm.OpcodeI64Store32
+	case wasm.OpcodeVecPrefix:
+		return in.opcode <= wasm.OpcodeVecV128Store ||
+			(in.opcode >= wasm.OpcodeVecV128Load8Lane && in.opcode <= wasm.OpcodeVecV128Load64zero)
+	case wasm.OpcodeAtomicPrefix:
+		return in.opcode != wasm.OpcodeAtomicFence
+	}
+	return false
+}
+
+// isLaneMemoryInstruction returns true if the memarg immediate is followed by a lane index.
+func isLaneMemoryInstruction(in instruction) bool {
+	return in.prefix == wasm.OpcodeVecPrefix && in.opcode >= wasm.OpcodeVecV128Load8Lane && in.opcode <= wasm.OpcodeVecV128Store64Lane
+}
+
+// naturalAlignment returns the default alignment of a memory instruction, as the exponent of a power of two. This is
+// the size of the memory access, which is the first number in the name, such as 16 in "i32.load16_s", or otherwise
+// the size of the value type.
+func naturalAlignment(name string) uint32 {
+	typ, op, _ := strings.Cut(name, ".")
+	if i := strings.IndexAny(op, "123456789"); i >= 0 {
+		if strings.Contains(op[i:], "x") { // e.g. v128.load8x8_s loads 64 bits
+			return 3
+		}
+		switch {
+		case strings.HasPrefix(op[i:], "8"):
+			return 0
+		case strings.HasPrefix(op[i:], "16"):
+			return 1
+		case strings.HasPrefix(op[i:], "32"):
+			return 2
+		case strings.HasPrefix(op[i:], "64"):
+			return 3
+		}
+	}
+	switch typ {
+	case "i64", "f64":
+		return 3
+	case "v128":
+		return 4
+	}
+	return 2 // i32, f32 and memory.atomic.notify
+}
@@ -0,0 +1,71 @@
+// Package wat compiles the WebAssembly Text Format (%.wat) into the WebAssembly Binary Format (%.wasm).
+//
+// Modules can be written with folded or plain instructions, symbolic identifiers, and inline imports and exports.
+// Script files (%.wast) are also accepted, in which case the first module is compiled and any commands, such as
+// assertions, are ignored.
+//
+// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#text-format%E2%91%A0
+package wat
+
+import (
+	"errors"
+
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+)
+
+// Compile returns the WebAssembly Binary Format of the module in the text format source.
+//
+// Symbolic identifiers of the module, functions and locals are retained in the "name" custom section.
+func Compile(source []byte) ([]byte, error) {
+	binary, m, err := parse(source)
+	if err != nil {
+		return nil, err
+	} else if binary != nil {
+		return binary, nil
+	}
+	return binaryencoding.EncodeModule(m), nil
+}
+
+// parse returns the module in the source, or its binary when it is written as (module binary "...").
+func parse(source []byte) (binary []byte, m *wasm.Module, err error) {
+	nodes, err := parseNodes(source)
+	if err != nil {
+		return nil, nil, err
+	} else if len(nodes) == 0 {
+		return nil, nil, &FormatError{Line: 1, Col: 1, cause: errors.New("missing module")}
+	}
+
+	// The module fields can be written without the enclosing (module ...).
+	if nodes[0].head() != "module" {
+		for _, n := range nodes {
+			if n.head() == "module" { // a script, such as a %.wast, whose first command isn't a module
+				return parseModuleNode(n)
+			}
+		}
+		m, err = parseModule(nil, nodes)
+		return nil, m, err
+	}
+	return parseModuleNode(nodes[0])
+}
+
+// parseModuleNode parses (module $id? field*), or the quoted forms used in scripts: (module $id? binary "...") and
+// (module $id? quote "...").
+func parseModuleNode(n *node) (binary []byte, m *wasm.Module, err error) {
+	rest := n.children[1:]
+	var id *node
+	if len(rest) > 0 && rest[0].isAtom(tokenID) {
+		id, rest = rest[0], rest[1:]
+	}
+	if len(rest) > 0 && (rest[0].isKeyword("binary") || rest[0].isKeyword("quote")) {
+		data, err := parseDataStrings(rest[1:])
+		if err != nil {
+			return nil, nil, err
+		} else if rest[0].text == "binary" {
+			return data, nil, nil
+		}
+		return parse(data)
+	}
+	m, err = parseModule(id, rest)
+	return nil, m, err
+}
@@ -0,0 +1,243 @@
+package wat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binary"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+)
+
+func decode(t *testing.T, bin []byte) *wasm.Module {
+	m, err := binary.DecodeModule(bin, api.CoreFeaturesV2|experimentalFeatures, wasm.MemoryLimitPages, false, false, false)
+	require.NoError(t, err)
+	return m
+}
+
+// experimentalFeatures are enabled when decoding, so that tests can use any instruction the compiler supports.
+const experimentalFeatures = api.CoreFeatureTailCall | api.CoreFeatureExceptionHandling | api.CoreFeatureMultiMemory |
+	api.CoreFeatureMemory64
+
+func TestCompile(t *testing.T) {
+	i32, i64 := wasm.ValueTypeI32, wasm.ValueTypeI64
+
+	tests := []struct {
+		name     string
+		input    string
+		expected *wasm.Module
+	}{
+		{
+			name:     "empty",
+			input:    "(module)",
+			expected: &wasm.Module{},
+		},
+		{
+			name:  "fields without module",
+			input: `(memory 1) (export "memory" (memory 0))`,
+			expected: &wasm.Module{
+				MemorySection: []wasm.Memory{{Min: 1, Cap: 1, Max: wasm.MemoryLimitPages}},
+				ExportSection: []wasm.Export{{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0}},
+			},
+		},
+		{
+			name: "folded instructions with names",
+			input: `(module $math
+	(func $add (export "add") (param $x i32) (param $y i32) (result i32)
+		(i32.add (local.get $x) (local.get $y))
+	)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i32, i32}, Results: []wasm.ValueType{i32}}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []wasm.Code{{Body: []byte{
+					wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd,
+				}}},
+				ExportSection: []wasm.Export{{Name: "add", Type: wasm.ExternTypeFunc, Index: 0}},
+				NameSection: &wasm.NameSection{
+					ModuleName:    "math",
+					FunctionNames: wasm.NameMap{{Index: 0, Name: "add"}},
+					LocalNames:    wasm.IndirectNameMap{{Index: 0, NameMap: wasm.NameMap{{Index: 0, Name: "x"}, {Index: 1, Name: "y"}}}},
+				},
+			},
+		},
+		{
+			name: "plain instructions and blocks",
+			input: `(module
+	(func (param i64) (result i64) (local i64)
+		block $done
+			loop $again
+				local.get 0
+				i64.eqz
+				br_if $done
+				local.get 0
+				i64.const -1
+				i64.add
+				local.set 0
+				br $again
+			end
+		end
+		local.get 1
+	)
+)`,
+			expected: &wasm.Module{
+				TypeSection:     []wasm.FunctionType{{Params: []wasm.ValueType{i64}, Results: []wasm.ValueType{i64}}},
+				FunctionSection: []wasm.Index{0},
+				CodeSection: []wasm.Code{{LocalTypes: []wasm.ValueType{i64}, Body: []byte{
+					wasm.OpcodeBlock, 0x40,
+					wasm.OpcodeLoop, 0x40,
+					wasm.OpcodeLocalGet, 0, wasm.OpcodeI64Eqz, wasm.OpcodeBrIf, 1,
+					wasm.OpcodeLocalGet, 0, wasm.OpcodeI64Const, 0x7f, wasm.OpcodeI64Add, wasm.OpcodeLocalSet, 0,
+					wasm.OpcodeBr, 0,
+					wasm.OpcodeEnd,
+					wasm.OpcodeEnd,
+					wasm.OpcodeLocalGet, 1,
+					wasm.OpcodeEnd,
+				}}},
+			},
+		},
+		{
+			name: "inline import and data",
+			input: `(module
+	(func $log (import "env" "log") (param i32 i32))
+	(memory (export "mem") 1)
+	(data (i32.const 8) "hi\0a")
+	(func (export "run") (call $log (i32.const 8) (i32.const 3)))
+)`,
+			expected: &wasm.Module{
+				TypeSection: []wasm.FunctionType{
+					{Params: []wasm.ValueType{i32, i32}},
+					{},
+				},
+				ImportSection:       []wasm.Import{{Module: "env", Name: "log", Type: wasm.ExternTypeFunc, DescFunc: 0}},
+				ImportFunctionCount: 1,
+				FunctionSection:     []wasm.Index{1},
+				MemorySection:       []wasm.Memory{{Min: 1, Cap: 1, Max: wasm.MemoryLimitPages}},
+				CodeSection: []wasm.Code{{Body: []byte{
+					wasm.OpcodeI32Const, 8, wasm.OpcodeI32Const, 3, wasm.OpcodeCall, 0, wasm.OpcodeEnd,
+				}}},
+				ExportSection: []wasm.Export{
+					{Name: "mem", Type: wasm.ExternTypeMemory, Index: 0},
+					{Name: "run", Type: wasm.ExternTypeFunc, Index: 1},
+				},
+				DataSection: []wasm.DataSegment{{
+					OffsetExpression: wasm.ConstantExpression{Opcode: wasm.OpcodeI32Const, Data: []byte{8}},
+					Init:             []byte("hi\n"),
+				}},
+				NameSection: &wasm.NameSection{FunctionNames: wasm.NameMap{{Index: 0, Name: "log"}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			bin, err := Compile([]byte(tc.input))
+			require.NoError(t, err)
+			require.Equal(t, binaryencoding.EncodeModule(tc.expected), bin)
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		name, input, expectedErr string
+	}{
+		{
+			name:        "empty",
+			input:       "",
+			expectedErr: "1:1: missing module",
+		},
+		{
+			name:        "unclosed",
+			input:       "(module\n  (func)",
+			expectedErr: "1:1: unclosed parenthesis",
+		},
+		{
+			name:        "unknown instruction",
+			input:       "(module\n  (func\n    i32.frob))",
+			expectedErr: "3:5: unknown instruction i32.frob",
+		},
+		{
+			name:        "unknown identifier",
+			input:       "(module (func call $nope))",
+			expectedErr: "1:20: unknown func $nope",
+		},
+		{
+			name:        "constant out of range",
+			input:       "(module (func i32.const 0x100000000 drop))",
+			expectedErr: "1:25: constant out of range",
+		},
+		{
+			name:        "missing separator",
+			input:       `(module (func $f"x"))`,
+			expectedErr: "1:15: missing separator after id",
+		},
+		{
+			name:        "missing offset",
+			input:       "(module (memory 1) (func (drop (i32.load offset= (i32.const 0)))))",
+			expectedErr: "1:42: missing value for offset=",
+		},
+		{
+			name:        "missing align",
+			input:       "(module (memory 1) (func (drop (i32.load align= (i32.const 0)))))",
+			expectedErr: "1:42: missing value for align=",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Compile([]byte(tc.input))
+			require.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestCompile_Binary(t *testing.T) {
+	bin, err := Compile([]byte(`(module binary "\00asm" "\01\00\00\00")`))
+	require.NoError(t, err)
+	require.Equal(t, []byte("\x00asm\x01\x00\x00\x00"), bin)
+}
+
+// TestCompile_Testdata ensures text format sources in this repository compile to the same module as their binary
+// siblings, which were generated with wat2wasm.
+func TestCompile_Testdata(t *testing.T) {
+	var files []string
+	for _, dir := range []string{"../../examples", "../../cmd/wazero/testdata"} {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && strings.HasSuffix(path, ".wat") {
+				files = append(files, path)
+			}
+			return err
+		})
+		require.NoError(t, err)
+	}
+	require.NotEqual(t, 0, len(files))
+
+	for _, f := range files {
+		wasmPath := strings.TrimSuffix(f, ".wat") + ".wasm"
+		expectedBin, err := os.ReadFile(wasmPath)
+		if err != nil {
+			continue // not every source has a binary sibling
+		}
+		t.Run(f, func(t *testing.T) {
+			source, err := os.ReadFile(f)
+			require.NoError(t, err)
+			bin, err := Compile(source)
+			require.NoError(t, err)
+
+			expected, actual := decode(t, expectedBin), decode(t, bin)
+			// wat2wasm writes the names of more things, and other custom sections.
+			expected.NameSection, actual.NameSection = nil, nil
+			expected.CustomSections, actual.CustomSections = nil, nil
+			expected.DataCountSection, actual.DataCountSection = nil, nil
+			expected.ID, actual.ID = wasm.ModuleID{}, wasm.ModuleID{}
+			require.Equal(t, expected, actual)
+		})
+	}
+}
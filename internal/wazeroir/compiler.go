@@ -31,6 +31,10 @@ type (
 		originalStackLenWithoutParam int
 		blockType                    *wasm.FunctionType
 		kind                         controlFrameKind
+		// isTryTable is true if this frame is created by try_table, and then exceptionHandlerIndex is the index of
+		// CompilationResult.ExceptionHandlers for it.
+		isTryTable            bool
+		exceptionHandlerIndex int
 	}
 	controlFrames struct{ frames []controlFrame }
 )
@@ -182,6 +186,12 @@ type Compiler struct {
 	funcs []uint32
 	// globals holds the global types for all declared globals in the module where the target function exists.
 	globals []wasm.GlobalType
+	// memories holds all declared memories in the module where the target function exists.
+	memories []*wasm.Memory
+	// hasMemory64 is true if any of memories is 64-bit (api.CoreFeatureMemory64).
+	hasMemory64 bool
+	// tags holds the type indexes for all declared tags in the module where the target function exists.
+	tags []wasm.Index
 
 	// needSourceOffset is true if this module requires DWARF based stack trace.
 	needSourceOffset bool
@@ -189,6 +199,10 @@ type Compiler struct {
 	bodyOffsetInCodeSection uint64
 
 	ensureTermination bool
+	// fuelMetering is true if the fuel is consumed on the function entry and the loop headers.
+	fuelMetering bool
+	// epochInterruption is true if the epoch deadline is checked on the function entry and the loop headers.
+	epochInterruption bool
 	// Pre-allocated bytes.Reader to be used in various places.
 	br             *bytes.Reader
 	funcTypeToSigs funcTypeToIRSignatures
@@ -219,7 +233,7 @@ type CompilationResult struct {
 
 	// IROperationSourceOffsetsInWasmBinary is index-correlated with Operation and maps each operation to the corresponding source instruction's
 	// offset in the original WebAssembly binary.
-	// Non nil only when the given Wasm module has the DWARF section.
+	// Non nil only when the given Wasm module has the DWARF section, or Compiler.RecordSourceOffsets was called.
 	IROperationSourceOffsetsInWasmBinary []uint64
 
 	// LabelCallers maps Label to the number of callers to that label.
@@ -237,6 +251,13 @@ type CompilationResult struct {
 	LabelCallers map[Label]uint32
 	// UsesMemory is true if this function might use memory.
 	UsesMemory bool
+	// BodyStart is the index in Operations of the first operation compiled from the function body. The operations
+	// before it are the prologue of the function, e.g. the initialization of non-param locals.
+	BodyStart int
+	// ExceptionHandlers holds the handlers of try_table instructions in this function, in the order of
+	// appearance. Handlers of the nested try_table come after the enclosing one, so engines must search
+	// this from the end to find the innermost handler of an operation.
+	ExceptionHandlers []ExceptionHandler
 
 	// The following fields are per-module values, not per-function.
 
@@ -254,51 +275,96 @@ type CompilationResult struct {
 	HasDataInstances bool
 	// HasDataInstances is true if the module has element instances which might be used by table.init or elem.drop instructions.
 	HasElementInstances bool
+	// LimitsCallStackDepth is true if the module from which this function is compiled has wasm.Module MaxCallStackDepth,
+	// in which case engines track the depth of the call stack on the entry to and the return from this function.
+	LimitsCallStackDepth bool
+}
+
+// ExceptionHandler is the lowered form of the catch clauses of a try_table instruction.
+type ExceptionHandler struct {
+	// Begin and End are the range of Operations, [Begin, End), where exceptions are caught by this handler.
+	Begin, End int
+	// StackHeightInUint64 is the number of values on the stack below the try_table block, counted from the
+	// first parameter of the function. The stack is unwound to this height before pushing the payload of the
+	// caught exception. Note that this doesn't include the call frame slots reserved with callFrameStackSizeInUint64.
+	StackHeightInUint64 int
+	// Catches are the catch clauses in the order of declaration.
+	Catches []ExceptionHandlerCatch
+}
+
+// ExceptionHandlerCatch is a catch clause of ExceptionHandler.
+type ExceptionHandlerCatch struct {
+	// Kind is one of catch, catch_ref, catch_all and catch_all_ref.
+	Kind wasm.TryTableCatchKind
+	// Tag is the index of the tag caught by this clause. Unused for catch_all and catch_all_ref.
+	Tag wasm.Index
+	// TagType is the type of Tag, or nil for catch_all and catch_all_ref.
+	TagType *wasm.FunctionType
+	// Label is the landing pad which expects the payload of the exception on top of the stack: the parameters of
+	// the tag for catch and catch_ref, followed by the exnref for catch_ref and catch_all_ref.
+	Label Label
 }
 
 // NewCompiler returns the new *Compiler for the given parameters.
 // Use Compiler.Next function to get compilation result per function.
 func NewCompiler(enabledFeatures api.CoreFeatures, callFrameStackSizeInUint64 int, module *wasm.Module, ensureTermination bool) (*Compiler, error) {
-	functions, globals, mem, tables, err := module.AllDeclarations()
+	functions, globals, memories, tables, err := module.AllDeclarations()
 	if err != nil {
 		return nil, err
 	}
 
-	hasMemory, hasTable, hasDataInstances, hasElementInstances := mem != nil, len(tables) > 0,
+	hasMemory, hasTable, hasDataInstances, hasElementInstances := len(memories) > 0, len(tables) > 0,
 		len(module.DataSection) > 0, len(module.ElementSection) > 0
 
 	types := module.TypeSection
 
+	var hasMemory64 bool
+	for _, mem := range memories {
+		hasMemory64 = hasMemory64 || mem.IsMemory64
+	}
+
 	c := &Compiler{
 		module:                     module,
 		enabledFeatures:            enabledFeatures,
 		controlFrames:              controlFrames{},
 		callFrameStackSizeInUint64: callFrameStackSizeInUint64,
 		result: CompilationResult{
-			Globals:             globals,
-			Functions:           functions,
-			Types:               types,
-			HasMemory:           hasMemory,
-			HasTable:            hasTable,
-			HasDataInstances:    hasDataInstances,
-			HasElementInstances: hasElementInstances,
-			LabelCallers:        map[Label]uint32{},
+			Globals:              globals,
+			Functions:            functions,
+			Types:                types,
+			HasMemory:            hasMemory,
+			HasTable:             hasTable,
+			HasDataInstances:     hasDataInstances,
+			HasElementInstances:  hasElementInstances,
+			LimitsCallStackDepth: module.MaxCallStackDepth != 0,
+			LabelCallers:         map[Label]uint32{},
 		},
 		globals:           globals,
+		memories:          memories,
+		hasMemory64:       hasMemory64,
 		funcs:             functions,
 		types:             types,
+		tags:              module.AllTags(),
 		ensureTermination: ensureTermination,
+		fuelMetering:      module.FuelMetering,
+		epochInterruption: module.EpochInterruption,
 		br:                bytes.NewReader(nil),
 		funcTypeToSigs: funcTypeToIRSignatures{
 			indirectCalls: make([]*signature, len(types)),
 			directCalls:   make([]*signature, len(types)),
 			wasmTypes:     types,
 		},
-		needSourceOffset: module.DWARFLines != nil,
+		needSourceOffset: module.DWARFLines != nil || module.CoreDump,
 	}
 	return c, nil
 }
 
+// RecordSourceOffsets makes the subsequent results have CompilationResult.IROperationSourceOffsetsInWasmBinary even
+// when the module doesn't have the DWARF section, e.g. to notify experimental.BlockListener.
+func (c *Compiler) RecordSourceOffsets() {
+	c.needSourceOffset = true
+}
+
 // Next returns the next CompilationResult for this Compiler.
 func (c *Compiler) Next() (*CompilationResult, error) {
 	funcIndex := c.next
@@ -309,6 +375,7 @@ func (c *Compiler) Next() (*CompilationResult, error) {
 	c.result.Operations = c.result.Operations[:0]
 	c.result.IROperationSourceOffsetsInWasmBinary = c.result.IROperationSourceOffsetsInWasmBinary[:0]
 	c.result.UsesMemory = false
+	c.result.ExceptionHandlers = c.result.ExceptionHandlers[:0]
 	// Clears the existing entries in LabelCallers.
 	for frameID := uint32(0); frameID <= c.currentFrameID; frameID++ {
 		for k := LabelKind(0); k < LabelKindNum; k++ {
@@ -344,6 +411,15 @@ func (c *Compiler) compile(sig *wasm.FunctionType, body []byte, localTypes []was
 
 	c.initializeStack()
 
+	// Consume the fuel on the function entry.
+	if c.fuelMetering {
+		c.emit(NewOperationBuiltinFunctionConsumeFuel())
+	}
+	// Check the epoch deadline on the function entry.
+	if c.epochInterruption {
+		c.emit(NewOperationBuiltinFunctionCheckEpochDeadline())
+	}
+
 	// Emit const expressions for locals.
 	// Note that here we don't take function arguments
 	// into account, meaning that callers must push
@@ -352,6 +428,8 @@ func (c *Compiler) compile(sig *wasm.FunctionType, body []byte, localTypes []was
 		c.emitDefaultValue(t)
 	}
 
+	c.result.BodyStart = len(c.result.Operations)
+
 	// Insert the function control frame.
 	c.controlFrames.push(controlFrame{
 		frameID:   c.nextFrameID(),
@@ -379,6 +457,8 @@ func (c *Compiler) handleInstruction() error {
 			instName = wasm.VectorInstructionName(c.body[c.pc+1])
 		} else if op == wasm.OpcodeMiscPrefix {
 			instName = wasm.MiscInstructionName(c.body[c.pc+1])
+		} else if op == wasm.OpcodeAtomicPrefix {
+			instName = wasm.AtomicInstructionName(c.body[c.pc+1])
 		} else {
 			instName = wasm.InstructionName(op)
 		}
@@ -432,6 +512,33 @@ operatorSwitch:
 		}
 		c.controlFrames.push(frame)
 
+	case wasm.OpcodeTryTable:
+		c.br.Reset(c.body[c.pc+1:])
+		bt, num, err := wasm.DecodeBlockType(c.types, c.br, c.enabledFeatures)
+		if err != nil {
+			return fmt.Errorf("reading block type for try_table instruction: %w", err)
+		}
+		catches, catchesNum, err := wasm.DecodeTryTableCatches(c.br)
+		if err != nil {
+			return fmt.Errorf("reading catches for try_table instruction: %w", err)
+		}
+		c.pc += num + catchesNum
+
+		if c.unreachableState.on {
+			// If it is currently in unreachable,
+			// just remove the entire block.
+			c.unreachableState.depth++
+			break operatorSwitch
+		}
+		c.emitTryTable(bt, catches)
+
+	case wasm.OpcodeThrow:
+		c.emit(NewOperationThrow(index))
+		c.markUnreachable()
+	case wasm.OpcodeThrowRef:
+		c.emit(NewOperationThrowRef())
+		c.markUnreachable()
+
 	case wasm.OpcodeLoop:
 		c.br.Reset(c.body[c.pc+1:])
 		bt, num, err := wasm.DecodeBlockType(c.types, c.br, c.enabledFeatures)
@@ -474,6 +581,13 @@ operatorSwitch:
 		if c.ensureTermination {
 			c.emit(NewOperationBuiltinFunctionCheckExitCode())
 		}
+		// Similarly, the fuel is consumed on each iteration of the loop.
+		if c.fuelMetering {
+			c.emit(NewOperationBuiltinFunctionConsumeFuel())
+		}
+		if c.epochInterruption {
+			c.emit(NewOperationBuiltinFunctionCheckEpochDeadline())
+		}
 	case wasm.OpcodeIf:
 		c.br.Reset(c.body[c.pc+1:])
 		bt, num, err := wasm.DecodeBlockType(c.types, c.br, c.enabledFeatures)
@@ -576,6 +690,9 @@ operatorSwitch:
 			if c.controlFrames.empty() {
 				return nil
 			}
+			if frame.isTryTable {
+				c.result.ExceptionHandlers[frame.exceptionHandlerIndex].End = len(c.result.Operations)
+			}
 
 			c.stack = c.stack[:frame.originalStackLenWithoutParam]
 			for _, t := range frame.blockType.Results {
@@ -600,6 +717,9 @@ operatorSwitch:
 		}
 
 		frame := c.controlFrames.pop()
+		if frame.isTryTable {
+			c.result.ExceptionHandlers[frame.exceptionHandlerIndex].End = len(c.result.Operations)
+		}
 
 		// We need to reset the stack so that
 		// the values pushed inside the block.
@@ -781,6 +901,26 @@ operatorSwitch:
 		c.emit(
 			NewOperationCallIndirect(typeIndex, tableIndex),
 		)
+	case wasm.OpcodeReturnCall:
+		// The engines discard the current frame except the arguments on top of the stack,
+		// so there's no need to emit the drop operation here unlike return.
+		c.emit(
+			NewOperationTailCall(index),
+		)
+		// Return call operation is stack-polymorphic like return.
+		c.markUnreachable()
+	case wasm.OpcodeReturnCallIndirect:
+		typeIndex := index
+		tableIndex, n, err := leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return fmt.Errorf("read target for return_call_indirect: %w", err)
+		}
+		c.pc += n
+		c.emit(
+			NewOperationTailCallIndirect(typeIndex, tableIndex),
+		)
+		// Return call operation is stack-polymorphic like return.
+		c.markUnreachable()
 	case wasm.OpcodeDrop:
 		r := InclusiveRange{Start: 0, End: 0}
 		if peekValueType == UnsignedTypeV128 {
@@ -1018,16 +1158,20 @@ operatorSwitch:
 			NewOperationStore32(imm),
 		)
 	case wasm.OpcodeMemorySize:
-		c.result.UsesMemory = true
-		c.pc++ // Skip the reserved one byte.
+		memoryIndex, err := c.readMemoryIndex(wasm.OpcodeMemorySizeName)
+		if err != nil {
+			return err
+		}
 		c.emit(
-			NewOperationMemorySize(),
+			NewOperationMemorySize(memoryIndex, c.isMemory64(memoryIndex)),
 		)
 	case wasm.OpcodeMemoryGrow:
-		c.result.UsesMemory = true
-		c.pc++ // Skip the reserved one byte.
+		memoryIndex, err := c.readMemoryIndex(wasm.OpcodeMemoryGrowName)
+		if err != nil {
+			return err
+		}
 		c.emit(
-			NewOperationMemoryGrow(),
+			NewOperationMemoryGrow(memoryIndex, c.isMemory64(memoryIndex)),
 		)
 	case wasm.OpcodeI32Const:
 		val, num, err := leb128.LoadInt32(c.body[c.pc+1:])
@@ -1658,9 +1802,13 @@ operatorSwitch:
 			if err != nil {
 				return fmt.Errorf("reading i32.const value: %v", err)
 			}
-			c.pc += num + 1 // +1 to skip the memory index which is fixed to zero.
+			c.pc += num
+			memoryIndex, err := c.readMemoryIndex(wasm.OpcodeMemoryInitName)
+			if err != nil {
+				return err
+			}
 			c.emit(
-				NewOperationMemoryInit(dataIndex),
+				NewOperationMemoryInit(dataIndex, memoryIndex, c.isMemory64(memoryIndex)),
 			)
 		case wasm.OpcodeMiscDataDrop:
 			dataIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
@@ -1672,16 +1820,24 @@ operatorSwitch:
 				NewOperationDataDrop(dataIndex),
 			)
 		case wasm.OpcodeMiscMemoryCopy:
-			c.result.UsesMemory = true
-			c.pc += 2 // +2 to skip two memory indexes which are fixed to zero.
+			dstMemoryIndex, err := c.readMemoryIndex(wasm.OpcodeMemoryCopyName)
+			if err != nil {
+				return err
+			}
+			srcMemoryIndex, err := c.readMemoryIndex(wasm.OpcodeMemoryCopyName)
+			if err != nil {
+				return err
+			}
 			c.emit(
-				NewOperationMemoryCopy(),
+				NewOperationMemoryCopy(dstMemoryIndex, srcMemoryIndex, c.isMemory64(dstMemoryIndex) || c.isMemory64(srcMemoryIndex)),
 			)
 		case wasm.OpcodeMiscMemoryFill:
-			c.result.UsesMemory = true
-			c.pc += 1 // +1 to skip the memory index which is fixed to zero.
+			memoryIndex, err := c.readMemoryIndex(wasm.OpcodeMemoryFillName)
+			if err != nil {
+				return err
+			}
 			c.emit(
-				NewOperationMemoryFill(),
+				NewOperationMemoryFill(memoryIndex, c.isMemory64(memoryIndex)),
 			)
 		case wasm.OpcodeMiscTableInit:
 			elemIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
@@ -2848,6 +3004,572 @@ operatorSwitch:
 		default:
 			return fmt.Errorf("unsupported vector instruction in wazeroir: %s", wasm.VectorInstructionName(vecOp))
 		}
+	case wasm.OpcodeAtomicPrefix:
+		c.pc++
+		switch atomicOp := c.body[c.pc]; atomicOp {
+		case wasm.OpcodeAtomicMemoryWait32:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicMemoryWait32Name)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicMemoryWait(UnsignedTypeI32, arg),
+			)
+		case wasm.OpcodeAtomicMemoryWait64:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicMemoryWait64Name)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicMemoryWait(UnsignedTypeI64, arg),
+			)
+		case wasm.OpcodeAtomicMemoryNotify:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicMemoryNotifyName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicMemoryNotify(arg),
+			)
+		case wasm.OpcodeAtomicFence:
+			// The fence instruction has a reserved zero byte immediate which is already validated.
+			c.pc++
+			c.emit(
+				NewOperationAtomicFence(),
+			)
+		case wasm.OpcodeAtomicI32Load:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32LoadName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicLoad(UnsignedTypeI32, arg),
+			)
+		case wasm.OpcodeAtomicI64Load:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64LoadName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicLoad(UnsignedTypeI64, arg),
+			)
+		case wasm.OpcodeAtomicI32Load8U:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Load8UName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicLoad8(UnsignedTypeI32, arg),
+			)
+		case wasm.OpcodeAtomicI32Load16U:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Load16UName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicLoad16(UnsignedTypeI32, arg),
+			)
+		case wasm.OpcodeAtomicI64Load8U:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Load8UName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicLoad8(UnsignedTypeI64, arg),
+			)
+		case wasm.OpcodeAtomicI64Load16U:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Load16UName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicLoad16(UnsignedTypeI64, arg),
+			)
+		case wasm.OpcodeAtomicI32Store:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32StoreName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicStore(UnsignedTypeI32, arg),
+			)
+		case wasm.OpcodeAtomicI64Store:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64StoreName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicStore(UnsignedTypeI64, arg),
+			)
+		case wasm.OpcodeAtomicI32Store8:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Store8Name)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicStore8(UnsignedTypeI32, arg),
+			)
+		case wasm.OpcodeAtomicI32Store16:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Store16Name)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicStore16(UnsignedTypeI32, arg),
+			)
+		case wasm.OpcodeAtomicI64Store8:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Store8Name)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicStore8(UnsignedTypeI64, arg),
+			)
+		case wasm.OpcodeAtomicI64Store16:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Store16Name)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicStore16(UnsignedTypeI64, arg),
+			)
+		// The 32-bit variants on i64 are lowered onto the i32 operations, which only use the lower 32 bits
+		// of the operands, followed by the unsigned extension of the result.
+		case wasm.OpcodeAtomicI64Load32U:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Load32UName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicLoad(UnsignedTypeI32, arg),
+			)
+			c.emit(
+				NewOperationExtend(false),
+			)
+		case wasm.OpcodeAtomicI64Store32:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Store32Name)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicStore(UnsignedTypeI32, arg),
+			)
+		case wasm.OpcodeAtomicI32RmwAdd:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32RmwAddName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI32, arg, AtomicArithmeticOpAdd),
+			)
+		case wasm.OpcodeAtomicI64RmwAdd:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64RmwAddName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI64, arg, AtomicArithmeticOpAdd),
+			)
+		case wasm.OpcodeAtomicI32Rmw8AddU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw8AddUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8(UnsignedTypeI32, arg, AtomicArithmeticOpAdd),
+			)
+		case wasm.OpcodeAtomicI64Rmw8AddU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw8AddUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8(UnsignedTypeI64, arg, AtomicArithmeticOpAdd),
+			)
+		case wasm.OpcodeAtomicI32Rmw16AddU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw16AddUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16(UnsignedTypeI32, arg, AtomicArithmeticOpAdd),
+			)
+		case wasm.OpcodeAtomicI64Rmw16AddU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw16AddUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16(UnsignedTypeI64, arg, AtomicArithmeticOpAdd),
+			)
+		case wasm.OpcodeAtomicI64Rmw32AddU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw32AddUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI32, arg, AtomicArithmeticOpAdd),
+			)
+			c.emit(
+				NewOperationExtend(false),
+			)
+		case wasm.OpcodeAtomicI32RmwSub:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32RmwSubName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI32, arg, AtomicArithmeticOpSub),
+			)
+		case wasm.OpcodeAtomicI64RmwSub:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64RmwSubName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI64, arg, AtomicArithmeticOpSub),
+			)
+		case wasm.OpcodeAtomicI32Rmw8SubU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw8SubUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8(UnsignedTypeI32, arg, AtomicArithmeticOpSub),
+			)
+		case wasm.OpcodeAtomicI64Rmw8SubU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw8SubUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8(UnsignedTypeI64, arg, AtomicArithmeticOpSub),
+			)
+		case wasm.OpcodeAtomicI32Rmw16SubU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw16SubUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16(UnsignedTypeI32, arg, AtomicArithmeticOpSub),
+			)
+		case wasm.OpcodeAtomicI64Rmw16SubU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw16SubUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16(UnsignedTypeI64, arg, AtomicArithmeticOpSub),
+			)
+		case wasm.OpcodeAtomicI64Rmw32SubU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw32SubUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI32, arg, AtomicArithmeticOpSub),
+			)
+			c.emit(
+				NewOperationExtend(false),
+			)
+		case wasm.OpcodeAtomicI32RmwAnd:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32RmwAndName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI32, arg, AtomicArithmeticOpAnd),
+			)
+		case wasm.OpcodeAtomicI64RmwAnd:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64RmwAndName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI64, arg, AtomicArithmeticOpAnd),
+			)
+		case wasm.OpcodeAtomicI32Rmw8AndU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw8AndUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8(UnsignedTypeI32, arg, AtomicArithmeticOpAnd),
+			)
+		case wasm.OpcodeAtomicI64Rmw8AndU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw8AndUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8(UnsignedTypeI64, arg, AtomicArithmeticOpAnd),
+			)
+		case wasm.OpcodeAtomicI32Rmw16AndU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw16AndUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16(UnsignedTypeI32, arg, AtomicArithmeticOpAnd),
+			)
+		case wasm.OpcodeAtomicI64Rmw16AndU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw16AndUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16(UnsignedTypeI64, arg, AtomicArithmeticOpAnd),
+			)
+		case wasm.OpcodeAtomicI64Rmw32AndU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw32AndUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI32, arg, AtomicArithmeticOpAnd),
+			)
+			c.emit(
+				NewOperationExtend(false),
+			)
+		case wasm.OpcodeAtomicI32RmwOr:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32RmwOrName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI32, arg, AtomicArithmeticOpOr),
+			)
+		case wasm.OpcodeAtomicI64RmwOr:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64RmwOrName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI64, arg, AtomicArithmeticOpOr),
+			)
+		case wasm.OpcodeAtomicI32Rmw8OrU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw8OrUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8(UnsignedTypeI32, arg, AtomicArithmeticOpOr),
+			)
+		case wasm.OpcodeAtomicI64Rmw8OrU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw8OrUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8(UnsignedTypeI64, arg, AtomicArithmeticOpOr),
+			)
+		case wasm.OpcodeAtomicI32Rmw16OrU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw16OrUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16(UnsignedTypeI32, arg, AtomicArithmeticOpOr),
+			)
+		case wasm.OpcodeAtomicI64Rmw16OrU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw16OrUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16(UnsignedTypeI64, arg, AtomicArithmeticOpOr),
+			)
+		case wasm.OpcodeAtomicI64Rmw32OrU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw32OrUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI32, arg, AtomicArithmeticOpOr),
+			)
+			c.emit(
+				NewOperationExtend(false),
+			)
+		case wasm.OpcodeAtomicI32RmwXor:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32RmwXorName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI32, arg, AtomicArithmeticOpXor),
+			)
+		case wasm.OpcodeAtomicI64RmwXor:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64RmwXorName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI64, arg, AtomicArithmeticOpXor),
+			)
+		case wasm.OpcodeAtomicI32Rmw8XorU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw8XorUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8(UnsignedTypeI32, arg, AtomicArithmeticOpXor),
+			)
+		case wasm.OpcodeAtomicI64Rmw8XorU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw8XorUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8(UnsignedTypeI64, arg, AtomicArithmeticOpXor),
+			)
+		case wasm.OpcodeAtomicI32Rmw16XorU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw16XorUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16(UnsignedTypeI32, arg, AtomicArithmeticOpXor),
+			)
+		case wasm.OpcodeAtomicI64Rmw16XorU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw16XorUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16(UnsignedTypeI64, arg, AtomicArithmeticOpXor),
+			)
+		case wasm.OpcodeAtomicI64Rmw32XorU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw32XorUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI32, arg, AtomicArithmeticOpXor),
+			)
+			c.emit(
+				NewOperationExtend(false),
+			)
+		case wasm.OpcodeAtomicI32RmwXchg:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32RmwXchgName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI32, arg, AtomicArithmeticOpNop),
+			)
+		case wasm.OpcodeAtomicI64RmwXchg:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64RmwXchgName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI64, arg, AtomicArithmeticOpNop),
+			)
+		case wasm.OpcodeAtomicI32Rmw8XchgU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw8XchgUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8(UnsignedTypeI32, arg, AtomicArithmeticOpNop),
+			)
+		case wasm.OpcodeAtomicI64Rmw8XchgU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw8XchgUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8(UnsignedTypeI64, arg, AtomicArithmeticOpNop),
+			)
+		case wasm.OpcodeAtomicI32Rmw16XchgU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw16XchgUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16(UnsignedTypeI32, arg, AtomicArithmeticOpNop),
+			)
+		case wasm.OpcodeAtomicI64Rmw16XchgU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw16XchgUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16(UnsignedTypeI64, arg, AtomicArithmeticOpNop),
+			)
+		case wasm.OpcodeAtomicI64Rmw32XchgU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw32XchgUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW(UnsignedTypeI32, arg, AtomicArithmeticOpNop),
+			)
+			c.emit(
+				NewOperationExtend(false),
+			)
+		case wasm.OpcodeAtomicI32RmwCmpxchg:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32RmwCmpxchgName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMWCmpxchg(UnsignedTypeI32, arg),
+			)
+		case wasm.OpcodeAtomicI64RmwCmpxchg:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64RmwCmpxchgName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMWCmpxchg(UnsignedTypeI64, arg),
+			)
+		case wasm.OpcodeAtomicI32Rmw8CmpxchgU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw8CmpxchgUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8Cmpxchg(UnsignedTypeI32, arg),
+			)
+		case wasm.OpcodeAtomicI64Rmw8CmpxchgU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw8CmpxchgUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW8Cmpxchg(UnsignedTypeI64, arg),
+			)
+		case wasm.OpcodeAtomicI32Rmw16CmpxchgU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI32Rmw16CmpxchgUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16Cmpxchg(UnsignedTypeI32, arg),
+			)
+		case wasm.OpcodeAtomicI64Rmw16CmpxchgU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw16CmpxchgUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMW16Cmpxchg(UnsignedTypeI64, arg),
+			)
+		case wasm.OpcodeAtomicI64Rmw32CmpxchgU:
+			arg, err := c.readMemoryArg(wasm.OpcodeAtomicI64Rmw32CmpxchgUName)
+			if err != nil {
+				return err
+			}
+			c.emit(
+				NewOperationAtomicRMWCmpxchg(UnsignedTypeI32, arg),
+			)
+			c.emit(
+				NewOperationExtend(false),
+			)
+		default:
+			return fmt.Errorf("unsupported atomic instruction in wazeroir: %s", wasm.AtomicInstructionName(atomicOp))
+		}
 	default:
 		return fmt.Errorf("unsupported instruction in wazeroir: 0x%x", op)
 	}
@@ -2857,6 +3579,70 @@ operatorSwitch:
 	return nil
 }
 
+// emitTryTable emits the operations for entering the try_table block whose type is bt. This emits
+// OperationKindTryTable followed by the landing pads of the catch clauses, which branch to the target labels
+// with the payload of the exception, and then the block body begins after them.
+func (c *Compiler) emitTryTable(bt *wasm.FunctionType, catches []wasm.TryTableCatch) {
+	frameID := c.nextFrameID()
+	originalStackLenWithoutParam := len(c.stack) - len(bt.Params)
+	handlerIndex := len(c.result.ExceptionHandlers)
+	handler := ExceptionHandler{
+		StackHeightInUint64: c.stackLenInUint64(originalStackLenWithoutParam),
+		Catches:             make([]ExceptionHandlerCatch, len(catches)),
+	}
+
+	bodyLabel := NewLabel(LabelKindHeader, frameID)
+	c.result.LabelCallers[bodyLabel]++
+	c.emit(NewOperationTryTable(uint32(handlerIndex), bt.ParamNumInUint64))
+	c.emit(NewOperationBr(bodyLabel))
+
+	stack := make([]UnsignedType, len(c.stack))
+	copy(stack, c.stack)
+	for i := range catches {
+		catch := &catches[i]
+		padLabel := NewLabel(LabelKindHeader, c.nextFrameID())
+		c.result.LabelCallers[padLabel]++
+		hc := &handler.Catches[i]
+		hc.Kind, hc.Tag, hc.Label = catch.Kind, catch.Tag, padLabel
+
+		// Emit the landing pad, which begins with the payload on top of the stack.
+		c.emit(NewOperationLabel(padLabel))
+		c.stack = c.stack[:originalStackLenWithoutParam]
+		if catch.Kind == wasm.TryTableCatchKindCatch || catch.Kind == wasm.TryTableCatchKindCatchRef {
+			hc.TagType = &c.types[c.tags[catch.Tag]]
+			for _, t := range hc.TagType.Params {
+				c.stackPush(wasmValueTypeToUnsignedType(t))
+			}
+		}
+		if catch.Kind == wasm.TryTableCatchKindCatchRef || catch.Kind == wasm.TryTableCatchKindCatchAllRef {
+			c.stackPush(UnsignedTypeI64)
+		}
+
+		// The labels of catch clauses are relative to the enclosing block of try_table.
+		targetFrame := c.controlFrames.get(int(catch.Label))
+		targetFrame.ensureContinuation()
+		dropOp := NewOperationDrop(c.getFrameDropRange(targetFrame, false))
+		targetID := targetFrame.asLabel()
+		c.result.LabelCallers[targetID]++
+		c.emit(dropOp)
+		c.emit(NewOperationBr(targetID))
+		c.stack = append(c.stack[:0], stack...)
+	}
+
+	c.emit(NewOperationLabel(bodyLabel))
+	handler.Begin = len(c.result.Operations)
+	c.result.ExceptionHandlers = append(c.result.ExceptionHandlers, handler)
+
+	c.controlFrames.push(controlFrame{
+		frameID:                      frameID,
+		originalStackLenWithoutParam: originalStackLenWithoutParam,
+		kind:                         controlFrameKindBlockWithoutContinuationLabel,
+		blockType:                    bt,
+		isTryTable:                   true,
+		exceptionHandlerIndex:        handlerIndex,
+	})
+}
+
 func (c *Compiler) nextFrameID() (id uint32) {
 	id = c.currentFrameID + 1
 	c.currentFrameID++
@@ -2870,6 +3656,9 @@ func (c *Compiler) applyToStack(opcode wasm.Opcode) (index uint32, err error) {
 		// and it DOES affect the signature of opcode.
 		wasm.OpcodeCall,
 		wasm.OpcodeCallIndirect,
+		wasm.OpcodeReturnCall,
+		wasm.OpcodeReturnCallIndirect,
+		wasm.OpcodeThrow,
 		wasm.OpcodeLocalGet,
 		wasm.OpcodeLocalSet,
 		wasm.OpcodeLocalTee,
@@ -2898,6 +3687,9 @@ func (c *Compiler) applyToStack(opcode wasm.Opcode) (index uint32, err error) {
 	if err != nil {
 		return 0, err
 	}
+	if c.hasMemory64 {
+		s = c.memory64Signature(opcode, s)
+	}
 
 	// Manipulate the stack according to the signature.
 	// Note that the following algorithm assumes that
@@ -2980,7 +3772,7 @@ func (c *Compiler) emitDefaultValue(t wasm.ValueType) {
 	case wasm.ValueTypeI32:
 		c.stackPush(UnsignedTypeI32)
 		c.emit(NewOperationConstI32(0))
-	case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+	case wasm.ValueTypeI64, wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref:
 		c.stackPush(UnsignedTypeI64)
 		c.emit(NewOperationConstI64(0))
 	case wasm.ValueTypeF32:
@@ -3059,10 +3851,44 @@ func (c *Compiler) readMemoryArg(tag string) (MemoryArg, error) {
 		return MemoryArg{}, fmt.Errorf("reading alignment for %s: %w", tag, err)
 	}
 	c.pc += num
-	offset, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+	var memoryIndex uint32
+	if alignment&wasm.MemArgMemoryIndexFlag != 0 && c.enabledFeatures.IsEnabled(api.CoreFeatureMultiMemory) {
+		alignment &^= wasm.MemArgMemoryIndexFlag
+		memoryIndex, num, err = leb128.LoadUint32(c.body[c.pc+1:])
+		if err != nil {
+			return MemoryArg{}, fmt.Errorf("reading memory index for %s: %w", tag, err)
+		}
+		c.pc += num
+	}
+	memory64 := c.isMemory64(memoryIndex)
+	var offset uint64
+	if memory64 {
+		offset, num, err = leb128.LoadUint64(c.body[c.pc+1:])
+	} else {
+		var offset32 uint32
+		offset32, num, err = leb128.LoadUint32(c.body[c.pc+1:])
+		offset = uint64(offset32)
+	}
 	if err != nil {
 		return MemoryArg{}, fmt.Errorf("reading offset for %s: %w", tag, err)
 	}
 	c.pc += num
-	return MemoryArg{Offset: offset, Alignment: alignment}, nil
+	return MemoryArg{Offset: offset, Alignment: alignment, MemoryIndex: memoryIndex, Memory64: memory64}, nil
+}
+
+// isMemory64 returns true if the memory at memoryIndex is 64-bit (api.CoreFeatureMemory64).
+func (c *Compiler) isMemory64(memoryIndex uint32) bool {
+	return c.hasMemory64 && c.memories[memoryIndex].IsMemory64
+}
+
+// readMemoryIndex reads the memory index immediate of instructions such as memory.size, which is always zero unless
+// api.CoreFeatureMultiMemory is enabled.
+func (c *Compiler) readMemoryIndex(tag string) (uint32, error) {
+	c.result.UsesMemory = true
+	memoryIndex, num, err := leb128.LoadUint32(c.body[c.pc+1:])
+	if err != nil {
+		return 0, fmt.Errorf("reading memory index for %s: %w", tag, err)
+	}
+	c.pc += num
+	return memoryIndex, nil
 }
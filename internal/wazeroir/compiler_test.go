@@ -157,7 +157,7 @@ func TestCompile(t *testing.T) {
 			expected: &CompilationResult{
 				Operations: []UnionOperation{ // begin with params: [$delta]
 					NewOperationPick(0, false),                         // [$delta, $delta]
-					NewOperationMemoryGrow(),                           // [$delta, $old_size]
+					NewOperationMemoryGrow(0, false),                   // [$delta, $old_size]
 					NewOperationDrop(InclusiveRange{Start: 1, End: 1}), // [$old_size]
 					NewOperationBr(NewLabel(LabelKindReturn, 0)),       // return!
 				},
@@ -267,7 +267,7 @@ func TestCompile_BulkMemoryOperations(t *testing.T) {
 	module := &wasm.Module{
 		TypeSection:     []wasm.FunctionType{v_v},
 		FunctionSection: []wasm.Index{0},
-		MemorySection:   &wasm.Memory{Min: 1},
+		MemorySection:   []wasm.Memory{{Min: 1}},
 		DataSection: []wasm.DataSegment{
 			{
 				OffsetExpression: wasm.ConstantExpression{
@@ -297,7 +297,7 @@ func TestCompile_BulkMemoryOperations(t *testing.T) {
 			NewOperationConstI32(16),                     // [16]
 			NewOperationConstI32(0),                      // [16, 0]
 			NewOperationConstI32(7),                      // [16, 0, 7]
-			NewOperationMemoryInit(1),                    // []
+			NewOperationMemoryInit(1, 0, false),          // []
 			NewOperationDataDrop(1),                      // []
 			NewOperationBr(NewLabel(LabelKindReturn, 0)), // return!
 		},
@@ -2641,7 +2641,7 @@ func TestCompile_Vec(t *testing.T) {
 			module := &wasm.Module{
 				TypeSection:     []wasm.FunctionType{v_v},
 				FunctionSection: []wasm.Index{0},
-				MemorySection:   &wasm.Memory{},
+				MemorySection:   []wasm.Memory{{}},
 				CodeSection:     []wasm.Code{{Body: tc.body}},
 			}
 			c, err := NewCompiler(api.CoreFeaturesV2, 0, module, false)
@@ -3071,3 +3071,87 @@ func Test_ensureTermination(t *testing.T) {
 		})
 	}
 }
+
+func Test_fuelMetering(t *testing.T) {
+	mod := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{v_v},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []wasm.Code{{
+			Body: []byte{
+				wasm.OpcodeLoop, 0x40, wasm.OpcodeI32Const, 1, wasm.OpcodeBrIf, 0, wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			},
+			LocalTypes: []wasm.ValueType{wasm.ValueTypeI32},
+		}},
+		FuelMetering: true,
+	}
+	c, err := NewCompiler(api.CoreFeaturesV2, 0, mod, false)
+	require.NoError(t, err)
+
+	actual, err := c.Next()
+	require.NoError(t, err)
+	require.Equal(t, `.entrypoint
+	BuiltinFunctionConsumeFuel
+	ConstI32 0x0
+	Br .L2
+.L2
+	BuiltinFunctionConsumeFuel
+	ConstI32 0x1
+	BrIf .L2, .L3
+.L3
+	Drop 0..0
+	Br .return
+`, Format(actual.Operations))
+}
+
+func Test_epochInterruption(t *testing.T) {
+	mod := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{v_v},
+		FunctionSection: []wasm.Index{0},
+		CodeSection: []wasm.Code{{
+			Body: []byte{
+				wasm.OpcodeLoop, 0x40, wasm.OpcodeI32Const, 1, wasm.OpcodeBrIf, 0, wasm.OpcodeEnd,
+				wasm.OpcodeEnd,
+			},
+		}},
+		FuelMetering:      true,
+		EpochInterruption: true,
+	}
+	c, err := NewCompiler(api.CoreFeaturesV2, 0, mod, false)
+	require.NoError(t, err)
+
+	actual, err := c.Next()
+	require.NoError(t, err)
+	require.Equal(t, `.entrypoint
+	BuiltinFunctionConsumeFuel
+	BuiltinFunctionCheckEpochDeadline
+	Br .L2
+.L2
+	BuiltinFunctionConsumeFuel
+	BuiltinFunctionCheckEpochDeadline
+	ConstI32 0x1
+	BrIf .L2, .L3
+.L3
+	Br .return
+`, Format(actual.Operations))
+}
+
+func Test_limitsCallStackDepth(t *testing.T) {
+	mod := &wasm.Module{
+		TypeSection:     []wasm.FunctionType{v_v},
+		FunctionSection: []wasm.Index{0},
+		CodeSection:     []wasm.Code{{Body: []byte{wasm.OpcodeEnd}}},
+	}
+	c, err := NewCompiler(api.CoreFeaturesV2, 0, mod, false)
+	require.NoError(t, err)
+	actual, err := c.Next()
+	require.NoError(t, err)
+	require.False(t, actual.LimitsCallStackDepth)
+
+	mod.MaxCallStackDepth = 100
+	c, err = NewCompiler(api.CoreFeaturesV2, 0, mod, false)
+	require.NoError(t, err)
+	actual, err = c.Next()
+	require.NoError(t, err)
+	require.True(t, actual.LimitsCallStackDepth)
+}
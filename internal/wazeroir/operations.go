@@ -413,8 +413,54 @@ func (o OperationKind) String() (ret string) {
 		ret = "V128Narrow"
 	case OperationKindV128ITruncSatFromF:
 		ret = "V128ITruncSatFromF"
+	case OperationKindAtomicMemoryWait:
+		ret = "AtomicMemoryWait"
+	case OperationKindAtomicMemoryNotify:
+		ret = "AtomicMemoryNotify"
+	case OperationKindAtomicFence:
+		ret = "AtomicFence"
+	case OperationKindAtomicLoad:
+		ret = "AtomicLoad"
+	case OperationKindAtomicLoad8:
+		ret = "AtomicLoad8"
+	case OperationKindAtomicLoad16:
+		ret = "AtomicLoad16"
+	case OperationKindAtomicStore:
+		ret = "AtomicStore"
+	case OperationKindAtomicStore8:
+		ret = "AtomicStore8"
+	case OperationKindAtomicStore16:
+		ret = "AtomicStore16"
+	case OperationKindAtomicRMW:
+		ret = "AtomicRMW"
+	case OperationKindAtomicRMW8:
+		ret = "AtomicRMW8"
+	case OperationKindAtomicRMW16:
+		ret = "AtomicRMW16"
+	case OperationKindAtomicRMWCmpxchg:
+		ret = "AtomicRMWCmpxchg"
+	case OperationKindAtomicRMW8Cmpxchg:
+		ret = "AtomicRMW8Cmpxchg"
+	case OperationKindAtomicRMW16Cmpxchg:
+		ret = "AtomicRMW16Cmpxchg"
+	case OperationKindTailCall:
+		ret = "TailCall"
+	case OperationKindTailCallIndirect:
+		ret = "TailCallIndirect"
+	case OperationKindThrow:
+		ret = "Throw"
+	case OperationKindThrowRef:
+		ret = "ThrowRef"
+	case OperationKindTryTable:
+		ret = "TryTable"
 	case OperationKindBuiltinFunctionCheckExitCode:
 		ret = "BuiltinFunctionCheckExitCode"
+	case OperationKindBuiltinFunctionConsumeFuel:
+		ret = "BuiltinFunctionConsumeFuel"
+	case OperationKindBuiltinFunctionCheckEpochDeadline:
+		ret = "BuiltinFunctionCheckEpochDeadline"
+	case OperationKindBuiltinFunctionNotifyInstruction:
+		ret = "BuiltinFunctionNotifyInstruction"
 	default:
 		panic(fmt.Errorf("unknown operation %d", o))
 	}
@@ -702,8 +748,57 @@ const (
 	// OperationKindV128ITruncSatFromF is the Kind for NewOperationV128ITruncSatFromF.
 	OperationKindV128ITruncSatFromF
 
+	// OperationKindAtomicMemoryWait is the Kind for NewOperationAtomicMemoryWait.
+	OperationKindAtomicMemoryWait
+	// OperationKindAtomicMemoryNotify is the Kind for NewOperationAtomicMemoryNotify.
+	OperationKindAtomicMemoryNotify
+	// OperationKindAtomicFence is the Kind for NewOperationAtomicFence.
+	OperationKindAtomicFence
+	// OperationKindAtomicLoad is the Kind for NewOperationAtomicLoad.
+	OperationKindAtomicLoad
+	// OperationKindAtomicLoad8 is the Kind for NewOperationAtomicLoad8.
+	OperationKindAtomicLoad8
+	// OperationKindAtomicLoad16 is the Kind for NewOperationAtomicLoad16.
+	OperationKindAtomicLoad16
+	// OperationKindAtomicStore is the Kind for NewOperationAtomicStore.
+	OperationKindAtomicStore
+	// OperationKindAtomicStore8 is the Kind for NewOperationAtomicStore8.
+	OperationKindAtomicStore8
+	// OperationKindAtomicStore16 is the Kind for NewOperationAtomicStore16.
+	OperationKindAtomicStore16
+	// OperationKindAtomicRMW is the Kind for NewOperationAtomicRMW.
+	OperationKindAtomicRMW
+	// OperationKindAtomicRMW8 is the Kind for NewOperationAtomicRMW8.
+	OperationKindAtomicRMW8
+	// OperationKindAtomicRMW16 is the Kind for NewOperationAtomicRMW16.
+	OperationKindAtomicRMW16
+	// OperationKindAtomicRMWCmpxchg is the Kind for NewOperationAtomicRMWCmpxchg.
+	OperationKindAtomicRMWCmpxchg
+	// OperationKindAtomicRMW8Cmpxchg is the Kind for NewOperationAtomicRMW8Cmpxchg.
+	OperationKindAtomicRMW8Cmpxchg
+	// OperationKindAtomicRMW16Cmpxchg is the Kind for NewOperationAtomicRMW16Cmpxchg.
+	OperationKindAtomicRMW16Cmpxchg
+
+	// OperationKindTailCall is the Kind for NewOperationTailCall.
+	OperationKindTailCall
+	// OperationKindTailCallIndirect is the Kind for NewOperationTailCallIndirect.
+	OperationKindTailCallIndirect
+
+	// OperationKindThrow is the Kind for NewOperationThrow.
+	OperationKindThrow
+	// OperationKindThrowRef is the Kind for NewOperationThrowRef.
+	OperationKindThrowRef
+	// OperationKindTryTable is the Kind for NewOperationTryTable.
+	OperationKindTryTable
+
 	// OperationKindBuiltinFunctionCheckExitCode is the Kind for NewOperationBuiltinFunctionCheckExitCode.
 	OperationKindBuiltinFunctionCheckExitCode
+	// OperationKindBuiltinFunctionConsumeFuel is the Kind for NewOperationBuiltinFunctionConsumeFuel.
+	OperationKindBuiltinFunctionConsumeFuel
+	// OperationKindBuiltinFunctionCheckEpochDeadline is the Kind for NewOperationBuiltinFunctionCheckEpochDeadline.
+	OperationKindBuiltinFunctionCheckEpochDeadline
+	// OperationKindBuiltinFunctionNotifyInstruction is the Kind for NewOperationBuiltinFunctionNotifyInstruction.
+	OperationKindBuiltinFunctionNotifyInstruction
 
 	// operationKindEnd is always placed at the bottom of this iota definition to be used in the test.
 	operationKindEnd
@@ -717,6 +812,32 @@ func NewOperationBuiltinFunctionCheckExitCode() UnionOperation {
 	return UnionOperation{Kind: OperationKindBuiltinFunctionCheckExitCode}
 }
 
+// NewOperationBuiltinFunctionConsumeFuel is a constructor for UnionOperation with Kind OperationKindBuiltinFunctionConsumeFuel.
+//
+// OperationBuiltinFunctionConsumeFuel corresponds to the instruction to consume one unit of the fuel of the module
+// instance, and to exit with sys.ExitCodeFuelExhausted if it runs out.
+func NewOperationBuiltinFunctionConsumeFuel() UnionOperation {
+	return UnionOperation{Kind: OperationKindBuiltinFunctionConsumeFuel}
+}
+
+// NewOperationBuiltinFunctionCheckEpochDeadline is a constructor for UnionOperation with Kind OperationKindBuiltinFunctionCheckEpochDeadline.
+//
+// OperationBuiltinFunctionCheckEpochDeadline corresponds to the instruction to exit with sys.ExitCodeEpochDeadlineExceeded
+// if the epoch of the store reached the deadline of the module instance.
+func NewOperationBuiltinFunctionCheckEpochDeadline() UnionOperation {
+	return UnionOperation{Kind: OperationKindBuiltinFunctionCheckEpochDeadline}
+}
+
+// NewOperationBuiltinFunctionNotifyInstruction is a constructor for UnionOperation with Kind
+// OperationKindBuiltinFunctionNotifyInstruction.
+//
+// OperationBuiltinFunctionNotifyInstruction corresponds to the instruction to notify the experimental.InstructionListener
+// of the function before the next instruction runs. This isn't emitted by the compiler, but inserted by the interpreter
+// before each instruction of the functions which have such a listener, so that others don't pay for it.
+func NewOperationBuiltinFunctionNotifyInstruction() UnionOperation {
+	return UnionOperation{Kind: OperationKindBuiltinFunctionNotifyInstruction}
+}
+
 // Label is the unique identifier for each block in a single function in wazeroir
 // where "block" consists of multiple operations, and must End with branching operations
 // (e.g. OperationKindBr or OperationKindBrIf).
@@ -828,7 +949,11 @@ func (o UnionOperation) String() string {
 		OperationKindTableSize,
 		OperationKindTableGrow,
 		OperationKindTableFill,
-		OperationKindBuiltinFunctionCheckExitCode:
+		OperationKindAtomicFence,
+		OperationKindBuiltinFunctionCheckExitCode,
+		OperationKindBuiltinFunctionConsumeFuel,
+		OperationKindBuiltinFunctionCheckEpochDeadline,
+		OperationKindBuiltinFunctionNotifyInstruction:
 		return o.Kind.String()
 
 	case OperationKindCall,
@@ -859,7 +984,18 @@ func (o UnionOperation) String() string {
 		}
 		return fmt.Sprintf("%s [%s] %s", o.Kind, strings.Join(targets, ","), defaultLabel)
 
-	case OperationKindCallIndirect:
+	case OperationKindTailCall,
+		OperationKindThrow:
+		return fmt.Sprintf("%s %d", o.Kind, o.U1)
+
+	case OperationKindThrowRef:
+		return o.Kind.String()
+
+	case OperationKindTryTable:
+		return fmt.Sprintf("%s handler=%d, params=%d", o.Kind, o.U1, o.U2)
+
+	case OperationKindCallIndirect,
+		OperationKindTailCallIndirect:
 		return fmt.Sprintf("%s: type=%d, table=%d", o.Kind, o.U1, o.U2)
 
 	case OperationKindDrop:
@@ -1010,6 +1146,26 @@ func (o UnionOperation) String() string {
 		OperationKindV128Narrow:
 		return o.Kind.String()
 
+	case OperationKindAtomicMemoryNotify:
+		return fmt.Sprintf("%s (align=%d, offset=%d)", o.Kind, o.U1, o.U2)
+
+	case OperationKindAtomicMemoryWait,
+		OperationKindAtomicLoad,
+		OperationKindAtomicLoad8,
+		OperationKindAtomicLoad16,
+		OperationKindAtomicStore,
+		OperationKindAtomicStore8,
+		OperationKindAtomicStore16,
+		OperationKindAtomicRMWCmpxchg,
+		OperationKindAtomicRMW8Cmpxchg,
+		OperationKindAtomicRMW16Cmpxchg:
+		return fmt.Sprintf("%s.%s (align=%d, offset=%d)", UnsignedType(o.B1), o.Kind, o.U1, o.U2)
+
+	case OperationKindAtomicRMW,
+		OperationKindAtomicRMW8,
+		OperationKindAtomicRMW16:
+		return fmt.Sprintf("%s.%s.%s (align=%d, offset=%d)", UnsignedType(o.B1), o.Kind, AtomicArithmeticOp(o.B2), o.U1, o.U2)
+
 	case OperationKindV128ITruncSatFromF:
 		if o.B3 {
 			return fmt.Sprintf("%s.%sS", o.Kind, shapeName(o.B1))
@@ -1101,6 +1257,57 @@ func NewOperationCallIndirect(typeIndex, tableIndex uint32) UnionOperation {
 	return UnionOperation{Kind: OperationKindCallIndirect, U1: uint64(typeIndex), U2: uint64(tableIndex)}
 }
 
+// NewOperationTailCall is a constructor for UnionOperation with OperationKindTailCall.
+//
+// This corresponds to wasm.OpcodeReturnCallName, and engines are expected to
+// replace the current function frame with the one of the function whose index
+// equals OperationTailCall.FunctionIndex, and enter into it. The arguments for
+// the callee are on the top of the stack, and all the other values in the
+// current frame are discarded. The callee returns directly to the caller of
+// the current function.
+func NewOperationTailCall(functionIndex uint32) UnionOperation {
+	return UnionOperation{Kind: OperationKindTailCall, U1: uint64(functionIndex)}
+}
+
+// NewOperationTailCallIndirect is a constructor for UnionOperation with OperationKindTailCallIndirect.
+//
+// This corresponds to wasm.OpcodeReturnCallIndirectName, and is the indirect
+// variant of NewOperationTailCall. The target function is determined and
+// checked in the same way as NewOperationCallIndirect.
+func NewOperationTailCallIndirect(typeIndex, tableIndex uint32) UnionOperation {
+	return UnionOperation{Kind: OperationKindTailCallIndirect, U1: uint64(typeIndex), U2: uint64(tableIndex)}
+}
+
+// NewOperationThrow is a constructor for UnionOperation with OperationKindThrow.
+//
+// This corresponds to wasm.OpcodeThrowName, and engines are expected to pop the
+// parameters of the tag whose index equals OperationThrow.TagIndex, and raise an
+// exception carrying them. The exception is caught by the innermost
+// ExceptionHandler enclosing the throwing operation in this or any caller frame.
+func NewOperationThrow(tagIndex uint32) UnionOperation {
+	return UnionOperation{Kind: OperationKindThrow, U1: uint64(tagIndex)}
+}
+
+// NewOperationThrowRef is a constructor for UnionOperation with OperationKindThrowRef.
+//
+// This corresponds to wasm.OpcodeThrowRefName, and engines are expected to pop
+// an exnref and re-raise the exception it refers to, or trap if it is null.
+func NewOperationThrowRef() UnionOperation {
+	return UnionOperation{Kind: OperationKindThrowRef}
+}
+
+// NewOperationTryTable is a constructor for UnionOperation with OperationKindTryTable.
+//
+// This corresponds to wasm.OpcodeTryTableName, and marks the beginning of the
+// CompilationResult.ExceptionHandlers entry at handlerIndex. paramNumInUint64 is
+// the number of values on the top of the stack which are the parameters of the
+// block, and they are discarded when landing on a handler. The operation itself
+// doesn't have any effect at runtime, and is followed by a branch over the landing
+// pads of the handler.
+func NewOperationTryTable(handlerIndex uint32, paramNumInUint64 int) UnionOperation {
+	return UnionOperation{Kind: OperationKindTryTable, U1: uint64(handlerIndex), U2: uint64(paramNumInUint64)}
+}
+
 // InclusiveRange is the range which spans across the value stack starting from the top to the bottom, and
 // both boundary are included in the range.
 type InclusiveRange struct {
@@ -1199,7 +1406,17 @@ type MemoryArg struct {
 
 	// Offset is the address offset added to the instruction's dynamic address operand, yielding a 33-bit effective
 	// address that is the zero-based index at which the memory is accessed. Default to zero.
-	Offset uint32
+	//
+	// This can exceed 32 bits only when Memory64 is true, in which case the effective address is 65-bit.
+	Offset uint64
+
+	// MemoryIndex is the index of the memory accessed by the instruction. This is non-zero only when
+	// api.CoreFeatureMultiMemory is enabled.
+	MemoryIndex uint32
+
+	// Memory64 is true when the memory accessed by the instruction is 64-bit, meaning the address operand is an i64.
+	// This can be true only when api.CoreFeatureMemory64 is enabled.
+	Memory64 bool
 }
 
 // NewOperationLoad is a constructor for UnionOperation with OperationKindLoad.
@@ -1209,7 +1426,7 @@ type MemoryArg struct {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise load the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func NewOperationLoad(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
-	return UnionOperation{Kind: OperationKindLoad, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return UnionOperation{Kind: OperationKindLoad, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationLoad8 is a constructor for UnionOperation with OperationKindLoad8.
@@ -1219,7 +1436,7 @@ func NewOperationLoad(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise load the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func NewOperationLoad8(signedInt SignedInt, arg MemoryArg) UnionOperation {
-	return UnionOperation{Kind: OperationKindLoad8, B1: byte(signedInt), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return UnionOperation{Kind: OperationKindLoad8, B1: byte(signedInt), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationLoad16 is a constructor for UnionOperation with OperationKindLoad16.
@@ -1229,7 +1446,7 @@ func NewOperationLoad8(signedInt SignedInt, arg MemoryArg) UnionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise load the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func NewOperationLoad16(signedInt SignedInt, arg MemoryArg) UnionOperation {
-	return UnionOperation{Kind: OperationKindLoad16, B1: byte(signedInt), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return UnionOperation{Kind: OperationKindLoad16, B1: byte(signedInt), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationLoad32 is a constructor for UnionOperation with OperationKindLoad32.
@@ -1243,7 +1460,7 @@ func NewOperationLoad32(signed bool, arg MemoryArg) UnionOperation {
 	if signed {
 		sigB = 1
 	}
-	return UnionOperation{Kind: OperationKindLoad32, B1: sigB, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return UnionOperation{Kind: OperationKindLoad32, B1: sigB, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationStore is a constructor for UnionOperation with OperationKindStore.
@@ -1253,7 +1470,7 @@ func NewOperationLoad32(signed bool, arg MemoryArg) UnionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise store the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func NewOperationStore(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
-	return UnionOperation{Kind: OperationKindStore, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return UnionOperation{Kind: OperationKindStore, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationStore8 is a constructor for UnionOperation with OperationKindStore8.
@@ -1263,7 +1480,7 @@ func NewOperationStore(unsignedType UnsignedType, arg MemoryArg) UnionOperation
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise store the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func NewOperationStore8(arg MemoryArg) UnionOperation {
-	return UnionOperation{Kind: OperationKindStore8, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return UnionOperation{Kind: OperationKindStore8, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationStore16 is a constructor for UnionOperation with OperationKindStore16.
@@ -1273,7 +1490,7 @@ func NewOperationStore8(arg MemoryArg) UnionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise store the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func NewOperationStore16(arg MemoryArg) UnionOperation {
-	return UnionOperation{Kind: OperationKindStore16, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return UnionOperation{Kind: OperationKindStore16, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationStore32 is a constructor for UnionOperation with OperationKindStore32.
@@ -1283,7 +1500,7 @@ func NewOperationStore16(arg MemoryArg) UnionOperation {
 // The engines are expected to check the boundary of memory length, and exit the execution if this exceeds the boundary,
 // otherwise store the corresponding value following the semantics of the corresponding WebAssembly instruction.
 func NewOperationStore32(arg MemoryArg) UnionOperation {
-	return UnionOperation{Kind: OperationKindStore32, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return UnionOperation{Kind: OperationKindStore32, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationMemorySize is a constructor for UnionOperation with OperationKindMemorySize.
@@ -1291,8 +1508,11 @@ func NewOperationStore32(arg MemoryArg) UnionOperation {
 // This corresponds to wasm.OpcodeMemorySize.
 //
 // The engines are expected to push the current page size of the memory onto the stack.
-func NewOperationMemorySize() UnionOperation {
-	return UnionOperation{Kind: OperationKindMemorySize}
+//
+// memoryIndex is the index of the memory, which is non-zero only when api.CoreFeatureMultiMemory is enabled.
+// memory64 is true when the memory is 64-bit (api.CoreFeatureMemory64), meaning the size is pushed as an i64.
+func NewOperationMemorySize(memoryIndex uint32, memory64 bool) UnionOperation {
+	return UnionOperation{Kind: OperationKindMemorySize, U1: uint64(memoryIndex), B3: memory64}
 }
 
 // NewOperationMemoryGrow is a constructor for UnionOperation with OperationKindMemoryGrow.
@@ -1302,8 +1522,11 @@ func NewOperationMemorySize() UnionOperation {
 // The engines are expected to pop one value from the top of the stack, then
 // execute wasm.MemoryInstance Grow with the value, and push the previous
 // page size of the memory onto the stack.
-func NewOperationMemoryGrow() UnionOperation {
-	return UnionOperation{Kind: OperationKindMemoryGrow}
+//
+// memoryIndex is the index of the memory, which is non-zero only when api.CoreFeatureMultiMemory is enabled.
+// memory64 is true when the memory is 64-bit (api.CoreFeatureMemory64), meaning the delta and the result are i64.
+func NewOperationMemoryGrow(memoryIndex uint32, memory64 bool) UnionOperation {
+	return UnionOperation{Kind: OperationKindMemoryGrow, U1: uint64(memoryIndex), B3: memory64}
 }
 
 // NewOperationConstI32 is a constructor for UnionOperation with OperationConstI32.
@@ -1786,8 +2009,10 @@ func NewOperationSignExtend64From32() UnionOperation {
 //
 // dataIndex is the index of the data instance in ModuleInstance.DataInstances
 // by which this operation instantiates a part of the memory.
-func NewOperationMemoryInit(dataIndex uint32) UnionOperation {
-	return UnionOperation{Kind: OperationKindMemoryInit, U1: uint64(dataIndex)}
+// memoryIndex is the index of the memory, which is non-zero only when api.CoreFeatureMultiMemory is enabled.
+// memory64 is true when the memory is 64-bit (api.CoreFeatureMemory64), meaning the destination address is an i64.
+func NewOperationMemoryInit(dataIndex, memoryIndex uint32, memory64 bool) UnionOperation {
+	return UnionOperation{Kind: OperationKindMemoryInit, U1: uint64(dataIndex), U2: uint64(memoryIndex), B3: memory64}
 }
 
 // NewOperationDataDrop implements Operation.
@@ -1803,13 +2028,20 @@ func NewOperationDataDrop(dataIndex uint32) UnionOperation {
 // NewOperationMemoryCopy is a consuctor for UnionOperation with OperationKindMemoryCopy.
 //
 // This corresponds to wasm.OpcodeMemoryCopyName.
-func NewOperationMemoryCopy() UnionOperation {
-	return UnionOperation{Kind: OperationKindMemoryCopy}
+//
+// dstMemoryIndex and srcMemoryIndex are the indexes of the destination and source memories, which can be non-zero
+// and different from each other only when api.CoreFeatureMultiMemory is enabled.
+// memory64 is true when either memory is 64-bit (api.CoreFeatureMemory64), meaning some operands are i64.
+func NewOperationMemoryCopy(dstMemoryIndex, srcMemoryIndex uint32, memory64 bool) UnionOperation {
+	return UnionOperation{Kind: OperationKindMemoryCopy, U1: uint64(dstMemoryIndex), U2: uint64(srcMemoryIndex), B3: memory64}
 }
 
 // NewOperationMemoryFill is a consuctor for UnionOperation with OperationKindMemoryFill.
-func NewOperationMemoryFill() UnionOperation {
-	return UnionOperation{Kind: OperationKindMemoryFill}
+//
+// memoryIndex is the index of the memory, which is non-zero only when api.CoreFeatureMultiMemory is enabled.
+// memory64 is true when the memory is 64-bit (api.CoreFeatureMemory64), meaning the address and size are i64.
+func NewOperationMemoryFill(memoryIndex uint32, memory64 bool) UnionOperation {
+	return UnionOperation{Kind: OperationKindMemoryFill, U1: uint64(memoryIndex), B3: memory64}
 }
 
 // NewOperationTableInit is a constructor for UnionOperation with OperationKindTableInit.
@@ -1980,7 +2212,7 @@ const (
 //	wasm.OpcodeVecV128Load32SplatName wasm.OpcodeVecV128Load64SplatName wasm.OpcodeVecV128Load32zeroName
 //	wasm.OpcodeVecV128Load64zeroName
 func NewOperationV128Load(loadType V128LoadType, arg MemoryArg) UnionOperation {
-	return UnionOperation{Kind: OperationKindV128Load, B1: loadType, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return UnionOperation{Kind: OperationKindV128Load, B1: loadType, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationV128LoadLane is a constructor for UnionOperation with OperationKindV128LoadLane.
@@ -1992,7 +2224,7 @@ func NewOperationV128Load(loadType V128LoadType, arg MemoryArg) UnionOperation {
 // laneIndex is >=0 && <(128/LaneSize).
 // laneSize is either 8, 16, 32, or 64.
 func NewOperationV128LoadLane(laneIndex, laneSize byte, arg MemoryArg) UnionOperation {
-	return UnionOperation{Kind: OperationKindV128LoadLane, B1: laneSize, B2: laneIndex, U1: uint64(arg.Alignment), U2: uint64(arg.Offset)}
+	return UnionOperation{Kind: OperationKindV128LoadLane, B1: laneSize, B2: laneIndex, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
 }
 
 // NewOperationV128Store is a constructor for UnionOperation with OperationKindV128Store.
@@ -2005,6 +2237,8 @@ func NewOperationV128Store(arg MemoryArg) UnionOperation {
 		Kind: OperationKindV128Store,
 		U1:   uint64(arg.Alignment),
 		U2:   uint64(arg.Offset),
+		U3:   uint64(arg.MemoryIndex),
+		B3:   arg.Memory64,
 	}
 }
 
@@ -2023,6 +2257,8 @@ func NewOperationV128StoreLane(laneIndex byte, laneSize byte, arg MemoryArg) Uni
 		B2:   laneIndex,
 		U1:   uint64(arg.Alignment),
 		U2:   uint64(arg.Offset),
+		U3:   uint64(arg.MemoryIndex),
+		B3:   arg.Memory64,
 	}
 }
 
@@ -2565,3 +2801,189 @@ func NewOperationV128Narrow(originShape Shape, signed bool) UnionOperation {
 func NewOperationV128ITruncSatFromF(originShape Shape, signed bool) UnionOperation {
 	return UnionOperation{Kind: OperationKindV128ITruncSatFromF, B1: originShape, B3: signed}
 }
+
+// AtomicArithmeticOp is the type for the operation kind of atomic arithmetic operations.
+type AtomicArithmeticOp byte
+
+const (
+	// AtomicArithmeticOpAdd is the kind for an add operation.
+	AtomicArithmeticOpAdd AtomicArithmeticOp = iota
+	// AtomicArithmeticOpSub is the kind for a sub operation.
+	AtomicArithmeticOpSub
+	// AtomicArithmeticOpAnd is the kind for a bitwise and operation.
+	AtomicArithmeticOpAnd
+	// AtomicArithmeticOpOr is the kind for a bitwise or operation.
+	AtomicArithmeticOpOr
+	// AtomicArithmeticOpXor is the kind for a bitwise xor operation.
+	AtomicArithmeticOpXor
+	// AtomicArithmeticOpNop is the kind for a nop operation, which stores the operand as-is (xchg).
+	AtomicArithmeticOpNop
+)
+
+// String implements fmt.Stringer.
+func (a AtomicArithmeticOp) String() (ret string) {
+	switch a {
+	case AtomicArithmeticOpAdd:
+		ret = "add"
+	case AtomicArithmeticOpSub:
+		ret = "sub"
+	case AtomicArithmeticOpAnd:
+		ret = "and"
+	case AtomicArithmeticOpOr:
+		ret = "or"
+	case AtomicArithmeticOpXor:
+		ret = "xor"
+	case AtomicArithmeticOpNop:
+		ret = "xchg"
+	}
+	return
+}
+
+// NewOperationAtomicMemoryWait is a constructor for UnionOperation with OperationKindAtomicMemoryWait.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicMemoryWait32Name wasm.OpcodeAtomicMemoryWait64Name
+//
+// The engines are expected to check the boundary and alignment of the address, and trap if the memory is not shared.
+// Otherwise, the current thread is suspended until it is notified or the timeout (in nanoseconds, negative for none)
+// expires, and the result 0 (ok), 1 (not-equal) or 2 (timed-out) is pushed.
+func NewOperationAtomicMemoryWait(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicMemoryWait, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicMemoryNotify is a constructor for UnionOperation with OperationKindAtomicMemoryNotify.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicMemoryNotifyName
+//
+// The engines are expected to check the boundary and alignment of the address, then wake up at most the given
+// count of waiters on the address and push the number of woken waiters.
+func NewOperationAtomicMemoryNotify(arg MemoryArg) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicMemoryNotify, U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicFence is a constructor for UnionOperation with OperationKindAtomicFence.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicFenceName
+func NewOperationAtomicFence() UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicFence}
+}
+
+// NewOperationAtomicLoad is a constructor for UnionOperation with OperationKindAtomicLoad.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicI32LoadName wasm.OpcodeAtomicI64LoadName
+func NewOperationAtomicLoad(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicLoad, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicLoad8 is a constructor for UnionOperation with OperationKindAtomicLoad8.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicI32Load8UName wasm.OpcodeAtomicI64Load8UName
+func NewOperationAtomicLoad8(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicLoad8, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicLoad16 is a constructor for UnionOperation with OperationKindAtomicLoad16.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicI32Load16UName wasm.OpcodeAtomicI64Load16UName
+func NewOperationAtomicLoad16(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicLoad16, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicStore is a constructor for UnionOperation with OperationKindAtomicStore.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicI32StoreName wasm.OpcodeAtomicI64StoreName
+func NewOperationAtomicStore(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicStore, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicStore8 is a constructor for UnionOperation with OperationKindAtomicStore8.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicI32Store8Name wasm.OpcodeAtomicI64Store8Name
+func NewOperationAtomicStore8(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicStore8, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicStore16 is a constructor for UnionOperation with OperationKindAtomicStore16.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicI32Store16Name wasm.OpcodeAtomicI64Store16Name
+func NewOperationAtomicStore16(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicStore16, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicRMW is a constructor for UnionOperation with OperationKindAtomicRMW.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicI32RmwAddName wasm.OpcodeAtomicI64RmwAddName
+//	wasm.OpcodeAtomicI32RmwSubName wasm.OpcodeAtomicI64RmwSubName
+//	wasm.OpcodeAtomicI32RmwAndName wasm.OpcodeAtomicI64RmwAndName
+//	wasm.OpcodeAtomicI32RmwOrName wasm.OpcodeAtomicI64RmwOrName
+//	wasm.OpcodeAtomicI32RmwXorName wasm.OpcodeAtomicI64RmwXorName
+//	wasm.OpcodeAtomicI32RmwXchgName wasm.OpcodeAtomicI64RmwXchgName
+//
+// The engines are expected to apply the op atomically and push the value previously stored at the address.
+func NewOperationAtomicRMW(unsignedType UnsignedType, arg MemoryArg, op AtomicArithmeticOp) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicRMW, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicRMW8 is a constructor for UnionOperation with OperationKindAtomicRMW8.
+//
+// This corresponds to the 8-bit variants of the read-modify-write instructions, e.g.
+//
+//	wasm.OpcodeAtomicI32Rmw8AddUName wasm.OpcodeAtomicI64Rmw8AddUName
+func NewOperationAtomicRMW8(unsignedType UnsignedType, arg MemoryArg, op AtomicArithmeticOp) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicRMW8, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicRMW16 is a constructor for UnionOperation with OperationKindAtomicRMW16.
+//
+// This corresponds to the 16-bit variants of the read-modify-write instructions, e.g.
+//
+//	wasm.OpcodeAtomicI32Rmw16AddUName wasm.OpcodeAtomicI64Rmw16AddUName
+func NewOperationAtomicRMW16(unsignedType UnsignedType, arg MemoryArg, op AtomicArithmeticOp) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicRMW16, B1: byte(unsignedType), B2: byte(op), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicRMWCmpxchg is a constructor for UnionOperation with OperationKindAtomicRMWCmpxchg.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicI32RmwCmpxchgName wasm.OpcodeAtomicI64RmwCmpxchgName
+func NewOperationAtomicRMWCmpxchg(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicRMWCmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicRMW8Cmpxchg is a constructor for UnionOperation with OperationKindAtomicRMW8Cmpxchg.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicI32Rmw8CmpxchgUName wasm.OpcodeAtomicI64Rmw8CmpxchgUName
+func NewOperationAtomicRMW8Cmpxchg(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicRMW8Cmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
+
+// NewOperationAtomicRMW16Cmpxchg is a constructor for UnionOperation with OperationKindAtomicRMW16Cmpxchg.
+//
+// This corresponds to
+//
+//	wasm.OpcodeAtomicI32Rmw16CmpxchgUName wasm.OpcodeAtomicI64Rmw16CmpxchgUName
+func NewOperationAtomicRMW16Cmpxchg(unsignedType UnsignedType, arg MemoryArg) UnionOperation {
+	return UnionOperation{Kind: OperationKindAtomicRMW16Cmpxchg, B1: byte(unsignedType), U1: uint64(arg.Alignment), U2: uint64(arg.Offset), U3: uint64(arg.MemoryIndex), B3: arg.Memory64}
+}
@@ -3,6 +3,8 @@ package wazeroir
 import (
 	"fmt"
 
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/leb128"
 	"github.com/AR1011/wazero/internal/wasm"
 )
 
@@ -162,6 +164,26 @@ var (
 	signature_I32I64I32_None = &signature{
 		in: []UnsignedType{UnsignedTypeI32, UnsignedTypeI64, UnsignedTypeI32},
 	}
+	signature_I32I64_I64 = &signature{
+		in:  []UnsignedType{UnsignedTypeI32, UnsignedTypeI64},
+		out: []UnsignedType{UnsignedTypeI64},
+	}
+	signature_I32I32I32_I32 = &signature{
+		in:  []UnsignedType{UnsignedTypeI32, UnsignedTypeI32, UnsignedTypeI32},
+		out: []UnsignedType{UnsignedTypeI32},
+	}
+	signature_I32I64I64_I64 = &signature{
+		in:  []UnsignedType{UnsignedTypeI32, UnsignedTypeI64, UnsignedTypeI64},
+		out: []UnsignedType{UnsignedTypeI64},
+	}
+	signature_I32I32I64_I32 = &signature{
+		in:  []UnsignedType{UnsignedTypeI32, UnsignedTypeI32, UnsignedTypeI64},
+		out: []UnsignedType{UnsignedTypeI32},
+	}
+	signature_I32I64I64_I32 = &signature{
+		in:  []UnsignedType{UnsignedTypeI32, UnsignedTypeI64, UnsignedTypeI64},
+		out: []UnsignedType{UnsignedTypeI32},
+	}
 	signature_UnknownUnknownI32_Unknown = &signature{
 		in:  []UnsignedType{UnsignedTypeUnknown, UnsignedTypeUnknown, UnsignedTypeI32},
 		out: []UnsignedType{UnsignedTypeUnknown},
@@ -256,6 +278,16 @@ func (c *Compiler) wasmOpcodeSignature(op wasm.Opcode, index uint32) (*signature
 		return c.funcTypeToSigs.get(c.funcs[index], false /* direct */), nil
 	case wasm.OpcodeCallIndirect:
 		return c.funcTypeToSigs.get(index, true /* call_indirect */), nil
+	case wasm.OpcodeReturnCall:
+		return c.funcTypeToSigs.get(c.funcs[index], false /* direct */), nil
+	case wasm.OpcodeReturnCallIndirect:
+		return c.funcTypeToSigs.get(index, true /* call_indirect */), nil
+	case wasm.OpcodeTryTable:
+		return signature_None_None, nil
+	case wasm.OpcodeThrow:
+		return c.funcTypeToSigs.get(c.tags[index], false /* direct */), nil
+	case wasm.OpcodeThrowRef:
+		return signature_I64_None, nil
 	case wasm.OpcodeDrop:
 		return signature_Unknown_None, nil
 	case wasm.OpcodeSelect, wasm.OpcodeTypedSelect:
@@ -586,11 +618,139 @@ func (c *Compiler) wasmOpcodeSignature(op wasm.Opcode, index uint32) (*signature
 		default:
 			return nil, fmt.Errorf("unsupported vector instruction in wazeroir: %s", wasm.VectorInstructionName(vecOp))
 		}
+	case wasm.OpcodeAtomicPrefix:
+		switch atomicOp := c.body[c.pc+1]; atomicOp {
+		case wasm.OpcodeAtomicMemoryNotify:
+			return signature_I32I32_I32, nil
+		case wasm.OpcodeAtomicMemoryWait32:
+			return signature_I32I32I64_I32, nil
+		case wasm.OpcodeAtomicMemoryWait64:
+			return signature_I32I64I64_I32, nil
+		case wasm.OpcodeAtomicFence:
+			return signature_None_None, nil
+		case wasm.OpcodeAtomicI32Load, wasm.OpcodeAtomicI32Load8U, wasm.OpcodeAtomicI32Load16U:
+			return signature_I32_I32, nil
+		case wasm.OpcodeAtomicI64Load, wasm.OpcodeAtomicI64Load8U, wasm.OpcodeAtomicI64Load16U, wasm.OpcodeAtomicI64Load32U:
+			return signature_I32_I64, nil
+		case wasm.OpcodeAtomicI32Store, wasm.OpcodeAtomicI32Store8, wasm.OpcodeAtomicI32Store16:
+			return signature_I32I32_None, nil
+		case wasm.OpcodeAtomicI64Store, wasm.OpcodeAtomicI64Store8, wasm.OpcodeAtomicI64Store16, wasm.OpcodeAtomicI64Store32:
+			return signature_I32I64_None, nil
+		case wasm.OpcodeAtomicI32RmwAdd, wasm.OpcodeAtomicI32RmwSub, wasm.OpcodeAtomicI32RmwAnd, wasm.OpcodeAtomicI32RmwOr, wasm.OpcodeAtomicI32RmwXor, wasm.OpcodeAtomicI32RmwXchg,
+			wasm.OpcodeAtomicI32Rmw8AddU, wasm.OpcodeAtomicI32Rmw8SubU, wasm.OpcodeAtomicI32Rmw8AndU, wasm.OpcodeAtomicI32Rmw8OrU, wasm.OpcodeAtomicI32Rmw8XorU, wasm.OpcodeAtomicI32Rmw8XchgU,
+			wasm.OpcodeAtomicI32Rmw16AddU, wasm.OpcodeAtomicI32Rmw16SubU, wasm.OpcodeAtomicI32Rmw16AndU, wasm.OpcodeAtomicI32Rmw16OrU, wasm.OpcodeAtomicI32Rmw16XorU, wasm.OpcodeAtomicI32Rmw16XchgU:
+			return signature_I32I32_I32, nil
+		case wasm.OpcodeAtomicI64RmwAdd, wasm.OpcodeAtomicI64RmwSub, wasm.OpcodeAtomicI64RmwAnd, wasm.OpcodeAtomicI64RmwOr, wasm.OpcodeAtomicI64RmwXor, wasm.OpcodeAtomicI64RmwXchg,
+			wasm.OpcodeAtomicI64Rmw8AddU, wasm.OpcodeAtomicI64Rmw8SubU, wasm.OpcodeAtomicI64Rmw8AndU, wasm.OpcodeAtomicI64Rmw8OrU, wasm.OpcodeAtomicI64Rmw8XorU, wasm.OpcodeAtomicI64Rmw8XchgU,
+			wasm.OpcodeAtomicI64Rmw16AddU, wasm.OpcodeAtomicI64Rmw16SubU, wasm.OpcodeAtomicI64Rmw16AndU, wasm.OpcodeAtomicI64Rmw16OrU, wasm.OpcodeAtomicI64Rmw16XorU, wasm.OpcodeAtomicI64Rmw16XchgU,
+			wasm.OpcodeAtomicI64Rmw32AddU, wasm.OpcodeAtomicI64Rmw32SubU, wasm.OpcodeAtomicI64Rmw32AndU, wasm.OpcodeAtomicI64Rmw32OrU, wasm.OpcodeAtomicI64Rmw32XorU, wasm.OpcodeAtomicI64Rmw32XchgU:
+			return signature_I32I64_I64, nil
+		case wasm.OpcodeAtomicI32RmwCmpxchg, wasm.OpcodeAtomicI32Rmw8CmpxchgU, wasm.OpcodeAtomicI32Rmw16CmpxchgU:
+			return signature_I32I32I32_I32, nil
+		case wasm.OpcodeAtomicI64RmwCmpxchg, wasm.OpcodeAtomicI64Rmw8CmpxchgU, wasm.OpcodeAtomicI64Rmw16CmpxchgU, wasm.OpcodeAtomicI64Rmw32CmpxchgU:
+			return signature_I32I64I64_I64, nil
+		default:
+			return nil, fmt.Errorf("unsupported atomic instruction in wazeroir: %s", wasm.AtomicInstructionName(atomicOp))
+		}
 	default:
 		return nil, fmt.Errorf("unsupported instruction in wazeroir: 0x%x", op)
 	}
 }
 
+// memory64Signature returns the signature of the memory instruction at c.pc adjusted for 64-bit memories
+// (api.CoreFeatureMemory64), where addresses and sizes are i64 instead of i32. Otherwise, s is returned as is.
+//
+// Note: this must be called before the immediates of the instruction are read, i.e. with c.pc at the opcode.
+func (c *Compiler) memory64Signature(op wasm.Opcode, s *signature) *signature {
+	// addresses are the indexes of the address operands in s.in, and results are the indexes of the address results in
+	// s.out, which are i64 when the accessed memory is 64-bit.
+	var addresses, results []int
+	switch {
+	case wasm.OpcodeI32Load <= op && op <= wasm.OpcodeI64Store32:
+		if c.isMemory64(c.peekMemArgMemoryIndex(c.pc + 1)) {
+			addresses = []int{0}
+		}
+	case op == wasm.OpcodeMemorySize:
+		if c.isMemory64(c.peekUint32(c.pc + 1)) {
+			results = []int{0}
+		}
+	case op == wasm.OpcodeMemoryGrow:
+		if c.isMemory64(c.peekUint32(c.pc + 1)) {
+			addresses, results = []int{0}, []int{0}
+		}
+	case op == wasm.OpcodeMiscPrefix:
+		switch c.body[c.pc+1] {
+		case wasm.OpcodeMiscMemoryInit:
+			_, num, _ := leb128.LoadUint32(c.body[c.pc+2:]) // data index.
+			if c.isMemory64(c.peekUint32(c.pc + 2 + num)) {
+				addresses = []int{0}
+			}
+		case wasm.OpcodeMiscMemoryCopy:
+			_, num, _ := leb128.LoadUint32(c.body[c.pc+2:])
+			dst, src := c.isMemory64(c.peekUint32(c.pc+2)), c.isMemory64(c.peekUint32(c.pc+2+num))
+			if dst {
+				addresses = append(addresses, 0)
+			}
+			if src {
+				addresses = append(addresses, 1)
+			}
+			// The size is only 64-bit when both memories are.
+			if dst && src {
+				addresses = append(addresses, 2)
+			}
+		case wasm.OpcodeMiscMemoryFill:
+			if c.isMemory64(c.peekUint32(c.pc + 2)) {
+				addresses = []int{0, 2}
+			}
+		}
+	case op == wasm.OpcodeVecPrefix:
+		switch vecOp := c.body[c.pc+1]; vecOp {
+		case wasm.OpcodeVecV128Load, wasm.OpcodeVecV128Load8x8s, wasm.OpcodeVecV128Load8x8u,
+			wasm.OpcodeVecV128Load16x4s, wasm.OpcodeVecV128Load16x4u, wasm.OpcodeVecV128Load32x2s,
+			wasm.OpcodeVecV128Load32x2u, wasm.OpcodeVecV128Load8Splat, wasm.OpcodeVecV128Load16Splat,
+			wasm.OpcodeVecV128Load32Splat, wasm.OpcodeVecV128Load64Splat, wasm.OpcodeVecV128Load32zero,
+			wasm.OpcodeVecV128Load64zero, wasm.OpcodeVecV128Load8Lane, wasm.OpcodeVecV128Load16Lane,
+			wasm.OpcodeVecV128Load32Lane, wasm.OpcodeVecV128Load64Lane, wasm.OpcodeVecV128Store,
+			wasm.OpcodeVecV128Store8Lane, wasm.OpcodeVecV128Store16Lane, wasm.OpcodeVecV128Store32Lane,
+			wasm.OpcodeVecV128Store64Lane:
+			if c.isMemory64(c.peekMemArgMemoryIndex(c.pc + 2)) {
+				addresses = []int{0}
+			}
+		}
+	case op == wasm.OpcodeAtomicPrefix:
+		if c.body[c.pc+1] != wasm.OpcodeAtomicFence && c.isMemory64(c.peekMemArgMemoryIndex(c.pc+2)) {
+			addresses = []int{0}
+		}
+	}
+
+	if addresses == nil && results == nil {
+		return s
+	}
+	ret := &signature{in: append([]UnsignedType(nil), s.in...), out: append([]UnsignedType(nil), s.out...)}
+	for _, i := range addresses {
+		ret.in[i] = UnsignedTypeI64
+	}
+	for _, i := range results {
+		ret.out[i] = UnsignedTypeI64
+	}
+	return ret
+}
+
+// peekUint32 returns the u32 immediate at pc without advancing c.pc.
+func (c *Compiler) peekUint32(pc uint64) uint32 {
+	v, _, _ := leb128.LoadUint32(c.body[pc:])
+	return v
+}
+
+// peekMemArgMemoryIndex returns the memory index of the memarg immediate at pc without advancing c.pc.
+func (c *Compiler) peekMemArgMemoryIndex(pc uint64) uint32 {
+	alignment, num, _ := leb128.LoadUint32(c.body[pc:])
+	if alignment&wasm.MemArgMemoryIndexFlag != 0 && c.enabledFeatures.IsEnabled(api.CoreFeatureMultiMemory) {
+		return c.peekUint32(pc + num)
+	}
+	return 0
+}
+
 // funcTypeToIRSignatures is the central cache for a module to get the *signature
 // for function calls.
 type funcTypeToIRSignatures struct {
@@ -646,7 +806,7 @@ func wasmValueTypeToUnsignedType(vt wasm.ValueType) UnsignedType {
 		return UnsignedTypeI32
 	case wasm.ValueTypeI64,
 		// From wazeroir layer, ref type values are opaque 64-bit pointers.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref:
 		return UnsignedTypeI64
 	case wasm.ValueTypeF32:
 		return UnsignedTypeF32
@@ -664,7 +824,7 @@ func wasmValueTypeToUnsignedOutSignature(vt wasm.ValueType) *signature {
 		return signature_None_I32
 	case wasm.ValueTypeI64,
 		// From wazeroir layer, ref type values are opaque 64-bit pointers.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref:
 		return signature_None_I64
 	case wasm.ValueTypeF32:
 		return signature_None_F32
@@ -682,7 +842,7 @@ func wasmValueTypeToUnsignedInSignature(vt wasm.ValueType) *signature {
 		return signature_I32_None
 	case wasm.ValueTypeI64,
 		// From wazeroir layer, ref type values are opaque 64-bit pointers.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref:
 		return signature_I64_None
 	case wasm.ValueTypeF32:
 		return signature_F32_None
@@ -700,7 +860,7 @@ func wasmValueTypeToUnsignedInOutSignature(vt wasm.ValueType) *signature {
 		return signature_I32_I32
 	case wasm.ValueTypeI64,
 		// From wazeroir layer, ref type values are opaque 64-bit pointers.
-		wasm.ValueTypeExternref, wasm.ValueTypeFuncref:
+		wasm.ValueTypeExternref, wasm.ValueTypeFuncref, wasm.ValueTypeExnref:
 		return signature_I64_I64
 	case wasm.ValueTypeF32:
 		return signature_F32_F32
@@ -2,7 +2,9 @@ package wazero
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"sync/atomic"
 
 	"github.com/AR1011/wazero/api"
@@ -12,6 +14,7 @@ import (
 	internalsys "github.com/AR1011/wazero/internal/sys"
 	"github.com/AR1011/wazero/internal/wasm"
 	binaryformat "github.com/AR1011/wazero/internal/wasm/binary"
+	"github.com/AR1011/wazero/internal/wat"
 	"github.com/AR1011/wazero/sys"
 )
 
@@ -99,6 +102,48 @@ type Runtime interface {
 	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#name-section%E2%91%A0
 	CompileModule(ctx context.Context, binary []byte) (CompiledModule, error)
 
+	// CompileModuleText is like CompileModule, except the source is in the WebAssembly Text Format (%.wat). This
+	// avoids keeping a binary copy of modules written by hand, such as in tests.
+	//
+	// Here's an example:
+	//
+	//	compiled, _ := r.CompileModuleText(ctx, []byte(`(module
+	//		(func (export "add") (param i32 i32) (result i32)
+	//			(i32.add (local.get 0) (local.get 1)))
+	//	)`))
+	//
+	// # Notes
+	//
+	//   - Instructions may be folded or plain, and identifiers such as $add are retained in the name section.
+	//   - Script files (%.wast) are also accepted, in which case their first module is compiled.
+	//   - Syntax errors include the line and column in the source, e.g. "3:5: unknown instruction i32.frob".
+	//
+	// See https://www.w3.org/TR/2019/REC-wasm-core-1-20191205/#text-format%E2%91%A0
+	CompileModuleText(ctx context.Context, text []byte) (CompiledModule, error)
+
+	// DeserializeModule reads the module written by CompiledModule.Serialize, so that it can be instantiated without
+	// compiling it again. This is useful to compile modules ahead of time, e.g. in CI, and ship the native code to
+	// the hosts which instantiate them.
+	//
+	// Here's an example:
+	//	f, _ := os.Open("app.cwasm")
+	//	defer f.Close()
+	//
+	//	compiled, _ := r.DeserializeModule(ctx, f)
+	//	mod, _ := r.InstantiateModule(ctx, compiled, wazero.NewModuleConfig())
+	//
+	// # Errors
+	//
+	// The source binary embedded in the serialized module is decoded and validated as usual, but the native code is
+	// trusted as is. This returns an error if the serialized module was written by:
+	//   - a different version of wazero, or one for a different runtime.GOOS or runtime.GOARCH.
+	//   - a host with CPU features which this host lacks.
+	//   - a Runtime with a different engine or configuration affecting the native code, such as
+	//     RuntimeConfig.WithCloseOnContextDone or experimental.FunctionListenerFactory.
+	//
+	// Note: This is only supported by the compilers, e.g. NewRuntimeConfigCompiler.
+	DeserializeModule(ctx context.Context, serialized io.Reader) (CompiledModule, error)
+
 	// InstantiateModule instantiates the module or errs for reasons including
 	// exit or validation.
 	//
@@ -136,6 +181,20 @@ type Runtime interface {
 	// Module returns an instantiated module in this runtime or nil if there aren't any.
 	Module(moduleName string) api.Module
 
+	// IncrementEpoch advances the epoch of this runtime by one, which traps the function calls of the modules
+	// whose deadline set by api.Module SetEpochDeadline has been reached. This is typically called periodically,
+	// e.g. from a time.Ticker, and is safe to call concurrently with the function calls.
+	//
+	// This has no effect unless RuntimeConfig.WithEpochInterruption is enabled.
+	IncrementEpoch()
+
+	// Externrefs returns the table which maps the externref values of this runtime to Go values. Host functions with
+	// interface{} parameters or results use it implicitly, while others can use it to convert explicitly, e.g. when
+	// writing an api.Table of api.ValueTypeExternref.
+	//
+	// See api.ExternrefTable
+	Externrefs() api.ExternrefTable
+
 	// Closer closes all compiled code by delegating to CloseWithExitCode with an exit code of zero.
 	api.Closer
 }
@@ -168,6 +227,11 @@ func NewRuntimeWithConfig(ctx context.Context, rConfig RuntimeConfig) Runtime {
 		dwarfDisabled:         config.dwarfDisabled,
 		storeCustomSections:   config.storeCustomSections,
 		ensureTermination:     config.ensureTermination,
+		fuelMetering:          config.fuelMetering,
+		epochInterruption:     config.epochInterruption,
+		maxCallStackDepth:     config.maxCallStackDepth,
+		coreDump:              config.coreDump,
+		serialization:         config.serialization,
 	}
 }
 
@@ -190,6 +254,11 @@ type runtime struct {
 	closed atomic.Uint64
 
 	ensureTermination bool
+	fuelMetering      bool
+	epochInterruption bool
+	maxCallStackDepth uint32
+	coreDump          bool
+	serialization     bool
 }
 
 // Module implements Runtime.Module.
@@ -206,38 +275,97 @@ func (r *runtime) CompileModule(ctx context.Context, binary []byte) (CompiledMod
 		return nil, err
 	}
 
+	c, listeners, err := r.decodeModule(ctx, binary)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.store.Engine.CompileModule(ctx, c.module, listeners, r.ensureTermination); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CompileModuleText implements Runtime.CompileModuleText
+func (r *runtime) CompileModuleText(ctx context.Context, text []byte) (CompiledModule, error) {
+	if err := r.failIfClosed(); err != nil {
+		return nil, err
+	}
+
+	binary, err := wat.Compile(text)
+	if err != nil {
+		return nil, err
+	}
+	return r.CompileModule(ctx, binary)
+}
+
+// DeserializeModule implements Runtime.DeserializeModule
+func (r *runtime) DeserializeModule(ctx context.Context, serialized io.Reader) (CompiledModule, error) {
+	if err := r.failIfClosed(); err != nil {
+		return nil, err
+	}
+
+	es, ok := r.store.Engine.(wasm.EngineSerializer)
+	if !ok {
+		return nil, errors.New("compiled modules can only be deserialized by the compiler")
+	}
+
+	id, binary, err := readSerializedModuleHeader(serialized)
+	if err != nil {
+		return nil, err
+	}
+
+	c, listeners, err := r.decodeModule(ctx, binary)
+	if err != nil {
+		return nil, err
+	} else if c.module.ID != id {
+		return nil, errors.New("compiled module was serialized with a different RuntimeConfig or function listeners")
+	}
+	if err = es.DeserializeCompiledModule(c.module, listeners, r.ensureTermination, serialized); err != nil {
+		return nil, fmt.Errorf("invalid compiled module: %w", err)
+	}
+	return c, nil
+}
+
+// decodeModule decodes and validates the binary, and returns the compiledModule whose source has the ID assigned, but
+// is not compiled yet.
+func (r *runtime) decodeModule(ctx context.Context, binary []byte) (*compiledModule, []experimentalapi.FunctionListener, error) {
 	internal, err := binaryformat.DecodeModule(binary, r.enabledFeatures,
 		r.memoryLimitPages, r.memoryCapacityFromMax, !r.dwarfDisabled, r.storeCustomSections)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	} else if err = internal.Validate(r.enabledFeatures); err != nil {
 		// TODO: decoders should validate before returning, as that allows
 		// them to err with the correct position in the wasm binary.
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Now that the module is validated, cache the memory definitions.
+	// Now that the module is validated, cache the memory and table definitions.
 	// TODO: lazy initialization of memory definition.
 	internal.BuildMemoryDefinitions()
+	internal.BuildTableDefinitions()
 
 	c := &compiledModule{module: internal, compiledEngine: r.store.Engine}
+	if r.serialization {
+		c.binary = binary
+	}
 
 	// typeIDs are static and compile-time known.
 	typeIDs, err := r.store.GetFunctionTypeIDs(internal.TypeSection)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	c.typeIDs = typeIDs
 
 	listeners, err := buildFunctionListeners(ctx, internal)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	internal.FuelMetering = r.fuelMetering
+	internal.EpochInterruption = r.epochInterruption
+	internal.MaxCallStackDepth = r.maxCallStackDepth
+	internal.CoreDump = r.coreDump
 	internal.AssignModuleID(binary, listeners, r.ensureTermination)
-	if err = r.store.Engine.CompileModule(ctx, internal, listeners, r.ensureTermination); err != nil {
-		return nil, err
-	}
-	return c, nil
+	return c, listeners, nil
 }
 
 func buildFunctionListeners(ctx context.Context, internal *wasm.Module) ([]experimentalapi.FunctionListener, error) {
@@ -308,8 +436,15 @@ func (r *runtime) InstantiateModule(
 		name = code.module.NameSection.ModuleName
 	}
 
+	// The fuel and the limiter are passed explicitly, not via ctx, so that a module instantiated by a host function
+	// called from another module doesn't inherit those of the caller.
+	var limiter wasm.ResourceLimiter
+	if config.resourceLimiter != nil {
+		limiter = config.resourceLimiter
+	}
+
 	// Instantiate the module.
-	mod, err = r.store.Instantiate(ctx, code.module, name, sysCtx, code.typeIDs)
+	mod, err = r.store.Instantiate(ctx, code.module, name, sysCtx, code.typeIDs, config.fuel, limiter)
 	if err != nil {
 		// If there was an error, don't leak the compiled module.
 		if code.closeWithModule {
@@ -350,6 +485,16 @@ func (r *runtime) InstantiateModule(
 	return
 }
 
+// IncrementEpoch implements Runtime.IncrementEpoch
+func (r *runtime) IncrementEpoch() {
+	r.store.IncrementEpoch()
+}
+
+// Externrefs implements Runtime.Externrefs
+func (r *runtime) Externrefs() api.ExternrefTable {
+	return r.store.Externrefs
+}
+
 // Close implements api.Closer embedded in Runtime.
 func (r *runtime) Close(ctx context.Context) error {
 	return r.CloseWithExitCode(ctx, 0)
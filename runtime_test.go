@@ -13,9 +13,9 @@ import (
 	"github.com/AR1011/wazero/internal/filecache"
 	"github.com/AR1011/wazero/internal/leb128"
 	"github.com/AR1011/wazero/internal/platform"
-	"github.com/AR1011/wazero/internal/testing/binaryencoding"
 	"github.com/AR1011/wazero/internal/testing/require"
 	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
 	"github.com/AR1011/wazero/sys"
 )
 
@@ -96,7 +96,7 @@ func TestRuntime_CompileModule(t *testing.T) {
 		{
 			name: "MemorySection, but not exported",
 			wasm: &wasm.Module{
-				MemorySection: &wasm.Memory{Min: 2, Max: 3, IsMaxEncoded: true},
+				MemorySection: []wasm.Memory{{Min: 2, Max: 3, IsMaxEncoded: true}},
 			},
 			expected: func(compiled CompiledModule) {
 				require.Nil(t, compiled.ImportedMemories())
@@ -106,7 +106,7 @@ func TestRuntime_CompileModule(t *testing.T) {
 		{
 			name: "MemorySection exported",
 			wasm: &wasm.Module{
-				MemorySection: &wasm.Memory{Min: 2, Max: 3, IsMaxEncoded: true},
+				MemorySection: []wasm.Memory{{Min: 2, Max: 3, IsMaxEncoded: true}},
 				ExportSection: []wasm.Export{{
 					Type:  wasm.ExternTypeMemory,
 					Name:  "memory",
@@ -168,7 +168,7 @@ func TestRuntime_CompileModule_Errors(t *testing.T) {
 		},
 		{
 			name:        "memory has too many pages",
-			wasm:        binaryencoding.EncodeModule(&wasm.Module{MemorySection: &wasm.Memory{Min: 2, Cap: 2, Max: 70000, IsMaxEncoded: true}}),
+			wasm:        binaryencoding.EncodeModule(&wasm.Module{MemorySection: []wasm.Memory{{Min: 2, Cap: 2, Max: 70000, IsMaxEncoded: true}}}),
 			expectedErr: "section memory: max 70000 pages (4 Gi) over limit of 65536 pages (4 Gi)",
 		},
 	}
@@ -186,6 +186,34 @@ func TestRuntime_CompileModule_Errors(t *testing.T) {
 	}
 }
 
+func TestRuntime_CompileModuleText(t *testing.T) {
+	r := NewRuntime(testCtx)
+	defer r.Close(testCtx)
+
+	compiled, err := r.CompileModuleText(testCtx, []byte(`(module $math
+	(func $add (export "add") (param $x i32) (param $y i32) (result i32)
+		(i32.add (local.get $x) (local.get $y)))
+)`))
+	require.NoError(t, err)
+	require.Equal(t, "math", compiled.Name())
+
+	mod, err := r.InstantiateModule(testCtx, compiled, NewModuleConfig())
+	require.NoError(t, err)
+	results, err := mod.ExportedFunction("add").Call(testCtx, 2, 3)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{5}, results)
+
+	t.Run("syntax error", func(t *testing.T) {
+		_, err := r.CompileModuleText(testCtx, []byte("(module\n  (func i32.frob))"))
+		require.EqualError(t, err, "2:9: unknown instruction i32.frob")
+	})
+
+	t.Run("invalid module", func(t *testing.T) {
+		_, err := r.CompileModuleText(testCtx, []byte("(module (func (result i32)))"))
+		require.Error(t, err)
+	})
+}
+
 // TestModule_Memory only covers a couple cases to avoid duplication of internal/wasm/runtime_test.go
 func TestModule_Memory(t *testing.T) {
 	tests := []struct {
@@ -201,7 +229,7 @@ func TestModule_Memory(t *testing.T) {
 		{
 			name: "memory exported, one page",
 			wasm: binaryencoding.EncodeModule(&wasm.Module{
-				MemorySection: &wasm.Memory{Min: 1},
+				MemorySection: []wasm.Memory{{Min: 1}},
 				ExportSection: []wasm.Export{{Name: "memory", Type: api.ExternTypeMemory}},
 			}),
 			expected:    true,
@@ -373,7 +401,7 @@ func TestRuntime_Instantiate_DoesntEnforce_Start(t *testing.T) {
 	defer r.Close(testCtx)
 
 	binary := binaryencoding.EncodeModule(&wasm.Module{
-		MemorySection: &wasm.Memory{Min: 1},
+		MemorySection: []wasm.Memory{{Min: 1}},
 		ExportSection: []wasm.Export{{Name: "memory", Type: wasm.ExternTypeMemory, Index: 0}},
 	})
 
@@ -749,6 +777,13 @@ func TestRuntime_Closed(t *testing.T) {
 				return err
 			},
 		},
+		{
+			name: "CompileModuleText",
+			errFunc: func(r Runtime, mod CompiledModule) error {
+				_, err := r.CompileModuleText(testCtx, []byte("(module)"))
+				return err
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			engine := &mockEngine{name: "mock", cachedModules: map[*wasm.Module]struct{}{}}
@@ -0,0 +1,104 @@
+package wazero
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	goruntime "runtime"
+
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/u64"
+	"github.com/AR1011/wazero/internal/wasm"
+)
+
+// serializedModuleFormatVersion is incremented when the layout of the header written by
+// writeSerializedModuleHeader changes. The layout of the native code is versioned by each engine.
+const serializedModuleFormatVersion = 1
+
+// serializedModuleTarget is the platform which the native code is compiled for.
+const serializedModuleTarget = goruntime.GOOS + "/" + goruntime.GOARCH
+
+// writeSerializedModuleHeader writes the header of the serialized CompiledModule, which is followed by the native
+// code written by wasm.EngineSerializer:
+//
+//   - wasm.SerializedModuleMagic
+//   - serializedModuleFormatVersion (1 byte)
+//   - the length of serializedModuleTarget (1 byte) followed by it
+//   - the CPU features of the host (8 bytes)
+//   - the wasm.ModuleID (32 bytes)
+//   - the length of the source binary (8 bytes) followed by it
+func writeSerializedModuleHeader(w io.Writer, id wasm.ModuleID, source []byte) error {
+	buf := bytes.NewBuffer(nil)
+	buf.Write(wasm.SerializedModuleMagic)
+	buf.WriteByte(serializedModuleFormatVersion)
+	buf.WriteByte(byte(len(serializedModuleTarget)))
+	buf.WriteString(serializedModuleTarget)
+	buf.Write(u64.LeBytes(platform.CpuFeatures.Raw()))
+	buf.Write(id[:])
+	buf.Write(u64.LeBytes(uint64(len(source))))
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(source)
+	return err
+}
+
+// readSerializedModuleHeader reads the header written by writeSerializedModuleHeader, and returns the wasm.ModuleID
+// and the source binary after checking that the native code which follows can run on this host.
+func readSerializedModuleHeader(r io.Reader) (id wasm.ModuleID, source []byte, err error) {
+	prefix := make([]byte, len(wasm.SerializedModuleMagic)+2)
+	if _, err = io.ReadFull(r, prefix); err != nil {
+		err = fmt.Errorf("invalid compiled module: error reading header: %w", err)
+		return
+	} else if !bytes.Equal(prefix[:len(wasm.SerializedModuleMagic)], wasm.SerializedModuleMagic) {
+		err = errors.New("invalid compiled module: invalid magic number")
+		return
+	} else if version := prefix[len(wasm.SerializedModuleMagic)]; version != serializedModuleFormatVersion {
+		err = fmt.Errorf("invalid compiled module: unsupported format version %d", version)
+		return
+	}
+
+	target := make([]byte, prefix[len(prefix)-1])
+	if _, err = io.ReadFull(r, target); err != nil {
+		err = fmt.Errorf("invalid compiled module: error reading target: %w", err)
+		return
+	} else if string(target) != serializedModuleTarget {
+		err = fmt.Errorf("compiled module is for %s, not %s", target, serializedModuleTarget)
+		return
+	}
+
+	var eightBytes [8]byte
+	if _, err = io.ReadFull(r, eightBytes[:]); err != nil {
+		err = fmt.Errorf("invalid compiled module: error reading CPU features: %w", err)
+		return
+	} else if missing := binary.LittleEndian.Uint64(eightBytes[:]) &^ platform.CpuFeatures.Raw(); missing != 0 {
+		err = fmt.Errorf("compiled module requires CPU features which this host lacks: %#x", missing)
+		return
+	}
+
+	if _, err = io.ReadFull(r, id[:]); err != nil {
+		err = fmt.Errorf("invalid compiled module: error reading module ID: %w", err)
+		return
+	}
+
+	if _, err = io.ReadFull(r, eightBytes[:]); err != nil {
+		err = fmt.Errorf("invalid compiled module: error reading source length: %w", err)
+		return
+	}
+	sourceLen := binary.LittleEndian.Uint64(eightBytes[:])
+	if sourceLen > math.MaxUint32 {
+		err = fmt.Errorf("invalid compiled module: source length %d too large", sourceLen)
+		return
+	}
+	// Grow the buffer while reading, instead of allocating the given length up front.
+	buf := bytes.NewBuffer(nil)
+	if _, err = io.CopyN(buf, r, int64(sourceLen)); err != nil {
+		err = fmt.Errorf("invalid compiled module: error reading source: %w", err)
+		return
+	}
+	source = buf.Bytes()
+	return
+}
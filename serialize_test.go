@@ -0,0 +1,251 @@
+package wazero
+
+import (
+	"bytes"
+	goruntime "runtime"
+	"testing"
+
+	"github.com/AR1011/wazero/api"
+	"github.com/AR1011/wazero/internal/platform"
+	"github.com/AR1011/wazero/internal/testing/require"
+	"github.com/AR1011/wazero/internal/u64"
+	"github.com/AR1011/wazero/internal/wasm"
+	"github.com/AR1011/wazero/internal/wasm/binaryencoding"
+)
+
+// binaryAdd exports the function "add" which returns the sum of two i32 parameters.
+var binaryAdd = binaryencoding.EncodeModule(&wasm.Module{
+	TypeSection:     []wasm.FunctionType{{Params: []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, Results: []api.ValueType{api.ValueTypeI32}}},
+	FunctionSection: []wasm.Index{0},
+	CodeSection: []wasm.Code{{Body: []byte{
+		wasm.OpcodeLocalGet, 0, wasm.OpcodeLocalGet, 1, wasm.OpcodeI32Add, wasm.OpcodeEnd,
+	}}},
+	ExportSection: []wasm.Export{{Name: "add", Type: wasm.ExternTypeFunc, Index: 0}},
+})
+
+// serializableConfigs returns the RuntimeConfig of each compiler supported on this platform.
+func serializableConfigs() map[string]func() RuntimeConfig {
+	ret := map[string]func() RuntimeConfig{}
+	if platform.CompilerSupported() {
+		ret["compiler"] = func() RuntimeConfig {
+			return NewRuntimeConfigCompiler().WithSerialization(true)
+		}
+	}
+	if goruntime.GOARCH == "amd64" || goruntime.GOARCH == "arm64" {
+		ret["optimizing compiler"] = func() RuntimeConfig {
+			c := NewRuntimeConfig().WithSerialization(true)
+			c.(*runtimeConfig).EnableOptimizingCompiler()
+			return c
+		}
+	}
+	return ret
+}
+
+func TestCompiledModule_Serialize(t *testing.T) {
+	for name, newConfig := range serializableConfigs() {
+		newConfig := newConfig
+		t.Run(name, func(t *testing.T) {
+			r := NewRuntimeWithConfig(testCtx, newConfig())
+			defer r.Close(testCtx)
+
+			compiled, err := r.CompileModule(testCtx, binaryAdd)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			require.NoError(t, compiled.Serialize(&buf))
+			serialized := buf.Bytes()
+
+			// Deserialize in a new runtime which has never compiled the module.
+			deserializer := NewRuntimeWithConfig(testCtx, newConfig())
+			defer deserializer.Close(testCtx)
+
+			deserialized, err := deserializer.DeserializeModule(testCtx, bytes.NewReader(serialized))
+			require.NoError(t, err)
+			require.Equal(t, compiled.ExportedFunctions()["add"].DebugName(), deserialized.ExportedFunctions()["add"].DebugName())
+
+			mod, err := deserializer.InstantiateModule(testCtx, deserialized, NewModuleConfig())
+			require.NoError(t, err)
+			res, err := mod.ExportedFunction("add").Call(testCtx, 1, 2)
+			require.NoError(t, err)
+			require.Equal(t, []uint64{3}, res)
+
+			// The deserialized module can be serialized again.
+			buf.Reset()
+			require.NoError(t, deserialized.Serialize(&buf))
+			require.Equal(t, serialized, buf.Bytes())
+		})
+	}
+
+	t.Run("interpreter", func(t *testing.T) {
+		r := NewRuntimeWithConfig(testCtx, NewRuntimeConfigInterpreter().WithSerialization(true))
+		defer r.Close(testCtx)
+
+		compiled, err := r.CompileModule(testCtx, binaryAdd)
+		require.NoError(t, err)
+		err = compiled.Serialize(&bytes.Buffer{})
+		require.EqualError(t, err, "compiled modules can only be serialized by the compiler")
+	})
+
+	for name, newConfig := range serializableConfigs() {
+		newConfig := newConfig
+		t.Run(name+" without WithSerialization", func(t *testing.T) {
+			r := NewRuntimeWithConfig(testCtx, newConfig().WithSerialization(false))
+			defer r.Close(testCtx)
+
+			compiled, err := r.CompileModule(testCtx, binaryAdd)
+			require.NoError(t, err)
+			require.Nil(t, compiled.(*compiledModule).binary)
+			err = compiled.Serialize(&bytes.Buffer{})
+			require.EqualError(t, err, "compiled modules can only be serialized when RuntimeConfig.WithSerialization is enabled")
+		})
+	}
+
+	for name, newConfig := range serializableConfigs() {
+		newConfig := newConfig
+		t.Run(name+" host module", func(t *testing.T) {
+			r := NewRuntimeWithConfig(testCtx, newConfig())
+			defer r.Close(testCtx)
+
+			compiled, err := r.NewHostModuleBuilder("host").
+				NewFunctionBuilder().WithFunc(func() {}).Export("noop").
+				Compile(testCtx)
+			require.NoError(t, err)
+			err = compiled.Serialize(&bytes.Buffer{})
+			require.EqualError(t, err, "host modules cannot be serialized")
+		})
+	}
+}
+
+func TestRuntime_DeserializeModule_Errors(t *testing.T) {
+	t.Run("interpreter", func(t *testing.T) {
+		r := NewRuntimeWithConfig(testCtx, NewRuntimeConfigInterpreter())
+		defer r.Close(testCtx)
+
+		_, err := r.DeserializeModule(testCtx, bytes.NewReader(nil))
+		require.EqualError(t, err, "compiled modules can only be deserialized by the compiler")
+	})
+
+	for name, newConfig := range serializableConfigs() {
+		newConfig := newConfig
+		t.Run(name, func(t *testing.T) {
+			r := NewRuntimeWithConfig(testCtx, newConfig())
+			defer r.Close(testCtx)
+
+			compiled, err := r.CompileModule(testCtx, binaryAdd)
+			require.NoError(t, err)
+			var buf bytes.Buffer
+			require.NoError(t, compiled.Serialize(&buf))
+			serialized := buf.Bytes()
+
+			t.Run("wasm binary", func(t *testing.T) {
+				_, err := r.DeserializeModule(testCtx, bytes.NewReader(binaryAdd))
+				require.EqualError(t, err, "invalid compiled module: invalid magic number")
+			})
+
+			t.Run("different config", func(t *testing.T) {
+				other := NewRuntimeWithConfig(testCtx, newConfig().WithCloseOnContextDone(true))
+				defer other.Close(testCtx)
+
+				_, err := other.DeserializeModule(testCtx, bytes.NewReader(serialized))
+				require.EqualError(t, err, "compiled module was serialized with a different RuntimeConfig or function listeners")
+			})
+
+			t.Run("truncated native code", func(t *testing.T) {
+				other := NewRuntimeWithConfig(testCtx, newConfig())
+				defer other.Close(testCtx)
+
+				_, err := other.DeserializeModule(testCtx, bytes.NewReader(serialized[:len(serialized)-1]))
+				require.Error(t, err)
+				require.Contains(t, err.Error(), "invalid compiled module: compilationcache: ")
+			})
+		})
+	}
+}
+
+func Test_readSerializedModuleHeader(t *testing.T) {
+	id := wasm.ModuleID{1, 2, 3}
+	var buf bytes.Buffer
+	require.NoError(t, writeSerializedModuleHeader(&buf, id, binaryAdd))
+	header := buf.Bytes()
+
+	t.Run("ok", func(t *testing.T) {
+		r := bytes.NewReader(append(header, 0xff))
+		actualID, source, err := readSerializedModuleHeader(r)
+		require.NoError(t, err)
+		require.Equal(t, id, actualID)
+		require.Equal(t, binaryAdd, source)
+		require.Equal(t, 1, r.Len()) // The native code is left unread.
+	})
+
+	// targetLenOffset is the offset of the length of serializedModuleTarget.
+	targetLenOffset := len(wasm.SerializedModuleMagic) + 1
+	cpuFeaturesOffset := targetLenOffset + 1 + len(serializedModuleTarget)
+	sourceLenOffset := cpuFeaturesOffset + 8 + len(id)
+
+	tests := []struct {
+		name   string
+		input  func() []byte
+		expErr string
+	}{
+		{
+			name:   "empty",
+			input:  func() []byte { return nil },
+			expErr: "invalid compiled module: error reading header: EOF",
+		},
+		{
+			name: "format version",
+			input: func() []byte {
+				ret := append([]byte{}, header...)
+				ret[len(wasm.SerializedModuleMagic)] = serializedModuleFormatVersion + 1
+				return ret
+			},
+			expErr: "invalid compiled module: unsupported format version 2",
+		},
+		{
+			name: "target",
+			input: func() []byte {
+				ret := append([]byte{}, header[:targetLenOffset]...)
+				ret = append(ret, byte(len("plan9/mips")))
+				ret = append(ret, "plan9/mips"...)
+				return append(ret, header[cpuFeaturesOffset:]...)
+			},
+			expErr: "compiled module is for plan9/mips, not " + serializedModuleTarget,
+		},
+		{
+			name: "CPU features",
+			input: func() []byte {
+				ret := append([]byte{}, header...)
+				copy(ret[cpuFeaturesOffset:], u64.LeBytes(platform.CpuFeatures.Raw()|1<<63))
+				return ret
+			},
+			expErr: "compiled module requires CPU features which this host lacks: 0x8000000000000000",
+		},
+		{
+			name:   "truncated module ID",
+			input:  func() []byte { return header[:cpuFeaturesOffset+10] },
+			expErr: "invalid compiled module: error reading module ID: unexpected EOF",
+		},
+		{
+			name: "source too large",
+			input: func() []byte {
+				ret := append([]byte{}, header...)
+				copy(ret[sourceLenOffset:], u64.LeBytes(1<<40))
+				return ret
+			},
+			expErr: "invalid compiled module: source length 1099511627776 too large",
+		},
+		{
+			name:   "truncated source",
+			input:  func() []byte { return header[:len(header)-1] },
+			expErr: "invalid compiled module: error reading source: EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := readSerializedModuleHeader(bytes.NewReader(tc.input()))
+			require.EqualError(t, err, tc.expErr)
+		})
+	}
+}
@@ -6,13 +6,20 @@ import (
 	"fmt"
 )
 
-// These two special exit codes are reserved by wazero for context Cancel and Timeout integrations.
-// The assumption here is that well-behaving Wasm programs won't use these two exit codes.
+// These special exit codes are reserved by wazero for context Cancel and Timeout integrations, fuel metering and
+// epoch interruption.
+// The assumption here is that well-behaving Wasm programs won't use these exit codes.
 const (
 	// ExitCodeContextCanceled corresponds to context.Canceled and returned by ExitError.ExitCode in that case.
 	ExitCodeContextCanceled uint32 = 0xffffffff
 	// ExitCodeDeadlineExceeded corresponds to context.DeadlineExceeded and returned by ExitError.ExitCode in that case.
 	ExitCodeDeadlineExceeded uint32 = 0xefffffff
+	// ExitCodeFuelExhausted is returned by ExitError.ExitCode when the api.Module ran out of fuel.
+	// See wazero.RuntimeConfig WithFuelMetering.
+	ExitCodeFuelExhausted uint32 = 0xdfffffff
+	// ExitCodeEpochDeadlineExceeded is returned by ExitError.ExitCode when the epoch of the wazero.Runtime reached
+	// the deadline of the api.Module. See wazero.RuntimeConfig WithEpochInterruption.
+	ExitCodeEpochDeadlineExceeded uint32 = 0xcfffffff
 )
 
 // ExitError is returned to a caller of api.Function when api.Module CloseWithExitCode was invoked,
@@ -36,6 +43,9 @@ const (
 // https://www.assemblyscript.org/concepts.html#special-imports
 //
 // Note: In the case of context cancellation or timeout, the api.Module from which the api.Function created is closed.
+// On the other hand, running out of fuel (ExitCodeFuelExhausted) doesn't close the api.Module, so the function can be
+// called again after adding fuel with api.Module AddFuel. Similarly, exceeding the epoch deadline
+// (ExitCodeEpochDeadlineExceeded) only traps the current call.
 type ExitError struct {
 	// Note: this is a struct not a uint32 type as it was originally one and
 	// we don't want to break call-sites that cast into it.
@@ -63,6 +73,10 @@ func (e *ExitError) Error() string {
 		return fmt.Sprintf("module closed with %s", context.Canceled)
 	case ExitCodeDeadlineExceeded:
 		return fmt.Sprintf("module closed with %s", context.DeadlineExceeded)
+	case ExitCodeFuelExhausted:
+		return "module ran out of fuel"
+	case ExitCodeEpochDeadlineExceeded:
+		return "module exceeded the epoch deadline"
 	default:
 		return fmt.Sprintf("module closed with exit_code(%d)", e.exitCode)
 	}
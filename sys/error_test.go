@@ -65,6 +65,16 @@ func TestExitError_Error(t *testing.T) {
 		require.EqualError(t, err, "module closed with context canceled")
 		require.ErrorIs(t, err, context.Canceled, "exit code context canceled should work")
 	})
+	t.Run("fuel exhausted", func(t *testing.T) {
+		err := sys.NewExitError(sys.ExitCodeFuelExhausted)
+		require.Equal(t, sys.ExitCodeFuelExhausted, err.ExitCode())
+		require.EqualError(t, err, "module ran out of fuel")
+	})
+	t.Run("epoch deadline exceeded", func(t *testing.T) {
+		err := sys.NewExitError(sys.ExitCodeEpochDeadlineExceeded)
+		require.Equal(t, sys.ExitCodeEpochDeadlineExceeded, err.ExitCode())
+		require.EqualError(t, err, "module exceeded the epoch deadline")
+	})
 	t.Run("normal", func(t *testing.T) {
 		err := sys.NewExitError(123)
 		require.Equal(t, uint32(123), err.ExitCode())